@@ -2,6 +2,7 @@ package private
 
 import (
 	"bytes"
+	"context"
 	"crypto/hmac"
 	"crypto/sha256"
 	"encoding/hex"
@@ -15,8 +16,12 @@ import (
 	"time"
 
 	"github.com/fxpgr/go-exchange-client/api/public"
+	"github.com/fxpgr/go-exchange-client/apierrors"
+	"github.com/fxpgr/go-exchange-client/decimal"
 	"github.com/fxpgr/go-exchange-client/helpers"
 	"github.com/fxpgr/go-exchange-client/models"
+	"github.com/fxpgr/go-exchange-client/retry"
+	"github.com/fxpgr/go-exchange-client/symbols"
 	"github.com/pkg/errors"
 	"github.com/tidwall/gjson"
 )
@@ -25,6 +30,8 @@ const (
 	BINANCE_BASE_URL = "https://api.binance.com"
 )
 
+var binanceSymbols = symbols.For("binance")
+
 func NewBinanceApi(apikey func() (string, error), apisecret func() (string, error)) (*BinanceApi, error) {
 	hitbtcPublic, err := public.NewBinancePublicApi()
 	if err != nil {
@@ -47,6 +54,7 @@ func NewBinanceApi(apikey func() (string, error), apisecret func() (string, erro
 		}
 	}
 	b := &BinanceApi{
+		HttpClient:        http.Client{Transport: newTransport("binance", nil)},
 		BaseURL:           BINANCE_BASE_URL,
 		apiV1:             BINANCE_BASE_URL + "/api/v1/",
 		apiV3:             BINANCE_BASE_URL + "/api/v3/",
@@ -72,11 +80,12 @@ type BinanceApi struct {
 	BaseURL           string
 	RateCacheDuration time.Duration
 	HttpClient        http.Client
-	rt                *http.Transport
-	settlements       []string
-	apiV1             string
-	apiV3             string
-	timeoffset        int64
+	clientContext
+	rt          *http.Transport
+	settlements []string
+	apiV1       string
+	apiV3       string
+	timeoffset  int64
 
 	volumeMap       map[string]map[string]float64
 	rateMap         map[string]map[string]float64
@@ -92,6 +101,12 @@ func (h *BinanceApi) privateApiUrl() string {
 	return h.BaseURL
 }
 
+func (h *BinanceApi) WithContext(ctx context.Context) PrivateClient {
+	c := *h
+	c.ctx = ctx
+	return &c
+}
+
 func (h *BinanceApi) publicApiUrl(command string) string {
 	return h.BaseURL + command
 }
@@ -103,7 +118,7 @@ func (h *BinanceApi) fetchPrecision() error {
 
 	h.precisionMap = make(map[string]map[string]models.Precisions)
 	url := h.publicApiUrl("/api/v1/exchangeInfo")
-	req, err := requestGetAsChrome(url)
+	req, err := requestGetAsChrome(h.context(), url)
 	if err != nil {
 		return errors.Wrapf(err, "failed to fetch %s", url)
 	}
@@ -118,17 +133,23 @@ func (h *BinanceApi) fetchPrecision() error {
 	}
 	value := gjson.ParseBytes(byteArray)
 	for _, v := range value.Get("symbols").Array() {
-		trading := v.Get("baseAsset").Str
-		settlement := v.Get("quoteAsset").Str
+		pair := binanceSymbols.AddPair(v.Get("baseAsset").Str, v.Get("quoteAsset").Str)
+		trading, settlement := pair.Trading, pair.Settlement
 		m, ok := h.precisionMap[trading]
 		if !ok {
 			m = make(map[string]models.Precisions)
 			h.precisionMap[trading] = m
 		}
-		m[settlement] = models.Precisions{
-			PricePrecision:  int(v.Get("baseAssetPrecision").Int()),
-			AmountPrecision: int(v.Get("quotePrecision").Int()),
+		var rules models.MarketRules
+		for _, f := range v.Get("filters").Array() {
+			switch f.Get("filterType").Str {
+			case "PRICE_FILTER":
+				rules.TickSize = helpers.ToDecimal(f.Get("tickSize"))
+			case "LOT_SIZE":
+				rules.StepSize = helpers.ToDecimal(f.Get("stepSize"))
+			}
 		}
+		m[settlement] = rules.Precisions()
 	}
 
 	return errors.Wrapf(err, "failed to fetch %s", url)
@@ -141,9 +162,9 @@ func (h *BinanceApi) precise(trading string, settlement string) (*models.Precisi
 
 	h.fetchPrecision()
 	if m, ok := h.precisionMap[trading]; !ok {
-		return &models.Precisions{}, errors.Errorf("%s/%s missing trading", trading, settlement)
+		return &models.Precisions{}, errors.Wrapf(apierrors.ErrInvalidSymbol, "%s/%s missing trading", trading, settlement)
 	} else if precisions, ok := m[settlement]; !ok {
-		return &models.Precisions{}, errors.Errorf("%s/%s missing settlement", trading, settlement)
+		return &models.Precisions{}, errors.Wrapf(apierrors.ErrInvalidSymbol, "%s/%s missing settlement", trading, settlement)
 	} else {
 		return &precisions, nil
 	}
@@ -156,8 +177,12 @@ func (h *BinanceApi) privateApi(method string, path string, params *url.Values)
 	nonce := time.Now().Unix() * 1000
 	params.Set("timestamp", fmt.Sprintf("%d", nonce))
 
+	ctx := h.context()
+	if params.Get("newClientOrderId") != "" {
+		ctx = retry.Idempotent(ctx)
+	}
 	reader := bytes.NewReader([]byte(params.Encode()))
-	req, err := http.NewRequest(method, urlStr, reader)
+	req, err := http.NewRequestWithContext(ctx, method, urlStr, reader)
 	if err != nil {
 		return nil, errors.Wrapf(err, "failed to create request command %s", path)
 	}
@@ -190,8 +215,42 @@ func (h *BinanceApi) privateApi(method string, path string, params *url.Values)
 	if err != nil {
 		return nil, errors.Wrapf(err, "failed to fetch result of command %s", path)
 	}
+	if err := binanceError(res.StatusCode, resBody); err != nil {
+		return nil, errors.Wrapf(err, "failed to request command %s", path)
+	}
 	return resBody, err
 }
+
+var binanceErrorCodes = map[string]error{
+	"-1002": apierrors.ErrAuth,
+	"-1003": apierrors.ErrRateLimited,
+	"-1015": apierrors.ErrRateLimited,
+	"-1016": apierrors.ErrMaintenance,
+	"-1021": apierrors.ErrAuth,
+	"-1022": apierrors.ErrAuth,
+	"-1121": apierrors.ErrInvalidSymbol,
+	"-2011": apierrors.ErrOrderNotFound,
+	"-2013": apierrors.ErrOrderNotFound,
+	"-2014": apierrors.ErrAuth,
+	"-2015": apierrors.ErrAuth,
+}
+
+func binanceError(statusCode int, body []byte) error {
+	value := gjson.ParseBytes(body)
+	code := value.Get("code")
+	if statusCode == http.StatusOK && !code.Exists() {
+		return nil
+	}
+	message := value.Get("msg").String()
+	kind := binanceErrorCodes[code.String()]
+	switch {
+	case statusCode == http.StatusTeapot:
+		kind = apierrors.ErrIPBanned
+	case strings.Contains(strings.ToLower(message), "insufficient balance"):
+		kind = apierrors.ErrInsufficientFunds
+	}
+	return apiError(statusCode, code.String(), message, kind)
+}
 func (h *BinanceApi) coins() ([]string, error) {
 	h.currencyM.Lock()
 	defer h.currencyM.Unlock()
@@ -213,7 +272,7 @@ func (h *BinanceApi) coins() ([]string, error) {
 		return coins, nil
 	}
 	url := h.publicApiUrl("/api/v1/exchangeInfo")
-	req, err := requestGetAsChrome(url)
+	req, err := requestGetAsChrome(h.context(), url)
 	if err != nil {
 		return coins, errors.Wrapf(err, "failed to fetch %s", url)
 	}
@@ -229,8 +288,8 @@ func (h *BinanceApi) coins() ([]string, error) {
 	value := gjson.ParseBytes(byteArray)
 	coinsWithDup := make([]string, 0)
 	for _, v := range value.Get("symbols").Array() {
-		coinsWithDup = append(coinsWithDup, v.Get("baseAsset").String())
-		coinsWithDup = append(coinsWithDup, v.Get("quoteAsset").String())
+		coinsWithDup = append(coinsWithDup, binanceSymbols.Currency(v.Get("baseAsset").String()))
+		coinsWithDup = append(coinsWithDup, binanceSymbols.Currency(v.Get("quoteAsset").String()))
 	}
 	m := make(map[string]struct{})
 	for _, element := range coinsWithDup {
@@ -249,7 +308,7 @@ func (h *BinanceApi) CurrencyPairs() ([]models.CurrencyPair, error) {
 		return h.currencyPairs, nil
 	}
 	url := h.publicApiUrl("/api/v1/exchangeInfo")
-	req, err := requestGetAsChrome(url)
+	req, err := requestGetAsChrome(h.context(), url)
 	if err != nil {
 		return h.currencyPairs, errors.Wrapf(err, "failed to fetch %s", url)
 	}
@@ -265,12 +324,7 @@ func (h *BinanceApi) CurrencyPairs() ([]models.CurrencyPair, error) {
 	currencyPairs := make([]models.CurrencyPair, 0)
 	value := gjson.ParseBytes(byteArray)
 	for _, v := range value.Get("symbols").Array() {
-		trading := v.Get("baseAsset").Str
-		settlement := v.Get("quoteAsset").Str
-		currencyPairs = append(currencyPairs, models.CurrencyPair{
-			Trading:    trading,
-			Settlement: settlement,
-		})
+		currencyPairs = append(currencyPairs, binanceSymbols.AddPair(v.Get("baseAsset").Str, v.Get("quoteAsset").Str))
 	}
 	h.currencyPairs = currencyPairs
 	return currencyPairs, nil
@@ -278,7 +332,7 @@ func (h *BinanceApi) CurrencyPairs() ([]models.CurrencyPair, error) {
 
 func (h *BinanceApi) TradeFeeRates() (map[string]map[string]TradeFee, error) {
 	url := public.BINANCE_BASE_URL + "/api/v3/account"
-	resp, err := h.HttpClient.Get(url)
+	resp, err := httpGet(h.context(), &h.HttpClient, url)
 	if err != nil {
 		return nil, errors.Wrapf(err, "failed to fetch %s", url)
 	}
@@ -289,8 +343,8 @@ func (h *BinanceApi) TradeFeeRates() (map[string]map[string]TradeFee, error) {
 		return nil, errors.Wrapf(err, "failed to fetch %s", url)
 	}
 	value := gjson.ParseBytes(byteArray)
-	makerFee := value.Get("makerCommission").Num / 10000
-	takerFee := value.Get("takerCommission").Num / 10000
+	makerFee := helpers.ToDecimal(value.Get("makerCommission")).Mul(decimal.New(1, -4))
+	takerFee := helpers.ToDecimal(value.Get("takerCommission")).Mul(decimal.New(1, -4))
 	traderFeeMap := make(map[string]map[string]TradeFee)
 	for _, pair := range h.currencyPairs {
 		m, ok := traderFeeMap[pair.Trading]
@@ -355,7 +409,7 @@ func (h *BinanceApi) TransferFee() (map[string]float64, error) {
 	for k, v := range adv {
 		vv := v.(map[string]interface{})
 		fee := vv["withdrawFee"].(float64)
-		transferFeeMap.Set(strings.ToUpper(k), fee)
+		transferFeeMap.Set(binanceSymbols.Currency(k), fee)
 	}*/
 	transferFeeMap.Set("CTR", 35.00000000)
 	transferFeeMap.Set("MATIC", 0.46000000)
@@ -603,7 +657,7 @@ func (h *BinanceApi) Balances() (map[string]float64, error) {
 		return nil, err
 	}
 	path := h.apiV3 + ACCOUNT_URI + params.Encode()
-	respmap, err := helpers.HttpGet2(&h.HttpClient, path, map[string]string{"X-MBX-APIKEY": apiKey})
+	respmap, err := helpers.HttpGet2WithContext(h.context(), &h.HttpClient, path, map[string]string{"X-MBX-APIKEY": apiKey})
 	if err != nil {
 		return nil, err
 	}
@@ -620,7 +674,7 @@ func (h *BinanceApi) Balances() (map[string]float64, error) {
 	for _, v := range balances {
 		vv := v.(map[string]interface{})
 		currency := vv["asset"].(string)
-		currency = strings.ToUpper(currency)
+		currency = binanceSymbols.Currency(currency)
 		m[currency] = helpers.ToFloat64(vv["free"])
 	}
 	return m, nil
@@ -639,17 +693,17 @@ func (h *BinanceApi) CompleteBalances() (map[string]*models.Balance, error) {
 		return nil, err
 	}
 	path := h.apiV3 + ACCOUNT_URI + params.Encode()
-	respmap, err := helpers.HttpGet2(&h.HttpClient, path, map[string]string{"X-MBX-APIKEY": apiKey})
+	respmap, err := helpers.HttpGet2WithContext(h.context(), &h.HttpClient, path, map[string]string{"X-MBX-APIKEY": apiKey})
 
 	m := make(map[string]*models.Balance)
 	balances := respmap["balances"].([]interface{})
 	for _, v := range balances {
 		vv := v.(map[string]interface{})
 		currency := vv["asset"].(string)
-		currency = strings.ToUpper(currency)
+		currency = binanceSymbols.Currency(currency)
 		m[currency] = &models.Balance{
-			Available: helpers.ToFloat64(vv["free"]),
-			OnOrders:  helpers.ToFloat64(vv["locked"]),
+			Available: helpers.ToDecimal(vv["free"]),
+			OnOrders:  helpers.ToDecimal(vv["locked"]),
 		}
 	}
 	return m, nil
@@ -679,26 +733,44 @@ func (h *BinanceApi) ActiveOrders() ([]*models.Order, error) {
 }
 
 func (h *BinanceApi) Order(trading string, settlement string, ordertype models.OrderType, price float64, amount float64) (string, error) {
+	return h.PlaceOrder(models.OrderRequest{Trading: trading, Settlement: settlement, Type: ordertype, Price: decimal.NewFromFloat(price), Amount: decimal.NewFromFloat(amount)})
+}
+
+func (h *BinanceApi) PlaceOrder(req models.OrderRequest) (string, error) {
 	params := &url.Values{}
 
-	symbol := strings.ToUpper(fmt.Sprintf("%s%s", trading, settlement))
+	symbol := binanceSymbols.Symbol(req.Trading, req.Settlement)
 	params.Set("symbol", symbol)
 
-	if ordertype == models.Bid {
+	if req.Type == models.Bid {
 		params.Set("side", "SELL")
-	} else if ordertype == models.Ask {
+	} else if req.Type == models.Ask {
 		params.Set("side", "BUY")
 	} else {
-		return "", errors.Errorf("unknown order type %d", ordertype)
+		return "", errors.Errorf("unknown order type %d", req.Type)
+	}
+	switch {
+	case req.ExecutionType == models.Market && req.TimeInForce == models.GTC:
+		params.Set("type", "MARKET")
+	case req.ExecutionType == models.Limit && req.TimeInForce == models.PostOnly:
+		params.Set("type", "LIMIT_MAKER")
+	case req.ExecutionType == models.Limit:
+		params.Set("type", "LIMIT")
+		params.Set("timeInForce", req.TimeInForce.String())
+	default:
+		return "", unsupportedOrder("binance", req)
 	}
-	params.Set("type", "LIMIT")
-	params.Set("timeInForce", "GTC")
-	precise, err := h.precise(trading, settlement)
+	if id := retry.ClientOrderID(h.context()); id != "" {
+		params.Set("newClientOrderId", id)
+	}
+	precise, err := h.precise(req.Trading, req.Settlement)
 	if err != nil {
 		return "", err
 	}
-	params.Set("quantity", FloorFloat64ToStr(amount, precise.AmountPrecision))
-	params.Set("price", FloorFloat64ToStr(price, precise.PricePrecision))
+	params.Set("quantity", FloorDecimalToStr(req.Amount, precise.AmountPrecision))
+	if req.ExecutionType == models.Limit {
+		params.Set("price", FloorDecimalToStr(req.Price, precise.PricePrecision))
+	}
 
 	byteArray, err := h.privateApi("POST", "/api/v3/order", params)
 	if err != nil {
@@ -722,7 +794,7 @@ func (h *BinanceApi) Transfer(typ string, addr string, amount float64, additiona
 func (h *BinanceApi) CancelOrder(trading string, settlement string,
 	ordertype models.OrderType, orderNumber string) error {
 	params := &url.Values{}
-	params.Set("symbol", trading+settlement)
+	params.Set("symbol", binanceSymbols.Symbol(trading, settlement))
 	params.Set("origClientOrderId", orderNumber)
 
 	bs, err := h.privateApi("DELETE", "/api/v3/order", params)
@@ -738,7 +810,7 @@ func (h *BinanceApi) CancelOrder(trading string, settlement string,
 
 func (h *BinanceApi) IsOrderFilled(trading string, settlement string, orderNumber string) (bool, error) {
 	params := &url.Values{}
-	params.Set("symbol", trading+settlement)
+	params.Set("symbol", binanceSymbols.Symbol(trading, settlement))
 	params.Set("origClientOrderId", orderNumber)
 	bs, err := h.privateApi("GET", "/api/v3/order", params)
 	if err != nil {
@@ -751,6 +823,232 @@ func (h *BinanceApi) IsOrderFilled(trading string, settlement string, orderNumbe
 	return false, nil
 }
 
+var binanceOrderStatus = map[string]models.OrderStatus{
+	"NEW":              models.OrderOpen,
+	"PARTIALLY_FILLED": models.OrderPartiallyFilled,
+	"FILLED":           models.OrderFilled,
+	"CANCELED":         models.OrderCanceled,
+	"REJECTED":         models.OrderRejected,
+	"EXPIRED":          models.OrderExpired,
+}
+
+// binanceStatus reads the status of an order which filled filled. An order
+// whose cancel is pending can still fill, so it is open or partially filled.
+func binanceStatus(status string, filled decimal.Decimal) models.OrderStatus {
+	if status == "PENDING_CANCEL" {
+		if filled.IsPositive() {
+			return models.OrderPartiallyFilled
+		}
+		return models.OrderOpen
+	}
+	return binanceOrderStatus[status]
+}
+
+func (h *BinanceApi) OrderStatus(trading string, settlement string, orderNumber string) (*models.Order, error) {
+	params := &url.Values{}
+	params.Set("symbol", binanceSymbols.Symbol(trading, settlement))
+	params.Set("origClientOrderId", orderNumber)
+	bs, err := h.privateApi("GET", "/api/v3/order", params)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to get order %s", orderNumber)
+	}
+	value := gjson.ParseBytes(bs)
+	order := &models.Order{
+		ExchangeOrderID: orderNumber,
+		Type:            models.Ask,
+		Trading:         trading,
+		Settlement:      settlement,
+		Price:           helpers.ToDecimal(value.Get("price")),
+		Amount:          helpers.ToDecimal(value.Get("origQty")),
+		FilledAmount:    helpers.ToDecimal(value.Get("executedQty")),
+		CreatedAt:       millisToTime(value.Get("time").Int()),
+		UpdatedAt:       millisToTime(value.Get("updateTime").Int()),
+	}
+	order.Status = binanceStatus(value.Get("status").Str, order.FilledAmount)
+	if value.Get("side").Str == "SELL" {
+		order.Type = models.Bid
+	}
+	order.AveragePrice = averagePrice(helpers.ToDecimal(value.Get("cummulativeQuoteQty")), order.FilledAmount)
+	if order.FilledAmount.IsZero() {
+		return order, nil
+	}
+
+	// the order endpoint does not report commissions, they are on the fills
+	params = &url.Values{}
+	params.Set("symbol", binanceSymbols.Symbol(trading, settlement))
+	params.Set("orderId", value.Get("orderId").String())
+	bs, err = h.privateApi("GET", "/api/v3/myTrades", params)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to get trades of order %s", orderNumber)
+	}
+	for _, trade := range gjson.ParseBytes(bs).Array() {
+		order.Fee = order.Fee.Add(helpers.ToDecimal(trade.Get("commission")))
+		order.FeeCurrency = binanceSymbols.Currency(trade.Get("commissionAsset").Str)
+	}
+	return order, nil
+}
+
+const binanceTradesPageSize = 1000
+
+func (h *BinanceApi) MyTrades(trading string, settlement string, since time.Time, limit int) ([]*models.Trade, error) {
+	var trades []*models.Trade
+	fromID := ""
+	for {
+		params := &url.Values{}
+		params.Set("symbol", binanceSymbols.Symbol(trading, settlement))
+		params.Set("limit", strconv.Itoa(binanceTradesPageSize))
+		if fromID != "" {
+			params.Set("fromId", fromID)
+		} else if !since.IsZero() {
+			params.Set("startTime", strconv.FormatInt(since.UnixNano()/int64(time.Millisecond), 10))
+		}
+		bs, err := h.privateApi("GET", "/api/v3/myTrades", params)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to get trades of %s/%s", trading, settlement)
+		}
+		page := gjson.ParseBytes(bs).Array()
+		for _, v := range page {
+			trade := &models.Trade{
+				ID:          v.Get("id").String(),
+				OrderID:     v.Get("orderId").String(),
+				Trading:     trading,
+				Settlement:  settlement,
+				Type:        models.Bid,
+				Price:       helpers.ToDecimal(v.Get("price")),
+				Amount:      helpers.ToDecimal(v.Get("qty")),
+				Fee:         helpers.ToDecimal(v.Get("commission")),
+				FeeCurrency: binanceSymbols.Currency(v.Get("commissionAsset").Str),
+				Time:        millisToTime(v.Get("time").Int()),
+			}
+			if v.Get("isBuyer").Bool() {
+				trade.Type = models.Ask
+			}
+			trades = append(trades, trade)
+		}
+		if len(page) < binanceTradesPageSize || (limit > 0 && len(trades) >= limit) {
+			break
+		}
+		fromID = strconv.FormatInt(page[len(page)-1].Get("id").Int()+1, 10)
+	}
+	trades = trimTrades(trades, since, limit)
+
+	// orders are known by their client order id, which fills do not carry
+	clientIDs, err := h.clientOrderIDs(trading, settlement, trades)
+	if err != nil {
+		return nil, err
+	}
+	for _, trade := range trades {
+		if id, ok := clientIDs[trade.OrderID]; ok {
+			trade.OrderID = id
+		}
+	}
+	return trades, nil
+}
+
+// clientOrderIDs maps the order ids of trades to their client order ids,
+// paging through the orders of the pair from the oldest one traded.
+func (h *BinanceApi) clientOrderIDs(trading string, settlement string, trades []*models.Trade) (map[string]string, error) {
+	clientIDs := make(map[string]string)
+	wanted := make(map[string]bool)
+	var fromID int64 = -1
+	for _, trade := range trades {
+		wanted[trade.OrderID] = true
+		if id, err := strconv.ParseInt(trade.OrderID, 10, 64); err == nil && (fromID < 0 || id < fromID) {
+			fromID = id
+		}
+	}
+	if fromID < 0 {
+		return clientIDs, nil
+	}
+	for {
+		params := &url.Values{}
+		params.Set("symbol", binanceSymbols.Symbol(trading, settlement))
+		params.Set("orderId", strconv.FormatInt(fromID, 10))
+		params.Set("limit", strconv.Itoa(binanceTradesPageSize))
+		bs, err := h.privateApi("GET", "/api/v3/allOrders", params)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to get orders of %s/%s", trading, settlement)
+		}
+		page := gjson.ParseBytes(bs).Array()
+		for _, v := range page {
+			if id := v.Get("orderId").String(); wanted[id] {
+				clientIDs[id] = v.Get("clientOrderId").Str
+			}
+		}
+		if len(page) < binanceTradesPageSize || len(clientIDs) == len(wanted) {
+			return clientIDs, nil
+		}
+		fromID = page[len(page)-1].Get("orderId").Int() + 1
+	}
+}
+
+func (h *BinanceApi) Deposits(since time.Time) ([]*models.Transfer, error) {
+	params := &url.Values{}
+	if !since.IsZero() {
+		params.Set("startTime", strconv.FormatInt(since.UnixNano()/int64(time.Millisecond), 10))
+	}
+	bs, err := h.privateApi("GET", "/wapi/v3/depositHistory.html", params)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get deposits")
+	}
+	var transfers []*models.Transfer
+	for _, v := range gjson.ParseBytes(bs).Get("depositList").Array() {
+		status := models.TransferPending
+		if v.Get("status").Int() == 1 {
+			status = models.TransferCompleted
+		}
+		transfers = append(transfers, &models.Transfer{
+			ID:        v.Get("txId").Str,
+			Currency:  v.Get("asset").Str,
+			Amount:    helpers.ToDecimal(v.Get("amount")),
+			Address:   v.Get("address").Str,
+			Tag:       v.Get("addressTag").Str,
+			TxID:      v.Get("txId").Str,
+			Network:   v.Get("network").Str,
+			Status:    status,
+			CreatedAt: millisToTime(v.Get("insertTime").Int()),
+		})
+	}
+	return trimTransfers(transfers, since), nil
+}
+
+var binanceWithdrawStatus = map[int64]models.TransferStatus{
+	0: models.TransferPending,
+	1: models.TransferCanceled,
+	2: models.TransferPending,
+	3: models.TransferFailed,
+	4: models.TransferPending,
+	5: models.TransferFailed,
+	6: models.TransferCompleted,
+}
+
+func (h *BinanceApi) Withdrawals(since time.Time) ([]*models.Transfer, error) {
+	params := &url.Values{}
+	if !since.IsZero() {
+		params.Set("startTime", strconv.FormatInt(since.UnixNano()/int64(time.Millisecond), 10))
+	}
+	bs, err := h.privateApi("GET", "/wapi/v3/withdrawHistory.html", params)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get withdrawals")
+	}
+	var transfers []*models.Transfer
+	for _, v := range gjson.ParseBytes(bs).Get("withdrawList").Array() {
+		transfers = append(transfers, &models.Transfer{
+			ID:        v.Get("id").Str,
+			Currency:  v.Get("asset").Str,
+			Amount:    helpers.ToDecimal(v.Get("amount")),
+			Fee:       helpers.ToDecimal(v.Get("transactionFee")),
+			Address:   v.Get("address").Str,
+			Tag:       v.Get("addressTag").Str,
+			TxID:      v.Get("txId").Str,
+			Network:   v.Get("network").Str,
+			Status:    binanceWithdrawStatus[v.Get("status").Int()],
+			CreatedAt: millisToTime(v.Get("applyTime").Int()),
+		})
+	}
+	return trimTransfers(transfers, since), nil
+}
+
 func (h *BinanceApi) Address(c string) (string, error) {
 	params := &url.Values{}
 	params.Set("asset", c)
@@ -0,0 +1,183 @@
+package private
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/fxpgr/go-exchange-client/helpers"
+	"github.com/fxpgr/go-exchange-client/logger"
+	"github.com/fxpgr/go-exchange-client/models"
+	"github.com/fxpgr/go-exchange-client/stream"
+	"github.com/pkg/errors"
+	"github.com/tidwall/gjson"
+)
+
+const (
+	BINANCE_USER_STREAM_URL = "wss://stream.binance.com:9443/ws/"
+)
+
+// BinanceUserStream follows the user data stream of Binance. The stream is
+// addressed by a listen key, which is created before every dial, kept alive
+// every KeepaliveInterval and created again once it expires. Keys left
+// behind expire by themselves.
+type BinanceUserStream struct {
+	userEvents
+	Api               *BinanceApi
+	Conn              *stream.Conn
+	URL               string
+	KeepaliveInterval time.Duration
+
+	listenKey string
+	keyM      sync.Mutex
+}
+
+func NewBinanceUserStream(api *BinanceApi) *BinanceUserStream {
+	s := &BinanceUserStream{
+		Api:               api,
+		URL:               BINANCE_USER_STREAM_URL,
+		KeepaliveInterval: 30 * time.Minute,
+	}
+	s.Conn = stream.NewConn("", s.handle)
+	s.Conn.Prepare = s.prepare
+	s.run = s.runStream
+	return s
+}
+
+func (s *BinanceUserStream) runStream(ctx context.Context) {
+	go s.keepalive(ctx)
+	s.Conn.Run(ctx)
+}
+
+func (s *BinanceUserStream) prepare(c *stream.Conn) error {
+	listenKey, err := s.Api.userDataStream("POST", "")
+	if err != nil {
+		return err
+	}
+	s.keyM.Lock()
+	s.listenKey = listenKey
+	s.keyM.Unlock()
+	c.URL = s.URL + listenKey
+	return nil
+}
+
+// keepalive extends the listen key, reconnecting with a new one when it
+// cannot be extended.
+func (s *BinanceUserStream) keepalive(ctx context.Context) {
+	ticker := time.NewTicker(s.KeepaliveInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		s.keyM.Lock()
+		listenKey := s.listenKey
+		s.keyM.Unlock()
+		if listenKey == "" {
+			continue
+		}
+		if _, err := s.Api.userDataStream("PUT", listenKey); err != nil {
+			logger.Get().Warnw("failed to keep binance listen key alive", "error", err)
+			s.Conn.Reconnect()
+		}
+	}
+}
+
+// userDataStream creates or extends a listen key. The endpoint takes the API
+// key but no signature.
+func (h *BinanceApi) userDataStream(method string, listenKey string) (string, error) {
+	path := "/api/v3/userDataStream"
+	urlStr := h.BaseURL + path
+	if listenKey != "" {
+		urlStr += "?" + url.Values{"listenKey": {listenKey}}.Encode()
+	}
+	req, err := http.NewRequestWithContext(h.context(), method, urlStr, nil)
+	if err != nil {
+		return "", errors.Wrapf(err, "failed to create request command %s", path)
+	}
+	apiKey, err := h.ApiKeyFunc()
+	if err != nil {
+		return "", errors.Wrapf(err, "failed to create request command %s", path)
+	}
+	req.Header.Set("X-MBX-APIKEY", apiKey)
+	res, err := h.HttpClient.Do(req)
+	if err != nil {
+		return "", errors.Wrapf(err, "failed to request command %s", path)
+	}
+	defer res.Body.Close()
+	resBody, err := ioutil.ReadAll(res.Body)
+	if err != nil {
+		return "", errors.Wrapf(err, "failed to fetch result of command %s", path)
+	}
+	if err := binanceError(res.StatusCode, resBody); err != nil {
+		return "", errors.Wrapf(err, "failed to request command %s", path)
+	}
+	return gjson.GetBytes(resBody, "listenKey").Str, nil
+}
+
+func (s *BinanceUserStream) handle(msg []byte) error {
+	value := gjson.ParseBytes(msg)
+	switch value.Get("e").Str {
+	case "listenKeyExpired":
+		return errors.New("binance listen key expired")
+	case "executionReport":
+		s.onExecution(value)
+	case "outboundAccountPosition", "outboundAccountInfo":
+		t := millisToTime(value.Get("E").Int())
+		for _, v := range value.Get("B").Array() {
+			s.publishBalance(binanceSymbols.Currency(v.Get("a").Str),
+				models.NewBalance(helpers.ToDecimal(v.Get("f")), helpers.ToDecimal(v.Get("l"))), t)
+		}
+	}
+	return nil
+}
+
+func (s *BinanceUserStream) onExecution(v gjson.Result) {
+	pair, ok := pairOf(binanceSymbols, v.Get("s").Str, s.Api.settlements)
+	if !ok {
+		logger.Get().Warnw("unknown binance symbol", "symbol", v.Get("s").Str)
+		return
+	}
+	// orders are known by their client order id, which a cancel replaces
+	id := v.Get("c").Str
+	if orig := v.Get("C").Str; orig != "" {
+		id = orig
+	}
+	order := &models.Order{
+		ExchangeOrderID: id,
+		Type:            models.Ask,
+		Trading:         pair.Trading,
+		Settlement:      pair.Settlement,
+		Price:           helpers.ToDecimal(v.Get("p")),
+		Amount:          helpers.ToDecimal(v.Get("q")),
+		FilledAmount:    helpers.ToDecimal(v.Get("z")),
+		CreatedAt:       millisToTime(v.Get("O").Int()),
+		UpdatedAt:       millisToTime(v.Get("T").Int()),
+	}
+	order.Status = binanceStatus(v.Get("X").Str, order.FilledAmount)
+	if v.Get("S").Str == "SELL" {
+		order.Type = models.Bid
+	}
+	order.AveragePrice = averagePrice(helpers.ToDecimal(v.Get("Z")), order.FilledAmount)
+	s.publishOrder(order)
+	if v.Get("x").Str != "TRADE" {
+		return
+	}
+	s.publishFill(&models.Trade{
+		ID:          v.Get("t").String(),
+		OrderID:     id,
+		Trading:     pair.Trading,
+		Settlement:  pair.Settlement,
+		Type:        order.Type,
+		Price:       helpers.ToDecimal(v.Get("L")),
+		Amount:      helpers.ToDecimal(v.Get("l")),
+		Fee:         helpers.ToDecimal(v.Get("n")),
+		FeeCurrency: binanceSymbols.Currency(v.Get("N").Str),
+		Time:        order.UpdatedAt,
+	})
+}
@@ -2,6 +2,7 @@ package private
 
 import (
 	"bytes"
+	"context"
 	"crypto/hmac"
 	"crypto/sha256"
 	"encoding/hex"
@@ -15,14 +16,21 @@ import (
 
 	"github.com/Jeffail/gabs"
 	"github.com/antonholmquist/jason"
+	"github.com/fxpgr/go-exchange-client/apierrors"
+	"github.com/fxpgr/go-exchange-client/decimal"
+	"github.com/fxpgr/go-exchange-client/helpers"
 	"github.com/fxpgr/go-exchange-client/models"
+	"github.com/fxpgr/go-exchange-client/symbols"
 	"github.com/pkg/errors"
+	"github.com/tidwall/gjson"
 )
 
 const (
 	BITFLYER_BASE_URL = "https://api.bitflyer.jp"
 )
 
+var bitflyerSymbols = symbols.For("bitflyer")
+
 type BitflyerApiConfig struct {
 }
 
@@ -32,7 +40,8 @@ type BitflyerApi struct {
 	BaseURL           string
 	RateCacheDuration time.Duration
 	HttpClient        http.Client
-	Mode              ClientMode
+	clientContext
+	Mode ClientMode
 
 	volumeMap       map[string]map[string]float64
 	rateMap         map[string]map[string]float64
@@ -43,6 +52,7 @@ type BitflyerApi struct {
 
 func NewBitflyerPrivateApi(apikey func() (string, error), apisecret func() (string, error)) (*BitflyerApi, error) {
 	api := &BitflyerApi{
+		HttpClient:        http.Client{Transport: newTransport("bitflyer", nil)},
 		ApikeyFunc:        apikey,
 		ApiSecretFunc:     apisecret,
 		BaseURL:           BITFLYER_BASE_URL,
@@ -60,6 +70,12 @@ func (b *BitflyerApi) privateApiUrl() string {
 	return b.BaseURL
 }
 
+func (b *BitflyerApi) WithContext(ctx context.Context) PrivateClient {
+	c := *b
+	c.ctx = ctx
+	return &c
+}
+
 func (b *BitflyerApi) privateApi(method string, path string, args map[string]string) ([]byte, error) {
 	var err error
 
@@ -77,7 +93,7 @@ func (b *BitflyerApi) privateApi(method string, path string, args map[string]str
 	text := nonce + method + path + string(jsonString)
 
 	reader := bytes.NewReader([]byte(val.Encode()))
-	req, err := http.NewRequest(method, b.privateApiUrl()+path, reader)
+	req, err := http.NewRequestWithContext(b.context(), method, b.privateApiUrl()+path, reader)
 	if err != nil {
 		return nil, errors.Wrapf(err, "failed to create request command %s", path)
 	}
@@ -112,9 +128,35 @@ func (b *BitflyerApi) privateApi(method string, path string, args map[string]str
 	if err != nil {
 		return []byte{}, errors.Wrapf(err, "failed to fetch %s", path)
 	}
+	if err := bitflyerError(resp.StatusCode, byteArray); err != nil {
+		return nil, errors.Wrapf(err, "failed to request command %s", path)
+	}
 	return byteArray, nil
 }
 
+// bitflyerErrorMessages lists funds before product_code, which messages about
+// an order name.
+var bitflyerErrorMessages = []messagePattern{
+	{"invalid signature", apierrors.ErrAuth},
+	{"key not found", apierrors.ErrAuth},
+	{"permission denied", apierrors.ErrAuth},
+	{"maintenance", apierrors.ErrMaintenance},
+	{"insufficient", apierrors.ErrInsufficientFunds},
+	{"product_code", apierrors.ErrInvalidSymbol},
+}
+
+// bitflyerError maps the {"status":-200,"error_message":...} payload; the
+// numeric status is not documented, so the message is matched instead.
+func bitflyerError(statusCode int, body []byte) error {
+	value := gjson.ParseBytes(body)
+	status := value.Get("status")
+	if statusCode == http.StatusOK && (!status.Exists() || status.Int() >= 0) {
+		return nil
+	}
+	message := value.Get("error_message").String()
+	return apiError(statusCode, status.String(), message, messageKind(message, bitflyerErrorMessages))
+}
+
 func (b *BitflyerApi) PurchaseFeeRate() (float64, error) {
 	purchaseFeeurl := "/v1/me/gettradingcommission?product_code=BTC_JPY"
 	method := "GET"
@@ -146,7 +188,7 @@ func (b *BitflyerApi) TradeFeeRates() (map[string]map[string]TradeFee, error) {
 		return nil, err
 	}
 	purchaseFeeMap := purchaseFeeObject.Map()
-	purchaseFee, err := purchaseFeeMap["commission_rate"].Float64()
+	purchaseFee, err := purchaseFeeMap["commission_rate"].Number()
 	if err != nil {
 		return nil, err
 	}
@@ -155,7 +197,7 @@ func (b *BitflyerApi) TradeFeeRates() (map[string]map[string]TradeFee, error) {
 	for trading, v := range b.rateMap {
 		m := make(map[string]TradeFee)
 		for settlement, _ := range v {
-			m[settlement] = TradeFee{TakerFee: purchaseFee, MakerFee: purchaseFee}
+			m[settlement] = TradeFee{TakerFee: helpers.ToDecimal(purchaseFee), MakerFee: helpers.ToDecimal(purchaseFee)}
 		}
 		traderFeeMap[trading] = m
 	}
@@ -210,7 +252,7 @@ func (b *BitflyerApi) Balances() (map[string]float64, error) {
 		if err != nil {
 			return nil, err
 		}
-		balancemap[cur] = avi
+		balancemap[bitflyerSymbols.Currency(cur)] = avi
 	}
 	return balancemap, nil
 }
@@ -246,16 +288,17 @@ func (b *BitflyerApi) CompleteBalances() (map[string]*models.Balance, error) {
 		if err != nil {
 			return nil, err
 		}
-		avi, err := balancetmpmap["available"].Float64()
+		avi, err := balancetmpmap["available"].Number()
 		if err != nil {
 			return nil, err
 		}
-		amount, err := balancetmpmap["amount"].Float64()
+		amount, err := balancetmpmap["amount"].Number()
 		if err != nil {
 			return nil, err
 		}
-		completeBalance := models.NewBalance(avi, amount-avi)
-		completebalancemap[cur] = completeBalance
+		available := helpers.ToDecimal(avi)
+		completeBalance := models.NewBalance(available, helpers.ToDecimal(amount).Sub(available))
+		completebalancemap[bitflyerSymbols.Currency(cur)] = completeBalance
 	}
 	return completebalancemap, nil
 }
@@ -290,16 +333,17 @@ func (b *BitflyerApi) CompleteBalance(coin string) (*models.Balance, error) {
 		if err != nil {
 			return nil, err
 		}
-		avi, err := balancetmpmap["available"].Float64()
+		avi, err := balancetmpmap["available"].Number()
 		if err != nil {
 			return nil, err
 		}
-		amount, err := balancetmpmap["amount"].Float64()
+		amount, err := balancetmpmap["amount"].Number()
 		if err != nil {
 			return nil, err
 		}
-		completeBalance := models.NewBalance(avi, amount-avi)
-		completebalancemap[cur] = completeBalance
+		available := helpers.ToDecimal(avi)
+		completeBalance := models.NewBalance(available, helpers.ToDecimal(amount).Sub(available))
+		completebalancemap[bitflyerSymbols.Currency(cur)] = completeBalance
 	}
 	return completebalancemap[coin], nil
 }
@@ -355,10 +399,11 @@ func (b *BitflyerApi) ActiveOrders() ([]*models.Order, error) {
 		if !ok {
 			continue
 		}
-		trading, settlement, err := parseCurrencyPair(productCodeStr)
-		if err != nil {
-			return nil, errors.Wrapf(err, "failed to parse currency pair")
+		pair, ok := bitflyerSymbols.Pair(productCodeStr)
+		if !ok {
+			return nil, errors.Errorf("failed to parse currency pair %s", productCodeStr)
 		}
+		trading, settlement := pair.Trading, pair.Settlement
 		amount, ok := v.Path("size").Data().(float64)
 		if !ok {
 			continue
@@ -372,37 +417,147 @@ func (b *BitflyerApi) ActiveOrders() ([]*models.Order, error) {
 			Type:            orderType,
 			Trading:         trading,
 			Settlement:      settlement,
-			Price:           price,
-			Amount:          amount,
+			Price:           decimal.NewFromFloat(price),
+			Amount:          decimal.NewFromFloat(amount),
 		})
 
 	}
 	return activeOrders, nil
 }
 
+func (b *BitflyerApi) OrderStatus(trading string, settlement string, orderNumber string) (*models.Order, error) {
+	path := "/v1/me/getchildorders?product_code=" + bitflyerSymbols.Symbol(trading, settlement) + "&child_order_acceptance_id=" + orderNumber
+	bs, err := b.privateApi("GET", path, nil)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to get order %s", orderNumber)
+	}
+	orders := gjson.ParseBytes(bs).Array()
+	if len(orders) == 0 {
+		return nil, errors.Wrapf(apierrors.ErrOrderNotFound, "order %s", orderNumber)
+	}
+	v := orders[0]
+	order := &models.Order{
+		ExchangeOrderID: orderNumber,
+		Type:            models.Ask,
+		Trading:         trading,
+		Settlement:      settlement,
+		Price:           helpers.ToDecimal(v.Get("price")),
+		Amount:          helpers.ToDecimal(v.Get("size")),
+		FilledAmount:    helpers.ToDecimal(v.Get("executed_size")),
+		AveragePrice:    helpers.ToDecimal(v.Get("average_price")),
+		Fee:             helpers.ToDecimal(v.Get("total_commission")),
+		FeeCurrency:     trading,
+	}
+	if v.Get("side").Str == "SELL" {
+		order.Type = models.Bid
+	}
+	switch v.Get("child_order_state").Str {
+	case "ACTIVE":
+		order.Status = models.OrderOpen
+		if order.FilledAmount.IsPositive() {
+			order.Status = models.OrderPartiallyFilled
+		}
+	case "COMPLETED":
+		order.Status = models.OrderFilled
+		if v.Get("cancel_size").Float() > 0 {
+			order.Status = models.OrderCanceled
+		}
+	case "CANCELED":
+		order.Status = models.OrderCanceled
+	case "EXPIRED":
+		order.Status = models.OrderExpired
+	case "REJECTED":
+		order.Status = models.OrderRejected
+	}
+	order.CreatedAt = bitflyerTime(v.Get("child_order_date").Str)
+	return order, nil
+}
+
+// bitflyerTime parses the UTC timestamps which bitflyer sends without a zone
+// designator.
+func bitflyerTime(s string) time.Time {
+	t, _ := time.Parse("2006-01-02T15:04:05.999999999", s)
+	return t
+}
+
+const bitflyerPageSize = 100
+
+// MyTrades pages backwards by execution id, as bitflyer returns the newest
+// executions first and cannot filter them by time.
+func (b *BitflyerApi) MyTrades(trading string, settlement string, since time.Time, limit int) ([]*models.Trade, error) {
+	var trades []*models.Trade
+	before := ""
+	for {
+		path := "/v1/me/getexecutions?product_code=" + bitflyerSymbols.Symbol(trading, settlement) + "&count=" + strconv.Itoa(bitflyerPageSize)
+		if before != "" {
+			path += "&before=" + before
+		}
+		bs, err := b.privateApi("GET", path, nil)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to get trades of %s/%s", trading, settlement)
+		}
+		page := gjson.ParseBytes(bs).Array()
+		for _, v := range page {
+			trade := &models.Trade{
+				ID:          v.Get("id").String(),
+				OrderID:     v.Get("child_order_acceptance_id").Str,
+				Trading:     trading,
+				Settlement:  settlement,
+				Type:        models.Ask,
+				Price:       helpers.ToDecimal(v.Get("price")),
+				Amount:      helpers.ToDecimal(v.Get("size")),
+				Fee:         helpers.ToDecimal(v.Get("commission")),
+				FeeCurrency: trading,
+				Time:        bitflyerTime(v.Get("exec_date").Str),
+			}
+			if v.Get("side").Str == "SELL" {
+				trade.Type = models.Bid
+			}
+			trades = append(trades, trade)
+		}
+		if len(page) < bitflyerPageSize || trades[len(trades)-1].Time.Before(since) {
+			break
+		}
+		before = page[len(page)-1].Get("id").String()
+	}
+	return trimTrades(trades, since, limit), nil
+}
+
 type orderBitflyerRespnose struct {
 	OrderNumber string `json:"child_order_acceptance_id"`
 }
 
 func (b *BitflyerApi) Order(trading string, settlement string, ordertype models.OrderType, price float64, amount float64) (string, error) {
+	return b.PlaceOrder(models.OrderRequest{Trading: trading, Settlement: settlement, Type: ordertype, Price: decimal.NewFromFloat(price), Amount: decimal.NewFromFloat(amount)})
+}
+
+func (b *BitflyerApi) PlaceOrder(req models.OrderRequest) (string, error) {
 	orderpath := "/v1/me/sendchildorder"
 	method := "POST"
 
 	param := make(map[string]string)
-	param["product_code"] = trading + "_" + settlement
-	param["child_order_type"] = "LIMIT"
+	param["product_code"] = bitflyerSymbols.Symbol(req.Trading, req.Settlement)
+	switch {
+	case req.ExecutionType == models.Market && req.TimeInForce == models.GTC:
+		param["child_order_type"] = "MARKET"
+	case req.ExecutionType == models.Limit && req.TimeInForce != models.PostOnly:
+		param["child_order_type"] = "LIMIT"
+		param["time_in_force"] = req.TimeInForce.String()
+		param["price"] = FloorDecimalToStr(req.Price, 8)
+	default:
+		return "", unsupportedOrder("bitflyer", req)
+	}
 
 	var cmd string
-	if ordertype == models.Ask {
+	if req.Type == models.Ask {
 		cmd = "BUY"
-	} else if ordertype == models.Bid {
+	} else if req.Type == models.Bid {
 		cmd = "SELL"
 	} else {
-		return "", errors.Errorf("unknown order type %d", ordertype)
+		return "", errors.Errorf("unknown order type %d", req.Type)
 	}
 	param["side"] = cmd
-	param["price"] = strconv.FormatFloat(price, 'f', 8, 64)
-	param["size"] = strconv.FormatFloat(amount, 'f', 8, 64)
+	param["size"] = FloorDecimalToStr(req.Amount, 8)
 
 	bs, err := b.privateApi(method, orderpath, param)
 	if err != nil {
@@ -435,6 +590,53 @@ func (b *BitflyerApi) CancelOrder(trading string, settlement string,
 	return nil
 }
 
+func (b *BitflyerApi) Deposits(since time.Time) ([]*models.Transfer, error) {
+	return b.coinTransfers("/v1/me/getcoinins", since)
+}
+
+func (b *BitflyerApi) Withdrawals(since time.Time) ([]*models.Transfer, error) {
+	return b.coinTransfers("/v1/me/getcoinouts", since)
+}
+
+// coinTransfers pages backwards by id, as bitflyer returns the newest
+// transfers first and cannot filter them by time.
+func (b *BitflyerApi) coinTransfers(path string, since time.Time) ([]*models.Transfer, error) {
+	var transfers []*models.Transfer
+	before := ""
+	for {
+		query := path + "?count=" + strconv.Itoa(bitflyerPageSize)
+		if before != "" {
+			query += "&before=" + before
+		}
+		bs, err := b.privateApi("GET", query, nil)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to get transfers %s", path)
+		}
+		page := gjson.ParseBytes(bs).Array()
+		for _, v := range page {
+			status := models.TransferPending
+			if v.Get("status").Str == "COMPLETED" {
+				status = models.TransferCompleted
+			}
+			transfers = append(transfers, &models.Transfer{
+				ID:        v.Get("order_id").Str,
+				Currency:  bitflyerSymbols.Currency(v.Get("currency_code").Str),
+				Amount:    helpers.ToDecimal(v.Get("amount")),
+				Fee:       helpers.ToDecimal(v.Get("fee")).Add(helpers.ToDecimal(v.Get("additional_fee"))),
+				Address:   v.Get("address").Str,
+				TxID:      v.Get("tx_hash").Str,
+				Status:    status,
+				CreatedAt: bitflyerTime(v.Get("event_date").Str),
+			})
+		}
+		if len(page) < bitflyerPageSize || transfers[len(transfers)-1].CreatedAt.Before(since) {
+			break
+		}
+		before = page[len(page)-1].Get("id").String()
+	}
+	return trimTransfers(transfers, since), nil
+}
+
 func (b *BitflyerApi) Address(c string) (string, error) {
 	return "", errors.New("bitflyer address api not implemented")
 }
@@ -1,15 +1,19 @@
 package private
 
 import (
+	"context"
+	"github.com/fxpgr/go-exchange-client/api/public"
+	"github.com/fxpgr/go-exchange-client/decimal"
 	"github.com/fxpgr/go-exchange-client/models"
 	"github.com/pkg/errors"
 	"github.com/stretchr/testify/mock"
 	"strings"
+	"time"
 )
 
 type TradeFee struct {
-	MakerFee float64
-	TakerFee float64
+	MakerFee decimal.Decimal
+	TakerFee decimal.Decimal
 }
 
 //go:generate mockery -name=PrivateClient -output=. -inpkg
@@ -22,34 +26,65 @@ type PrivateClient interface {
 	CompleteBalance(coin string) (*models.Balance, error)
 	ActiveOrders() ([]*models.Order, error)
 	IsOrderFilled(trading string, settlement string, orderNumber string) (bool, error)
+	// OrderStatus returns the order with its status, fills, fees and
+	// timestamps, failing with apierrors.ErrOrderNotFound when the exchange
+	// does not know it.
+	OrderStatus(trading string, settlement string, orderNumber string) (*models.Order, error)
+	// MyTrades returns our fills since the given time, oldest first, paging
+	// through the exchange until limit trades are found. A limit of zero or
+	// less returns every trade.
+	MyTrades(trading string, settlement string, since time.Time, limit int) ([]*models.Trade, error)
 	Order(trading string, settlement string,
 		ordertype models.OrderType, price float64, amount float64) (string, error)
+	// PlaceOrder places an order of any execution type and time in force,
+	// failing with apierrors.ErrUnsupported when the exchange cannot.
+	PlaceOrder(req models.OrderRequest) (string, error)
 	CancelOrder(trading string, settlement string,
 		ordertype models.OrderType, orderNumber string) error
 	//FilledOrderInfo(orderNumber string) (models.FilledOrderInfo,error)
 	Transfer(typ string, addr string,
 		amount float64, additionalFee float64) error
 	Address(c string) (string, error)
+	// Deposits and Withdrawals return the transfers created since the given
+	// time, oldest first.
+	Deposits(since time.Time) ([]*models.Transfer, error)
+	Withdrawals(since time.Time) ([]*models.Transfer, error)
+	// WithContext returns a shallow copy of the client whose requests are
+	// bound to ctx, so they are aborted once ctx is cancelled or expires.
+	WithContext(ctx context.Context) PrivateClient
 }
 
 func NewClient(mode ClientMode, exchangeName string, apikey func() (string, error), seckey func() (string, error)) (PrivateClient, error) {
 	if mode == TEST {
 		m := new(MockPrivateClient)
 		retCompleteBalance := make(map[string]*models.Balance)
-		retCompleteBalance["BTC"] = &models.Balance{Available: 10000, OnOrders: 0}
+		retCompleteBalance["BTC"] = &models.Balance{Available: decimal.NewFromInt(10000), OnOrders: decimal.Zero}
 		retActiveOrders := make([]*models.Order, 0)
-		retTradeFeeRate := TradeFee{MakerFee: 0.002, TakerFee: 0.002}
+		retTradeFeeRate := TradeFee{MakerFee: decimal.New(2, -3), TakerFee: decimal.New(2, -3)}
 		m.On("CompleteBalances").Return(retCompleteBalance, nil)
 		m.On("CompleteBalance").Return(retCompleteBalance["BTC"], nil)
 		m.On("ActiveOrders").Return(retActiveOrders, nil)
 		m.On("IsOrderFilled", mock.Anything, mock.Anything).Return(true, nil)
+		m.On("OrderStatus", mock.Anything, mock.Anything, mock.Anything).Return(&models.Order{ExchangeOrderID: "12345", Status: models.OrderFilled}, nil)
+		m.On("MyTrades", mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(make([]*models.Trade, 0), nil)
 		m.On("Order", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return("12345", nil)
+		m.On("PlaceOrder", mock.Anything).Return("12345", nil)
 		m.On("CancelOrder", mock.Anything, mock.Anything).Return(nil)
 		m.On("TradeFeeRate", mock.Anything, mock.Anything).Return(retTradeFeeRate, nil)
 		m.On("Address", mock.Anything).Return("", nil)
+		m.On("Deposits", mock.Anything).Return(make([]*models.Transfer, 0), nil)
+		m.On("Withdrawals", mock.Anything).Return(make([]*models.Transfer, 0), nil)
 		m.On("Transfer", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(nil)
+		m.On("WithContext", mock.Anything).Return(m)
 		return m, nil
 	}
+	if mode == PAPER {
+		pub, err := public.NewClient(exchangeName)
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to initialize public client")
+		}
+		return NewPaperApi(pub, nil, TradeFee{MakerFee: decimal.New(2, -3), TakerFee: decimal.New(2, -3)}), nil
+	}
 	switch strings.ToLower(exchangeName) {
 	case "bitflyer":
 		return NewBitflyerPrivateApi(apikey, seckey)
@@ -2,18 +2,24 @@ package private
 
 import (
 	"bytes"
+	"context"
 	"io/ioutil"
 	"net/http"
 	"net/url"
 	"sync"
 	"time"
 
-	"fmt"
 	"github.com/Jeffail/gabs"
 	"github.com/antonholmquist/jason"
 	"github.com/fxpgr/go-exchange-client/api/public"
+	"github.com/fxpgr/go-exchange-client/apierrors"
+	"github.com/fxpgr/go-exchange-client/decimal"
+	"github.com/fxpgr/go-exchange-client/helpers"
 	"github.com/fxpgr/go-exchange-client/models"
+	"github.com/fxpgr/go-exchange-client/retry"
+	"github.com/fxpgr/go-exchange-client/symbols"
 	"github.com/pkg/errors"
+	"github.com/tidwall/gjson"
 	"strconv"
 	"strings"
 )
@@ -22,6 +28,8 @@ const (
 	HITBTC_BASE_URL = "https://api.hitbtc.com"
 )
 
+var hitbtcSymbols = symbols.For("hitbtc")
+
 func NewHitbtcApi(apikey func() (string, error), apisecret func() (string, error)) (*HitbtcApi, error) {
 	hitbtcPublic, err := public.NewHitbtcPublicApi()
 	if err != nil {
@@ -45,6 +53,7 @@ func NewHitbtcApi(apikey func() (string, error), apisecret func() (string, error
 	}
 
 	return &HitbtcApi{
+		HttpClient:        http.Client{Transport: newTransport("hitbtc", nil)},
 		BaseURL:           HITBTC_BASE_URL,
 		RateCacheDuration: 30 * time.Second,
 		ApiKeyFunc:        apikey,
@@ -64,7 +73,8 @@ type HitbtcApi struct {
 	BaseURL           string
 	RateCacheDuration time.Duration
 	HttpClient        http.Client
-	settlements       []string
+	clientContext
+	settlements []string
 
 	volumeMap       map[string]map[string]float64
 	rateMap         map[string]map[string]float64
@@ -81,6 +91,12 @@ func (h *HitbtcApi) privateApiUrl() string {
 	return h.BaseURL
 }
 
+func (h *HitbtcApi) WithContext(ctx context.Context) PrivateClient {
+	c := *h
+	c.ctx = ctx
+	return &c
+}
+
 func (h *HitbtcApi) privateApi(method string, path string, args map[string]string) ([]byte, error) {
 	val := url.Values{}
 	if args != nil {
@@ -88,8 +104,12 @@ func (h *HitbtcApi) privateApi(method string, path string, args map[string]strin
 			val.Add(k, v)
 		}
 	}
+	ctx := h.context()
+	if args["clientOrderId"] != "" {
+		ctx = retry.Idempotent(ctx)
+	}
 	reader := bytes.NewReader([]byte(val.Encode()))
-	req, err := http.NewRequest(method, h.privateApiUrl()+path, reader)
+	req, err := http.NewRequestWithContext(ctx, method, h.privateApiUrl()+path, reader)
 	if err != nil {
 		return nil, errors.Wrapf(err, "failed to create request command on newRequest %s", path)
 	}
@@ -114,9 +134,34 @@ func (h *HitbtcApi) privateApi(method string, path string, args map[string]strin
 	if err != nil {
 		return nil, errors.Wrapf(err, "failed to fetch result of command %s", path)
 	}
+	if err := hitbtcError(res.StatusCode, resBody); err != nil {
+		return nil, errors.Wrapf(err, "failed to request command %s", path)
+	}
 	return resBody, nil
 }
 
+var hitbtcErrorCodes = map[string]error{
+	"429":   apierrors.ErrRateLimited,
+	"503":   apierrors.ErrMaintenance,
+	"1001":  apierrors.ErrAuth,
+	"1002":  apierrors.ErrAuth,
+	"1003":  apierrors.ErrAuth,
+	"1004":  apierrors.ErrAuth,
+	"2001":  apierrors.ErrInvalidSymbol,
+	"2002":  apierrors.ErrInvalidSymbol,
+	"20001": apierrors.ErrInsufficientFunds,
+	"20002": apierrors.ErrOrderNotFound,
+}
+
+func hitbtcError(statusCode int, body []byte) error {
+	value := gjson.ParseBytes(body).Get("error")
+	if statusCode == http.StatusOK && !value.Exists() {
+		return nil
+	}
+	code := value.Get("code").String()
+	return apiError(statusCode, code, value.Get("message").String(), hitbtcErrorCodes[code])
+}
+
 func (h *HitbtcApi) TradeFeeRates() (map[string]map[string]TradeFee, error) {
 	purchaseFeeurl := "/api/2/public/symbol"
 	method := "GET"
@@ -135,7 +180,7 @@ func (h *HitbtcApi) TradeFeeRates() (map[string]map[string]TradeFee, error) {
 		if !ok {
 			continue
 		}
-		takeLiquidityRate, err := strconv.ParseFloat(takeLiquidityRateStr, 10)
+		takeLiquidityRate, err := decimal.NewFromString(takeLiquidityRateStr)
 		if err != nil {
 			return nil, errors.Wrapf(err, "failed to parse json")
 		}
@@ -143,7 +188,7 @@ func (h *HitbtcApi) TradeFeeRates() (map[string]map[string]TradeFee, error) {
 		if !ok {
 			continue
 		}
-		provideLiquidityRate, err := strconv.ParseFloat(provideLiquidityRateStr, 10)
+		provideLiquidityRate, err := decimal.NewFromString(provideLiquidityRateStr)
 		if err != nil {
 			return nil, errors.Wrapf(err, "failed to parse json")
 		}
@@ -155,12 +200,13 @@ func (h *HitbtcApi) TradeFeeRates() (map[string]map[string]TradeFee, error) {
 		if !ok {
 			continue
 		}
+		pair := hitbtcSymbols.AddPair(baseCurrency, quoteCurrency)
 		n := make(map[string]TradeFee)
-		n[quoteCurrency] = TradeFee{
+		n[pair.Settlement] = TradeFee{
 			TakerFee: takeLiquidityRate,
 			MakerFee: provideLiquidityRate,
 		}
-		traderFeeMap[baseCurrency] = n
+		traderFeeMap[pair.Trading] = n
 	}
 	return traderFeeMap, nil
 }
@@ -175,7 +221,7 @@ func (b *HitbtcApi) TradeFeeRate(trading string, settlement string) (TradeFee, e
 
 func (h *HitbtcApi) TransferFee() (map[string]float64, error) {
 	url := h.publicApiUrl("currency")
-	resp, err := h.HttpClient.Get(url)
+	resp, err := httpGet(h.context(), &h.HttpClient, url)
 	if err != nil {
 		return nil, errors.Wrapf(err, "failed to fetch %s", url)
 	}
@@ -207,7 +253,7 @@ func (h *HitbtcApi) TransferFee() (map[string]float64, error) {
 		if !ok {
 			continue
 		}
-		transferFeeMap[currency] = payoutFee
+		transferFeeMap[hitbtcSymbols.Currency(currency)] = payoutFee
 	}
 	return transferFeeMap, nil
 }
@@ -239,7 +285,7 @@ func (h *HitbtcApi) Balances() (map[string]float64, error) {
 		if err != nil {
 			return nil, err
 		}
-		m[currency] = available
+		m[hitbtcSymbols.Currency(currency)] = available
 	}
 	return m, nil
 }
@@ -265,7 +311,7 @@ func (h *HitbtcApi) CompleteBalances() (map[string]*models.Balance, error) {
 		if !ok {
 			continue
 		}
-		available, err := strconv.ParseFloat(availableStr, 10)
+		available, err := decimal.NewFromString(availableStr)
 		if err != nil {
 			return nil, err
 		}
@@ -273,7 +319,7 @@ func (h *HitbtcApi) CompleteBalances() (map[string]*models.Balance, error) {
 		if !ok {
 			continue
 		}
-		reserved, err := strconv.ParseFloat(reservedStr, 10)
+		reserved, err := decimal.NewFromString(reservedStr)
 		if err != nil {
 			return nil, err
 		}
@@ -282,7 +328,7 @@ func (h *HitbtcApi) CompleteBalances() (map[string]*models.Balance, error) {
 			continue
 		}
 		balance := models.NewBalance(available, reserved)
-		m[currency] = balance
+		m[hitbtcSymbols.Currency(currency)] = balance
 	}
 	return m, nil
 }
@@ -308,14 +354,14 @@ func (h *HitbtcApi) CompleteBalance(coin string) (*models.Balance, error) {
 		if !ok {
 			continue
 		}
-		if currency != coin {
+		if hitbtcSymbols.Currency(currency) != coin {
 			continue
 		}
 		availableStr, ok := v.Path("available").Data().(string)
 		if !ok {
 			continue
 		}
-		available, err := strconv.ParseFloat(availableStr, 10)
+		available, err := decimal.NewFromString(availableStr)
 		if err != nil {
 			return nil, err
 		}
@@ -323,12 +369,12 @@ func (h *HitbtcApi) CompleteBalance(coin string) (*models.Balance, error) {
 		if !ok {
 			continue
 		}
-		reserved, err := strconv.ParseFloat(reservedStr, 10)
+		reserved, err := decimal.NewFromString(reservedStr)
 		if err != nil {
 			return nil, err
 		}
 		balance := models.NewBalance(available, reserved)
-		m[currency] = balance
+		m[hitbtcSymbols.Currency(currency)] = balance
 	}
 	return m[coin], nil
 }
@@ -370,7 +416,7 @@ func (h *HitbtcApi) ActiveOrders() ([]*models.Order, error) {
 		if err != nil {
 			continue
 		}
-		quantity, err := strconv.ParseFloat(quantityStr, 10)
+		quantity, err := decimal.NewFromString(quantityStr)
 		if err != nil {
 			return nil, err
 		}
@@ -378,7 +424,7 @@ func (h *HitbtcApi) ActiveOrders() ([]*models.Order, error) {
 		if err != nil {
 			continue
 		}
-		price, err := strconv.ParseFloat(priceStr, 10)
+		price, err := decimal.NewFromString(priceStr)
 		if err != nil {
 			return nil, err
 		}
@@ -390,23 +436,24 @@ func (h *HitbtcApi) ActiveOrders() ([]*models.Order, error) {
 		if side == "buy" {
 			orderType = models.Ask
 		}
-		var settlement string
-		var trading string
-		for _, s := range h.settlements {
-			index := strings.LastIndex(symbol, s)
-			if index != 0 && index == len(symbol)-len(s) {
-				settlement = s
-				trading = symbol[0:index]
+		pair, ok := hitbtcSymbols.Pair(symbol)
+		if !ok {
+			for _, s := range h.settlements {
+				s = hitbtcSymbols.Native(s)
+				index := strings.LastIndex(symbol, s)
+				if index > 0 && index == len(symbol)-len(s) {
+					pair = hitbtcSymbols.AddPair(symbol[0:index], s)
+				}
 			}
 		}
-		if settlement == "" || trading == "" {
+		if pair.Settlement == "" || pair.Trading == "" {
 			continue
 		}
 		c := &models.Order{
 			ExchangeOrderID: orderId,
 			Type:            orderType,
-			Trading:         trading,
-			Settlement:      settlement,
+			Trading:         pair.Trading,
+			Settlement:      pair.Settlement,
 			Price:           price,
 			Amount:          quantity,
 		}
@@ -415,21 +462,137 @@ func (h *HitbtcApi) ActiveOrders() ([]*models.Order, error) {
 	return orders, nil
 }
 
+var hitbtcOrderStatus = map[string]models.OrderStatus{
+	"new":             models.OrderOpen,
+	"suspended":       models.OrderOpen,
+	"partiallyFilled": models.OrderPartiallyFilled,
+	"filled":          models.OrderFilled,
+	"canceled":        models.OrderCanceled,
+	"expired":         models.OrderExpired,
+}
+
+func (h *HitbtcApi) OrderStatus(trading string, settlement string, orderNumber string) (*models.Order, error) {
+	bs, err := h.privateApi("GET", "/api/2/history/order?clientOrderId="+orderNumber, nil)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to get order %s", orderNumber)
+	}
+	orders := gjson.ParseBytes(bs).Array()
+	if len(orders) == 0 {
+		return nil, errors.Wrapf(apierrors.ErrOrderNotFound, "order %s", orderNumber)
+	}
+	v := orders[0]
+	order := &models.Order{
+		ExchangeOrderID: orderNumber,
+		Type:            models.Ask,
+		Trading:         trading,
+		Settlement:      settlement,
+		Price:           helpers.ToDecimal(v.Get("price")),
+		Amount:          helpers.ToDecimal(v.Get("quantity")),
+		Status:          hitbtcOrderStatus[v.Get("status").Str],
+		FilledAmount:    helpers.ToDecimal(v.Get("cumQuantity")),
+		FeeCurrency:     settlement,
+	}
+	if v.Get("side").Str == "sell" {
+		order.Type = models.Bid
+	}
+	order.CreatedAt, _ = time.Parse(time.RFC3339Nano, v.Get("createdAt").Str)
+	order.UpdatedAt, _ = time.Parse(time.RFC3339Nano, v.Get("updatedAt").Str)
+	if order.FilledAmount.IsZero() {
+		return order, nil
+	}
+
+	bs, err = h.privateApi("GET", "/api/2/history/order/"+v.Get("id").String()+"/trades", nil)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to get trades of order %s", orderNumber)
+	}
+	total := decimal.Zero
+	for _, trade := range gjson.ParseBytes(bs).Array() {
+		total = total.Add(helpers.ToDecimal(trade.Get("price")).Mul(helpers.ToDecimal(trade.Get("quantity"))))
+		order.Fee = order.Fee.Add(helpers.ToDecimal(trade.Get("fee")))
+	}
+	order.AveragePrice = averagePrice(total, order.FilledAmount)
+	return order, nil
+}
+
+const hitbtcPageSize = 1000
+
+func (h *HitbtcApi) MyTrades(trading string, settlement string, since time.Time, limit int) ([]*models.Trade, error) {
+	var trades []*models.Trade
+	for offset := 0; ; offset += hitbtcPageSize {
+		query := url.Values{}
+		query.Set("symbol", hitbtcSymbols.Symbol(trading, settlement))
+		query.Set("sort", "ASC")
+		query.Set("limit", strconv.Itoa(hitbtcPageSize))
+		query.Set("offset", strconv.Itoa(offset))
+		if !since.IsZero() {
+			query.Set("from", since.UTC().Format(time.RFC3339Nano))
+		}
+		bs, err := h.privateApi("GET", "/api/2/history/trades?"+query.Encode(), nil)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to get trades of %s/%s", trading, settlement)
+		}
+		page := gjson.ParseBytes(bs).Array()
+		for _, v := range page {
+			trade := &models.Trade{
+				ID:          v.Get("id").String(),
+				OrderID:     v.Get("clientOrderId").String(),
+				Trading:     trading,
+				Settlement:  settlement,
+				Type:        models.Ask,
+				Price:       helpers.ToDecimal(v.Get("price")),
+				Amount:      helpers.ToDecimal(v.Get("quantity")),
+				Fee:         helpers.ToDecimal(v.Get("fee")),
+				FeeCurrency: settlement,
+			}
+			if v.Get("side").Str == "sell" {
+				trade.Type = models.Bid
+			}
+			trade.Time, _ = time.Parse(time.RFC3339Nano, v.Get("timestamp").Str)
+			trades = append(trades, trade)
+		}
+		if len(page) < hitbtcPageSize || (limit > 0 && len(trades) >= limit) {
+			break
+		}
+	}
+	return trimTrades(trades, since, limit), nil
+}
+
 func (h *HitbtcApi) Order(trading string, settlement string, ordertype models.OrderType, price float64, amount float64) (string, error) {
+	return h.PlaceOrder(models.OrderRequest{Trading: trading, Settlement: settlement, Type: ordertype, Price: decimal.NewFromFloat(price), Amount: decimal.NewFromFloat(amount)})
+}
+
+func (h *HitbtcApi) PlaceOrder(req models.OrderRequest) (string, error) {
 	var cmd string
-	if ordertype == models.Ask {
+	if req.Type == models.Ask {
 		cmd = "buy"
-	} else if ordertype == models.Bid {
+	} else if req.Type == models.Bid {
 		cmd = "sell"
 	} else {
-		return "", errors.Errorf("unknown order type %d", ordertype)
+		return "", errors.Errorf("unknown order type %d", req.Type)
 	}
-	pair := strings.ToUpper(fmt.Sprintf("%s%s", trading, settlement))
+	pair := hitbtcSymbols.Symbol(req.Trading, req.Settlement)
 	args := make(map[string]string)
 	args["side"] = cmd
 	args["symbol"] = pair
-	args["price"] = strconv.FormatFloat(price, 'g', -1, 64)
-	args["quantity"] = strconv.FormatFloat(amount, 'g', -1, 64)
+	switch {
+	case req.ExecutionType == models.Market && req.TimeInForce == models.GTC:
+		args["type"] = "market"
+	case req.ExecutionType == models.Limit && req.TimeInForce == models.PostOnly:
+		args["type"] = "limit"
+		args["postOnly"] = "true"
+	case req.ExecutionType == models.Limit:
+		args["type"] = "limit"
+		args["timeInForce"] = req.TimeInForce.String()
+	default:
+		return "", unsupportedOrder("hitbtc", req)
+	}
+	if req.ExecutionType == models.Limit {
+		args["price"] = req.Price.String()
+	}
+	args["quantity"] = req.Amount.String()
+	if id := retry.ClientOrderID(h.context()); id != "" {
+		args["clientOrderId"] = id
+	}
 	bs, err := h.privateApi("POST", "/api/2/order", args)
 	if err != nil {
 		return "", errors.Wrap(err, "failed to request order")
@@ -496,6 +659,64 @@ func (h *HitbtcApi) CancelOrder(trading string, settlement string,
 	return nil
 }
 
+func (h *HitbtcApi) Deposits(since time.Time) ([]*models.Transfer, error) {
+	return h.transactions(since, "payin", "deposit")
+}
+
+func (h *HitbtcApi) Withdrawals(since time.Time) ([]*models.Transfer, error) {
+	return h.transactions(since, "payout", "withdraw")
+}
+
+var hitbtcTransferStatus = map[string]models.TransferStatus{
+	"created": models.TransferPending,
+	"pending": models.TransferPending,
+	"failed":  models.TransferFailed,
+	"success": models.TransferCompleted,
+}
+
+// transactions pages through the account transactions and keeps those of the
+// given crypto and bank types, as hitbtc cannot filter them by type.
+func (h *HitbtcApi) transactions(since time.Time, deposit string, payment string) ([]*models.Transfer, error) {
+	var transfers []*models.Transfer
+	for offset := 0; ; offset += hitbtcPageSize {
+		query := url.Values{}
+		query.Set("sort", "ASC")
+		query.Set("limit", strconv.Itoa(hitbtcPageSize))
+		query.Set("offset", strconv.Itoa(offset))
+		if !since.IsZero() {
+			query.Set("from", since.UTC().Format(time.RFC3339Nano))
+		}
+		bs, err := h.privateApi("GET", "/api/2/account/transactions?"+query.Encode(), nil)
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to get transactions")
+		}
+		page := gjson.ParseBytes(bs).Array()
+		for _, v := range page {
+			typ := v.Get("type").Str
+			if typ != deposit && typ != payment {
+				continue
+			}
+			transfer := &models.Transfer{
+				ID:       v.Get("id").Str,
+				Currency: hitbtcSymbols.Currency(v.Get("currency").Str),
+				Amount:   helpers.ToDecimal(v.Get("amount")),
+				Fee:      helpers.ToDecimal(v.Get("fee")),
+				Address:  v.Get("address").Str,
+				Tag:      v.Get("paymentId").Str,
+				TxID:     v.Get("hash").Str,
+				Status:   hitbtcTransferStatus[v.Get("status").Str],
+			}
+			transfer.CreatedAt, _ = time.Parse(time.RFC3339Nano, v.Get("createdAt").Str)
+			transfer.UpdatedAt, _ = time.Parse(time.RFC3339Nano, v.Get("updatedAt").Str)
+			transfers = append(transfers, transfer)
+		}
+		if len(page) < hitbtcPageSize {
+			break
+		}
+	}
+	return trimTransfers(transfers, since), nil
+}
+
 func (h *HitbtcApi) Address(c string) (string, error) {
 	bs, err := h.privateApi("GET", "/api/2/account/crypto/address/"+c, nil)
 	if err != nil {
@@ -0,0 +1,160 @@
+package private
+
+import (
+	"time"
+
+	"github.com/fxpgr/go-exchange-client/helpers"
+	"github.com/fxpgr/go-exchange-client/logger"
+	"github.com/fxpgr/go-exchange-client/models"
+	"github.com/fxpgr/go-exchange-client/stream"
+	"github.com/pkg/errors"
+	"github.com/tidwall/gjson"
+)
+
+const (
+	HITBTC_USER_STREAM_URL = "wss://api.hitbtc.com/api/2/ws"
+)
+
+// HitbtcUserStream follows the order reports of HitBTC. HitBTC pushes no
+// balances, so the trading balances are fetched over the stream after login
+// and after every fill, and the currencies which changed are published.
+type HitbtcUserStream struct {
+	userEvents
+	Api  *HitbtcApi
+	Conn *stream.Conn
+
+	// the fields below are only used by the goroutine of Conn
+	nextID   int64
+	requests map[int64]string
+	balances map[string]models.Balance
+}
+
+func NewHitbtcUserStream(api *HitbtcApi) *HitbtcUserStream {
+	s := &HitbtcUserStream{Api: api, balances: make(map[string]models.Balance)}
+	s.Conn = stream.NewConn(HITBTC_USER_STREAM_URL, s.handle)
+	s.Conn.OnConnect = s.login
+	s.run = runConn(s.Conn)
+	return s
+}
+
+// request sends a request, remembering its method to handle the response.
+func (s *HitbtcUserStream) request(method string, params interface{}) error {
+	s.nextID++
+	s.requests[s.nextID] = method
+	err := s.Conn.WriteJSON(map[string]interface{}{"method": method, "params": params, "id": s.nextID})
+	if err != nil {
+		return errors.Wrapf(err, "failed to request %s", method)
+	}
+	return nil
+}
+
+func (s *HitbtcUserStream) login(*stream.Conn) error {
+	s.requests = make(map[int64]string)
+	apiKey, err := s.Api.ApiKeyFunc()
+	if err != nil {
+		return errors.Wrap(err, "failed to log in")
+	}
+	secretKey, err := s.Api.SecretKeyFunc()
+	if err != nil {
+		return errors.Wrap(err, "failed to log in")
+	}
+	return s.request("login", map[string]string{"algo": "BASIC", "pKey": apiKey, "sKey": secretKey})
+}
+
+func (s *HitbtcUserStream) handle(msg []byte) error {
+	value := gjson.ParseBytes(msg)
+	if id := value.Get("id"); id.Exists() {
+		method := s.requests[id.Int()]
+		delete(s.requests, id.Int())
+		if e := value.Get("error"); e.Exists() {
+			if method == "login" {
+				return authError(e.Get("code").String(), e.Get("message").Str)
+			}
+			logger.Get().Warnw("hitbtc user stream request failed", "method", method, "error", e.Get("message").Str)
+			return nil
+		}
+		switch method {
+		case "login":
+			if err := s.request("subscribeReports", map[string]string{}); err != nil {
+				return err
+			}
+			return s.request("getTradingBalance", map[string]string{})
+		case "getTradingBalance":
+			s.onBalances(value.Get("result").Array())
+		}
+		return nil
+	}
+	switch value.Get("method").Str {
+	case "activeOrders":
+		for _, v := range value.Get("params").Array() {
+			s.onReport(v)
+		}
+	case "report":
+		s.onReport(value.Get("params"))
+		if value.Get("params.reportType").Str == "trade" {
+			return s.request("getTradingBalance", map[string]string{})
+		}
+	}
+	return nil
+}
+
+func (s *HitbtcUserStream) onReport(v gjson.Result) {
+	pair, ok := pairOf(hitbtcSymbols, v.Get("symbol").Str, s.Api.settlements)
+	if !ok {
+		logger.Get().Warnw("unknown hitbtc symbol", "symbol", v.Get("symbol").Str)
+		return
+	}
+	order := &models.Order{
+		ExchangeOrderID: v.Get("clientOrderId").Str,
+		Type:            models.Ask,
+		Trading:         pair.Trading,
+		Settlement:      pair.Settlement,
+		Price:           helpers.ToDecimal(v.Get("price")),
+		Amount:          helpers.ToDecimal(v.Get("quantity")),
+		Status:          hitbtcOrderStatus[v.Get("status").Str],
+		FilledAmount:    helpers.ToDecimal(v.Get("cumQuantity")),
+		FeeCurrency:     pair.Settlement,
+	}
+	if v.Get("side").Str == "sell" {
+		order.Type = models.Bid
+	}
+	order.CreatedAt, _ = time.Parse(time.RFC3339Nano, v.Get("createdAt").Str)
+	order.UpdatedAt, _ = time.Parse(time.RFC3339Nano, v.Get("updatedAt").Str)
+	s.publishOrder(order)
+	if v.Get("reportType").Str != "trade" {
+		return
+	}
+	s.publishFill(&models.Trade{
+		ID:          v.Get("tradeId").String(),
+		OrderID:     order.ExchangeOrderID,
+		Trading:     pair.Trading,
+		Settlement:  pair.Settlement,
+		Type:        order.Type,
+		Price:       helpers.ToDecimal(v.Get("tradePrice")),
+		Amount:      helpers.ToDecimal(v.Get("tradeQuantity")),
+		Fee:         helpers.ToDecimal(v.Get("tradeFee")),
+		FeeCurrency: pair.Settlement,
+		Time:        order.UpdatedAt,
+	})
+}
+
+// onBalances publishes the balances which differ from the ones fetched last.
+func (s *HitbtcUserStream) onBalances(result []gjson.Result) {
+	now := time.Now()
+	for _, v := range result {
+		currency := hitbtcSymbols.Currency(v.Get("currency").Str)
+		balance := models.Balance{
+			Available: helpers.ToDecimal(v.Get("available")),
+			OnOrders:  helpers.ToDecimal(v.Get("reserved")),
+		}
+		last, ok := s.balances[currency]
+		if !ok && balance.Available.IsZero() && balance.OnOrders.IsZero() {
+			continue
+		}
+		if ok && last.Available.Equal(balance.Available) && last.OnOrders.Equal(balance.OnOrders) {
+			continue
+		}
+		s.balances[currency] = balance
+		s.publishBalance(currency, models.NewBalance(balance.Available, balance.OnOrders), now)
+	}
+}
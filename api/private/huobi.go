@@ -1,6 +1,7 @@
 package private
 
 import (
+	"context"
 	"fmt"
 	"net/http"
 	"net/url"
@@ -11,14 +12,22 @@ import (
 
 	"github.com/antonholmquist/jason"
 	"github.com/fxpgr/go-exchange-client/api/public"
+	"github.com/fxpgr/go-exchange-client/apierrors"
+	"github.com/fxpgr/go-exchange-client/decimal"
+	"github.com/fxpgr/go-exchange-client/helpers"
 	"github.com/fxpgr/go-exchange-client/models"
+	"github.com/fxpgr/go-exchange-client/retry"
+	"github.com/fxpgr/go-exchange-client/symbols"
 	"github.com/pkg/errors"
+	"github.com/tidwall/gjson"
 )
 
 const (
 	HUOBI_BASE_URL = "https://api.huobi.pro"
 )
 
+var huobiSymbols = symbols.For("huobi")
+
 func NewHuobiApi(apikey func() (string, error), apisecret func() (string, error)) (*HuobiApi, error) {
 	hitbtcPublic, err := public.NewHuobiPublicApi()
 	if err != nil {
@@ -42,6 +51,7 @@ func NewHuobiApi(apikey func() (string, error), apisecret func() (string, error)
 	}
 
 	return &HuobiApi{
+		HttpClient:        http.Client{Transport: newTransport("huobi", nil)},
 		BaseURL:           HUOBI_BASE_URL,
 		RateCacheDuration: 30 * time.Second,
 		ApiKeyFunc:        apikey,
@@ -62,8 +72,9 @@ type HuobiApi struct {
 	BaseURL           string
 	RateCacheDuration time.Duration
 	HttpClient        http.Client
-	rt                *http.Transport
-	settlements       []string
+	clientContext
+	rt          *http.Transport
+	settlements []string
 
 	volumeMap       map[string]map[string]float64
 	rateMap         map[string]map[string]float64
@@ -76,6 +87,12 @@ func (h *HuobiApi) privateApiUrl() string {
 	return h.BaseURL
 }
 
+func (h *HuobiApi) WithContext(ctx context.Context) PrivateClient {
+	c := *h
+	c.ctx = ctx
+	return &c
+}
+
 func (h *HuobiApi) privateApi(method string, path string, params *url.Values) ([]byte, error) {
 
 	apiKey, err := h.ApiKeyFunc()
@@ -95,8 +112,46 @@ func (h *HuobiApi) privateApi(method string, path string, params *url.Values) ([
 	sign, _ := GetParamHmacSHA256Base64Sign(secretKey, payload)
 	params.Set("Signature", sign)
 	urlStr := h.BaseURL + path + "?" + params.Encode()
-	resBody, err := NewHttpRequest(&http.Client{}, method, urlStr, "", nil)
-	return resBody, err
+	ctx := h.context()
+	if isPlaceOrWithdraw(path) && params.Get("client-order-id") == "" {
+		ctx = retry.WithPolicy(ctx, retry.Policy{MaxAttempts: 1})
+	}
+	resBody, err := helpers.NewHttpRequestWithContext(ctx, &h.HttpClient, method, urlStr, "", nil)
+	if err != nil {
+		return nil, err
+	}
+	if err := huobiError(resBody); err != nil {
+		return nil, errors.Wrapf(err, "failed to request command %s", path)
+	}
+	return resBody, nil
+}
+
+// isPlaceOrWithdraw reports the endpoints which are not idempotent even
+// though they are served over GET.
+func isPlaceOrWithdraw(path string) bool {
+	return strings.HasSuffix(path, "/orders/place") || strings.HasSuffix(path, "/withdraw/api/create")
+}
+
+var huobiErrorCodes = map[string]error{
+	"api-signature-not-valid":                   apierrors.ErrAuth,
+	"api-signature-check-failed":                apierrors.ErrAuth,
+	"login-required":                            apierrors.ErrAuth,
+	"base-symbol-error":                         apierrors.ErrInvalidSymbol,
+	"base-record-invalid":                       apierrors.ErrOrderNotFound,
+	"order-accountbalance-error":                apierrors.ErrInsufficientFunds,
+	"account-frozen-balance-insufficient-error": apierrors.ErrInsufficientFunds,
+	"account-balance-insufficient-error":        apierrors.ErrInsufficientFunds,
+}
+
+// huobiError maps the {"status":"error","err-code":...} payload which huobi
+// answers failed requests with, even though the HTTP status is 200.
+func huobiError(body []byte) error {
+	value := gjson.ParseBytes(body)
+	if value.Get("status").String() != "error" {
+		return nil
+	}
+	code := value.Get("err-code").String()
+	return apiError(http.StatusOK, code, value.Get("err-msg").String(), huobiErrorCodes[code])
 }
 
 func (h *HuobiApi) TradeFeeRates() (map[string]map[string]TradeFee, error) {
@@ -104,14 +159,14 @@ func (h *HuobiApi) TradeFeeRates() (map[string]map[string]TradeFee, error) {
 	if err != nil {
 		return nil, err
 	}
-	pairs, err := cli.CurrencyPairs()
+	pairs, err := cli.WithContext(h.context()).CurrencyPairs()
 	if err != nil {
 		return nil, err
 	}
 	traderFeeMap := make(map[string]map[string]TradeFee)
 	for _, p := range pairs {
 		n := make(map[string]TradeFee)
-		n[p.Settlement] = TradeFee{0.002, 0.002}
+		n[p.Settlement] = TradeFee{decimal.New(2, -3), decimal.New(2, -3)}
 		traderFeeMap[p.Trading] = n
 	}
 	return traderFeeMap, nil
@@ -297,6 +352,7 @@ func (h *HuobiApi) Balances() (map[string]float64, error) {
 		if err != nil {
 			continue
 		}
+		currency = huobiSymbols.Currency(currency)
 		t, err := v.GetString("type")
 		if err != nil {
 			continue
@@ -372,12 +428,13 @@ func (h *HuobiApi) CompleteBalances() (map[string]*models.Balance, error) {
 	m := make(map[string]*models.Balance)
 	var previousCurrency string
 	previousBalance := &models.Balance{}
-	var available float64
+	var available decimal.Decimal
 	for _, v := range balances {
 		currency, err := v.GetString("currency")
 		if err != nil {
 			continue
 		}
+		currency = huobiSymbols.Currency(currency)
 		t, err := v.GetString("type")
 		if err != nil {
 			continue
@@ -386,7 +443,7 @@ func (h *HuobiApi) CompleteBalances() (map[string]*models.Balance, error) {
 		if err != nil {
 			continue
 		}
-		available, err = strconv.ParseFloat(availableStr, 10)
+		available, err = decimal.NewFromString(availableStr)
 		if err != nil {
 			return nil, err
 		}
@@ -431,12 +488,13 @@ func (h *HuobiApi) CompleteBalance(coin string) (*models.Balance, error) {
 	m := make(map[string]*models.Balance)
 	var previousCurrency string
 	previousBalance := &models.Balance{}
-	var available float64
+	var available decimal.Decimal
 	for _, v := range balances {
 		currency, err := v.GetString("currency")
 		if err != nil {
 			continue
 		}
+		currency = huobiSymbols.Currency(currency)
 		if currency == coin {
 			continue
 		}
@@ -448,7 +506,7 @@ func (h *HuobiApi) CompleteBalance(coin string) (*models.Balance, error) {
 		if err != nil {
 			continue
 		}
-		available, err = strconv.ParseFloat(availableStr, 10)
+		available, err = decimal.NewFromString(availableStr)
 		if err != nil {
 			return nil, err
 		}
@@ -479,24 +537,56 @@ func (h *HuobiApi) ActiveOrders() ([]*models.Order, error) {
 }
 
 func (h *HuobiApi) Order(trading string, settlement string, ordertype models.OrderType, price float64, amount float64) (string, error) {
+	return h.PlaceOrder(models.OrderRequest{Trading: trading, Settlement: settlement, Type: ordertype, Price: decimal.NewFromFloat(price), Amount: decimal.NewFromFloat(amount)})
+}
+
+func (h *HuobiApi) PlaceOrder(req models.OrderRequest) (string, error) {
 	accountId, err := h.getAccountId()
 	if err != nil {
 		return "", err
 	}
 	params := &url.Values{}
-	if ordertype == models.Ask {
-		params.Set("type", "buy-limit")
-	} else if ordertype == models.Bid {
-		params.Set("type", "sell-limit")
+	var side string
+	if req.Type == models.Ask {
+		side = "buy"
+	} else if req.Type == models.Bid {
+		side = "sell"
 	} else {
-		return "", errors.Errorf("unknown order type %d", ordertype)
-	}
-	params.Set("symbol", strings.ToLower(fmt.Sprintf("%s%s", trading, settlement)))
+		return "", errors.Errorf("unknown order type %d", req.Type)
+	}
+	amount := req.Amount
+	switch {
+	case req.ExecutionType == models.Market && req.TimeInForce == models.GTC:
+		params.Set("type", side+"-market")
+		if req.Type == models.Ask {
+			// market buys are sized in the settlement currency
+			if !req.Price.IsPositive() {
+				return "", errors.New("market buy needs a reference price to be sized")
+			}
+			amount = req.Amount.Mul(req.Price)
+		}
+	case req.ExecutionType == models.Limit && req.TimeInForce == models.GTC:
+		params.Set("type", side+"-limit")
+	case req.ExecutionType == models.Limit && req.TimeInForce == models.IOC:
+		params.Set("type", side+"-ioc")
+	case req.ExecutionType == models.Limit && req.TimeInForce == models.FOK:
+		params.Set("type", side+"-limit-fok")
+	case req.ExecutionType == models.Limit && req.TimeInForce == models.PostOnly:
+		params.Set("type", side+"-limit-maker")
+	default:
+		return "", unsupportedOrder("huobi", req)
+	}
+	params.Set("symbol", huobiSymbols.Symbol(req.Trading, req.Settlement))
 	params.Set("account-id", accountId)
-	amountStr := strconv.FormatFloat(amount, 'f', 4, 64)
-	priceStr := strconv.FormatFloat(price, 'f', 4, 64)
+	amountStr := FloorDecimalToStr(amount, 4)
 	params.Set("amount", amountStr)
-	params.Set("price", priceStr)
+	if req.ExecutionType == models.Limit {
+		priceStr := FloorDecimalToStr(req.Price, 4)
+		params.Set("price", priceStr)
+	}
+	if id := retry.ClientOrderID(h.context()); id != "" {
+		params.Set("client-order-id", id)
+	}
 	byteArray, err := h.privateApi("GET", "/v1/order/orders/place", params)
 	if err != nil {
 		return "", err
@@ -518,7 +608,7 @@ func (h *HuobiApi) Transfer(typ string, addr string, amount float64, additionalF
 	additionalFeeStr := strconv.FormatFloat(additionalFee, 'f', 4, 64)
 	params.Set("address", addr)
 	params.Set("amount", amountStr)
-	params.Set("currency", typ)
+	params.Set("currency", huobiSymbols.Native(typ))
 	params.Set("fee", additionalFeeStr)
 	_, err := h.privateApi("GET", "/v1/dw/withdraw/api/create", params)
 	return err
@@ -560,9 +650,184 @@ func (h *HuobiApi) IsOrderFilled(trading string, settlement string, orderNumber
 	return false, nil
 }
 
+func (h *HuobiApi) OrderStatus(trading string, settlement string, orderNumber string) (*models.Order, error) {
+	bs, err := h.privateApi("GET", "/v1/order/orders/"+orderNumber, &url.Values{})
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to get order %s", orderNumber)
+	}
+	return parseHuobiOrder(bs, trading, settlement, orderNumber), nil
+}
+
+var huobiOrderStatus = map[string]models.OrderStatus{
+	"created":          models.OrderOpen,
+	"submitted":        models.OrderOpen,
+	"partial-filled":   models.OrderPartiallyFilled,
+	"filled":           models.OrderFilled,
+	"partial-canceled": models.OrderCanceled,
+	"canceled":         models.OrderCanceled,
+}
+
+func parseHuobiOrder(bs []byte, trading string, settlement string, orderNumber string) *models.Order {
+	data := gjson.ParseBytes(bs).Get("data")
+	order := &models.Order{
+		ExchangeOrderID: orderNumber,
+		Type:            models.Ask,
+		Trading:         trading,
+		Settlement:      settlement,
+		Price:           helpers.ToDecimal(data.Get("price")),
+		Amount:          helpers.ToDecimal(data.Get("amount")),
+		Status:          huobiOrderStatus[data.Get("state").Str],
+		FilledAmount:    helpers.ToDecimal(data.Get("field-amount")),
+		Fee:             helpers.ToDecimal(data.Get("field-fees")),
+		FeeCurrency:     trading,
+		CreatedAt:       millisToTime(data.Get("created-at").Int()),
+		UpdatedAt:       millisToTime(data.Get("finished-at").Int()),
+	}
+	// fees are charged in the currency received
+	if strings.HasPrefix(data.Get("type").Str, "sell") {
+		order.Type = models.Bid
+		order.FeeCurrency = settlement
+	}
+	if canceledAt := millisToTime(data.Get("canceled-at").Int()); canceledAt.After(order.UpdatedAt) {
+		order.UpdatedAt = canceledAt
+	}
+	order.AveragePrice = averagePrice(helpers.ToDecimal(data.Get("field-cash-amount")), order.FilledAmount)
+	return order
+}
+
+func (h *HuobiApi) MyTrades(trading string, settlement string, since time.Time, limit int) ([]*models.Trade, error) {
+	return huobiMatchResults(h.privateApi, trading, settlement, since, limit)
+}
+
+const huobiPageSize = 100
+
+// huobiMatchResults pages forward through the fills from since, for huobi and
+// the okex v1 endpoints which mirror it.
+func huobiMatchResults(privateApi func(string, string, *url.Values) ([]byte, error),
+	trading string, settlement string, since time.Time, limit int) ([]*models.Trade, error) {
+	var trades []*models.Trade
+	from := ""
+	for {
+		params := &url.Values{}
+		params.Set("symbol", huobiSymbols.Symbol(trading, settlement))
+		params.Set("size", strconv.Itoa(huobiPageSize))
+		if !since.IsZero() {
+			params.Set("start-date", since.UTC().Format("2006-01-02"))
+		}
+		if from != "" {
+			params.Set("from", from)
+			params.Set("direct", "next")
+		}
+		bs, err := privateApi("GET", "/v1/order/matchresults", params)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to get trades of %s/%s", trading, settlement)
+		}
+		page := gjson.ParseBytes(bs).Get("data").Array()
+		var lastID int64
+		for _, v := range page {
+			trade := &models.Trade{
+				ID:          v.Get("id").String(),
+				OrderID:     v.Get("order-id").String(),
+				Trading:     trading,
+				Settlement:  settlement,
+				Type:        models.Ask,
+				Price:       helpers.ToDecimal(v.Get("price")),
+				Amount:      helpers.ToDecimal(v.Get("filled-amount")),
+				Fee:         helpers.ToDecimal(v.Get("filled-fees")),
+				FeeCurrency: trading,
+				Time:        millisToTime(v.Get("created-at").Int()),
+			}
+			if strings.HasPrefix(v.Get("type").Str, "sell") {
+				trade.Type = models.Bid
+				trade.FeeCurrency = settlement
+			}
+			if id := v.Get("id").Int(); id > lastID {
+				lastID = id
+			}
+			trades = append(trades, trade)
+		}
+		if len(page) < huobiPageSize || (limit > 0 && len(trades) >= limit) {
+			break
+		}
+		from = strconv.FormatInt(lastID, 10)
+	}
+	return trimTrades(trades, since, limit), nil
+}
+
+func (h *HuobiApi) Deposits(since time.Time) ([]*models.Transfer, error) {
+	return huobiDepositWithdraw(h.privateApi, "deposit", since)
+}
+
+func (h *HuobiApi) Withdrawals(since time.Time) ([]*models.Transfer, error) {
+	return huobiDepositWithdraw(h.privateApi, "withdraw", since)
+}
+
+var huobiTransferStatus = map[string]models.TransferStatus{
+	"confirming":      models.TransferPending,
+	"confirmed":       models.TransferCompleted,
+	"safe":            models.TransferCompleted,
+	"orphan":          models.TransferFailed,
+	"submitted":       models.TransferPending,
+	"reexamine":       models.TransferPending,
+	"pass":            models.TransferPending,
+	"pre-transfer":    models.TransferPending,
+	"wallet-transfer": models.TransferPending,
+	"canceled":        models.TransferCanceled,
+	"repealed":        models.TransferCanceled,
+	"reject":          models.TransferFailed,
+	"wallet-reject":   models.TransferFailed,
+	"confirm-error":   models.TransferFailed,
+}
+
+// huobiDepositWithdraw pages forward through the transfers of typ, which is
+// "deposit" or "withdraw", for huobi and the okex v1 endpoints which mirror it.
+func huobiDepositWithdraw(privateApi func(string, string, *url.Values) ([]byte, error),
+	typ string, since time.Time) ([]*models.Transfer, error) {
+	var transfers []*models.Transfer
+	from := ""
+	for {
+		params := &url.Values{}
+		params.Set("type", typ)
+		params.Set("size", strconv.Itoa(huobiPageSize))
+		if from != "" {
+			params.Set("from", from)
+			params.Set("direct", "next")
+		}
+		bs, err := privateApi("GET", "/v1/query/deposit-withdraw", params)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to get %s history", typ)
+		}
+		page := gjson.ParseBytes(bs).Get("data").Array()
+		var lastID int64
+		for _, v := range page {
+			transfers = append(transfers, &models.Transfer{
+				ID:        v.Get("id").String(),
+				Currency:  huobiSymbols.Currency(v.Get("currency").Str),
+				Amount:    helpers.ToDecimal(v.Get("amount")),
+				Fee:       helpers.ToDecimal(v.Get("fee")),
+				Address:   v.Get("address").Str,
+				Tag:       v.Get("address-tag").Str,
+				TxID:      v.Get("tx-hash").Str,
+				Network:   v.Get("chain").Str,
+				Status:    huobiTransferStatus[v.Get("state").Str],
+				CreatedAt: millisToTime(v.Get("created-at").Int()),
+				UpdatedAt: millisToTime(v.Get("updated-at").Int()),
+			})
+			if id := v.Get("id").Int(); id > lastID {
+				lastID = id
+			}
+		}
+		if len(page) < huobiPageSize {
+			break
+		}
+		from = strconv.FormatInt(lastID, 10)
+	}
+	return trimTransfers(transfers, since), nil
+}
+
 func (h *HuobiApi) Address(c string) (string, error) {
 	params := &url.Values{}
-	params.Set("currency", strings.ToLower(c))
+	params.Set("currency", huobiSymbols.Native(c))
 	params.Set("type", "deposit")
 
 	bs, err := h.privateApi("GET", "/v1/dw/deposit-virtual/addresses", params)
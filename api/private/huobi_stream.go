@@ -0,0 +1,168 @@
+package private
+
+import (
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/fxpgr/go-exchange-client/helpers"
+	"github.com/fxpgr/go-exchange-client/logger"
+	"github.com/fxpgr/go-exchange-client/models"
+	"github.com/fxpgr/go-exchange-client/stream"
+	"github.com/pkg/errors"
+	"github.com/tidwall/gjson"
+)
+
+const (
+	HUOBI_USER_STREAM_URL = "wss://api.huobi.pro/ws/v2"
+)
+
+// huobiUserTopics are the order updates, the fills and the balance changes
+// of every pair and currency.
+var huobiUserTopics = []string{"orders#*", "trade.clearing#*#0", "accounts.update#1"}
+
+// HuobiUserStream follows the account of Huobi over its v2 WebSocket API,
+// authenticating with a signature over the URL after every dial.
+type HuobiUserStream struct {
+	userEvents
+	Api  *HuobiApi
+	Conn *stream.Conn
+}
+
+func NewHuobiUserStream(api *HuobiApi) *HuobiUserStream {
+	s := &HuobiUserStream{Api: api}
+	s.Conn = stream.NewConn(HUOBI_USER_STREAM_URL, s.handle)
+	s.Conn.OnConnect = s.authenticate
+	s.run = runConn(s.Conn)
+	return s
+}
+
+func (s *HuobiUserStream) authenticate(c *stream.Conn) error {
+	apiKey, err := s.Api.ApiKeyFunc()
+	if err != nil {
+		return errors.Wrap(err, "failed to authenticate")
+	}
+	secretKey, err := s.Api.SecretKeyFunc()
+	if err != nil {
+		return errors.Wrap(err, "failed to authenticate")
+	}
+	u, err := url.Parse(c.URL)
+	if err != nil {
+		return errors.Wrapf(err, "failed to parse %s", c.URL)
+	}
+	params := url.Values{}
+	params.Set("accessKey", apiKey)
+	params.Set("signatureMethod", "HmacSHA256")
+	params.Set("signatureVersion", "2.1")
+	params.Set("timestamp", time.Now().UTC().Format("2006-01-02T15:04:05"))
+	sign, _ := GetParamHmacSHA256Base64Sign(secretKey, fmt.Sprintf("GET\n%s\n%s\n%s", u.Host, u.Path, params.Encode()))
+	return c.WriteJSON(map[string]interface{}{
+		"action": "req",
+		"ch":     "auth",
+		"params": map[string]string{
+			"authType":         "api",
+			"accessKey":        apiKey,
+			"signatureMethod":  "HmacSHA256",
+			"signatureVersion": "2.1",
+			"timestamp":        params.Get("timestamp"),
+			"signature":        sign,
+		},
+	})
+}
+
+func (s *HuobiUserStream) handle(msg []byte) error {
+	value := gjson.ParseBytes(msg)
+	ch := value.Get("ch").Str
+	switch value.Get("action").Str {
+	case "ping":
+		return s.Conn.WriteJSON(map[string]interface{}{
+			"action": "pong",
+			"data":   map[string]int64{"ts": value.Get("data.ts").Int()},
+		})
+	case "req":
+		if ch != "auth" {
+			return nil
+		}
+		if code := value.Get("code").Int(); code != 200 {
+			return authError(strconv.FormatInt(code, 10), value.Get("message").Str)
+		}
+		for _, topic := range huobiUserTopics {
+			if err := s.Conn.WriteJSON(map[string]string{"action": "sub", "ch": topic}); err != nil {
+				return errors.Wrapf(err, "failed to subscribe %s", topic)
+			}
+		}
+	case "sub":
+		if value.Get("code").Int() != 200 {
+			logger.Get().Warnw("huobi user stream subscription failed", "topic", ch, "error", value.Get("message").Str)
+		}
+	case "push":
+		data := value.Get("data")
+		switch {
+		case strings.HasPrefix(ch, "orders#"):
+			s.onOrder(data)
+		case strings.HasPrefix(ch, "trade.clearing#"):
+			s.onTrade(data)
+		case strings.HasPrefix(ch, "accounts.update#"):
+			available := helpers.ToDecimal(data.Get("available"))
+			s.publishBalance(huobiSymbols.Currency(data.Get("currency").Str),
+				models.NewBalance(available, helpers.ToDecimal(data.Get("balance")).Sub(available)),
+				millisToTime(data.Get("changeTime").Int()))
+		}
+	}
+	return nil
+}
+
+func (s *HuobiUserStream) onOrder(data gjson.Result) {
+	pair, ok := pairOf(huobiSymbols, data.Get("symbol").Str, s.Api.settlements)
+	if !ok {
+		logger.Get().Warnw("unknown huobi symbol", "symbol", data.Get("symbol").Str)
+		return
+	}
+	order := &models.Order{
+		ExchangeOrderID: data.Get("orderId").String(),
+		Type:            models.Ask,
+		Trading:         pair.Trading,
+		Settlement:      pair.Settlement,
+		Price:           helpers.ToDecimal(data.Get("orderPrice")),
+		Amount:          helpers.ToDecimal(data.Get("orderSize")),
+		Status:          huobiOrderStatus[data.Get("orderStatus").Str],
+		FilledAmount:    helpers.ToDecimal(data.Get("execAmt")),
+		CreatedAt:       millisToTime(data.Get("orderCreateTime").Int()),
+	}
+	if strings.HasPrefix(data.Get("type").Str, "sell") {
+		order.Type = models.Bid
+	}
+	for _, key := range []string{"tradeTime", "lastActTime", "orderCreateTime"} {
+		if t := data.Get(key); t.Exists() {
+			order.UpdatedAt = millisToTime(t.Int())
+			break
+		}
+	}
+	s.publishOrder(order)
+}
+
+func (s *HuobiUserStream) onTrade(data gjson.Result) {
+	pair, ok := pairOf(huobiSymbols, data.Get("symbol").Str, s.Api.settlements)
+	if !ok {
+		logger.Get().Warnw("unknown huobi symbol", "symbol", data.Get("symbol").Str)
+		return
+	}
+	trade := &models.Trade{
+		ID:          data.Get("tradeId").String(),
+		OrderID:     data.Get("orderId").String(),
+		Trading:     pair.Trading,
+		Settlement:  pair.Settlement,
+		Type:        models.Ask,
+		Price:       helpers.ToDecimal(data.Get("tradePrice")),
+		Amount:      helpers.ToDecimal(data.Get("tradeVolume")),
+		Fee:         helpers.ToDecimal(data.Get("transactFee")),
+		FeeCurrency: huobiSymbols.Currency(data.Get("feeCurrency").Str),
+		Time:        millisToTime(data.Get("tradeTime").Int()),
+	}
+	if data.Get("orderSide").Str == "sell" {
+		trade.Type = models.Bid
+	}
+	s.publishFill(trade)
+}
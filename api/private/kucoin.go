@@ -1,6 +1,7 @@
 package private
 
 import (
+	"context"
 	"crypto/hmac"
 	"crypto/sha256"
 	"encoding/base64"
@@ -17,7 +18,12 @@ import (
 
 	"github.com/antonholmquist/jason"
 	"github.com/fxpgr/go-exchange-client/api/public"
+	"github.com/fxpgr/go-exchange-client/apierrors"
+	"github.com/fxpgr/go-exchange-client/decimal"
+	"github.com/fxpgr/go-exchange-client/helpers"
 	"github.com/fxpgr/go-exchange-client/models"
+	"github.com/fxpgr/go-exchange-client/retry"
+	"github.com/fxpgr/go-exchange-client/symbols"
 	"github.com/pkg/errors"
 	"github.com/tidwall/gjson"
 )
@@ -26,6 +32,8 @@ const (
 	KUCOIN_BASE_URL = "https://api.kucoin.com"
 )
 
+var kucoinSymbols = symbols.For("kucoin")
+
 func NewKucoinApi(apikey func() (string, error), apisecret func() (string, error)) (*KucoinApi, error) {
 	hitbtcPublic, err := public.NewKucoinPublicApi()
 	if err != nil {
@@ -49,6 +57,7 @@ func NewKucoinApi(apikey func() (string, error), apisecret func() (string, error
 	}
 
 	return &KucoinApi{
+		HttpClient:        http.Client{Transport: newTransport("kucoin", nil)},
 		BaseURL:           KUCOIN_BASE_URL,
 		RateCacheDuration: 30 * time.Second,
 		ApiKeyFunc:        apikey,
@@ -69,8 +78,9 @@ type KucoinApi struct {
 	BaseURL           string
 	RateCacheDuration time.Duration
 	HttpClient        http.Client
-	rt                *http.Transport
-	settlements       []string
+	clientContext
+	rt          *http.Transport
+	settlements []string
 
 	volumeMap       map[string]map[string]float64
 	rateMap         map[string]map[string]float64
@@ -84,12 +94,18 @@ func (h *KucoinApi) privateApiUrl() string {
 	return h.BaseURL
 }
 
+func (h *KucoinApi) WithContext(ctx context.Context) PrivateClient {
+	c := *h
+	c.ctx = ctx
+	return &c
+}
+
 func (h *KucoinApi) publicApiUrl(command string) string {
 	return h.BaseURL + command
 }
 
-func requestGetAsChrome(url string) (*http.Request, error) {
-	req, err := http.NewRequest("GET", url, nil)
+func requestGetAsChrome(ctx context.Context, url string) (*http.Request, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
 	if err != nil {
 		return req, err
 	}
@@ -103,7 +119,7 @@ func (h *KucoinApi) fetchPrecision() error {
 	}
 	coinPrecision := make(map[string]int)
 	url := h.publicApiUrl("/api/v1/currencies")
-	req, err := requestGetAsChrome(url)
+	req, err := requestGetAsChrome(h.context(), url)
 	if err != nil {
 		return errors.Wrapf(err, "failed to fetch %s", url)
 	}
@@ -118,13 +134,13 @@ func (h *KucoinApi) fetchPrecision() error {
 	}
 	value := gjson.Parse(string(byteArray))
 	for _, v := range value.Get("data").Array() {
-		coinPrecision[v.Get("currency").Str] = int(v.Get("precision").Int())
+		coinPrecision[kucoinSymbols.Currency(v.Get("currency").Str)] = int(v.Get("precision").Int())
 	}
 
 	h.precisionMap = make(map[string]map[string]models.Precisions)
 
 	url = h.publicApiUrl("/api/v1/market/allTickers")
-	req, err = requestGetAsChrome(url)
+	req, err = requestGetAsChrome(h.context(), url)
 	if err != nil {
 		return errors.Wrapf(err, "failed to fetch %s", url)
 	}
@@ -139,12 +155,11 @@ func (h *KucoinApi) fetchPrecision() error {
 	}
 	value = gjson.ParseBytes(byteArray)
 	for _, v := range value.Get("data.ticker").Array() {
-		currencies := strings.Split(v.Get("symbol").Str, "-")
-		if len(currencies) < 2 {
+		pair, ok := kucoinSymbols.Pair(v.Get("symbol").Str)
+		if !ok {
 			continue
 		}
-		trading := currencies[0]
-		settlement := currencies[1]
+		trading, settlement := pair.Trading, pair.Settlement
 
 		m, ok := h.precisionMap[trading]
 		if !ok {
@@ -166,9 +181,9 @@ func (h *KucoinApi) precise(trading string, settlement string) (*models.Precisio
 
 	h.fetchPrecision()
 	if m, ok := h.precisionMap[trading]; !ok {
-		return &models.Precisions{}, errors.Errorf("%s/%s missing trading", trading, settlement)
+		return &models.Precisions{}, errors.Wrapf(apierrors.ErrInvalidSymbol, "%s/%s missing trading", trading, settlement)
 	} else if precisions, ok := m[settlement]; !ok {
-		return &models.Precisions{}, errors.Errorf("%s/%s missing settlement", trading, settlement)
+		return &models.Precisions{}, errors.Wrapf(apierrors.ErrInvalidSymbol, "%s/%s missing settlement", trading, settlement)
 	} else {
 		return &precisions, nil
 	}
@@ -179,14 +194,14 @@ func (h *KucoinApi) privateApi(method string, path string, params *url.Values) (
 		return nil, errors.Wrapf(err, "failed to create request command %s", path)
 	}
 	if !strings.Contains(apiFraseAndKey, "::") {
-		return nil, errors.New("invalid passphrase")
+		return nil, errors.Wrap(apierrors.ErrAuth, "invalid passphrase")
 	}
 	sli := strings.SplitN(apiFraseAndKey, "::", 2)
 	if len(sli) < 2 {
-		return nil, errors.New("invalid passphrase")
+		return nil, errors.Wrap(apierrors.ErrAuth, "invalid passphrase")
 	}
 	if (sli[0] == "") || (sli[1] == "") {
-		return nil, errors.New("invalid passphrase")
+		return nil, errors.Wrap(apierrors.ErrAuth, "invalid passphrase")
 	}
 	phrase := sli[0]
 	apiKey := sli[1]
@@ -199,8 +214,12 @@ func (h *KucoinApi) privateApi(method string, path string, params *url.Values) (
 		urlStr = urlStr + "?" + params.Encode()
 	}
 
+	ctx := h.context()
+	if params.Get("clientOid") != "" {
+		ctx = retry.Idempotent(ctx)
+	}
 	reader := bytes.NewReader([]byte(params.Encode()))
-	req, err := http.NewRequest(method, urlStr, reader)
+	req, err := http.NewRequestWithContext(ctx, method, urlStr, reader)
 	if err != nil {
 		return nil, errors.Wrapf(err, "failed to create request command %s", path)
 	}
@@ -230,12 +249,42 @@ func (h *KucoinApi) privateApi(method string, path string, params *url.Values) (
 	if err != nil {
 		return nil, errors.Wrapf(err, "failed to fetch result of command %s", path)
 	}
+	if err := kucoinError(res.StatusCode, resBody); err != nil {
+		return nil, errors.Wrapf(err, "failed to request command %s", path)
+	}
 	return resBody, err
 }
 
+var kucoinErrorCodes = map[string]error{
+	"400001": apierrors.ErrAuth,
+	"400002": apierrors.ErrAuth,
+	"400003": apierrors.ErrAuth,
+	"400004": apierrors.ErrAuth,
+	"400005": apierrors.ErrAuth,
+	"400006": apierrors.ErrAuth,
+	"400007": apierrors.ErrAuth,
+	"429000": apierrors.ErrRateLimited,
+	"200004": apierrors.ErrInsufficientFunds,
+	"900001": apierrors.ErrInvalidSymbol,
+}
+
+func kucoinError(statusCode int, body []byte) error {
+	value := gjson.ParseBytes(body)
+	code := value.Get("code").String()
+	if statusCode == http.StatusOK && (code == "" || code == "200000") {
+		return nil
+	}
+	message := value.Get("msg").String()
+	kind := kucoinErrorCodes[code]
+	if strings.Contains(strings.ToLower(message), "order not exist") {
+		kind = apierrors.ErrOrderNotFound
+	}
+	return apiError(statusCode, code, message, kind)
+}
+
 func (h *KucoinApi) TradeFeeRates() (map[string]map[string]TradeFee, error) {
 	url := h.publicApiUrl("/api/v1/market/allTickers")
-	req, err := requestGetAsChrome(url)
+	req, err := requestGetAsChrome(h.context(), url)
 	if err != nil {
 		return nil, errors.Wrapf(err, "failed to fetch %s", url)
 	}
@@ -251,14 +300,13 @@ func (h *KucoinApi) TradeFeeRates() (map[string]map[string]TradeFee, error) {
 	value := gjson.ParseBytes(byteArray)
 	traderFeeMap := make(map[string]map[string]TradeFee)
 	for _, v := range value.Get("data.ticker").Array() {
-		currencies := strings.Split(v.Get("symbol").Str, "-")
-		if len(currencies) < 2 {
+		pair, ok := kucoinSymbols.Pair(v.Get("symbol").Str)
+		if !ok {
 			continue
 		}
-		trading := currencies[0]
-		settlement := currencies[1]
+		trading, settlement := pair.Trading, pair.Settlement
 
-		feeRate := 0.001
+		feeRate := decimal.New(1, -3)
 		m, ok := traderFeeMap[trading]
 		if !ok {
 			m = make(map[string]TradeFee)
@@ -302,7 +350,7 @@ func (sm *kucoinTransferFeeSyncMap) GetAll() map[string]float64 {
 
 func (h *KucoinApi) TransferFee() (map[string]float64, error) {
 	url := h.publicApiUrl("/api/v1/currencies")
-	req, err := requestGetAsChrome(url)
+	req, err := requestGetAsChrome(h.context(), url)
 	if err != nil {
 		return nil, errors.Wrapf(err, "failed to fetch %s", url)
 	}
@@ -338,7 +386,7 @@ func (h *KucoinApi) TransferFee() (map[string]float64, error) {
 		if err != nil {
 			continue
 		}
-		transferFeeMap.Set(strings.ToUpper(coin), feef)
+		transferFeeMap.Set(kucoinSymbols.Currency(coin), feef)
 	}
 	return transferFeeMap.GetAll(), nil
 }
@@ -380,7 +428,7 @@ func (h *KucoinApi) Balances() (map[string]float64, error) {
 		if err != nil {
 			return nil, errors.Wrapf(err, "failed to parse currency on %s", json)
 		}
-		currency = strings.ToUpper(currency)
+		currency = kucoinSymbols.Currency(currency)
 		m[currency] = balance - freeze
 	}
 	return m, nil
@@ -407,23 +455,30 @@ func (h *KucoinApi) CompleteBalances() (map[string]*models.Balance, error) {
 		return nil, errors.Wrapf(err, "failed to parse json key data %s", json)
 	}
 	for _, v := range data {
-		balance, err := v.GetFloat64("balance")
+		balanceStr, err := v.GetString("balance")
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to parse balance on %s", json)
+		}
+		balance, err := decimal.NewFromString(balanceStr)
 		if err != nil {
 			return nil, errors.Wrapf(err, "failed to parse balance on %s", json)
 		}
-		available, err := v.GetFloat64("available")
+		availableStr, err := v.GetString("available")
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to parse available on %s", json)
+		}
+		available, err := decimal.NewFromString(availableStr)
 		if err != nil {
 			return nil, errors.Wrapf(err, "failed to parse available on %s", json)
 		}
-		freeze := balance - available
 		currency, err := v.GetString("currency")
 		if err != nil {
 			return nil, errors.Wrapf(err, "failed to parse currency on %s", json)
 		}
-		currency = strings.ToUpper(currency)
+		currency = kucoinSymbols.Currency(currency)
 		m[currency] = &models.Balance{
-			Available: balance,
-			OnOrders:  freeze,
+			Available: available,
+			OnOrders:  balance.Sub(available),
 		}
 	}
 	return m, nil
@@ -455,23 +510,33 @@ func (h *KucoinApi) ActiveOrders() ([]*models.Order, error) {
 }
 
 func (h *KucoinApi) Order(trading string, settlement string, ordertype models.OrderType, price float64, amount float64) (string, error) {
+	return h.PlaceOrder(models.OrderRequest{Trading: trading, Settlement: settlement, Type: ordertype, Price: decimal.NewFromFloat(price), Amount: decimal.NewFromFloat(amount)})
+}
+
+func (h *KucoinApi) PlaceOrder(req models.OrderRequest) (string, error) {
+	if req.ExecutionType != models.Limit || req.TimeInForce != models.GTC {
+		return "", unsupportedOrder("kucoin", req)
+	}
 	params := &url.Values{}
-	if ordertype == models.Bid {
+	if req.Type == models.Bid {
 		params.Set("type", "SELL")
-	} else if ordertype == models.Ask {
+	} else if req.Type == models.Ask {
 		params.Set("type", "BUY")
 	} else {
-		return "", errors.Errorf("unknown order type %d", ordertype)
+		return "", errors.Errorf("unknown order type %d", req.Type)
 	}
-	precise, err := h.precise(trading, settlement)
+	precise, err := h.precise(req.Trading, req.Settlement)
 	if err != nil {
 		return "", err
 	}
-	params.Set("price", FloorFloat64ToStr(price, precise.PricePrecision))
-	params.Set("amount", FloorFloat64ToStr(amount, precise.AmountPrecision))
+	params.Set("price", FloorDecimalToStr(req.Price, precise.PricePrecision))
+	params.Set("amount", FloorDecimalToStr(req.Amount, precise.AmountPrecision))
 
-	symbol := strings.ToUpper(fmt.Sprintf("%s-%s", trading, settlement))
+	symbol := kucoinSymbols.Symbol(req.Trading, req.Settlement)
 	params.Set("symbol", symbol)
+	if id := retry.ClientOrderID(h.context()); id != "" {
+		params.Set("clientOid", id)
+	}
 	byteArray, err := h.privateApi("POST", "/v1/order", params)
 	if err != nil {
 		return "", err
@@ -505,7 +570,7 @@ func (h *KucoinApi) Transfer(typ string, addr string, amount float64, additional
 func (h *KucoinApi) CancelOrder(trading string, settlement string,
 	ordertype models.OrderType, orderNumber string) error {
 	params := &url.Values{}
-	params.Set("symbol", trading+"-"+settlement)
+	params.Set("symbol", kucoinSymbols.Symbol(trading, settlement))
 	params.Set("orderOid", orderNumber)
 	if ordertype == models.Ask {
 		params.Set("type", "BUY")
@@ -532,7 +597,7 @@ func (h *KucoinApi) CancelOrder(trading string, settlement string,
 
 func (h *KucoinApi) IsOrderFilled(trading string, settlement string, orderNumber string) (bool, error) {
 	params := &url.Values{}
-	params.Set("symbol", trading+"-"+settlement)
+	params.Set("symbol", kucoinSymbols.Symbol(trading, settlement))
 	bs, err := h.privateApi("GET", "/v1/order/active", params)
 	if err != nil {
 		return false, errors.Wrapf(err, "failed to cancel order")
@@ -582,6 +647,155 @@ func (h *KucoinApi) IsOrderFilled(trading string, settlement string, orderNumber
 	return true, nil
 }
 
+// OrderStatus looks the order up on both sides, as the detail endpoint needs
+// the side which the order number alone does not tell.
+func (h *KucoinApi) OrderStatus(trading string, settlement string, orderNumber string) (*models.Order, error) {
+	for _, side := range []string{"BUY", "SELL"} {
+		params := &url.Values{}
+		params.Set("symbol", kucoinSymbols.Symbol(trading, settlement))
+		params.Set("type", side)
+		params.Set("orderOid", orderNumber)
+		bs, err := h.privateApi("GET", "/v1/order/detail", params)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to get order %s", orderNumber)
+		}
+		data := gjson.ParseBytes(bs).Get("data")
+		if data.IsObject() {
+			return parseKucoinOrder(data, trading, settlement, orderNumber), nil
+		}
+	}
+	return nil, errors.Wrapf(apierrors.ErrOrderNotFound, "order %s", orderNumber)
+}
+
+func parseKucoinOrder(data gjson.Result, trading string, settlement string, orderNumber string) *models.Order {
+	order := &models.Order{
+		ExchangeOrderID: orderNumber,
+		Type:            models.Ask,
+		Trading:         trading,
+		Settlement:      settlement,
+		Price:           helpers.ToDecimal(data.Get("orderPrice")),
+		FilledAmount:    helpers.ToDecimal(data.Get("dealAmount")),
+		AveragePrice:    helpers.ToDecimal(data.Get("dealPriceAverage")),
+		Fee:             helpers.ToDecimal(data.Get("feeTotal")),
+		FeeCurrency:     trading,
+		CreatedAt:       millisToTime(data.Get("createdAt").Int()),
+	}
+	// fees are charged in the currency received
+	if data.Get("type").Str == "SELL" {
+		order.Type = models.Bid
+		order.FeeCurrency = settlement
+	}
+	pending := helpers.ToDecimal(data.Get("pendingAmount"))
+	order.Amount = order.FilledAmount.Add(pending)
+	switch {
+	case data.Get("isActive").Bool() && order.FilledAmount.IsPositive():
+		order.Status = models.OrderPartiallyFilled
+	case data.Get("isActive").Bool():
+		order.Status = models.OrderOpen
+	case pending.IsZero() && order.FilledAmount.IsPositive():
+		order.Status = models.OrderFilled
+	default:
+		order.Status = models.OrderCanceled
+	}
+	return order
+}
+
+const kucoinTradesPageSize = 20
+
+func (h *KucoinApi) MyTrades(trading string, settlement string, since time.Time, limit int) ([]*models.Trade, error) {
+	var trades []*models.Trade
+	for page := 1; ; page++ {
+		params := &url.Values{}
+		params.Set("symbol", kucoinSymbols.Symbol(trading, settlement))
+		params.Set("limit", strconv.Itoa(kucoinTradesPageSize))
+		params.Set("page", strconv.Itoa(page))
+		if !since.IsZero() {
+			params.Set("since", strconv.FormatInt(since.UnixNano()/int64(time.Millisecond), 10))
+		}
+		bs, err := h.privateApi("GET", "/v1/order/dealt", params)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to get trades of %s/%s", trading, settlement)
+		}
+		datas := gjson.ParseBytes(bs).Get("data.datas").Array()
+		for _, v := range datas {
+			trade := &models.Trade{
+				ID:          v.Get("oid").String(),
+				OrderID:     v.Get("orderOid").String(),
+				Trading:     trading,
+				Settlement:  settlement,
+				Type:        models.Ask,
+				Price:       helpers.ToDecimal(v.Get("dealPrice")),
+				Amount:      helpers.ToDecimal(v.Get("amount")),
+				Fee:         helpers.ToDecimal(v.Get("fee")),
+				FeeCurrency: trading,
+				Time:        millisToTime(v.Get("createdAt").Int()),
+			}
+			if v.Get("direction").Str == "SELL" {
+				trade.Type = models.Bid
+				trade.FeeCurrency = settlement
+			}
+			trades = append(trades, trade)
+		}
+		if len(datas) < kucoinTradesPageSize || (limit > 0 && len(trades) >= limit) {
+			break
+		}
+	}
+	return trimTrades(trades, since, limit), nil
+}
+
+func (h *KucoinApi) Deposits(since time.Time) ([]*models.Transfer, error) {
+	return h.transfers("/api/v1/deposits", since)
+}
+
+func (h *KucoinApi) Withdrawals(since time.Time) ([]*models.Transfer, error) {
+	return h.transfers("/api/v1/withdrawals", since)
+}
+
+var kucoinTransferStatus = map[string]models.TransferStatus{
+	"PROCESSING":        models.TransferPending,
+	"WALLET_PROCESSING": models.TransferPending,
+	"SUCCESS":           models.TransferCompleted,
+	"FAILURE":           models.TransferFailed,
+}
+
+const kucoinTransfersPageSize = 100
+
+func (h *KucoinApi) transfers(path string, since time.Time) ([]*models.Transfer, error) {
+	var transfers []*models.Transfer
+	for page := 1; ; page++ {
+		params := &url.Values{}
+		params.Set("currentPage", strconv.Itoa(page))
+		params.Set("pageSize", strconv.Itoa(kucoinTransfersPageSize))
+		if !since.IsZero() {
+			params.Set("startAt", strconv.FormatInt(since.UnixNano()/int64(time.Millisecond), 10))
+		}
+		bs, err := h.privateApi("GET", path, params)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to get transfers %s", path)
+		}
+		data := gjson.ParseBytes(bs).Get("data")
+		for _, v := range data.Get("items").Array() {
+			transfers = append(transfers, &models.Transfer{
+				ID:        v.Get("id").Str,
+				Currency:  kucoinSymbols.Currency(v.Get("currency").Str),
+				Amount:    helpers.ToDecimal(v.Get("amount")),
+				Fee:       helpers.ToDecimal(v.Get("fee")),
+				Address:   v.Get("address").Str,
+				Tag:       v.Get("memo").Str,
+				TxID:      v.Get("walletTxId").Str,
+				Network:   v.Get("chain").Str,
+				Status:    kucoinTransferStatus[v.Get("status").Str],
+				CreatedAt: millisToTime(v.Get("createdAt").Int()),
+				UpdatedAt: millisToTime(v.Get("updatedAt").Int()),
+			})
+		}
+		if int64(page) >= data.Get("totalPage").Int() {
+			break
+		}
+	}
+	return trimTransfers(transfers, since), nil
+}
+
 func (h *KucoinApi) Address(c string) (string, error) {
 	params := &url.Values{}
 	bs, err := h.privateApi("GET", fmt.Sprintf("/v1/account/%s/wallet/address", c), params)
@@ -0,0 +1,141 @@
+package private
+
+import (
+	"net/url"
+	"strconv"
+	"sync/atomic"
+	"time"
+
+	"github.com/fxpgr/go-exchange-client/helpers"
+	"github.com/fxpgr/go-exchange-client/logger"
+	"github.com/fxpgr/go-exchange-client/models"
+	"github.com/fxpgr/go-exchange-client/stream"
+	"github.com/pkg/errors"
+	"github.com/tidwall/gjson"
+)
+
+// kucoinUserTopics are the order updates and fills of every pair and the
+// balance changes of every currency.
+var kucoinUserTopics = []string{"/spotMarket/tradeOrders", "/account/balance"}
+
+// KucoinUserStream follows the private channels of Kucoin. Every dial asks
+// for a new token and the server to connect to, and the stream is pinged as
+// often as the server asks.
+type KucoinUserStream struct {
+	userEvents
+	Api  *KucoinApi
+	Conn *stream.Conn
+
+	nextID int64
+}
+
+func NewKucoinUserStream(api *KucoinApi) *KucoinUserStream {
+	s := &KucoinUserStream{Api: api}
+	s.Conn = stream.NewConn("", s.handle)
+	s.Conn.Prepare = s.prepare
+	s.Conn.Ping = func(c *stream.Conn) error {
+		return c.WriteJSON(map[string]string{"id": s.id(), "type": "ping"})
+	}
+	s.run = runConn(s.Conn)
+	return s
+}
+
+func (s *KucoinUserStream) id() string {
+	return strconv.FormatInt(atomic.AddInt64(&s.nextID, 1), 10)
+}
+
+func (s *KucoinUserStream) prepare(c *stream.Conn) error {
+	bs, err := s.Api.privateApi("POST", "/api/v1/bullet-private", &url.Values{})
+	if err != nil {
+		return errors.Wrap(err, "failed to get token")
+	}
+	data := gjson.ParseBytes(bs).Get("data")
+	server := data.Get("instanceServers.0")
+	if !server.Exists() {
+		return errors.New("no kucoin stream server")
+	}
+	c.URL = server.Get("endpoint").Str + "?" + url.Values{"token": {data.Get("token").Str}, "connectId": {s.id()}}.Encode()
+	if interval := server.Get("pingInterval").Int(); interval > 0 {
+		c.PingInterval = time.Duration(interval) * time.Millisecond
+	}
+	return nil
+}
+
+func (s *KucoinUserStream) handle(msg []byte) error {
+	value := gjson.ParseBytes(msg)
+	switch value.Get("type").Str {
+	case "welcome":
+		for _, topic := range kucoinUserTopics {
+			err := s.Conn.WriteJSON(map[string]interface{}{
+				"id":             s.id(),
+				"type":           "subscribe",
+				"topic":          topic,
+				"privateChannel": true,
+				"response":       true,
+			})
+			if err != nil {
+				return errors.Wrapf(err, "failed to subscribe %s", topic)
+			}
+		}
+	case "error":
+		logger.Get().Warnw("kucoin user stream request failed", "error", value.Get("data").String())
+	case "message":
+		data := value.Get("data")
+		switch value.Get("topic").Str {
+		case "/spotMarket/tradeOrders":
+			s.onOrder(data)
+		case "/account/balance":
+			s.publishBalance(kucoinSymbols.Currency(data.Get("currency").Str),
+				models.NewBalance(helpers.ToDecimal(data.Get("available")), helpers.ToDecimal(data.Get("hold"))),
+				millisToTime(data.Get("time").Int()))
+		}
+	}
+	return nil
+}
+
+func (s *KucoinUserStream) onOrder(data gjson.Result) {
+	pair, ok := kucoinSymbols.Pair(data.Get("symbol").Str)
+	if !ok {
+		logger.Get().Warnw("unknown kucoin symbol", "symbol", data.Get("symbol").Str)
+		return
+	}
+	order := &models.Order{
+		ExchangeOrderID: data.Get("orderId").Str,
+		Type:            models.Ask,
+		Trading:         pair.Trading,
+		Settlement:      pair.Settlement,
+		Price:           helpers.ToDecimal(data.Get("price")),
+		Amount:          helpers.ToDecimal(data.Get("size")),
+		FilledAmount:    helpers.ToDecimal(data.Get("filledSize")),
+		CreatedAt:       time.Unix(0, data.Get("orderTime").Int()),
+		UpdatedAt:       time.Unix(0, data.Get("ts").Int()),
+	}
+	if data.Get("side").Str == "sell" {
+		order.Type = models.Bid
+	}
+	switch data.Get("type").Str {
+	case "filled":
+		order.Status = models.OrderFilled
+	case "canceled":
+		order.Status = models.OrderCanceled
+	default:
+		order.Status = models.OrderOpen
+		if order.FilledAmount.IsPositive() {
+			order.Status = models.OrderPartiallyFilled
+		}
+	}
+	s.publishOrder(order)
+	if data.Get("type").Str != "match" {
+		return
+	}
+	s.publishFill(&models.Trade{
+		ID:         data.Get("tradeId").Str,
+		OrderID:    order.ExchangeOrderID,
+		Trading:    pair.Trading,
+		Settlement: pair.Settlement,
+		Type:       order.Type,
+		Price:      helpers.ToDecimal(data.Get("matchPrice")),
+		Amount:     helpers.ToDecimal(data.Get("matchSize")),
+		Time:       order.UpdatedAt,
+	})
+}
@@ -1,6 +1,7 @@
 package private
 
 import (
+	"context"
 	"io/ioutil"
 	"net/http"
 	"net/url"
@@ -8,11 +9,16 @@ import (
 	"time"
 
 	"bytes"
-	"fmt"
 	"github.com/antonholmquist/jason"
 	"github.com/fxpgr/go-exchange-client/api/public"
+	"github.com/fxpgr/go-exchange-client/apierrors"
+	"github.com/fxpgr/go-exchange-client/decimal"
+	"github.com/fxpgr/go-exchange-client/helpers"
 	"github.com/fxpgr/go-exchange-client/models"
+	"github.com/fxpgr/go-exchange-client/retry"
+	"github.com/fxpgr/go-exchange-client/symbols"
 	"github.com/pkg/errors"
+	"github.com/tidwall/gjson"
 	"strconv"
 	"strings"
 )
@@ -21,6 +27,8 @@ const (
 	LBANK_BASE_URL = "https://api.lbkex.com"
 )
 
+var lbankSymbols = symbols.For("lbank")
+
 func NewLbankApi(apikey func() (string, error), apisecret func() (string, error)) (*LbankApi, error) {
 	hitbtcPublic, err := public.NewLbankPublicApi()
 	if err != nil {
@@ -44,6 +52,7 @@ func NewLbankApi(apikey func() (string, error), apisecret func() (string, error)
 	}
 
 	return &LbankApi{
+		HttpClient:        http.Client{Transport: newTransport("lbank", nil)},
 		BaseURL:           LBANK_BASE_URL,
 		RateCacheDuration: 30 * time.Second,
 		ApiKeyFunc:        apikey,
@@ -64,8 +73,9 @@ type LbankApi struct {
 	BaseURL           string
 	RateCacheDuration time.Duration
 	HttpClient        http.Client
-	rt                *http.Transport
-	settlements       []string
+	clientContext
+	rt          *http.Transport
+	settlements []string
 
 	volumeMap       map[string]map[string]float64
 	rateMap         map[string]map[string]float64
@@ -78,6 +88,12 @@ func (h *LbankApi) privateApiUrl() string {
 	return h.BaseURL
 }
 
+func (h *LbankApi) WithContext(ctx context.Context) PrivateClient {
+	c := *h
+	c.ctx = ctx
+	return &c
+}
+
 func (h *LbankApi) privateApi(method string, path string, params *url.Values) ([]byte, error) {
 
 	apiKey, err := h.ApiKeyFunc()
@@ -99,8 +115,12 @@ func (h *LbankApi) privateApi(method string, path string, params *url.Values) ([
 		urlStr = urlStr + "?" + params.Encode()
 	}
 
+	ctx := h.context()
+	if path == "/v1/user_info.do" || path == "/v1/orders_info.do" {
+		ctx = retry.Idempotent(ctx)
+	}
 	reader := bytes.NewReader([]byte(params.Encode()))
-	req, err := http.NewRequest(method, urlStr, reader)
+	req, err := http.NewRequestWithContext(ctx, method, urlStr, reader)
 	if err != nil {
 		return nil, errors.Wrapf(err, "failed to create request command %s", path)
 	}
@@ -117,22 +137,43 @@ func (h *LbankApi) privateApi(method string, path string, params *url.Values) ([
 	if err != nil {
 		return nil, errors.Wrapf(err, "failed to fetch result of command %s", path)
 	}
+	if err := lbankError(res.StatusCode, resBody); err != nil {
+		return nil, errors.Wrapf(err, "failed to request command %s", path)
+	}
 	return resBody, err
 }
 
+var lbankErrorCodes = map[string]error{
+	"10004": apierrors.ErrRateLimited,
+	"10005": apierrors.ErrAuth,
+	"10007": apierrors.ErrAuth,
+	"10008": apierrors.ErrInvalidSymbol,
+	"10014": apierrors.ErrInsufficientFunds,
+	"10016": apierrors.ErrInsufficientFunds,
+}
+
+func lbankError(statusCode int, body []byte) error {
+	value := gjson.ParseBytes(body)
+	if statusCode == http.StatusOK && value.Get("result").String() != "false" {
+		return nil
+	}
+	code := value.Get("error_code").String()
+	return apiError(statusCode, code, string(body), lbankErrorCodes[code])
+}
+
 func (h *LbankApi) TradeFeeRates() (map[string]map[string]TradeFee, error) {
 	cli, err := public.NewClient("lbank")
 	if err != nil {
 		return nil, err
 	}
-	pairs, err := cli.CurrencyPairs()
+	pairs, err := cli.WithContext(h.context()).CurrencyPairs()
 	if err != nil {
 		return nil, err
 	}
 	traderFeeMap := make(map[string]map[string]TradeFee)
 	for _, p := range pairs {
 		n := make(map[string]TradeFee)
-		n[p.Settlement] = TradeFee{0.001, 0.001}
+		n[p.Settlement] = TradeFee{decimal.New(1, -3), decimal.New(1, -3)}
 		traderFeeMap[p.Trading] = n
 	}
 	return traderFeeMap, nil
@@ -171,7 +212,7 @@ func (sm *lbankTransferFeeSyncMap) GetAll() map[string]float64 {
 
 func (h *LbankApi) TransferFee() (map[string]float64, error) {
 	url := LBANK_BASE_URL + "/v1/withdrawConfigs.do"
-	resp, err := h.HttpClient.Get(url)
+	resp, err := httpGet(h.context(), &h.HttpClient, url)
 	transferFeeMap := lbankTransferFeeSyncMap{make(lbankTransferFeeMap), new(sync.Mutex)}
 	if err != nil {
 		return transferFeeMap.GetAll(), errors.Wrapf(err, "failed to fetch %s", url)
@@ -207,7 +248,7 @@ func (h *LbankApi) TransferFee() (map[string]float64, error) {
 		if err != nil {
 			continue
 		}
-		transferFeeMap.Set(strings.ToUpper(currency), feef)
+		transferFeeMap.Set(lbankSymbols.Currency(currency), feef)
 	}
 	return transferFeeMap.GetAll(), nil
 }
@@ -236,7 +277,7 @@ func (h *LbankApi) Balances() (map[string]float64, error) {
 		if err != nil {
 			return nil, errors.Wrapf(err, "failed to parse json key list1")
 		}
-		currency = strings.ToUpper(currency)
+		currency = lbankSymbols.Currency(currency)
 		m[currency] = available
 	}
 	return m, nil
@@ -271,25 +312,25 @@ func (h *LbankApi) CompleteBalances() (map[string]*models.Balance, error) {
 	}
 	m := make(map[string]*models.Balance)
 	for currency, v := range frees.Map() {
-		available, err := v.Float64()
+		available, err := v.Number()
 		if err != nil {
 			return nil, errors.Wrapf(err, "failed to parse json key list 1")
 		}
 
-		currency = strings.ToUpper(currency)
-		m[currency] = &models.Balance{Available: available}
+		currency = lbankSymbols.Currency(currency)
+		m[currency] = &models.Balance{Available: helpers.ToDecimal(available)}
 	}
 	for currency, v := range frozens.Map() {
-		frozen, err := v.Float64()
+		frozen, err := v.Number()
 		if err != nil {
 			return nil, errors.Wrapf(err, "failed to parse json key list 3")
 		}
-		currency = strings.ToUpper(currency)
+		currency = lbankSymbols.Currency(currency)
 		_, ok := m[currency]
 		if ok {
-			m[currency].OnOrders = frozen
+			m[currency].OnOrders = helpers.ToDecimal(frozen)
 		} else {
-			m[currency] = &models.Balance{OnOrders: frozen}
+			m[currency] = &models.Balance{OnOrders: helpers.ToDecimal(frozen)}
 		}
 	}
 	return m, nil
@@ -319,30 +360,30 @@ func (h *LbankApi) CompleteBalance(coin string) (*models.Balance, error) {
 	}
 	m := make(map[string]*models.Balance)
 	for currency, v := range frees.Map() {
-		if strings.ToUpper(currency) != coin {
+		if lbankSymbols.Currency(currency) != coin {
 			continue
 		}
-		available, err := v.Float64()
+		available, err := v.Number()
 		if err != nil {
 			return nil, errors.Wrapf(err, "failed to parse json key list 1")
 		}
-		currency = strings.ToUpper(currency)
-		m[currency] = &models.Balance{Available: available}
+		currency = lbankSymbols.Currency(currency)
+		m[currency] = &models.Balance{Available: helpers.ToDecimal(available)}
 	}
 	for currency, v := range frozens.Map() {
-		if strings.ToUpper(currency) != coin {
+		if lbankSymbols.Currency(currency) != coin {
 			continue
 		}
-		frozen, err := v.Float64()
+		frozen, err := v.Number()
 		if err != nil {
 			return nil, errors.Wrapf(err, "failed to parse json key list 3")
 		}
-		currency = strings.ToUpper(currency)
+		currency = lbankSymbols.Currency(currency)
 		_, ok := m[currency]
 		if ok {
-			m[currency].OnOrders = frozen
+			m[currency].OnOrders = helpers.ToDecimal(frozen)
 		} else {
-			m[currency] = &models.Balance{OnOrders: frozen}
+			m[currency] = &models.Balance{OnOrders: helpers.ToDecimal(frozen)}
 		}
 	}
 	return m[coin], nil
@@ -360,19 +401,40 @@ func (h *LbankApi) ActiveOrders() ([]*models.Order, error) {
 }
 
 func (h *LbankApi) Order(trading string, settlement string, ordertype models.OrderType, price float64, amount float64) (string, error) {
+	return h.PlaceOrder(models.OrderRequest{Trading: trading, Settlement: settlement, Type: ordertype, Price: decimal.NewFromFloat(price), Amount: decimal.NewFromFloat(amount)})
+}
+
+func (h *LbankApi) PlaceOrder(req models.OrderRequest) (string, error) {
 	params := &url.Values{}
-	if ordertype == models.Ask {
-		params.Set("type", "buy")
-	} else if ordertype == models.Bid {
-		params.Set("type", "sell")
+	var side string
+	if req.Type == models.Ask {
+		side = "buy"
+	} else if req.Type == models.Bid {
+		side = "sell"
 	} else {
-		return "", errors.Errorf("unknown order type %d", ordertype)
+		return "", errors.Errorf("unknown order type %d", req.Type)
+	}
+	params.Set("symbol", lbankSymbols.Symbol(req.Trading, req.Settlement))
+	switch {
+	case req.ExecutionType == models.Market && req.TimeInForce == models.GTC && req.Type == models.Ask:
+		// market buys take the total in the settlement currency as price
+		if !req.Price.IsPositive() {
+			return "", errors.New("market buy needs a reference price to be sized")
+		}
+		params.Set("type", "buy_market")
+		params.Set("price", FloorDecimalToStr(req.Amount.Mul(req.Price), 4))
+	case req.ExecutionType == models.Market && req.TimeInForce == models.GTC:
+		params.Set("type", "sell_market")
+		params.Set("amount", FloorDecimalToStr(req.Amount, 4))
+	case req.ExecutionType == models.Limit && req.TimeInForce == models.GTC:
+		params.Set("type", side)
+		amountStr := FloorDecimalToStr(req.Amount, 4)
+		priceStr := FloorDecimalToStr(req.Price, 4)
+		params.Set("amount", amountStr)
+		params.Set("price", priceStr)
+	default:
+		return "", unsupportedOrder("lbank", req)
 	}
-	params.Set("symbol", strings.ToLower(fmt.Sprintf("%s_%s", trading, settlement)))
-	amountStr := strconv.FormatFloat(amount, 'f', 4, 64)
-	priceStr := strconv.FormatFloat(price, 'f', 4, 64)
-	params.Set("amount", amountStr)
-	params.Set("price", priceStr)
 	byteArray, err := h.privateApi("POST", "/v1/create_order.do", params)
 	if err != nil {
 		return "", err
@@ -434,6 +496,114 @@ func (h *LbankApi) IsOrderFilled(trading string, settlement string, orderNumber
 	return false, nil
 }
 
+func (h *LbankApi) OrderStatus(trading string, settlement string, orderNumber string) (*models.Order, error) {
+	params := &url.Values{}
+	params.Set("order_id", orderNumber)
+	params.Set("symbol", lbankSymbols.Symbol(trading, settlement))
+	bs, err := h.privateApi("POST", "/v1/orders_info.do", params)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to get order %s", orderNumber)
+	}
+	orders := gjson.ParseBytes(bs).Get("orders").Array()
+	if len(orders) == 0 {
+		return nil, errors.Wrapf(apierrors.ErrOrderNotFound, "order %s", orderNumber)
+	}
+	v := orders[0]
+	// lbank does not report the fees of an order
+	order := &models.Order{
+		ExchangeOrderID: orderNumber,
+		Type:            models.Ask,
+		Trading:         trading,
+		Settlement:      settlement,
+		Price:           helpers.ToDecimal(v.Get("price")),
+		Amount:          helpers.ToDecimal(v.Get("amount")),
+		FilledAmount:    helpers.ToDecimal(v.Get("deal_amount")),
+		AveragePrice:    helpers.ToDecimal(v.Get("avg_price")),
+		CreatedAt:       millisToTime(v.Get("create_time").Int()),
+	}
+	if strings.HasPrefix(v.Get("type").Str, "sell") {
+		order.Type = models.Bid
+	}
+	switch v.Get("status").Int() {
+	case -1:
+		order.Status = models.OrderCanceled
+	case 0:
+		order.Status = models.OrderOpen
+	case 1, 4:
+		order.Status = models.OrderPartiallyFilled
+		if order.FilledAmount.IsZero() {
+			order.Status = models.OrderOpen
+		}
+	case 2:
+		order.Status = models.OrderFilled
+	}
+	return order, nil
+}
+
+const lbankTradesPageSize = 100
+
+func (h *LbankApi) MyTrades(trading string, settlement string, since time.Time, limit int) ([]*models.Trade, error) {
+	var trades []*models.Trade
+	seen := make(map[string]bool)
+	fromID := ""
+	for {
+		params := &url.Values{}
+		params.Set("symbol", lbankSymbols.Symbol(trading, settlement))
+		params.Set("limit", strconv.Itoa(lbankTradesPageSize))
+		if !since.IsZero() {
+			params.Set("startTime", since.UTC().Format("2006-01-02"))
+		}
+		if fromID != "" {
+			params.Set("fromId", fromID)
+		}
+		bs, err := h.privateApi("POST", "/v2/transaction_history.do", params)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to get trades of %s/%s", trading, settlement)
+		}
+		page := gjson.ParseBytes(bs).Get("data").Array()
+		added := 0
+		for _, v := range page {
+			id := v.Get("txUuid").String()
+			if seen[id] {
+				continue
+			}
+			seen[id] = true
+			added++
+			trade := &models.Trade{
+				ID:          id,
+				OrderID:     v.Get("orderUuid").String(),
+				Trading:     trading,
+				Settlement:  settlement,
+				Type:        models.Ask,
+				Price:       helpers.ToDecimal(v.Get("dealPrice")),
+				Amount:      helpers.ToDecimal(v.Get("dealQuantity")),
+				Fee:         helpers.ToDecimal(v.Get("tradeFee")),
+				FeeCurrency: trading,
+				Time:        millisToTime(v.Get("dealTime").Int()),
+			}
+			if strings.HasPrefix(v.Get("tradeType").Str, "sell") {
+				trade.Type = models.Bid
+				trade.FeeCurrency = settlement
+			}
+			trades = append(trades, trade)
+			fromID = id
+		}
+		// fromId is inclusive, so a page without new trades is the last one
+		if len(page) < lbankTradesPageSize || added == 0 || (limit > 0 && len(trades) >= limit) {
+			break
+		}
+	}
+	return trimTrades(trades, since, limit), nil
+}
+
+func (h *LbankApi) Deposits(since time.Time) ([]*models.Transfer, error) {
+	return nil, errors.Wrap(apierrors.ErrUnsupported, "deposit history on lbank")
+}
+
+func (h *LbankApi) Withdrawals(since time.Time) ([]*models.Transfer, error) {
+	return nil, errors.Wrap(apierrors.ErrUnsupported, "withdrawal history on lbank")
+}
+
 func (h *LbankApi) Address(c string) (string, error) {
 	return "", errors.New("not implemented")
 }
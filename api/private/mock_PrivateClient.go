@@ -1,8 +1,10 @@
 // Code generated by mockery v1.0.0
 package private
 
+import context "context"
 import mock "github.com/stretchr/testify/mock"
 import models "github.com/fxpgr/go-exchange-client/models"
+import time "time"
 
 // MockPrivateClient is an autogenerated mock type for the PrivateClient type
 type MockPrivateClient struct {
@@ -136,6 +138,29 @@ func (_m *MockPrivateClient) CompleteBalances() (map[string]*models.Balance, err
 	return r0, r1
 }
 
+// Deposits provides a mock function with given fields: since
+func (_m *MockPrivateClient) Deposits(since time.Time) ([]*models.Transfer, error) {
+	ret := _m.Called(since)
+
+	var r0 []*models.Transfer
+	if rf, ok := ret.Get(0).(func(time.Time) []*models.Transfer); ok {
+		r0 = rf(since)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]*models.Transfer)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(time.Time) error); ok {
+		r1 = rf(since)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
 // IsOrderFilled provides a mock function with given fields: trading, settlement, orderNumber
 func (_m *MockPrivateClient) IsOrderFilled(trading string, settlement string, orderNumber string) (bool, error) {
 	ret := _m.Called(trading, settlement, orderNumber)
@@ -157,6 +182,29 @@ func (_m *MockPrivateClient) IsOrderFilled(trading string, settlement string, or
 	return r0, r1
 }
 
+// MyTrades provides a mock function with given fields: trading, settlement, since, limit
+func (_m *MockPrivateClient) MyTrades(trading string, settlement string, since time.Time, limit int) ([]*models.Trade, error) {
+	ret := _m.Called(trading, settlement, since, limit)
+
+	var r0 []*models.Trade
+	if rf, ok := ret.Get(0).(func(string, string, time.Time, int) []*models.Trade); ok {
+		r0 = rf(trading, settlement, since, limit)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]*models.Trade)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(string, string, time.Time, int) error); ok {
+		r1 = rf(trading, settlement, since, limit)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
 // Order provides a mock function with given fields: trading, settlement, ordertype, price, amount
 func (_m *MockPrivateClient) Order(trading string, settlement string, ordertype models.OrderType, price float64, amount float64) (string, error) {
 	ret := _m.Called(trading, settlement, ordertype, price, amount)
@@ -178,6 +226,50 @@ func (_m *MockPrivateClient) Order(trading string, settlement string, ordertype
 	return r0, r1
 }
 
+// OrderStatus provides a mock function with given fields: trading, settlement, orderNumber
+func (_m *MockPrivateClient) OrderStatus(trading string, settlement string, orderNumber string) (*models.Order, error) {
+	ret := _m.Called(trading, settlement, orderNumber)
+
+	var r0 *models.Order
+	if rf, ok := ret.Get(0).(func(string, string, string) *models.Order); ok {
+		r0 = rf(trading, settlement, orderNumber)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*models.Order)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(string, string, string) error); ok {
+		r1 = rf(trading, settlement, orderNumber)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// PlaceOrder provides a mock function with given fields: req
+func (_m *MockPrivateClient) PlaceOrder(req models.OrderRequest) (string, error) {
+	ret := _m.Called(req)
+
+	var r0 string
+	if rf, ok := ret.Get(0).(func(models.OrderRequest) string); ok {
+		r0 = rf(req)
+	} else {
+		r0 = ret.Get(0).(string)
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(models.OrderRequest) error); ok {
+		r1 = rf(req)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
 // TradeFeeRate provides a mock function with given fields: _a0, _a1
 func (_m *MockPrivateClient) TradeFeeRate(_a0 string, _a1 string) (TradeFee, error) {
 	ret := _m.Called(_a0, _a1)
@@ -258,3 +350,42 @@ func (_m *MockPrivateClient) TransferFee() (map[string]float64, error) {
 
 	return r0, r1
 }
+
+// WithContext provides a mock function with given fields: ctx
+func (_m *MockPrivateClient) WithContext(ctx context.Context) PrivateClient {
+	ret := _m.Called(ctx)
+
+	var r0 PrivateClient
+	if rf, ok := ret.Get(0).(func(context.Context) PrivateClient); ok {
+		r0 = rf(ctx)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(PrivateClient)
+		}
+	}
+
+	return r0
+}
+
+// Withdrawals provides a mock function with given fields: since
+func (_m *MockPrivateClient) Withdrawals(since time.Time) ([]*models.Transfer, error) {
+	ret := _m.Called(since)
+
+	var r0 []*models.Transfer
+	if rf, ok := ret.Get(0).(func(time.Time) []*models.Transfer); ok {
+		r0 = rf(since)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]*models.Transfer)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(time.Time) error); ok {
+		r1 = rf(since)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
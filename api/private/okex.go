@@ -1,6 +1,7 @@
 package private
 
 import (
+	"context"
 	"io/ioutil"
 	"net/http"
 	"net/url"
@@ -10,7 +11,11 @@ import (
 	"fmt"
 	"github.com/antonholmquist/jason"
 	"github.com/fxpgr/go-exchange-client/api/public"
+	"github.com/fxpgr/go-exchange-client/decimal"
+	"github.com/fxpgr/go-exchange-client/helpers"
 	"github.com/fxpgr/go-exchange-client/models"
+	"github.com/fxpgr/go-exchange-client/retry"
+	"github.com/fxpgr/go-exchange-client/symbols"
 	"github.com/pkg/errors"
 	"strconv"
 	"strings"
@@ -20,6 +25,8 @@ const (
 	OKEX_BASE_URL = "https://www.okex.com"
 )
 
+var okexSymbols = symbols.For("okex")
+
 func NewOkexApi(apikey func() (string, error), apisecret func() (string, error)) (*OkexApi, error) {
 	hitbtcPublic, err := public.NewOkexPublicApi()
 	if err != nil {
@@ -43,6 +50,7 @@ func NewOkexApi(apikey func() (string, error), apisecret func() (string, error))
 	}
 
 	return &OkexApi{
+		HttpClient:        http.Client{Transport: newTransport("okex", nil)},
 		BaseURL:           OKEX_BASE_URL,
 		RateCacheDuration: 30 * time.Second,
 		ApiKeyFunc:        apikey,
@@ -63,8 +71,9 @@ type OkexApi struct {
 	BaseURL           string
 	RateCacheDuration time.Duration
 	HttpClient        http.Client
-	rt                *http.Transport
-	settlements       []string
+	clientContext
+	rt          *http.Transport
+	settlements []string
 
 	volumeMap       map[string]map[string]float64
 	rateMap         map[string]map[string]float64
@@ -77,6 +86,12 @@ func (o *OkexApi) privateApiUrl() string {
 	return o.BaseURL
 }
 
+func (o *OkexApi) WithContext(ctx context.Context) PrivateClient {
+	c := *o
+	c.ctx = ctx
+	return &c
+}
+
 func (o *OkexApi) privateApi(method string, path string, params *url.Values) ([]byte, error) {
 
 	apiKey, err := o.ApiKeyFunc()
@@ -96,8 +111,19 @@ func (o *OkexApi) privateApi(method string, path string, params *url.Values) ([]
 	sign, _ := GetParamHmacSHA256Base64Sign(secretKey, payload)
 	params.Set("Signature", sign)
 	urlStr := o.BaseURL + path + "?" + params.Encode()
-	resBody, err := NewHttpRequest(&http.Client{}, method, urlStr, "", nil)
-	return resBody, err
+	ctx := o.context()
+	if isPlaceOrWithdraw(path) && params.Get("client-order-id") == "" {
+		ctx = retry.WithPolicy(ctx, retry.Policy{MaxAttempts: 1})
+	}
+	resBody, err := helpers.NewHttpRequestWithContext(ctx, &o.HttpClient, method, urlStr, "", nil)
+	if err != nil {
+		return nil, err
+	}
+	// the v1 endpoints report failures in the same shape as huobi
+	if err := huobiError(resBody); err != nil {
+		return nil, errors.Wrapf(err, "failed to request command %s", path)
+	}
+	return resBody, nil
 }
 
 func (o *OkexApi) TradeFeeRates() (map[string]map[string]TradeFee, error) {
@@ -105,14 +131,14 @@ func (o *OkexApi) TradeFeeRates() (map[string]map[string]TradeFee, error) {
 	if err != nil {
 		return nil, err
 	}
-	pairs, err := cli.CurrencyPairs()
+	pairs, err := cli.WithContext(o.context()).CurrencyPairs()
 	if err != nil {
 		return nil, err
 	}
 	traderFeeMap := make(map[string]map[string]TradeFee)
 	for _, p := range pairs {
 		n := make(map[string]TradeFee)
-		n[p.Settlement] = TradeFee{-0.001, 0.001}
+		n[p.Settlement] = TradeFee{decimal.New(-1, -3), decimal.New(1, -3)}
 		traderFeeMap[p.Trading] = n
 	}
 	return traderFeeMap, nil
@@ -154,7 +180,7 @@ func (o *OkexApi) TransferFee() (map[string]float64, error) {
 	if err != nil {
 		return nil, err
 	}
-	currencies, err := cli.FrozenCurrency()
+	currencies, err := cli.WithContext(o.context()).FrozenCurrency()
 	if err != nil {
 		return nil, err
 	}
@@ -167,10 +193,10 @@ func (o *OkexApi) TransferFee() (map[string]float64, error) {
 		go func(currency string) {
 			defer wg.Done()
 			args := url.Values{}
-			args.Add("currency", strings.ToLower(currency))
+			args.Add("currency", okexSymbols.Native(currency))
 			url := o.BaseURL + "/v1/dw/withdraw-virtual/fee-range?" + args.Encode()
-			cli := &http.Client{Transport: o.rt}
-			resp, err := cli.Get(url)
+			cli := &http.Client{Transport: newTransport("okex", o.rt)}
+			resp, err := httpGet(o.context(), cli, url)
 			if err != nil {
 				ch <- &OkexTransferFeeResponse{nil, currency, err}
 				return
@@ -236,6 +262,7 @@ func (o *OkexApi) Balances() (map[string]float64, error) {
 		if err != nil {
 			continue
 		}
+		currency = okexSymbols.Currency(currency)
 		t, err := v.GetString("type")
 		if err != nil {
 			continue
@@ -311,12 +338,13 @@ func (o *OkexApi) CompleteBalances() (map[string]*models.Balance, error) {
 	m := make(map[string]*models.Balance)
 	var previousCurrency string
 	previousBalance := &models.Balance{}
-	var available float64
+	var available decimal.Decimal
 	for _, v := range balances {
 		currency, err := v.GetString("currency")
 		if err != nil {
 			continue
 		}
+		currency = okexSymbols.Currency(currency)
 		t, err := v.GetString("type")
 		if err != nil {
 			continue
@@ -325,7 +353,7 @@ func (o *OkexApi) CompleteBalances() (map[string]*models.Balance, error) {
 		if err != nil {
 			continue
 		}
-		available, err = strconv.ParseFloat(availableStr, 10)
+		available, err = decimal.NewFromString(availableStr)
 		if err != nil {
 			return nil, err
 		}
@@ -370,12 +398,13 @@ func (o *OkexApi) CompleteBalance(coin string) (*models.Balance, error) {
 	m := make(map[string]*models.Balance)
 	var previousCurrency string
 	previousBalance := &models.Balance{}
-	var available float64
+	var available decimal.Decimal
 	for _, v := range balances {
 		currency, err := v.GetString("currency")
 		if err != nil {
 			continue
 		}
+		currency = okexSymbols.Currency(currency)
 		t, err := v.GetString("type")
 		if err != nil {
 			continue
@@ -384,7 +413,7 @@ func (o *OkexApi) CompleteBalance(coin string) (*models.Balance, error) {
 		if err != nil {
 			continue
 		}
-		available, err = strconv.ParseFloat(availableStr, 10)
+		available, err = decimal.NewFromString(availableStr)
 		if err != nil {
 			return nil, err
 		}
@@ -415,24 +444,57 @@ func (o *OkexApi) ActiveOrders() ([]*models.Order, error) {
 }
 
 func (o *OkexApi) Order(trading string, settlement string, ordertype models.OrderType, price float64, amount float64) (string, error) {
+	return o.PlaceOrder(models.OrderRequest{Trading: trading, Settlement: settlement, Type: ordertype, Price: decimal.NewFromFloat(price), Amount: decimal.NewFromFloat(amount)})
+}
+
+func (o *OkexApi) PlaceOrder(req models.OrderRequest) (string, error) {
 	accountId, err := o.getAccountId()
 	if err != nil {
 		return "", err
 	}
 	params := &url.Values{}
-	if ordertype == models.Ask {
-		params.Set("type", "buy-limit")
-	} else if ordertype == models.Bid {
-		params.Set("type", "sell-limit")
+	var side string
+	if req.Type == models.Ask {
+		side = "buy"
+	} else if req.Type == models.Bid {
+		side = "sell"
 	} else {
-		return "", errors.Errorf("unknown order type %d", ordertype)
-	}
-	params.Set("symbol", strings.ToLower(fmt.Sprintf("%s%s", trading, settlement)))
+		return "", errors.Errorf("unknown order type %d", req.Type)
+	}
+	amount := req.Amount
+	switch {
+	case req.ExecutionType == models.Market && req.TimeInForce == models.GTC:
+		params.Set("type", side+"-market")
+		if req.Type == models.Ask {
+			// market buys are sized in the settlement currency
+			if !req.Price.IsPositive() {
+				return "", errors.New("market buy needs a reference price to be sized")
+			}
+			amount = req.Amount.Mul(req.Price)
+		}
+	case req.ExecutionType == models.Limit && req.TimeInForce == models.GTC:
+		params.Set("type", side+"-limit")
+	case req.ExecutionType == models.Limit && req.TimeInForce == models.IOC:
+		params.Set("type", side+"-ioc")
+	case req.ExecutionType == models.Limit && req.TimeInForce == models.FOK:
+		params.Set("type", side+"-limit-fok")
+	case req.ExecutionType == models.Limit && req.TimeInForce == models.PostOnly:
+		params.Set("type", side+"-limit-maker")
+	default:
+		return "", unsupportedOrder("okex", req)
+	}
+	// the signed v1 endpoints join the codes without a separator
+	params.Set("symbol", okexSymbols.Native(req.Trading)+okexSymbols.Native(req.Settlement))
 	params.Set("account-id", accountId)
-	amountStr := strconv.FormatFloat(amount, 'f', 4, 64)
-	priceStr := strconv.FormatFloat(price, 'f', 4, 64)
+	amountStr := FloorDecimalToStr(amount, 4)
 	params.Set("amount", amountStr)
-	params.Set("price", priceStr)
+	if req.ExecutionType == models.Limit {
+		priceStr := FloorDecimalToStr(req.Price, 4)
+		params.Set("price", priceStr)
+	}
+	if id := retry.ClientOrderID(o.context()); id != "" {
+		params.Set("client-order-id", id)
+	}
 	byteArray, err := o.privateApi("GET", "/v1/order/orders/place", params)
 	if err != nil {
 		return "", err
@@ -454,7 +516,7 @@ func (o *OkexApi) Transfer(typ string, addr string, amount float64, additionalFe
 	additionalFeeStr := strconv.FormatFloat(additionalFee, 'f', 4, 64)
 	params.Set("address", addr)
 	params.Set("amount", amountStr)
-	params.Set("currency", typ)
+	params.Set("currency", okexSymbols.Native(typ))
 	params.Set("fee", additionalFeeStr)
 	_, err := o.privateApi("GET", "/v1/dw/withdraw/api/create", params)
 	return err
@@ -496,9 +558,29 @@ func (o *OkexApi) IsOrderFilled(trading string, settlement string, orderNumber s
 	return false, nil
 }
 
+func (o *OkexApi) OrderStatus(trading string, settlement string, orderNumber string) (*models.Order, error) {
+	bs, err := o.privateApi("GET", "/v1/order/orders/"+orderNumber, &url.Values{})
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to get order %s", orderNumber)
+	}
+	return parseHuobiOrder(bs, trading, settlement, orderNumber), nil
+}
+
+func (o *OkexApi) MyTrades(trading string, settlement string, since time.Time, limit int) ([]*models.Trade, error) {
+	return huobiMatchResults(o.privateApi, trading, settlement, since, limit)
+}
+
+func (o *OkexApi) Deposits(since time.Time) ([]*models.Transfer, error) {
+	return huobiDepositWithdraw(o.privateApi, "deposit", since)
+}
+
+func (o *OkexApi) Withdrawals(since time.Time) ([]*models.Transfer, error) {
+	return huobiDepositWithdraw(o.privateApi, "withdraw", since)
+}
+
 func (o *OkexApi) Address(c string) (string, error) {
 	params := &url.Values{}
-	params.Set("currency", strings.ToLower(c))
+	params.Set("currency", okexSymbols.Native(c))
 	params.Set("type", "deposit")
 
 	bs, err := o.privateApi("GET", "/v1/dw/deposit-virtual/addresses", params)
@@ -0,0 +1,175 @@
+package private
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/fxpgr/go-exchange-client/helpers"
+	"github.com/fxpgr/go-exchange-client/logger"
+	"github.com/fxpgr/go-exchange-client/models"
+	"github.com/fxpgr/go-exchange-client/stream"
+	"github.com/pkg/errors"
+	"github.com/tidwall/gjson"
+)
+
+const (
+	OKEX_USER_STREAM_URL = "wss://real.okex.com:8443/ws/v3"
+)
+
+var okexOrderStatus = map[string]models.OrderStatus{
+	"-2": models.OrderRejected,
+	"-1": models.OrderCanceled,
+	"0":  models.OrderOpen,
+	"1":  models.OrderPartiallyFilled,
+	"2":  models.OrderFilled,
+	"3":  models.OrderOpen,
+	"4":  models.OrderOpen,
+}
+
+// OkexUserStream follows the orders of Pairs and the balances of their
+// currencies over the v3 WebSocket API of OKEx, which subscribes to them one
+// by one. It logs in with the passphrase of the API key after every dial.
+type OkexUserStream struct {
+	userEvents
+	Api            *OkexApi
+	Conn           *stream.Conn
+	PassphraseFunc func() (string, error)
+	Pairs          []models.CurrencyPair
+
+	// loggedIn is only used by the goroutine of Conn
+	loggedIn bool
+}
+
+func NewOkexUserStream(api *OkexApi, passphrase func() (string, error), pairs []models.CurrencyPair) *OkexUserStream {
+	s := &OkexUserStream{Api: api, PassphraseFunc: passphrase, Pairs: pairs}
+	s.Conn = stream.NewConn(OKEX_USER_STREAM_URL, s.handle)
+	s.Conn.Decode = stream.Inflate
+	s.Conn.OnConnect = s.login
+	s.Conn.Ping = func(c *stream.Conn) error {
+		return c.WriteText([]byte("ping"))
+	}
+	s.run = runConn(s.Conn)
+	return s
+}
+
+func okexInstrumentID(pair models.CurrencyPair) string {
+	return strings.ToUpper(okexSymbols.Native(pair.Trading) + "-" + okexSymbols.Native(pair.Settlement))
+}
+
+func (s *OkexUserStream) login(c *stream.Conn) error {
+	s.loggedIn = false
+	apiKey, err := s.Api.ApiKeyFunc()
+	if err != nil {
+		return errors.Wrap(err, "failed to log in")
+	}
+	secretKey, err := s.Api.SecretKeyFunc()
+	if err != nil {
+		return errors.Wrap(err, "failed to log in")
+	}
+	passphrase, err := s.PassphraseFunc()
+	if err != nil {
+		return errors.Wrap(err, "failed to log in")
+	}
+	timestamp := fmt.Sprintf("%.3f", float64(time.Now().UnixNano())/1e9)
+	sign, _ := GetParamHmacSHA256Base64Sign(secretKey, timestamp+"GET/users/self/verify")
+	return c.WriteJSON(map[string]interface{}{
+		"op":   "login",
+		"args": []string{apiKey, passphrase, timestamp, sign},
+	})
+}
+
+// channels are the order channels of Pairs and the account channels of their
+// currencies.
+func (s *OkexUserStream) channels() []string {
+	var channels []string
+	currencies := make(map[string]bool)
+	for _, pair := range s.Pairs {
+		channels = append(channels, "spot/order:"+okexInstrumentID(pair))
+		for _, c := range []string{pair.Trading, pair.Settlement} {
+			if !currencies[c] {
+				currencies[c] = true
+				channels = append(channels, "spot/account:"+strings.ToUpper(okexSymbols.Native(c)))
+			}
+		}
+	}
+	return channels
+}
+
+func (s *OkexUserStream) handle(msg []byte) error {
+	if string(msg) == "pong" {
+		return nil
+	}
+	value := gjson.ParseBytes(msg)
+	switch value.Get("event").Str {
+	case "login":
+		if !value.Get("success").Bool() {
+			return authError("", "okex login refused")
+		}
+		s.loggedIn = true
+		return s.Conn.WriteJSON(map[string]interface{}{"op": "subscribe", "args": s.channels()})
+	case "error":
+		if !s.loggedIn {
+			return authError(value.Get("errorCode").String(), value.Get("message").Str)
+		}
+		logger.Get().Warnw("okex user stream request failed", "error", value.Get("message").Str)
+		return nil
+	}
+	switch value.Get("table").Str {
+	case "spot/order":
+		for _, data := range value.Get("data").Array() {
+			s.onOrder(data)
+		}
+	case "spot/account":
+		for _, data := range value.Get("data").Array() {
+			s.publishBalance(okexSymbols.Currency(data.Get("currency").Str),
+				models.NewBalance(helpers.ToDecimal(data.Get("available")), helpers.ToDecimal(data.Get("hold"))), time.Now())
+		}
+	}
+	return nil
+}
+
+func (s *OkexUserStream) onOrder(data gjson.Result) {
+	xs := strings.Split(data.Get("instrument_id").Str, "-")
+	if len(xs) != 2 {
+		logger.Get().Warnw("unknown okex instrument", "instrument", data.Get("instrument_id").Str)
+		return
+	}
+	trading, settlement := okexSymbols.Currency(xs[0]), okexSymbols.Currency(xs[1])
+	order := &models.Order{
+		ExchangeOrderID: data.Get("order_id").String(),
+		Type:            models.Ask,
+		Trading:         trading,
+		Settlement:      settlement,
+		Price:           helpers.ToDecimal(data.Get("price")),
+		Amount:          helpers.ToDecimal(data.Get("size")),
+		Status:          okexOrderStatus[data.Get("state").String()],
+		FilledAmount:    helpers.ToDecimal(data.Get("filled_size")),
+	}
+	if data.Get("side").Str == "sell" {
+		order.Type = models.Bid
+	}
+	order.CreatedAt, _ = time.Parse(time.RFC3339Nano, data.Get("created_at").Str)
+	order.UpdatedAt, _ = time.Parse(time.RFC3339Nano, data.Get("timestamp").Str)
+	order.AveragePrice = averagePrice(helpers.ToDecimal(data.Get("filled_notional")), order.FilledAmount)
+	s.publishOrder(order)
+
+	amount := helpers.ToDecimal(data.Get("last_fill_qty"))
+	if !amount.IsPositive() {
+		return
+	}
+	trade := &models.Trade{
+		ID:         data.Get("last_fill_id").String(),
+		OrderID:    order.ExchangeOrderID,
+		Trading:    trading,
+		Settlement: settlement,
+		Type:       order.Type,
+		Price:      helpers.ToDecimal(data.Get("last_fill_px")),
+		Amount:     amount,
+		Time:       order.UpdatedAt,
+	}
+	if t, err := time.Parse(time.RFC3339Nano, data.Get("last_fill_time").Str); err == nil {
+		trade.Time = t
+	}
+	s.publishFill(trade)
+}
@@ -1,6 +1,7 @@
 package private
 
 import (
+	"context"
 	"crypto/hmac"
 	"crypto/sha256"
 	"encoding/base64"
@@ -18,7 +19,11 @@ import (
 	"github.com/Jeffail/gabs"
 	"github.com/antonholmquist/jason"
 	"github.com/fxpgr/go-exchange-client/api/public"
+	"github.com/fxpgr/go-exchange-client/apierrors"
+	"github.com/fxpgr/go-exchange-client/decimal"
+	"github.com/fxpgr/go-exchange-client/helpers"
 	"github.com/fxpgr/go-exchange-client/models"
+	"github.com/fxpgr/go-exchange-client/symbols"
 	"github.com/pkg/errors"
 	"github.com/tidwall/gjson"
 )
@@ -27,6 +32,8 @@ const (
 	P2PB2B_BASE_URL = "https://api.p2pb2b.io/api/v1"
 )
 
+var p2pb2bSymbols = symbols.For("p2pb2b")
+
 func NewP2pb2bApi(apikey func() (string, error), apisecret func() (string, error)) (*P2pb2bApi, error) {
 	hitbtcPublic, err := public.NewP2pb2bPublicApi()
 	if err != nil {
@@ -50,6 +57,7 @@ func NewP2pb2bApi(apikey func() (string, error), apisecret func() (string, error
 	}
 
 	return &P2pb2bApi{
+		HttpClient:        http.Client{Transport: newTransport("p2pb2b", nil)},
 		BaseURL:           P2PB2B_BASE_URL,
 		RateCacheDuration: 30 * time.Second,
 		ApiKeyFunc:        apikey,
@@ -70,8 +78,9 @@ type P2pb2bApi struct {
 	BaseURL           string
 	RateCacheDuration time.Duration
 	HttpClient        http.Client
-	rt                *http.Transport
-	settlements       []string
+	clientContext
+	rt          *http.Transport
+	settlements []string
 
 	volumeMap       map[string]map[string]float64
 	rateMap         map[string]map[string]float64
@@ -85,6 +94,12 @@ func (h *P2pb2bApi) privateApiUrl() string {
 	return h.BaseURL
 }
 
+func (h *P2pb2bApi) WithContext(ctx context.Context) PrivateClient {
+	c := *h
+	c.ctx = ctx
+	return &c
+}
+
 func (h *P2pb2bApi) publicApiUrl(command string) string {
 	return h.BaseURL + command
 }
@@ -96,7 +111,7 @@ func (h *P2pb2bApi) fetchPrecision() error {
 	h.precisionMap = make(map[string]map[string]models.Precisions)
 
 	url := h.publicApiUrl("public/tickers")
-	resp, err := h.HttpClient.Get(url)
+	resp, err := httpGet(h.context(), &h.HttpClient, url)
 	if err != nil {
 		return errors.Wrapf(err, "failed to fetch %s", url)
 	}
@@ -115,20 +130,17 @@ func (h *P2pb2bApi) fetchPrecision() error {
 		return errors.Wrapf(err, "failed to parse json")
 	}
 	for k, v := range rateMap {
-		coins := strings.Split(k, "_")
-		if len(coins) != 2 {
-			continue
-		}
-		trading, settlement := coins[0], coins[1]
-		if settlement == "" || trading == "" {
+		pair, ok := p2pb2bSymbols.Pair(k)
+		if !ok {
 			continue
 		}
+		trading, settlement := pair.Trading, pair.Settlement
 		last, ok := v.Path("ticker").Path("last").Data().(string)
 		if !ok {
 			continue
 		}
 		// update rate
-		_, err = strconv.ParseFloat(last, 64)
+		_, err = decimal.NewFromString(last)
 		if err != nil {
 			return err
 		}
@@ -138,7 +150,7 @@ func (h *P2pb2bApi) fetchPrecision() error {
 		if !ok {
 			continue
 		}
-		_, err = strconv.ParseFloat(volume, 64)
+		_, err = decimal.NewFromString(volume)
 		if err != nil {
 			return err
 		}
@@ -163,9 +175,9 @@ func (h *P2pb2bApi) precise(trading string, settlement string) (*models.Precisio
 
 	h.fetchPrecision()
 	if m, ok := h.precisionMap[trading]; !ok {
-		return &models.Precisions{}, errors.Errorf("%s/%s missing trading", trading, settlement)
+		return &models.Precisions{}, errors.Wrapf(apierrors.ErrInvalidSymbol, "%s/%s missing trading", trading, settlement)
 	} else if precisions, ok := m[settlement]; !ok {
-		return &models.Precisions{}, errors.Errorf("%s/%s missing settlement", trading, settlement)
+		return &models.Precisions{}, errors.Wrapf(apierrors.ErrInvalidSymbol, "%s/%s missing settlement", trading, settlement)
 	} else {
 		return &precisions, nil
 	}
@@ -186,7 +198,7 @@ func (h *P2pb2bApi) privateApi(method string, path string, params *url.Values) (
 	}
 
 	reader := bytes.NewReader([]byte(params.Encode()))
-	req, err := http.NewRequest(method, urlStr, reader)
+	req, err := http.NewRequestWithContext(h.context(), method, urlStr, reader)
 	if err != nil {
 		return nil, errors.Wrapf(err, "failed to create request command %s", path)
 	}
@@ -216,13 +228,38 @@ func (h *P2pb2bApi) privateApi(method string, path string, params *url.Values) (
 	if err != nil {
 		return nil, errors.Wrapf(err, "failed to fetch result of command %s", path)
 	}
+	if err := p2pb2bError(res.StatusCode, resBody); err != nil {
+		return nil, errors.Wrapf(err, "failed to request command %s", path)
+	}
 	return resBody, err
 }
 
+// p2pb2bErrorMessages lists rate limits and authentication first, as they
+// fail a request before it is looked at.
+var p2pb2bErrorMessages = []messagePattern{
+	{"too many requests", apierrors.ErrRateLimited},
+	{"authentication", apierrors.ErrAuth},
+	{"nonce", apierrors.ErrAuth},
+	{"order not found", apierrors.ErrOrderNotFound},
+	{"market is not available", apierrors.ErrInvalidSymbol},
+	{"balance not enough", apierrors.ErrInsufficientFunds},
+	{"insufficient", apierrors.ErrInsufficientFunds},
+}
+
+func p2pb2bError(statusCode int, body []byte) error {
+	value := gjson.ParseBytes(body)
+	success := value.Get("success")
+	if statusCode == http.StatusOK && (!success.Exists() || success.Bool()) {
+		return nil
+	}
+	message := value.Get("message").String()
+	return apiError(statusCode, value.Get("errorCode").String(), message, messageKind(message, p2pb2bErrorMessages))
+}
+
 func (h *P2pb2bApi) TradeFeeRates() (map[string]map[string]TradeFee, error) {
 
 	url := h.publicApiUrl("/api/v1/market/allTickers")
-	req, err := requestGetAsChrome(url)
+	req, err := requestGetAsChrome(h.context(), url)
 	if err != nil {
 		return nil, errors.Wrapf(err, "failed to fetch %s", url)
 	}
@@ -238,14 +275,13 @@ func (h *P2pb2bApi) TradeFeeRates() (map[string]map[string]TradeFee, error) {
 	value := gjson.ParseBytes(byteArray)
 	traderFeeMap := make(map[string]map[string]TradeFee)
 	for _, v := range value.Get("data.ticker").Array() {
-		currencies := strings.Split(v.Get("symbol").Str, "-")
-		if len(currencies) < 2 {
+		pair, ok := p2pb2bSymbols.Pair(v.Get("symbol").Str)
+		if !ok {
 			continue
 		}
-		trading := currencies[0]
-		settlement := currencies[1]
+		trading, settlement := pair.Trading, pair.Settlement
 
-		feeRate := 0.001
+		feeRate := decimal.New(1, -3)
 		m, ok := traderFeeMap[trading]
 		if !ok {
 			m = make(map[string]TradeFee)
@@ -637,7 +673,7 @@ func (h *P2pb2bApi) Balances() (map[string]float64, error) {
 		if err != nil {
 			return nil, errors.Wrapf(err, "failed to parse currency on %s", json)
 		}
-		currency = strings.ToUpper(currency)
+		currency = p2pb2bSymbols.Currency(currency)
 		m[currency] = balance - freeze
 	}
 	return m, nil
@@ -664,22 +700,22 @@ func (h *P2pb2bApi) CompleteBalances() (map[string]*models.Balance, error) {
 		return nil, errors.Wrapf(err, "failed to parse json key data %s", json)
 	}
 	for _, v := range data {
-		balance, err := v.GetFloat64("balance")
+		balance, err := v.GetNumber("balance")
 		if err != nil {
 			return nil, errors.Wrapf(err, "failed to parse balance on %s", json)
 		}
-		available, err := v.GetFloat64("available")
+		available, err := v.GetNumber("available")
 		if err != nil {
 			return nil, errors.Wrapf(err, "failed to parse available on %s", json)
 		}
-		freeze := balance - available
+		freeze := helpers.ToDecimal(balance).Sub(helpers.ToDecimal(available))
 		currency, err := v.GetString("currency")
 		if err != nil {
 			return nil, errors.Wrapf(err, "failed to parse currency on %s", json)
 		}
-		currency = strings.ToUpper(currency)
+		currency = p2pb2bSymbols.Currency(currency)
 		m[currency] = &models.Balance{
-			Available: balance,
+			Available: helpers.ToDecimal(balance),
 			OnOrders:  freeze,
 		}
 	}
@@ -712,22 +748,29 @@ func (h *P2pb2bApi) ActiveOrders() ([]*models.Order, error) {
 }
 
 func (h *P2pb2bApi) Order(trading string, settlement string, ordertype models.OrderType, price float64, amount float64) (string, error) {
+	return h.PlaceOrder(models.OrderRequest{Trading: trading, Settlement: settlement, Type: ordertype, Price: decimal.NewFromFloat(price), Amount: decimal.NewFromFloat(amount)})
+}
+
+func (h *P2pb2bApi) PlaceOrder(req models.OrderRequest) (string, error) {
+	if req.ExecutionType != models.Limit || req.TimeInForce != models.GTC {
+		return "", unsupportedOrder("p2pb2b", req)
+	}
 	params := &url.Values{}
-	if ordertype == models.Bid {
+	if req.Type == models.Bid {
 		params.Set("type", "SELL")
-	} else if ordertype == models.Ask {
+	} else if req.Type == models.Ask {
 		params.Set("type", "BUY")
 	} else {
-		return "", errors.Errorf("unknown order type %d", ordertype)
+		return "", errors.Errorf("unknown order type %d", req.Type)
 	}
-	precise, err := h.precise(trading, settlement)
+	precise, err := h.precise(req.Trading, req.Settlement)
 	if err != nil {
 		return "", err
 	}
-	params.Set("price", FloorFloat64ToStr(price, precise.PricePrecision))
-	params.Set("amount", FloorFloat64ToStr(amount, precise.AmountPrecision))
+	params.Set("price", FloorDecimalToStr(req.Price, precise.PricePrecision))
+	params.Set("amount", FloorDecimalToStr(req.Amount, precise.AmountPrecision))
 
-	symbol := strings.ToUpper(fmt.Sprintf("%s-%s", trading, settlement))
+	symbol := p2pb2bSymbols.Symbol(req.Trading, req.Settlement)
 	params.Set("symbol", symbol)
 	byteArray, err := h.privateApi("POST", "/v1/order", params)
 	if err != nil {
@@ -762,7 +805,7 @@ func (h *P2pb2bApi) Transfer(typ string, addr string, amount float64, additional
 func (h *P2pb2bApi) CancelOrder(trading string, settlement string,
 	ordertype models.OrderType, orderNumber string) error {
 	params := &url.Values{}
-	params.Set("symbol", trading+"-"+settlement)
+	params.Set("symbol", p2pb2bSymbols.Symbol(trading, settlement))
 	params.Set("orderOid", orderNumber)
 	if ordertype == models.Ask {
 		params.Set("type", "BUY")
@@ -789,7 +832,7 @@ func (h *P2pb2bApi) CancelOrder(trading string, settlement string,
 
 func (h *P2pb2bApi) IsOrderFilled(trading string, settlement string, orderNumber string) (bool, error) {
 	params := &url.Values{}
-	params.Set("symbol", trading+"-"+settlement)
+	params.Set("symbol", p2pb2bSymbols.Symbol(trading, settlement))
 	bs, err := h.privateApi("GET", "/v1/order/active", params)
 	if err != nil {
 		return false, errors.Wrapf(err, "failed to cancel order")
@@ -839,6 +882,36 @@ func (h *P2pb2bApi) IsOrderFilled(trading string, settlement string, orderNumber
 	return true, nil
 }
 
+func (h *P2pb2bApi) OrderStatus(trading string, settlement string, orderNumber string) (*models.Order, error) {
+	for _, side := range []string{"BUY", "SELL"} {
+		params := &url.Values{}
+		params.Set("symbol", p2pb2bSymbols.Symbol(trading, settlement))
+		params.Set("type", side)
+		params.Set("orderOid", orderNumber)
+		bs, err := h.privateApi("GET", "/v1/order/detail", params)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to get order %s", orderNumber)
+		}
+		data := gjson.ParseBytes(bs).Get("data")
+		if data.IsObject() {
+			return parseKucoinOrder(data, trading, settlement, orderNumber), nil
+		}
+	}
+	return nil, errors.Wrapf(apierrors.ErrOrderNotFound, "order %s", orderNumber)
+}
+
+func (h *P2pb2bApi) MyTrades(trading string, settlement string, since time.Time, limit int) ([]*models.Trade, error) {
+	return nil, errors.Wrap(apierrors.ErrUnsupported, "trade history on p2pb2b")
+}
+
+func (h *P2pb2bApi) Deposits(since time.Time) ([]*models.Transfer, error) {
+	return nil, errors.Wrap(apierrors.ErrUnsupported, "deposit history on p2pb2b")
+}
+
+func (h *P2pb2bApi) Withdrawals(since time.Time) ([]*models.Transfer, error) {
+	return nil, errors.Wrap(apierrors.ErrUnsupported, "withdrawal history on p2pb2b")
+}
+
 func (h *P2pb2bApi) Address(c string) (string, error) {
 	params := &url.Values{}
 	bs, err := h.privateApi("GET", fmt.Sprintf("/v1/account/%s/wallet/address", c), params)
@@ -0,0 +1,606 @@
+package private
+
+import (
+	"context"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/fxpgr/go-exchange-client/api/public"
+	"github.com/fxpgr/go-exchange-client/apierrors"
+	"github.com/fxpgr/go-exchange-client/decimal"
+	"github.com/fxpgr/go-exchange-client/models"
+	"github.com/pkg/errors"
+)
+
+// PaperApi is a simulated exchange. Orders are matched against the boards of
+// Public, taking liquidity when placed and filling as makers once the board
+// crosses them, while balances, open orders and fills are kept in memory.
+// Fees are charged in the currency received.
+//
+// Resting orders are matched whenever the client is read. The liquidity taken
+// from a price level is hidden from the boards which follow until the level
+// leaves the board, so it is not filled twice.
+type PaperApi struct {
+	Public public.PublicClient
+	Fee    TradeFee
+	// Now is the clock of the simulation, time.Now when nil.
+	Now func() time.Time
+
+	state *paperState
+	clientContext
+}
+
+type paperState struct {
+	m         sync.Mutex
+	balances  map[string]*models.Balance
+	orders    map[string]*models.Order
+	open      []*models.Order
+	trades    []*models.Trade
+	deposits  []*models.Transfer
+	withdraws []*models.Transfer
+	taken     map[paperLevels]map[string]decimal.Decimal
+	lastID    int
+}
+
+// paperLevels are the levels of a board taken by orders of typ, the asks for
+// buys and the bids for sells.
+type paperLevels struct {
+	trading    string
+	settlement string
+	typ        models.OrderType
+}
+
+type paperFill struct {
+	price  decimal.Decimal
+	amount decimal.Decimal
+}
+
+// paperBook is a copy of a board which fills consume, best price first.
+type paperBook struct {
+	asks []models.BoardBar
+	bids []models.BoardBar
+}
+
+// book copies board less the liquidity taken from it before.
+func (s *paperState) book(trading string, settlement string, board *models.Board) *paperBook {
+	b := &paperBook{
+		asks: s.untaken(paperLevels{trading, settlement, models.Ask}, board.Asks),
+		bids: s.untaken(paperLevels{trading, settlement, models.Bid}, board.Bids),
+	}
+	sort.SliceStable(b.asks, func(i, j int) bool {
+		return b.asks[i].Price.LessThan(b.asks[j].Price)
+	})
+	sort.SliceStable(b.bids, func(i, j int) bool {
+		return b.bids[i].Price.GreaterThan(b.bids[j].Price)
+	})
+	return b
+}
+
+func (s *paperState) untaken(levels paperLevels, bars []models.BoardBar) []models.BoardBar {
+	taken := s.taken[levels]
+	left := make(map[string]decimal.Decimal)
+	bars = append([]models.BoardBar(nil), bars...)
+	for i := range bars {
+		level := bars[i].Price.String()
+		t := decimal.Min(taken[level], bars[i].Amount)
+		if t.IsPositive() {
+			left[level] = t
+		}
+		bars[i].Amount = bars[i].Amount.Sub(t)
+	}
+	s.taken[levels] = left
+	return bars
+}
+
+// consume hides the liquidity of fills from the boards which follow.
+func (s *paperState) consume(trading string, settlement string, typ models.OrderType, fills []paperFill) {
+	taken := s.taken[paperLevels{trading, settlement, typ}]
+	for _, f := range fills {
+		level := f.price.String()
+		taken[level] = taken[level].Add(f.amount)
+	}
+}
+
+// take consumes up to amount of the bars an order of typ at price crosses. A
+// price of zero crosses every bar.
+func (b *paperBook) take(typ models.OrderType, price decimal.Decimal, amount decimal.Decimal) []paperFill {
+	bars := b.asks
+	crosses := func(p decimal.Decimal) bool { return price.IsZero() || p.Cmp(price) <= 0 }
+	if typ == models.Bid {
+		bars = b.bids
+		crosses = func(p decimal.Decimal) bool { return price.IsZero() || p.Cmp(price) >= 0 }
+	}
+	var fills []paperFill
+	for i := range bars {
+		if !amount.IsPositive() || !crosses(bars[i].Price) {
+			break
+		}
+		if !bars[i].Amount.IsPositive() {
+			continue
+		}
+		f := paperFill{price: bars[i].Price, amount: decimal.Min(amount, bars[i].Amount)}
+		bars[i].Amount = bars[i].Amount.Sub(f.amount)
+		amount = amount.Sub(f.amount)
+		fills = append(fills, f)
+	}
+	return fills
+}
+
+// NewPaperApi returns a simulated exchange holding balances, which fills
+// orders against the boards of pub and charges fee.
+func NewPaperApi(pub public.PublicClient, balances map[string]decimal.Decimal, fee TradeFee) *PaperApi {
+	s := &paperState{
+		balances: make(map[string]*models.Balance),
+		orders:   make(map[string]*models.Order),
+		taken:    make(map[paperLevels]map[string]decimal.Decimal),
+	}
+	for c, v := range balances {
+		s.balances[c] = &models.Balance{Available: v}
+	}
+	return &PaperApi{Public: pub, Fee: fee, state: s}
+}
+
+func (p *PaperApi) WithContext(ctx context.Context) PrivateClient {
+	c := *p
+	c.ctx = ctx
+	return &c
+}
+
+func (p *PaperApi) now() time.Time {
+	if p.Now == nil {
+		return time.Now()
+	}
+	return p.Now()
+}
+
+func (p *PaperApi) board(trading string, settlement string) (*models.Board, error) {
+	board, err := p.Public.WithContext(p.context()).Board(trading, settlement)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to fetch board %s/%s", trading, settlement)
+	}
+	return board, nil
+}
+
+func (s *paperState) balance(c string) *models.Balance {
+	b, ok := s.balances[c]
+	if !ok {
+		b = &models.Balance{}
+		s.balances[c] = b
+	}
+	return b
+}
+
+func (s *paperState) nextID() string {
+	s.lastID++
+	return strconv.Itoa(s.lastID)
+}
+
+// Deposit credits amount of currency as a completed deposit.
+func (p *PaperApi) Deposit(currency string, amount decimal.Decimal) {
+	s := p.state
+	s.m.Lock()
+	defer s.m.Unlock()
+	b := s.balance(currency)
+	b.Available = b.Available.Add(amount)
+	now := p.now()
+	s.deposits = append(s.deposits, &models.Transfer{
+		ID:        s.nextID(),
+		Currency:  currency,
+		Amount:    amount,
+		Status:    models.TransferCompleted,
+		CreatedAt: now,
+		UpdatedAt: now,
+	})
+}
+
+func (p *PaperApi) TransferFee() (map[string]float64, error) {
+	return make(map[string]float64), nil
+}
+
+func (p *PaperApi) TradeFeeRates() (map[string]map[string]TradeFee, error) {
+	pairs, err := p.Public.WithContext(p.context()).CurrencyPairs()
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get pairs")
+	}
+	rates := make(map[string]map[string]TradeFee)
+	for _, pair := range pairs {
+		if rates[pair.Trading] == nil {
+			rates[pair.Trading] = make(map[string]TradeFee)
+		}
+		rates[pair.Trading][pair.Settlement] = p.Fee
+	}
+	return rates, nil
+}
+
+func (p *PaperApi) TradeFeeRate(trading string, settlement string) (TradeFee, error) {
+	return p.Fee, nil
+}
+
+func (p *PaperApi) Balances() (map[string]float64, error) {
+	if err := p.match(); err != nil {
+		return nil, err
+	}
+	s := p.state
+	s.m.Lock()
+	defer s.m.Unlock()
+	m := make(map[string]float64)
+	for c, b := range s.balances {
+		m[c] = b.Available.Float64()
+	}
+	return m, nil
+}
+
+func (p *PaperApi) CompleteBalances() (map[string]*models.Balance, error) {
+	if err := p.match(); err != nil {
+		return nil, err
+	}
+	s := p.state
+	s.m.Lock()
+	defer s.m.Unlock()
+	m := make(map[string]*models.Balance)
+	for c, b := range s.balances {
+		m[c] = models.NewBalance(b.Available, b.OnOrders)
+	}
+	return m, nil
+}
+
+func (p *PaperApi) CompleteBalance(coin string) (*models.Balance, error) {
+	if err := p.match(); err != nil {
+		return nil, err
+	}
+	s := p.state
+	s.m.Lock()
+	defer s.m.Unlock()
+	b := s.balance(coin)
+	return models.NewBalance(b.Available, b.OnOrders), nil
+}
+
+func (p *PaperApi) ActiveOrders() ([]*models.Order, error) {
+	if err := p.match(); err != nil {
+		return nil, err
+	}
+	s := p.state
+	s.m.Lock()
+	defer s.m.Unlock()
+	orders := make([]*models.Order, 0, len(s.open))
+	for _, o := range s.open {
+		c := *o
+		orders = append(orders, &c)
+	}
+	return orders, nil
+}
+
+func (p *PaperApi) IsOrderFilled(trading string, settlement string, orderNumber string) (bool, error) {
+	o, err := p.OrderStatus(trading, settlement, orderNumber)
+	if err != nil {
+		return false, err
+	}
+	return o.Status == models.OrderFilled, nil
+}
+
+func (p *PaperApi) OrderStatus(trading string, settlement string, orderNumber string) (*models.Order, error) {
+	if err := p.match(); err != nil {
+		return nil, err
+	}
+	s := p.state
+	s.m.Lock()
+	defer s.m.Unlock()
+	o, ok := s.orders[orderNumber]
+	if !ok || o.Trading != trading || o.Settlement != settlement {
+		return nil, errors.Wrapf(apierrors.ErrOrderNotFound, "order %s", orderNumber)
+	}
+	c := *o
+	return &c, nil
+}
+
+func (p *PaperApi) MyTrades(trading string, settlement string, since time.Time, limit int) ([]*models.Trade, error) {
+	if err := p.match(); err != nil {
+		return nil, err
+	}
+	s := p.state
+	s.m.Lock()
+	defer s.m.Unlock()
+	trades := make([]*models.Trade, 0)
+	for _, t := range s.trades {
+		if t.Trading == trading && t.Settlement == settlement {
+			c := *t
+			trades = append(trades, &c)
+		}
+	}
+	return trimTrades(trades, since, limit), nil
+}
+
+func (p *PaperApi) Order(trading string, settlement string,
+	ordertype models.OrderType, price float64, amount float64) (string, error) {
+	return p.PlaceOrder(models.OrderRequest{
+		Trading:    trading,
+		Settlement: settlement,
+		Type:       ordertype,
+		Price:      decimal.NewFromFloat(price),
+		Amount:     decimal.NewFromFloat(amount),
+	})
+}
+
+// PlaceOrder takes the liquidity the order crosses on the current board and
+// leaves the rest open unless it is a market, IOC or FOK order, whose
+// remainder expires.
+func (p *PaperApi) PlaceOrder(req models.OrderRequest) (string, error) {
+	if !req.Amount.IsPositive() {
+		return "", errors.Errorf("invalid amount %v", req.Amount)
+	}
+	price := req.Price
+	if req.ExecutionType == models.Market {
+		price = decimal.Zero
+	} else if !price.IsPositive() {
+		return "", errors.Errorf("invalid price %v", req.Price)
+	}
+	board, err := p.board(req.Trading, req.Settlement)
+	if err != nil {
+		return "", err
+	}
+	s := p.state
+	s.m.Lock()
+	defer s.m.Unlock()
+	fills := s.book(req.Trading, req.Settlement, board).take(req.Type, price, req.Amount)
+	filled, cost := decimal.Zero, decimal.Zero
+	for _, f := range fills {
+		filled = filled.Add(f.amount)
+		cost = cost.Add(f.amount.Mul(f.price))
+	}
+	if req.ExecutionType == models.Limit {
+		switch req.TimeInForce {
+		case models.PostOnly:
+			if len(fills) > 0 {
+				return "", errors.Errorf("post-only order at %v would take liquidity", req.Price)
+			}
+		case models.FOK:
+			if filled.LessThan(req.Amount) {
+				fills = nil
+			}
+		}
+	}
+
+	// buys reserve the settlement at their limit price, or what the fills
+	// cost for market orders, and sells reserve the trading currency
+	reserve, currency := req.Amount, req.Trading
+	if req.Type == models.Ask {
+		reserve, currency = req.Amount.Mul(price), req.Settlement
+		if price.IsZero() {
+			reserve = cost
+		}
+	}
+	b := s.balance(currency)
+	if b.Available.LessThan(reserve) {
+		return "", errors.Wrapf(apierrors.ErrInsufficientFunds, "%v %s available, %v needed", b.Available, currency, reserve)
+	}
+	b.Available = b.Available.Sub(reserve)
+	b.OnOrders = b.OnOrders.Add(reserve)
+
+	now := p.now()
+	o := &models.Order{
+		ExchangeOrderID: s.nextID(),
+		Type:            req.Type,
+		Trading:         req.Trading,
+		Settlement:      req.Settlement,
+		Price:           price,
+		Amount:          req.Amount,
+		Status:          models.OrderOpen,
+		CreatedAt:       now,
+		UpdatedAt:       now,
+	}
+	s.orders[o.ExchangeOrderID] = o
+	s.consume(o.Trading, o.Settlement, o.Type, fills)
+	for _, f := range fills {
+		p.fill(o, f, p.Fee.TakerFee, false)
+	}
+	if o.Status.Closed() {
+		return o.ExchangeOrderID, nil
+	}
+	if req.ExecutionType == models.Market || req.TimeInForce == models.IOC || req.TimeInForce == models.FOK {
+		p.close(o, models.OrderExpired)
+		return o.ExchangeOrderID, nil
+	}
+	s.open = append(s.open, o)
+	return o.ExchangeOrderID, nil
+}
+
+func (p *PaperApi) CancelOrder(trading string, settlement string,
+	ordertype models.OrderType, orderNumber string) error {
+	if err := p.match(); err != nil {
+		return err
+	}
+	s := p.state
+	s.m.Lock()
+	defer s.m.Unlock()
+	o, ok := s.orders[orderNumber]
+	if !ok || o.Trading != trading || o.Settlement != settlement {
+		return errors.Wrapf(apierrors.ErrOrderNotFound, "order %s", orderNumber)
+	}
+	if o.Status.Closed() {
+		return errors.Wrapf(apierrors.ErrOrderNotFound, "order %s is %s", orderNumber, o.Status)
+	}
+	p.close(o, models.OrderCanceled)
+	return nil
+}
+
+// Transfer withdraws amount and additionalFee of typ at once.
+func (p *PaperApi) Transfer(typ string, addr string,
+	amount float64, additionalFee float64) error {
+	s := p.state
+	s.m.Lock()
+	defer s.m.Unlock()
+	b := s.balance(typ)
+	total := decimal.NewFromFloat(amount).Add(decimal.NewFromFloat(additionalFee))
+	if b.Available.LessThan(total) {
+		return errors.Wrapf(apierrors.ErrInsufficientFunds, "%v %s available, %v needed", b.Available, typ, total)
+	}
+	b.Available = b.Available.Sub(total)
+	now := p.now()
+	s.withdraws = append(s.withdraws, &models.Transfer{
+		ID:        s.nextID(),
+		Currency:  typ,
+		Amount:    decimal.NewFromFloat(amount),
+		Fee:       decimal.NewFromFloat(additionalFee),
+		Address:   addr,
+		Status:    models.TransferCompleted,
+		CreatedAt: now,
+		UpdatedAt: now,
+	})
+	return nil
+}
+
+func (p *PaperApi) Address(c string) (string, error) {
+	return "", errors.Wrap(apierrors.ErrUnsupported, "deposit addresses on paper")
+}
+
+func (p *PaperApi) Deposits(since time.Time) ([]*models.Transfer, error) {
+	s := p.state
+	s.m.Lock()
+	defer s.m.Unlock()
+	return trimTransfers(copyTransfers(s.deposits), since), nil
+}
+
+func (p *PaperApi) Withdrawals(since time.Time) ([]*models.Transfer, error) {
+	s := p.state
+	s.m.Lock()
+	defer s.m.Unlock()
+	return trimTransfers(copyTransfers(s.withdraws), since), nil
+}
+
+func copyTransfers(transfers []*models.Transfer) []*models.Transfer {
+	c := make([]*models.Transfer, 0, len(transfers))
+	for _, t := range transfers {
+		v := *t
+		c = append(c, &v)
+	}
+	return c
+}
+
+// match fills the open orders crossed by the current boards as makers, in
+// price-time priority.
+func (p *PaperApi) match() error {
+	s := p.state
+	s.m.Lock()
+	pairs := make(map[[2]string]bool)
+	for _, o := range s.open {
+		pairs[[2]string{o.Trading, o.Settlement}] = true
+	}
+	s.m.Unlock()
+
+	for pair := range pairs {
+		board, err := p.board(pair[0], pair[1])
+		if err != nil {
+			return err
+		}
+		s.m.Lock()
+		book := s.book(pair[0], pair[1], board)
+		// buys match highest price first and sells lowest first, each side
+		// keeping the order they were placed in between equal prices
+		var buys, sells []*models.Order
+		for _, o := range s.open {
+			if o.Trading != pair[0] || o.Settlement != pair[1] {
+				continue
+			}
+			if o.Type == models.Ask {
+				buys = append(buys, o)
+			} else {
+				sells = append(sells, o)
+			}
+		}
+		sort.SliceStable(buys, func(i, j int) bool { return buys[i].Price.GreaterThan(buys[j].Price) })
+		sort.SliceStable(sells, func(i, j int) bool { return sells[i].Price.LessThan(sells[j].Price) })
+		for _, o := range append(buys, sells...) {
+			fills := book.take(o.Type, o.Price, o.RemainingAmount())
+			s.consume(o.Trading, o.Settlement, o.Type, fills)
+			for _, f := range fills {
+				p.fill(o, f, p.Fee.MakerFee, true)
+			}
+		}
+		s.m.Unlock()
+	}
+	return nil
+}
+
+// fill settles f against o, which must be locked. Makers trade at their own
+// price and takers at the price of the board.
+func (p *PaperApi) fill(o *models.Order, f paperFill, rate decimal.Decimal, maker bool) {
+	s := p.state
+	price := f.price
+	if maker {
+		price = o.Price
+	}
+	var fee decimal.Decimal
+	if o.Type == models.Ask {
+		reserved := o.Price
+		if reserved.IsZero() {
+			reserved = price
+		}
+		b := s.balance(o.Settlement)
+		b.OnOrders = b.OnOrders.Sub(f.amount.Mul(reserved))
+		b.Available = b.Available.Add(f.amount.Mul(reserved.Sub(price)))
+		fee = f.amount.Mul(rate)
+		t := s.balance(o.Trading)
+		t.Available = t.Available.Add(f.amount.Sub(fee))
+		o.FeeCurrency = o.Trading
+	} else {
+		t := s.balance(o.Trading)
+		t.OnOrders = t.OnOrders.Sub(f.amount)
+		fee = f.amount.Mul(price).Mul(rate)
+		b := s.balance(o.Settlement)
+		b.Available = b.Available.Add(f.amount.Mul(price).Sub(fee))
+		o.FeeCurrency = o.Settlement
+	}
+	now := p.now()
+	filled := o.FilledAmount.Add(f.amount)
+	o.AveragePrice = o.AveragePrice.Mul(o.FilledAmount).Add(price.Mul(f.amount)).Div(filled)
+	o.FilledAmount = filled
+	o.Fee = o.Fee.Add(fee)
+	o.UpdatedAt = now
+	o.Status = models.OrderPartiallyFilled
+	if !o.RemainingAmount().IsPositive() {
+		o.Status = models.OrderFilled
+		p.release(o)
+	}
+	s.trades = append(s.trades, &models.Trade{
+		ID:          s.nextID(),
+		OrderID:     o.ExchangeOrderID,
+		Trading:     o.Trading,
+		Settlement:  o.Settlement,
+		Type:        o.Type,
+		Price:       price,
+		Amount:      f.amount,
+		Fee:         fee,
+		FeeCurrency: o.FeeCurrency,
+		Time:        now,
+	})
+}
+
+// close gives the reservation of the unfilled part of o back and leaves it in
+// status.
+func (p *PaperApi) close(o *models.Order, status models.OrderStatus) {
+	o.Status = status
+	o.UpdatedAt = p.now()
+	p.release(o)
+}
+
+func (p *PaperApi) release(o *models.Order) {
+	s := p.state
+	remaining := decimal.Max(decimal.Zero, o.RemainingAmount())
+	if o.Type == models.Ask {
+		b := s.balance(o.Settlement)
+		b.OnOrders = b.OnOrders.Sub(remaining.Mul(o.Price))
+		b.Available = b.Available.Add(remaining.Mul(o.Price))
+	} else {
+		b := s.balance(o.Trading)
+		b.OnOrders = b.OnOrders.Sub(remaining)
+		b.Available = b.Available.Add(remaining)
+	}
+	for i, v := range s.open {
+		if v == o {
+			s.open = append(s.open[:i], s.open[i+1:]...)
+			break
+		}
+	}
+}
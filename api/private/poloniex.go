@@ -2,6 +2,7 @@ package private
 
 import (
 	"bytes"
+	"context"
 	"crypto/hmac"
 	"crypto/sha512"
 	"encoding/hex"
@@ -15,9 +16,15 @@ import (
 	"time"
 
 	"github.com/antonholmquist/jason"
+	"github.com/fxpgr/go-exchange-client/apierrors"
+	"github.com/fxpgr/go-exchange-client/decimal"
+	"github.com/fxpgr/go-exchange-client/helpers"
 	"github.com/fxpgr/go-exchange-client/logger"
 	"github.com/fxpgr/go-exchange-client/models"
+	"github.com/fxpgr/go-exchange-client/retry"
+	"github.com/fxpgr/go-exchange-client/symbols"
 	"github.com/pkg/errors"
+	"github.com/tidwall/gjson"
 	"strings"
 )
 
@@ -25,8 +32,11 @@ const (
 	POLONIEX_BASE_URL = "https://poloniex.com"
 )
 
+var poloniexSymbols = symbols.For("poloniex")
+
 func NewPoloniexApi(apikey func() (string, error), apisecret func() (string, error)) (*PoloniexApi, error) {
 	return &PoloniexApi{
+		HttpClient:        http.Client{Transport: newTransport("poloniex", nil)},
 		BaseURL:           POLONIEX_BASE_URL,
 		RateCacheDuration: 7 * 24 * time.Hour,
 		ApiKeyFunc:        apikey,
@@ -45,6 +55,7 @@ type PoloniexApi struct {
 	BaseURL           string
 	RateCacheDuration time.Duration
 	HttpClient        http.Client
+	clientContext
 
 	volumeMap       map[string]map[string]float64
 	rateMap         map[string]map[string]float64
@@ -54,13 +65,11 @@ type PoloniexApi struct {
 }
 
 func parsePoloCurrencyPair(s string) (string, string, error) {
-	xs := strings.Split(s, "_")
-
-	if len(xs) != 2 {
+	pair, ok := poloniexSymbols.Pair(s)
+	if !ok {
 		return "", "", errors.New("invalid ticker title")
 	}
-
-	return xs[0], xs[1], nil
+	return pair.Settlement, pair.Trading, nil
 }
 
 func (p *PoloniexApi) baseUrl() string {
@@ -71,7 +80,41 @@ func (p *PoloniexApi) privateApiUrl() string {
 	return p.BaseURL
 }
 
+func (p *PoloniexApi) WithContext(ctx context.Context) PrivateClient {
+	c := *p
+	c.ctx = ctx
+	return &c
+}
+
+// privateApi sends a command. Every request is signed over a new nonce, so
+// reads are retried here with a new request rather than by the transport,
+// which would send the same nonce again.
 func (p *PoloniexApi) privateApi(command string, args map[string]string) ([]byte, error) {
+	send := func() (*http.Response, error) { return p.send(command, args) }
+	var res *http.Response
+	var err error
+	if strings.HasPrefix(command, "return") {
+		res, err = retry.Do(p.context(), send)
+	} else {
+		res, err = send()
+	}
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to request command %s", command)
+	}
+	defer res.Body.Close()
+
+	resBody, err := ioutil.ReadAll(res.Body)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to fetch result of command %s", command)
+	}
+	if err := poloniexError(res.StatusCode, resBody); err != nil {
+		return nil, errors.Wrapf(err, "failed to request command %s", command)
+	}
+	return resBody, nil
+}
+
+// send signs and sends a command with a new nonce.
+func (p *PoloniexApi) send(command string, args map[string]string) (*http.Response, error) {
 	apiKey, err := p.ApiKeyFunc()
 	if err != nil {
 		return nil, errors.Wrapf(err, "failed to create request command %s", command)
@@ -90,7 +133,7 @@ func (p *PoloniexApi) privateApi(command string, args map[string]string) ([]byte
 	}
 
 	reader := bytes.NewReader([]byte(val.Encode()))
-	req, err := http.NewRequest("POST", p.privateApiUrl(), reader)
+	req, err := http.NewRequestWithContext(p.context(), "POST", p.privateApiUrl(), reader)
 	if err != nil {
 		return nil, errors.Wrapf(err, "failed to create request command %s", command)
 	}
@@ -105,18 +148,33 @@ func (p *PoloniexApi) privateApi(command string, args map[string]string) ([]byte
 	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
 	req.Header.Add("Key", apiKey)
 	req.Header.Add("Sign", hex.EncodeToString(sign))
+	return p.HttpClient.Do(req)
+}
 
-	res, err := p.HttpClient.Do(req)
-	if err != nil {
-		return nil, errors.Wrapf(err, "failed to request command %s", command)
-	}
-	defer res.Body.Close()
+// poloniexErrorMessages lists rate limits and authentication first, as they
+// fail a request before it is looked at.
+var poloniexErrorMessages = []messagePattern{
+	{"please do not make more than", apierrors.ErrRateLimited},
+	{"invalid api key", apierrors.ErrAuth},
+	{"nonce must be greater", apierrors.ErrAuth},
+	{"maintenance", apierrors.ErrMaintenance},
+	{"invalid order number", apierrors.ErrOrderNotFound},
+	{"order not found", apierrors.ErrOrderNotFound},
+	{"invalid currency pair", apierrors.ErrInvalidSymbol},
+	{"not enough", apierrors.ErrInsufficientFunds},
+}
 
-	resBody, err := ioutil.ReadAll(res.Body)
-	if err != nil {
-		return nil, errors.Wrapf(err, "failed to fetch result of command %s", command)
+func poloniexError(statusCode int, body []byte) error {
+	var res errorResponse
+	json.Unmarshal(body, &res)
+	if statusCode == http.StatusOK && res.Error == nil {
+		return nil
 	}
-	return resBody, nil
+	message := string(body)
+	if res.Error != nil {
+		message = *res.Error
+	}
+	return apiError(statusCode, "", message, messageKind(message, poloniexErrorMessages))
 }
 
 type poloniexFeeRate struct {
@@ -128,7 +186,7 @@ func (p *PoloniexApi) fetchRate() error {
 	p.rateMap = make(map[string]map[string]float64)
 	p.volumeMap = make(map[string]map[string]float64)
 	url := p.baseUrl() + "/public?command=returnTicker"
-	resp, err := p.HttpClient.Get(url)
+	resp, err := httpGet(p.context(), &p.HttpClient, url)
 	if err != nil {
 		return errors.Wrapf(err, "failed to fetch %s", url)
 	}
@@ -215,7 +273,7 @@ func (p *PoloniexApi) TradeFeeRates() (map[string]map[string]TradeFee, error) {
 	if err != nil {
 		return nil, errors.Wrap(err, "dd")
 	}
-	makerFee, err := strconv.ParseFloat(makerFeeString, 10)
+	makerFee, err := decimal.NewFromString(makerFeeString)
 	if err != nil {
 		return nil, err
 	}
@@ -223,7 +281,7 @@ func (p *PoloniexApi) TradeFeeRates() (map[string]map[string]TradeFee, error) {
 	if err != nil {
 		return nil, err
 	}
-	takerFee, err := strconv.ParseFloat(takerFeeString, 10)
+	takerFee, err := decimal.NewFromString(takerFeeString)
 	if err != nil {
 		return nil, err
 	}
@@ -261,7 +319,7 @@ type Currency struct {
 
 func (p *PoloniexApi) TransferFee() (map[string]float64, error) {
 	url := p.baseUrl() + "/public?command=returnCurrencies"
-	resp, err := p.HttpClient.Get(url)
+	resp, err := httpGet(p.context(), &p.HttpClient, url)
 	if err != nil {
 		return nil, errors.Wrapf(err, "failed to fetch %s", url)
 	}
@@ -272,7 +330,7 @@ func (p *PoloniexApi) TransferFee() (map[string]float64, error) {
 		return nil, errors.Wrap(err, "failed to parse response")
 	}
 	for k, v := range m {
-		transferFeeMap[k] = v.TxFee
+		transferFeeMap[poloniexSymbols.Currency(k)] = v.TxFee
 	}
 	return transferFeeMap, nil
 }
@@ -302,7 +360,7 @@ func (p *PoloniexApi) Balances() (map[string]float64, error) {
 		if err != nil {
 			return nil, errors.Wrapf(err, "failed to parse %s as float", balanceStr)
 		}
-		m[k] = balance
+		m[poloniexSymbols.Currency(k)] = balance
 	}
 
 	return m, nil
@@ -332,7 +390,7 @@ func (p *PoloniexApi) CompleteBalances() (map[string]*models.Balance, error) {
 		if err != nil {
 			return nil, errors.Wrap(err, "couldn't get available as string")
 		}
-		available, err := strconv.ParseFloat(availableStr, 64)
+		available, err := decimal.NewFromString(availableStr)
 		if err != nil {
 			return nil, errors.Wrapf(err, "couldn't parse available(%s) as float64", availableStr)
 		}
@@ -341,13 +399,13 @@ func (p *PoloniexApi) CompleteBalances() (map[string]*models.Balance, error) {
 		if err != nil {
 			return nil, errors.Wrap(err, "couldn't get onOrders as string")
 		}
-		onOrders, err := strconv.ParseFloat(onOrdersStr, 64)
+		onOrders, err := decimal.NewFromString(onOrdersStr)
 		if err != nil {
 			return nil, errors.Wrapf(err, "couldn't parse onOrders(%s) as float64", onOrdersStr)
 		}
 
 		b := models.NewBalance(available, onOrders)
-		m[k] = b
+		m[poloniexSymbols.Currency(k)] = b
 	}
 
 	return m, nil
@@ -380,7 +438,7 @@ func (p *PoloniexApi) CompleteBalance(coin string) (*models.Balance, error) {
 		if err != nil {
 			return nil, errors.Wrap(err, "couldn't get available as string")
 		}
-		available, err := strconv.ParseFloat(availableStr, 64)
+		available, err := decimal.NewFromString(availableStr)
 		if err != nil {
 			return nil, errors.Wrapf(err, "couldn't parse available(%s) as float64", availableStr)
 		}
@@ -389,13 +447,13 @@ func (p *PoloniexApi) CompleteBalance(coin string) (*models.Balance, error) {
 		if err != nil {
 			return nil, errors.Wrap(err, "couldn't get onOrders as string")
 		}
-		onOrders, err := strconv.ParseFloat(onOrdersStr, 64)
+		onOrders, err := decimal.NewFromString(onOrdersStr)
 		if err != nil {
 			return nil, errors.Wrapf(err, "couldn't parse onOrders(%s) as float64", onOrdersStr)
 		}
 
 		b := models.NewBalance(available, onOrders)
-		m[k] = b
+		m[poloniexSymbols.Currency(k)] = b
 	}
 
 	return m[coin], nil
@@ -414,6 +472,209 @@ func (p *PoloniexApi) IsOrderFilled(trading string, settlement string, orderNumb
 	return true, nil
 }
 
+// OrderStatus combines returnOrderStatus, which only knows open orders, with
+// the trades of the order. A closed order is reported filled by the amount it
+// traded, as poloniex forgets the amount it was placed with.
+func (p *PoloniexApi) OrderStatus(trading string, settlement string, orderNumber string) (*models.Order, error) {
+	bs, err := p.privateApi("returnOrderStatus", map[string]string{
+		"orderNumber": orderNumber,
+	})
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to get order %s", orderNumber)
+	}
+	order := &models.Order{
+		ExchangeOrderID: orderNumber,
+		Type:            models.Ask,
+		Trading:         trading,
+		Settlement:      settlement,
+		Status:          models.OrderFilled,
+	}
+	open := gjson.ParseBytes(bs).Get("result." + orderNumber)
+	if open.Exists() {
+		order.Price = helpers.ToDecimal(open.Get("rate"))
+		order.Amount = helpers.ToDecimal(open.Get("startingAmount"))
+		order.FilledAmount = order.Amount.Sub(helpers.ToDecimal(open.Get("amount")))
+		order.Status = models.OrderOpen
+		if order.FilledAmount.IsPositive() {
+			order.Status = models.OrderPartiallyFilled
+		}
+		if open.Get("type").Str == "sell" {
+			order.Type = models.Bid
+		}
+		order.CreatedAt, _ = time.Parse("2006-01-02 15:04:05", open.Get("date").Str)
+	}
+
+	bs, err = p.privateApi("returnOrderTrades", map[string]string{
+		"orderNumber": orderNumber,
+	})
+	if err != nil {
+		if open.Exists() && errors.Is(err, apierrors.ErrOrderNotFound) {
+			return order, nil
+		}
+		return nil, errors.Wrapf(err, "failed to get trades of order %s", orderNumber)
+	}
+	filled, total := decimal.Zero, decimal.Zero
+	for _, trade := range gjson.ParseBytes(bs).Array() {
+		amount := helpers.ToDecimal(trade.Get("amount"))
+		filled = filled.Add(amount)
+		total = total.Add(helpers.ToDecimal(trade.Get("total")))
+		// fee is a rate charged in the currency received
+		if trade.Get("type").Str == "sell" {
+			order.Type = models.Bid
+			order.Fee = order.Fee.Add(helpers.ToDecimal(trade.Get("fee")).Mul(helpers.ToDecimal(trade.Get("total"))))
+		} else {
+			order.Fee = order.Fee.Add(helpers.ToDecimal(trade.Get("fee")).Mul(amount))
+		}
+		if t, err := time.Parse("2006-01-02 15:04:05", trade.Get("date").Str); err == nil && t.After(order.UpdatedAt) {
+			order.UpdatedAt = t
+		}
+	}
+	order.FeeCurrency = trading
+	if order.Type == models.Bid {
+		order.FeeCurrency = settlement
+	}
+	order.AveragePrice = averagePrice(total, filled)
+	if !open.Exists() {
+		order.Amount = filled
+		order.FilledAmount = filled
+		if filled.IsPositive() && order.Price.IsZero() {
+			order.Price = order.AveragePrice
+		}
+	}
+	return order, nil
+}
+
+const poloniexTradesPageSize = 10000
+
+// MyTrades pages backwards from now, as poloniex returns the newest trades
+// of the window first.
+func (p *PoloniexApi) MyTrades(trading string, settlement string, since time.Time, limit int) ([]*models.Trade, error) {
+	var trades []*models.Trade
+	seen := make(map[string]bool)
+	var start int64
+	if !since.IsZero() {
+		start = since.Unix()
+	}
+	end := time.Now().Unix()
+	for {
+		bs, err := p.privateApi("returnTradeHistory", map[string]string{
+			"currencyPair": poloniexSymbols.Symbol(trading, settlement),
+			"start":        strconv.FormatInt(start, 10),
+			"end":          strconv.FormatInt(end, 10),
+			"limit":        strconv.Itoa(poloniexTradesPageSize),
+		})
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to get trades of %s/%s", trading, settlement)
+		}
+		page := gjson.ParseBytes(bs).Array()
+		added := 0
+		for _, v := range page {
+			id := v.Get("globalTradeID").String()
+			if seen[id] {
+				continue
+			}
+			seen[id] = true
+			added++
+			trade := &models.Trade{
+				ID:          id,
+				OrderID:     v.Get("orderNumber").String(),
+				Trading:     trading,
+				Settlement:  settlement,
+				Type:        models.Ask,
+				Price:       helpers.ToDecimal(v.Get("rate")),
+				Amount:      helpers.ToDecimal(v.Get("amount")),
+				FeeCurrency: trading,
+			}
+			// fee is a rate charged in the currency received
+			trade.Fee = helpers.ToDecimal(v.Get("fee")).Mul(trade.Amount)
+			if v.Get("type").Str == "sell" {
+				trade.Type = models.Bid
+				trade.Fee = helpers.ToDecimal(v.Get("fee")).Mul(helpers.ToDecimal(v.Get("total")))
+				trade.FeeCurrency = settlement
+			}
+			trade.Time, _ = time.Parse("2006-01-02 15:04:05", v.Get("date").Str)
+			if trade.Time.Unix() < end {
+				end = trade.Time.Unix()
+			}
+			trades = append(trades, trade)
+		}
+		// end is inclusive, so a page without new trades is the last one
+		if len(page) < poloniexTradesPageSize || added == 0 {
+			break
+		}
+	}
+	return trimTrades(trades, since, limit), nil
+}
+
+func (p *PoloniexApi) Deposits(since time.Time) ([]*models.Transfer, error) {
+	deposits, _, err := p.depositsWithdrawals(since)
+	return deposits, err
+}
+
+func (p *PoloniexApi) Withdrawals(since time.Time) ([]*models.Transfer, error) {
+	_, withdrawals, err := p.depositsWithdrawals(since)
+	return withdrawals, err
+}
+
+// poloniexTransferStatus maps the status, which for completed withdrawals is
+// followed by the txid as in "COMPLETE: <txid>".
+func poloniexTransferStatus(status string) (models.TransferStatus, string) {
+	switch {
+	case strings.HasPrefix(status, "COMPLETE"):
+		return models.TransferCompleted, strings.TrimSpace(strings.TrimPrefix(status, "COMPLETE:"))
+	case strings.HasPrefix(status, "PENDING"), strings.HasPrefix(status, "AWAITING"):
+		return models.TransferPending, ""
+	case strings.HasPrefix(status, "CANCEL"):
+		return models.TransferCanceled, ""
+	case strings.HasPrefix(status, "FAIL"):
+		return models.TransferFailed, ""
+	}
+	return models.TransferUnknown, ""
+}
+
+func (p *PoloniexApi) depositsWithdrawals(since time.Time) ([]*models.Transfer, []*models.Transfer, error) {
+	var start int64
+	if !since.IsZero() {
+		start = since.Unix()
+	}
+	bs, err := p.privateApi("returnDepositsWithdrawals", map[string]string{
+		"start": strconv.FormatInt(start, 10),
+		"end":   strconv.FormatInt(time.Now().Unix(), 10),
+	})
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "failed to get deposits and withdrawals")
+	}
+	value := gjson.ParseBytes(bs)
+	var deposits, withdrawals []*models.Transfer
+	for _, v := range value.Get("deposits").Array() {
+		status, _ := poloniexTransferStatus(v.Get("status").Str)
+		deposits = append(deposits, &models.Transfer{
+			ID:        v.Get("depositNumber").String(),
+			Currency:  poloniexSymbols.Currency(v.Get("currency").Str),
+			Amount:    helpers.ToDecimal(v.Get("amount")),
+			Address:   v.Get("address").Str,
+			TxID:      v.Get("txid").Str,
+			Status:    status,
+			CreatedAt: time.Unix(v.Get("timestamp").Int(), 0),
+		})
+	}
+	for _, v := range value.Get("withdrawals").Array() {
+		status, txid := poloniexTransferStatus(v.Get("status").Str)
+		withdrawals = append(withdrawals, &models.Transfer{
+			ID:        v.Get("withdrawalNumber").String(),
+			Currency:  poloniexSymbols.Currency(v.Get("currency").Str),
+			Amount:    helpers.ToDecimal(v.Get("amount")),
+			Fee:       helpers.ToDecimal(v.Get("fee")),
+			Address:   v.Get("address").Str,
+			Tag:       v.Get("paymentID").Str,
+			TxID:      txid,
+			Status:    status,
+			CreatedAt: time.Unix(v.Get("timestamp").Int(), 0),
+		})
+	}
+	return trimTransfers(deposits, since), trimTransfers(withdrawals, since), nil
+}
+
 func (p *PoloniexApi) ActiveOrders() ([]*models.Order, error) {
 	bs, err := p.privateApi("returnOpenOrders", map[string]string{
 		"currencyPair": "all",
@@ -433,7 +694,7 @@ func (p *PoloniexApi) ActiveOrders() ([]*models.Order, error) {
 			continue
 		}
 
-		settlement, trading, err := parseCurrencyPair(pair)
+		settlement, trading, err := parsePoloCurrencyPair(pair)
 		if err != nil {
 			return nil, errors.Wrapf(err, "cannot parse currency pair: %s", pair)
 		}
@@ -444,12 +705,12 @@ func (p *PoloniexApi) ActiveOrders() ([]*models.Order, error) {
 				return nil, errors.Wrapf(err, "cannot parse ordernumber: %s", o.OrderNumber)
 			}
 
-			rate, err := strconv.ParseFloat(o.Rate, 64)
+			rate, err := decimal.NewFromString(o.Rate)
 			if err != nil {
 				return nil, errors.Wrapf(err, "cannot parse rate: %s", o.Rate)
 			}
 
-			amount, err := strconv.ParseFloat(o.Amount, 64)
+			amount, err := decimal.NewFromString(o.Amount)
 			if err != nil {
 				return nil, errors.Wrapf(err, "cannot parse amount: %s", o.Amount)
 			}
@@ -479,21 +740,35 @@ func (p *PoloniexApi) ActiveOrders() ([]*models.Order, error) {
 }
 
 func (p *PoloniexApi) Order(trading string, settlement string, ordertype models.OrderType, price float64, amount float64) (string, error) {
+	return p.PlaceOrder(models.OrderRequest{Trading: trading, Settlement: settlement, Type: ordertype, Price: decimal.NewFromFloat(price), Amount: decimal.NewFromFloat(amount)})
+}
+
+func (p *PoloniexApi) PlaceOrder(req models.OrderRequest) (string, error) {
 	var cmd string
-	if ordertype == models.Ask {
+	if req.Type == models.Ask {
 		cmd = "buy"
-	} else if ordertype == models.Bid {
+	} else if req.Type == models.Bid {
 		cmd = "sell"
 	} else {
-		return "", errors.Errorf("unknown order type %d", ordertype)
+		return "", errors.Errorf("unknown order type %d", req.Type)
 	}
 
-	pair := fmt.Sprintf("%s_%s", settlement, trading)
+	pair := fmt.Sprintf("%s_%s", req.Settlement, req.Trading)
 
 	args := make(map[string]string)
 	args["currencyPair"] = pair
-	args["rate"] = strconv.FormatFloat(price, 'g', -1, 64)
-	args["amount"] = strconv.FormatFloat(amount, 'g', -1, 64)
+	args["rate"] = req.Price.String()
+	args["amount"] = req.Amount.String()
+	switch {
+	case req.ExecutionType != models.Limit:
+		return "", unsupportedOrder("poloniex", req)
+	case req.TimeInForce == models.IOC:
+		args["immediateOrCancel"] = "1"
+	case req.TimeInForce == models.FOK:
+		args["fillOrKill"] = "1"
+	case req.TimeInForce == models.PostOnly:
+		args["postOnly"] = "1"
+	}
 
 	bs, err := p.privateApi(cmd, args)
 	if err != nil {
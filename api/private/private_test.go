@@ -1,11 +1,27 @@
 package private
 
 import (
+	"bytes"
+	"compress/flate"
+	"context"
+	"errors"
+	"fmt"
+	"github.com/fxpgr/go-exchange-client/api/public/mocks"
+	"github.com/fxpgr/go-exchange-client/apierrors"
+	"github.com/fxpgr/go-exchange-client/decimal"
+	"github.com/fxpgr/go-exchange-client/exchangetest"
 	"github.com/fxpgr/go-exchange-client/models"
+	"github.com/fxpgr/go-exchange-client/retry"
+	"github.com/gorilla/websocket"
+	"github.com/stretchr/testify/mock"
 	"io/ioutil"
+	"math"
 	"net/http"
+	"net/http/httptest"
+	"net/url"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 )
@@ -146,6 +162,184 @@ func newTestPrivateClient(exchangeName string, rt http.RoundTripper) PrivateClie
 	return nil
 }
 
+// newTestServerClient returns a client of the exchange talking to the fake
+// exchange server srv.
+func newTestServerClient(exchangeName string, srv *exchangetest.Server) PrivateClient {
+	apiFunc := func() (string, error) { return srv.APIKey, nil }
+	secFunc := func() (string, error) { return srv.Secret, nil }
+	switch strings.ToLower(exchangeName) {
+	case "binance":
+		return &BinanceApi{
+			ApiKeyFunc:        apiFunc,
+			SecretKeyFunc:     secFunc,
+			BaseURL:           srv.URL,
+			RateCacheDuration: 30 * time.Second,
+			HttpClient:        *srv.Client(),
+			apiV1:             srv.URL + "/api/v1/",
+			apiV3:             srv.URL + "/api/v3/",
+			settlements:       []string{"BTC"},
+			m:                 new(sync.Mutex),
+			currencyM:         new(sync.Mutex),
+		}
+	case "huobi":
+		return &HuobiApi{
+			ApiKeyFunc:        apiFunc,
+			SecretKeyFunc:     secFunc,
+			BaseURL:           srv.URL,
+			RateCacheDuration: 30 * time.Second,
+			HttpClient:        *srv.Client(),
+			settlements:       []string{"BTC"},
+			m:                 new(sync.Mutex),
+		}
+	case "okex":
+		return &OkexApi{
+			ApiKeyFunc:        apiFunc,
+			SecretKeyFunc:     secFunc,
+			BaseURL:           srv.URL,
+			RateCacheDuration: 30 * time.Second,
+			HttpClient:        *srv.Client(),
+			settlements:       []string{"BTC"},
+			m:                 new(sync.Mutex),
+		}
+	case "kucoin":
+		return &KucoinApi{
+			ApiKeyFunc:        func() (string, error) { return srv.Passphrase + "::" + srv.APIKey, nil },
+			SecretKeyFunc:     secFunc,
+			BaseURL:           srv.URL,
+			RateCacheDuration: 30 * time.Second,
+			HttpClient:        *srv.Client(),
+			settlements:       []string{"BTC"},
+			m:                 new(sync.Mutex),
+		}
+	case "hitbtc":
+		return &HitbtcApi{
+			ApiKeyFunc:        apiFunc,
+			SecretKeyFunc:     secFunc,
+			BaseURL:           srv.URL,
+			RateCacheDuration: 30 * time.Second,
+			HttpClient:        *srv.Client(),
+			settlements:       []string{"BTC"},
+			m:                 new(sync.Mutex),
+		}
+	case "poloniex":
+		return &PoloniexApi{
+			ApiKeyFunc:        apiFunc,
+			SecretKeyFunc:     secFunc,
+			BaseURL:           srv.URL,
+			RateCacheDuration: 30 * time.Second,
+			HttpClient:        *srv.Client(),
+			m:                 new(sync.Mutex),
+		}
+	}
+	return nil
+}
+
+var d = decimal.RequireFromString
+
+func TestExchangeServers(t *testing.T) {
+	servers := map[string]*exchangetest.Server{
+		"binance":  exchangetest.NewBinanceServer("APIKEY", "SECKEY"),
+		"huobi":    exchangetest.NewHuobiServer("APIKEY", "SECKEY"),
+		"okex":     exchangetest.NewOkexServer("APIKEY", "SECKEY"),
+		"kucoin":   exchangetest.NewKucoinServer("PASSPHRASE", "APIKEY", "SECKEY"),
+		"hitbtc":   exchangetest.NewHitbtcServer("APIKEY", "SECKEY"),
+		"poloniex": exchangetest.NewPoloniexServer("APIKEY", "SECKEY"),
+	}
+	near := func(a, b float64) bool { return math.Abs(a-b) < 1e-9 }
+	for name, srv := range servers {
+		name, srv := name, srv
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			defer srv.Close()
+			srv.AddPair("ETH", "BTC", models.Precisions{PricePrecision: 6, AmountPrecision: 3})
+			srv.SetBalance("BTC", d("1"))
+			srv.SetBalance("ETH", d("10"))
+			srv.SetBoard("ETH", "BTC", &models.Board{
+				Asks: []models.BoardBar{{Price: d("0.031"), Amount: d("5")}},
+				Bids: []models.BoardBar{{Price: d("0.029"), Amount: d("5")}},
+			})
+			client := newTestServerClient(name, srv)
+
+			id, err := client.Order("ETH", "BTC", models.Ask, 0.03, 2)
+			if err != nil {
+				t.Fatal(err)
+			}
+			balances, err := client.Balances()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !near(balances["BTC"], 0.94) {
+				t.Errorf("Expected %v BTC available. Got %v", 0.94, balances["BTC"])
+			}
+			order, err := client.OrderStatus("ETH", "BTC", id)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if order.Status != models.OrderOpen || !order.Amount.Equal(d("2")) || !order.Price.Equal(d("0.03")) {
+				t.Errorf("unexpected order %+v", order)
+			}
+			if err := client.CancelOrder("ETH", "BTC", models.Ask, id); err != nil {
+				t.Fatal(err)
+			}
+			if b := srv.Balances()["BTC"]; !b.Available.Equal(d("1")) || !b.OnOrders.IsZero() {
+				t.Errorf("unexpected balance after cancel %+v", b)
+			}
+
+			if _, err := client.Order("ETH", "BTC", models.Ask, 0.031, 1); err != nil {
+				t.Fatal(err)
+			}
+			trades, err := client.MyTrades("ETH", "BTC", time.Time{}, 0)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(trades) != 1 || !trades[0].Price.Equal(d("0.031")) || !trades[0].Amount.Equal(d("1")) {
+				t.Errorf("unexpected trades %+v", trades)
+			}
+			if b := srv.Balances(); !b["BTC"].Available.Add(b["ETH"].Available.Mul(d("0.031"))).Equal(d("1.309969")) {
+				t.Errorf("unexpected balances after fill %+v", b)
+			}
+
+			if _, err := client.Order("ETH", "BTC", models.Ask, 0.03, 100); !errors.Is(err, apierrors.ErrInsufficientFunds) {
+				t.Errorf("Expected %v. Got %v", apierrors.ErrInsufficientFunds, err)
+			}
+		})
+	}
+}
+
+func TestBinanceMyTradesOrderID(t *testing.T) {
+	t.Parallel()
+	srv := exchangetest.NewBinanceServer("APIKEY", "SECKEY")
+	defer srv.Close()
+	srv.AddPair("ETH", "BTC", models.Precisions{PricePrecision: 6, AmountPrecision: 3})
+	srv.SetBalance("BTC", d("1"))
+	srv.SetBoard("ETH", "BTC", &models.Board{
+		Asks: []models.BoardBar{{Price: d("0.031"), Amount: d("5")}},
+		Bids: []models.BoardBar{{Price: d("0.029"), Amount: d("5")}},
+	})
+	client := newTestServerClient("binance", srv)
+
+	var ids []string
+	for _, amount := range []string{"1", "2"} {
+		id, err := client.PlaceOrder(models.OrderRequest{Trading: "ETH", Settlement: "BTC", Type: models.Ask, Price: d("0.031"), Amount: d(amount)})
+		if err != nil {
+			t.Fatal(err)
+		}
+		ids = append(ids, id)
+	}
+	trades, err := client.MyTrades("ETH", "BTC", time.Time{}, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(trades) != 2 {
+		t.Fatalf("BinancePrivateApi: Expected %v trades. Got %+v", 2, trades)
+	}
+	for i, trade := range trades {
+		if trade.OrderID != ids[i] {
+			t.Errorf("BinancePrivateApi: Expected order id %v. Got %v", ids[i], trade.OrderID)
+		}
+	}
+}
+
 func TestBitflyerFee(t *testing.T) {
 	t.Parallel()
 	json := `{
@@ -157,7 +351,7 @@ func TestBitflyerFee(t *testing.T) {
 	if err != nil {
 		panic(err)
 	}
-	if fee.MakerFee != 0.001 || fee.TakerFee != 0.001 {
+	if !fee.MakerFee.Equal(d("0.001")) || !fee.TakerFee.Equal(d("0.001")) {
 		t.Errorf("PoloniexPrivateApi: Expected %v %v. Got %v %v", 0.001, 0.001, fee.MakerFee, fee.TakerFee)
 	}
 	_, err = client.TransferFee()
@@ -198,7 +392,7 @@ func TestBitflyerBalances(t *testing.T) {
 		panic(err)
 	}
 
-	if balanceMap["BTC"].Available != 4.12 || balanceMap["BTC"].OnOrders != 6.12 {
+	if !balanceMap["BTC"].Available.Equal(d("4.12")) || !balanceMap["BTC"].OnOrders.Equal(d("6.12")) {
 		t.Error("BitflyerPrivateApi: balance map error")
 	}
 }
@@ -283,7 +477,7 @@ func TestPoloniexFee(t *testing.T) {
 	if err != nil {
 		panic(err)
 	}
-	if fee.MakerFee != 0.0014 || fee.TakerFee != 0.0024 {
+	if !fee.MakerFee.Equal(d("0.0014")) || !fee.TakerFee.Equal(d("0.0024")) {
 		t.Errorf("PoloniexPrivateApi: Expected %v %v. Got %v %v", 0.0014, 0.0024, fee.MakerFee, fee.TakerFee)
 	}
 	rt.message = `{"1CR":{"id":1,"name":"1CRedit","txFee":"0.01000000","minConf":3,"depositAddress":null,"disabled":0,"delisted":1,"frozen":0},"ABY":{"id":2,"name":"ArtByte","txFee":"0.01000000","minConf":8,"depositAddress":null,"disabled":0,"delisted":1,"frozen":0}}`
@@ -311,7 +505,7 @@ func TestPoloniexBalances(t *testing.T) {
 		panic(err)
 	}
 
-	if balanceMap["LTC"].Available != 5.015 || balanceMap["LTC"].OnOrders != 1.0025 {
+	if !balanceMap["LTC"].Available.Equal(d("5.015")) || !balanceMap["LTC"].OnOrders.Equal(d("1.0025")) {
 		t.Error("PoloniexPrivateApi: balance map error")
 	}
 }
@@ -371,6 +565,114 @@ func TestPoloniexOthers(t *testing.T) {
 	}
 }
 
+// unavailableOnce sends the first request on, as if the exchange handled it,
+// but answers 503 in place of its response.
+type unavailableOnce struct {
+	base http.RoundTripper
+	sent int32
+}
+
+func (rt *unavailableOnce) RoundTrip(r *http.Request) (*http.Response, error) {
+	res, err := rt.base.RoundTrip(r)
+	if err != nil || atomic.AddInt32(&rt.sent, 1) > 1 {
+		return res, err
+	}
+	res.Body.Close()
+	return &http.Response{
+		StatusCode: http.StatusServiceUnavailable,
+		Body:       ioutil.NopCloser(strings.NewReader("")),
+		Header:     make(http.Header),
+		Request:    r,
+	}, nil
+}
+
+func TestPoloniexRetryNonce(t *testing.T) {
+	srv := exchangetest.NewPoloniexServer("APIKEY", "SECKEY")
+	defer srv.Close()
+	srv.SetBalance("BTC", d("1"))
+	client := newTestServerClient("poloniex", srv).(*PoloniexApi)
+	client.HttpClient.Transport = retry.NewTransport(&unavailableOnce{base: srv.Client().Transport})
+
+	// the server saw the nonce of the failed attempt, so the retry needs a new one
+	ctx := retry.WithPolicy(context.Background(), retry.Policy{MaxAttempts: 2, BaseDelay: time.Millisecond, MaxDelay: time.Millisecond})
+	balances, err := client.WithContext(ctx).Balances()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if balances["BTC"] != 1 {
+		t.Errorf("PoloniexPrivateApi: Expected %v. Got %v", 1, balances["BTC"])
+	}
+}
+
+func TestPoloniexErrors(t *testing.T) {
+	t.Parallel()
+	json := `{"error":"Not enough BTC."}`
+	rt := &FakeRoundTripper{message: json, status: http.StatusOK}
+	client := newTestPrivateClient("poloniex", rt)
+	_, err := client.Order("ETH", "BTC", models.Ask, 0.01, 1)
+	if !errors.Is(err, apierrors.ErrInsufficientFunds) {
+		t.Errorf("PoloniexPrivateApi: Expected %v. Got %v", apierrors.ErrInsufficientFunds, err)
+	}
+	rt.message = `{"error":"Invalid order number, or you are not the person who placed the order."}`
+	err = client.CancelOrder("ETH", "BTC", models.Ask, "120466")
+	if !errors.Is(err, apierrors.ErrOrderNotFound) {
+		t.Errorf("PoloniexPrivateApi: Expected %v. Got %v", apierrors.ErrOrderNotFound, err)
+	}
+}
+
+func TestMessageKindOrder(t *testing.T) {
+	for _, c := range []struct {
+		message  string
+		patterns []messagePattern
+		want     error
+	}{
+		{"Insufficient funds to order on product_code FX_BTC_JPY", bitflyerErrorMessages, apierrors.ErrInsufficientFunds},
+		{"Invalid nonce, insufficient request data", p2pb2bErrorMessages, apierrors.ErrAuth},
+		{"Not enough BTC.", poloniexErrorMessages, apierrors.ErrInsufficientFunds},
+		{"Order not found, or not enough rights to cancel it.", poloniexErrorMessages, apierrors.ErrOrderNotFound},
+		{"unknown", poloniexErrorMessages, nil},
+	} {
+		// the kind must not depend on the iteration order of the table
+		for i := 0; i < 10; i++ {
+			if kind := messageKind(c.message, c.patterns); kind != c.want {
+				t.Fatalf("messageKind(%q): Expected %v. Got %v", c.message, c.want, kind)
+			}
+		}
+	}
+}
+
+func TestPoloniexDepositsWithdrawals(t *testing.T) {
+	t.Parallel()
+	json := `{"deposits":
+[{"currency":"BTC","address":"131rdg5Rzn6BFufnnQaHhVa5ZtRU1J2EZR","amount":"0.01006132","confirmations":10,
+"txid":"17f819a91369a9ff6c4a34216d434597cfc1b4a3d0489b46bd6f924137a47701","timestamp":1399305798,"status":"COMPLETE"},
+{"currency":"BTC","address":"131rdg5Rzn6BFufnnQaHhVa5ZtRU1J2EZR","amount":"0.00404104","confirmations":10,
+"txid":"7acb90965b252e55a894b535ef0b0b65f45821f2899e4a379d3e43799604695c","timestamp":1399245916,"status":"PENDING"}],
+"withdrawals":
+[{"withdrawalNumber":134933,"currency":"BTC","address":"1N2i5n8DwTGzUq2Vmn9TUL8J1vdr1XBDFg","amount":"5.00010000","fee":"0.00010000",
+"timestamp":1399267904,"status":"COMPLETE: 36e483efa6aff9fd53a235177579d98451c4eb237c210e66cd2b9a2d4a988f8e","ipAddress":"...","paymentID":null}]}`
+	rt := &FakeRoundTripper{message: json, status: http.StatusOK}
+	client := newTestPrivateClient("poloniex", rt)
+	deposits, err := client.Deposits(time.Unix(1399245916, 0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(deposits) != 2 || deposits[0].Status != models.TransferPending || deposits[1].Status != models.TransferCompleted {
+		t.Errorf("PoloniexPrivateApi: unexpected deposits %+v", deposits)
+	}
+	withdrawals, err := client.Withdrawals(time.Time{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(withdrawals) != 1 {
+		t.Fatalf("PoloniexPrivateApi: Expected %v. Got %v", 1, len(withdrawals))
+	}
+	if w := withdrawals[0]; w.ID != "134933" || !w.Fee.Equal(d("0.0001")) ||
+		w.TxID != "36e483efa6aff9fd53a235177579d98451c4eb237c210e66cd2b9a2d4a988f8e" || w.Status != models.TransferCompleted {
+		t.Errorf("PoloniexPrivateApi: unexpected withdrawal %+v", w)
+	}
+}
+
 func TestHitbtcBalances(t *testing.T) {
 	t.Parallel()
 	json := `[{"currency": "ETH", "available": "10.000000000", "reserved":"0.560000000"},{"currency": "BTC","available":"0.010205869","reserved": "0"}]`
@@ -388,11 +690,27 @@ func TestHitbtcBalances(t *testing.T) {
 		panic(err)
 	}
 
-	if balanceMap["ETH"].Available != 10 || balanceMap["ETH"].OnOrders != 0.56 {
+	if !balanceMap["ETH"].Available.Equal(d("10")) || !balanceMap["ETH"].OnOrders.Equal(d("0.56")) {
 		t.Error("HitbtcPrivateApi: balance map error")
 	}
 }
 
+func TestHitbtcWithContext(t *testing.T) {
+	t.Parallel()
+	json := `[{"currency": "ETH", "available": "10.000000000", "reserved":"0.560000000"}]`
+	rt := &FakeRoundTripper{message: json, status: http.StatusOK}
+	client := newTestPrivateClient("hitbtc", rt)
+	ctx, cancel := context.WithCancel(context.Background())
+	_, err := client.WithContext(ctx).Balances()
+	if err != nil {
+		t.Fatal(err)
+	}
+	cancel()
+	if len(rt.requests) != 1 || rt.requests[0].Context().Err() != context.Canceled {
+		t.Error("HitbtcPrivateApi: request is not bound to the given context")
+	}
+}
+
 func TestHitbtcOrders(t *testing.T) {
 	t.Parallel()
 	json := `[
@@ -460,6 +778,80 @@ func TestHitbtcOrder(t *testing.T) {
 	if err != nil {
 		t.Error(err)
 	}
+	rt.message = json
+	rt.Reset()
+	ctx := retry.WithClientOrderID(context.Background(), "d8574207d9e3b16a4a5511753eeef175")
+	if _, err := client.WithContext(ctx).Order("ETH", "BTC", models.Bid, 1000000, 0.01); err != nil {
+		t.Fatal(err)
+	}
+	body, _ := ioutil.ReadAll(rt.requests[0].Body)
+	if !strings.Contains(string(body), "clientOrderId=d8574207d9e3b16a4a5511753eeef175") {
+		t.Errorf("HitbtcPrivateApi: client order id is not sent: %s", body)
+	}
+}
+
+func TestHitbtcMyTrades(t *testing.T) {
+	t.Parallel()
+	json := `[
+  {
+    "id": 9535486,
+    "clientOrderId": "f8dbaab336d44d5ba3ff578098a68454",
+    "orderId": 816088377,
+    "symbol": "ETHBTC",
+    "side": "sell",
+    "quantity": "0.061",
+    "price": "0.045487",
+    "fee": "0.000002775",
+    "timestamp": "2017-05-17T12:32:57.848Z"
+  },
+  {
+    "id": 9535437,
+    "clientOrderId": "27b9bfc068b44194b1f453c7af511ed6",
+    "orderId": 816088021,
+    "symbol": "ETHBTC",
+    "side": "buy",
+    "quantity": "0.038",
+    "price": "0.046000",
+    "fee": "-0.000000174",
+    "timestamp": "2017-05-17T12:30:57.848Z"
+  }
+]`
+	rt := &FakeRoundTripper{message: json, status: http.StatusOK}
+	client := newTestPrivateClient("hitbtc", rt)
+	since := time.Date(2017, 5, 17, 12, 0, 0, 0, time.UTC)
+	trades, err := client.MyTrades("ETH", "BTC", since, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(trades) != 1 || trades[0].ID != "9535437" {
+		t.Fatalf("HitbtcPrivateApi: Expected oldest trade %v. Got %+v", "9535437", trades)
+	}
+	if trades[0].Type != models.Ask || trades[0].OrderID != "27b9bfc068b44194b1f453c7af511ed6" || trades[0].FeeCurrency != "BTC" {
+		t.Errorf("HitbtcPrivateApi: unexpected trade %+v", trades[0])
+	}
+	if q := rt.requests[0].URL.Query(); q.Get("symbol") != "ETHBTC" || q.Get("from") != "2017-05-17T12:00:00Z" {
+		t.Errorf("HitbtcPrivateApi: unexpected query %v", q)
+	}
+}
+
+func TestHitbtcErrors(t *testing.T) {
+	t.Parallel()
+	json := `{"error":{"code":20001,"message":"Insufficient funds","description":"Check that the funds are sufficient"}}`
+	rt := &FakeRoundTripper{message: json, status: http.StatusBadRequest}
+	client := newTestPrivateClient("hitbtc", rt)
+	_, err := client.Balances()
+	if !errors.Is(err, apierrors.ErrInsufficientFunds) {
+		t.Errorf("HitbtcPrivateApi: Expected %v. Got %v", apierrors.ErrInsufficientFunds, err)
+	}
+	var apiErr *apierrors.Error
+	if !errors.As(err, &apiErr) || apiErr.Code != "20001" {
+		t.Errorf("HitbtcPrivateApi: Expected code %v. Got %v", "20001", err)
+	}
+	rt.message = `{"error":{"code":429,"message":"Too many requests"}}`
+	rt.status = http.StatusTooManyRequests
+	if _, err := client.Balances(); !errors.Is(err, apierrors.ErrRateLimited) {
+		t.Errorf("HitbtcPrivateApi: Expected %v. Got %v", apierrors.ErrRateLimited, err)
+	}
 }
 
 func TestHitbtcOthers(t *testing.T) {
@@ -505,6 +897,254 @@ func TestLbankOrder(t *testing.T) {
 	}
 }
 
+// recordingTransport records the requests it forwards to next.
+type recordingTransport struct {
+	next     http.RoundTripper
+	m        sync.Mutex
+	requests []*http.Request
+}
+
+func (rt *recordingTransport) RoundTrip(r *http.Request) (*http.Response, error) {
+	rt.m.Lock()
+	rt.requests = append(rt.requests, r)
+	rt.m.Unlock()
+	return rt.next.RoundTrip(r)
+}
+
+// last returns the last request to path.
+func (rt *recordingTransport) last(path string) *http.Request {
+	rt.m.Lock()
+	defer rt.m.Unlock()
+	for i := len(rt.requests) - 1; i >= 0; i-- {
+		if rt.requests[i].URL.Path == path {
+			return rt.requests[i]
+		}
+	}
+	return nil
+}
+
+// requestParams returns the query and form parameters r was sent with.
+func requestParams(t *testing.T, r *http.Request) url.Values {
+	if r == nil {
+		t.Fatal("Expected a request")
+	}
+	params := r.URL.Query()
+	if r.GetBody == nil {
+		return params
+	}
+	body, err := r.GetBody()
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := ioutil.ReadAll(body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	form, err := url.ParseQuery(string(b))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for k, v := range form {
+		params[k] = v
+	}
+	return params
+}
+
+func orderRequest(typ models.OrderType, execution models.ExecutionType, tif models.TimeInForce, price string, amount string) models.OrderRequest {
+	req := models.OrderRequest{Trading: "ETH", Settlement: "BTC", Type: typ, ExecutionType: execution, TimeInForce: tif, Amount: d(amount)}
+	if price != "" {
+		req.Price = d(price)
+	}
+	return req
+}
+
+// placeOrderCase is an order and the parameters it must be sent with, an
+// empty one being absent. Numbers are compared as decimals.
+type placeOrderCase struct {
+	req    models.OrderRequest
+	params map[string]string
+}
+
+func checkPlaceOrder(t *testing.T, client PrivateClient, last func() *http.Request, cases []placeOrderCase) {
+	t.Helper()
+	for _, c := range cases {
+		if _, err := client.PlaceOrder(c.req); err != nil {
+			t.Fatalf("%s %s order: %v", c.req.TimeInForce, c.req.ExecutionType, err)
+		}
+		params := requestParams(t, last())
+		for k, want := range c.params {
+			if got := params.Get(k); !sameParam(got, want) {
+				t.Errorf("%s %s order: Expected %s=%q. Got %q", c.req.TimeInForce, c.req.ExecutionType, k, want, got)
+			}
+		}
+	}
+}
+
+func sameParam(got string, want string) bool {
+	if g, err := decimal.NewFromString(got); err == nil && want != "" {
+		if w, err := decimal.NewFromString(want); err == nil {
+			return g.Equal(w)
+		}
+	}
+	return got == want
+}
+
+func TestHuobiOkexPlaceOrder(t *testing.T) {
+	servers := map[string]*exchangetest.Server{
+		"huobi": exchangetest.NewHuobiServer("APIKEY", "SECKEY"),
+		"okex":  exchangetest.NewOkexServer("APIKEY", "SECKEY"),
+	}
+	for name, srv := range servers {
+		name, srv := name, srv
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			defer srv.Close()
+			srv.AddPair("ETH", "BTC", models.Precisions{PricePrecision: 6, AmountPrecision: 3})
+			srv.SetBalance("BTC", d("1"))
+			srv.SetBalance("ETH", d("10"))
+			srv.SetBoard("ETH", "BTC", &models.Board{
+				Asks: []models.BoardBar{{Price: d("0.031"), Amount: d("5")}},
+				Bids: []models.BoardBar{{Price: d("0.029"), Amount: d("5")}},
+			})
+			client := newTestServerClient(name, srv)
+			rt := &recordingTransport{next: srv.Client().Transport}
+			switch c := client.(type) {
+			case *HuobiApi:
+				c.HttpClient.Transport = rt
+			case *OkexApi:
+				c.HttpClient.Transport = rt
+			}
+			last := func() *http.Request { return rt.last("/v1/order/orders/place") }
+			checkPlaceOrder(t, client, last, []placeOrderCase{
+				{orderRequest(models.Bid, models.Limit, models.GTC, "0.04", "1"), map[string]string{"type": "sell-limit", "price": "0.04", "amount": "1"}},
+				{orderRequest(models.Ask, models.Limit, models.IOC, "0.03", "1"), map[string]string{"type": "buy-ioc", "price": "0.03", "amount": "1"}},
+				{orderRequest(models.Bid, models.Limit, models.FOK, "0.03", "1"), map[string]string{"type": "sell-limit-fok", "price": "0.03", "amount": "1"}},
+				{orderRequest(models.Ask, models.Limit, models.PostOnly, "0.02", "1"), map[string]string{"type": "buy-limit-maker", "price": "0.02", "amount": "1"}},
+				// market buys are sized in the settlement currency at the reference price
+				{orderRequest(models.Ask, models.Market, models.GTC, "0.031", "2"), map[string]string{"type": "buy-market", "price": "", "amount": "0.062"}},
+				{orderRequest(models.Bid, models.Market, models.GTC, "", "1"), map[string]string{"type": "sell-market", "price": "", "amount": "1"}},
+			})
+			if _, err := client.PlaceOrder(orderRequest(models.Ask, models.Market, models.GTC, "", "2")); err == nil {
+				t.Error("Expected a market buy without a price to fail")
+			}
+			if _, err := client.PlaceOrder(orderRequest(models.Ask, models.Market, models.IOC, "0.031", "2")); !errors.Is(err, apierrors.ErrUnsupported) {
+				t.Errorf("Expected %v. Got %v", apierrors.ErrUnsupported, err)
+			}
+		})
+	}
+}
+
+func TestHitbtcPlaceOrder(t *testing.T) {
+	t.Parallel()
+	rt := &FakeRoundTripper{message: `{"id":0,"clientOrderId":"d8574207d9e3b16a4a5511753eeef175","symbol":"ETHBTC","side":"sell","status":"new","type":"limit","timeInForce":"GTC","quantity":"1","price":"0.04","cumQuantity":"0"}`, status: http.StatusOK}
+	client := newTestPrivateClient("hitbtc", rt)
+	last := func() *http.Request { return rt.requests[len(rt.requests)-1] }
+	checkPlaceOrder(t, client, last, []placeOrderCase{
+		{orderRequest(models.Bid, models.Limit, models.GTC, "0.04", "1"), map[string]string{"side": "sell", "type": "limit", "timeInForce": "GTC", "price": "0.04", "quantity": "1"}},
+		{orderRequest(models.Ask, models.Limit, models.IOC, "0.03", "1"), map[string]string{"side": "buy", "type": "limit", "timeInForce": "IOC", "postOnly": ""}},
+		{orderRequest(models.Ask, models.Limit, models.FOK, "0.03", "1"), map[string]string{"type": "limit", "timeInForce": "FOK"}},
+		{orderRequest(models.Ask, models.Limit, models.PostOnly, "0.02", "1"), map[string]string{"type": "limit", "postOnly": "true", "timeInForce": ""}},
+		{orderRequest(models.Ask, models.Market, models.GTC, "", "2"), map[string]string{"type": "market", "price": "", "quantity": "2"}},
+	})
+	if _, err := client.PlaceOrder(orderRequest(models.Ask, models.Market, models.FOK, "", "2")); !errors.Is(err, apierrors.ErrUnsupported) {
+		t.Errorf("HitbtcPrivateApi: Expected %v. Got %v", apierrors.ErrUnsupported, err)
+	}
+}
+
+func TestPoloniexPlaceOrder(t *testing.T) {
+	t.Parallel()
+	rt := &FakeRoundTripper{message: `{"orderNumber":31226040,"resultingTrades":[]}`, status: http.StatusOK}
+	client := newTestPrivateClient("poloniex", rt)
+	last := func() *http.Request { return rt.requests[len(rt.requests)-1] }
+	checkPlaceOrder(t, client, last, []placeOrderCase{
+		{orderRequest(models.Ask, models.Limit, models.GTC, "0.03", "1"), map[string]string{"command": "buy", "currencyPair": "BTC_ETH", "rate": "0.03", "amount": "1", "immediateOrCancel": "", "fillOrKill": "", "postOnly": ""}},
+		{orderRequest(models.Bid, models.Limit, models.IOC, "0.03", "1"), map[string]string{"command": "sell", "immediateOrCancel": "1"}},
+		{orderRequest(models.Ask, models.Limit, models.FOK, "0.03", "1"), map[string]string{"command": "buy", "fillOrKill": "1"}},
+		{orderRequest(models.Bid, models.Limit, models.PostOnly, "0.04", "1"), map[string]string{"command": "sell", "postOnly": "1"}},
+	})
+	rt.Reset()
+	if _, err := client.PlaceOrder(orderRequest(models.Ask, models.Market, models.GTC, "", "1")); !errors.Is(err, apierrors.ErrUnsupported) {
+		t.Errorf("PoloniexPrivateApi: Expected %v. Got %v", apierrors.ErrUnsupported, err)
+	}
+	if len(rt.requests) != 0 {
+		t.Errorf("PoloniexPrivateApi: Expected no request for an unsupported order. Got %v", len(rt.requests))
+	}
+}
+
+func TestBitflyerPlaceOrder(t *testing.T) {
+	t.Parallel()
+	rt := &FakeRoundTripper{message: `{"child_order_acceptance_id":"JRF20150707-050237-639234"}`, status: http.StatusOK}
+	client := newTestPrivateClient("bitflyer", rt)
+	last := func() *http.Request { return rt.requests[len(rt.requests)-1] }
+	checkPlaceOrder(t, client, last, []placeOrderCase{
+		{orderRequest(models.Ask, models.Limit, models.GTC, "0.03", "1"), map[string]string{"side": "BUY", "child_order_type": "LIMIT", "time_in_force": "GTC", "price": "0.03", "size": "1"}},
+		{orderRequest(models.Ask, models.Limit, models.IOC, "0.03", "1"), map[string]string{"child_order_type": "LIMIT", "time_in_force": "IOC"}},
+		{orderRequest(models.Bid, models.Limit, models.FOK, "0.03", "1"), map[string]string{"side": "SELL", "child_order_type": "LIMIT", "time_in_force": "FOK"}},
+		{orderRequest(models.Bid, models.Market, models.GTC, "", "1"), map[string]string{"side": "SELL", "child_order_type": "MARKET", "price": "", "time_in_force": "", "size": "1"}},
+	})
+	if _, err := client.PlaceOrder(orderRequest(models.Ask, models.Limit, models.PostOnly, "0.02", "1")); !errors.Is(err, apierrors.ErrUnsupported) {
+		t.Errorf("BitflyerPrivateApi: Expected %v. Got %v", apierrors.ErrUnsupported, err)
+	}
+}
+
+func TestLbankPlaceOrder(t *testing.T) {
+	t.Parallel()
+	rt := &FakeRoundTripper{message: `{"result":"true","order_id":"123456789"}`, status: http.StatusOK}
+	client := newTestPrivateClient("lbank", rt)
+	last := func() *http.Request { return rt.requests[len(rt.requests)-1] }
+	checkPlaceOrder(t, client, last, []placeOrderCase{
+		{orderRequest(models.Bid, models.Limit, models.GTC, "0.04", "1"), map[string]string{"type": "sell", "price": "0.04", "amount": "1"}},
+		// market buys take the total in the settlement currency as price
+		{orderRequest(models.Ask, models.Market, models.GTC, "0.031", "2"), map[string]string{"type": "buy_market", "price": "0.062", "amount": ""}},
+		{orderRequest(models.Bid, models.Market, models.GTC, "", "1"), map[string]string{"type": "sell_market", "price": "", "amount": "1"}},
+	})
+	for _, tif := range []models.TimeInForce{models.IOC, models.FOK, models.PostOnly} {
+		if _, err := client.PlaceOrder(orderRequest(models.Ask, models.Limit, tif, "0.03", "1")); !errors.Is(err, apierrors.ErrUnsupported) {
+			t.Errorf("LbankPrivateApi: Expected %v for %s. Got %v", apierrors.ErrUnsupported, tif, err)
+		}
+	}
+}
+
+func TestLbankOrderStatus(t *testing.T) {
+	t.Parallel()
+	json := `{
+  "result":"true",
+  "orders":[
+    {
+      "symbol":"eth_btc",
+      "amount":10.0,
+      "create_time":1526030100000,
+      "price":0.05,
+      "avg_price":0.049,
+      "type":"sell",
+      "order_id":"123456789",
+      "deal_amount":4.0,
+      "status":1
+    }
+  ]
+}`
+	rt := &FakeRoundTripper{message: json, status: http.StatusOK}
+	client := newTestPrivateClient("lbank", rt)
+	order, err := client.OrderStatus("ETH", "BTC", "123456789")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if order.Status != models.OrderPartiallyFilled {
+		t.Errorf("LbankPrivateApi: Expected %v. Got %v", models.OrderPartiallyFilled, order.Status)
+	}
+	if order.Type != models.Bid || !order.RemainingAmount().Equal(d("6")) || !order.AveragePrice.Equal(d("0.049")) {
+		t.Errorf("LbankPrivateApi: unexpected order %+v", order)
+	}
+	if !order.CreatedAt.Equal(time.Unix(1526030100, 0)) {
+		t.Errorf("LbankPrivateApi: Expected %v. Got %v", time.Unix(1526030100, 0), order.CreatedAt)
+	}
+
+	rt.message = `{"result":"true","orders":[]}`
+	if _, err := client.OrderStatus("ETH", "BTC", "1"); !errors.Is(err, apierrors.ErrOrderNotFound) {
+		t.Errorf("LbankPrivateApi: Expected %v. Got %v", apierrors.ErrOrderNotFound, err)
+	}
+}
+
 func TestLbankBalances(t *testing.T) {
 	t.Parallel()
 	json := `{"result":"true","info":{"freeze":{"btc":1,"zec":0,"cny":80000},"asset":{"net":95678.25},"free":{"btc":2,"zec":0,"cny":34}}}`
@@ -522,7 +1162,7 @@ func TestLbankBalances(t *testing.T) {
 		panic(err)
 	}
 
-	if balanceMap["BTC"].Available != 2.0 || balanceMap["BTC"].OnOrders != 1.0 {
+	if !balanceMap["BTC"].Available.Equal(d("2")) || !balanceMap["BTC"].OnOrders.Equal(d("1")) {
 		t.Error("LbankPrivateApi: balance map error")
 	}
 }
@@ -575,6 +1215,26 @@ func TestKucoinOrder(t *testing.T) {
 	}
 }
 
+func TestBinanceOrderStatusPendingCancel(t *testing.T) {
+	for _, c := range []struct {
+		filled string
+		want   models.OrderStatus
+	}{
+		{"0.00000000", models.OrderOpen},
+		{"1.00000000", models.OrderPartiallyFilled},
+	} {
+		json := `{"symbol":"ETHBTC","orderId":28,"clientOrderId":"6gCrw2kRUAF9CvJDGP16IP","price":"0.10000000","origQty":"2.00000000","executedQty":"` + c.filled + `","cummulativeQuoteQty":"0.10000000","status":"PENDING_CANCEL","side":"BUY","time":1499827319559,"updateTime":1499827319559}`
+		client := newTestPrivateClient("binance", &FakeRoundTripper{message: json, status: http.StatusOK})
+		order, err := client.OrderStatus("ETH", "BTC", "6gCrw2kRUAF9CvJDGP16IP")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if order.Status != c.want {
+			t.Errorf("BinancePrivateApi: Expected %v with %v filled. Got %v", c.want, c.filled, order.Status)
+		}
+	}
+}
+
 func TestBinanceOrder(t *testing.T) {
 	t.Parallel()
 	jsonPrecision := `{"timezone":"UTC","serverTime":1508631584636,"rateLimits":[{"rateLimitType":"REQUESTS_WEIGHT","interval":"MINUTE","limit":1200},{"rateLimitType":"ORDERS","interval":"SECOND","limit":10},{"rateLimitType":"ORDERS","interval":"DAY","limit":100000}],"exchangeFilters":[],"symbols":[{"symbol":"ETHBTC","status":"TRADING","baseAsset":"ETH","baseAssetPrecision":8,"quoteAsset":"BTC","quotePrecision":8,"orderTypes":["LIMIT","MARKET"],"icebergAllowed":false,"filters":[{"filterType":"PRICE_FILTER","minPrice":"0.00000100","maxPrice":"100000.00000000","tickSize":"0.00000100"},{"filterType":"LOT_SIZE","minQty":"0.00100000","maxQty":"100000.00000000","stepSize":"0.00100000"},{"filterType":"MIN_NOTIONAL","minNotional":"0.00100000"}]}]}`
@@ -626,4 +1286,588 @@ func TestBinanceOrder(t *testing.T) {
 	if err != nil {
 		t.Error(err)
 	}
+	rt.message = json
+	rt.Reset()
+	_, err = client.PlaceOrder(models.OrderRequest{Trading: "ETH", Settlement: "BTC", Type: models.Ask, ExecutionType: models.Market, Amount: d("0.01")})
+	if err != nil {
+		t.Error(err)
+	}
+	query := rt.requests[0].URL.Query()
+	if query.Get("type") != "MARKET" || query.Get("price") != "" || query.Get("timeInForce") != "" {
+		t.Errorf("BinanceApi: unexpected market order %s", rt.requests[0].URL.RawQuery)
+	}
+	_, err = client.PlaceOrder(models.OrderRequest{Trading: "ETH", Settlement: "BTC", Type: models.Ask, ExecutionType: models.Market, TimeInForce: models.FOK, Amount: d("0.01")})
+	if !errors.Is(err, apierrors.ErrUnsupported) {
+		t.Errorf("BinanceApi: Expected %v. Got %v", apierrors.ErrUnsupported, err)
+	}
+}
+
+func TestPaperOrder(t *testing.T) {
+	board := &models.Board{
+		Asks: []models.BoardBar{{Price: d("101"), Amount: d("2")}, {Price: d("100"), Amount: d("1")}},
+		Bids: []models.BoardBar{{Price: d("99"), Amount: d("1")}},
+	}
+	pub := new(mocks.PublicClient)
+	pub.On("WithContext", mock.Anything).Return(pub)
+	pub.On("Board", "BTC", "USD").Return(func(string, string) *models.Board { return board }, nil)
+	client := NewPaperApi(pub, map[string]decimal.Decimal{"USD": d("1000")}, TradeFee{MakerFee: d("0.001"), TakerFee: d("0.002")})
+	near := func(a, b float64) bool { return math.Abs(a-b) < 1e-9 }
+
+	id, err := client.Order("BTC", "USD", models.Ask, 100.5, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	usd, _ := client.CompleteBalance("USD")
+	btc, _ := client.CompleteBalance("BTC")
+	if !usd.Available.Equal(d("799.5")) || !usd.OnOrders.Equal(d("100.5")) || !btc.Available.Equal(d("0.998")) {
+		t.Errorf("PaperApi: unexpected balances USD %+v BTC %+v", usd, btc)
+	}
+
+	board = &models.Board{Asks: []models.BoardBar{{Price: d("100.2"), Amount: d("3")}}, Bids: []models.BoardBar{{Price: d("99"), Amount: d("1")}}}
+	order, err := client.OrderStatus("BTC", "USD", id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if order.Status != models.OrderFilled || !order.AveragePrice.Equal(d("100.25")) || !order.Fee.Equal(d("0.003")) {
+		t.Errorf("PaperApi: unexpected order %+v", order)
+	}
+
+	_, err = client.PlaceOrder(models.OrderRequest{Trading: "BTC", Settlement: "USD", Type: models.Bid, ExecutionType: models.Market, Amount: d("1")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	balances, _ := client.Balances()
+	if !near(balances["USD"], 799.5+99*0.998) || !near(balances["BTC"], 0.997) {
+		t.Errorf("PaperApi: unexpected balances %v", balances)
+	}
+	trades, _ := client.MyTrades("BTC", "USD", time.Time{}, 0)
+	if len(trades) != 3 {
+		t.Errorf("PaperApi: Expected %v trades. Got %v", 3, len(trades))
+	}
+
+	if _, err := client.Order("BTC", "USD", models.Ask, 100, 100); !errors.Is(err, apierrors.ErrInsufficientFunds) {
+		t.Errorf("PaperApi: Expected %v. Got %v", apierrors.ErrInsufficientFunds, err)
+	}
+	if err := client.CancelOrder("BTC", "USD", models.Ask, id); !errors.Is(err, apierrors.ErrOrderNotFound) {
+		t.Errorf("PaperApi: Expected %v. Got %v", apierrors.ErrOrderNotFound, err)
+	}
+}
+
+func TestPaperMatchPriority(t *testing.T) {
+	board := &models.Board{
+		Asks: []models.BoardBar{{Price: d("110"), Amount: d("1")}},
+		Bids: []models.BoardBar{{Price: d("90"), Amount: d("1")}},
+	}
+	var m sync.Mutex
+	pub := new(mocks.PublicClient)
+	pub.On("WithContext", mock.Anything).Return(pub)
+	pub.On("Board", "BTC", "USD").Return(func(string, string) *models.Board {
+		m.Lock()
+		defer m.Unlock()
+		return board
+	}, nil)
+	client := NewPaperApi(pub, map[string]decimal.Decimal{"USD": d("1000"), "BTC": d("1")}, TradeFee{})
+
+	low, err := client.Order("BTC", "USD", models.Ask, 100, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sell, err := client.Order("BTC", "USD", models.Bid, 105, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	high, err := client.Order("BTC", "USD", models.Ask, 102, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// only one of the buys can take the new ask, the one with the higher price
+	m.Lock()
+	board = &models.Board{
+		Asks: []models.BoardBar{{Price: d("99"), Amount: d("1")}},
+		Bids: []models.BoardBar{{Price: d("90"), Amount: d("1")}},
+	}
+	m.Unlock()
+	expected := map[string]models.OrderStatus{high: models.OrderFilled, low: models.OrderOpen, sell: models.OrderOpen}
+	for id, status := range expected {
+		order, err := client.OrderStatus("BTC", "USD", id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if order.Status != status {
+			t.Errorf("PaperApi: Expected order at %v to be %v. Got %v", order.Price, status, order.Status)
+		}
+	}
+}
+
+func TestFloorFloat64ToStr(t *testing.T) {
+	tests := []struct {
+		num      float64
+		dig      int
+		expected string
+	}{
+		{0.29, 2, "0.29"},
+		{1.999, 2, "1.99"},
+		{0.123456789, 8, "0.12345678"},
+		{5, 3, "5.000"},
+		{12.7, 0, "12"},
+	}
+	for _, test := range tests {
+		if s := FloorFloat64ToStr(test.num, test.dig); s != test.expected {
+			t.Errorf("%v %v: Expected %v. Got %v", test.num, test.dig, test.expected, s)
+		}
+	}
+}
+
+func TestValidatorOrder(t *testing.T) {
+	pub := new(mocks.PublicClient)
+	pub.On("WithContext", mock.Anything).Return(pub)
+	pub.On("Board", "BTC", "USD").Return(&models.Board{
+		Asks: []models.BoardBar{{Price: d("110"), Amount: d("1")}},
+		Bids: []models.BoardBar{{Price: d("90"), Amount: d("1")}},
+	}, nil)
+	pub.On("Rules", "BTC", "USD").Return(&models.MarketRules{TickSize: d("0.5"), StepSize: d("0.001"), MinAmount: d("0.01"), MinNotional: d("10")}, nil)
+	paper := NewPaperApi(pub, map[string]decimal.Decimal{"USD": d("100"), "BTC": d("1")}, TradeFee{})
+	client := NewValidator(paper, pub)
+
+	if _, err := client.Order("BTC", "USD", models.Ask, 100.3, 0.5019); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := client.Order("BTC", "USD", models.Bid, 100.3, 0.2); err != nil {
+		t.Fatal(err)
+	}
+	orders, _ := paper.ActiveOrders()
+	prices := map[models.OrderType]string{}
+	for _, o := range orders {
+		prices[o.Type] = o.Price.String()
+		if o.Type == models.Ask && !o.Amount.Equal(d("0.501")) {
+			t.Errorf("Validator: Expected amount %v. Got %v", 0.501, o.Amount)
+		}
+	}
+	if prices[models.Ask] != "100" || prices[models.Bid] != "100.5" {
+		t.Errorf("Validator: unexpected prices %v", prices)
+	}
+
+	tests := []struct {
+		req  models.OrderRequest
+		kind error
+		rule string
+	}{
+		{models.OrderRequest{Type: models.Bid, Price: d("100"), Amount: d("0.005")}, apierrors.ErrInvalidOrder, "amount below minimum"},
+		{models.OrderRequest{Type: models.Bid, Price: d("100"), Amount: d("0.05")}, apierrors.ErrInvalidOrder, "notional below minimum"},
+		{models.OrderRequest{Type: models.Ask, Price: d("100"), Amount: d("0.5")}, apierrors.ErrInsufficientFunds, "USD needed above available"},
+		{models.OrderRequest{Type: models.Bid, ExecutionType: models.Market, Amount: d("0.9")}, apierrors.ErrInsufficientFunds, "BTC needed above available"},
+	}
+	for _, test := range tests {
+		test.req.Trading, test.req.Settlement = "BTC", "USD"
+		_, err := client.PlaceOrder(test.req)
+		var orderErr *OrderError
+		if !errors.Is(err, test.kind) || !errors.As(err, &orderErr) || orderErr.Rule != test.rule {
+			t.Errorf("Validator %+v: Expected %v %v. Got %v", test.req, test.kind, test.rule, err)
+		}
+	}
+}
+
+// newTestStreamServer serves WebSocket connections by serve, numbering them
+// from 1, and other requests by rest.
+func newTestStreamServer(t *testing.T, rest http.HandlerFunc, serve func(r *http.Request, conn *websocket.Conn, n int)) *httptest.Server {
+	var upgrader websocket.Upgrader
+	var n int
+	var m sync.Mutex
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !websocket.IsWebSocketUpgrade(r) {
+			rest(w, r)
+			return
+		}
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		defer conn.Close()
+		m.Lock()
+		n++
+		i := n
+		m.Unlock()
+		serve(r, conn, i)
+	}))
+}
+
+func wsURL(srv *httptest.Server) string {
+	return "ws" + strings.TrimPrefix(srv.URL, "http")
+}
+
+// nextEvents returns the next n events received from ch.
+func nextEvents(t *testing.T, ch <-chan models.UserEvent, n int) []models.UserEvent {
+	var events []models.UserEvent
+	timeout := time.After(5 * time.Second)
+	for len(events) < n {
+		select {
+		case e, open := <-ch:
+			if !open {
+				t.Fatal("event channel closed")
+			}
+			events = append(events, e)
+		case <-timeout:
+			t.Fatalf("timed out waiting for events, got %+v", events)
+		}
+	}
+	return events
+}
+
+func TestBinanceUserStream(t *testing.T) {
+	var keys int
+	var m sync.Mutex
+	srv := newTestStreamServer(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/v3/userDataStream" || r.Header.Get("X-MBX-APIKEY") != "APIKEY" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		m.Lock()
+		keys++
+		fmt.Fprintf(w, `{"listenKey":"key%d"}`, keys)
+		m.Unlock()
+	}, func(r *http.Request, conn *websocket.Conn, n int) {
+		if r.URL.Path != fmt.Sprintf("/ws/key%d", n) {
+			t.Errorf("BinanceUserStream: unexpected path %s", r.URL.Path)
+			return
+		}
+		events := []string{
+			`{"e":"executionReport","E":1499405658658,"s":"ETHBTC","c":"order1","S":"BUY","o":"LIMIT","q":"1.00000000","p":"0.10264410","x":"NEW","X":"NEW","i":4293153,"l":"0.00000000","z":"0.00000000","L":"0.00000000","n":"0","N":null,"T":1499405658657,"t":-1,"O":1499405658657,"Z":"0.00000000","C":""}`,
+			`{"e":"executionReport","E":1499405658700,"s":"ETHBTC","c":"order1","S":"BUY","o":"LIMIT","q":"1.00000000","p":"0.10264410","x":"TRADE","X":"PARTIALLY_FILLED","i":4293153,"l":"0.40000000","z":"0.40000000","L":"0.10264410","n":"0.00040000","N":"ETH","T":1499405658699,"t":12345,"O":1499405658657,"Z":"0.04105764","C":""}`,
+			`{"e":"outboundAccountPosition","E":1564034571105,"u":1564034571073,"B":[{"a":"ETH","f":"10.40000000","l":"0.00000000"}]}`,
+			`{"e":"listenKeyExpired","E":1576653824250}`,
+		}
+		if n > 1 {
+			events = []string{`{"e":"executionReport","E":1499405659000,"s":"ETHBTC","c":"cancel1","S":"BUY","o":"LIMIT","q":"1.00000000","p":"0.10264410","x":"CANCELED","X":"CANCELED","i":4293153,"l":"0.00000000","z":"0.40000000","L":"0.00000000","n":"0","N":null,"T":1499405658999,"t":-1,"O":1499405658657,"Z":"0.04105764","C":"order1"}`}
+		}
+		for _, e := range events {
+			conn.WriteMessage(websocket.TextMessage, []byte(e))
+		}
+		conn.ReadMessage()
+	})
+	defer srv.Close()
+
+	client := newTestPrivateClient("binance", http.DefaultTransport).(*BinanceApi)
+	client.BaseURL = srv.URL
+	s := NewBinanceUserStream(client)
+	s.URL = wsURL(srv) + "/ws/"
+	s.Conn.MinBackoff = 10 * time.Millisecond
+	defer s.Close()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	ch, err := s.Subscribe(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	events := nextEvents(t, ch, 5)
+	if e := events[0]; e.Type != models.OrderUpdate || e.Order.ExchangeOrderID != "order1" || e.Order.Status != models.OrderOpen ||
+		e.Order.Trading != "ETH" || e.Order.Type != models.Ask || e.Order.Price.String() != "0.1026441" {
+		t.Errorf("BinanceUserStream: unexpected event %+v %+v", e, e.Order)
+	}
+	if e := events[1]; e.Type != models.OrderUpdate || e.Order.Status != models.OrderPartiallyFilled || e.Order.FilledAmount.String() != "0.4" {
+		t.Errorf("BinanceUserStream: unexpected event %+v %+v", e, e.Order)
+	}
+	if e := events[2]; e.Type != models.Fill || e.Trade.ID != "12345" || e.Trade.OrderID != "order1" ||
+		e.Trade.Amount.String() != "0.4" || e.Trade.Fee.String() != "0.0004" || e.Trade.FeeCurrency != "ETH" {
+		t.Errorf("BinanceUserStream: unexpected event %+v %+v", e, e.Trade)
+	}
+	if e := events[3]; e.Type != models.BalanceChange || e.Currency != "ETH" || e.Balance.Available.String() != "10.4" {
+		t.Errorf("BinanceUserStream: unexpected event %+v %+v", e, e.Balance)
+	}
+	// the expired listen key is replaced on the next connection
+	if e := events[4]; e.Type != models.OrderUpdate || e.Order.ExchangeOrderID != "order1" || e.Order.Status != models.OrderCanceled {
+		t.Errorf("BinanceUserStream: unexpected event %+v %+v", e, e.Order)
+	}
+	cancel()
+	for range ch {
+	}
+}
+
+func TestHuobiUserStream(t *testing.T) {
+	srv := newTestStreamServer(t, nil, func(r *http.Request, conn *websocket.Conn, n int) {
+		var auth struct {
+			Action string
+			Ch     string
+			Params map[string]string
+		}
+		if err := conn.ReadJSON(&auth); err != nil || auth.Ch != "auth" {
+			return
+		}
+		params := url.Values{}
+		for _, k := range []string{"accessKey", "signatureMethod", "signatureVersion", "timestamp"} {
+			params.Set(k, auth.Params[k])
+		}
+		sign, _ := GetParamHmacSHA256Base64Sign("SECKEY", "GET\n"+r.Host+"\n/ws/v2\n"+params.Encode())
+		if auth.Params["signature"] != sign || auth.Params["accessKey"] != "APIKEY" {
+			t.Errorf("HuobiUserStream: unexpected signature %+v", auth.Params)
+		}
+		if n == 1 {
+			// the stream authenticates again on the next connection
+			conn.WriteMessage(websocket.TextMessage, []byte(`{"action":"req","code":2002,"ch":"auth","message":"invalid.auth.state"}`))
+			conn.ReadMessage()
+			return
+		}
+		conn.WriteMessage(websocket.TextMessage, []byte(`{"action":"req","code":200,"ch":"auth","data":{}}`))
+		for i := 0; i < len(huobiUserTopics); i++ {
+			if _, _, err := conn.ReadMessage(); err != nil {
+				return
+			}
+		}
+		conn.WriteMessage(websocket.TextMessage, []byte(`{"action":"ping","data":{"ts":1575537778295}}`))
+		if _, msg, err := conn.ReadMessage(); err != nil || string(msg) != `{"action":"pong","data":{"ts":1575537778295}}`+"\n" {
+			t.Errorf("HuobiUserStream: unexpected pong %s", msg)
+		}
+		for _, msg := range []string{
+			`{"action":"push","ch":"orders#ethbtc","data":{"orderSize":"2","orderCreateTime":1583853365586,"orderPrice":"0.03","type":"sell-limit","orderId":27163533,"clientOrderId":"","orderStatus":"submitted","symbol":"ethbtc","eventType":"creation"}}`,
+			`{"action":"push","ch":"trade.clearing#ethbtc#0","data":{"eventType":"trade","symbol":"ethbtc","orderId":27163533,"tradePrice":"0.03","tradeVolume":"0.5","orderSide":"sell","orderType":"sell-limit","aggressor":false,"tradeId":919219323232,"tradeTime":1583853365700,"transactFee":"0.00003","feeCurrency":"btc"}}`,
+			`{"action":"push","ch":"accounts.update#1","data":{"currency":"eth","accountId":123456,"balance":"23.111","available":"21.111","changeType":"order.place","accountType":"trade","changeTime":1583853365586}}`,
+		} {
+			conn.WriteMessage(websocket.TextMessage, []byte(msg))
+		}
+		conn.ReadMessage()
+	})
+	defer srv.Close()
+
+	client := &HuobiApi{
+		ApiKeyFunc:    func() (string, error) { return "APIKEY", nil },
+		SecretKeyFunc: func() (string, error) { return "SECKEY", nil },
+		settlements:   []string{"BTC"},
+		m:             new(sync.Mutex),
+	}
+	s := NewHuobiUserStream(client)
+	s.Conn.URL = wsURL(srv) + "/ws/v2"
+	s.Conn.MinBackoff = 10 * time.Millisecond
+	defer s.Close()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	ch, err := s.Subscribe(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	events := nextEvents(t, ch, 3)
+	if e := events[0]; e.Type != models.OrderUpdate || e.Order.ExchangeOrderID != "27163533" || e.Order.Type != models.Bid ||
+		e.Order.Status != models.OrderOpen || e.Order.Amount.String() != "2" || e.Order.Trading != "ETH" {
+		t.Errorf("HuobiUserStream: unexpected event %+v %+v", e, e.Order)
+	}
+	if e := events[1]; e.Type != models.Fill || e.Trade.ID != "919219323232" || e.Trade.Amount.String() != "0.5" || e.Trade.FeeCurrency != "BTC" {
+		t.Errorf("HuobiUserStream: unexpected event %+v %+v", e, e.Trade)
+	}
+	if e := events[2]; e.Type != models.BalanceChange || e.Currency != "ETH" || e.Balance.Available.String() != "21.111" || e.Balance.OnOrders.String() != "2" {
+		t.Errorf("HuobiUserStream: unexpected event %+v %+v", e, e.Balance)
+	}
+	cancel()
+	for range ch {
+	}
+}
+
+func TestOkexUserStream(t *testing.T) {
+	deflated := func(msg string) []byte {
+		var buf bytes.Buffer
+		w, _ := flate.NewWriter(&buf, flate.DefaultCompression)
+		w.Write([]byte(msg))
+		w.Close()
+		return buf.Bytes()
+	}
+	srv := newTestStreamServer(t, nil, func(r *http.Request, conn *websocket.Conn, n int) {
+		var login struct {
+			Op   string
+			Args []string
+		}
+		if err := conn.ReadJSON(&login); err != nil || login.Op != "login" || len(login.Args) != 4 {
+			t.Errorf("OkexUserStream: unexpected login %+v", login)
+			return
+		}
+		sign, _ := GetParamHmacSHA256Base64Sign("SECKEY", login.Args[2]+"GET/users/self/verify")
+		if login.Args[0] != "APIKEY" || login.Args[1] != "PASSPHRASE" || login.Args[3] != sign {
+			t.Errorf("OkexUserStream: unexpected login %+v", login)
+		}
+		conn.WriteMessage(websocket.BinaryMessage, deflated(`{"event":"login","success":true}`))
+		var subscribe struct {
+			Op   string
+			Args []string
+		}
+		if err := conn.ReadJSON(&subscribe); err != nil {
+			return
+		}
+		if strings.Join(subscribe.Args, ",") != "spot/order:ETH-BTC,spot/account:ETH,spot/account:BTC" {
+			t.Errorf("OkexUserStream: unexpected subscription %+v", subscribe)
+		}
+		for _, msg := range []string{
+			`{"table":"spot/order","data":[{"client_oid":"","filled_notional":"0.0015","filled_size":"0.05","instrument_id":"ETH-BTC","last_fill_px":"0.03","last_fill_qty":"0.05","last_fill_time":"2019-07-04T08:20:24.115Z","last_fill_id":"9876","margin_trading":"1","notional":"","order_id":"3150436826412032","order_type":"0","price":"0.03","side":"buy","size":"0.1","state":"1","status":"part_filled","timestamp":"2019-07-04T08:20:24.115Z","created_at":"2019-07-04T08:20:20.000Z","type":"limit"}]}`,
+			`{"table":"spot/account","data":[{"balance":"2.215374581911","available":"1.632774581911","currency":"BTC","id":"","hold":"0.5826"}]}`,
+		} {
+			conn.WriteMessage(websocket.BinaryMessage, deflated(msg))
+		}
+		conn.ReadMessage()
+	})
+	defer srv.Close()
+
+	client := &OkexApi{
+		ApiKeyFunc:    func() (string, error) { return "APIKEY", nil },
+		SecretKeyFunc: func() (string, error) { return "SECKEY", nil },
+		m:             new(sync.Mutex),
+	}
+	s := NewOkexUserStream(client, func() (string, error) { return "PASSPHRASE", nil }, []models.CurrencyPair{{Trading: "ETH", Settlement: "BTC"}})
+	s.Conn.URL = wsURL(srv)
+	defer s.Close()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	ch, err := s.Subscribe(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	events := nextEvents(t, ch, 3)
+	if e := events[0]; e.Type != models.OrderUpdate || e.Order.ExchangeOrderID != "3150436826412032" || e.Order.Status != models.OrderPartiallyFilled ||
+		e.Order.Settlement != "BTC" || e.Order.AveragePrice.String() != "0.03" {
+		t.Errorf("OkexUserStream: unexpected event %+v %+v", e, e.Order)
+	}
+	if e := events[1]; e.Type != models.Fill || e.Trade.ID != "9876" || e.Trade.Amount.String() != "0.05" || e.Trade.Type != models.Ask {
+		t.Errorf("OkexUserStream: unexpected event %+v %+v", e, e.Trade)
+	}
+	if e := events[2]; e.Type != models.BalanceChange || e.Currency != "BTC" || e.Balance.OnOrders.String() != "0.5826" {
+		t.Errorf("OkexUserStream: unexpected event %+v %+v", e, e.Balance)
+	}
+	cancel()
+	for range ch {
+	}
+}
+
+func TestKucoinUserStream(t *testing.T) {
+	var srv *httptest.Server
+	srv = newTestStreamServer(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/v1/bullet-private" || r.Header.Get("KC-API-PASSPHRASE") != "PASS" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		fmt.Fprintf(w, `{"code":"200000","data":{"token":"TOKEN","instanceServers":[{"endpoint":"%s/endpoint","protocol":"websocket","encrypt":false,"pingInterval":20,"pingTimeout":10000}]}}`, wsURL(srv))
+	}, func(r *http.Request, conn *websocket.Conn, n int) {
+		if r.URL.Query().Get("token") != "TOKEN" {
+			t.Errorf("KucoinUserStream: unexpected query %s", r.URL.RawQuery)
+		}
+		conn.WriteMessage(websocket.TextMessage, []byte(`{"id":"hQvf8jkno","type":"welcome"}`))
+		topics := make(map[string]bool)
+		pinged := false
+		for len(topics) < len(kucoinUserTopics) || !pinged {
+			var msg struct {
+				ID    string
+				Type  string
+				Topic string
+			}
+			if err := conn.ReadJSON(&msg); err != nil {
+				return
+			}
+			switch msg.Type {
+			case "subscribe":
+				topics[msg.Topic] = true
+				conn.WriteMessage(websocket.TextMessage, []byte(`{"id":"`+msg.ID+`","type":"ack"}`))
+			case "ping":
+				pinged = true
+				conn.WriteMessage(websocket.TextMessage, []byte(`{"id":"`+msg.ID+`","type":"pong"}`))
+			}
+		}
+		for _, msg := range []string{
+			`{"type":"message","topic":"/spotMarket/tradeOrders","subject":"orderChange","channelType":"private","data":{"symbol":"ETH-BTC","orderType":"limit","side":"sell","orderId":"5efab07953bdea00089965fa","liquidity":"maker","type":"match","orderTime":1593487481683297666,"size":"0.1","filledSize":"0.04","price":"0.03","matchPrice":"0.03","matchSize":"0.04","tradeId":"5efab07a4ee4c7000a82d6d9","clientOid":"","remainSize":"0.06","status":"match","ts":1593487482038606180}}`,
+			`{"type":"message","topic":"/account/balance","subject":"account.balance","channelType":"private","data":{"total":"88","available":"88","availableChange":"88","currency":"KCS","hold":"0","holdChange":"0","relationEvent":"trade.setted","relationEventId":"5c21e80303aa677bd09d7dff","time":"1545743136994"}}`,
+		} {
+			conn.WriteMessage(websocket.TextMessage, []byte(msg))
+		}
+		conn.ReadMessage()
+	})
+	defer srv.Close()
+
+	client := newTestPrivateClient("kucoin", http.DefaultTransport).(*KucoinApi)
+	client.ApiKeyFunc = func() (string, error) { return "PASS::APIKEY", nil }
+	client.BaseURL = srv.URL
+	s := NewKucoinUserStream(client)
+	defer s.Close()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	ch, err := s.Subscribe(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	events := nextEvents(t, ch, 3)
+	if e := events[0]; e.Type != models.OrderUpdate || e.Order.ExchangeOrderID != "5efab07953bdea00089965fa" || e.Order.Type != models.Bid ||
+		e.Order.Status != models.OrderPartiallyFilled || e.Order.Trading != "ETH" {
+		t.Errorf("KucoinUserStream: unexpected event %+v %+v", e, e.Order)
+	}
+	if e := events[1]; e.Type != models.Fill || e.Trade.ID != "5efab07a4ee4c7000a82d6d9" || e.Trade.Amount.String() != "0.04" {
+		t.Errorf("KucoinUserStream: unexpected event %+v %+v", e, e.Trade)
+	}
+	if e := events[2]; e.Type != models.BalanceChange || e.Currency != "KCS" || e.Balance.Available.String() != "88" || e.Time.Unix() != 1545743136 {
+		t.Errorf("KucoinUserStream: unexpected event %+v %+v", e, e.Balance)
+	}
+	cancel()
+	for range ch {
+	}
+}
+
+func TestHitbtcUserStream(t *testing.T) {
+	srv := newTestStreamServer(t, nil, func(r *http.Request, conn *websocket.Conn, n int) {
+		var req struct {
+			Method string
+			Params map[string]string
+			ID     int64
+		}
+		reply := func(result string) bool {
+			if err := conn.ReadJSON(&req); err != nil {
+				return false
+			}
+			conn.WriteMessage(websocket.TextMessage, []byte(fmt.Sprintf(`{"jsonrpc":"2.0","result":%s,"id":%d}`, result, req.ID)))
+			return true
+		}
+		if !reply("true") {
+			return
+		}
+		if req.Method != "login" || req.Params["pKey"] != "APIKEY" || req.Params["sKey"] != "SECKEY" {
+			t.Errorf("HitbtcUserStream: unexpected login %+v", req)
+		}
+		if !reply("true") || req.Method != "subscribeReports" {
+			t.Errorf("HitbtcUserStream: unexpected request %+v", req)
+		}
+		if !reply(`[{"currency":"BTC","available":"1","reserved":"0"},{"currency":"ETH","available":"0","reserved":"0"}]`) || req.Method != "getTradingBalance" {
+			t.Errorf("HitbtcUserStream: unexpected request %+v", req)
+		}
+		conn.WriteMessage(websocket.TextMessage, []byte(`{"jsonrpc":"2.0","method":"report","params":{"id":"4345697765","clientOrderId":"53b7cf917963464a811a4af426102c19","symbol":"ETHBTC","side":"buy","status":"filled","type":"limit","timeInForce":"GTC","quantity":"0.5","price":"0.03","cumQuantity":"0.5","createdAt":"2017-10-20T12:20:05.952Z","updatedAt":"2017-10-20T12:20:38.708Z","reportType":"trade","tradeQuantity":"0.5","tradePrice":"0.03","tradeId":55051,"tradeFee":"0.000015"}}`))
+		reply(`[{"currency":"BTC","available":"0.984985","reserved":"0"},{"currency":"ETH","available":"0.5","reserved":"0"}]`)
+		conn.ReadMessage()
+	})
+	defer srv.Close()
+
+	client := newTestPrivateClient("hitbtc", http.DefaultTransport).(*HitbtcApi)
+	s := NewHitbtcUserStream(client)
+	s.Conn.URL = wsURL(srv)
+	defer s.Close()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	ch, err := s.Subscribe(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	events := nextEvents(t, ch, 5)
+	if e := events[0]; e.Type != models.BalanceChange || e.Currency != "BTC" || e.Balance.Available.String() != "1" {
+		t.Errorf("HitbtcUserStream: unexpected event %+v %+v", e, e.Balance)
+	}
+	if e := events[1]; e.Type != models.OrderUpdate || e.Order.ExchangeOrderID != "53b7cf917963464a811a4af426102c19" || e.Order.Status != models.OrderFilled {
+		t.Errorf("HitbtcUserStream: unexpected event %+v %+v", e, e.Order)
+	}
+	if e := events[2]; e.Type != models.Fill || e.Trade.ID != "55051" || e.Trade.Fee.String() != "0.000015" {
+		t.Errorf("HitbtcUserStream: unexpected event %+v %+v", e, e.Trade)
+	}
+	changed := map[string]string{}
+	for _, e := range events[3:] {
+		changed[e.Currency] = e.Balance.Available.String()
+	}
+	if changed["BTC"] != "0.984985" || changed["ETH"] != "0.5" {
+		t.Errorf("HitbtcUserStream: unexpected balance changes %v", changed)
+	}
+	cancel()
+	for range ch {
+	}
 }
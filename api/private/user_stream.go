@@ -0,0 +1,160 @@
+package private
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/fxpgr/go-exchange-client/apierrors"
+	"github.com/fxpgr/go-exchange-client/logger"
+	"github.com/fxpgr/go-exchange-client/models"
+	"github.com/fxpgr/go-exchange-client/stream"
+	"github.com/fxpgr/go-exchange-client/symbols"
+	"github.com/pkg/errors"
+)
+
+// UserEventsBuffer is the number of events a subscriber of a UserStream may
+// fall behind before events are dropped.
+var UserEventsBuffer = 1024
+
+// UserStream pushes the order updates, fills and balance changes of an
+// account as they happen, instead of polling IsOrderFilled and
+// CompleteBalances. The stream is connected and authenticated while it has
+// subscribers, and reconnected and authenticated again whenever it drops.
+type UserStream interface {
+	// Subscribe returns a channel receiving the events of the account until
+	// ctx is done.
+	Subscribe(ctx context.Context) (<-chan models.UserEvent, error)
+	// Close disconnects the stream and closes the channels of every
+	// subscription.
+	Close()
+}
+
+// NewUserStream returns the user stream of the exchange of client. Okex needs
+// a passphrase and the pairs to follow, so its stream is made by
+// NewOkexUserStream.
+func NewUserStream(client PrivateClient) (UserStream, error) {
+	switch c := client.(type) {
+	case *BinanceApi:
+		return NewBinanceUserStream(c), nil
+	case *HuobiApi:
+		return NewHuobiUserStream(c), nil
+	case *KucoinApi:
+		return NewKucoinUserStream(c), nil
+	case *HitbtcApi:
+		return NewHitbtcUserStream(c), nil
+	}
+	return nil, errors.Wrapf(apierrors.ErrUnsupported, "no user stream for %T", client)
+}
+
+// userEvents fans the events of a user stream out to its subscribers, running
+// the stream while there are any.
+type userEvents struct {
+	run func(ctx context.Context)
+
+	subscribers []chan models.UserEvent
+	cancel      context.CancelFunc
+	m           sync.Mutex
+}
+
+func (u *userEvents) Subscribe(ctx context.Context) (<-chan models.UserEvent, error) {
+	ch := make(chan models.UserEvent, UserEventsBuffer)
+	u.m.Lock()
+	u.subscribers = append(u.subscribers, ch)
+	if u.cancel == nil {
+		var runCtx context.Context
+		runCtx, u.cancel = context.WithCancel(context.Background())
+		go u.run(runCtx)
+	}
+	u.m.Unlock()
+
+	go func() {
+		<-ctx.Done()
+		u.unsubscribe(ch)
+	}()
+	return ch, nil
+}
+
+func (u *userEvents) unsubscribe(ch chan models.UserEvent) {
+	u.m.Lock()
+	defer u.m.Unlock()
+	for i, sub := range u.subscribers {
+		if sub == ch {
+			u.subscribers = append(u.subscribers[:i], u.subscribers[i+1:]...)
+			close(ch)
+			break
+		}
+	}
+	if len(u.subscribers) == 0 && u.cancel != nil {
+		u.cancel()
+		u.cancel = nil
+	}
+}
+
+func (u *userEvents) Close() {
+	u.m.Lock()
+	defer u.m.Unlock()
+	if u.cancel != nil {
+		u.cancel()
+		u.cancel = nil
+	}
+	for _, ch := range u.subscribers {
+		close(ch)
+	}
+	u.subscribers = nil
+}
+
+func (u *userEvents) publish(e models.UserEvent) {
+	u.m.Lock()
+	defer u.m.Unlock()
+	for _, ch := range u.subscribers {
+		select {
+		case ch <- e:
+		default:
+			logger.Get().Warnw("user stream subscriber fell behind, dropping event", "type", e.Type.String())
+		}
+	}
+}
+
+func (u *userEvents) publishOrder(order *models.Order) {
+	u.publish(models.UserEvent{Type: models.OrderUpdate, Order: order, Time: order.UpdatedAt})
+}
+
+func (u *userEvents) publishFill(trade *models.Trade) {
+	u.publish(models.UserEvent{Type: models.Fill, Trade: trade, Time: trade.Time})
+}
+
+func (u *userEvents) publishBalance(currency string, balance *models.Balance, t time.Time) {
+	u.publish(models.UserEvent{Type: models.BalanceChange, Currency: currency, Balance: balance, Time: t})
+}
+
+// runConn runs conn until ctx is done.
+func runConn(conn *stream.Conn) func(ctx context.Context) {
+	return func(ctx context.Context) {
+		conn.Run(ctx)
+	}
+}
+
+// authError is a login refused by a stream, which is retried after the
+// backoff of its connection.
+func authError(code string, message string) error {
+	return errors.Wrap(apiError(http.StatusOK, code, message, apierrors.ErrAuth), "failed to authenticate")
+}
+
+// pairOf returns the canonical pair of a symbol of an exchange. Symbols the
+// registry cannot split are split by the settlement currencies of the
+// exchange.
+func pairOf(r *symbols.Registry, symbol string, settlements []string) (models.CurrencyPair, bool) {
+	if pair, ok := r.Pair(symbol); ok {
+		return pair, true
+	}
+	for _, s := range settlements {
+		s = r.Native(s)
+		if index := len(symbol) - len(s); index > 0 && strings.EqualFold(symbol[index:], s) {
+			return r.AddPair(symbol[:index], symbol[index:]), true
+		}
+	}
+	return models.CurrencyPair{}, false
+}
@@ -1,18 +1,25 @@
 package private
 
 import (
+	"context"
 	"crypto/hmac"
 	"crypto/md5"
 	"crypto/sha256"
 	"encoding/base64"
 	"encoding/hex"
 	"fmt"
+	"github.com/fxpgr/go-exchange-client/apierrors"
+	"github.com/fxpgr/go-exchange-client/decimal"
+	"github.com/fxpgr/go-exchange-client/models"
+	"github.com/fxpgr/go-exchange-client/ratelimit"
+	"github.com/fxpgr/go-exchange-client/retry"
 	"github.com/pkg/errors"
 	"io"
 	"io/ioutil"
 	"net/http"
-	"strconv"
+	"sort"
 	"strings"
+	"time"
 )
 
 type ClientMode int
@@ -20,19 +27,120 @@ type ClientMode int
 const (
 	TEST ClientMode = iota
 	PROJECT
+	// PAPER simulates the exchange with a PaperApi, funded by its Deposit.
+	PAPER
 )
 
+// FloorFloat64ToStr formats num with dig decimals, truncating the rest. It is
+// FloorDecimalToStr for float callers, working on the shortest decimal form
+// of num so 0.29 stays 0.29 rather than flooring its binary error.
 func FloorFloat64ToStr(num float64, dig int) string {
-	return fmt.Sprintf("%."+strconv.Itoa(dig)+"f", num)
+	return FloorDecimalToStr(decimal.NewFromFloat(num), dig)
 }
 
-func parseCurrencyPair(s string) (string, string, error) {
-	xs := strings.Split(s, "_")
+// FloorDecimalToStr formats num with dig decimals, truncating the rest so an
+// amount is never sent larger than it is.
+func FloorDecimalToStr(num decimal.Decimal, dig int) string {
+	return num.Truncate(int32(dig)).StringFixed(int32(dig))
+}
+
+type clientContext struct {
+	ctx context.Context
+}
 
-	if len(xs) != 2 {
-		return "", "", errors.New("invalid ticker title")
+func (c clientContext) context() context.Context {
+	if c.ctx == nil {
+		return context.Background()
 	}
-	return xs[0], xs[1], nil
+	return c.ctx
+}
+
+// newTransport wraps base with the retry policy and the rate limiter shared by
+// every client of exchange.
+func newTransport(exchange string, base http.RoundTripper) http.RoundTripper {
+	return retry.NewTransport(ratelimit.NewTransport(exchange, base))
+}
+
+func httpGet(ctx context.Context, client *http.Client, url string) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+	if err != nil {
+		return nil, err
+	}
+	return client.Do(req)
+}
+
+// apiError builds the typed error for a failed response, falling back to the
+// HTTP status when the exchange specific kind is unknown.
+func apiError(statusCode int, code string, message string, kind error) error {
+	if kind == nil {
+		kind = apierrors.FromStatus(statusCode)
+	}
+	return apierrors.New(statusCode, code, message, kind)
+}
+
+func unsupportedOrder(exchange string, req models.OrderRequest) error {
+	if req.ExecutionType == models.Market {
+		return errors.Wrapf(apierrors.ErrUnsupported, "%s market orders on %s", req.TimeInForce, exchange)
+	}
+	return errors.Wrapf(apierrors.ErrUnsupported, "%s %s orders on %s", req.TimeInForce, req.ExecutionType, exchange)
+}
+
+// messagePattern maps the lower case text of an error message to its kind.
+type messagePattern struct {
+	pattern string
+	kind    error
+}
+
+// messageKind is for exchanges which report failures as free text only. The
+// first pattern found wins, so a table lists the most specific ones first.
+func messageKind(message string, patterns []messagePattern) error {
+	message = strings.ToLower(message)
+	for _, p := range patterns {
+		if strings.Contains(message, p.pattern) {
+			return p.kind
+		}
+	}
+	return nil
+}
+
+func millisToTime(ms int64) time.Time {
+	if ms <= 0 {
+		return time.Time{}
+	}
+	return time.Unix(0, ms*int64(time.Millisecond))
+}
+
+func averagePrice(total decimal.Decimal, filled decimal.Decimal) decimal.Decimal {
+	if filled.IsZero() {
+		return decimal.Zero
+	}
+	return total.Div(filled)
+}
+
+// trimTrades orders trades oldest first and drops those before since or
+// beyond limit, for exchanges which page newest first or coarser than since.
+func trimTrades(trades []*models.Trade, since time.Time, limit int) []*models.Trade {
+	sort.SliceStable(trades, func(i, j int) bool {
+		return trades[i].Time.Before(trades[j].Time)
+	})
+	i := sort.Search(len(trades), func(i int) bool {
+		return !trades[i].Time.Before(since)
+	})
+	trades = trades[i:]
+	if limit > 0 && len(trades) > limit {
+		trades = trades[:limit]
+	}
+	return trades
+}
+
+func trimTransfers(transfers []*models.Transfer, since time.Time) []*models.Transfer {
+	sort.SliceStable(transfers, func(i, j int) bool {
+		return transfers[i].CreatedAt.Before(transfers[j].CreatedAt)
+	})
+	i := sort.Search(len(transfers), func(i int) bool {
+		return !transfers[i].CreatedAt.Before(since)
+	})
+	return transfers[i:]
 }
 
 type errorResponse struct {
@@ -99,7 +207,7 @@ func NewHttpRequest(client *http.Client, reqType string, reqUrl string, postData
 		return nil, err
 	}
 	if resp.StatusCode != 200 {
-		return nil, errors.New(fmt.Sprintf("HttpStatusCode:%d ,Desc:%s", resp.StatusCode, string(bodyData)))
+		return nil, apiError(resp.StatusCode, "", string(bodyData), nil)
 	}
 	return bodyData, nil
 }
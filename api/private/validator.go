@@ -0,0 +1,128 @@
+package private
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/fxpgr/go-exchange-client/api/public"
+	"github.com/fxpgr/go-exchange-client/apierrors"
+	"github.com/fxpgr/go-exchange-client/decimal"
+	"github.com/fxpgr/go-exchange-client/models"
+	"github.com/pkg/errors"
+)
+
+// OrderError is an order the Validator refuses to send. Value is what the
+// order has for Rule, and Limit what the rule or the balance allows. Kind is
+// apierrors.ErrInsufficientFunds when the balance falls short and
+// apierrors.ErrInvalidOrder otherwise.
+type OrderError struct {
+	Trading    string
+	Settlement string
+	Rule       string
+	Value      decimal.Decimal
+	Limit      decimal.Decimal
+	Kind       error
+}
+
+func (e *OrderError) Error() string {
+	return fmt.Sprintf("%v: %s/%s %s (%v, limit %v)", e.Kind, e.Trading, e.Settlement, e.Rule, e.Value, e.Limit)
+}
+
+func (e *OrderError) Unwrap() error {
+	return e.Kind
+}
+
+// Validator wraps a PrivateClient, normalizing its orders to the MarketRules
+// of Public before they are sent. Prices are rounded to the tick, down for
+// buys and up for sells, and amounts down to the step, so an order never
+// costs more or sells for less than asked. Orders which still break a rule or
+// exceed the available balance fail with an *OrderError.
+type Validator struct {
+	PrivateClient
+	Public public.PublicClient
+}
+
+func NewValidator(client PrivateClient, pub public.PublicClient) *Validator {
+	return &Validator{PrivateClient: client, Public: pub}
+}
+
+func (v *Validator) WithContext(ctx context.Context) PrivateClient {
+	return &Validator{
+		PrivateClient: v.PrivateClient.WithContext(ctx),
+		Public:        v.Public.WithContext(ctx),
+	}
+}
+
+func (v *Validator) Order(trading string, settlement string, ordertype models.OrderType, price float64, amount float64) (string, error) {
+	return v.PlaceOrder(models.OrderRequest{
+		Trading:    trading,
+		Settlement: settlement,
+		Type:       ordertype,
+		Price:      decimal.NewFromFloat(price),
+		Amount:     decimal.NewFromFloat(amount),
+	})
+}
+
+func (v *Validator) PlaceOrder(req models.OrderRequest) (string, error) {
+	req, err := v.Normalize(req)
+	if err != nil {
+		return "", err
+	}
+	return v.PrivateClient.PlaceOrder(req)
+}
+
+// Normalize returns req rounded to the rules of its pair, failing as
+// PlaceOrder does without placing it. The price of a market order is only
+// checked against the minimum notional and the balance when it is set.
+func (v *Validator) Normalize(req models.OrderRequest) (models.OrderRequest, error) {
+	rules, err := v.Public.Rules(req.Trading, req.Settlement)
+	if err != nil {
+		return req, errors.Wrapf(err, "failed to fetch rules %s/%s", req.Trading, req.Settlement)
+	}
+	reject := func(kind error, rule string, value decimal.Decimal, limit decimal.Decimal) (models.OrderRequest, error) {
+		return req, &OrderError{Trading: req.Trading, Settlement: req.Settlement, Rule: rule, Value: value, Limit: limit, Kind: kind}
+	}
+
+	amount := rules.FloorAmount(req.Amount)
+	switch {
+	case !amount.IsPositive():
+		return reject(apierrors.ErrInvalidOrder, "amount below step size", req.Amount, rules.StepSize)
+	case rules.MinAmount.IsPositive() && amount.LessThan(rules.MinAmount):
+		return reject(apierrors.ErrInvalidOrder, "amount below minimum", amount, rules.MinAmount)
+	case rules.MaxAmount.IsPositive() && amount.GreaterThan(rules.MaxAmount):
+		return reject(apierrors.ErrInvalidOrder, "amount above maximum", amount, rules.MaxAmount)
+	}
+	req.Amount = amount
+
+	if req.ExecutionType != models.Market || req.Price.IsPositive() {
+		price := rules.RoundPrice(req.Price, req.Type == models.Bid)
+		notional := price.Mul(amount)
+		switch {
+		case !price.IsPositive():
+			return reject(apierrors.ErrInvalidOrder, "price below tick size", req.Price, rules.TickSize)
+		case rules.MinPrice.IsPositive() && price.LessThan(rules.MinPrice):
+			return reject(apierrors.ErrInvalidOrder, "price below minimum", price, rules.MinPrice)
+		case rules.MaxPrice.IsPositive() && price.GreaterThan(rules.MaxPrice):
+			return reject(apierrors.ErrInvalidOrder, "price above maximum", price, rules.MaxPrice)
+		case rules.MinNotional.IsPositive() && notional.LessThan(rules.MinNotional):
+			return reject(apierrors.ErrInvalidOrder, "notional below minimum", notional, rules.MinNotional)
+		}
+		req.Price = price
+	}
+
+	currency, need := req.Trading, req.Amount
+	if req.Type == models.Ask {
+		if !req.Price.IsPositive() {
+			return req, nil
+		}
+		currency, need = req.Settlement, req.Price.Mul(req.Amount)
+	}
+	balance, err := v.PrivateClient.CompleteBalance(currency)
+	if err != nil {
+		return req, errors.Wrapf(err, "failed to fetch balance %s", currency)
+	}
+	if need.GreaterThan(balance.Available) {
+		return reject(apierrors.ErrInsufficientFunds, currency+" needed above available", need, balance.Available)
+	}
+	return req, nil
+}
@@ -1,14 +1,19 @@
 package public
 
 import (
+	"context"
 	"io"
 	"io/ioutil"
 	"net/http"
+	"strconv"
 	"sync"
 	"time"
 
 	"github.com/fxpgr/go-exchange-client/api/unified"
+	"github.com/fxpgr/go-exchange-client/apierrors"
+	"github.com/fxpgr/go-exchange-client/helpers"
 	"github.com/fxpgr/go-exchange-client/models"
+	"github.com/fxpgr/go-exchange-client/symbols"
 	"github.com/patrickmn/go-cache"
 	"github.com/pkg/errors"
 	"github.com/tidwall/gjson"
@@ -18,8 +23,10 @@ const (
 	BINANCE_BASE_URL = "https://api.binance.com"
 )
 
+var binanceSymbols = symbols.For("binance")
+
 func NewBinancePublicApi() (*BinanceApi, error) {
-	cli := &http.Client{}
+	cli := &http.Client{Transport: newTransport("binance", nil)}
 	cli.Timeout = 20 * time.Second
 	shrimpyApi, err := unified.NewShrimpyApi()
 	if err != nil {
@@ -53,11 +60,13 @@ type BinanceApi struct {
 	rateMap           map[string]map[string]float64
 	orderBookTickMap  map[string]map[string]models.OrderBookTick
 	precisionMap      map[string]map[string]models.Precisions
+	rulesMap          map[string]map[string]models.MarketRules
 	boardCache        *cache.Cache
 	boardTickerCache  *cache.Cache
 	currencyPairs     []models.CurrencyPair
 
-	HttpClient    *http.Client
+	HttpClient *http.Client
+	clientContext
 	ShrimpyClient *unified.ShrimpyApiClient
 
 	settlements  []string
@@ -68,10 +77,16 @@ type BinanceApi struct {
 }
 
 func (h *BinanceApi) SetTransport(transport http.RoundTripper) error {
-	h.HttpClient.Transport = transport
+	h.HttpClient.Transport = newTransport("binance", transport)
 	return nil
 }
 
+func (h *BinanceApi) WithContext(ctx context.Context) PublicClient {
+	c := *h
+	c.ctx = ctx
+	return &c
+}
+
 func (h *BinanceApi) renewHttpClient() error {
 	rt := h.HttpClient.Transport
 	h.HttpClient = &http.Client{Transport: rt}
@@ -83,7 +98,7 @@ func (h *BinanceApi) publicApiUrl(command string) string {
 }
 
 func (h *BinanceApi) getRequest(url string) (string, error) {
-	req, err := requestGetAsChrome(url)
+	req, err := requestGetAsChrome(h.context(), url)
 	if err != nil {
 		return "", errors.Wrapf(err, "failed to fetch %s", url)
 	}
@@ -100,9 +115,31 @@ func (h *BinanceApi) getRequest(url string) (string, error) {
 	if err != nil {
 		return "", errors.Wrapf(err, "failed to fetch %s", url)
 	}
+	if resp.StatusCode != http.StatusOK {
+		return "", errors.Wrapf(binanceError(resp.StatusCode, byteArray), "failed to fetch %s", url)
+	}
 	return string(byteArray), err
 }
 
+var binanceErrorCodes = map[string]error{
+	"-1003": apierrors.ErrRateLimited,
+	"-1016": apierrors.ErrMaintenance,
+	"-1121": apierrors.ErrInvalidSymbol,
+}
+
+func binanceError(statusCode int, body []byte) error {
+	value := gjson.ParseBytes(body)
+	code := value.Get("code").String()
+	kind := binanceErrorCodes[code]
+	if statusCode == http.StatusTeapot {
+		kind = apierrors.ErrIPBanned
+	}
+	if kind == nil {
+		kind = apierrors.FromStatus(statusCode)
+	}
+	return apierrors.New(statusCode, code, value.Get("msg").String(), kind)
+}
+
 func (h *BinanceApi) fetchSettlements() error {
 	h.settlements = []string{"BTC", "ETH", "NEO", "USDT", "KCS"}
 	return nil
@@ -115,38 +152,64 @@ type BinanceTickResponse struct {
 	err        error
 }
 
+// fetchPrecision loads the precisions and rules of every symbol once. The
+// caller holds h.m, and a failed fetch is tried again by the next caller.
 func (h *BinanceApi) fetchPrecision() error {
 	if h.precisionMap != nil {
 		return nil
 	}
 
-	h.precisionMap = make(map[string]map[string]models.Precisions)
 	url := h.publicApiUrl("/api/v1/exchangeInfo")
-
 	byteArray, err := h.getRequest(url)
 	if err != nil {
 		return err
 	}
 	value := gjson.Parse(byteArray)
 
-	if value.Get("code").String() == "-1003" {
-		return errors.Errorf("ip banned %s", url)
-	}
+	precisionMap := make(map[string]map[string]models.Precisions)
+	rulesMap := make(map[string]map[string]models.MarketRules)
+
 	for _, v := range value.Get("symbols").Array() {
-		trading := v.Get("baseAsset").Str
-		settlement := v.Get("quoteAsset").Str
-		m, ok := h.precisionMap[trading]
+		p := binanceSymbols.AddPair(v.Get("baseAsset").Str, v.Get("quoteAsset").Str)
+		trading, settlement := p.Trading, p.Settlement
+		rules := binanceRules(v)
+		r, ok := rulesMap[trading]
 		if !ok {
-			m = make(map[string]models.Precisions)
-			h.precisionMap[trading] = m
+			r = make(map[string]models.MarketRules)
+			rulesMap[trading] = r
 		}
-		m[settlement] = models.Precisions{
-			PricePrecision:  int(v.Get("baseAssetPrecision").Int()),
-			AmountPrecision: int(v.Get("quotePrecision").Int()),
+		r[settlement] = rules
+		m, ok := precisionMap[trading]
+		if !ok {
+			m = make(map[string]models.Precisions)
+			precisionMap[trading] = m
 		}
+		m[settlement] = rules.Precisions()
 	}
+	h.precisionMap = precisionMap
+	h.rulesMap = rulesMap
+	return nil
+}
 
-	return errors.Wrapf(err, "failed to fetch %s", url)
+// binanceRules reads the PRICE_FILTER, LOT_SIZE and MIN_NOTIONAL filters of a
+// symbol of exchangeInfo.
+func binanceRules(symbol gjson.Result) models.MarketRules {
+	var rules models.MarketRules
+	for _, f := range symbol.Get("filters").Array() {
+		switch f.Get("filterType").Str {
+		case "PRICE_FILTER":
+			rules.TickSize = helpers.ToDecimal(f.Get("tickSize"))
+			rules.MinPrice = helpers.ToDecimal(f.Get("minPrice"))
+			rules.MaxPrice = helpers.ToDecimal(f.Get("maxPrice"))
+		case "LOT_SIZE":
+			rules.StepSize = helpers.ToDecimal(f.Get("stepSize"))
+			rules.MinAmount = helpers.ToDecimal(f.Get("minQty"))
+			rules.MaxAmount = helpers.ToDecimal(f.Get("maxQty"))
+		case "MIN_NOTIONAL":
+			rules.MinNotional = helpers.ToDecimal(f.Get("minNotional"))
+		}
+	}
+	return rules
 }
 
 func (h *BinanceApi) fetchRate() error {
@@ -156,32 +219,29 @@ func (h *BinanceApi) fetchRate() error {
 		return err
 	}
 	value := gjson.Parse(byteArray)
-	if value.Get("code").String() == "-1003" {
-		return errors.Errorf("ip banned %s", url)
-	}
 	currencyPairs, err := h.CurrencyPairs()
 	if err != nil {
 		return errors.Wrapf(err, "failed to fetch %s", url)
 	}
+	pairs := make(map[string]models.CurrencyPair, len(currencyPairs))
+	for _, c := range currencyPairs {
+		pairs[binanceSymbols.Symbol(c.Trading, c.Settlement)] = c
+	}
 
 	rateMap := make(map[string]map[string]float64)
 	volumeMap := make(map[string]map[string]float64)
 	orderBookTickMap := make(map[string]map[string]models.OrderBookTick)
 	for _, v := range value.Array() {
-		var trading, settlement string
-		symbol := v.Get("symbol").Str
-		for _, c := range currencyPairs {
-			if symbol == c.Trading+c.Settlement {
-				trading = c.Trading
-				settlement = c.Settlement
-				break
-			}
+		p, ok := pairs[v.Get("symbol").Str]
+		if !ok {
+			continue
 		}
+		trading, settlement := p.Trading, p.Settlement
 
 		lastf := v.Get("lastPrice").Float()
 		volumef := v.Get("volume").Float()
-		bestbidPrice := v.Get("bidPrice").Float()
-		bestaskPrice := v.Get("askPrice").Float()
+		bestbidPrice := helpers.ToDecimal(v.Get("bidPrice"))
+		bestaskPrice := helpers.ToDecimal(v.Get("askPrice"))
 		h.rateM.Lock()
 		n, ok := volumeMap[trading]
 		if !ok {
@@ -213,13 +273,15 @@ func (h *BinanceApi) fetchRate() error {
 }
 
 func (h *BinanceApi) fetchOrderBookTick() error {
-	boardMap, err := h.ShrimpyClient.GetBoards("binance")
+	boardMap, err := h.ShrimpyClient.WithContext(h.context()).GetBoards("binance")
 	if err != nil {
 		return err
 	}
 	orderBookTickMap := make(map[string]map[string]models.OrderBookTick)
 	for settlement, m := range boardMap {
+		settlement = binanceSymbols.Currency(settlement)
 		for trading, value := range m {
+			trading = binanceSymbols.Currency(trading)
 			l, ok := orderBookTickMap[trading]
 			if !ok {
 				l = make(map[string]models.OrderBookTick)
@@ -270,16 +332,35 @@ func (h *BinanceApi) Precise(trading string, settlement string) (*models.Precisi
 		return &models.Precisions{}, nil
 	}
 
-	h.fetchPrecision()
+	h.m.Lock()
+	defer h.m.Unlock()
+	if err := h.fetchPrecision(); err != nil {
+		return &models.Precisions{}, err
+	}
 	if m, ok := h.precisionMap[trading]; !ok {
-		return &models.Precisions{}, errors.Errorf("%s/%s", trading, settlement)
+		return &models.Precisions{}, errors.Wrapf(apierrors.ErrInvalidSymbol, "%s/%s", trading, settlement)
 	} else if precisions, ok := m[settlement]; !ok {
-		return &models.Precisions{}, errors.Errorf("%s/%s", trading, settlement)
+		return &models.Precisions{}, errors.Wrapf(apierrors.ErrInvalidSymbol, "%s/%s", trading, settlement)
 	} else {
 		return &precisions, nil
 	}
 }
 
+func (h *BinanceApi) Rules(trading string, settlement string) (*models.MarketRules, error) {
+	h.m.Lock()
+	defer h.m.Unlock()
+	if err := h.fetchPrecision(); err != nil {
+		return nil, err
+	}
+	if m, ok := h.rulesMap[trading]; !ok {
+		return nil, errors.Wrapf(apierrors.ErrInvalidSymbol, "%s/%s", trading, settlement)
+	} else if rules, ok := m[settlement]; !ok {
+		return nil, errors.Wrapf(apierrors.ErrInvalidSymbol, "%s/%s", trading, settlement)
+	} else {
+		return &rules, nil
+	}
+}
+
 func (h *BinanceApi) VolumeMap() (map[string]map[string]float64, error) {
 	h.m.Lock()
 	defer h.m.Unlock()
@@ -308,16 +389,8 @@ func (h *BinanceApi) CurrencyPairs() ([]models.CurrencyPair, error) {
 	value := gjson.Parse(byteArray)
 	currencyPairs := make([]models.CurrencyPair, 0)
 
-	if value.Get("code").String() == "-1003" {
-		return h.currencyPairs, errors.Errorf("ip banned %s", url)
-	}
 	for _, v := range value.Get("symbols").Array() {
-		trading := v.Get("baseAsset").Str
-		settlement := v.Get("quoteAsset").Str
-		currencyPairs = append(currencyPairs, models.CurrencyPair{
-			Trading:    trading,
-			Settlement: settlement,
-		})
+		currencyPairs = append(currencyPairs, binanceSymbols.AddPair(v.Get("baseAsset").Str, v.Get("quoteAsset").Str))
 	}
 	h.currencyPairs = currencyPairs
 	return currencyPairs, nil
@@ -336,9 +409,9 @@ func (h *BinanceApi) Volume(trading string, settlement string) (float64, error)
 		h.rateLastUpdated = now
 	}
 	if m, ok := h.volumeMap[trading]; !ok {
-		return 0, errors.Errorf("%s/%s", trading, settlement)
+		return 0, errors.Wrapf(apierrors.ErrInvalidSymbol, "%s/%s", trading, settlement)
 	} else if volume, ok := m[settlement]; !ok {
-		return 0, errors.Errorf("%s/%s", trading, settlement)
+		return 0, errors.Wrapf(apierrors.ErrInvalidSymbol, "%s/%s", trading, settlement)
 	} else {
 		return volume, nil
 	}
@@ -361,9 +434,9 @@ func (h *BinanceApi) Rate(trading string, settlement string) (float64, error) {
 		h.rateLastUpdated = now
 	}
 	if m, ok := h.rateMap[trading]; !ok {
-		return 0, errors.Errorf("%s/%s", trading, settlement)
+		return 0, errors.Wrapf(apierrors.ErrInvalidSymbol, "%s/%s", trading, settlement)
 	} else if rate, ok := m[settlement]; !ok {
-		return 0, errors.Errorf("%s/%s", trading, settlement)
+		return 0, errors.Wrapf(apierrors.ErrInvalidSymbol, "%s/%s", trading, settlement)
 	} else {
 		return rate, nil
 	}
@@ -381,17 +454,13 @@ func (h *BinanceApi) FrozenCurrency() ([]string, error) {
 	}
 	value := gjson.Parse(byteArray)
 
-	if value.Get("code").String() == "-1003" {
-		return []string{}, errors.Errorf("ip banned %s", url)
-	}
 	symbols := value.Get("symbols").Array()
 
 	var frozenCurrencies []string
 	for _, v := range symbols {
 		if v.Get("status").Str != "TRADING" {
-			trading := v.Get("baseAsset").Str
-			settlement := v.Get("quoteAsset").Str
-			frozenCurrencies = append(frozenCurrencies, trading, settlement)
+			p := binanceSymbols.AddPair(v.Get("baseAsset").Str, v.Get("quoteAsset").Str)
+			frozenCurrencies = append(frozenCurrencies, p.Trading, p.Settlement)
 		}
 	}
 	m := make(map[string]bool)
@@ -412,29 +481,26 @@ func (h *BinanceApi) fetchBoardTicker() error {
 		return err
 	}
 	value := gjson.Parse(byteArray)
-	if value.Get("code").String() == "-1003" {
-		return errors.Errorf("ip banned %s", url)
-	}
 	currencyPairs, err := h.CurrencyPairs()
 	if err != nil {
 		return errors.Wrapf(err, "failed to fetch %s", url)
 	}
+	pairs := make(map[string]models.CurrencyPair, len(currencyPairs))
+	for _, c := range currencyPairs {
+		pairs[binanceSymbols.Symbol(c.Trading, c.Settlement)] = c
+	}
 
 	for _, v := range value.Array() {
-		var trading, settlement string
-		symbol := v.Get("symbol").Str
-		for _, c := range currencyPairs {
-			if symbol == c.Trading+c.Settlement {
-				trading = c.Trading
-				settlement = c.Settlement
-				break
-			}
+		p, ok := pairs[v.Get("symbol").Str]
+		if !ok {
+			continue
 		}
+		trading, settlement := p.Trading, p.Settlement
 		bids := make([]models.BoardBar, 0)
 		asks := make([]models.BoardBar, 0)
 
-		bestBidPricef := v.Get("bidPrice").Float()
-		bestBidAmountf := v.Get("bidQty").Float()
+		bestBidPricef := helpers.ToDecimal(v.Get("bidPrice"))
+		bestBidAmountf := helpers.ToDecimal(v.Get("bidQty"))
 
 		bids = append(bids, models.BoardBar{
 			Price:  bestBidPricef,
@@ -442,8 +508,8 @@ func (h *BinanceApi) fetchBoardTicker() error {
 			Type:   models.Bid,
 		})
 
-		bestAskPricef := v.Get("askPrice").Float()
-		bestAskAmountf := v.Get("askQty").Float()
+		bestAskPricef := helpers.ToDecimal(v.Get("askPrice"))
+		bestAskAmountf := helpers.ToDecimal(v.Get("askQty"))
 
 		asks = append(asks, models.BoardBar{
 			Price:  bestAskPricef,
@@ -467,23 +533,31 @@ func (h *BinanceApi) Board(trading string, settlement string) (board *models.Boa
 	if trading == settlement {
 		return nil, errors.Errorf("trading and settlment are same")
 	}
-	url := h.publicApiUrl("/api/v1/depth?limit=1000&symbol=" + trading + settlement)
-	byteArray, err := h.getRequest(url)
+	board, _, err = h.depth(trading, settlement)
 	if err != nil {
 		return nil, err
 	}
-	value := gjson.Parse(byteArray)
-	if value.Get("code").String() == "-1003" {
-		return nil, errors.Errorf("ip banned %s", url)
+	h.boardCache.Set(trading+"_"+settlement, board, cache.DefaultExpiration)
+	return board, nil
+}
+
+// depth fetches the board of a pair with the id of the last update it
+// includes.
+func (h *BinanceApi) depth(trading string, settlement string) (*models.Board, int64, error) {
+	url := h.publicApiUrl("/api/v1/depth?limit=1000&symbol=" + binanceSymbols.Symbol(trading, settlement))
+	byteArray, err := h.getRequest(url)
+	if err != nil {
+		return nil, 0, err
 	}
+	value := gjson.Parse(byteArray)
 	bidsJson := value.Get("bids").Array()
 	asksJson := value.Get("asks").Array()
 
 	asks := make([]models.BoardBar, 0)
 	bids := make([]models.BoardBar, 0)
 	for _, bidJson := range bidsJson {
-		price := bidJson.Array()[0].Float()
-		amount := bidJson.Array()[1].Float()
+		price := helpers.ToDecimal(bidJson.Array()[0])
+		amount := helpers.ToDecimal(bidJson.Array()[1])
 		bidBoardBar := models.BoardBar{
 			Type:   models.Bid,
 			Price:  price,
@@ -492,8 +566,8 @@ func (h *BinanceApi) Board(trading string, settlement string) (board *models.Boa
 		bids = append(bids, bidBoardBar)
 	}
 	for _, askJson := range asksJson {
-		price := askJson.Array()[0].Float()
-		amount := askJson.Array()[1].Float()
+		price := helpers.ToDecimal(askJson.Array()[0])
+		amount := helpers.ToDecimal(askJson.Array()[1])
 		askBoardBar := models.BoardBar{
 			Type:   models.Ask,
 			Price:  price,
@@ -501,12 +575,11 @@ func (h *BinanceApi) Board(trading string, settlement string) (board *models.Boa
 		}
 		asks = append(asks, askBoardBar)
 	}
-	board = &models.Board{
+	board := &models.Board{
 		Asks: asks,
 		Bids: bids,
 	}
-	h.boardCache.Set(trading+"_"+settlement, board, cache.DefaultExpiration)
-	return board, nil
+	return board, value.Get("lastUpdateId").Int(), nil
 }
 
 /*duplicated*/
@@ -530,3 +603,74 @@ func (h *BinanceApi) BoardTicker(trading string, settlement string) (board *mode
 	}
 	return c.(*models.Board), nil
 }
+
+var binanceIntervals = map[models.Interval]string{
+	models.Interval1m:  "1m",
+	models.Interval3m:  "3m",
+	models.Interval5m:  "5m",
+	models.Interval15m: "15m",
+	models.Interval30m: "30m",
+	models.Interval1h:  "1h",
+	models.Interval2h:  "2h",
+	models.Interval4h:  "4h",
+	models.Interval6h:  "6h",
+	models.Interval8h:  "8h",
+	models.Interval12h: "12h",
+	models.Interval1d:  "1d",
+	models.Interval1w:  "1w",
+}
+
+func (h *BinanceApi) Candles(trading string, settlement string, interval models.Interval, since time.Time, limit int) ([]models.Candle, error) {
+	period, ok := binanceIntervals[interval]
+	if !ok {
+		return nil, unsupportedInterval("binance", interval)
+	}
+	return pageCandles(interval, since, limit, 0, func(start time.Time) ([]models.Candle, error) {
+		url := h.publicApiUrl("/api/v1/klines?limit=1000&symbol=" + binanceSymbols.Symbol(trading, settlement) +
+			"&interval=" + period + "&startTime=" + millis(start))
+		byteArray, err := h.getRequest(url)
+		if err != nil {
+			return nil, err
+		}
+		var candles []models.Candle
+		for _, v := range gjson.Parse(byteArray).Array() {
+			a := v.Array()
+			if len(a) < 6 {
+				continue
+			}
+			candles = append(candles, models.Candle{
+				Time:   millisToTime(a[0].Int()),
+				Open:   helpers.ToDecimal(a[1]),
+				High:   helpers.ToDecimal(a[2]),
+				Low:    helpers.ToDecimal(a[3]),
+				Close:  helpers.ToDecimal(a[4]),
+				Volume: helpers.ToDecimal(a[5]),
+			})
+		}
+		return candles, nil
+	})
+}
+
+func (h *BinanceApi) RecentTrades(trading string, settlement string, limit int) ([]models.PublicTrade, error) {
+	limit = tradesLimit(limit, 1000)
+	url := h.publicApiUrl("/api/v1/trades?symbol=" + binanceSymbols.Symbol(trading, settlement) + "&limit=" + strconv.Itoa(limit))
+	byteArray, err := h.getRequest(url)
+	if err != nil {
+		return nil, err
+	}
+	var trades []models.PublicTrade
+	for _, v := range gjson.Parse(byteArray).Array() {
+		side := models.Ask
+		if v.Get("isBuyerMaker").Bool() {
+			side = models.Bid
+		}
+		trades = append(trades, models.PublicTrade{
+			ID:     strconv.FormatInt(v.Get("id").Int(), 10),
+			Type:   side,
+			Price:  helpers.ToDecimal(v.Get("price")),
+			Amount: helpers.ToDecimal(v.Get("qty")),
+			Time:   millisToTime(v.Get("time").Int()),
+		})
+	}
+	return latestTrades(trades, limit), nil
+}
@@ -0,0 +1,372 @@
+package public
+
+import (
+	"context"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/fxpgr/go-exchange-client/helpers"
+	"github.com/fxpgr/go-exchange-client/logger"
+	"github.com/fxpgr/go-exchange-client/models"
+	"github.com/fxpgr/go-exchange-client/stream"
+	"github.com/pkg/errors"
+	"github.com/tidwall/gjson"
+)
+
+const (
+	BINANCE_STREAM_URL = "wss://stream.binance.com:9443/stream"
+)
+
+// BinanceStream maintains the boards of Binance from its depth diff streams.
+// A board is loaded from the REST snapshot of Api and kept in sync by the
+// update ids of the diffs, and loaded again whenever a diff is missed or the
+// connection drops. Ticks and trades come from the book ticker and trade
+// streams.
+type BinanceStream struct {
+	Api  *BinanceApi
+	Conn *stream.Conn
+
+	books  map[string]*binanceBook
+	feeds  map[string]*stream.Subscribers
+	nextID int64
+	cancel context.CancelFunc
+	m      sync.Mutex
+}
+
+type binanceBook struct {
+	trading      string
+	settlement   string
+	book         *stream.Book
+	lastUpdateID int64
+	synced       bool
+	fetching     bool
+	buffer       []binanceDepth
+	subscribers  stream.Subscribers
+}
+
+// binanceDepth is a diff of the levels between the update ids first and
+// last.
+type binanceDepth struct {
+	first int64
+	last  int64
+	bids  []gjson.Result
+	asks  []gjson.Result
+}
+
+func NewBinanceStream(api *BinanceApi) *BinanceStream {
+	s := &BinanceStream{
+		Api:   api,
+		books: make(map[string]*binanceBook),
+		feeds: make(map[string]*stream.Subscribers),
+	}
+	s.Conn = stream.NewConn(BINANCE_STREAM_URL, s.handle)
+	s.Conn.OnConnect = s.onConnect
+	return s
+}
+
+func binanceStreamName(trading string, settlement string, channel string) string {
+	return strings.ToLower(binanceSymbols.Symbol(trading, settlement)) + "@" + channel
+}
+
+// SubscribeBoard returns a channel receiving the board of a pair whenever it
+// changes, until ctx is done. A receiver which falls behind only gets the
+// latest board.
+func (s *BinanceStream) SubscribeBoard(ctx context.Context, trading string, settlement string) (<-chan *models.Board, error) {
+	if trading == settlement {
+		return nil, errors.Errorf("trading and settlment are same")
+	}
+	name := binanceStreamName(trading, settlement, "depth@100ms")
+	s.m.Lock()
+	b, ok := s.books[name]
+	if !ok {
+		b = &binanceBook{trading: trading, settlement: settlement, book: stream.NewBook()}
+		s.books[name] = b
+		s.send("SUBSCRIBE", name)
+	}
+	ch := b.subscribers.AddBoard()
+	if b.synced {
+		ch <- b.book.Board()
+	}
+	if s.cancel == nil {
+		var connCtx context.Context
+		connCtx, s.cancel = context.WithCancel(context.Background())
+		go s.Conn.Run(connCtx)
+	}
+	s.m.Unlock()
+
+	go func() {
+		<-ctx.Done()
+		s.unsubscribe(name, ch)
+	}()
+	return ch, nil
+}
+
+// SubscribeTicks returns a channel receiving the best bid and offer of a pair
+// whenever they change, until ctx is done. A receiver which falls behind only
+// gets the latest tick.
+func (s *BinanceStream) SubscribeTicks(ctx context.Context, trading string, settlement string) (<-chan models.OrderBookTick, error) {
+	var ch chan models.OrderBookTick
+	err := s.subscribeFeed(ctx, trading, settlement, "bookTicker", func(subscribers *stream.Subscribers) interface{} {
+		ch = subscribers.AddTicks()
+		return ch
+	})
+	return ch, err
+}
+
+// SubscribeTrades returns a channel receiving the trades of a pair as they
+// happen, until ctx is done.
+func (s *BinanceStream) SubscribeTrades(ctx context.Context, trading string, settlement string) (<-chan models.PublicTrade, error) {
+	var ch chan models.PublicTrade
+	err := s.subscribeFeed(ctx, trading, settlement, "trade", func(subscribers *stream.Subscribers) interface{} {
+		ch = subscribers.AddTrades()
+		return ch
+	})
+	return ch, err
+}
+
+func (s *BinanceStream) subscribeFeed(ctx context.Context, trading string, settlement string, channel string, add func(subscribers *stream.Subscribers) interface{}) error {
+	if trading == settlement {
+		return errors.Errorf("trading and settlment are same")
+	}
+	name := binanceStreamName(trading, settlement, channel)
+	s.m.Lock()
+	subscribers, ok := s.feeds[name]
+	if !ok {
+		subscribers = &stream.Subscribers{}
+		s.feeds[name] = subscribers
+		s.send("SUBSCRIBE", name)
+	}
+	ch := add(subscribers)
+	if s.cancel == nil {
+		var connCtx context.Context
+		connCtx, s.cancel = context.WithCancel(context.Background())
+		go s.Conn.Run(connCtx)
+	}
+	s.m.Unlock()
+
+	go func() {
+		<-ctx.Done()
+		s.unsubscribe(name, ch)
+	}()
+	return nil
+}
+
+func (s *BinanceStream) unsubscribe(name string, ch interface{}) {
+	s.m.Lock()
+	defer s.m.Unlock()
+	if b, ok := s.books[name]; ok {
+		b.subscribers.Remove(ch)
+		if b.subscribers.Len() == 0 {
+			delete(s.books, name)
+			s.send("UNSUBSCRIBE", name)
+		}
+		return
+	}
+	if subscribers, ok := s.feeds[name]; ok {
+		subscribers.Remove(ch)
+		if subscribers.Len() == 0 {
+			delete(s.feeds, name)
+			s.send("UNSUBSCRIBE", name)
+		}
+	}
+}
+
+// Close disconnects the stream and closes the channels of every
+// subscription.
+func (s *BinanceStream) Close() {
+	s.m.Lock()
+	defer s.m.Unlock()
+	if s.cancel != nil {
+		s.cancel()
+		s.cancel = nil
+	}
+	for name, b := range s.books {
+		b.subscribers.Close()
+		delete(s.books, name)
+	}
+	for name, subscribers := range s.feeds {
+		subscribers.Close()
+		delete(s.feeds, name)
+	}
+}
+
+// send asks for or stops the streams names. Streams asked for while the
+// connection is down are asked for by onConnect.
+func (s *BinanceStream) send(method string, names ...string) error {
+	s.nextID++
+	err := s.Conn.WriteJSON(map[string]interface{}{"method": method, "params": names, "id": s.nextID})
+	if err != nil && err != stream.ErrNotConnected {
+		return errors.Wrapf(err, "failed to %s %v", strings.ToLower(method), names)
+	}
+	return nil
+}
+
+func (s *BinanceStream) onConnect(*stream.Conn) error {
+	s.m.Lock()
+	defer s.m.Unlock()
+	var names []string
+	for name, b := range s.books {
+		// the diffs missed while disconnected are lost
+		b.synced = false
+		b.buffer = nil
+		b.book.Reset()
+		names = append(names, name)
+	}
+	for name := range s.feeds {
+		names = append(names, name)
+	}
+	if len(names) == 0 {
+		return nil
+	}
+	return s.send("SUBSCRIBE", names...)
+}
+
+func (s *BinanceStream) handle(msg []byte) error {
+	value := gjson.ParseBytes(msg)
+	if value.Get("id").Exists() {
+		if e := value.Get("error"); e.Exists() {
+			logger.Get().Warnw("binance stream request failed", "error", e.Get("msg").String())
+		}
+		return nil
+	}
+	name := value.Get("stream").Str
+	data := value.Get("data")
+	switch {
+	case strings.HasSuffix(name, "@bookTicker"):
+		s.publish(name, func(subscribers *stream.Subscribers) {
+			subscribers.PublishTick(models.OrderBookTick{
+				BestBidPrice:  helpers.ToDecimal(data.Get("b")),
+				BestBidAmount: helpers.ToDecimal(data.Get("B")),
+				BestAskPrice:  helpers.ToDecimal(data.Get("a")),
+				BestAskAmount: helpers.ToDecimal(data.Get("A")),
+			})
+		})
+		return nil
+	case data.Get("e").Str == "trade":
+		// the taker sold when the buyer is the maker
+		side := models.Ask
+		if data.Get("m").Bool() {
+			side = models.Bid
+		}
+		s.publish(name, func(subscribers *stream.Subscribers) {
+			subscribers.PublishTrade(models.PublicTrade{
+				ID:     strconv.FormatInt(data.Get("t").Int(), 10),
+				Type:   side,
+				Price:  helpers.ToDecimal(data.Get("p")),
+				Amount: helpers.ToDecimal(data.Get("q")),
+				Time:   millisToTime(data.Get("T").Int()),
+			})
+		})
+		return nil
+	case data.Get("e").Str != "depthUpdate":
+		return nil
+	}
+	s.onDepth(name, binanceDepth{
+		first: data.Get("U").Int(),
+		last:  data.Get("u").Int(),
+		bids:  data.Get("b").Array(),
+		asks:  data.Get("a").Array(),
+	})
+	return nil
+}
+
+func (s *BinanceStream) publish(name string, f func(subscribers *stream.Subscribers)) {
+	s.m.Lock()
+	defer s.m.Unlock()
+	if subscribers, ok := s.feeds[name]; ok {
+		f(subscribers)
+	}
+}
+
+func (s *BinanceStream) onDepth(name string, d binanceDepth) {
+	s.m.Lock()
+	defer s.m.Unlock()
+	b, ok := s.books[name]
+	if !ok {
+		return
+	}
+	if !b.synced {
+		b.buffer = append(b.buffer, d)
+		s.resync(name, b)
+		return
+	}
+	if d.last <= b.lastUpdateID {
+		return
+	}
+	if d.first > b.lastUpdateID+1 {
+		s.desync(name, b, []binanceDepth{d})
+		return
+	}
+	b.apply(d)
+	b.subscribers.PublishBoard(b.book)
+}
+
+// desync drops the board of b, keeping buffer to apply over the next
+// snapshot.
+func (s *BinanceStream) desync(name string, b *binanceBook, buffer []binanceDepth) {
+	b.synced = false
+	b.book.Reset()
+	b.buffer = buffer
+	s.resync(name, b)
+}
+
+// resync fetches the snapshot of b unless it is being fetched already.
+func (s *BinanceStream) resync(name string, b *binanceBook) {
+	if b.fetching {
+		return
+	}
+	b.fetching = true
+	go func() {
+		board, lastUpdateID, err := s.Api.depth(b.trading, b.settlement)
+		s.m.Lock()
+		defer s.m.Unlock()
+		b.fetching = false
+		if s.books[name] != b || b.synced {
+			return
+		}
+		if err != nil {
+			// the next diff tries again
+			logger.Get().Warnw("failed to fetch binance depth snapshot", "stream", name, "error", err)
+			return
+		}
+		s.load(name, b, board, lastUpdateID)
+	}()
+}
+
+// load applies the buffered diffs of b over a snapshot. The first diff
+// applied must include the update following the snapshot, or the snapshot
+// is fetched again.
+func (s *BinanceStream) load(name string, b *binanceBook, board *models.Board, lastUpdateID int64) {
+	buffer := b.buffer
+	for len(buffer) > 0 && buffer[0].last <= lastUpdateID {
+		buffer = buffer[1:]
+	}
+	if len(buffer) > 0 && buffer[0].first > lastUpdateID+1 {
+		b.buffer = buffer
+		s.resync(name, b)
+		return
+	}
+	b.book.Load(board)
+	b.lastUpdateID = lastUpdateID
+	b.synced = true
+	b.buffer = nil
+	for i, d := range buffer {
+		if d.first > b.lastUpdateID+1 {
+			s.desync(name, b, buffer[i:])
+			return
+		}
+		b.apply(d)
+	}
+	b.subscribers.PublishBoard(b.book)
+}
+
+func (b *binanceBook) apply(d binanceDepth) {
+	for _, level := range d.bids {
+		b.book.Set(models.Bid, helpers.ToDecimal(level.Get("0")), helpers.ToDecimal(level.Get("1")))
+	}
+	for _, level := range d.asks {
+		b.book.Set(models.Ask, helpers.ToDecimal(level.Get("0")), helpers.ToDecimal(level.Get("1")))
+	}
+	b.lastUpdateID = d.last
+}
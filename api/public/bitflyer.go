@@ -1,17 +1,21 @@
 package public
 
 import (
+	"context"
 	"io/ioutil"
 	"net/http"
+	"strconv"
 	"time"
 
 	"sync"
 
-	"strings"
-
 	"github.com/Jeffail/gabs"
 	"github.com/antonholmquist/jason"
+	"github.com/fxpgr/go-exchange-client/apierrors"
+	"github.com/fxpgr/go-exchange-client/decimal"
+	"github.com/fxpgr/go-exchange-client/helpers"
 	"github.com/fxpgr/go-exchange-client/models"
+	"github.com/fxpgr/go-exchange-client/symbols"
 	"github.com/pkg/errors"
 	"github.com/tidwall/gjson"
 )
@@ -20,6 +24,8 @@ const (
 	BITFLYER_BASE_URL = "https://api.bitflyer.jp/v1"
 )
 
+var bitflyerSymbols = symbols.For("bitflyer")
+
 func NewBitflyerPublicApi() (*BitflyerApi, error) {
 	api := &BitflyerApi{
 		BaseURL:           BITFLYER_BASE_URL,
@@ -28,7 +34,7 @@ func NewBitflyerPublicApi() (*BitflyerApi, error) {
 		volumeMap:         nil,
 		orderBookTickMap:  nil,
 		rateLastUpdated:   time.Date(1970, 1, 1, 0, 0, 0, 0, time.UTC),
-		HttpClient:        http.Client{},
+		HttpClient:        http.Client{Transport: newTransport("bitflyer", nil)},
 
 		m: new(sync.Mutex),
 	}
@@ -40,6 +46,7 @@ type BitflyerApi struct {
 	BaseURL           string
 	RateCacheDuration time.Duration
 	HttpClient        http.Client
+	clientContext
 
 	volumeMap        map[string]map[string]float64
 	rateMap          map[string]map[string]float64
@@ -52,10 +59,16 @@ type BitflyerApi struct {
 }
 
 func (h *BitflyerApi) SetTransport(transport http.RoundTripper) error {
-	h.HttpClient.Transport = transport
+	h.HttpClient.Transport = newTransport("bitflyer", transport)
 	return nil
 }
 
+func (h *BitflyerApi) WithContext(ctx context.Context) PublicClient {
+	c := *h
+	c.ctx = ctx
+	return &c
+}
+
 func (b *BitflyerApi) publicApiUrl(command string) string {
 	return b.BaseURL + "/" + command
 }
@@ -72,7 +85,7 @@ func (b *BitflyerApi) fetchRate() error {
 	b.volumeMap = make(map[string]map[string]float64)
 	b.orderBookTickMap = make(map[string]map[string]models.OrderBookTick)
 	url := b.publicApiUrl("ticker")
-	resp, err := b.HttpClient.Get(url)
+	resp, err := httpGet(b.context(), &b.HttpClient, url)
 	if err != nil {
 		return errors.Wrapf(err, "failed to fetch %s", url)
 	}
@@ -89,18 +102,11 @@ func (b *BitflyerApi) fetchRate() error {
 	}
 	pair := json.Path("product_code").Data().(string)
 
-	var settlement string
-	var trading string
-	for _, s := range b.settlements {
-		index := strings.LastIndex(pair, s)
-		if index != 0 && index == len(pair)-len(s) {
-			settlement = s
-			trading = strings.Replace(pair[0:index], "_", "", -1)
-		}
-	}
-	if settlement == "" || trading == "" {
+	p, ok := bitflyerSymbols.Pair(pair)
+	if !ok {
 		return errors.New("pair is not parsed")
 	}
+	trading, settlement := p.Trading, p.Settlement
 	// update rate
 	last, ok := json.Path("ltp").Data().(float64)
 	if !ok {
@@ -141,8 +147,8 @@ func (b *BitflyerApi) fetchRate() error {
 		b.orderBookTickMap[trading] = n
 	}
 	n[settlement] = models.OrderBookTick{
-		BestAskPrice: ask,
-		BestBidPrice: bid,
+		BestAskPrice: decimal.NewFromFloat(ask),
+		BestBidPrice: decimal.NewFromFloat(bid),
 	}
 	return nil
 }
@@ -154,7 +160,7 @@ func (b *BitflyerApi) fetchPrecision() error {
 	b.precisionMap = make(map[string]map[string]models.Precisions)
 
 	url := b.publicApiUrl("ticker")
-	resp, err := b.HttpClient.Get(url)
+	resp, err := httpGet(b.context(), &b.HttpClient, url)
 	if err != nil {
 		return errors.Wrapf(err, "failed to fetch %s", url)
 	}
@@ -167,18 +173,11 @@ func (b *BitflyerApi) fetchPrecision() error {
 	value := gjson.ParseBytes(byteArray)
 	pair := value.Get("product_code").Str
 
-	var settlement string
-	var trading string
-	for _, s := range b.settlements {
-		index := strings.LastIndex(pair, s)
-		if index != 0 && index == len(pair)-len(s) {
-			settlement = s
-			trading = strings.Replace(pair[0:index], "_", "", -1)
-		}
-	}
-	if settlement == "" || trading == "" {
+	p, ok := bitflyerSymbols.Pair(pair)
+	if !ok {
 		return errors.New("pair is not parsed")
 	}
+	trading, settlement := p.Trading, p.Settlement
 
 	// update rate
 	last := value.Get("ltp").Raw
@@ -238,9 +237,9 @@ func (b *BitflyerApi) Volume(trading string, settlement string) (float64, error)
 	}
 
 	if m, ok := b.volumeMap[trading]; !ok {
-		return 0, errors.New("trading volume not found")
+		return 0, errors.Wrap(apierrors.ErrInvalidSymbol, "trading volume not found")
 	} else if volume, ok := m[settlement]; !ok {
-		return 0, errors.New("settlement volume not found")
+		return 0, errors.Wrap(apierrors.ErrInvalidSymbol, "settlement volume not found")
 	} else {
 		return volume, nil
 	}
@@ -263,9 +262,9 @@ func (b *BitflyerApi) Rate(trading string, settlement string) (float64, error) {
 		b.rateLastUpdated = now
 	}
 	if m, ok := b.rateMap[trading]; !ok {
-		return 0, errors.New("trading rate not found")
+		return 0, errors.Wrap(apierrors.ErrInvalidSymbol, "trading rate not found")
 	} else if rate, ok := m[settlement]; !ok {
-		return 0, errors.New("settlement rate not found")
+		return 0, errors.Wrap(apierrors.ErrInvalidSymbol, "settlement rate not found")
 	} else {
 		return rate, nil
 	}
@@ -324,17 +323,35 @@ func (h *BitflyerApi) Precise(trading string, settlement string) (*models.Precis
 
 	h.fetchPrecision()
 	if m, ok := h.precisionMap[trading]; !ok {
-		return &models.Precisions{}, errors.Errorf("%s/%s", trading, settlement)
+		return &models.Precisions{}, errors.Wrapf(apierrors.ErrInvalidSymbol, "%s/%s", trading, settlement)
 	} else if precisions, ok := m[settlement]; !ok {
-		return &models.Precisions{}, errors.Errorf("%s/%s", trading, settlement)
+		return &models.Precisions{}, errors.Wrapf(apierrors.ErrInvalidSymbol, "%s/%s", trading, settlement)
 	} else {
 		return &precisions, nil
 	}
 }
 
+// bitflyerMinAmounts are the minimum order sizes of bitflyer, which
+// publishes no trading rules.
+var bitflyerMinAmounts = map[string]decimal.Decimal{
+	"BTC": decimal.New(1, -3),
+	"ETH": decimal.New(1, -2),
+	"BCH": decimal.New(1, -2),
+}
+
+func (h *BitflyerApi) Rules(trading string, settlement string) (*models.MarketRules, error) {
+	precisions, err := h.Precise(trading, settlement)
+	if err != nil {
+		return nil, err
+	}
+	rules := models.NewMarketRules(*precisions)
+	rules.MinAmount = bitflyerMinAmounts[trading]
+	return &rules, nil
+}
+
 func (b *BitflyerApi) Board(trading string, settlement string) (board *models.Board, err error) {
-	url := b.publicApiUrl("board") + "?product_code=" + strings.ToUpper(trading) + "_" + strings.ToLower(settlement)
-	resp, err := b.HttpClient.Get(url)
+	url := b.publicApiUrl("board") + "?product_code=" + bitflyerSymbols.Symbol(trading, settlement)
+	resp, err := httpGet(b.context(), &b.HttpClient, url)
 	if err != nil {
 		return nil, errors.Wrapf(err, "failed to fetch %s", url)
 	}
@@ -359,32 +376,32 @@ func (b *BitflyerApi) Board(trading string, settlement string) (board *models.Bo
 	bids := make([]models.BoardBar, 0)
 	asks := make([]models.BoardBar, 0)
 	for _, v := range jsonBids {
-		price, err := v.GetFloat64("price")
+		price, err := v.GetNumber("price")
 		if err != nil {
 			return nil, errors.Wrapf(err, "failed to parse price")
 		}
-		size, err := v.GetFloat64("size")
+		size, err := v.GetNumber("size")
 		if err != nil {
 			return nil, errors.Wrapf(err, "failed to parse size")
 		}
 		bids = append(bids, models.BoardBar{
-			Price:  price,
-			Amount: size,
+			Price:  helpers.ToDecimal(price),
+			Amount: helpers.ToDecimal(size),
 			Type:   models.Bid,
 		})
 	}
 	for _, v := range jsonAsks {
-		price, err := v.GetFloat64("price")
+		price, err := v.GetNumber("price")
 		if err != nil {
 			return nil, errors.Wrapf(err, "failed to parse price")
 		}
-		size, err := v.GetFloat64("size")
+		size, err := v.GetNumber("size")
 		if err != nil {
 			return nil, errors.Wrapf(err, "failed to parse size")
 		}
 		asks = append(asks, models.BoardBar{
-			Price:  price,
-			Amount: size,
+			Price:  helpers.ToDecimal(price),
+			Amount: helpers.ToDecimal(size),
 			Type:   models.Ask,
 		})
 	}
@@ -394,3 +411,33 @@ func (b *BitflyerApi) Board(trading string, settlement string) (board *models.Bo
 	}
 	return board, nil
 }
+
+func (b *BitflyerApi) Candles(trading string, settlement string, interval models.Interval, since time.Time, limit int) ([]models.Candle, error) {
+	return nil, errors.Wrap(apierrors.ErrUnsupported, "candles on bitflyer")
+}
+
+func (b *BitflyerApi) RecentTrades(trading string, settlement string, limit int) ([]models.PublicTrade, error) {
+	limit = tradesLimit(limit, 500)
+	url := b.publicApiUrl("getexecutions") + "?product_code=" + bitflyerSymbols.Symbol(trading, settlement) +
+		"&count=" + strconv.Itoa(limit)
+	byteArray, err := getBody(b.context(), &b.HttpClient, url)
+	if err != nil {
+		return nil, err
+	}
+	var trades []models.PublicTrade
+	for _, v := range gjson.ParseBytes(byteArray).Array() {
+		// exec_date is UTC without a zone designator
+		t, err := time.Parse("2006-01-02T15:04:05.999999999", v.Get("exec_date").Str)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to parse execution time %s", v.Get("exec_date").Str)
+		}
+		trades = append(trades, models.PublicTrade{
+			ID:     strconv.FormatInt(v.Get("id").Int(), 10),
+			Type:   takerSide(v.Get("side").Str),
+			Price:  helpers.ToDecimal(v.Get("price")),
+			Amount: helpers.ToDecimal(v.Get("size")),
+			Time:   t,
+		})
+	}
+	return latestTrades(trades, limit), nil
+}
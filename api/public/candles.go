@@ -0,0 +1,102 @@
+package public
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/fxpgr/go-exchange-client/apierrors"
+	"github.com/fxpgr/go-exchange-client/models"
+	"github.com/pkg/errors"
+)
+
+// defaultCandleLimit is the number of candles returned for a zero since and
+// limit.
+const defaultCandleLimit = 500
+
+// pageCandles collects the candles of interval from since, oldest first. fetch
+// is called with the open time of the next candle wanted until limit candles
+// are found or the present is reached. On exchanges serving a fixed window of
+// window intervals from start, a page bringing nothing new moves start past
+// the window, so periods without trades are skipped. A zero window stops at
+// such a page instead, so fetch may ignore start on exchanges which only serve
+// the latest candles. A zero since asks for the latest limit candles.
+func pageCandles(interval models.Interval, since time.Time, limit int, window int,
+	fetch func(start time.Time) ([]models.Candle, error)) ([]models.Candle, error) {
+	step := interval.Duration()
+	if step == 0 {
+		return nil, errors.Errorf("unknown interval %d", interval)
+	}
+	now := time.Now()
+	if since.IsZero() {
+		if limit <= 0 {
+			limit = defaultCandleLimit
+		}
+		since = now.Add(-time.Duration(limit) * step).Truncate(step)
+	}
+	var candles []models.Candle
+	start := since
+	for limit <= 0 || len(candles) < limit {
+		page, err := fetch(start)
+		if err != nil {
+			return nil, err
+		}
+		sort.Slice(page, func(i, j int) bool {
+			return page[i].Time.Before(page[j].Time)
+		})
+		added := 0
+		for _, c := range page {
+			if c.Time.Before(start) {
+				continue
+			}
+			candles = append(candles, c)
+			start = c.Time.Add(step)
+			added++
+		}
+		if added == 0 {
+			if window <= 0 {
+				break
+			}
+			start = start.Add(time.Duration(window) * step)
+		}
+		if start.After(now) {
+			break
+		}
+	}
+	if limit > 0 && len(candles) > limit {
+		candles = candles[:limit]
+	}
+	return candles, nil
+}
+
+func unsupportedInterval(exchange string, interval models.Interval) error {
+	return errors.Wrapf(apierrors.ErrUnsupported, "%s candles on %s", interval, exchange)
+}
+
+// getBody returns the body of a successful GET of url.
+func getBody(ctx context.Context, client *http.Client, url string) ([]byte, error) {
+	resp, err := httpGet(ctx, client, url)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to fetch %s", url)
+	}
+	defer resp.Body.Close()
+	byteArray, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to fetch %s", url)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, errors.Wrapf(apierrors.New(resp.StatusCode, "", string(byteArray), nil), "failed to fetch %s", url)
+	}
+	return byteArray, nil
+}
+
+func millisToTime(ms int64) time.Time {
+	return time.Unix(0, ms*int64(time.Millisecond))
+}
+
+func millis(t time.Time) string {
+	return strconv.FormatInt(t.UnixNano()/int64(time.Millisecond), 10)
+}
@@ -1,11 +1,17 @@
 package public
 
 import (
+	"context"
 	"errors"
+	"github.com/fxpgr/go-exchange-client/apierrors"
 	"github.com/fxpgr/go-exchange-client/models"
+	"github.com/fxpgr/go-exchange-client/ratelimit"
+	"github.com/fxpgr/go-exchange-client/retry"
+	"io/ioutil"
 	"net/http"
 	"strings"
 	"sync"
+	"time"
 )
 
 var (
@@ -24,8 +30,64 @@ type PublicClient interface {
 	FrozenCurrency() ([]string, error)
 	Board(trading string, settlement string) (*models.Board, error)
 	Precise(trading string, settlement string) (*models.Precisions, error)
+	// Rules returns the tick size, lot size and minimum notional of a pair.
+	Rules(trading string, settlement string) (*models.MarketRules, error)
+	// Candles returns the candles opening at or after since, oldest first,
+	// paging through the exchange until limit candles are found. A zero
+	// since returns the latest limit candles.
+	Candles(trading string, settlement string, interval models.Interval, since time.Time, limit int) ([]models.Candle, error)
+	// RecentTrades returns the latest limit trades of the public tape, oldest
+	// first. A limit of zero or less returns as many as the exchange serves.
+	RecentTrades(trading string, settlement string, limit int) ([]models.PublicTrade, error)
 
 	SetTransport(transport http.RoundTripper) error
+	// WithContext returns a shallow copy of the client whose requests are
+	// bound to ctx, so they are aborted once ctx is cancelled or expires.
+	WithContext(ctx context.Context) PublicClient
+}
+
+type clientContext struct {
+	ctx context.Context
+}
+
+func (c clientContext) context() context.Context {
+	if c.ctx == nil {
+		return context.Background()
+	}
+	return c.ctx
+}
+
+// newTransport wraps base with the retry policy and the rate limiter shared by
+// every client of exchange.
+func newTransport(exchange string, base http.RoundTripper) http.RoundTripper {
+	return retry.NewTransport(ratelimit.NewTransport(exchange, base))
+}
+
+func httpGet(ctx context.Context, client *http.Client, url string) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if err := statusError(resp); err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+// statusError consumes and closes the body of a response whose status has an
+// exchange independent meaning, such as a rate limit or a ban.
+func statusError(resp *http.Response) error {
+	kind := apierrors.FromStatus(resp.StatusCode)
+	if kind == nil {
+		return nil
+	}
+	defer resp.Body.Close()
+	body, _ := ioutil.ReadAll(resp.Body)
+	return apierrors.New(resp.StatusCode, "", string(body), kind)
 }
 
 func NewDefaultClient(exchangeName string) PublicClient {
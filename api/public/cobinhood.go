@@ -1,6 +1,7 @@
 package public
 
 import (
+	"context"
 	"net/http"
 	"strconv"
 	"sync"
@@ -8,18 +9,23 @@ import (
 
 	"fmt"
 	"github.com/antonholmquist/jason"
+	"github.com/fxpgr/go-exchange-client/apierrors"
+	"github.com/fxpgr/go-exchange-client/decimal"
+	"github.com/fxpgr/go-exchange-client/helpers"
 	"github.com/fxpgr/go-exchange-client/models"
+	"github.com/fxpgr/go-exchange-client/symbols"
 	"github.com/pkg/errors"
 	"github.com/tidwall/gjson"
 	"io/ioutil"
 	"net/url"
-	"strings"
 )
 
 const (
 	COBINHOOD_BASE_URL = "https://api.cobinhood.com"
 )
 
+var cobinhoodSymbols = symbols.For("cobinhood")
+
 type CobinhoodApiConfig struct {
 }
 
@@ -33,6 +39,7 @@ func NewCobinhoodPublicApi() (*CobinhoodApi, error) {
 		rateLastUpdated:            time.Date(1970, 1, 1, 0, 0, 0, 0, time.UTC),
 		CurrencyPairsCacheDuration: 7 * 24 * time.Hour,
 		currencyPairsLastUpdated:   time.Date(1970, 1, 1, 0, 0, 0, 0, time.UTC),
+		HttpClient:                 http.Client{Transport: newTransport("cobinhood", nil)},
 
 		m:         new(sync.Mutex),
 		currencyM: new(sync.Mutex),
@@ -48,11 +55,13 @@ type CobinhoodApi struct {
 	rateMap                    map[string]map[string]float64
 	orderBookTickMap           map[string]map[string]models.OrderBookTick
 	precisionMap               map[string]map[string]models.Precisions
+	rulesMap                   map[string]map[string]models.MarketRules
 	rateLastUpdated            time.Time
 	currencyPairs              []models.CurrencyPair
 	CurrencyPairsCacheDuration time.Duration
 	currencyPairsLastUpdated   time.Time
 	HttpClient                 http.Client
+	clientContext
 
 	settlements []string
 
@@ -62,10 +71,16 @@ type CobinhoodApi struct {
 }
 
 func (h *CobinhoodApi) SetTransport(transport http.RoundTripper) error {
-	h.HttpClient.Transport = transport
+	h.HttpClient.Transport = newTransport("cobinhood", transport)
 	return nil
 }
 
+func (h *CobinhoodApi) WithContext(ctx context.Context) PublicClient {
+	c := *h
+	c.ctx = ctx
+	return &c
+}
+
 func (h *CobinhoodApi) publicApiUrl(command string) string {
 	return h.BaseURL + command
 }
@@ -94,7 +109,7 @@ func (h *CobinhoodApi) fetchPrecision() error {
 	h.precisionMap = make(map[string]map[string]models.Precisions)
 
 	url := h.publicApiUrl("/v1/market/tickers")
-	resp, err := h.HttpClient.Get(url)
+	resp, err := httpGet(h.context(), &h.HttpClient, url)
 	if err != nil {
 		return errors.Wrapf(err, "failed to fetch %s", url)
 	}
@@ -110,12 +125,11 @@ func (h *CobinhoodApi) fetchPrecision() error {
 		last := v.Get("last_trade_price").Str
 		volume := v.Get("24h_volume").Str
 		pairString := v.Get("trading_pair_id").Str
-		currencies := strings.Split(pairString, "-")
-		if len(currencies) != 2 {
+		pair, ok := cobinhoodSymbols.Pair(pairString)
+		if !ok {
 			continue
 		}
-		trading := currencies[0]
-		settlement := currencies[1]
+		trading, settlement := pair.Trading, pair.Settlement
 
 		m, ok := h.precisionMap[trading]
 		if !ok {
@@ -135,7 +149,7 @@ func (h *CobinhoodApi) fetchRate() error {
 	h.volumeMap = make(map[string]map[string]float64)
 	h.orderBookTickMap = make(map[string]map[string]models.OrderBookTick)
 	url := h.publicApiUrl("/v1/market/tickers")
-	resp, err := h.HttpClient.Get(url)
+	resp, err := httpGet(h.context(), &h.HttpClient, url)
 	if err != nil {
 		return errors.Wrapf(err, "failed to fetch %s", url)
 	}
@@ -180,7 +194,7 @@ func (h *CobinhoodApi) fetchRate() error {
 		if err != nil {
 			return errors.Wrapf(err, "failed to parse quote")
 		}
-		askf, err := strconv.ParseFloat(askString, 64)
+		askf, err := decimal.NewFromString(askString)
 		if err != nil {
 			return errors.Wrapf(err, "failed to parse quote")
 		}
@@ -189,7 +203,7 @@ func (h *CobinhoodApi) fetchRate() error {
 		if err != nil {
 			return errors.Wrapf(err, "failed to parse quote")
 		}
-		bidf, err := strconv.ParseFloat(bidString, 64)
+		bidf, err := decimal.NewFromString(bidString)
 		if err != nil {
 			return errors.Wrapf(err, "failed to parse quote")
 		}
@@ -197,12 +211,11 @@ func (h *CobinhoodApi) fetchRate() error {
 		if err != nil {
 			return errors.Wrapf(err, "failed to parse quote")
 		}
-		currencies := strings.Split(pairString, "-")
-		if len(currencies) != 2 {
+		pair, ok := cobinhoodSymbols.Pair(pairString)
+		if !ok {
 			continue
 		}
-		trading := currencies[0]
-		settlement := currencies[1]
+		trading, settlement := pair.Trading, pair.Settlement
 		m, ok := h.rateMap[trading]
 		if !ok {
 			m = make(map[string]float64)
@@ -277,7 +290,7 @@ func (h *CobinhoodApi) CurrencyPairs() ([]models.CurrencyPair, error) {
 		return h.currencyPairs, nil
 	}
 	url := h.publicApiUrl("/v1/market/trading_pairs")
-	resp, err := h.HttpClient.Get(url)
+	resp, err := httpGet(h.context(), &h.HttpClient, url)
 	if err != nil {
 		return nil, errors.Wrapf(err, "failed to fetch %s", url)
 	}
@@ -309,10 +322,7 @@ func (h *CobinhoodApi) CurrencyPairs() ([]models.CurrencyPair, error) {
 		if err != nil {
 			return nil, errors.Wrapf(err, "failed to parse quote")
 		}
-		pair := models.CurrencyPair{
-			Trading:    strings.ToUpper(trading),
-			Settlement: strings.ToUpper(settlement),
-		}
+		pair := cobinhoodSymbols.AddPair(trading, settlement)
 		pairs = append(pairs, pair)
 	}
 	h.currencyPairs = pairs
@@ -332,9 +342,9 @@ func (h *CobinhoodApi) Volume(trading string, settlement string) (float64, error
 		h.rateLastUpdated = now
 	}
 	if m, ok := h.volumeMap[trading]; !ok {
-		return 0, errors.Errorf("%s/%s", trading, settlement)
+		return 0, errors.Wrapf(apierrors.ErrInvalidSymbol, "%s/%s", trading, settlement)
 	} else if volume, ok := m[settlement]; !ok {
-		return 0, errors.Errorf("%s/%s", trading, settlement)
+		return 0, errors.Wrapf(apierrors.ErrInvalidSymbol, "%s/%s", trading, settlement)
 	} else {
 		return volume, nil
 	}
@@ -357,9 +367,9 @@ func (h *CobinhoodApi) Rate(trading string, settlement string) (float64, error)
 		h.rateLastUpdated = now
 	}
 	if m, ok := h.rateMap[trading]; !ok {
-		return 0, errors.Errorf("%s/%s", trading, settlement)
+		return 0, errors.Wrapf(apierrors.ErrInvalidSymbol, "%s/%s", trading, settlement)
 	} else if rate, ok := m[settlement]; !ok {
-		return 0, errors.Errorf("%s/%s", trading, settlement)
+		return 0, errors.Wrapf(apierrors.ErrInvalidSymbol, "%s/%s", trading, settlement)
 	} else {
 		return rate, nil
 	}
@@ -372,18 +382,60 @@ func (h *CobinhoodApi) Precise(trading string, settlement string) (*models.Preci
 
 	h.fetchPrecision()
 	if m, ok := h.precisionMap[trading]; !ok {
-		return &models.Precisions{}, errors.Errorf("%s/%s", trading, settlement)
+		return &models.Precisions{}, errors.Wrapf(apierrors.ErrInvalidSymbol, "%s/%s", trading, settlement)
 	} else if precisions, ok := m[settlement]; !ok {
-		return &models.Precisions{}, errors.Errorf("%s/%s", trading, settlement)
+		return &models.Precisions{}, errors.Wrapf(apierrors.ErrInvalidSymbol, "%s/%s", trading, settlement)
 	} else {
 		return &precisions, nil
 	}
 }
 
+func (h *CobinhoodApi) fetchRules() error {
+	if h.rulesMap != nil {
+		return nil
+	}
+	url := h.publicApiUrl("/v1/market/trading_pairs")
+	byteArray, err := getBody(h.context(), &h.HttpClient, url)
+	if err != nil {
+		return err
+	}
+	rulesMap := make(map[string]map[string]models.MarketRules)
+	for _, v := range gjson.ParseBytes(byteArray).Get("result.trading_pairs").Array() {
+		pair := cobinhoodSymbols.AddPair(v.Get("base_currency_id").Str, v.Get("quote_currency_id").Str)
+		r, ok := rulesMap[pair.Trading]
+		if !ok {
+			r = make(map[string]models.MarketRules)
+			rulesMap[pair.Trading] = r
+		}
+		r[pair.Settlement] = models.MarketRules{
+			TickSize:  helpers.ToDecimal(v.Get("quote_increment")),
+			MinAmount: helpers.ToDecimal(v.Get("base_min_size")),
+			MaxAmount: helpers.ToDecimal(v.Get("base_max_size")),
+		}
+	}
+	h.rulesMap = rulesMap
+	return nil
+}
+
+func (h *CobinhoodApi) Rules(trading string, settlement string) (*models.MarketRules, error) {
+	h.m.Lock()
+	defer h.m.Unlock()
+	if err := h.fetchRules(); err != nil {
+		return nil, err
+	}
+	if m, ok := h.rulesMap[trading]; !ok {
+		return nil, errors.Wrapf(apierrors.ErrInvalidSymbol, "%s/%s", trading, settlement)
+	} else if rules, ok := m[settlement]; !ok {
+		return nil, errors.Wrapf(apierrors.ErrInvalidSymbol, "%s/%s", trading, settlement)
+	} else {
+		return &rules, nil
+	}
+}
+
 func (h *CobinhoodApi) FrozenCurrency() ([]string, error) {
 	var frozens []string
 	url := h.publicApiUrl("/v1/market/currencies")
-	resp, err := h.HttpClient.Get(url)
+	resp, err := httpGet(h.context(), &h.HttpClient, url)
 	if err != nil {
 		return nil, errors.Wrapf(err, "failed to fetch %s", url)
 	}
@@ -430,8 +482,8 @@ func (h *CobinhoodApi) FrozenCurrency() ([]string, error) {
 func (h *CobinhoodApi) Board(trading string, settlement string) (board *models.Board, err error) {
 	args := url.Values{}
 	args.Add("limit", "10000")
-	path := h.publicApiUrl("/v1/market/orderbooks/"+trading+"-"+settlement) + "?" + args.Encode()
-	resp, err := h.HttpClient.Get(path)
+	path := h.publicApiUrl("/v1/market/orderbooks/"+cobinhoodSymbols.Symbol(trading, settlement)) + "?" + args.Encode()
+	resp, err := httpGet(h.context(), &h.HttpClient, path)
 	if err != nil {
 		return nil, errors.Wrapf(err, "failed to fetch %s", path)
 	}
@@ -478,11 +530,11 @@ func (h *CobinhoodApi) Board(trading string, settlement string) (board *models.B
 		if err != nil {
 			return nil, errors.Wrapf(err, "failed to parse amount")
 		}
-		price, err := strconv.ParseFloat(priceStr, 64)
+		price, err := decimal.NewFromString(priceStr)
 		if err != nil {
 			return nil, errors.Wrapf(err, "failed to parse amount")
 		}
-		amount, err := strconv.ParseFloat(amountStr, 64)
+		amount, err := decimal.NewFromString(amountStr)
 		if err != nil {
 			return nil, errors.Wrapf(err, "failed to parse amount")
 		}
@@ -506,11 +558,11 @@ func (h *CobinhoodApi) Board(trading string, settlement string) (board *models.B
 		if err != nil {
 			return nil, errors.Wrapf(err, "failed to parse amount")
 		}
-		price, err := strconv.ParseFloat(priceStr, 64)
+		price, err := decimal.NewFromString(priceStr)
 		if err != nil {
 			return nil, errors.Wrapf(err, "failed to parse amount")
 		}
-		amount, err := strconv.ParseFloat(amountStr, 64)
+		amount, err := decimal.NewFromString(amountStr)
 		if err != nil {
 			return nil, errors.Wrapf(err, "failed to parse amount")
 		}
@@ -526,3 +578,70 @@ func (h *CobinhoodApi) Board(trading string, settlement string) (board *models.B
 	}
 	return board, nil
 }
+
+var cobinhoodIntervals = map[models.Interval]string{
+	models.Interval1m:  "1m",
+	models.Interval5m:  "5m",
+	models.Interval15m: "15m",
+	models.Interval30m: "30m",
+	models.Interval1h:  "1h",
+	models.Interval6h:  "6h",
+	models.Interval12h: "12h",
+	models.Interval1d:  "1D",
+	models.Interval1w:  "7D",
+}
+
+const cobinhoodCandlesPageSize = 1000
+
+func (h *CobinhoodApi) Candles(trading string, settlement string, interval models.Interval, since time.Time, limit int) ([]models.Candle, error) {
+	timeframe, ok := cobinhoodIntervals[interval]
+	if !ok {
+		return nil, unsupportedInterval("cobinhood", interval)
+	}
+	return pageCandles(interval, since, limit, cobinhoodCandlesPageSize, func(start time.Time) ([]models.Candle, error) {
+		end := start.Add(cobinhoodCandlesPageSize * interval.Duration())
+		path := h.publicApiUrl("/v1/chart/candles/"+cobinhoodSymbols.Symbol(trading, settlement)) + "?timeframe=" + timeframe +
+			"&start_time=" + millis(start) + "&end_time=" + millis(end)
+		byteArray, err := getBody(h.context(), &h.HttpClient, path)
+		if err != nil {
+			return nil, err
+		}
+		var candles []models.Candle
+		for _, v := range gjson.ParseBytes(byteArray).Get("result.candles").Array() {
+			candles = append(candles, models.Candle{
+				Time:   millisToTime(v.Get("timestamp").Int()),
+				Open:   helpers.ToDecimal(v.Get("open")),
+				High:   helpers.ToDecimal(v.Get("high")),
+				Low:    helpers.ToDecimal(v.Get("low")),
+				Close:  helpers.ToDecimal(v.Get("close")),
+				Volume: helpers.ToDecimal(v.Get("volume")),
+			})
+		}
+		return candles, nil
+	})
+}
+
+func (h *CobinhoodApi) RecentTrades(trading string, settlement string, limit int) ([]models.PublicTrade, error) {
+	limit = tradesLimit(limit, 50)
+	url := h.publicApiUrl("/v1/market/trades/"+cobinhoodSymbols.Symbol(trading, settlement)) + "?limit=" + strconv.Itoa(limit)
+	byteArray, err := getBody(h.context(), &h.HttpClient, url)
+	if err != nil {
+		return nil, err
+	}
+	var trades []models.PublicTrade
+	for _, v := range gjson.ParseBytes(byteArray).Get("result.trades").Array() {
+		// the taker is on the other side of maker_side
+		side := models.Ask
+		if v.Get("maker_side").Str == "bid" {
+			side = models.Bid
+		}
+		trades = append(trades, models.PublicTrade{
+			ID:     v.Get("id").String(),
+			Type:   side,
+			Price:  helpers.ToDecimal(v.Get("price")),
+			Amount: helpers.ToDecimal(v.Get("size")),
+			Time:   millisToTime(v.Get("timestamp").Int()),
+		})
+	}
+	return latestTrades(trades, limit), nil
+}
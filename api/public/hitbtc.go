@@ -1,6 +1,7 @@
 package public
 
 import (
+	"context"
 	"io/ioutil"
 	"net/http"
 	"strconv"
@@ -11,7 +12,11 @@ import (
 	"github.com/Jeffail/gabs"
 	"github.com/antonholmquist/jason"
 	"github.com/fxpgr/go-exchange-client/api/unified"
+	"github.com/fxpgr/go-exchange-client/apierrors"
+	"github.com/fxpgr/go-exchange-client/decimal"
+	"github.com/fxpgr/go-exchange-client/helpers"
 	"github.com/fxpgr/go-exchange-client/models"
+	"github.com/fxpgr/go-exchange-client/symbols"
 	"github.com/patrickmn/go-cache"
 	"github.com/pkg/errors"
 	"github.com/tidwall/gjson"
@@ -21,6 +26,8 @@ const (
 	HITBTC_BASE_URL = "https://api.hitbtc.com/api/2"
 )
 
+var hitbtcSymbols = symbols.For("hitbtc")
+
 type HitbtcApiConfig struct {
 }
 
@@ -38,7 +45,7 @@ func NewHitbtcPublicApi() (*HitbtcApi, error) {
 		precisionMap:      nil,
 		rateLastUpdated:   time.Date(1970, 1, 1, 0, 0, 0, 0, time.UTC),
 		boardCache:        cache.New(3*time.Second, 1*time.Second),
-		HttpClient:        &http.Client{},
+		HttpClient:        &http.Client{Transport: newTransport("hitbtc", nil)},
 		ShrimpyClient:     shrimpyApi,
 
 		m: new(sync.Mutex),
@@ -54,10 +61,12 @@ type HitbtcApi struct {
 	rateMap           map[string]map[string]float64
 	orderBookTickMap  map[string]map[string]models.OrderBookTick
 	precisionMap      map[string]map[string]models.Precisions
+	rulesMap          map[string]map[string]models.MarketRules
 	rateLastUpdated   time.Time
 	boardCache        *cache.Cache
 	HttpClient        *http.Client
-	ShrimpyClient     *unified.ShrimpyApiClient
+	clientContext
+	ShrimpyClient *unified.ShrimpyApiClient
 
 	settlements []string
 
@@ -66,10 +75,16 @@ type HitbtcApi struct {
 }
 
 func (h *HitbtcApi) SetTransport(transport http.RoundTripper) error {
-	h.HttpClient.Transport = transport
+	h.HttpClient.Transport = newTransport("hitbtc", transport)
 	return nil
 }
 
+func (h *HitbtcApi) WithContext(ctx context.Context) PublicClient {
+	c := *h
+	c.ctx = ctx
+	return &c
+}
+
 func (h *HitbtcApi) publicApiUrl(command string) string {
 	return h.BaseURL + "/public/" + command
 }
@@ -77,7 +92,7 @@ func (h *HitbtcApi) publicApiUrl(command string) string {
 func (h *HitbtcApi) fetchSettlements() error {
 	settlements := make([]string, 0)
 	url := h.publicApiUrl("symbol")
-	resp, err := h.HttpClient.Get(url)
+	resp, err := httpGet(h.context(), h.HttpClient, url)
 	if err != nil {
 		return errors.Wrapf(err, "failed to fetch %s", url)
 	}
@@ -97,13 +112,25 @@ func (h *HitbtcApi) fetchSettlements() error {
 	if err != nil {
 		return errors.Wrapf(err, "failed to parse json")
 	}
+	rulesMap := make(map[string]map[string]models.MarketRules)
 	for _, v := range pairMap {
 		settlement, ok := v.Path("quoteCurrency").Data().(string)
 		if !ok {
 			continue
 		}
+		if trading, ok := v.Path("baseCurrency").Data().(string); ok {
+			pair := hitbtcSymbols.AddPair(trading, settlement)
+			settlement = pair.Settlement
+			r, ok := rulesMap[pair.Trading]
+			if !ok {
+				r = make(map[string]models.MarketRules)
+				rulesMap[pair.Trading] = r
+			}
+			r[settlement] = hitbtcRules(v)
+		}
 		settlements = append(settlements, settlement)
 	}
+	h.rulesMap = rulesMap
 	m := make(map[string]bool)
 	uniq := []string{}
 	for _, ele := range settlements {
@@ -116,6 +143,40 @@ func (h *HitbtcApi) fetchSettlements() error {
 	return nil
 }
 
+// hitbtcRules reads a symbol, whose minimum quantity is its increment.
+func hitbtcRules(symbol *gabs.Container) models.MarketRules {
+	tickSize, _ := symbol.Path("tickSize").Data().(string)
+	stepSize, _ := symbol.Path("quantityIncrement").Data().(string)
+	rules := models.MarketRules{}
+	rules.TickSize, _ = decimal.NewFromString(tickSize)
+	rules.StepSize, _ = decimal.NewFromString(stepSize)
+	rules.MinAmount = rules.StepSize
+	return rules
+}
+
+func (h *HitbtcApi) fetchRules() error {
+	if h.rulesMap != nil {
+		return nil
+	}
+	return h.fetchSettlements()
+}
+
+// pair splits a symbol registered by fetchSettlements, or else by the
+// settlement currency it ends with.
+func (h *HitbtcApi) pair(symbol string) (string, string, bool) {
+	if p, ok := hitbtcSymbols.Pair(symbol); ok {
+		return p.Trading, p.Settlement, true
+	}
+	for _, s := range h.settlements {
+		native := hitbtcSymbols.Native(s)
+		index := strings.LastIndex(symbol, native)
+		if index > 0 && index == len(symbol)-len(native) {
+			return hitbtcSymbols.Currency(symbol[0:index]), s, true
+		}
+	}
+	return "", "", false
+}
+
 func Precision(numStr string) int {
 	numStrArr := strings.Split(numStr, ".")
 	if len(numStrArr) != 2 {
@@ -131,7 +192,7 @@ func (h *HitbtcApi) fetchPrecision() error {
 	h.precisionMap = make(map[string]map[string]models.Precisions)
 
 	url := h.publicApiUrl("ticker")
-	resp, err := h.HttpClient.Get(url)
+	resp, err := httpGet(h.context(), h.HttpClient, url)
 	if err != nil {
 		return errors.Wrapf(err, "failed to fetch %s", url)
 	}
@@ -144,17 +205,8 @@ func (h *HitbtcApi) fetchPrecision() error {
 	value := gjson.Parse(string(byteArray))
 
 	for _, v := range value.Array() {
-		pair := v.Get("symbol").Str
-		var settlement string
-		var trading string
-		for _, s := range h.settlements {
-			index := strings.LastIndex(pair, s)
-			if index > 0 && index == len(pair)-len(s) {
-				settlement = s
-				trading = pair[0:index]
-			}
-		}
-		if settlement == "" || trading == "" {
+		trading, settlement, ok := h.pair(v.Get("symbol").Str)
+		if !ok {
 			continue
 		}
 		last := v.Get("last").Str
@@ -185,7 +237,7 @@ func (h *HitbtcApi) fetchRate() error {
 	h.volumeMap = make(map[string]map[string]float64)
 	h.orderBookTickMap = make(map[string]map[string]models.OrderBookTick)
 	url := h.publicApiUrl("ticker")
-	resp, err := h.HttpClient.Get(url)
+	resp, err := httpGet(h.context(), h.HttpClient, url)
 	if err != nil {
 		return errors.Wrapf(err, "failed to fetch %s", url)
 	}
@@ -209,17 +261,8 @@ func (h *HitbtcApi) fetchRate() error {
 		if !ok {
 			continue
 		}
-
-		var settlement string
-		var trading string
-		for _, s := range h.settlements {
-			index := strings.LastIndex(pair, s)
-			if index != -1 && index == len(pair)-len(s) {
-				settlement = s
-				trading = pair[0:index]
-			}
-		}
-		if settlement == "" || trading == "" {
+		trading, settlement, ok := h.pair(pair)
+		if !ok {
 			continue
 		}
 		// update rate
@@ -262,7 +305,7 @@ func (h *HitbtcApi) fetchRate() error {
 		if !ok {
 			continue
 		}
-		askPricef, err := strconv.ParseFloat(askPrice, 64)
+		askPricef, err := decimal.NewFromString(askPrice)
 		if err != nil {
 			return err
 		}
@@ -270,7 +313,7 @@ func (h *HitbtcApi) fetchRate() error {
 		if !ok {
 			continue
 		}
-		bidPricef, err := strconv.ParseFloat(bidPrice, 64)
+		bidPricef, err := decimal.NewFromString(bidPrice)
 		if err != nil {
 			return err
 		}
@@ -290,13 +333,15 @@ func (h *HitbtcApi) fetchRate() error {
 }
 
 func (h *HitbtcApi) fetchOrderBookTick() error {
-	boardMap, err := h.ShrimpyClient.GetBoards("hitbtc")
+	boardMap, err := h.ShrimpyClient.WithContext(h.context()).GetBoards("hitbtc")
 	if err != nil {
 		return err
 	}
 	orderBookTickMap := make(map[string]map[string]models.OrderBookTick)
 	for settlement, m := range boardMap {
+		settlement = hitbtcSymbols.Currency(settlement)
 		for trading, value := range m {
+			trading = hitbtcSymbols.Currency(trading)
 			l, ok := orderBookTickMap[trading]
 			if !ok {
 				l = make(map[string]models.OrderBookTick)
@@ -390,14 +435,29 @@ func (h *HitbtcApi) Precise(trading string, settlement string) (*models.Precisio
 
 	h.fetchPrecision()
 	if m, ok := h.precisionMap[trading]; !ok {
-		return &models.Precisions{}, errors.Errorf("%s/%s", trading, settlement)
+		return &models.Precisions{}, errors.Wrapf(apierrors.ErrInvalidSymbol, "%s/%s", trading, settlement)
 	} else if precisions, ok := m[settlement]; !ok {
-		return &models.Precisions{}, errors.Errorf("%s/%s", trading, settlement)
+		return &models.Precisions{}, errors.Wrapf(apierrors.ErrInvalidSymbol, "%s/%s", trading, settlement)
 	} else {
 		return &precisions, nil
 	}
 }
 
+func (h *HitbtcApi) Rules(trading string, settlement string) (*models.MarketRules, error) {
+	h.m.Lock()
+	defer h.m.Unlock()
+	if err := h.fetchRules(); err != nil {
+		return nil, err
+	}
+	if m, ok := h.rulesMap[trading]; !ok {
+		return nil, errors.Wrapf(apierrors.ErrInvalidSymbol, "%s/%s", trading, settlement)
+	} else if rules, ok := m[settlement]; !ok {
+		return nil, errors.Wrapf(apierrors.ErrInvalidSymbol, "%s/%s", trading, settlement)
+	} else {
+		return &rules, nil
+	}
+}
+
 func (h *HitbtcApi) Volume(trading string, settlement string) (float64, error) {
 	h.m.Lock()
 	defer h.m.Unlock()
@@ -411,9 +471,9 @@ func (h *HitbtcApi) Volume(trading string, settlement string) (float64, error) {
 		h.rateLastUpdated = now
 	}
 	if m, ok := h.volumeMap[trading]; !ok {
-		return 0, errors.Errorf("%s/%s", trading, settlement)
+		return 0, errors.Wrapf(apierrors.ErrInvalidSymbol, "%s/%s", trading, settlement)
 	} else if volume, ok := m[settlement]; !ok {
-		return 0, errors.Errorf("%s/%s", trading, settlement)
+		return 0, errors.Wrapf(apierrors.ErrInvalidSymbol, "%s/%s", trading, settlement)
 	} else {
 		return volume, nil
 	}
@@ -436,9 +496,9 @@ func (h *HitbtcApi) Rate(trading string, settlement string) (float64, error) {
 		h.rateLastUpdated = now
 	}
 	if m, ok := h.rateMap[trading]; !ok {
-		return 0, errors.Errorf("%s/%s", trading, settlement)
+		return 0, errors.Wrapf(apierrors.ErrInvalidSymbol, "%s/%s", trading, settlement)
 	} else if rate, ok := m[settlement]; !ok {
-		return 0, errors.Errorf("%s/%s", trading, settlement)
+		return 0, errors.Wrapf(apierrors.ErrInvalidSymbol, "%s/%s", trading, settlement)
 	} else {
 		return rate, nil
 	}
@@ -446,7 +506,7 @@ func (h *HitbtcApi) Rate(trading string, settlement string) (float64, error) {
 
 func (h *HitbtcApi) FrozenCurrency() ([]string, error) {
 	url := h.publicApiUrl("currency")
-	resp, err := h.HttpClient.Get(url)
+	resp, err := httpGet(h.context(), h.HttpClient, url)
 	if err != nil {
 		return nil, errors.Wrapf(err, "failed to fetch %s", url)
 	}
@@ -486,8 +546,8 @@ func (h *HitbtcApi) Board(trading string, settlement string) (board *models.Boar
 	if found {
 		return c.(*models.Board), nil
 	}
-	url := h.publicApiUrl("orderbook/" + trading + settlement)
-	resp, err := h.HttpClient.Get(url)
+	url := h.publicApiUrl("orderbook/" + hitbtcSymbols.Symbol(trading, settlement))
+	resp, err := httpGet(h.context(), h.HttpClient, url)
 	if err != nil {
 		return nil, errors.Wrapf(err, "failed to fetch %s", url)
 	}
@@ -516,7 +576,7 @@ func (h *HitbtcApi) Board(trading string, settlement string) (board *models.Boar
 		if err != nil {
 			return nil, errors.Wrapf(err, "failed to parse price")
 		}
-		price, err := strconv.ParseFloat(priceStr, 10)
+		price, err := decimal.NewFromString(priceStr)
 		if err != nil {
 			return nil, errors.Wrapf(err, "failed to parse price")
 		}
@@ -524,7 +584,7 @@ func (h *HitbtcApi) Board(trading string, settlement string) (board *models.Boar
 		if err != nil {
 			return nil, errors.Wrapf(err, "failed to parse size")
 		}
-		size, err := strconv.ParseFloat(sizeStr, 10)
+		size, err := decimal.NewFromString(sizeStr)
 		if err != nil {
 			return nil, errors.Wrapf(err, "failed to parse size")
 		}
@@ -539,7 +599,7 @@ func (h *HitbtcApi) Board(trading string, settlement string) (board *models.Boar
 		if err != nil {
 			return nil, errors.Wrapf(err, "failed to parse price")
 		}
-		price, err := strconv.ParseFloat(priceStr, 10)
+		price, err := decimal.NewFromString(priceStr)
 		if err != nil {
 			return nil, errors.Wrapf(err, "failed to parse price")
 		}
@@ -547,7 +607,7 @@ func (h *HitbtcApi) Board(trading string, settlement string) (board *models.Boar
 		if err != nil {
 			return nil, errors.Wrapf(err, "failed to parse size")
 		}
-		size, err := strconv.ParseFloat(sizeStr, 10)
+		size, err := decimal.NewFromString(sizeStr)
 		if err != nil {
 			return nil, errors.Wrapf(err, "failed to parse size")
 		}
@@ -564,3 +624,71 @@ func (h *HitbtcApi) Board(trading string, settlement string) (board *models.Boar
 	h.boardCache.Set(trading+"_"+settlement, board, cache.DefaultExpiration)
 	return board, nil
 }
+
+var hitbtcIntervals = map[models.Interval]string{
+	models.Interval1m:  "M1",
+	models.Interval3m:  "M3",
+	models.Interval5m:  "M5",
+	models.Interval15m: "M15",
+	models.Interval30m: "M30",
+	models.Interval1h:  "H1",
+	models.Interval4h:  "H4",
+	models.Interval1d:  "D1",
+	models.Interval1w:  "D7",
+}
+
+func (h *HitbtcApi) Candles(trading string, settlement string, interval models.Interval, since time.Time, limit int) ([]models.Candle, error) {
+	period, ok := hitbtcIntervals[interval]
+	if !ok {
+		return nil, unsupportedInterval("hitbtc", interval)
+	}
+	return pageCandles(interval, since, limit, 0, func(start time.Time) ([]models.Candle, error) {
+		url := h.publicApiUrl("candles/" + hitbtcSymbols.Symbol(trading, settlement) + "?sort=ASC&limit=1000&period=" + period +
+			"&from=" + start.UTC().Format(time.RFC3339))
+		byteArray, err := getBody(h.context(), h.HttpClient, url)
+		if err != nil {
+			return nil, err
+		}
+		var candles []models.Candle
+		for _, v := range gjson.ParseBytes(byteArray).Array() {
+			t, err := time.Parse(time.RFC3339Nano, v.Get("timestamp").Str)
+			if err != nil {
+				return nil, errors.Wrapf(err, "failed to parse candle time %s", v.Get("timestamp").Str)
+			}
+			candles = append(candles, models.Candle{
+				Time:   t,
+				Open:   helpers.ToDecimal(v.Get("open")),
+				High:   helpers.ToDecimal(v.Get("max")),
+				Low:    helpers.ToDecimal(v.Get("min")),
+				Close:  helpers.ToDecimal(v.Get("close")),
+				Volume: helpers.ToDecimal(v.Get("volume")),
+			})
+		}
+		return candles, nil
+	})
+}
+
+func (h *HitbtcApi) RecentTrades(trading string, settlement string, limit int) ([]models.PublicTrade, error) {
+	limit = tradesLimit(limit, 1000)
+	url := h.publicApiUrl("trades/" + hitbtcSymbols.Symbol(trading, settlement) +
+		"?sort=DESC&limit=" + strconv.Itoa(limit))
+	byteArray, err := getBody(h.context(), h.HttpClient, url)
+	if err != nil {
+		return nil, err
+	}
+	var trades []models.PublicTrade
+	for _, v := range gjson.ParseBytes(byteArray).Array() {
+		t, err := time.Parse(time.RFC3339Nano, v.Get("timestamp").Str)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to parse trade time %s", v.Get("timestamp").Str)
+		}
+		trades = append(trades, models.PublicTrade{
+			ID:     strconv.FormatInt(v.Get("id").Int(), 10),
+			Type:   takerSide(v.Get("side").Str),
+			Price:  helpers.ToDecimal(v.Get("price")),
+			Amount: helpers.ToDecimal(v.Get("quantity")),
+			Time:   t,
+		})
+	}
+	return latestTrades(trades, limit), nil
+}
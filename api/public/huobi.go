@@ -1,6 +1,7 @@
 package public
 
 import (
+	"context"
 	"net/http"
 	"sync"
 	"time"
@@ -8,20 +9,24 @@ import (
 	"fmt"
 	"github.com/antonholmquist/jason"
 	"github.com/fxpgr/go-exchange-client/api/unified"
+	"github.com/fxpgr/go-exchange-client/apierrors"
+	"github.com/fxpgr/go-exchange-client/helpers"
 	"github.com/fxpgr/go-exchange-client/models"
+	"github.com/fxpgr/go-exchange-client/symbols"
 	"github.com/patrickmn/go-cache"
 	"github.com/pkg/errors"
 	"github.com/tidwall/gjson"
 	"io/ioutil"
 	url2 "net/url"
 	"strconv"
-	"strings"
 )
 
 const (
 	HUOBI_BASE_URL = "https://api.huobi.pro"
 )
 
+var huobiSymbols = symbols.For("huobi")
+
 func NewHuobiPublicApi() (*HuobiApi, error) {
 	shrimpyApi, err := unified.NewShrimpyApi()
 	if err != nil {
@@ -35,9 +40,9 @@ func NewHuobiPublicApi() (*HuobiApi, error) {
 		orderBookTickMap:  nil,
 		rateLastUpdated:   time.Date(1970, 1, 1, 0, 0, 0, 0, time.UTC),
 		boardCache:        cache.New(3*time.Second, 1*time.Second),
-		HttpClient:        &http.Client{Timeout: time.Duration(10) * time.Second},
+		HttpClient:        &http.Client{Timeout: time.Duration(10) * time.Second, Transport: newTransport("huobi", nil)},
 		ShrimpyClient:     shrimpyApi,
-		rt:                &http.Transport{},
+		rt:                newTransport("huobi", &http.Transport{}),
 
 		m:         new(sync.Mutex),
 		rateM:     new(sync.Mutex),
@@ -55,10 +60,12 @@ type HuobiApi struct {
 	rateMap           map[string]map[string]float64
 	orderBookTickMap  map[string]map[string]models.OrderBookTick
 	precisionMap      map[string]map[string]models.Precisions
+	rulesMap          map[string]map[string]models.MarketRules
 	currencyPairs     []models.CurrencyPair
 	boardCache        *cache.Cache
 
-	HttpClient    *http.Client
+	HttpClient *http.Client
+	clientContext
 	ShrimpyClient *unified.ShrimpyApiClient
 
 	rt http.RoundTripper
@@ -71,10 +78,16 @@ type HuobiApi struct {
 }
 
 func (h *HuobiApi) SetTransport(transport http.RoundTripper) error {
-	h.HttpClient.Transport = transport
+	h.HttpClient.Transport = newTransport("huobi", transport)
 	return nil
 }
 
+func (h *HuobiApi) WithContext(ctx context.Context) PublicClient {
+	c := *h
+	c.ctx = ctx
+	return &c
+}
+
 func (h *HuobiApi) publicApiUrl(command string) string {
 	return h.BaseURL + command
 }
@@ -82,7 +95,7 @@ func (h *HuobiApi) publicApiUrl(command string) string {
 func (h *HuobiApi) fetchSettlements() error {
 	settlements := make([]string, 0)
 	url := h.publicApiUrl("/v1/common/symbols")
-	resp, err := h.HttpClient.Get(url)
+	resp, err := httpGet(h.context(), h.HttpClient, url)
 	if err != nil {
 		return errors.Wrapf(err, "failed to fetch %s", url)
 	}
@@ -130,22 +143,16 @@ func (h *HuobiApi) fetchPrecision() error {
 	if h.precisionMap != nil {
 		return nil
 	}
-	h.precisionMap = make(map[string]map[string]models.Precisions)
-
 	url := h.publicApiUrl("/v1/common/symbols")
-	resp, err := h.HttpClient.Get(url)
+	byteArray, err := getBody(h.context(), h.HttpClient, url)
 	if err != nil {
-		return errors.Wrapf(err, "failed to fetch %s", url)
-	}
-	defer resp.Body.Close()
-
-	byteArray, err := ioutil.ReadAll(resp.Body)
-	if err != nil {
-		return errors.Wrapf(err, "failed to fetch %s", url)
+		return err
 	}
 
-	value := gjson.Parse(string(byteArray))
+	value := gjson.ParseBytes(byteArray)
 
+	precisionMap := make(map[string]map[string]models.Precisions)
+	rulesMap := make(map[string]map[string]models.MarketRules)
 	for _, v := range value.Get("data").Array() {
 		pricePrecision, err := strconv.Atoi(v.Get("price-precision").Raw)
 		if err != nil {
@@ -157,19 +164,31 @@ func (h *HuobiApi) fetchPrecision() error {
 			fmt.Println(err)
 			continue
 		}
-		trading := strings.ToUpper(v.Get("base-currency").Str)
-		settlement := strings.ToUpper(v.Get("quote-currency").Str)
+		pair := huobiSymbols.AddPair(v.Get("base-currency").Str, v.Get("quote-currency").Str)
+		trading, settlement := pair.Trading, pair.Settlement
 
-		m, ok := h.precisionMap[trading]
+		m, ok := precisionMap[trading]
 		if !ok {
 			m = make(map[string]models.Precisions)
-			h.precisionMap[trading] = m
+			precisionMap[trading] = m
 		}
 		m[settlement] = models.Precisions{
 			PricePrecision:  pricePrecision,
 			AmountPrecision: amountPrecision,
 		}
+		rules := models.NewMarketRules(m[settlement])
+		rules.MinAmount = helpers.ToDecimal(v.Get("min-order-amt"))
+		rules.MaxAmount = helpers.ToDecimal(v.Get("max-order-amt"))
+		rules.MinNotional = helpers.ToDecimal(v.Get("min-order-value"))
+		r, ok := rulesMap[trading]
+		if !ok {
+			r = make(map[string]models.MarketRules)
+			rulesMap[trading] = r
+		}
+		r[settlement] = rules
 	}
+	h.precisionMap = precisionMap
+	h.rulesMap = rulesMap
 	return nil
 }
 
@@ -190,9 +209,9 @@ func (h *HuobiApi) fetchRate() error {
 		workers <- 1
 		go func(trading string, settlement string) {
 			defer wg.Done()
-			url := h.publicApiUrl("/market/detail/merged?symbol=" + strings.ToLower(trading) + strings.ToLower(settlement))
+			url := h.publicApiUrl("/market/detail/merged?symbol=" + huobiSymbols.Symbol(trading, settlement))
 			cli := &http.Client{Transport: h.rt}
-			resp, err := cli.Get(url)
+			resp, err := httpGet(h.context(), cli, url)
 			if err != nil {
 				ch <- &HuobiTickResponse{nil, trading, settlement, err}
 				<-workers
@@ -242,11 +261,11 @@ func (h *HuobiApi) fetchRate() error {
 			rateMap[r.Trading] = m
 		}
 		m[r.Settlement] = close
-		ask, err := tick.GetFloat64Array("ask")
+		ask, err := tick.GetValueArray("ask")
 		if err != nil || len(ask) == 0 {
 			continue
 		}
-		bid, err := tick.GetFloat64Array("bid")
+		bid, err := tick.GetValueArray("bid")
 		if err != nil || len(bid) == 0 {
 			continue
 		}
@@ -256,8 +275,8 @@ func (h *HuobiApi) fetchRate() error {
 			orderBookTickMap[r.Trading] = l
 		}
 		l[r.Settlement] = models.OrderBookTick{
-			BestAskPrice: ask[0],
-			BestBidPrice: bid[0],
+			BestAskPrice: helpers.ToDecimal(ask[0].Interface()),
+			BestBidPrice: helpers.ToDecimal(bid[0].Interface()),
 		}
 		h.rateM.Unlock()
 	}
@@ -268,13 +287,15 @@ func (h *HuobiApi) fetchRate() error {
 }
 
 func (h *HuobiApi) fetchOrderBookTick() error {
-	boardMap, err := h.ShrimpyClient.GetBoards("huobi")
+	boardMap, err := h.ShrimpyClient.WithContext(h.context()).GetBoards("huobi")
 	if err != nil {
 		return err
 	}
 	orderBookTickMap := make(map[string]map[string]models.OrderBookTick)
 	for settlement, m := range boardMap {
+		settlement = huobiSymbols.Currency(settlement)
 		for trading, value := range m {
+			trading = huobiSymbols.Currency(trading)
 			l, ok := orderBookTickMap[trading]
 			if !ok {
 				l = make(map[string]models.OrderBookTick)
@@ -341,7 +362,7 @@ func (h *HuobiApi) CurrencyPairs() ([]models.CurrencyPair, error) {
 		return h.currencyPairs, nil
 	}
 	url := h.publicApiUrl("/v1/common/symbols")
-	resp, err := h.HttpClient.Get(url)
+	resp, err := httpGet(h.context(), h.HttpClient, url)
 	if err != nil {
 		return nil, errors.Wrapf(err, "failed to fetch %s", url)
 	}
@@ -369,10 +390,7 @@ func (h *HuobiApi) CurrencyPairs() ([]models.CurrencyPair, error) {
 		if err != nil {
 			return nil, errors.Wrapf(err, "failed to parse base")
 		}
-		pair := models.CurrencyPair{
-			Trading:    strings.ToUpper(trading),
-			Settlement: strings.ToUpper(settlement),
-		}
+		pair := huobiSymbols.AddPair(trading, settlement)
 		pairs = append(pairs, pair)
 	}
 	h.currencyPairs = pairs
@@ -392,9 +410,9 @@ func (h *HuobiApi) Volume(trading string, settlement string) (float64, error) {
 		h.rateLastUpdated = now
 	}
 	if m, ok := h.volumeMap[trading]; !ok {
-		return 0, errors.Errorf("%s/%s", trading, settlement)
+		return 0, errors.Wrapf(apierrors.ErrInvalidSymbol, "%s/%s", trading, settlement)
 	} else if volume, ok := m[settlement]; !ok {
-		return 0, errors.Errorf("%s/%s", trading, settlement)
+		return 0, errors.Wrapf(apierrors.ErrInvalidSymbol, "%s/%s", trading, settlement)
 	} else {
 		return volume, nil
 	}
@@ -417,9 +435,9 @@ func (h *HuobiApi) Rate(trading string, settlement string) (float64, error) {
 		h.rateLastUpdated = now
 	}
 	if m, ok := h.rateMap[trading]; !ok {
-		return 0, errors.Errorf("%s/%s", trading, settlement)
+		return 0, errors.Wrapf(apierrors.ErrInvalidSymbol, "%s/%s", trading, settlement)
 	} else if rate, ok := m[settlement]; !ok {
-		return 0, errors.Errorf("%s/%s", trading, settlement)
+		return 0, errors.Wrapf(apierrors.ErrInvalidSymbol, "%s/%s", trading, settlement)
 	} else {
 		return rate, nil
 	}
@@ -430,25 +448,42 @@ func (h *HuobiApi) Precise(trading string, settlement string) (*models.Precision
 		return &models.Precisions{}, nil
 	}
 
+	h.m.Lock()
+	defer h.m.Unlock()
 	err := h.fetchPrecision()
 	if err != nil {
 		return &models.Precisions{}, err
 	}
 	if m, ok := h.precisionMap[trading]; !ok {
-		return &models.Precisions{}, errors.Errorf("%s/%s", trading, settlement)
+		return &models.Precisions{}, errors.Wrapf(apierrors.ErrInvalidSymbol, "%s/%s", trading, settlement)
 	} else if precisions, ok := m[settlement]; !ok {
-		return &models.Precisions{}, errors.Errorf("%s/%s", trading, settlement)
+		return &models.Precisions{}, errors.Wrapf(apierrors.ErrInvalidSymbol, "%s/%s", trading, settlement)
 	} else {
 		return &precisions, nil
 	}
 }
 
+func (h *HuobiApi) Rules(trading string, settlement string) (*models.MarketRules, error) {
+	h.m.Lock()
+	defer h.m.Unlock()
+	if err := h.fetchPrecision(); err != nil {
+		return nil, err
+	}
+	if m, ok := h.rulesMap[trading]; !ok {
+		return nil, errors.Wrapf(apierrors.ErrInvalidSymbol, "%s/%s", trading, settlement)
+	} else if rules, ok := m[settlement]; !ok {
+		return nil, errors.Wrapf(apierrors.ErrInvalidSymbol, "%s/%s", trading, settlement)
+	} else {
+		return &rules, nil
+	}
+}
+
 func (h *HuobiApi) FrozenCurrency() ([]string, error) {
 	var frozens []string
 	args := url2.Values{}
 	args.Add("language", "en-US")
 	url := h.publicApiUrl("/v1/settings/currencys?") + args.Encode()
-	resp, err := h.HttpClient.Get(url)
+	resp, err := httpGet(h.context(), h.HttpClient, url)
 	if err != nil {
 		return nil, errors.Wrapf(err, "failed to fetch %s", url)
 	}
@@ -493,10 +528,10 @@ func (h *HuobiApi) Board(trading string, settlement string) (board *models.Board
 		return c.(*models.Board), nil
 	}
 	args := url2.Values{}
-	args.Add("symbol", strings.ToLower(trading)+strings.ToLower(settlement))
+	args.Add("symbol", huobiSymbols.Symbol(trading, settlement))
 	args.Add("type", "step0")
 	url := h.publicApiUrl("/market/depth?") + args.Encode()
-	resp, err := h.HttpClient.Get(url)
+	resp, err := httpGet(h.context(), h.HttpClient, url)
 	if err != nil {
 		return nil, errors.Wrapf(err, "failed to fetch %s", url)
 	}
@@ -529,17 +564,17 @@ func (h *HuobiApi) Board(trading string, settlement string) (board *models.Board
 		if err != nil {
 			return nil, errors.Wrapf(err, "failed to parse array")
 		}
-		price, err := s[0].Float64()
+		price, err := s[0].Number()
 		if err != nil {
 			return nil, errors.Wrapf(err, "failed to parse price")
 		}
-		amount, err := s[1].Float64()
+		amount, err := s[1].Number()
 		if err != nil {
 			return nil, errors.Wrapf(err, "failed to parse amount")
 		}
 		bids = append(bids, models.BoardBar{
-			Price:  price,
-			Amount: amount,
+			Price:  helpers.ToDecimal(price),
+			Amount: helpers.ToDecimal(amount),
 			Type:   models.Bid,
 		})
 	}
@@ -548,17 +583,17 @@ func (h *HuobiApi) Board(trading string, settlement string) (board *models.Board
 		if err != nil {
 			return nil, errors.Wrapf(err, "failed to parse array")
 		}
-		price, err := s[0].Float64()
+		price, err := s[0].Number()
 		if err != nil {
 			return nil, errors.Wrapf(err, "failed to parse price")
 		}
-		amount, err := s[1].Float64()
+		amount, err := s[1].Number()
 		if err != nil {
 			return nil, errors.Wrapf(err, "failed to parse amount")
 		}
 		asks = append(asks, models.BoardBar{
-			Price:  price,
-			Amount: amount,
+			Price:  helpers.ToDecimal(price),
+			Amount: helpers.ToDecimal(amount),
 			Type:   models.Ask,
 		})
 	}
@@ -569,3 +604,75 @@ func (h *HuobiApi) Board(trading string, settlement string) (board *models.Board
 	h.boardCache.Set(trading+"_"+settlement, board, cache.DefaultExpiration)
 	return board, nil
 }
+
+var huobiIntervals = map[models.Interval]string{
+	models.Interval1m:  "1min",
+	models.Interval5m:  "5min",
+	models.Interval15m: "15min",
+	models.Interval30m: "30min",
+	models.Interval1h:  "60min",
+	models.Interval4h:  "4hour",
+	models.Interval1d:  "1day",
+	models.Interval1w:  "1week",
+}
+
+// Candles can only reach back 2000 candles, as huobi serves the latest ones
+// only.
+func (h *HuobiApi) Candles(trading string, settlement string, interval models.Interval, since time.Time, limit int) ([]models.Candle, error) {
+	period, ok := huobiIntervals[interval]
+	if !ok {
+		return nil, unsupportedInterval("huobi", interval)
+	}
+	return pageCandles(interval, since, limit, 0, func(start time.Time) ([]models.Candle, error) {
+		url := h.publicApiUrl("/market/history/kline?size=2000&symbol=" + huobiSymbols.Symbol(trading, settlement) + "&period=" + period)
+		byteArray, err := getBody(h.context(), h.HttpClient, url)
+		if err != nil {
+			return nil, err
+		}
+		var candles []models.Candle
+		for _, v := range gjson.ParseBytes(byteArray).Get("data").Array() {
+			candles = append(candles, models.Candle{
+				Time:   time.Unix(v.Get("id").Int(), 0),
+				Open:   helpers.ToDecimal(v.Get("open")),
+				High:   helpers.ToDecimal(v.Get("high")),
+				Low:    helpers.ToDecimal(v.Get("low")),
+				Close:  helpers.ToDecimal(v.Get("close")),
+				Volume: helpers.ToDecimal(v.Get("amount")),
+			})
+		}
+		return candles, nil
+	})
+}
+
+// huobiTradeID returns the trade id of a trade under key, or else its id,
+// which does not fit an int64 and is kept as sent.
+func huobiTradeID(v gjson.Result, key string) string {
+	if id := v.Get(key); id.Exists() {
+		return id.Raw
+	}
+	return v.Get("id").Raw
+}
+
+func (h *HuobiApi) RecentTrades(trading string, settlement string, limit int) ([]models.PublicTrade, error) {
+	limit = tradesLimit(limit, 2000)
+	url := h.publicApiUrl("/market/history/trade?symbol=" + huobiSymbols.Symbol(trading, settlement) +
+		"&size=" + strconv.Itoa(limit))
+	byteArray, err := getBody(h.context(), h.HttpClient, url)
+	if err != nil {
+		return nil, err
+	}
+	var trades []models.PublicTrade
+	// trades are grouped by the taker order which filled them
+	for _, group := range gjson.ParseBytes(byteArray).Get("data").Array() {
+		for _, v := range group.Get("data").Array() {
+			trades = append(trades, models.PublicTrade{
+				ID:     huobiTradeID(v, "trade-id"),
+				Type:   takerSide(v.Get("direction").Str),
+				Price:  helpers.ToDecimal(v.Get("price")),
+				Amount: helpers.ToDecimal(v.Get("amount")),
+				Time:   millisToTime(v.Get("ts").Int()),
+			})
+		}
+	}
+	return latestTrades(trades, limit), nil
+}
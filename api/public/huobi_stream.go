@@ -0,0 +1,218 @@
+package public
+
+import (
+	"context"
+	"strconv"
+	"sync"
+
+	"github.com/fxpgr/go-exchange-client/helpers"
+	"github.com/fxpgr/go-exchange-client/logger"
+	"github.com/fxpgr/go-exchange-client/models"
+	"github.com/fxpgr/go-exchange-client/stream"
+	"github.com/pkg/errors"
+	"github.com/tidwall/gjson"
+)
+
+const (
+	HUOBI_STREAM_URL = "wss://api.huobi.pro/ws"
+)
+
+// HuobiStream subscribes to the market channels of Huobi. Huobi pushes the
+// full depth of a board every time, so a board is never out of sync for
+// longer than the connection is down.
+type HuobiStream struct {
+	Conn *stream.Conn
+
+	topics map[string]*huobiTopic
+	nextID int64
+	cancel context.CancelFunc
+	m      sync.Mutex
+}
+
+type huobiTopic struct {
+	book        *stream.Book
+	synced      bool
+	subscribers stream.Subscribers
+}
+
+func NewHuobiStream() *HuobiStream {
+	s := &HuobiStream{
+		topics: make(map[string]*huobiTopic),
+	}
+	s.Conn = stream.NewConn(HUOBI_STREAM_URL, s.handle)
+	s.Conn.Decode = stream.Gunzip
+	s.Conn.OnConnect = s.onConnect
+	return s
+}
+
+// SubscribeBoard returns a channel receiving the board of a pair whenever it
+// is pushed, until ctx is done. A receiver which falls behind only gets the
+// latest board.
+func (s *HuobiStream) SubscribeBoard(ctx context.Context, trading string, settlement string) (<-chan *models.Board, error) {
+	var ch chan *models.Board
+	err := s.subscribe(ctx, trading, settlement, "depth.step0", func(t *huobiTopic) interface{} {
+		ch = t.subscribers.AddBoard()
+		if t.synced {
+			ch <- t.book.Board()
+		}
+		return ch
+	})
+	return ch, err
+}
+
+// SubscribeTicks returns a channel receiving the best bid and offer of a pair
+// whenever they change, until ctx is done. A receiver which falls behind only
+// gets the latest tick.
+func (s *HuobiStream) SubscribeTicks(ctx context.Context, trading string, settlement string) (<-chan models.OrderBookTick, error) {
+	var ch chan models.OrderBookTick
+	err := s.subscribe(ctx, trading, settlement, "bbo", func(t *huobiTopic) interface{} {
+		ch = t.subscribers.AddTicks()
+		return ch
+	})
+	return ch, err
+}
+
+// SubscribeTrades returns a channel receiving the trades of a pair as they
+// happen, until ctx is done.
+func (s *HuobiStream) SubscribeTrades(ctx context.Context, trading string, settlement string) (<-chan models.PublicTrade, error) {
+	var ch chan models.PublicTrade
+	err := s.subscribe(ctx, trading, settlement, "trade.detail", func(t *huobiTopic) interface{} {
+		ch = t.subscribers.AddTrades()
+		return ch
+	})
+	return ch, err
+}
+
+func (s *HuobiStream) subscribe(ctx context.Context, trading string, settlement string, channel string, add func(t *huobiTopic) interface{}) error {
+	if trading == settlement {
+		return errors.Errorf("trading and settlment are same")
+	}
+	name := "market." + huobiSymbols.Symbol(trading, settlement) + "." + channel
+
+	s.m.Lock()
+	t, ok := s.topics[name]
+	if !ok {
+		t = &huobiTopic{book: stream.NewBook()}
+		s.topics[name] = t
+		s.send("sub", name)
+	}
+	ch := add(t)
+	if s.cancel == nil {
+		var connCtx context.Context
+		connCtx, s.cancel = context.WithCancel(context.Background())
+		go s.Conn.Run(connCtx)
+	}
+	s.m.Unlock()
+
+	go func() {
+		<-ctx.Done()
+		s.unsubscribe(name, ch)
+	}()
+	return nil
+}
+
+func (s *HuobiStream) unsubscribe(name string, ch interface{}) {
+	s.m.Lock()
+	defer s.m.Unlock()
+	t, ok := s.topics[name]
+	if !ok {
+		return
+	}
+	t.subscribers.Remove(ch)
+	if t.subscribers.Len() == 0 {
+		delete(s.topics, name)
+		s.send("unsub", name)
+	}
+}
+
+// Close disconnects the stream and closes the channels of every
+// subscription.
+func (s *HuobiStream) Close() {
+	s.m.Lock()
+	defer s.m.Unlock()
+	if s.cancel != nil {
+		s.cancel()
+		s.cancel = nil
+	}
+	for name, t := range s.topics {
+		t.subscribers.Close()
+		delete(s.topics, name)
+	}
+}
+
+// send subscribes to or unsubscribes from the topic name. Topics subscribed
+// to while the connection is down are subscribed to by onConnect.
+func (s *HuobiStream) send(op string, name string) error {
+	s.nextID++
+	err := s.Conn.WriteJSON(map[string]string{op: name, "id": strconv.FormatInt(s.nextID, 10)})
+	if err != nil && err != stream.ErrNotConnected {
+		return errors.Wrapf(err, "failed to %s %s", op, name)
+	}
+	return nil
+}
+
+func (s *HuobiStream) onConnect(*stream.Conn) error {
+	s.m.Lock()
+	defer s.m.Unlock()
+	for name, t := range s.topics {
+		t.synced = false
+		t.book.Reset()
+		if err := s.send("sub", name); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *HuobiStream) handle(msg []byte) error {
+	value := gjson.ParseBytes(msg)
+	if ping := value.Get("ping"); ping.Exists() {
+		return s.Conn.WriteJSON(map[string]int64{"pong": ping.Int()})
+	}
+	if value.Get("status").Str == "error" {
+		logger.Get().Warnw("huobi stream request failed", "error", value.Get("err-msg").Str)
+		return nil
+	}
+	name := value.Get("ch").Str
+	if name == "" {
+		return nil
+	}
+	tick := value.Get("tick")
+
+	s.m.Lock()
+	defer s.m.Unlock()
+	t, ok := s.topics[name]
+	if !ok {
+		return nil
+	}
+	switch {
+	case tick.Get("bids").Exists():
+		t.book.Reset()
+		for _, v := range tick.Get("bids").Array() {
+			t.book.Set(models.Bid, helpers.ToDecimal(v.Get("0")), helpers.ToDecimal(v.Get("1")))
+		}
+		for _, v := range tick.Get("asks").Array() {
+			t.book.Set(models.Ask, helpers.ToDecimal(v.Get("0")), helpers.ToDecimal(v.Get("1")))
+		}
+		t.synced = true
+		t.subscribers.PublishBoard(t.book)
+	case tick.Get("bid").Exists():
+		t.subscribers.PublishTick(models.OrderBookTick{
+			BestAskPrice:  helpers.ToDecimal(tick.Get("ask")),
+			BestAskAmount: helpers.ToDecimal(tick.Get("askSize")),
+			BestBidPrice:  helpers.ToDecimal(tick.Get("bid")),
+			BestBidAmount: helpers.ToDecimal(tick.Get("bidSize")),
+		})
+	case tick.Get("data").Exists():
+		for _, v := range tick.Get("data").Array() {
+			t.subscribers.PublishTrade(models.PublicTrade{
+				ID:     huobiTradeID(v, "tradeId"),
+				Type:   takerSide(v.Get("direction").Str),
+				Price:  helpers.ToDecimal(v.Get("price")),
+				Amount: helpers.ToDecimal(v.Get("amount")),
+				Time:   millisToTime(v.Get("ts").Int()),
+			})
+		}
+	}
+	return nil
+}
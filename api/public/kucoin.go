@@ -1,17 +1,21 @@
 package public
 
 import (
+	"context"
 	"net/http"
 	"sync"
 	"time"
 
 	"io/ioutil"
 	url2 "net/url"
-	"strings"
+	"strconv"
 
 	"github.com/antonholmquist/jason"
 	"github.com/fxpgr/go-exchange-client/api/unified"
+	"github.com/fxpgr/go-exchange-client/apierrors"
+	"github.com/fxpgr/go-exchange-client/helpers"
 	"github.com/fxpgr/go-exchange-client/models"
+	"github.com/fxpgr/go-exchange-client/symbols"
 	cache "github.com/patrickmn/go-cache"
 	"github.com/pkg/errors"
 	"github.com/tidwall/gjson"
@@ -21,6 +25,8 @@ const (
 	KUCOIN_BASE_URL = "https://api.kucoin.com"
 )
 
+var kucoinSymbols = symbols.For("kucoin")
+
 func NewKucoinPublicApi() (*KucoinApi, error) {
 	shrimpyApi, err := unified.NewShrimpyApi()
 	if err != nil {
@@ -34,7 +40,7 @@ func NewKucoinPublicApi() (*KucoinApi, error) {
 		orderBookTickMap:  nil,
 		rateLastUpdated:   time.Date(1970, 1, 1, 0, 0, 0, 0, time.UTC),
 		boardCache:        cache.New(3*time.Second, 1*time.Second),
-		HttpClient:        &http.Client{Timeout: time.Duration(10) * time.Second},
+		HttpClient:        &http.Client{Timeout: time.Duration(10) * time.Second, Transport: newTransport("kucoin", nil)},
 		ShrimpyClient:     shrimpyApi,
 		rt:                &http.Transport{},
 
@@ -54,12 +60,14 @@ type KucoinApi struct {
 	rateMap           map[string]map[string]float64
 	orderBookTickMap  map[string]map[string]models.OrderBookTick
 	precisionMap      map[string]map[string]models.Precisions
+	rulesMap          map[string]map[string]models.MarketRules
 	boardCache        *cache.Cache
 	currencyPairs     []models.CurrencyPair
 	ShrimpyClient     *unified.ShrimpyApiClient
 
 	HttpClient *http.Client
-	rt         http.RoundTripper
+	clientContext
+	rt http.RoundTripper
 
 	settlements []string
 
@@ -69,10 +77,16 @@ type KucoinApi struct {
 }
 
 func (h *KucoinApi) SetTransport(transport http.RoundTripper) error {
-	h.HttpClient.Transport = transport
+	h.HttpClient.Transport = newTransport("kucoin", transport)
 	return nil
 }
 
+func (h *KucoinApi) WithContext(ctx context.Context) PublicClient {
+	c := *h
+	c.ctx = ctx
+	return &c
+}
+
 func (h *KucoinApi) publicApiUrl(command string) string {
 	return h.BaseURL + command
 }
@@ -83,13 +97,15 @@ func (h *KucoinApi) fetchSettlements() error {
 }
 
 func (h *KucoinApi) fetchOrderBookTick() error {
-	boardMap, err := h.ShrimpyClient.GetBoards("kucoin")
+	boardMap, err := h.ShrimpyClient.WithContext(h.context()).GetBoards("kucoin")
 	if err != nil {
 		return err
 	}
 	orderBookTickMap := make(map[string]map[string]models.OrderBookTick)
 	for settlement, m := range boardMap {
+		settlement = kucoinSymbols.Currency(settlement)
 		for trading, value := range m {
+			trading = kucoinSymbols.Currency(trading)
 			l, ok := orderBookTickMap[trading]
 			if !ok {
 				l = make(map[string]models.OrderBookTick)
@@ -128,8 +144,8 @@ type KucoinTickResponse struct {
 	err        error
 }
 
-func requestGetAsChrome(url string) (*http.Request, error) {
-	req, err := http.NewRequest("GET", url, nil)
+func requestGetAsChrome(ctx context.Context, url string) (*http.Request, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
 	if err != nil {
 		return req, err
 	}
@@ -143,7 +159,7 @@ func (h *KucoinApi) fetchPrecision() error {
 	}
 	coinPrecision := make(map[string]int)
 	url := h.publicApiUrl("/api/v1/currencies")
-	req, err := requestGetAsChrome(url)
+	req, err := requestGetAsChrome(h.context(), url)
 	if err != nil {
 		return errors.Wrapf(err, "failed to fetch %s", url)
 	}
@@ -151,6 +167,9 @@ func (h *KucoinApi) fetchPrecision() error {
 	if err != nil {
 		return errors.Wrapf(err, "failed to fetch %s", url)
 	}
+	if err := statusError(resp); err != nil {
+		return errors.Wrapf(err, "failed to fetch %s", url)
+	}
 	defer resp.Body.Close()
 	byteArray, err := ioutil.ReadAll(resp.Body)
 	if err != nil {
@@ -164,7 +183,7 @@ func (h *KucoinApi) fetchPrecision() error {
 	h.precisionMap = make(map[string]map[string]models.Precisions)
 
 	url = h.publicApiUrl("/api/v1/market/allTickers")
-	req, err = requestGetAsChrome(url)
+	req, err = requestGetAsChrome(h.context(), url)
 	if err != nil {
 		return errors.Wrapf(err, "failed to fetch %s", url)
 	}
@@ -172,6 +191,9 @@ func (h *KucoinApi) fetchPrecision() error {
 	if err != nil {
 		return errors.Wrapf(err, "failed to fetch %s", url)
 	}
+	if err := statusError(resp); err != nil {
+		return errors.Wrapf(err, "failed to fetch %s", url)
+	}
 	defer resp.Body.Close()
 	byteArray, err = ioutil.ReadAll(resp.Body)
 	if err != nil {
@@ -180,12 +202,11 @@ func (h *KucoinApi) fetchPrecision() error {
 	value = gjson.ParseBytes(byteArray)
 	for _, v := range value.Get("data.ticker").Array() {
 
-		currencies := strings.Split(v.Get("symbol").Str, "-")
-		if len(currencies) < 2 {
+		pair, ok := kucoinSymbols.Pair(v.Get("symbol").Str)
+		if !ok {
 			continue
 		}
-		trading := currencies[0]
-		settlement := currencies[1]
+		trading, settlement := pair.Trading, pair.Settlement
 		buyPrecision := Precision(v.Get("buy").Str)
 		sellPrecision := Precision(v.Get("sell").Str)
 		highPrecision := Precision(v.Get("high").Str)
@@ -214,7 +235,7 @@ func (h *KucoinApi) fetchPrecision() error {
 
 func (h *KucoinApi) fetchRate() error {
 	url := h.publicApiUrl("/api/v1/market/allTickers")
-	req, err := requestGetAsChrome(url)
+	req, err := requestGetAsChrome(h.context(), url)
 	if err != nil {
 		return errors.Wrapf(err, "failed to fetch %s", url)
 	}
@@ -222,6 +243,9 @@ func (h *KucoinApi) fetchRate() error {
 	if err != nil {
 		return errors.Wrapf(err, "failed to fetch %s", url)
 	}
+	if err := statusError(resp); err != nil {
+		return errors.Wrapf(err, "failed to fetch %s", url)
+	}
 	defer resp.Body.Close()
 	byteArray, err := ioutil.ReadAll(resp.Body)
 	if err != nil {
@@ -232,17 +256,16 @@ func (h *KucoinApi) fetchRate() error {
 	volumeMap := make(map[string]map[string]float64)
 	orderBookTickMap := make(map[string]map[string]models.OrderBookTick)
 	for _, v := range value.Get("data.ticker").Array() {
-		currencies := strings.Split(v.Get("symbol").Str, "-")
-		if len(currencies) < 2 {
+		pair, ok := kucoinSymbols.Pair(v.Get("symbol").Str)
+		if !ok {
 			continue
 		}
-		trading := currencies[0]
-		settlement := currencies[1]
+		trading, settlement := pair.Trading, pair.Settlement
 
 		lastf := v.Get("last").Float()
 		volumef := v.Get("vol").Float()
-		bestbidPrice := v.Get("buy").Float()
-		bestaskPrice := v.Get("sell").Float()
+		bestbidPrice := helpers.ToDecimal(v.Get("buy"))
+		bestaskPrice := helpers.ToDecimal(v.Get("sell"))
 
 		h.rateM.Lock()
 		n, ok := volumeMap[trading]
@@ -295,14 +318,58 @@ func (h *KucoinApi) Precise(trading string, settlement string) (*models.Precisio
 
 	h.fetchPrecision()
 	if m, ok := h.precisionMap[trading]; !ok {
-		return &models.Precisions{}, errors.Errorf("%s/%s", trading, settlement)
+		return &models.Precisions{}, errors.Wrapf(apierrors.ErrInvalidSymbol, "%s/%s", trading, settlement)
 	} else if precisions, ok := m[settlement]; !ok {
-		return &models.Precisions{}, errors.Errorf("%s/%s", trading, settlement)
+		return &models.Precisions{}, errors.Wrapf(apierrors.ErrInvalidSymbol, "%s/%s", trading, settlement)
 	} else {
 		return &precisions, nil
 	}
 }
 
+func (h *KucoinApi) fetchRules() error {
+	if h.rulesMap != nil {
+		return nil
+	}
+	url := h.publicApiUrl("/api/v1/symbols")
+	byteArray, err := getBody(h.context(), h.HttpClient, url)
+	if err != nil {
+		return err
+	}
+	rulesMap := make(map[string]map[string]models.MarketRules)
+	for _, v := range gjson.ParseBytes(byteArray).Get("data").Array() {
+		pair := kucoinSymbols.AddPair(v.Get("baseCurrency").Str, v.Get("quoteCurrency").Str)
+		r, ok := rulesMap[pair.Trading]
+		if !ok {
+			r = make(map[string]models.MarketRules)
+			rulesMap[pair.Trading] = r
+		}
+		r[pair.Settlement] = models.MarketRules{
+			TickSize:    helpers.ToDecimal(v.Get("priceIncrement")),
+			StepSize:    helpers.ToDecimal(v.Get("baseIncrement")),
+			MinAmount:   helpers.ToDecimal(v.Get("baseMinSize")),
+			MaxAmount:   helpers.ToDecimal(v.Get("baseMaxSize")),
+			MinNotional: helpers.ToDecimal(v.Get("quoteMinSize")),
+		}
+	}
+	h.rulesMap = rulesMap
+	return nil
+}
+
+func (h *KucoinApi) Rules(trading string, settlement string) (*models.MarketRules, error) {
+	h.m.Lock()
+	defer h.m.Unlock()
+	if err := h.fetchRules(); err != nil {
+		return nil, err
+	}
+	if m, ok := h.rulesMap[trading]; !ok {
+		return nil, errors.Wrapf(apierrors.ErrInvalidSymbol, "%s/%s", trading, settlement)
+	} else if rules, ok := m[settlement]; !ok {
+		return nil, errors.Wrapf(apierrors.ErrInvalidSymbol, "%s/%s", trading, settlement)
+	} else {
+		return &rules, nil
+	}
+}
+
 func (h *KucoinApi) VolumeMap() (map[string]map[string]float64, error) {
 	h.m.Lock()
 	defer h.m.Unlock()
@@ -326,7 +393,7 @@ func (h *KucoinApi) CurrencyPairs() ([]models.CurrencyPair, error) {
 	h.fetchSettlements()
 	currecyPairs := make([]models.CurrencyPair, 0)
 	url := h.publicApiUrl("/api/v1/symbols")
-	req, err := requestGetAsChrome(url)
+	req, err := requestGetAsChrome(h.context(), url)
 	if err != nil {
 		return nil, errors.Wrapf(err, "failed to fetch %s", url)
 	}
@@ -334,6 +401,9 @@ func (h *KucoinApi) CurrencyPairs() ([]models.CurrencyPair, error) {
 	if err != nil {
 		return nil, errors.Wrapf(err, "failed to fetch %s", url)
 	}
+	if err := statusError(resp); err != nil {
+		return nil, errors.Wrapf(err, "failed to fetch %s", url)
+	}
 	defer resp.Body.Close()
 
 	byteArray, err := ioutil.ReadAll(resp.Body)
@@ -358,10 +428,7 @@ func (h *KucoinApi) CurrencyPairs() ([]models.CurrencyPair, error) {
 		if err != nil {
 			continue
 		}
-		currecyPairs = append(currecyPairs, models.CurrencyPair{
-			Trading:    trading,
-			Settlement: settlement,
-		})
+		currecyPairs = append(currecyPairs, kucoinSymbols.AddPair(trading, settlement))
 	}
 	h.currencyPairs = currecyPairs
 	return currecyPairs, nil
@@ -380,9 +447,9 @@ func (h *KucoinApi) Volume(trading string, settlement string) (float64, error) {
 		h.rateLastUpdated = now
 	}
 	if m, ok := h.volumeMap[trading]; !ok {
-		return 0, errors.Errorf("%s/%s", trading, settlement)
+		return 0, errors.Wrapf(apierrors.ErrInvalidSymbol, "%s/%s", trading, settlement)
 	} else if volume, ok := m[settlement]; !ok {
-		return 0, errors.Errorf("%s/%s", trading, settlement)
+		return 0, errors.Wrapf(apierrors.ErrInvalidSymbol, "%s/%s", trading, settlement)
 	} else {
 		return volume, nil
 	}
@@ -405,9 +472,9 @@ func (h *KucoinApi) Rate(trading string, settlement string) (float64, error) {
 		h.rateLastUpdated = now
 	}
 	if m, ok := h.rateMap[trading]; !ok {
-		return 0, errors.Errorf("%s/%s", trading, settlement)
+		return 0, errors.Wrapf(apierrors.ErrInvalidSymbol, "%s/%s", trading, settlement)
 	} else if rate, ok := m[settlement]; !ok {
-		return 0, errors.Errorf("%s/%s", trading, settlement)
+		return 0, errors.Wrapf(apierrors.ErrInvalidSymbol, "%s/%s", trading, settlement)
 	} else {
 		return rate, nil
 	}
@@ -415,7 +482,7 @@ func (h *KucoinApi) Rate(trading string, settlement string) (float64, error) {
 
 func (h *KucoinApi) FrozenCurrency() ([]string, error) {
 	url := h.publicApiUrl("/api/v1/currencies")
-	req, err := requestGetAsChrome(url)
+	req, err := requestGetAsChrome(h.context(), url)
 	if err != nil {
 		return []string{}, errors.Wrapf(err, "failed to fetch %s", url)
 	}
@@ -423,6 +490,9 @@ func (h *KucoinApi) FrozenCurrency() ([]string, error) {
 	if err != nil {
 		return []string{}, errors.Wrapf(err, "failed to fetch %s", url)
 	}
+	if err := statusError(resp); err != nil {
+		return []string{}, errors.Wrapf(err, "failed to fetch %s", url)
+	}
 	defer resp.Body.Close()
 
 	byteArray, err := ioutil.ReadAll(resp.Body)
@@ -464,9 +534,9 @@ func (h *KucoinApi) Board(trading string, settlement string) (board *models.Boar
 		return c.(*models.Board), nil
 	}
 	args := url2.Values{}
-	args.Add("symbol", strings.ToUpper(trading)+"-"+strings.ToUpper(settlement))
+	args.Add("symbol", kucoinSymbols.Symbol(trading, settlement))
 	url := h.publicApiUrl("/api/v2/market/orderbook/level2?") + args.Encode()
-	req, err := requestGetAsChrome(url)
+	req, err := requestGetAsChrome(h.context(), url)
 	if err != nil {
 		return nil, errors.Wrapf(err, "failed to fetch %s", url)
 	}
@@ -474,6 +544,9 @@ func (h *KucoinApi) Board(trading string, settlement string) (board *models.Boar
 	if err != nil {
 		return nil, errors.Wrapf(err, "failed to fetch %s", url)
 	}
+	if err := statusError(resp); err != nil {
+		return nil, errors.Wrapf(err, "failed to fetch %s", url)
+	}
 	defer resp.Body.Close()
 
 	byteArray, err := ioutil.ReadAll(resp.Body)
@@ -487,8 +560,8 @@ func (h *KucoinApi) Board(trading string, settlement string) (board *models.Boar
 	bids := make([]models.BoardBar, 0)
 	asks := make([]models.BoardBar, 0)
 	for _, v := range buys {
-		price := v.Array()[0].Float()
-		amount := v.Array()[1].Float()
+		price := helpers.ToDecimal(v.Array()[0])
+		amount := helpers.ToDecimal(v.Array()[1])
 		bids = append(bids, models.BoardBar{
 			Price:  price,
 			Amount: amount,
@@ -496,8 +569,8 @@ func (h *KucoinApi) Board(trading string, settlement string) (board *models.Boar
 		})
 	}
 	for _, v := range sells {
-		price := v.Array()[0].Float()
-		amount := v.Array()[1].Float()
+		price := helpers.ToDecimal(v.Array()[0])
+		amount := helpers.ToDecimal(v.Array()[1])
 		asks = append(asks, models.BoardBar{
 			Price:  price,
 			Amount: amount,
@@ -511,3 +584,74 @@ func (h *KucoinApi) Board(trading string, settlement string) (board *models.Boar
 	h.boardCache.Set(trading+"_"+settlement, board, cache.DefaultExpiration)
 	return board, nil
 }
+
+var kucoinIntervals = map[models.Interval]string{
+	models.Interval1m:  "1min",
+	models.Interval3m:  "3min",
+	models.Interval5m:  "5min",
+	models.Interval15m: "15min",
+	models.Interval30m: "30min",
+	models.Interval1h:  "1hour",
+	models.Interval2h:  "2hour",
+	models.Interval4h:  "4hour",
+	models.Interval6h:  "6hour",
+	models.Interval8h:  "8hour",
+	models.Interval12h: "12hour",
+	models.Interval1d:  "1day",
+	models.Interval1w:  "1week",
+}
+
+const kucoinCandlesPageSize = 1500
+
+func (h *KucoinApi) Candles(trading string, settlement string, interval models.Interval, since time.Time, limit int) ([]models.Candle, error) {
+	period, ok := kucoinIntervals[interval]
+	if !ok {
+		return nil, unsupportedInterval("kucoin", interval)
+	}
+	return pageCandles(interval, since, limit, kucoinCandlesPageSize, func(start time.Time) ([]models.Candle, error) {
+		end := start.Add(kucoinCandlesPageSize * interval.Duration())
+		url := h.publicApiUrl("/api/v1/market/candles?symbol=" + kucoinSymbols.Symbol(trading, settlement) + "&type=" + period +
+			"&startAt=" + strconv.FormatInt(start.Unix(), 10) + "&endAt=" + strconv.FormatInt(end.Unix(), 10))
+		byteArray, err := getBody(h.context(), h.HttpClient, url)
+		if err != nil {
+			return nil, err
+		}
+		var candles []models.Candle
+		for _, v := range gjson.ParseBytes(byteArray).Get("data").Array() {
+			a := v.Array()
+			if len(a) < 6 {
+				continue
+			}
+			candles = append(candles, models.Candle{
+				Time:   time.Unix(a[0].Int(), 0),
+				Open:   helpers.ToDecimal(a[1]),
+				Close:  helpers.ToDecimal(a[2]),
+				High:   helpers.ToDecimal(a[3]),
+				Low:    helpers.ToDecimal(a[4]),
+				Volume: helpers.ToDecimal(a[5]),
+			})
+		}
+		return candles, nil
+	})
+}
+
+func (h *KucoinApi) RecentTrades(trading string, settlement string, limit int) ([]models.PublicTrade, error) {
+	// the latest 100 trades are served regardless of any limit
+	limit = tradesLimit(limit, 100)
+	url := h.publicApiUrl("/api/v1/market/histories?symbol=" + kucoinSymbols.Symbol(trading, settlement))
+	byteArray, err := getBody(h.context(), h.HttpClient, url)
+	if err != nil {
+		return nil, err
+	}
+	var trades []models.PublicTrade
+	for _, v := range gjson.ParseBytes(byteArray).Get("data").Array() {
+		trades = append(trades, models.PublicTrade{
+			ID:     v.Get("sequence").String(),
+			Type:   takerSide(v.Get("side").Str),
+			Price:  helpers.ToDecimal(v.Get("price")),
+			Amount: helpers.ToDecimal(v.Get("size")),
+			Time:   time.Unix(0, v.Get("time").Int()),
+		})
+	}
+	return latestTrades(trades, limit), nil
+}
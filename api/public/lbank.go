@@ -1,24 +1,30 @@
 package public
 
 import (
+	"context"
 	"net/http"
 	"sync"
 	"time"
 
 	"github.com/antonholmquist/jason"
+	"github.com/fxpgr/go-exchange-client/apierrors"
+	"github.com/fxpgr/go-exchange-client/helpers"
 	"github.com/fxpgr/go-exchange-client/models"
+	"github.com/fxpgr/go-exchange-client/symbols"
 	"github.com/patrickmn/go-cache"
 	"github.com/pkg/errors"
 	"github.com/tidwall/gjson"
 	"io/ioutil"
 	url2 "net/url"
-	"strings"
+	"strconv"
 )
 
 const (
 	LBANK_BASE_URL = "https://api.lbkex.com"
 )
 
+var lbankSymbols = symbols.For("lbank")
+
 func NewLbankPublicApi() (*LbankApi, error) {
 	api := &LbankApi{
 		BaseURL:           LBANK_BASE_URL,
@@ -29,7 +35,7 @@ func NewLbankPublicApi() (*LbankApi, error) {
 		rateLastUpdated:   time.Date(1970, 1, 1, 0, 0, 0, 0, time.UTC),
 		boardCache:        cache.New(3*time.Second, 1*time.Second),
 
-		HttpClient: &http.Client{},
+		HttpClient: &http.Client{Transport: newTransport("lbank", nil)},
 		rt:         &http.Transport{},
 
 		m:         new(sync.Mutex),
@@ -48,11 +54,13 @@ type LbankApi struct {
 	rateMap           map[string]map[string]float64
 	orderBookTickMap  map[string]map[string]models.OrderBookTick
 	precisionMap      map[string]map[string]models.Precisions
+	rulesMap          map[string]map[string]models.MarketRules
 	currencyPairs     []models.CurrencyPair
 	boardCache        *cache.Cache
 
 	HttpClient *http.Client
-	rt         http.RoundTripper
+	clientContext
+	rt http.RoundTripper
 
 	settlements []string
 
@@ -62,10 +70,16 @@ type LbankApi struct {
 }
 
 func (h *LbankApi) SetTransport(transport http.RoundTripper) error {
-	h.HttpClient.Transport = transport
+	h.HttpClient.Transport = newTransport("lbank", transport)
 	return nil
 }
 
+func (h *LbankApi) WithContext(ctx context.Context) PublicClient {
+	c := *h
+	c.ctx = ctx
+	return &c
+}
+
 func (h *LbankApi) publicApiUrl(command string) string {
 	return h.BaseURL + command
 }
@@ -101,7 +115,7 @@ func (h *LbankApi) fetchPrecision() error {
 	h.precisionMap = make(map[string]map[string]models.Precisions)
 
 	url := h.publicApiUrl("/v1/ticker.do") + "?symbol=all"
-	resp, err := h.HttpClient.Get(url)
+	resp, err := httpGet(h.context(), h.HttpClient, url)
 	if err != nil {
 		return errors.Wrapf(err, "failed to fetch %s", url)
 	}
@@ -118,12 +132,11 @@ func (h *LbankApi) fetchPrecision() error {
 		last := v.Get("ticker.latest").Raw
 		volume := v.Get("ticker.vol").Raw
 
-		currencies := strings.Split(pairString, "_")
-		if len(currencies) != 2 {
+		pair, ok := lbankSymbols.Pair(pairString)
+		if !ok {
 			continue
 		}
-		trading := strings.ToUpper(currencies[0])
-		settlement := strings.ToUpper(currencies[1])
+		trading, settlement := pair.Trading, pair.Settlement
 
 		m, ok := h.precisionMap[trading]
 		if !ok {
@@ -142,7 +155,7 @@ func (h *LbankApi) fetchRate() error {
 	h.rateMap = make(map[string]map[string]float64)
 	h.volumeMap = make(map[string]map[string]float64)
 	url := h.publicApiUrl("/v1/ticker.do") + "?symbol=all"
-	resp, err := h.HttpClient.Get(url)
+	resp, err := httpGet(h.context(), h.HttpClient, url)
 	if err != nil {
 		return errors.Wrapf(err, "failed to fetch %s", url)
 	}
@@ -182,12 +195,11 @@ func (h *LbankApi) fetchRate() error {
 			return errors.Wrapf(err, "failed to parse vol")
 		}
 
-		currencies := strings.Split(pairString, "_")
-		if len(currencies) != 2 {
+		pair, ok := lbankSymbols.Pair(pairString)
+		if !ok {
 			continue
 		}
-		trading := strings.ToUpper(currencies[0])
-		settlement := strings.ToUpper(currencies[1])
+		trading, settlement := pair.Trading, pair.Settlement
 		m, ok := h.rateMap[trading]
 		if !ok {
 			m = make(map[string]float64)
@@ -244,14 +256,60 @@ func (h *LbankApi) Precise(trading string, settlement string) (*models.Precision
 
 	h.fetchPrecision()
 	if m, ok := h.precisionMap[trading]; !ok {
-		return &models.Precisions{}, errors.Errorf("%s/%s", trading, settlement)
+		return &models.Precisions{}, errors.Wrapf(apierrors.ErrInvalidSymbol, "%s/%s", trading, settlement)
 	} else if precisions, ok := m[settlement]; !ok {
-		return &models.Precisions{}, errors.Errorf("%s/%s", trading, settlement)
+		return &models.Precisions{}, errors.Wrapf(apierrors.ErrInvalidSymbol, "%s/%s", trading, settlement)
 	} else {
 		return &precisions, nil
 	}
 }
 
+func (h *LbankApi) fetchRules() error {
+	if h.rulesMap != nil {
+		return nil
+	}
+	url := h.publicApiUrl("/v2/accuracy.do")
+	byteArray, err := getBody(h.context(), h.HttpClient, url)
+	if err != nil {
+		return err
+	}
+	rulesMap := make(map[string]map[string]models.MarketRules)
+	for _, v := range gjson.ParseBytes(byteArray).Get("data").Array() {
+		pair, ok := lbankSymbols.Pair(v.Get("symbol").Str)
+		if !ok {
+			continue
+		}
+		r, ok := rulesMap[pair.Trading]
+		if !ok {
+			r = make(map[string]models.MarketRules)
+			rulesMap[pair.Trading] = r
+		}
+		rules := models.NewMarketRules(models.Precisions{
+			PricePrecision:  int(v.Get("priceAccuracy").Int()),
+			AmountPrecision: int(v.Get("quantityAccuracy").Int()),
+		})
+		rules.MinAmount = helpers.ToDecimal(v.Get("minTranQua"))
+		r[pair.Settlement] = rules
+	}
+	h.rulesMap = rulesMap
+	return nil
+}
+
+func (h *LbankApi) Rules(trading string, settlement string) (*models.MarketRules, error) {
+	h.m.Lock()
+	defer h.m.Unlock()
+	if err := h.fetchRules(); err != nil {
+		return nil, err
+	}
+	if m, ok := h.rulesMap[trading]; !ok {
+		return nil, errors.Wrapf(apierrors.ErrInvalidSymbol, "%s/%s", trading, settlement)
+	} else if rules, ok := m[settlement]; !ok {
+		return nil, errors.Wrapf(apierrors.ErrInvalidSymbol, "%s/%s", trading, settlement)
+	} else {
+		return &rules, nil
+	}
+}
+
 func (h *LbankApi) CurrencyPairs() ([]models.CurrencyPair, error) {
 	h.currencyM.Lock()
 	defer h.currencyM.Unlock()
@@ -259,7 +317,7 @@ func (h *LbankApi) CurrencyPairs() ([]models.CurrencyPair, error) {
 		return h.currencyPairs, nil
 	}
 	url := h.publicApiUrl("/v1/currencyPairs.do")
-	resp, err := h.HttpClient.Get(url)
+	resp, err := httpGet(h.context(), h.HttpClient, url)
 	if err != nil {
 		return nil, errors.Wrapf(err, "failed to fetch %s", url)
 	}
@@ -283,14 +341,10 @@ func (h *LbankApi) CurrencyPairs() ([]models.CurrencyPair, error) {
 		if err != nil {
 			return nil, errors.Wrapf(err, "failed to parse quote")
 		}
-		currencies := strings.Split(pairString, "_")
-		if len(currencies) != 2 {
+		pair, ok := lbankSymbols.Pair(pairString)
+		if !ok {
 			continue
 		}
-		pair := models.CurrencyPair{
-			Trading:    strings.ToUpper(currencies[0]),
-			Settlement: strings.ToUpper(currencies[1]),
-		}
 		pairs = append(pairs, pair)
 	}
 	h.currencyPairs = pairs
@@ -310,9 +364,9 @@ func (h *LbankApi) Volume(trading string, settlement string) (float64, error) {
 		h.rateLastUpdated = now
 	}
 	if m, ok := h.volumeMap[trading]; !ok {
-		return 0, errors.Errorf("%s/%s", trading, settlement)
+		return 0, errors.Wrapf(apierrors.ErrInvalidSymbol, "%s/%s", trading, settlement)
 	} else if volume, ok := m[settlement]; !ok {
-		return 0, errors.Errorf("%s/%s", trading, settlement)
+		return 0, errors.Wrapf(apierrors.ErrInvalidSymbol, "%s/%s", trading, settlement)
 	} else {
 		return volume, nil
 	}
@@ -335,9 +389,9 @@ func (h *LbankApi) Rate(trading string, settlement string) (float64, error) {
 		h.rateLastUpdated = now
 	}
 	if m, ok := h.rateMap[trading]; !ok {
-		return 0, errors.Errorf("%s/%s", trading, settlement)
+		return 0, errors.Wrapf(apierrors.ErrInvalidSymbol, "%s/%s", trading, settlement)
 	} else if rate, ok := m[settlement]; !ok {
-		return 0, errors.Errorf("%s/%s", trading, settlement)
+		return 0, errors.Wrapf(apierrors.ErrInvalidSymbol, "%s/%s", trading, settlement)
 	} else {
 		return rate, nil
 	}
@@ -345,7 +399,7 @@ func (h *LbankApi) Rate(trading string, settlement string) (float64, error) {
 
 func (h *LbankApi) FrozenCurrency() ([]string, error) {
 	url := h.publicApiUrl("/v1/withdrawConfigs.do")
-	resp, err := h.HttpClient.Get(url)
+	resp, err := httpGet(h.context(), h.HttpClient, url)
 	if err != nil {
 		return nil, errors.Wrapf(err, "failed to fetch %s", url)
 	}
@@ -390,11 +444,11 @@ func (h *LbankApi) Board(trading string, settlement string) (board *models.Board
 		return c.(*models.Board), nil
 	}
 	args := url2.Values{}
-	args.Add("symbol", strings.ToLower(trading)+"_"+strings.ToLower(settlement))
+	args.Add("symbol", lbankSymbols.Symbol(trading, settlement))
 	args.Add("size", "60")
 	method := "/v1/depth.do?" + args.Encode()
 	url := h.publicApiUrl(method)
-	resp, err := h.HttpClient.Get(url)
+	resp, err := httpGet(h.context(), h.HttpClient, url)
 	if err != nil {
 		return nil, errors.Wrapf(err, "failed to fetch %s", url)
 	}
@@ -423,17 +477,17 @@ func (h *LbankApi) Board(trading string, settlement string) (board *models.Board
 		if err != nil {
 			return nil, errors.Wrapf(err, "failed to parse array")
 		}
-		pricef, err := arr[0].Float64()
+		pricef, err := arr[0].Number()
 		if err != nil {
 			return nil, errors.Wrapf(err, "failed to parse array")
 		}
-		sizef, err := arr[1].Float64()
+		sizef, err := arr[1].Number()
 		if err != nil {
 			return nil, errors.Wrapf(err, "failed to parse array")
 		}
 		bids = append(bids, models.BoardBar{
-			Price:  pricef,
-			Amount: sizef,
+			Price:  helpers.ToDecimal(pricef),
+			Amount: helpers.ToDecimal(sizef),
 			Type:   models.Bid,
 		})
 	}
@@ -442,17 +496,17 @@ func (h *LbankApi) Board(trading string, settlement string) (board *models.Board
 		if err != nil {
 			return nil, errors.Wrapf(err, "failed to parse array")
 		}
-		pricef, err := arr[0].Float64()
+		pricef, err := arr[0].Number()
 		if err != nil {
 			return nil, errors.Wrapf(err, "failed to parse array")
 		}
-		sizef, err := arr[1].Float64()
+		sizef, err := arr[1].Number()
 		if err != nil {
 			return nil, errors.Wrapf(err, "failed to parse array")
 		}
 		asks = append(asks, models.BoardBar{
-			Price:  pricef,
-			Amount: sizef,
+			Price:  helpers.ToDecimal(pricef),
+			Amount: helpers.ToDecimal(sizef),
 			Type:   models.Ask,
 		})
 	}
@@ -464,3 +518,67 @@ func (h *LbankApi) Board(trading string, settlement string) (board *models.Board
 
 	return board, nil
 }
+
+var lbankIntervals = map[models.Interval]string{
+	models.Interval1m:  "minute1",
+	models.Interval5m:  "minute5",
+	models.Interval15m: "minute15",
+	models.Interval30m: "minute30",
+	models.Interval1h:  "hour1",
+	models.Interval4h:  "hour4",
+	models.Interval8h:  "hour8",
+	models.Interval12h: "hour12",
+	models.Interval1d:  "day1",
+	models.Interval1w:  "week1",
+}
+
+func (h *LbankApi) Candles(trading string, settlement string, interval models.Interval, since time.Time, limit int) ([]models.Candle, error) {
+	period, ok := lbankIntervals[interval]
+	if !ok {
+		return nil, unsupportedInterval("lbank", interval)
+	}
+	return pageCandles(interval, since, limit, 0, func(start time.Time) ([]models.Candle, error) {
+		url := h.publicApiUrl("/v1/kline.do?size=2880&symbol=" + lbankSymbols.Symbol(trading, settlement) +
+			"&type=" + period + "&time=" + strconv.FormatInt(start.Unix(), 10))
+		byteArray, err := getBody(h.context(), h.HttpClient, url)
+		if err != nil {
+			return nil, err
+		}
+		var candles []models.Candle
+		for _, v := range gjson.ParseBytes(byteArray).Array() {
+			a := v.Array()
+			if len(a) < 6 {
+				continue
+			}
+			candles = append(candles, models.Candle{
+				Time:   time.Unix(a[0].Int(), 0),
+				Open:   helpers.ToDecimal(a[1]),
+				High:   helpers.ToDecimal(a[2]),
+				Low:    helpers.ToDecimal(a[3]),
+				Close:  helpers.ToDecimal(a[4]),
+				Volume: helpers.ToDecimal(a[5]),
+			})
+		}
+		return candles, nil
+	})
+}
+
+func (h *LbankApi) RecentTrades(trading string, settlement string, limit int) ([]models.PublicTrade, error) {
+	limit = tradesLimit(limit, 600)
+	url := h.publicApiUrl("/v1/trades.do?symbol=" + lbankSymbols.Symbol(trading, settlement) + "&size=" + strconv.Itoa(limit))
+	byteArray, err := getBody(h.context(), h.HttpClient, url)
+	if err != nil {
+		return nil, err
+	}
+	var trades []models.PublicTrade
+	for _, v := range gjson.ParseBytes(byteArray).Array() {
+		trades = append(trades, models.PublicTrade{
+			ID:     v.Get("tid").String(),
+			Type:   takerSide(v.Get("type").Str),
+			Price:  helpers.ToDecimal(v.Get("price")),
+			Amount: helpers.ToDecimal(v.Get("amount")),
+			Time:   millisToTime(v.Get("date_ms").Int()),
+		})
+	}
+	return latestTrades(trades, limit), nil
+}
@@ -1,8 +1,12 @@
 // Code generated by mockery v1.0.0
 package mocks
 
+import context "context"
+import http "net/http"
 import mock "github.com/stretchr/testify/mock"
 import models "github.com/fxpgr/go-exchange-client/models"
+import public "github.com/fxpgr/go-exchange-client/api/public"
+import time "time"
 
 // PublicClient is an autogenerated mock type for the PublicClient type
 type PublicClient struct {
@@ -32,6 +36,29 @@ func (_m *PublicClient) Board(trading string, settlement string) (*models.Board,
 	return r0, r1
 }
 
+// Candles provides a mock function with given fields: trading, settlement, interval, since, limit
+func (_m *PublicClient) Candles(trading string, settlement string, interval models.Interval, since time.Time, limit int) ([]models.Candle, error) {
+	ret := _m.Called(trading, settlement, interval, since, limit)
+
+	var r0 []models.Candle
+	if rf, ok := ret.Get(0).(func(string, string, models.Interval, time.Time, int) []models.Candle); ok {
+		r0 = rf(trading, settlement, interval, since, limit)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]models.Candle)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(string, string, models.Interval, time.Time, int) error); ok {
+		r1 = rf(trading, settlement, interval, since, limit)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
 // CurrencyPairs provides a mock function with given fields:
 func (_m *PublicClient) CurrencyPairs() ([]models.CurrencyPair, error) {
 	ret := _m.Called()
@@ -78,6 +105,52 @@ func (_m *PublicClient) FrozenCurrency() ([]string, error) {
 	return r0, r1
 }
 
+// OrderBookTickMap provides a mock function with given fields:
+func (_m *PublicClient) OrderBookTickMap() (map[string]map[string]models.OrderBookTick, error) {
+	ret := _m.Called()
+
+	var r0 map[string]map[string]models.OrderBookTick
+	if rf, ok := ret.Get(0).(func() map[string]map[string]models.OrderBookTick); ok {
+		r0 = rf()
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(map[string]map[string]models.OrderBookTick)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func() error); ok {
+		r1 = rf()
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// Precise provides a mock function with given fields: trading, settlement
+func (_m *PublicClient) Precise(trading string, settlement string) (*models.Precisions, error) {
+	ret := _m.Called(trading, settlement)
+
+	var r0 *models.Precisions
+	if rf, ok := ret.Get(0).(func(string, string) *models.Precisions); ok {
+		r0 = rf(trading, settlement)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*models.Precisions)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(string, string) error); ok {
+		r1 = rf(trading, settlement)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
 // Rate provides a mock function with given fields: trading, settlement
 func (_m *PublicClient) Rate(trading string, settlement string) (float64, error) {
 	ret := _m.Called(trading, settlement)
@@ -122,6 +195,66 @@ func (_m *PublicClient) RateMap() (map[string]map[string]float64, error) {
 	return r0, r1
 }
 
+// RecentTrades provides a mock function with given fields: trading, settlement, limit
+func (_m *PublicClient) RecentTrades(trading string, settlement string, limit int) ([]models.PublicTrade, error) {
+	ret := _m.Called(trading, settlement, limit)
+
+	var r0 []models.PublicTrade
+	if rf, ok := ret.Get(0).(func(string, string, int) []models.PublicTrade); ok {
+		r0 = rf(trading, settlement, limit)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]models.PublicTrade)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(string, string, int) error); ok {
+		r1 = rf(trading, settlement, limit)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// Rules provides a mock function with given fields: trading, settlement
+func (_m *PublicClient) Rules(trading string, settlement string) (*models.MarketRules, error) {
+	ret := _m.Called(trading, settlement)
+
+	var r0 *models.MarketRules
+	if rf, ok := ret.Get(0).(func(string, string) *models.MarketRules); ok {
+		r0 = rf(trading, settlement)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*models.MarketRules)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(string, string) error); ok {
+		r1 = rf(trading, settlement)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// SetTransport provides a mock function with given fields: transport
+func (_m *PublicClient) SetTransport(transport http.RoundTripper) error {
+	ret := _m.Called(transport)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(http.RoundTripper) error); ok {
+		r0 = rf(transport)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
 // Volume provides a mock function with given fields: trading, settlement
 func (_m *PublicClient) Volume(trading string, settlement string) (float64, error) {
 	ret := _m.Called(trading, settlement)
@@ -165,3 +298,19 @@ func (_m *PublicClient) VolumeMap() (map[string]map[string]float64, error) {
 
 	return r0, r1
 }
+
+// WithContext provides a mock function with given fields: ctx
+func (_m *PublicClient) WithContext(ctx context.Context) public.PublicClient {
+	ret := _m.Called(ctx)
+
+	var r0 public.PublicClient
+	if rf, ok := ret.Get(0).(func(context.Context) public.PublicClient); ok {
+		r0 = rf(ctx)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(public.PublicClient)
+		}
+	}
+
+	return r0
+}
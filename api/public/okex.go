@@ -1,13 +1,18 @@
 package public
 
 import (
+	"context"
 	"net/http"
 	"sync"
 	"time"
 
 	"github.com/antonholmquist/jason"
 	"github.com/fxpgr/go-exchange-client/api/unified"
+	"github.com/fxpgr/go-exchange-client/apierrors"
+	"github.com/fxpgr/go-exchange-client/decimal"
+	"github.com/fxpgr/go-exchange-client/helpers"
 	"github.com/fxpgr/go-exchange-client/models"
+	"github.com/fxpgr/go-exchange-client/symbols"
 	"github.com/pkg/errors"
 	"github.com/tidwall/gjson"
 	"io/ioutil"
@@ -20,6 +25,8 @@ const (
 	OKEX_BASE_URL = "https://www.okex.com"
 )
 
+var okexSymbols = symbols.For("okex")
+
 func NewOkexPublicApi() (*OkexApi, error) {
 	shrimpyApi, err := unified.NewShrimpyApi()
 	if err != nil {
@@ -35,7 +42,7 @@ func NewOkexPublicApi() (*OkexApi, error) {
 		CurrencyPairsCacheDuration: 7 * 24 * time.Hour,
 		currencyPairsLastUpdated:   time.Date(1970, 1, 1, 0, 0, 0, 0, time.UTC),
 
-		HttpClient:    &http.Client{},
+		HttpClient:    &http.Client{Transport: newTransport("okex", nil)},
 		ShrimpyClient: shrimpyApi,
 		rt:            &http.Transport{},
 
@@ -55,11 +62,13 @@ type OkexApi struct {
 	rateMap                    map[string]map[string]float64
 	orderBookTickMap           map[string]map[string]models.OrderBookTick
 	precisionMap               map[string]map[string]models.Precisions
+	rulesMap                   map[string]map[string]models.MarketRules
 	currencyPairs              []models.CurrencyPair
 	CurrencyPairsCacheDuration time.Duration
 	currencyPairsLastUpdated   time.Time
 
-	HttpClient    *http.Client
+	HttpClient *http.Client
+	clientContext
 	ShrimpyClient *unified.ShrimpyApiClient
 
 	rt http.RoundTripper
@@ -72,9 +81,15 @@ type OkexApi struct {
 }
 
 func (h *OkexApi) SetTransport(transport http.RoundTripper) error {
-	h.HttpClient.Transport = transport
+	h.HttpClient.Transport = newTransport("okex", transport)
 	return nil
 }
+
+func (h *OkexApi) WithContext(ctx context.Context) PublicClient {
+	c := *h
+	c.ctx = ctx
+	return &c
+}
 func (h *OkexApi) publicApiUrl(command string) string {
 	return h.BaseURL + command
 }
@@ -103,7 +118,7 @@ func (h *OkexApi) fetchPrecision() error {
 	h.precisionMap = make(map[string]map[string]models.Precisions)
 
 	url := h.publicApiUrl("/v2/spot/markets/tickers")
-	resp, err := h.HttpClient.Get(url)
+	resp, err := httpGet(h.context(), h.HttpClient, url)
 	if err != nil {
 		return errors.Wrapf(err, "failed to fetch %s", url)
 	}
@@ -120,12 +135,11 @@ func (h *OkexApi) fetchPrecision() error {
 		volume := v.Get("volume").Str
 
 		pairString := v.Get("symbol").Str
-		currencies := strings.Split(pairString, "_")
-		if len(currencies) != 2 {
+		pair, ok := okexSymbols.Pair(pairString)
+		if !ok {
 			continue
 		}
-		trading := currencies[0]
-		settlement := currencies[1]
+		trading, settlement := pair.Trading, pair.Settlement
 		m, ok := h.precisionMap[trading]
 		if !ok {
 			m = make(map[string]models.Precisions)
@@ -151,7 +165,7 @@ func (h *OkexApi) fetchRate() error {
 	h.volumeMap = make(map[string]map[string]float64)
 	h.orderBookTickMap = make(map[string]map[string]models.OrderBookTick)
 	url := h.publicApiUrl("/v2/spot/markets/tickers")
-	resp, err := h.HttpClient.Get(url)
+	resp, err := httpGet(h.context(), h.HttpClient, url)
 	if err != nil {
 		return errors.Wrapf(err, "failed to fetch %s", url)
 	}
@@ -191,7 +205,7 @@ func (h *OkexApi) fetchRate() error {
 		if err != nil {
 			return errors.Wrapf(err, "failed to parse quote")
 		}
-		buyf, err := strconv.ParseFloat(buyString, 64)
+		buyf, err := decimal.NewFromString(buyString)
 		if err != nil {
 			return errors.Wrapf(err, "failed to parse quote")
 		}
@@ -199,7 +213,7 @@ func (h *OkexApi) fetchRate() error {
 		if err != nil {
 			return errors.Wrapf(err, "failed to parse quote")
 		}
-		sellf, err := strconv.ParseFloat(sellString, 64)
+		sellf, err := decimal.NewFromString(sellString)
 		if err != nil {
 			return errors.Wrapf(err, "failed to parse quote")
 		}
@@ -208,12 +222,11 @@ func (h *OkexApi) fetchRate() error {
 		if err != nil {
 			return errors.Wrapf(err, "failed to parse quote")
 		}
-		currencies := strings.Split(pairString, "_")
-		if len(currencies) != 2 {
+		pair, ok := okexSymbols.Pair(pairString)
+		if !ok {
 			continue
 		}
-		trading := currencies[0]
-		settlement := currencies[1]
+		trading, settlement := pair.Trading, pair.Settlement
 		m, ok := h.rateMap[trading]
 		if !ok {
 			m = make(map[string]float64)
@@ -240,13 +253,15 @@ func (h *OkexApi) fetchRate() error {
 }
 
 func (h *OkexApi) fetchOrderBookTick() error {
-	boardMap, err := h.ShrimpyClient.GetBoards("okex")
+	boardMap, err := h.ShrimpyClient.WithContext(h.context()).GetBoards("okex")
 	if err != nil {
 		return err
 	}
 	orderBookTickMap := make(map[string]map[string]models.OrderBookTick)
 	for settlement, m := range boardMap {
+		settlement = okexSymbols.Currency(settlement)
 		for trading, value := range m {
+			trading = okexSymbols.Currency(trading)
 			l, ok := orderBookTickMap[trading]
 			if !ok {
 				l = make(map[string]models.OrderBookTick)
@@ -313,7 +328,7 @@ func (h *OkexApi) CurrencyPairs() ([]models.CurrencyPair, error) {
 		return h.currencyPairs, nil
 	}
 	url := h.publicApiUrl("/v2/markets/products")
-	resp, err := h.HttpClient.Get(url)
+	resp, err := httpGet(h.context(), h.HttpClient, url)
 	if err != nil {
 		return nil, errors.Wrapf(err, "failed to fetch %s", url)
 	}
@@ -337,14 +352,10 @@ func (h *OkexApi) CurrencyPairs() ([]models.CurrencyPair, error) {
 		if err != nil {
 			return nil, errors.Wrapf(err, "failed to parse quote")
 		}
-		currencies := strings.Split(pairString, "_")
-		if len(currencies) != 2 {
+		pair, ok := okexSymbols.Pair(pairString)
+		if !ok {
 			continue
 		}
-		pair := models.CurrencyPair{
-			Trading:    strings.ToUpper(currencies[0]),
-			Settlement: strings.ToUpper(currencies[1]),
-		}
 		pairs = append(pairs, pair)
 	}
 	h.currencyPairs = pairs
@@ -358,14 +369,56 @@ func (h *OkexApi) Precise(trading string, settlement string) (*models.Precisions
 
 	h.fetchPrecision()
 	if m, ok := h.precisionMap[trading]; !ok {
-		return &models.Precisions{}, errors.Errorf("%s/%s", trading, settlement)
+		return &models.Precisions{}, errors.Wrapf(apierrors.ErrInvalidSymbol, "%s/%s", trading, settlement)
 	} else if precisions, ok := m[settlement]; !ok {
-		return &models.Precisions{}, errors.Errorf("%s/%s", trading, settlement)
+		return &models.Precisions{}, errors.Wrapf(apierrors.ErrInvalidSymbol, "%s/%s", trading, settlement)
 	} else {
 		return &precisions, nil
 	}
 }
 
+func (h *OkexApi) fetchRules() error {
+	if h.rulesMap != nil {
+		return nil
+	}
+	url := h.publicApiUrl("/api/spot/v3/instruments")
+	byteArray, err := getBody(h.context(), h.HttpClient, url)
+	if err != nil {
+		return err
+	}
+	rulesMap := make(map[string]map[string]models.MarketRules)
+	for _, v := range gjson.ParseBytes(byteArray).Array() {
+		pair := okexSymbols.AddPair(v.Get("base_currency").Str, v.Get("quote_currency").Str)
+		r, ok := rulesMap[pair.Trading]
+		if !ok {
+			r = make(map[string]models.MarketRules)
+			rulesMap[pair.Trading] = r
+		}
+		r[pair.Settlement] = models.MarketRules{
+			TickSize:  helpers.ToDecimal(v.Get("tick_size")),
+			StepSize:  helpers.ToDecimal(v.Get("size_increment")),
+			MinAmount: helpers.ToDecimal(v.Get("min_size")),
+		}
+	}
+	h.rulesMap = rulesMap
+	return nil
+}
+
+func (h *OkexApi) Rules(trading string, settlement string) (*models.MarketRules, error) {
+	h.m.Lock()
+	defer h.m.Unlock()
+	if err := h.fetchRules(); err != nil {
+		return nil, err
+	}
+	if m, ok := h.rulesMap[trading]; !ok {
+		return nil, errors.Wrapf(apierrors.ErrInvalidSymbol, "%s/%s", trading, settlement)
+	} else if rules, ok := m[settlement]; !ok {
+		return nil, errors.Wrapf(apierrors.ErrInvalidSymbol, "%s/%s", trading, settlement)
+	} else {
+		return &rules, nil
+	}
+}
+
 func (h *OkexApi) Volume(trading string, settlement string) (float64, error) {
 	h.m.Lock()
 	defer h.m.Unlock()
@@ -379,9 +432,9 @@ func (h *OkexApi) Volume(trading string, settlement string) (float64, error) {
 		h.rateLastUpdated = now
 	}
 	if m, ok := h.volumeMap[trading]; !ok {
-		return 0, errors.Errorf("%s/%s", trading, settlement)
+		return 0, errors.Wrapf(apierrors.ErrInvalidSymbol, "%s/%s", trading, settlement)
 	} else if volume, ok := m[settlement]; !ok {
-		return 0, errors.Errorf("%s/%s", trading, settlement)
+		return 0, errors.Wrapf(apierrors.ErrInvalidSymbol, "%s/%s", trading, settlement)
 	} else {
 		return volume, nil
 	}
@@ -404,9 +457,9 @@ func (h *OkexApi) Rate(trading string, settlement string) (float64, error) {
 		h.rateLastUpdated = now
 	}
 	if m, ok := h.rateMap[trading]; !ok {
-		return 0, errors.Errorf("%s/%s", trading, settlement)
+		return 0, errors.Wrapf(apierrors.ErrInvalidSymbol, "%s/%s", trading, settlement)
 	} else if rate, ok := m[settlement]; !ok {
-		return 0, errors.Errorf("%s/%s", trading, settlement)
+		return 0, errors.Wrapf(apierrors.ErrInvalidSymbol, "%s/%s", trading, settlement)
 	} else {
 		return rate, nil
 	}
@@ -415,7 +468,7 @@ func (h *OkexApi) Rate(trading string, settlement string) (float64, error) {
 func (h *OkexApi) FrozenCurrency() ([]string, error) {
 	var frozens []string
 	url := h.publicApiUrl("/v2/markets/currencies")
-	resp, err := h.HttpClient.Get(url)
+	resp, err := httpGet(h.context(), h.HttpClient, url)
 	if err != nil {
 		return nil, errors.Wrapf(err, "failed to fetch %s", url)
 	}
@@ -449,7 +502,7 @@ func (h *OkexApi) FrozenCurrency() ([]string, error) {
 		if err != nil {
 			return nil, errors.Wrapf(err, "failed to parse quote")
 		}
-		frozens = append(frozens, currencyName)
+		frozens = append(frozens, okexSymbols.Currency(currencyName))
 	}
 	return frozens, nil
 }
@@ -457,9 +510,9 @@ func (h *OkexApi) FrozenCurrency() ([]string, error) {
 func (h *OkexApi) Board(trading string, settlement string) (board *models.Board, err error) {
 	args := url2.Values{}
 	args.Add("size", "200")
-	method := "/v2/markets/" + strings.ToLower(trading) + "_" + strings.ToLower(settlement) + "/depth?" + args.Encode()
+	method := "/v2/markets/" + okexSymbols.Symbol(trading, settlement) + "/depth?" + args.Encode()
 	url := h.publicApiUrl(method)
-	resp, err := h.HttpClient.Get(url)
+	resp, err := httpGet(h.context(), h.HttpClient, url)
 	if err != nil {
 		return nil, errors.Wrapf(err, "failed to fetch %s", url)
 	}
@@ -496,11 +549,11 @@ func (h *OkexApi) Board(trading string, settlement string) (board *models.Board,
 		if err != nil {
 			return nil, errors.Wrapf(err, "failed to parse array")
 		}
-		pricef, err := strconv.ParseFloat(priceString, 64)
+		pricef, err := decimal.NewFromString(priceString)
 		if err != nil {
 			return nil, errors.Wrapf(err, "failed to parse price")
 		}
-		sizef, err := strconv.ParseFloat(sizeString, 64)
+		sizef, err := decimal.NewFromString(sizeString)
 		if err != nil {
 			return nil, errors.Wrapf(err, "failed to parse size")
 		}
@@ -520,11 +573,11 @@ func (h *OkexApi) Board(trading string, settlement string) (board *models.Board,
 		if err != nil {
 			return nil, errors.Wrapf(err, "failed to parse array")
 		}
-		pricef, err := strconv.ParseFloat(priceString, 64)
+		pricef, err := decimal.NewFromString(priceString)
 		if err != nil {
 			return nil, errors.Wrapf(err, "failed to parse price")
 		}
-		sizef, err := strconv.ParseFloat(sizeString, 64)
+		sizef, err := decimal.NewFromString(sizeString)
 		if err != nil {
 			return nil, errors.Wrapf(err, "failed to parse size")
 		}
@@ -540,3 +593,65 @@ func (h *OkexApi) Board(trading string, settlement string) (board *models.Board,
 	}
 	return board, nil
 }
+
+const okexCandlesPageSize = 200
+
+func (h *OkexApi) Candles(trading string, settlement string, interval models.Interval, since time.Time, limit int) ([]models.Candle, error) {
+	if interval == models.Interval8h {
+		return nil, unsupportedInterval("okex", interval)
+	}
+	step := interval.Duration()
+	return pageCandles(interval, since, limit, okexCandlesPageSize, func(start time.Time) ([]models.Candle, error) {
+		end := start.Add(okexCandlesPageSize * step)
+		url := h.publicApiUrl("/api/spot/v3/instruments/" + strings.ToUpper(okexSymbols.Native(trading)+"-"+okexSymbols.Native(settlement)) + "/candles?granularity=" +
+			strconv.Itoa(int(step/time.Second)) + "&start=" + start.UTC().Format(time.RFC3339) + "&end=" + end.UTC().Format(time.RFC3339))
+		byteArray, err := getBody(h.context(), h.HttpClient, url)
+		if err != nil {
+			return nil, err
+		}
+		var candles []models.Candle
+		for _, v := range gjson.ParseBytes(byteArray).Array() {
+			a := v.Array()
+			if len(a) < 6 {
+				continue
+			}
+			t, err := time.Parse(time.RFC3339Nano, a[0].Str)
+			if err != nil {
+				return nil, errors.Wrapf(err, "failed to parse candle time %s", a[0].Str)
+			}
+			candles = append(candles, models.Candle{
+				Time:   t,
+				Open:   helpers.ToDecimal(a[1]),
+				High:   helpers.ToDecimal(a[2]),
+				Low:    helpers.ToDecimal(a[3]),
+				Close:  helpers.ToDecimal(a[4]),
+				Volume: helpers.ToDecimal(a[5]),
+			})
+		}
+		return candles, nil
+	})
+}
+
+func (h *OkexApi) RecentTrades(trading string, settlement string, limit int) ([]models.PublicTrade, error) {
+	limit = tradesLimit(limit, 100)
+	url := h.publicApiUrl("/api/spot/v3/instruments/" + strings.ToUpper(okexSymbols.Native(trading)+"-"+okexSymbols.Native(settlement)) + "/trades?limit=" + strconv.Itoa(limit))
+	byteArray, err := getBody(h.context(), h.HttpClient, url)
+	if err != nil {
+		return nil, err
+	}
+	var trades []models.PublicTrade
+	for _, v := range gjson.ParseBytes(byteArray).Array() {
+		t, err := time.Parse(time.RFC3339Nano, v.Get("timestamp").Str)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to parse trade time %s", v.Get("timestamp").Str)
+		}
+		trades = append(trades, models.PublicTrade{
+			ID:     v.Get("trade_id").String(),
+			Type:   takerSide(v.Get("side").Str),
+			Price:  helpers.ToDecimal(v.Get("price")),
+			Amount: helpers.ToDecimal(v.Get("size")),
+			Time:   t,
+		})
+	}
+	return latestTrades(trades, limit), nil
+}
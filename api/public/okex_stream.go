@@ -0,0 +1,292 @@
+package public
+
+import (
+	"context"
+	"hash/crc32"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/fxpgr/go-exchange-client/helpers"
+	"github.com/fxpgr/go-exchange-client/logger"
+	"github.com/fxpgr/go-exchange-client/models"
+	"github.com/fxpgr/go-exchange-client/stream"
+	"github.com/pkg/errors"
+	"github.com/tidwall/gjson"
+)
+
+const (
+	OKEX_STREAM_URL = "wss://real.okex.com:8443/ws/v3"
+)
+
+// okexChecksumDepth is the number of levels of each side the checksum of a
+// depth update covers.
+const okexChecksumDepth = 25
+
+// OkexStream subscribes to the spot channels of OKEx. A board is loaded from
+// the partial depth pushed on subscription and kept by the updates following
+// it, each of which is verified by its checksum. A board failing the
+// checksum is subscribed to again to get a new partial.
+type OkexStream struct {
+	Conn *stream.Conn
+
+	topics map[string]*okexTopic
+	cancel context.CancelFunc
+	m      sync.Mutex
+}
+
+type okexTopic struct {
+	book   *stream.Book
+	synced bool
+	// raw keeps the levels of book as pushed, "price:size" by the price of
+	// the level and its side, as the checksum is taken over them.
+	raw         map[models.OrderType]map[string]string
+	subscribers stream.Subscribers
+}
+
+func NewOkexStream() *OkexStream {
+	s := &OkexStream{
+		topics: make(map[string]*okexTopic),
+	}
+	s.Conn = stream.NewConn(OKEX_STREAM_URL, s.handle)
+	s.Conn.Decode = stream.Inflate
+	s.Conn.OnConnect = s.onConnect
+	s.Conn.Ping = func(c *stream.Conn) error {
+		return c.WriteText([]byte("ping"))
+	}
+	return s
+}
+
+func okexInstrumentID(trading string, settlement string) string {
+	return strings.ToUpper(okexSymbols.Native(trading) + "-" + okexSymbols.Native(settlement))
+}
+
+// SubscribeBoard returns a channel receiving the board of a pair whenever it
+// changes, until ctx is done. A receiver which falls behind only gets the
+// latest board.
+func (s *OkexStream) SubscribeBoard(ctx context.Context, trading string, settlement string) (<-chan *models.Board, error) {
+	var ch chan *models.Board
+	err := s.subscribe(ctx, trading, settlement, "spot/depth", func(t *okexTopic) interface{} {
+		ch = t.subscribers.AddBoard()
+		if t.synced {
+			ch <- t.book.Board()
+		}
+		return ch
+	})
+	return ch, err
+}
+
+// SubscribeTicks returns a channel receiving the best bid and offer of a pair
+// whenever they change, until ctx is done. A receiver which falls behind only
+// gets the latest tick.
+func (s *OkexStream) SubscribeTicks(ctx context.Context, trading string, settlement string) (<-chan models.OrderBookTick, error) {
+	var ch chan models.OrderBookTick
+	err := s.subscribe(ctx, trading, settlement, "spot/ticker", func(t *okexTopic) interface{} {
+		ch = t.subscribers.AddTicks()
+		return ch
+	})
+	return ch, err
+}
+
+// SubscribeTrades returns a channel receiving the trades of a pair as they
+// happen, until ctx is done.
+func (s *OkexStream) SubscribeTrades(ctx context.Context, trading string, settlement string) (<-chan models.PublicTrade, error) {
+	var ch chan models.PublicTrade
+	err := s.subscribe(ctx, trading, settlement, "spot/trade", func(t *okexTopic) interface{} {
+		ch = t.subscribers.AddTrades()
+		return ch
+	})
+	return ch, err
+}
+
+func (s *OkexStream) subscribe(ctx context.Context, trading string, settlement string, channel string, add func(t *okexTopic) interface{}) error {
+	if trading == settlement {
+		return errors.Errorf("trading and settlment are same")
+	}
+	name := channel + ":" + okexInstrumentID(trading, settlement)
+
+	s.m.Lock()
+	t, ok := s.topics[name]
+	if !ok {
+		t = &okexTopic{book: stream.NewBook()}
+		t.reset()
+		s.topics[name] = t
+		s.send("subscribe", name)
+	}
+	ch := add(t)
+	if s.cancel == nil {
+		var connCtx context.Context
+		connCtx, s.cancel = context.WithCancel(context.Background())
+		go s.Conn.Run(connCtx)
+	}
+	s.m.Unlock()
+
+	go func() {
+		<-ctx.Done()
+		s.unsubscribe(name, ch)
+	}()
+	return nil
+}
+
+func (s *OkexStream) unsubscribe(name string, ch interface{}) {
+	s.m.Lock()
+	defer s.m.Unlock()
+	t, ok := s.topics[name]
+	if !ok {
+		return
+	}
+	t.subscribers.Remove(ch)
+	if t.subscribers.Len() == 0 {
+		delete(s.topics, name)
+		s.send("unsubscribe", name)
+	}
+}
+
+// Close disconnects the stream and closes the channels of every
+// subscription.
+func (s *OkexStream) Close() {
+	s.m.Lock()
+	defer s.m.Unlock()
+	if s.cancel != nil {
+		s.cancel()
+		s.cancel = nil
+	}
+	for name, t := range s.topics {
+		t.subscribers.Close()
+		delete(s.topics, name)
+	}
+}
+
+// send subscribes to or unsubscribes from the channels names. Channels
+// subscribed to while the connection is down are subscribed to by onConnect.
+func (s *OkexStream) send(op string, names ...string) error {
+	err := s.Conn.WriteJSON(map[string]interface{}{"op": op, "args": names})
+	if err != nil && err != stream.ErrNotConnected {
+		return errors.Wrapf(err, "failed to %s %v", op, names)
+	}
+	return nil
+}
+
+func (s *OkexStream) onConnect(*stream.Conn) error {
+	s.m.Lock()
+	defer s.m.Unlock()
+	var names []string
+	for name, t := range s.topics {
+		t.reset()
+		names = append(names, name)
+	}
+	if len(names) == 0 {
+		return nil
+	}
+	return s.send("subscribe", names...)
+}
+
+func (s *OkexStream) handle(msg []byte) error {
+	if string(msg) == "pong" {
+		return nil
+	}
+	value := gjson.ParseBytes(msg)
+	if value.Get("event").Str == "error" {
+		logger.Get().Warnw("okex stream request failed", "error", value.Get("message").Str)
+		return nil
+	}
+	table := value.Get("table").Str
+	if table == "" {
+		return nil
+	}
+
+	s.m.Lock()
+	defer s.m.Unlock()
+	for _, data := range value.Get("data").Array() {
+		name := table + ":" + data.Get("instrument_id").Str
+		t, ok := s.topics[name]
+		if !ok {
+			continue
+		}
+		switch table {
+		case "spot/depth":
+			s.onDepth(name, t, value.Get("action").Str == "partial", data)
+		case "spot/ticker":
+			t.subscribers.PublishTick(models.OrderBookTick{
+				BestAskPrice:  helpers.ToDecimal(data.Get("best_ask")),
+				BestAskAmount: helpers.ToDecimal(data.Get("best_ask_size")),
+				BestBidPrice:  helpers.ToDecimal(data.Get("best_bid")),
+				BestBidAmount: helpers.ToDecimal(data.Get("best_bid_size")),
+			})
+		case "spot/trade":
+			tm, err := time.Parse(time.RFC3339Nano, data.Get("timestamp").Str)
+			if err != nil {
+				logger.Get().Warnw("failed to parse okex trade time", "timestamp", data.Get("timestamp").Str)
+				continue
+			}
+			t.subscribers.PublishTrade(models.PublicTrade{
+				ID:     data.Get("trade_id").String(),
+				Type:   takerSide(data.Get("side").Str),
+				Price:  helpers.ToDecimal(data.Get("price")),
+				Amount: helpers.ToDecimal(data.Get("size")),
+				Time:   tm,
+			})
+		}
+	}
+	return nil
+}
+
+// onDepth applies a partial or an update to the board of t. Updates are
+// ignored until the partial following a subscription arrives.
+func (s *OkexStream) onDepth(name string, t *okexTopic, partial bool, data gjson.Result) {
+	if partial {
+		t.reset()
+		t.synced = true
+	} else if !t.synced {
+		return
+	}
+	for _, level := range data.Get("asks").Array() {
+		t.set(models.Ask, level.Get("0").Str, level.Get("1").Str)
+	}
+	for _, level := range data.Get("bids").Array() {
+		t.set(models.Bid, level.Get("0").Str, level.Get("1").Str)
+	}
+	if checksum := int32(data.Get("checksum").Int()); t.checksum() != checksum {
+		logger.Get().Warnw("okex depth checksum mismatch, subscribing again", "channel", name)
+		t.reset()
+		s.send("unsubscribe", name)
+		s.send("subscribe", name)
+		return
+	}
+	t.subscribers.PublishBoard(t.book)
+}
+
+func (t *okexTopic) reset() {
+	t.synced = false
+	t.book.Reset()
+	t.raw = map[models.OrderType]map[string]string{
+		models.Ask: make(map[string]string),
+		models.Bid: make(map[string]string),
+	}
+}
+
+func (t *okexTopic) set(side models.OrderType, price string, size string) {
+	p, a := helpers.ToDecimal(price), helpers.ToDecimal(size)
+	t.book.Set(side, p, a)
+	if a.IsPositive() {
+		t.raw[side][p.String()] = price + ":" + size
+	} else {
+		delete(t.raw[side], p.String())
+	}
+}
+
+// checksum is the CRC32 of the best levels of the board, bids and asks
+// interleaved as they were pushed.
+func (t *okexTopic) checksum() int32 {
+	board := t.book.Board()
+	var levels []string
+	for i := 0; i < okexChecksumDepth; i++ {
+		if i < len(board.Bids) {
+			levels = append(levels, t.raw[models.Bid][board.Bids[i].Price.String()])
+		}
+		if i < len(board.Asks) {
+			levels = append(levels, t.raw[models.Ask][board.Asks[i].Price.String()])
+		}
+	}
+	return int32(crc32.ChecksumIEEE([]byte(strings.Join(levels, ":"))))
+}
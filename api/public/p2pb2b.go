@@ -1,15 +1,19 @@
 package public
 
 import (
+	"context"
 	"io/ioutil"
 	"net/http"
 	"strconv"
-	"strings"
 	"sync"
 	"time"
 
 	"github.com/Jeffail/gabs"
+	"github.com/fxpgr/go-exchange-client/apierrors"
+	"github.com/fxpgr/go-exchange-client/decimal"
+	"github.com/fxpgr/go-exchange-client/helpers"
 	"github.com/fxpgr/go-exchange-client/models"
+	"github.com/fxpgr/go-exchange-client/symbols"
 	"github.com/patrickmn/go-cache"
 	"github.com/pkg/errors"
 	"github.com/tidwall/gjson"
@@ -19,6 +23,8 @@ const (
 	P2PB2B_BASE_URL = "https://api.p2pb2b.io/api/v1"
 )
 
+var p2pb2bSymbols = symbols.For("p2pb2b")
+
 type P2pb2bApiConfig struct {
 }
 
@@ -32,7 +38,7 @@ func NewP2pb2bPublicApi() (*P2pb2bApi, error) {
 		precisionMap:      nil,
 		rateLastUpdated:   time.Date(1970, 1, 1, 0, 0, 0, 0, time.UTC),
 		boardCache:        cache.New(3*time.Second, 1*time.Second),
-		HttpClient:        &http.Client{},
+		HttpClient:        &http.Client{Transport: newTransport("p2pb2b", nil)},
 
 		m: new(sync.Mutex),
 	}
@@ -47,9 +53,11 @@ type P2pb2bApi struct {
 	rateMap           map[string]map[string]float64
 	orderBookTickMap  map[string]map[string]models.OrderBookTick
 	precisionMap      map[string]map[string]models.Precisions
+	rulesMap          map[string]map[string]models.MarketRules
 	rateLastUpdated   time.Time
 	boardCache        *cache.Cache
 	HttpClient        *http.Client
+	clientContext
 
 	settlements []string
 
@@ -58,10 +66,16 @@ type P2pb2bApi struct {
 }
 
 func (h *P2pb2bApi) SetTransport(transport http.RoundTripper) error {
-	h.HttpClient.Transport = transport
+	h.HttpClient.Transport = newTransport("p2pb2b", transport)
 	return nil
 }
 
+func (h *P2pb2bApi) WithContext(ctx context.Context) PublicClient {
+	c := *h
+	c.ctx = ctx
+	return &c
+}
+
 func (h *P2pb2bApi) publicApiUrl(command string) string {
 	return h.BaseURL + "/" + command
 }
@@ -69,7 +83,7 @@ func (h *P2pb2bApi) publicApiUrl(command string) string {
 func (h *P2pb2bApi) fetchSettlements() error {
 	settlements := make([]string, 0)
 	url := h.publicApiUrl("public/products")
-	resp, err := h.HttpClient.Get(url)
+	resp, err := httpGet(h.context(), h.HttpClient, url)
 	if err != nil {
 		return errors.Wrapf(err, "failed to fetch %s", url)
 	}
@@ -111,7 +125,7 @@ func (h *P2pb2bApi) fetchPrecision() error {
 	h.precisionMap = make(map[string]map[string]models.Precisions)
 
 	url := h.publicApiUrl("public/tickers")
-	resp, err := h.HttpClient.Get(url)
+	resp, err := httpGet(h.context(), h.HttpClient, url)
 	if err != nil {
 		return errors.Wrapf(err, "failed to fetch %s", url)
 	}
@@ -130,11 +144,11 @@ func (h *P2pb2bApi) fetchPrecision() error {
 		return errors.Wrapf(err, "failed to parse json")
 	}
 	for k, v := range rateMap {
-		coins := strings.Split(k, "_")
-		if len(coins) != 2 {
+		pair, ok := p2pb2bSymbols.Pair(k)
+		if !ok {
 			continue
 		}
-		trading, settlement := coins[0], coins[1]
+		trading, settlement := pair.Trading, pair.Settlement
 		if settlement == "" || trading == "" {
 			continue
 		}
@@ -176,7 +190,7 @@ func (h *P2pb2bApi) fetchRate() error {
 	h.volumeMap = make(map[string]map[string]float64)
 	h.orderBookTickMap = make(map[string]map[string]models.OrderBookTick)
 	url := h.publicApiUrl("public/tickers")
-	resp, err := h.HttpClient.Get(url)
+	resp, err := httpGet(h.context(), h.HttpClient, url)
 	if err != nil {
 		return errors.Wrapf(err, "failed to fetch %s", url)
 	}
@@ -195,11 +209,11 @@ func (h *P2pb2bApi) fetchRate() error {
 		return errors.Wrapf(err, "failed to parse json children map")
 	}
 	for k, v := range rateMap {
-		coins := strings.Split(k, "_")
-		if len(coins) != 2 {
+		pair, ok := p2pb2bSymbols.Pair(k)
+		if !ok {
 			continue
 		}
-		trading, settlement := coins[0], coins[1]
+		trading, settlement := pair.Trading, pair.Settlement
 		if settlement == "" || trading == "" {
 			continue
 		}
@@ -242,7 +256,7 @@ func (h *P2pb2bApi) fetchRate() error {
 		if !ok {
 			continue
 		}
-		askPricef, err := strconv.ParseFloat(askPrice, 64)
+		askPricef, err := decimal.NewFromString(askPrice)
 		if err != nil {
 			return err
 		}
@@ -250,7 +264,7 @@ func (h *P2pb2bApi) fetchRate() error {
 		if !ok {
 			continue
 		}
-		bidPricef, err := strconv.ParseFloat(bidPrice, 64)
+		bidPricef, err := decimal.NewFromString(bidPrice)
 		if err != nil {
 			return err
 		}
@@ -345,14 +359,64 @@ func (h *P2pb2bApi) Precise(trading string, settlement string) (*models.Precisio
 
 	h.fetchPrecision()
 	if m, ok := h.precisionMap[trading]; !ok {
-		return &models.Precisions{}, errors.Errorf("%s/%s", trading, settlement)
+		return &models.Precisions{}, errors.Wrapf(apierrors.ErrInvalidSymbol, "%s/%s", trading, settlement)
 	} else if precisions, ok := m[settlement]; !ok {
-		return &models.Precisions{}, errors.Errorf("%s/%s", trading, settlement)
+		return &models.Precisions{}, errors.Wrapf(apierrors.ErrInvalidSymbol, "%s/%s", trading, settlement)
 	} else {
 		return &precisions, nil
 	}
 }
 
+func (h *P2pb2bApi) fetchRules() error {
+	if h.rulesMap != nil {
+		return nil
+	}
+	url := h.publicApiUrl("public/markets")
+	byteArray, err := getBody(h.context(), h.HttpClient, url)
+	if err != nil {
+		return err
+	}
+	rulesMap := make(map[string]map[string]models.MarketRules)
+	for _, v := range gjson.ParseBytes(byteArray).Get("result").Array() {
+		pair := p2pb2bSymbols.AddPair(v.Get("stock").Str, v.Get("money").Str)
+		r, ok := rulesMap[pair.Trading]
+		if !ok {
+			r = make(map[string]models.MarketRules)
+			rulesMap[pair.Trading] = r
+		}
+		rules := models.NewMarketRules(models.Precisions{
+			PricePrecision:  int(v.Get("precision.money").Int()),
+			AmountPrecision: int(v.Get("precision.stock").Int()),
+		})
+		if step := helpers.ToDecimal(v.Get("limits.step_size")); step.IsPositive() {
+			rules.StepSize = step
+		}
+		rules.MinAmount = helpers.ToDecimal(v.Get("limits.min_amount"))
+		rules.MaxAmount = helpers.ToDecimal(v.Get("limits.max_amount"))
+		rules.MinPrice = helpers.ToDecimal(v.Get("limits.min_price"))
+		rules.MaxPrice = helpers.ToDecimal(v.Get("limits.max_price"))
+		rules.MinNotional = helpers.ToDecimal(v.Get("limits.min_total"))
+		r[pair.Settlement] = rules
+	}
+	h.rulesMap = rulesMap
+	return nil
+}
+
+func (h *P2pb2bApi) Rules(trading string, settlement string) (*models.MarketRules, error) {
+	h.m.Lock()
+	defer h.m.Unlock()
+	if err := h.fetchRules(); err != nil {
+		return nil, err
+	}
+	if m, ok := h.rulesMap[trading]; !ok {
+		return nil, errors.Wrapf(apierrors.ErrInvalidSymbol, "%s/%s", trading, settlement)
+	} else if rules, ok := m[settlement]; !ok {
+		return nil, errors.Wrapf(apierrors.ErrInvalidSymbol, "%s/%s", trading, settlement)
+	} else {
+		return &rules, nil
+	}
+}
+
 func (h *P2pb2bApi) Volume(trading string, settlement string) (float64, error) {
 	h.m.Lock()
 	defer h.m.Unlock()
@@ -366,9 +430,9 @@ func (h *P2pb2bApi) Volume(trading string, settlement string) (float64, error) {
 		h.rateLastUpdated = now
 	}
 	if m, ok := h.volumeMap[trading]; !ok {
-		return 0, errors.Errorf("%s/%s", trading, settlement)
+		return 0, errors.Wrapf(apierrors.ErrInvalidSymbol, "%s/%s", trading, settlement)
 	} else if volume, ok := m[settlement]; !ok {
-		return 0, errors.Errorf("%s/%s", trading, settlement)
+		return 0, errors.Wrapf(apierrors.ErrInvalidSymbol, "%s/%s", trading, settlement)
 	} else {
 		return volume, nil
 	}
@@ -391,9 +455,9 @@ func (h *P2pb2bApi) Rate(trading string, settlement string) (float64, error) {
 		h.rateLastUpdated = now
 	}
 	if m, ok := h.rateMap[trading]; !ok {
-		return 0, errors.Errorf("%s/%s", trading, settlement)
+		return 0, errors.Wrapf(apierrors.ErrInvalidSymbol, "%s/%s", trading, settlement)
 	} else if rate, ok := m[settlement]; !ok {
-		return 0, errors.Errorf("%s/%s", trading, settlement)
+		return 0, errors.Wrapf(apierrors.ErrInvalidSymbol, "%s/%s", trading, settlement)
 	} else {
 		return rate, nil
 	}
@@ -409,8 +473,8 @@ func (h *P2pb2bApi) Board(trading string, settlement string) (board *models.Boar
 	if found {
 		return c.(*models.Board), nil
 	}
-	url := h.publicApiUrl("public/depth/result?market=" + trading + "_" + settlement + "&limit=100")
-	resp, err := h.HttpClient.Get(url)
+	url := h.publicApiUrl("public/depth/result?market=" + p2pb2bSymbols.Symbol(trading, settlement) + "&limit=100")
+	resp, err := httpGet(h.context(), h.HttpClient, url)
 	if err != nil {
 		return nil, errors.Wrapf(err, "failed to fetch %s", url)
 	}
@@ -422,7 +486,7 @@ func (h *P2pb2bApi) Board(trading string, settlement string) (board *models.Boar
 	}
 	value := gjson.Parse(string(byteArray))
 	if value.Get("code").String() == "-1003" {
-		return nil, errors.Errorf("ip banned %s", url)
+		return nil, errors.Wrapf(apierrors.ErrIPBanned, "failed to fetch %s", url)
 	}
 	bidsJson := value.Get("bids").Array()
 	asksJson := value.Get("asks").Array()
@@ -430,8 +494,8 @@ func (h *P2pb2bApi) Board(trading string, settlement string) (board *models.Boar
 	asks := make([]models.BoardBar, 0)
 	bids := make([]models.BoardBar, 0)
 	for _, bidJson := range bidsJson {
-		price := bidJson.Array()[0].Float()
-		amount := bidJson.Array()[1].Float()
+		price := helpers.ToDecimal(bidJson.Array()[0])
+		amount := helpers.ToDecimal(bidJson.Array()[1])
 		bidBoardBar := models.BoardBar{
 			Type:   models.Bid,
 			Price:  price,
@@ -440,8 +504,8 @@ func (h *P2pb2bApi) Board(trading string, settlement string) (board *models.Boar
 		bids = append(bids, bidBoardBar)
 	}
 	for _, askJson := range asksJson {
-		price := askJson.Array()[0].Float()
-		amount := askJson.Array()[1].Float()
+		price := helpers.ToDecimal(askJson.Array()[0])
+		amount := helpers.ToDecimal(askJson.Array()[1])
 		askBoardBar := models.BoardBar{
 			Type:   models.Ask,
 			Price:  price,
@@ -456,3 +520,63 @@ func (h *P2pb2bApi) Board(trading string, settlement string) (board *models.Boar
 	h.boardCache.Set(trading+"_"+settlement, board, cache.DefaultExpiration)
 	return board, nil
 }
+
+var p2pb2bIntervals = map[models.Interval]string{
+	models.Interval1m: "1m",
+	models.Interval1h: "1h",
+	models.Interval1d: "1d",
+}
+
+// Candles can only reach back 500 candles, as p2pb2b serves the latest ones
+// only.
+func (h *P2pb2bApi) Candles(trading string, settlement string, interval models.Interval, since time.Time, limit int) ([]models.Candle, error) {
+	period, ok := p2pb2bIntervals[interval]
+	if !ok {
+		return nil, unsupportedInterval("p2pb2b", interval)
+	}
+	return pageCandles(interval, since, limit, 0, func(start time.Time) ([]models.Candle, error) {
+		url := h.publicApiUrl("public/market/kline?offset=0&limit=500&market=" + p2pb2bSymbols.Symbol(trading, settlement) + "&interval=" + period)
+		byteArray, err := getBody(h.context(), h.HttpClient, url)
+		if err != nil {
+			return nil, err
+		}
+		var candles []models.Candle
+		for _, v := range gjson.ParseBytes(byteArray).Get("result").Array() {
+			a := v.Array()
+			if len(a) < 6 {
+				continue
+			}
+			candles = append(candles, models.Candle{
+				Time:   time.Unix(a[0].Int(), 0),
+				Open:   helpers.ToDecimal(a[1]),
+				Close:  helpers.ToDecimal(a[2]),
+				High:   helpers.ToDecimal(a[3]),
+				Low:    helpers.ToDecimal(a[4]),
+				Volume: helpers.ToDecimal(a[5]),
+			})
+		}
+		return candles, nil
+	})
+}
+
+func (h *P2pb2bApi) RecentTrades(trading string, settlement string, limit int) ([]models.PublicTrade, error) {
+	limit = tradesLimit(limit, 100)
+	// lastId is mandatory, and the latest trades after it are returned
+	url := h.publicApiUrl("public/history?lastId=1&market=" + p2pb2bSymbols.Symbol(trading, settlement) +
+		"&limit=" + strconv.Itoa(limit))
+	byteArray, err := getBody(h.context(), h.HttpClient, url)
+	if err != nil {
+		return nil, err
+	}
+	var trades []models.PublicTrade
+	for _, v := range gjson.ParseBytes(byteArray).Get("result").Array() {
+		trades = append(trades, models.PublicTrade{
+			ID:     strconv.FormatInt(v.Get("id").Int(), 10),
+			Type:   takerSide(v.Get("type").Str),
+			Price:  helpers.ToDecimal(v.Get("price")),
+			Amount: helpers.ToDecimal(v.Get("amount")),
+			Time:   time.Unix(0, int64(v.Get("time").Float()*float64(time.Second))),
+		})
+	}
+	return latestTrades(trades, limit), nil
+}
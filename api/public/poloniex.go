@@ -1,17 +1,21 @@
 package public
 
 import (
+	"context"
 	"net/http"
 	"strconv"
-	"strings"
 	"sync"
 	"time"
 
 	"encoding/json"
 	"github.com/antonholmquist/jason"
 	"github.com/fxpgr/go-exchange-client/api/unified"
+	"github.com/fxpgr/go-exchange-client/apierrors"
+	"github.com/fxpgr/go-exchange-client/decimal"
+	"github.com/fxpgr/go-exchange-client/helpers"
 	"github.com/fxpgr/go-exchange-client/logger"
 	"github.com/fxpgr/go-exchange-client/models"
+	"github.com/fxpgr/go-exchange-client/symbols"
 	"github.com/pkg/errors"
 	"github.com/tidwall/gjson"
 	"io/ioutil"
@@ -22,6 +26,8 @@ const (
 	POLONIEX_BASE_URL = "https://poloniex.com"
 )
 
+var poloniexSymbols = symbols.For("poloniex")
+
 func NewPoloniexPublicApi() (*PoloniexApi, error) {
 	shrimpyApi, err := unified.NewShrimpyApi()
 	if err != nil {
@@ -34,7 +40,7 @@ func NewPoloniexPublicApi() (*PoloniexApi, error) {
 		volumeMap:         nil,
 		orderBookTickMap:  nil,
 		rateLastUpdated:   time.Date(1970, 1, 1, 0, 0, 0, 0, time.UTC),
-		HttpClient:        http.Client{},
+		HttpClient:        http.Client{Transport: newTransport("poloniex", nil)},
 		ShrimpyClient:     shrimpyApi,
 
 		m: new(sync.Mutex),
@@ -43,13 +49,11 @@ func NewPoloniexPublicApi() (*PoloniexApi, error) {
 }
 
 func parsePoloCurrencyPair(s string) (string, string, error) {
-	xs := strings.Split(s, "_")
-
-	if len(xs) != 2 {
+	pair, ok := poloniexSymbols.Pair(s)
+	if !ok {
 		return "", "", errors.New("invalid ticker title")
 	}
-
-	return xs[0], xs[1], nil
+	return pair.Settlement, pair.Trading, nil
 }
 
 type PoloniexApi struct {
@@ -61,16 +65,23 @@ type PoloniexApi struct {
 	precisionMap      map[string]map[string]models.Precisions
 	rateLastUpdated   time.Time
 	HttpClient        http.Client
-	ShrimpyClient     *unified.ShrimpyApiClient
+	clientContext
+	ShrimpyClient *unified.ShrimpyApiClient
 
 	m *sync.Mutex
 }
 
 func (p *PoloniexApi) SetTransport(transport http.RoundTripper) error {
-	p.HttpClient.Transport = transport
+	p.HttpClient.Transport = newTransport("poloniex", transport)
 	return nil
 }
 
+func (p *PoloniexApi) WithContext(ctx context.Context) PublicClient {
+	c := *p
+	c.ctx = ctx
+	return &c
+}
+
 func (p *PoloniexApi) publicApiUrl(command string) string {
 	return p.BaseURL + "/public?command=" + command
 }
@@ -81,7 +92,7 @@ func (p *PoloniexApi) fetchPrecision() error {
 	p.precisionMap = make(map[string]map[string]models.Precisions)
 	url := p.publicApiUrl("returnTicker")
 
-	resp, err := p.HttpClient.Get(url)
+	resp, err := httpGet(p.context(), &p.HttpClient, url)
 	if err != nil {
 		return errors.Wrapf(err, "failed to fetch %s", url)
 	}
@@ -119,7 +130,7 @@ func (p *PoloniexApi) fetchRate() error {
 	p.orderBookTickMap = make(map[string]map[string]models.OrderBookTick)
 	url := p.publicApiUrl("returnTicker")
 
-	resp, err := p.HttpClient.Get(url)
+	resp, err := httpGet(p.context(), &p.HttpClient, url)
 	if err != nil {
 		return errors.Wrapf(err, "failed to fetch %s", url)
 	}
@@ -182,7 +193,7 @@ func (p *PoloniexApi) fetchRate() error {
 		if err != nil {
 			return err
 		}
-		askf, err := strconv.ParseFloat(ask, 64)
+		askf, err := decimal.NewFromString(ask)
 		if err != nil {
 			return err
 		}
@@ -190,7 +201,7 @@ func (p *PoloniexApi) fetchRate() error {
 		if err != nil {
 			return err
 		}
-		bidf, err := strconv.ParseFloat(bid, 64)
+		bidf, err := decimal.NewFromString(bid)
 		if err != nil {
 			return err
 		}
@@ -249,9 +260,9 @@ func (p *PoloniexApi) Volume(trading string, settlement string) (float64, error)
 	}
 
 	if m, ok := p.volumeMap[trading]; !ok {
-		return 0, errors.New("trading volume not found")
+		return 0, errors.Wrap(apierrors.ErrInvalidSymbol, "trading volume not found")
 	} else if volume, ok := m[settlement]; !ok {
-		return 0, errors.New("settlement volume not found")
+		return 0, errors.Wrap(apierrors.ErrInvalidSymbol, "settlement volume not found")
 	} else {
 		return volume, nil
 	}
@@ -263,22 +274,38 @@ func (p *PoloniexApi) Precise(trading string, settlement string) (*models.Precis
 	}
 	p.fetchPrecision()
 	if m, ok := p.precisionMap[trading]; !ok {
-		return &models.Precisions{}, errors.Errorf("%s/%s", trading, settlement)
+		return &models.Precisions{}, errors.Wrapf(apierrors.ErrInvalidSymbol, "%s/%s", trading, settlement)
 	} else if precisions, ok := m[settlement]; !ok {
-		return &models.Precisions{}, errors.Errorf("%s/%s", trading, settlement)
+		return &models.Precisions{}, errors.Wrapf(apierrors.ErrInvalidSymbol, "%s/%s", trading, settlement)
 	} else {
 		return &precisions, nil
 	}
 }
 
+// poloniexMinNotional is the smallest total of an order, as poloniex
+// publishes no trading rules.
+var poloniexMinNotional = decimal.New(1, -4)
+
+func (p *PoloniexApi) Rules(trading string, settlement string) (*models.MarketRules, error) {
+	precisions, err := p.Precise(trading, settlement)
+	if err != nil {
+		return nil, err
+	}
+	rules := models.NewMarketRules(*precisions)
+	rules.MinNotional = poloniexMinNotional
+	return &rules, nil
+}
+
 func (h *PoloniexApi) fetchOrderBookTick() error {
-	boardMap, err := h.ShrimpyClient.GetBoards("poloniex")
+	boardMap, err := h.ShrimpyClient.WithContext(h.context()).GetBoards("poloniex")
 	if err != nil {
 		return err
 	}
 	orderBookTickMap := make(map[string]map[string]models.OrderBookTick)
 	for settlement, m := range boardMap {
+		settlement = poloniexSymbols.Currency(settlement)
 		for trading, value := range m {
+			trading = poloniexSymbols.Currency(trading)
 			l, ok := orderBookTickMap[trading]
 			if !ok {
 				l = make(map[string]models.OrderBookTick)
@@ -355,9 +382,9 @@ func (p *PoloniexApi) Rate(trading string, settlement string) (float64, error) {
 		p.rateLastUpdated = now
 	}
 	if m, ok := p.rateMap[trading]; !ok {
-		return 0, errors.New("trading rate not found")
+		return 0, errors.Wrap(apierrors.ErrInvalidSymbol, "trading rate not found")
 	} else if rate, ok := m[settlement]; !ok {
-		return 0, errors.New("settlement rate not found")
+		return 0, errors.Wrap(apierrors.ErrInvalidSymbol, "settlement rate not found")
 	} else {
 		return rate, nil
 	}
@@ -365,7 +392,7 @@ func (p *PoloniexApi) Rate(trading string, settlement string) (float64, error) {
 
 func (p *PoloniexApi) FrozenCurrency() ([]string, error) {
 	url := p.publicApiUrl("returnCurrencies")
-	resp, err := p.HttpClient.Get(url)
+	resp, err := httpGet(p.context(), &p.HttpClient, url)
 	if err != nil {
 		return nil, errors.Wrapf(err, "failed to fetch %s", url)
 	}
@@ -397,9 +424,9 @@ type Currency struct {
 
 func (p *PoloniexApi) Board(trading string, settlement string) (*models.Board, error) {
 	args := url2.Values{}
-	args.Add("currencyPair", settlement+"_"+trading)
+	args.Add("currencyPair", poloniexSymbols.Symbol(trading, settlement))
 	url := p.publicApiUrl("returnOrderBook") + "&" + args.Encode()
-	resp, err := p.HttpClient.Get(url)
+	resp, err := httpGet(p.context(), &p.HttpClient, url)
 	if err != nil {
 		return nil, errors.Wrapf(err, "failed to fetch %s", url)
 	}
@@ -431,17 +458,17 @@ func (p *PoloniexApi) Board(trading string, settlement string) (*models.Board, e
 		if err != nil {
 			return nil, errors.Wrapf(err, "failed to parse price")
 		}
-		price, err := strconv.ParseFloat(priceStr, 10)
+		price, err := decimal.NewFromString(priceStr)
 		if err != nil {
 			return nil, errors.Wrapf(err, "failed to parse price")
 		}
-		amount, err := s[1].Float64()
+		amount, err := s[1].Number()
 		if err != nil {
 			return nil, errors.Wrapf(err, "failed to parse price")
 		}
 		bids = append(bids, models.BoardBar{
 			Price:  price,
-			Amount: amount,
+			Amount: helpers.ToDecimal(amount),
 			Type:   models.Bid,
 		})
 	}
@@ -454,17 +481,17 @@ func (p *PoloniexApi) Board(trading string, settlement string) (*models.Board, e
 		if err != nil {
 			return nil, errors.Wrapf(err, "failed to parse price")
 		}
-		price, err := strconv.ParseFloat(priceStr, 10)
+		price, err := decimal.NewFromString(priceStr)
 		if err != nil {
 			return nil, errors.Wrapf(err, "failed to parse price")
 		}
-		amount, err := s[1].Float64()
+		amount, err := s[1].Number()
 		if err != nil {
 			return nil, errors.Wrapf(err, "failed to parse price")
 		}
 		asks = append(asks, models.BoardBar{
 			Price:  price,
-			Amount: amount,
+			Amount: helpers.ToDecimal(amount),
 			Type:   models.Ask,
 		})
 	}
@@ -475,3 +502,71 @@ func (p *PoloniexApi) Board(trading string, settlement string) (*models.Board, e
 	}
 	return board, nil
 }
+
+var poloniexIntervals = map[models.Interval]string{
+	models.Interval5m:  "300",
+	models.Interval15m: "900",
+	models.Interval30m: "1800",
+	models.Interval2h:  "7200",
+	models.Interval4h:  "14400",
+	models.Interval1d:  "86400",
+}
+
+const poloniexCandlesPageSize = 1000
+
+func (p *PoloniexApi) Candles(trading string, settlement string, interval models.Interval, since time.Time, limit int) ([]models.Candle, error) {
+	period, ok := poloniexIntervals[interval]
+	if !ok {
+		return nil, unsupportedInterval("poloniex", interval)
+	}
+	return pageCandles(interval, since, limit, poloniexCandlesPageSize, func(start time.Time) ([]models.Candle, error) {
+		end := start.Add(poloniexCandlesPageSize * interval.Duration())
+		url := p.publicApiUrl("returnChartData") + "&currencyPair=" + poloniexSymbols.Symbol(trading, settlement) + "&period=" + period +
+			"&start=" + strconv.FormatInt(start.Unix(), 10) + "&end=" + strconv.FormatInt(end.Unix(), 10)
+		byteArray, err := getBody(p.context(), &p.HttpClient, url)
+		if err != nil {
+			return nil, err
+		}
+		var candles []models.Candle
+		for _, v := range gjson.ParseBytes(byteArray).Array() {
+			// an empty range is answered with a single zero candle
+			if v.Get("date").Int() == 0 {
+				continue
+			}
+			candles = append(candles, models.Candle{
+				Time:   time.Unix(v.Get("date").Int(), 0),
+				Open:   helpers.ToDecimal(v.Get("open")),
+				High:   helpers.ToDecimal(v.Get("high")),
+				Low:    helpers.ToDecimal(v.Get("low")),
+				Close:  helpers.ToDecimal(v.Get("close")),
+				Volume: helpers.ToDecimal(v.Get("quoteVolume")),
+			})
+		}
+		return candles, nil
+	})
+}
+
+func (p *PoloniexApi) RecentTrades(trading string, settlement string, limit int) ([]models.PublicTrade, error) {
+	// the latest 200 trades are served without a time range
+	limit = tradesLimit(limit, 200)
+	url := p.publicApiUrl("returnTradeHistory") + "&currencyPair=" + poloniexSymbols.Symbol(trading, settlement)
+	byteArray, err := getBody(p.context(), &p.HttpClient, url)
+	if err != nil {
+		return nil, err
+	}
+	var trades []models.PublicTrade
+	for _, v := range gjson.ParseBytes(byteArray).Array() {
+		t, err := time.Parse("2006-01-02 15:04:05", v.Get("date").Str)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to parse trade time %s", v.Get("date").Str)
+		}
+		trades = append(trades, models.PublicTrade{
+			ID:     strconv.FormatInt(v.Get("tradeID").Int(), 10),
+			Type:   takerSide(v.Get("type").Str),
+			Price:  helpers.ToDecimal(v.Get("rate")),
+			Amount: helpers.ToDecimal(v.Get("amount")),
+			Time:   t,
+		})
+	}
+	return latestTrades(trades, limit), nil
+}
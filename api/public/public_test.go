@@ -1,12 +1,23 @@
 package public
 
 import (
+	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"context"
+	"errors"
 	"fmt"
+	"github.com/fxpgr/go-exchange-client/apierrors"
+	"github.com/fxpgr/go-exchange-client/decimal"
 	"github.com/fxpgr/go-exchange-client/models"
+	"github.com/gorilla/websocket"
 	"github.com/patrickmn/go-cache"
+	"hash/crc32"
 	"io/ioutil"
 	"math"
 	"net/http"
+	"net/http/httptest"
+	"strconv"
 	"strings"
 	"sync"
 	"testing"
@@ -46,7 +57,7 @@ func TestNewClient(t *testing.T) {
 	}
 }
 
-func newTestPoloniexPublicClient(rt http.RoundTripper) PublicClient {
+func newTestPoloniexPublicClient(rt http.RoundTripper) *PoloniexApi {
 	endpoint := "http://localhost:4243"
 	api := &PoloniexApi{
 		BaseURL:           endpoint,
@@ -59,7 +70,7 @@ func newTestPoloniexPublicClient(rt http.RoundTripper) PublicClient {
 	}
 	return api
 }
-func newTestHitbtcPublicClient(rt http.RoundTripper) PublicClient {
+func newTestHitbtcPublicClient(rt http.RoundTripper) *HitbtcApi {
 	endpoint := "http://localhost:4243"
 	api := &HitbtcApi{
 		BaseURL:           endpoint,
@@ -75,7 +86,7 @@ func newTestHitbtcPublicClient(rt http.RoundTripper) PublicClient {
 	return api
 }
 
-func newTestLbankPublicClient(rt http.RoundTripper) PublicClient {
+func newTestLbankPublicClient(rt http.RoundTripper) *LbankApi {
 	endpoint := "http://localhost:4243"
 	api := &LbankApi{
 		BaseURL:           endpoint,
@@ -93,7 +104,7 @@ func newTestLbankPublicClient(rt http.RoundTripper) PublicClient {
 	return api
 }
 
-func newTestKucoinPublicClient(rt http.RoundTripper) PublicClient {
+func newTestKucoinPublicClient(rt http.RoundTripper) *KucoinApi {
 	endpoint := "http://localhost:4243"
 	api := &KucoinApi{
 		BaseURL:           endpoint,
@@ -111,7 +122,7 @@ func newTestKucoinPublicClient(rt http.RoundTripper) PublicClient {
 	return api
 }
 
-func newTestBinancePublicClient(rt http.RoundTripper) PublicClient {
+func newTestBinancePublicClient(rt http.RoundTripper) *BinanceApi {
 	endpoint := "http://localhost:4243"
 	currencyPairs := make([]models.CurrencyPair, 0)
 	currencyPairs = append(currencyPairs, models.CurrencyPair{Trading: "BNB", Settlement: "BTC"})
@@ -131,7 +142,7 @@ func newTestBinancePublicClient(rt http.RoundTripper) PublicClient {
 	return api
 }
 
-func newTestHuobiPublicClient(rt http.RoundTripper) PublicClient {
+func newTestHuobiPublicClient(rt http.RoundTripper) *HuobiApi {
 	endpoint := "http://localhost:4243"
 	n := make(map[string]float64)
 	n["BTC"] = 0.1
@@ -158,7 +169,7 @@ func newTestHuobiPublicClient(rt http.RoundTripper) PublicClient {
 	return api
 }
 
-func newTestBitflyerPublicClient(rt http.RoundTripper) PublicClient {
+func newTestBitflyerPublicClient(rt http.RoundTripper) *BitflyerApi {
 	endpoint := "http://localhost:4243"
 	api := &BitflyerApi{
 		BaseURL:           endpoint,
@@ -478,6 +489,20 @@ func TestHuobiCurrencyPairs(t *testing.T) {
 	}
 }
 
+func TestHuobiRules(t *testing.T) {
+	jsonSymbol := `{"status":"ok","data":[{"base-currency":"eos","quote-currency":"eth","price-precision":8,"amount-precision":2,"symbol-partition":"main","min-order-amt":0.1,"max-order-amt":5000000,"min-order-value":0.001}]}`
+	fakeRoundTripper := &FakeRoundTripper{message: jsonSymbol, status: http.StatusOK}
+	client := newTestHuobiPublicClient(fakeRoundTripper)
+	rules, err := client.Rules("EOS", "ETH")
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := "{TickSize:0.00000001 StepSize:0.01 MinAmount:0.1 MaxAmount:5000000 MinNotional:0.001 MinPrice:0 MaxPrice:0}"
+	if fmt.Sprintf("%+v", *rules) != expected {
+		t.Errorf("HuobiPublicApi: Expected %+v. Got %+v", expected, *rules)
+	}
+}
+
 func TestHuobiFrozenCurrency(t *testing.T) {
 	jsonSymbol := `{"status":"ok","data":[{"name":"ela","display-name":"ELA","withdraw-precision":8,"currency-type":"eth","currency-partition":"pro","otc-enable":0,"deposit-min-amount":"0.1","withdraw-min-amount":"0.2","show-precision":"8","weight":"4995","visible":true,"deposit-desc":"","withdraw-desc":"","deposit-enabled":true,"withdraw-enabled":true,"currency-addr-with-tag":false,"fast-confirms":16,"safe-confirms":16},{"name":"bcx","display-name":"BCX","withdraw-precision":8,"currency-type":"eth","currency-partition":"pro","otc-enable":0,"deposit-min-amount":"1","withdraw-min-amount":"2","show-precision":"4","weight":"3000","visible":true,"deposit-desc":"","withdraw-desc":"","deposit-enabled":false,"withdraw-enabled":false,"currency-addr-with-tag":false,"fast-confirms":6,"safe-confirms":6},{"name":"sbtc","display-name":"SBTC","withdraw-precision":8,"currency-type":"eth","currency-partition":"pro","otc-enable":0,"deposit-min-amount":"0.001","withdraw-min-amount":"0.001","show-precision":"4","weight":"2999","visible":true,"deposit-desc":"","withdraw-desc":"","deposit-enabled":false,"withdraw-enabled":false,"currency-addr-with-tag":false,"fast-confirms":6,"safe-confirms":6},{"name":"etf","display-name":"ETF","withdraw-precision":8,"currency-type":"eth","currency-partition":"pro","otc-enable":0,"deposit-min-amount":"1","withdraw-min-amount":"1","show-precision":"8","weight":"2998","visible":true,"deposit-desc":"","withdraw-desc":"","deposit-enabled":false,"withdraw-enabled":false,"currency-addr-with-tag":false,"fast-confirms":6,"safe-confirms":6},{"name":"abt","display-name":"ABT","withdraw-precision":8,"currency-type":"eth","currency-partition":"pro","otc-enable":0,"deposit-min-amount":"2","withdraw-min-amount":"4","show-precision":"8","weight":"2989","visible":true,"deposit-desc":"","withdraw-desc":"","deposit-enabled":true,"withdraw-enabled":true,"currency-addr-with-tag":false,"fast-confirms":15,"safe-confirms":30},{"name":"ont","display-name":"ONT","withdraw-precision":8,"currency-type":"eth","currency-partition":"pro","otc-enable":0,"deposit-min-amount":"0.02","withdraw-min-amount":"0.04","show-precision":"8","weight":"2988","visible":true,"deposit-desc":"","withdraw-desc":"","deposit-enabled":true,"withdraw-enabled":false,"currency-addr-with-tag":false,"fast-confirms":1,"safe-confirms":1},{"name":"bt1","display-name":"BT1","withdraw-precision":8,"currency-type":"btc","currency-partition":"pro","otc-enable":0,"deposit-min-amount":"0.01","withdraw-min-amount":"0.01","show-precision":"4","weight":"1","visible":true,"deposit-desc":"","withdraw-desc":"","deposit-enabled":false,"withdraw-enabled":false,"currency-addr-with-tag":false,"fast-confirms":6,"safe-confirms":6}]}`
 	fakeRoundTripper := &FakeRoundTripper{message: jsonSymbol, status: http.StatusOK}
@@ -623,6 +648,89 @@ func TestBinanceCurrencyPairs(t *testing.T) {
 	}
 }
 
+func TestBinanceRules(t *testing.T) {
+	jsonExchangeInfo := `{"timezone":"UTC","serverTime":1508631584636,"symbols":[{"symbol":"ETHBTC","status":"TRADING","baseAsset":"ETH","baseAssetPrecision":8,"quoteAsset":"BTC","quotePrecision":8,"orderTypes":["LIMIT","MARKET"],"filters":[{"filterType":"PRICE_FILTER","minPrice":"0.00000100","maxPrice":"100000.00000000","tickSize":"0.00000100"},{"filterType":"LOT_SIZE","minQty":"0.00100000","maxQty":"100000.00000000","stepSize":"0.00100000"},{"filterType":"MIN_NOTIONAL","minNotional":"0.00100000"}]}]}`
+	fakeRoundTripper := &FakeRoundTripper{message: jsonExchangeInfo, status: http.StatusOK}
+	client := newTestBinancePublicClient(fakeRoundTripper)
+	rules, err := client.Rules("ETH", "BTC")
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := "{TickSize:0.000001 StepSize:0.001 MinAmount:0.001 MaxAmount:100000 MinNotional:0.001 MinPrice:0.000001 MaxPrice:100000}"
+	if fmt.Sprintf("%+v", *rules) != expected {
+		t.Errorf("BinancePublicApi: Expected %+v. Got %+v", expected, *rules)
+	}
+	precisions, err := client.Precise("ETH", "BTC")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if precisions.PricePrecision != 6 || precisions.AmountPrecision != 3 {
+		t.Errorf("BinancePublicApi: unexpected precisions %+v", precisions)
+	}
+	if _, err := client.Rules("XRP", "BTC"); err == nil {
+		t.Error("BinancePublicApi: Expected an invalid symbol")
+	}
+}
+
+func TestBinanceRulesRetry(t *testing.T) {
+	jsonExchangeInfo := `{"timezone":"UTC","serverTime":1508631584636,"symbols":[{"symbol":"ETHBTC","status":"TRADING","baseAsset":"ETH","baseAssetPrecision":8,"quoteAsset":"BTC","quotePrecision":8,"orderTypes":["LIMIT","MARKET"],"filters":[{"filterType":"PRICE_FILTER","minPrice":"0.00000100","maxPrice":"100000.00000000","tickSize":"0.00000100"},{"filterType":"LOT_SIZE","minQty":"0.00100000","maxQty":"100000.00000000","stepSize":"0.00100000"},{"filterType":"MIN_NOTIONAL","minNotional":"0.00100000"}]}]}`
+	fakeRoundTripper := &FakeRoundTripper{message: `{"code":-1001,"msg":"Internal error; unable to process your request. Please try again."}`, status: http.StatusInternalServerError}
+	client := newTestBinancePublicClient(fakeRoundTripper)
+	_, err := client.Rules("ETH", "BTC")
+	if err == nil || errors.Is(err, apierrors.ErrInvalidSymbol) {
+		t.Errorf("BinancePublicApi: Expected the fetch error. Got %v", err)
+	}
+
+	// the exchange info is fetched again after a failure
+	fakeRoundTripper.message = jsonExchangeInfo
+	fakeRoundTripper.status = http.StatusOK
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := client.Rules("ETH", "BTC"); err != nil {
+				t.Error(err)
+			}
+			if _, err := client.Precise("ETH", "BTC"); err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+func TestHuobiRulesRetry(t *testing.T) {
+	jsonSymbols := `{"status":"ok","data":[{"base-currency":"eth","quote-currency":"btc","price-precision":6,"amount-precision":4,"symbol-partition":"main","min-order-amt":"0.001","max-order-amt":"10000","min-order-value":"0.0001"}]}`
+	fakeRoundTripper := &FakeRoundTripper{message: `{"status":"error","err-code":"base-system-error","err-msg":"system error"}`, status: http.StatusInternalServerError}
+	client := newTestHuobiPublicClient(fakeRoundTripper)
+	_, err := client.Rules("ETH", "BTC")
+	if err == nil || errors.Is(err, apierrors.ErrInvalidSymbol) {
+		t.Errorf("HuobiPublicApi: Expected the fetch error. Got %v", err)
+	}
+
+	// the symbols are fetched again after a failure
+	fakeRoundTripper.message = jsonSymbols
+	fakeRoundTripper.status = http.StatusOK
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			rules, err := client.Rules("ETH", "BTC")
+			if err != nil {
+				t.Error(err)
+			} else if !rules.MinAmount.Equal(d("0.001")) {
+				t.Errorf("HuobiPublicApi: Expected min amount %v. Got %v", "0.001", rules.MinAmount)
+			}
+			if _, err := client.Precise("ETH", "BTC"); err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+	wg.Wait()
+}
+
 func TestBinanceBoard(t *testing.T) {
 	jsonBoard := `{"lastUpdateId":325276434,"bids":[["0.03439800","2.49700000",[]],["0.03439700","1.49100000",[]],["0.03439100","1.74300000",[]],["0.03439000","13.63400000",[]],["0.03438700","0.31400000",[]],["0.03438500","45.45000000",[]],["0.03438000","22.14900000",[]],["0.03437900","0.06300000",[]],["0.03437600","0.06300000",[]],["0.03437400","0.06300000",[]],["0.03437300","0.48100000",[]],["0.03436900","0.42800000",[]],["0.03436800","0.06300000",[]],["0.03435000","1.00000000",[]],["0.03433900","0.06300000",[]],["0.03433300","4.04600000",[]],["0.03433200","0.10000000",[]],["0.03433100","0.06300000",[]],["0.03432900","9.46000000",[]],["0.03431400","7.10000000",[]],["0.03430400","54.16900000",[]],["0.03430300","19.09700000",[]],["0.03430200","1.84000000",[]],["0.03430100","44.40000000",[]],["0.03430000","29.63000000",[]],["0.03429800","0.29300000",[]],["0.03429400","0.87500000",[]],["0.03429300","3.42300000",[]],["0.03429200","0.14600000",[]],["0.03429000","0.05000000",[]],["0.03428900","0.20000000",[]],["0.03428500","1.89100000",[]],["0.03428000","0.05000000",[]],["0.03427800","0.04000000",[]],["0.03427600","0.50000000",[]],["0.03427500","0.58200000",[]],["0.03427300","0.15400000",[]],["0.03427000","0.92300000",[]],["0.03426900","12.34300000",[]],["0.03426700","8.75400000",[]],["0.03426600","27.76400000",[]],["0.03426500","0.90000000",[]],["0.03426300","0.10000000",[]],["0.03426200","0.16000000",[]],["0.03426000","1.88800000",[]],["0.03425800","0.16900000",[]],["0.03425500","2.50000000",[]],["0.03425200","2.07100000",[]],["0.03425000","22.85000000",[]],["0.03424300","0.05000000",[]],["0.03424200","0.25000000",[]],["0.03424000","0.05000000",[]],["0.03423700","18.00000000",[]],["0.03423300","2.27500000",[]],["0.03423100","0.07000000",[]],["0.03423000","0.05000000",[]],["0.03422600","1.46200000",[]],["0.03422400","0.04400000",[]],["0.03422000","0.05000000",[]],["0.03421700","0.03400000",[]],["0.03421600","0.10000000",[]],["0.03421400","0.10400000",[]],["0.03421300","23.76200000",[]],["0.03421200","41.89800000",[]],["0.03421100","40.03600000",[]],["0.03421000","0.10900000",[]],["0.03420600","1.46200000",[]],["0.03420400","14.00000000",[]],["0.03420200","2.00000000",[]],["0.03420100","0.12200000",[]],["0.03420000","93.78100000",[]],["0.03419900","0.07400000",[]],["0.03419700","1.50400000",[]],["0.03419300","0.10000000",[]],["0.03419100","0.05000000",[]],["0.03419000","0.12000000",[]],["0.03418900","0.54400000",[]],["0.03418800","0.08200000",[]],["0.03418200","1.89100000",[]],["0.03418000","0.05000000",[]],["0.03417900","0.03300000",[]],["0.03417800","13.34300000",[]],["0.03417700","64.10000000",[]],["0.03417600","0.04300000",[]],["0.03417400","150.00000000",[]],["0.03417000","28.33600000",[]],["0.03416300","0.07000000",[]],["0.03416200","0.10000000",[]],["0.03416000","1.80700000",[]],["0.03415900","0.15300000",[]],["0.03415700","0.10000000",[]],["0.03415400","0.09600000",[]],["0.03415000","0.05000000",[]],["0.03414900","0.03000000",[]],["0.03414400","3.00000000",[]],["0.03414300","5.31200000",[]],["0.03414000","0.05000000",[]],["0.03413500","2.42000000",[]],["0.03413400","0.03000000",[]],["0.03413300","1.00000000",[]]],"asks":[["0.03443300","0.74500000",[]],["0.03443400","6.26900000",[]],["0.03444100","0.20000000",[]],["0.03444400","11.87700000",[]],["0.03444500","7.00000000",[]],["0.03445000","0.04200000",[]],["0.03445200","10.59800000",[]],["0.03445300","0.04200000",[]],["0.03446600","4.39900000",[]],["0.03446800","16.00000000",[]],["0.03447100","0.04200000",[]],["0.03447300","3.13800000",[]],["0.03447700","55.12400000",[]],["0.03447800","9.35200000",[]],["0.03448000","2.12100000",[]],["0.03448100","3.10600000",[]],["0.03448200","1.71400000",[]],["0.03448400","1.33000000",[]],["0.03448900","2.55900000",[]],["0.03449000","27.50000000",[]],["0.03449700","19.00000000",[]],["0.03449800","1.29900000",[]],["0.03449900","2.00000000",[]],["0.03450000","104.41600000",[]],["0.03450600","0.10000000",[]],["0.03451200","0.22500000",[]],["0.03451300","0.43600000",[]],["0.03451700","2.00000000",[]],["0.03451900","0.07300000",[]],["0.03452000","142.90000000",[]],["0.03452100","0.10000000",[]],["0.03452400","20.00000000",[]],["0.03452500","0.40800000",[]],["0.03452800","2.20300000",[]],["0.03452900","65.70000000",[]],["0.03453000","3.31400000",[]],["0.03454100","0.99800000",[]],["0.03454400","0.10000000",[]],["0.03454600","0.04200000",[]],["0.03454700","0.22500000",[]],["0.03455000","23.31500000",[]],["0.03455400","0.19300000",[]],["0.03455600","5.97900000",[]],["0.03455800","0.05000000",[]],["0.03456700","0.25000000",[]],["0.03457200","1.98400000",[]],["0.03457400","0.35900000",[]],["0.03457500","1.39700000",[]],["0.03457900","0.25800000",[]],["0.03458000","0.06000000",[]],["0.03458100","0.05800000",[]],["0.03458300","0.09200000",[]],["0.03458600","0.84500000",[]],["0.03458700","0.21500000",[]],["0.03459000","3.06600000",[]],["0.03459400","0.03600000",[]],["0.03459500","22.11300000",[]],["0.03459600","0.10100000",[]],["0.03459800","0.06000000",[]],["0.03460000","9.22500000",[]],["0.03460100","0.08800000",[]],["0.03460200","0.15000000",[]],["0.03460300","0.50000000",[]],["0.03460600","0.03200000",[]],["0.03460700","1.35900000",[]],["0.03460800","0.03600000",[]],["0.03460900","0.64700000",[]],["0.03461300","7.01300000",[]],["0.03461600","7.64900000",[]],["0.03461800","0.11600000",[]],["0.03461900","0.24600000",[]],["0.03462000","39.05600000",[]],["0.03462100","0.81100000",[]],["0.03462300","0.10000000",[]],["0.03462400","2.99600000",[]],["0.03462700","0.99900000",[]],["0.03462800","0.05000000",[]],["0.03463100","0.02900000",[]],["0.03463400","0.03000000",[]],["0.03463500","0.07100000",[]],["0.03463600","0.17100000",[]],["0.03464000","1.76400000",[]],["0.03464100","0.14600000",[]],["0.03464200","0.29300000",[]],["0.03464300","0.24400000",[]],["0.03464500","0.87900000",[]],["0.03464600","0.70000000",[]],["0.03464700","0.36100000",[]],["0.03464900","0.40100000",[]],["0.03465000","16.44900000",[]],["0.03465100","0.08900000",[]],["0.03465300","0.14800000",[]],["0.03465500","0.88400000",[]],["0.03465600","2.04900000",[]],["0.03465700","0.14600000",[]],["0.03466000","0.05800000",[]],["0.03466100","0.57800000",[]],["0.03466200","0.11600000",[]],["0.03466400","0.17700000",[]],["0.03466500","0.03600000",[]]]}`
 	fakeRoundTripper := &FakeRoundTripper{message: jsonBoard, status: http.StatusOK}
@@ -660,3 +768,578 @@ func TestBinanceVolume(t *testing.T) {
 		t.Errorf("LbankPublicApi: Expected %v. Got %v", 3913.7508371, vol)
 	}
 }
+
+func TestBinanceCandles(t *testing.T) {
+	jsonKlines := `[[1499040000000,"0.01634790","0.80000000","0.01575800","0.01577100","148976.11427815",1499644799999,"2434.19055334",308,"1756.87402397","28.46694368","0"],[1499040060000,"0.01577100","0.01600000","0.01570000","0.01590000","1200.00000000",1499644859999,"19.00000000",12,"600.00000000","9.50000000","0"]]`
+	client := newTestBinancePublicClient(&FakeRoundTripper{message: jsonKlines, status: http.StatusOK})
+	since := time.Unix(1499040000, 0)
+	candles, err := client.Candles("LTC", "BTC", models.Interval1m, since, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(candles) != 2 {
+		t.Fatalf("BinancePublicApi: Expected %v candles. Got %v", 2, len(candles))
+	}
+	if !candles[1].Time.Equal(since.Add(time.Minute)) || candles[1].Close.String() != "0.0159" || candles[0].Volume.String() != "148976.11427815" {
+		t.Errorf("BinancePublicApi: unexpected candles %+v", candles)
+	}
+	candles, err = client.Candles("LTC", "BTC", models.Interval1m, since, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(candles) != 1 {
+		t.Errorf("BinancePublicApi: Expected %v candles. Got %v", 1, len(candles))
+	}
+	if _, err := client.Candles("LTC", "BTC", models.Interval(100), since, 1); err == nil {
+		t.Error("BinancePublicApi: unknown interval must fail")
+	}
+}
+
+func TestHuobiRecentTrades(t *testing.T) {
+	jsonTrades := `{"status":"ok","ch":"market.ethbtc.trade.detail","ts":1550000000500,"data":[{"id":31618787514,"ts":1550000000300,"data":[{"amount":0.5,"ts":1550000000300,"id":10245963207126327849872,"trade-id":100050305348,"price":0.0334,"direction":"sell"},{"amount":1.5,"ts":1550000000300,"id":10245963207126327849873,"trade-id":100050305349,"price":0.0333,"direction":"sell"}]},{"id":31618787513,"ts":1550000000100,"data":[{"amount":2,"ts":1550000000100,"id":10245963207126327849871,"price":0.0335,"direction":"buy"}]}]}`
+	client := newTestHuobiPublicClient(&FakeRoundTripper{message: jsonTrades, status: http.StatusOK})
+	trades, err := client.RecentTrades("ETH", "BTC", 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(trades) != 2 {
+		t.Fatalf("HuobiPublicApi: Expected %v trades. Got %v", 2, len(trades))
+	}
+	if trades[0].ID != "100050305348" || trades[1].ID != "100050305349" || trades[0].Type != models.Bid || trades[0].Price.String() != "0.0334" {
+		t.Errorf("HuobiPublicApi: unexpected trades %+v", trades)
+	}
+	trades, err = client.RecentTrades("ETH", "BTC", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the ids of trades without a trade-id are kept although they overflow
+	// an int64
+	if len(trades) != 3 || trades[0].ID != "10245963207126327849871" || trades[0].Type != models.Ask || trades[0].Amount.String() != "2" {
+		t.Errorf("HuobiPublicApi: unexpected trades %+v", trades)
+	}
+}
+
+// newTestStreamServer serves every WebSocket connection with serve, passing
+// the number of the connection, and returns its ws:// URL.
+func newTestStreamServer(t *testing.T, serve func(conn *websocket.Conn, n int)) (string, func()) {
+	var upgrader websocket.Upgrader
+	var n int
+	var m sync.Mutex
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		defer conn.Close()
+		m.Lock()
+		n++
+		i := n
+		m.Unlock()
+		serve(conn, i)
+	}))
+	return "ws" + strings.TrimPrefix(srv.URL, "http"), srv.Close
+}
+
+// waitBoard returns the first board received from ch which ok accepts.
+func waitBoard(t *testing.T, ch <-chan *models.Board, ok func(b *models.Board) bool) *models.Board {
+	timeout := time.After(5 * time.Second)
+	for {
+		select {
+		case b, open := <-ch:
+			if !open {
+				t.Fatal("board channel closed")
+			}
+			if ok(b) {
+				return b
+			}
+		case <-timeout:
+			t.Fatal("timed out waiting for board")
+		}
+	}
+}
+
+func TestBinanceStream(t *testing.T) {
+	jsonDepth := `{"lastUpdateId":100,"bids":[["0.02990000","10.00000000"]],"asks":[["0.03010000","5.00000000"]]}`
+	client := newTestBinancePublicClient(&FakeRoundTripper{message: jsonDepth, status: http.StatusOK})
+	subscribed := make(chan string, 2)
+	url, closeServer := newTestStreamServer(t, func(conn *websocket.Conn, n int) {
+		_, msg, err := conn.ReadMessage()
+		if err != nil {
+			return
+		}
+		subscribed <- string(msg)
+		events := []string{
+			`{"e":"depthUpdate","s":"ETHBTC","U":90,"u":95,"b":[["0.02980000","1.00000000"]],"a":[]}`,
+			`{"e":"depthUpdate","s":"ETHBTC","U":96,"u":101,"b":[["0.02990000","8.00000000"]],"a":[["0.03020000","1.00000000"]]}`,
+			`{"e":"depthUpdate","s":"ETHBTC","U":102,"u":102,"b":[],"a":[["0.03010000","0.00000000"]]}`,
+		}
+		if n > 1 {
+			events = []string{`{"e":"depthUpdate","s":"ETHBTC","U":101,"u":103,"b":[["0.02970000","3.00000000"]],"a":[]}`}
+		}
+		conn.WriteMessage(websocket.TextMessage, []byte(`{"result":null,"id":1}`))
+		for _, e := range events {
+			conn.WriteMessage(websocket.TextMessage, []byte(`{"stream":"ethbtc@depth@100ms","data":`+e+`}`))
+		}
+		if n > 1 {
+			conn.ReadMessage()
+		}
+	})
+	defer closeServer()
+
+	s := NewBinanceStream(client)
+	s.Conn.URL = url
+	s.Conn.MinBackoff = 10 * time.Millisecond
+	defer s.Close()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	ch, err := s.SubscribeBoard(ctx, "ETH", "BTC")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if msg := <-subscribed; !strings.Contains(msg, `"SUBSCRIBE"`) || !strings.Contains(msg, "ethbtc@depth@100ms") {
+		t.Errorf("BinanceStream: unexpected subscription %s", msg)
+	}
+	board := waitBoard(t, ch, func(b *models.Board) bool { return len(b.Asks) == 1 && b.Asks[0].Price.String() == "0.0302" })
+	if len(board.Bids) != 1 || board.Bids[0].Amount.String() != "8" {
+		t.Errorf("BinanceStream: unexpected board %+v", board)
+	}
+
+	// the server dropped the first connection, so the board is loaded again
+	if msg := <-subscribed; !strings.Contains(msg, "ethbtc@depth@100ms") {
+		t.Errorf("BinanceStream: unexpected subscription %s", msg)
+	}
+	board = waitBoard(t, ch, func(b *models.Board) bool { return len(b.Bids) == 2 })
+	if board.Bids[1].Price.String() != "0.0297" || board.Asks[0].Price.String() != "0.0301" {
+		t.Errorf("BinanceStream: unexpected board %+v", board)
+	}
+	cancel()
+	for range ch {
+	}
+}
+
+// readUntil reads from conn until a message containing each of want was read.
+func readUntil(conn *websocket.Conn, decode func([]byte) string, want ...string) bool {
+	seen := make(map[string]bool)
+	for len(seen) < len(want) {
+		_, msg, err := conn.ReadMessage()
+		if err != nil {
+			return false
+		}
+		for _, w := range want {
+			if strings.Contains(decode(msg), w) {
+				seen[w] = true
+			}
+		}
+	}
+	return true
+}
+
+func TestHuobiStream(t *testing.T) {
+	gzipped := func(msg string) []byte {
+		var buf bytes.Buffer
+		w := gzip.NewWriter(&buf)
+		w.Write([]byte(msg))
+		w.Close()
+		return buf.Bytes()
+	}
+	topics := []string{"market.ethbtc.depth.step0", "market.ethbtc.bbo", "market.ethbtc.trade.detail"}
+	url, closeServer := newTestStreamServer(t, func(conn *websocket.Conn, n int) {
+		conn.WriteMessage(websocket.BinaryMessage, gzipped(`{"ping":1492420473027}`))
+		if !readUntil(conn, func(msg []byte) string { return string(msg) }, append(topics, `{"pong":1492420473027}`)...) {
+			return
+		}
+		messages := []string{
+			`{"ch":"market.ethbtc.bbo","ts":1489474082831,"tick":{"symbol":"ethbtc","bid":0.0299,"bidSize":10,"ask":0.0301,"askSize":5}}`,
+			`{"ch":"market.ethbtc.trade.detail","ts":1489474082831,"tick":{"id":1,"ts":1489474082831,"data":[{"id":10245963207126327849872,"tradeId":100050305348,"ts":1489474082831,"amount":0.5,"price":0.03,"direction":"sell"}]}}`,
+			`{"ch":"market.ethbtc.depth.step0","ts":1489474082831,"tick":{"bids":[[0.0299,10],[0.0298,1]],"asks":[[0.0301,5]]}}`,
+		}
+		if n > 1 {
+			messages = []string{`{"ch":"market.ethbtc.depth.step0","ts":1489474082832,"tick":{"bids":[[0.0297,3]],"asks":[[0.0302,2]]}}`}
+		}
+		for _, msg := range messages {
+			conn.WriteMessage(websocket.BinaryMessage, gzipped(msg))
+		}
+		if n > 1 {
+			conn.ReadMessage()
+		}
+	})
+	defer closeServer()
+
+	s := NewHuobiStream()
+	s.Conn.URL = url
+	s.Conn.MinBackoff = 10 * time.Millisecond
+	defer s.Close()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	boards, err := s.SubscribeBoard(ctx, "ETH", "BTC")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ticks, err := s.SubscribeTicks(ctx, "ETH", "BTC")
+	if err != nil {
+		t.Fatal(err)
+	}
+	trades, err := s.SubscribeTrades(ctx, "ETH", "BTC")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.SubscribeBoard(ctx, "BTC", "BTC"); err == nil {
+		t.Error("HuobiStream: expected an error subscribing to a pair of one currency")
+	}
+
+	tick := <-ticks
+	if tick.BestBidPrice.String() != "0.0299" || tick.BestAskAmount.String() != "5" {
+		t.Errorf("HuobiStream: unexpected tick %+v", tick)
+	}
+	trade := <-trades
+	if trade.ID != "100050305348" || trade.Type != models.Bid || trade.Price.String() != "0.03" || trade.Amount.String() != "0.5" {
+		t.Errorf("HuobiStream: unexpected trade %+v", trade)
+	}
+	board := waitBoard(t, boards, func(b *models.Board) bool { return len(b.Bids) == 2 })
+	if board.Bids[0].Price.String() != "0.0299" || board.Asks[0].Amount.String() != "5" {
+		t.Errorf("HuobiStream: unexpected board %+v", board)
+	}
+
+	// the server dropped the first connection, so the topics are subscribed
+	// to again and the board replaced
+	board = waitBoard(t, boards, func(b *models.Board) bool { return len(b.Bids) == 1 })
+	if board.Bids[0].Price.String() != "0.0297" || board.Asks[0].Price.String() != "0.0302" {
+		t.Errorf("HuobiStream: unexpected board %+v", board)
+	}
+	cancel()
+	for range boards {
+	}
+}
+
+func TestOkexStream(t *testing.T) {
+	deflated := func(msg string) []byte {
+		var buf bytes.Buffer
+		w, _ := flate.NewWriter(&buf, flate.DefaultCompression)
+		w.Write([]byte(msg))
+		w.Close()
+		return buf.Bytes()
+	}
+	checksum := func(levels string) string {
+		return strconv.Itoa(int(int32(crc32.ChecksumIEEE([]byte(levels)))))
+	}
+	depth := func(action string, bids string, asks string, sum string) []byte {
+		return deflated(`{"table":"spot/depth","action":"` + action + `","data":[{"instrument_id":"ETH-BTC","asks":` + asks +
+			`,"bids":` + bids + `,"timestamp":"2019-05-06T07:19:39.348Z","checksum":` + sum + `}]}`)
+	}
+	pinged := make(chan struct{}, 1)
+	pong := func(conn *websocket.Conn) func([]byte) string {
+		return func(msg []byte) string {
+			if string(msg) == "ping" {
+				select {
+				case pinged <- struct{}{}:
+				default:
+				}
+				conn.WriteMessage(websocket.BinaryMessage, deflated("pong"))
+			}
+			return string(msg)
+		}
+	}
+	url, closeServer := newTestStreamServer(t, func(conn *websocket.Conn, n int) {
+		if !readUntil(conn, pong(conn), "spot/depth:ETH-BTC", "spot/ticker:ETH-BTC", "spot/trade:ETH-BTC") {
+			return
+		}
+		if n > 1 {
+			conn.WriteMessage(websocket.BinaryMessage, depth("partial",
+				`[["0.02970000","3.0","1"]]`, `[["0.03020000","2.0","1"]]`, checksum("0.02970000:3.0:0.03020000:2.0")))
+			// keep the connection open, answering pings
+			readUntil(conn, pong(conn), "close")
+			return
+		}
+		conn.WriteMessage(websocket.BinaryMessage, deflated(`{"event":"subscribe","channel":"spot/depth:ETH-BTC"}`))
+		conn.WriteMessage(websocket.BinaryMessage, deflated(`{"table":"spot/ticker","data":[{"instrument_id":"ETH-BTC","last":"0.03","best_bid":"0.0299","best_ask":"0.0301","best_bid_size":"10","best_ask_size":"5"}]}`))
+		conn.WriteMessage(websocket.BinaryMessage, deflated(`{"table":"spot/trade","data":[{"instrument_id":"ETH-BTC","price":"0.03","side":"buy","size":"0.5","timestamp":"2019-05-06T07:19:37.496Z","trade_id":"1234"}]}`))
+		conn.WriteMessage(websocket.BinaryMessage, depth("partial",
+			`[["0.02990000","10.0","1"],["0.02980000","1.0","1"]]`, `[["0.03010000","5.0","1"]]`,
+			checksum("0.02990000:10.0:0.03010000:5.0:0.02980000:1.0")))
+		conn.WriteMessage(websocket.BinaryMessage, depth("update",
+			`[["0.02980000","0","0"]]`, `[["0.03000000","1.5","1"]]`,
+			checksum("0.02990000:10.0:0.03000000:1.5:0.03010000:5.0")))
+		// a corrupted update is dropped and the channel subscribed to again
+		conn.WriteMessage(websocket.BinaryMessage, depth("update", `[]`, `[["0.03000000","2.5","1"]]`, "0"))
+		readUntil(conn, func(msg []byte) string { return string(msg) }, `"unsubscribe"`)
+		readUntil(conn, func(msg []byte) string { return string(msg) }, `"subscribe"`)
+		conn.WriteMessage(websocket.BinaryMessage, depth("partial",
+			`[["0.02990000","10.0","1"]]`, `[["0.03000000","2.5","1"]]`, checksum("0.02990000:10.0:0.03000000:2.5")))
+	})
+	defer closeServer()
+
+	s := NewOkexStream()
+	s.Conn.URL = url
+	s.Conn.MinBackoff = 10 * time.Millisecond
+	s.Conn.PingInterval = 10 * time.Millisecond
+	defer s.Close()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	boards, err := s.SubscribeBoard(ctx, "ETH", "BTC")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ticks, err := s.SubscribeTicks(ctx, "ETH", "BTC")
+	if err != nil {
+		t.Fatal(err)
+	}
+	trades, err := s.SubscribeTrades(ctx, "ETH", "BTC")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tick := <-ticks
+	if tick.BestBidPrice.String() != "0.0299" || tick.BestAskAmount.String() != "5" {
+		t.Errorf("OkexStream: unexpected tick %+v", tick)
+	}
+	trade := <-trades
+	if trade.ID != "1234" || trade.Type != models.Ask || trade.Amount.String() != "0.5" || trade.Time.Nanosecond() != 496000000 {
+		t.Errorf("OkexStream: unexpected trade %+v", trade)
+	}
+	board := waitBoard(t, boards, func(b *models.Board) bool { return len(b.Asks) == 2 })
+	if len(board.Bids) != 1 || board.Asks[0].Price.String() != "0.03" || board.Asks[0].Amount.String() != "1.5" {
+		t.Errorf("OkexStream: unexpected board %+v", board)
+	}
+	board = waitBoard(t, boards, func(b *models.Board) bool { return len(b.Asks) == 1 && b.Asks[0].Amount.String() == "2.5" })
+	if len(board.Bids) != 1 || board.Bids[0].Amount.String() != "10" {
+		t.Errorf("OkexStream: unexpected board %+v", board)
+	}
+
+	// the server dropped the first connection
+	board = waitBoard(t, boards, func(b *models.Board) bool { return b.Bids[0].Price.String() == "0.0297" })
+	if len(board.Asks) != 1 || board.Asks[0].Price.String() != "0.0302" {
+		t.Errorf("OkexStream: unexpected board %+v", board)
+	}
+	select {
+	case <-pinged:
+	case <-time.After(5 * time.Second):
+		t.Error("OkexStream: expected the stream to be pinged")
+	}
+	cancel()
+	for range boards {
+	}
+}
+
+func TestBinanceStreamTicksAndTrades(t *testing.T) {
+	client := newTestBinancePublicClient(&FakeRoundTripper{status: http.StatusOK})
+	url, closeServer := newTestStreamServer(t, func(conn *websocket.Conn, n int) {
+		if !readUntil(conn, func(b []byte) string { return string(b) }, "ethbtc@bookTicker", "ethbtc@trade") {
+			return
+		}
+		for _, msg := range []string{
+			`{"stream":"ethbtc@bookTicker","data":{"u":400900217,"s":"ETHBTC","b":"0.02990000","B":"31.21000000","a":"0.03010000","A":"40.66000000"}}`,
+			`{"stream":"ethbtc@trade","data":{"e":"trade","E":123456789,"s":"ETHBTC","t":12345,"p":"0.03000000","q":"2.00000000","b":88,"a":50,"T":1600000000000,"m":true,"M":true}}`,
+		} {
+			conn.WriteMessage(websocket.TextMessage, []byte(msg))
+		}
+		conn.ReadMessage()
+	})
+	defer closeServer()
+
+	s := NewBinanceStream(client)
+	s.Conn.URL = url
+	defer s.Close()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	ticks, err := s.SubscribeTicks(ctx, "ETH", "BTC")
+	if err != nil {
+		t.Fatal(err)
+	}
+	trades, err := s.SubscribeTrades(ctx, "ETH", "BTC")
+	if err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case tick := <-ticks:
+		if tick.BestBidPrice.String() != "0.0299" || tick.BestAskAmount.String() != "40.66" {
+			t.Errorf("BinanceStream: unexpected tick %+v", tick)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for tick")
+	}
+	select {
+	case trade := <-trades:
+		if trade.ID != "12345" || trade.Type != models.Bid || trade.Amount.String() != "2" || trade.Time.Unix() != 1600000000 {
+			t.Errorf("BinanceStream: unexpected trade %+v", trade)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for trade")
+	}
+	cancel()
+	for range ticks {
+	}
+	for range trades {
+	}
+}
+
+var d = decimal.RequireFromString
+
+// fakePollingClient serves the boards and trades pushed to it, one per call,
+// repeating the last one.
+type fakePollingClient struct {
+	PublicClient
+	m      sync.Mutex
+	boards []*models.Board
+	trades [][]models.PublicTrade
+}
+
+func (c *fakePollingClient) Board(trading string, settlement string) (*models.Board, error) {
+	c.m.Lock()
+	defer c.m.Unlock()
+	board := c.boards[0]
+	if len(c.boards) > 1 {
+		c.boards = c.boards[1:]
+	}
+	return board, nil
+}
+
+func (c *fakePollingClient) RecentTrades(trading string, settlement string, limit int) ([]models.PublicTrade, error) {
+	c.m.Lock()
+	defer c.m.Unlock()
+	trades := c.trades[0]
+	if len(c.trades) > 1 {
+		c.trades = c.trades[1:]
+	}
+	return trades, nil
+}
+
+func (c *fakePollingClient) WithContext(ctx context.Context) PublicClient {
+	return c
+}
+
+func TestPollingStream(t *testing.T) {
+	bar := func(side models.OrderType, price string, amount string) models.BoardBar {
+		return models.BoardBar{Type: side, Price: d(price), Amount: d(amount)}
+	}
+	trade := func(id string) models.PublicTrade {
+		return models.PublicTrade{ID: id, Price: d("0.03"), Amount: d("1")}
+	}
+	client := &fakePollingClient{
+		boards: []*models.Board{
+			{Asks: []models.BoardBar{bar(models.Ask, "0.0301", "5")}, Bids: []models.BoardBar{bar(models.Bid, "0.0299", "10")}},
+			{Asks: []models.BoardBar{bar(models.Ask, "0.0301", "5")}, Bids: []models.BoardBar{bar(models.Bid, "0.0299", "10")}},
+			{Asks: []models.BoardBar{bar(models.Ask, "0.0301", "5")}, Bids: []models.BoardBar{bar(models.Bid, "0.0299", "10"), bar(models.Bid, "0.0298", "1")}},
+			{Asks: []models.BoardBar{bar(models.Ask, "0.0302", "5")}, Bids: []models.BoardBar{bar(models.Bid, "0.0299", "10")}},
+		},
+		trades: [][]models.PublicTrade{
+			{trade("1"), trade("2")},
+			{trade("2"), trade("3"), trade("4")},
+			{},
+			{trade("4"), trade("5")},
+		},
+	}
+	s := NewPollingStream(client, 5*time.Millisecond)
+	defer s.Close()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	boards, err := s.SubscribeBoard(ctx, "ETH", "BTC")
+	if err != nil {
+		t.Fatal(err)
+	}
+	trades, err := s.SubscribeTrades(ctx, "ETH", "BTC")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// unchanged boards are not published
+	var sizes []int
+	for len(sizes) < 3 {
+		board := waitBoard(t, boards, func(*models.Board) bool { return true })
+		sizes = append(sizes, len(board.Bids))
+		if len(sizes) == 3 && board.Asks[0].Price.String() != "0.0302" {
+			t.Errorf("PollingStream: unexpected board %+v", board)
+		}
+	}
+	if sizes[0] != 1 || sizes[1] != 2 || sizes[2] != 1 {
+		t.Errorf("PollingStream: unexpected boards %v", sizes)
+	}
+
+	// the trades of the first poll are not published, nor trades seen before
+	var ids []string
+	for len(ids) < 3 {
+		select {
+		case trade := <-trades:
+			ids = append(ids, trade.ID)
+		case <-time.After(5 * time.Second):
+			t.Fatalf("timed out waiting for trades, got %v", ids)
+		}
+	}
+	if strings.Join(ids, ",") != "3,4,5" {
+		t.Errorf("PollingStream: unexpected trades %v", ids)
+	}
+
+	ticks, err := s.SubscribeTicks(ctx, "ETH", "BTC")
+	if err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case tick := <-ticks:
+		if tick.BestAskPrice.String() != "0.0302" || tick.BestBidAmount.String() != "10" {
+			t.Errorf("PollingStream: unexpected tick %+v", tick)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for tick")
+	}
+	cancel()
+	for range boards {
+	}
+	for range trades {
+	}
+	for range ticks {
+	}
+}
+
+func TestNewStreamer(t *testing.T) {
+	if _, ok := NewStreamer(newTestBinancePublicClient(&FakeRoundTripper{}), time.Second).(*BinanceStream); !ok {
+		t.Error("NewStreamer: expected a BinanceStream for binance")
+	}
+	s, ok := NewStreamer(newTestLbankPublicClient(&FakeRoundTripper{}), time.Second).(*PollingStream)
+	if !ok {
+		t.Fatal("NewStreamer: expected a PollingStream for lbank")
+	}
+	if s.Interval != time.Second {
+		t.Errorf("NewStreamer: Expected interval %v. Got %v", time.Second, s.Interval)
+	}
+}
+
+func TestPageCandlesEmptyWindow(t *testing.T) {
+	step := time.Minute
+	since := time.Now().Add(-300 * step).Truncate(step)
+	listed := since.Add(250 * step)
+	var starts []time.Time
+	fetch := func(start time.Time) ([]models.Candle, error) {
+		starts = append(starts, start)
+		var candles []models.Candle
+		for t := start; t.Before(start.Add(200 * step)); t = t.Add(step) {
+			if !t.Before(listed) && !t.After(listed.Add(9*step)) {
+				candles = append(candles, models.Candle{Time: t})
+			}
+		}
+		return candles, nil
+	}
+	candles, err := pageCandles(models.Interval1m, since, 5, 200, fetch)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(candles) != 5 || !candles[0].Time.Equal(listed) {
+		t.Fatalf("expected 5 candles from %v. Got %+v", listed, candles)
+	}
+	if len(starts) != 2 || !starts[1].Equal(since.Add(200*step)) {
+		t.Errorf("expected the second page to start after the empty window. Got %v", starts)
+	}
+	candles, err = pageCandles(models.Interval1m, since, 0, 200, fetch)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(candles) != 10 {
+		t.Errorf("expected %v candles. Got %v", 10, len(candles))
+	}
+	candles, err = pageCandles(models.Interval1m, since, 0, 0, fetch)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(candles) != 0 {
+		t.Errorf("expected no candles without a window. Got %v", len(candles))
+	}
+}
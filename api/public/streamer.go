@@ -0,0 +1,272 @@
+package public
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/fxpgr/go-exchange-client/logger"
+	"github.com/fxpgr/go-exchange-client/models"
+	"github.com/fxpgr/go-exchange-client/stream"
+	"github.com/pkg/errors"
+)
+
+// Streamer pushes the boards, ticks and trades of pairs, whether an exchange
+// streams them or they are polled.
+type Streamer interface {
+	SubscribeBoard(ctx context.Context, trading string, settlement string) (<-chan *models.Board, error)
+	SubscribeTicks(ctx context.Context, trading string, settlement string) (<-chan models.OrderBookTick, error)
+	SubscribeTrades(ctx context.Context, trading string, settlement string) (<-chan models.PublicTrade, error)
+	// Close stops the streamer and closes the channels of every
+	// subscription.
+	Close()
+}
+
+// NewStreamer returns the WebSocket stream of the exchange of client, or a
+// PollingStream polling client every interval for exchanges without one.
+func NewStreamer(client PublicClient, interval time.Duration) Streamer {
+	switch c := client.(type) {
+	case *BinanceApi:
+		return NewBinanceStream(c)
+	case *HuobiApi:
+		return NewHuobiStream()
+	case *OkexApi:
+		return NewOkexStream()
+	}
+	return NewPollingStream(client, interval)
+}
+
+// PollingStream streams a PublicClient by polling it every Interval. Boards
+// and ticks are published when they change, and trades when RecentTrades
+// returns ones not seen in the previous poll. Trades happening faster than
+// TradesLimit per Interval are missed.
+type PollingStream struct {
+	Client      PublicClient
+	Interval    time.Duration
+	TradesLimit int
+
+	topics map[string]*pollingTopic
+	m      sync.Mutex
+}
+
+type pollingTopic struct {
+	polled      bool
+	book        *stream.Book
+	board       *models.Board
+	tick        models.OrderBookTick
+	trades      map[string]bool
+	subscribers stream.Subscribers
+	cancel      context.CancelFunc
+}
+
+func NewPollingStream(client PublicClient, interval time.Duration) *PollingStream {
+	return &PollingStream{
+		Client:      client,
+		Interval:    interval,
+		TradesLimit: 100,
+		topics:      make(map[string]*pollingTopic),
+	}
+}
+
+// SubscribeBoard returns a channel receiving the board of a pair whenever a
+// poll finds it changed, until ctx is done. A receiver which falls behind
+// only gets the latest board.
+func (s *PollingStream) SubscribeBoard(ctx context.Context, trading string, settlement string) (<-chan *models.Board, error) {
+	var ch chan *models.Board
+	err := s.subscribe(ctx, trading, settlement, "board", s.pollBoard, func(t *pollingTopic) interface{} {
+		ch = t.subscribers.AddBoard()
+		if t.polled {
+			ch <- t.book.Board()
+		}
+		return ch
+	})
+	return ch, err
+}
+
+// SubscribeTicks returns a channel receiving the best bid and offer of a pair
+// whenever a poll finds them changed, until ctx is done. A receiver which
+// falls behind only gets the latest tick.
+func (s *PollingStream) SubscribeTicks(ctx context.Context, trading string, settlement string) (<-chan models.OrderBookTick, error) {
+	var ch chan models.OrderBookTick
+	err := s.subscribe(ctx, trading, settlement, "ticks", s.pollTicks, func(t *pollingTopic) interface{} {
+		ch = t.subscribers.AddTicks()
+		return ch
+	})
+	return ch, err
+}
+
+// SubscribeTrades returns a channel receiving the trades of a pair found by
+// every poll after the first, until ctx is done.
+func (s *PollingStream) SubscribeTrades(ctx context.Context, trading string, settlement string) (<-chan models.PublicTrade, error) {
+	var ch chan models.PublicTrade
+	err := s.subscribe(ctx, trading, settlement, "trades", s.pollTrades, func(t *pollingTopic) interface{} {
+		ch = t.subscribers.AddTrades()
+		return ch
+	})
+	return ch, err
+}
+
+type pollFunc func(client PublicClient, t *pollingTopic, trading string, settlement string) error
+
+func (s *PollingStream) subscribe(ctx context.Context, trading string, settlement string, channel string, poll pollFunc, add func(t *pollingTopic) interface{}) error {
+	if trading == settlement {
+		return errors.Errorf("trading and settlment are same")
+	}
+	if s.Interval <= 0 {
+		return errors.Errorf("invalid polling interval %v", s.Interval)
+	}
+	name := channel + ":" + trading + "/" + settlement
+
+	s.m.Lock()
+	t, ok := s.topics[name]
+	if !ok {
+		var pollCtx context.Context
+		t = &pollingTopic{book: stream.NewBook()}
+		pollCtx, t.cancel = context.WithCancel(context.Background())
+		s.topics[name] = t
+		go s.run(pollCtx, name, t, poll, trading, settlement)
+	}
+	ch := add(t)
+	s.m.Unlock()
+
+	go func() {
+		<-ctx.Done()
+		s.unsubscribe(name, ch)
+	}()
+	return nil
+}
+
+func (s *PollingStream) unsubscribe(name string, ch interface{}) {
+	s.m.Lock()
+	defer s.m.Unlock()
+	t, ok := s.topics[name]
+	if !ok {
+		return
+	}
+	t.subscribers.Remove(ch)
+	if t.subscribers.Len() == 0 {
+		delete(s.topics, name)
+		t.cancel()
+	}
+}
+
+// Close stops polling and closes the channels of every subscription.
+func (s *PollingStream) Close() {
+	s.m.Lock()
+	defer s.m.Unlock()
+	for name, t := range s.topics {
+		t.cancel()
+		t.subscribers.Close()
+		delete(s.topics, name)
+	}
+}
+
+// run polls a topic until ctx is done. Requests of a stopped topic are
+// aborted, and a failed poll is tried again at the next interval.
+func (s *PollingStream) run(ctx context.Context, name string, t *pollingTopic, poll pollFunc, trading string, settlement string) {
+	client := s.Client.WithContext(ctx)
+	ticker := time.NewTicker(s.Interval)
+	defer ticker.Stop()
+	for {
+		if err := poll(client, t, trading, settlement); err != nil && ctx.Err() == nil {
+			logger.Get().Warnw("failed to poll", "topic", name, "error", err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (s *PollingStream) pollBoard(client PublicClient, t *pollingTopic, trading string, settlement string) error {
+	board, err := client.Board(trading, settlement)
+	if err != nil {
+		return err
+	}
+	s.m.Lock()
+	defer s.m.Unlock()
+	t.book.Load(board)
+	board = t.book.Board()
+	if t.polled && sameBoard(t.board, board) {
+		return nil
+	}
+	t.polled = true
+	t.board = board
+	t.subscribers.PublishBoard(t.book)
+	return nil
+}
+
+func (s *PollingStream) pollTicks(client PublicClient, t *pollingTopic, trading string, settlement string) error {
+	board, err := client.Board(trading, settlement)
+	if err != nil {
+		return err
+	}
+	s.m.Lock()
+	defer s.m.Unlock()
+	t.book.Load(board)
+	tick := t.book.Tick()
+	if t.polled && sameTick(t.tick, tick) {
+		return nil
+	}
+	t.polled = true
+	t.tick = tick
+	t.subscribers.PublishTick(tick)
+	return nil
+}
+
+// pollTrades publishes the trades missing from the previous poll, oldest
+// first. The first poll only remembers the trades already made.
+func (s *PollingStream) pollTrades(client PublicClient, t *pollingTopic, trading string, settlement string) error {
+	trades, err := client.RecentTrades(trading, settlement, s.TradesLimit)
+	if err != nil {
+		return err
+	}
+	s.m.Lock()
+	defer s.m.Unlock()
+	seen := make(map[string]bool, len(trades))
+	for _, trade := range trades {
+		key := tradeKey(trade)
+		seen[key] = true
+		if t.polled && !t.trades[key] {
+			t.subscribers.PublishTrade(trade)
+		}
+	}
+	// an empty tape keeps the trades seen before it
+	if !t.polled || len(trades) > 0 {
+		t.trades = seen
+	}
+	t.polled = true
+	return nil
+}
+
+// tradeKey identifies a trade by its ID, or by all of its fields when the
+// exchange gives none.
+func tradeKey(trade models.PublicTrade) string {
+	if trade.ID != "" {
+		return trade.ID
+	}
+	return fmt.Sprintf("%d:%d:%s:%s", trade.Time.UnixNano(), trade.Type, trade.Price, trade.Amount)
+}
+
+func sameBoard(a *models.Board, b *models.Board) bool {
+	return sameBars(a.Asks, b.Asks) && sameBars(a.Bids, b.Bids)
+}
+
+func sameBars(a []models.BoardBar, b []models.BoardBar) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if !a[i].Price.Equal(b[i].Price) || !a[i].Amount.Equal(b[i].Amount) {
+			return false
+		}
+	}
+	return true
+}
+
+func sameTick(a models.OrderBookTick, b models.OrderBookTick) bool {
+	return a.BestAskPrice.Equal(b.BestAskPrice) && a.BestAskAmount.Equal(b.BestAskAmount) &&
+		a.BestBidPrice.Equal(b.BestBidPrice) && a.BestBidAmount.Equal(b.BestBidAmount)
+}
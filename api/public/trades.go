@@ -0,0 +1,36 @@
+package public
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/fxpgr/go-exchange-client/models"
+)
+
+// tradesLimit caps limit at the most trades an exchange serves in one request,
+// which is also what a limit of zero or less asks for.
+func tradesLimit(limit int, max int) int {
+	if limit <= 0 || limit > max {
+		return max
+	}
+	return limit
+}
+
+// latestTrades sorts trades oldest first and keeps the latest limit of them.
+func latestTrades(trades []models.PublicTrade, limit int) []models.PublicTrade {
+	sort.SliceStable(trades, func(i, j int) bool {
+		return trades[i].Time.Before(trades[j].Time)
+	})
+	if limit > 0 && len(trades) > limit {
+		trades = trades[len(trades)-limit:]
+	}
+	return trades
+}
+
+// takerSide maps the buy or sell side of a taker to an OrderType.
+func takerSide(side string) models.OrderType {
+	if strings.EqualFold(side, "sell") {
+		return models.Bid
+	}
+	return models.Ask
+}
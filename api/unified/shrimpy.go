@@ -1,12 +1,14 @@
 package unified
 
 import (
+	"context"
 	"io/ioutil"
 	"net/http"
 	url2 "net/url"
 	"sync"
 	"time"
 
+	"github.com/fxpgr/go-exchange-client/helpers"
 	"github.com/fxpgr/go-exchange-client/models"
 	"github.com/patrickmn/go-cache"
 	"github.com/pkg/errors"
@@ -41,6 +43,7 @@ type ShrimpyApiClient struct {
 	currencyPairs     []models.CurrencyPair
 
 	HttpClient *http.Client
+	ctx        context.Context
 
 	settlements  []string
 	m            *sync.Mutex
@@ -53,9 +56,20 @@ func (h *ShrimpyApiClient) publicApiUrl(command string) string {
 	return h.BaseURL + command
 }
 
+// WithContext returns a shallow copy of the client whose requests are bound to ctx.
+func (h *ShrimpyApiClient) WithContext(ctx context.Context) *ShrimpyApiClient {
+	c := *h
+	c.ctx = ctx
+	return &c
+}
+
 func (h *ShrimpyApiClient) getRequest(path string) ([]byte, error) {
 	url := h.publicApiUrl(path)
-	req, err := http.NewRequest("GET", url, nil)
+	ctx := h.ctx
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
 	if err != nil {
 		return []byte{}, err
 	}
@@ -148,8 +162,8 @@ func (h *ShrimpyApiClient) GetBoards(exchange string) (map[string]map[string]mod
 		bidBoardBars := make([]models.BoardBar, 0)
 		askBoardBars := make([]models.BoardBar, 0)
 		for _, ask := range asks.Array() {
-			price := ask.Get("price").Float()
-			quantity := ask.Get("quantity").Float()
+			price := helpers.ToDecimal(ask.Get("price"))
+			quantity := helpers.ToDecimal(ask.Get("quantity"))
 			askBoardBars = append(askBoardBars, models.BoardBar{
 				Price:  price,
 				Amount: quantity,
@@ -157,8 +171,8 @@ func (h *ShrimpyApiClient) GetBoards(exchange string) (map[string]map[string]mod
 			})
 		}
 		for _, bid := range bids.Array() {
-			price := bid.Get("price").Float()
-			quantity := bid.Get("quantity").Float()
+			price := helpers.ToDecimal(bid.Get("price"))
+			quantity := helpers.ToDecimal(bid.Get("quantity"))
 			bidBoardBars = append(bidBoardBars, models.BoardBar{
 				Price:  price,
 				Amount: quantity,
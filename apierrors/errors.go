@@ -0,0 +1,68 @@
+package apierrors
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+)
+
+var (
+	ErrRateLimited       = errors.New("rate limited")
+	ErrIPBanned          = errors.New("ip banned")
+	ErrInsufficientFunds = errors.New("insufficient funds")
+	ErrOrderNotFound     = errors.New("order not found")
+	ErrInvalidSymbol     = errors.New("invalid symbol")
+	ErrAuth              = errors.New("authentication failed")
+	ErrMaintenance       = errors.New("exchange under maintenance")
+	ErrUnsupported       = errors.New("unsupported by exchange")
+	ErrInvalidOrder      = errors.New("order breaks trading rules")
+)
+
+// Error is a failure reported by an exchange. Kind holds one of the Err*
+// sentinels when the native code is known, so errors.Is works through it.
+type Error struct {
+	StatusCode int
+	Code       string
+	Message    string
+	Kind       error
+}
+
+func (e *Error) Error() string {
+	s := fmt.Sprintf("HttpStatusCode:%d ,Desc:%s", e.StatusCode, e.Message)
+	if e.Code != "" {
+		s = fmt.Sprintf("HttpStatusCode:%d ,Code:%s ,Desc:%s", e.StatusCode, e.Code, e.Message)
+	}
+	if e.Kind != nil {
+		s = e.Kind.Error() + ": " + s
+	}
+	return s
+}
+
+func (e *Error) Unwrap() error {
+	return e.Kind
+}
+
+func New(statusCode int, code string, message string, kind error) error {
+	return &Error{
+		StatusCode: statusCode,
+		Code:       code,
+		Message:    message,
+		Kind:       kind,
+	}
+}
+
+// FromStatus maps the HTTP status codes shared by every exchange onto a
+// sentinel, returning nil for statuses with no exchange independent meaning.
+func FromStatus(statusCode int) error {
+	switch statusCode {
+	case http.StatusTooManyRequests:
+		return ErrRateLimited
+	case http.StatusTeapot:
+		return ErrIPBanned
+	case http.StatusUnauthorized, http.StatusForbidden:
+		return ErrAuth
+	case http.StatusServiceUnavailable:
+		return ErrMaintenance
+	}
+	return nil
+}
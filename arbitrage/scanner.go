@@ -0,0 +1,286 @@
+package arbitrage
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/fxpgr/go-exchange-client/api/private"
+	"github.com/fxpgr/go-exchange-client/api/public"
+	"github.com/fxpgr/go-exchange-client/decimal"
+	"github.com/fxpgr/go-exchange-client/logger"
+	"github.com/fxpgr/go-exchange-client/models"
+)
+
+// Venue is an exchange to scan. Trade and withdrawal fees are read from
+// Private, and are zero when it is nil.
+type Venue struct {
+	Exchange string
+	Public   public.PublicClient
+	Private  private.PrivateClient
+}
+
+// Config of a Scanner. Venues are scanned every Interval, and opportunities
+// earning less than MinProfitRate of their cost are dropped.
+type Config struct {
+	Venues        []Venue
+	Interval      time.Duration
+	MinProfitRate decimal.Decimal
+}
+
+var DefaultConfig = Config{
+	Interval: 10 * time.Second,
+}
+
+// Opportunity is buying Amount of Trading with Settlement on Buy, withdrawing
+// it to Sell and selling it there. BuyPrice and SellPrice are the average
+// rates over the depth taken. Cost and Profit are in Settlement, Profit net
+// of the taker fees of both exchanges and the withdrawal fee of Buy.
+type Opportunity struct {
+	Trading    string
+	Settlement string
+	Buy        string
+	Sell       string
+	BuyPrice   decimal.Decimal
+	SellPrice  decimal.Decimal
+	Amount     decimal.Decimal
+	Cost       decimal.Decimal
+	Profit     decimal.Decimal
+	ProfitRate decimal.Decimal
+	Time       time.Time
+}
+
+// Scanner finds pairs whose best bid on one exchange is above the best ask
+// on another.
+type Scanner struct {
+	config Config
+}
+
+func NewScanner(config Config) *Scanner {
+	if config.Interval <= 0 {
+		config.Interval = DefaultConfig.Interval
+	}
+	return &Scanner{config: config}
+}
+
+// Run scans every Interval until ctx is done, sending the opportunities of
+// every scan to out, and closes out.
+func (s *Scanner) Run(ctx context.Context, out chan<- []Opportunity) {
+	defer close(out)
+	ticker := time.NewTicker(s.config.Interval)
+	defer ticker.Stop()
+	for {
+		opportunities := s.Scan(ctx)
+		select {
+		case <-ctx.Done():
+			return
+		case out <- opportunities:
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// snapshot is what a scan reads from a venue.
+type snapshot struct {
+	venue        Venue
+	ticks        map[string]map[string]models.OrderBookTick
+	transferFees map[string]float64
+	boards       map[models.CurrencyPair]*models.Board
+}
+
+// Scan reads the order book ticks of every venue once and returns the
+// opportunities found, most profitable first. Venues and boards which fail
+// are logged and skipped.
+func (s *Scanner) Scan(ctx context.Context) []Opportunity {
+	snapshots := make([]*snapshot, len(s.config.Venues))
+	var wg sync.WaitGroup
+	for i, v := range s.config.Venues {
+		wg.Add(1)
+		go func(i int, v Venue) {
+			defer wg.Done()
+			snapshots[i] = s.snapshot(ctx, v)
+		}(i, v)
+	}
+	wg.Wait()
+
+	var opportunities []Opportunity
+	for _, buy := range snapshots {
+		for _, sell := range snapshots {
+			if buy == nil || sell == nil || buy == sell {
+				continue
+			}
+			opportunities = append(opportunities, s.scanPair(ctx, buy, sell)...)
+		}
+	}
+	sort.SliceStable(opportunities, func(i, j int) bool {
+		return opportunities[i].Profit.GreaterThan(opportunities[j].Profit)
+	})
+	return opportunities
+}
+
+func (s *Scanner) snapshot(ctx context.Context, v Venue) *snapshot {
+	ticks, err := v.Public.WithContext(ctx).OrderBookTickMap()
+	if err != nil {
+		warn(ctx, "failed to get order book ticks", v.Exchange, err)
+		return nil
+	}
+	snap := &snapshot{venue: v, ticks: ticks, boards: make(map[models.CurrencyPair]*models.Board)}
+	if v.Private != nil {
+		snap.transferFees, err = v.Private.WithContext(ctx).TransferFee()
+		if err != nil {
+			warn(ctx, "failed to get transfer fees", v.Exchange, err)
+			return nil
+		}
+	}
+	return snap
+}
+
+// scanPair returns the opportunities of buying on buy and selling on sell.
+func (s *Scanner) scanPair(ctx context.Context, buy *snapshot, sell *snapshot) []Opportunity {
+	var opportunities []Opportunity
+	for trading, settlements := range buy.ticks {
+		for settlement, ask := range settlements {
+			bid, ok := sell.ticks[trading][settlement]
+			if !ok || !ask.BestAskPrice.IsPositive() || !bid.BestBidPrice.GreaterThan(ask.BestAskPrice) {
+				continue
+			}
+			o, ok := s.evaluate(ctx, buy, sell, models.CurrencyPair{Trading: trading, Settlement: settlement})
+			if ok && !o.ProfitRate.LessThan(s.config.MinProfitRate) {
+				opportunities = append(opportunities, o)
+			}
+		}
+	}
+	return opportunities
+}
+
+func (s *Scanner) evaluate(ctx context.Context, buy *snapshot, sell *snapshot, p models.CurrencyPair) (Opportunity, bool) {
+	buyFee, err := takerFee(ctx, buy.venue, p)
+	if err != nil {
+		warn(ctx, "failed to get trade fee", buy.venue.Exchange, err)
+		return Opportunity{}, false
+	}
+	sellFee, err := takerFee(ctx, sell.venue, p)
+	if err != nil {
+		warn(ctx, "failed to get trade fee", sell.venue.Exchange, err)
+		return Opportunity{}, false
+	}
+	asks, bids := buy.board(ctx, p), sell.board(ctx, p)
+	if asks == nil || bids == nil {
+		return Opportunity{}, false
+	}
+	o, ok := size(asks, bids, buyFee, sellFee, decimal.NewFromFloat(buy.transferFees[p.Trading]))
+	if !ok {
+		return Opportunity{}, false
+	}
+	o.Trading, o.Settlement = p.Trading, p.Settlement
+	o.Buy, o.Sell = buy.venue.Exchange, sell.venue.Exchange
+	o.Time = time.Now()
+	return o, true
+}
+
+// board returns the board of p, fetching it once per scan.
+func (snap *snapshot) board(ctx context.Context, p models.CurrencyPair) *models.Board {
+	if b, ok := snap.boards[p]; ok {
+		return b
+	}
+	b, err := snap.venue.Public.WithContext(ctx).Board(p.Trading, p.Settlement)
+	if err != nil {
+		warn(ctx, "failed to get board", snap.venue.Exchange, err)
+		b = nil
+	}
+	snap.boards[p] = b
+	return b
+}
+
+func takerFee(ctx context.Context, v Venue, p models.CurrencyPair) (decimal.Decimal, error) {
+	if v.Private == nil {
+		return decimal.Zero, nil
+	}
+	fee, err := v.Private.WithContext(ctx).TradeFeeRate(p.Trading, p.Settlement)
+	if err != nil {
+		return decimal.Zero, err
+	}
+	return fee.TakerFee, nil
+}
+
+// size returns the most profitable amount to buy on asks and sell on bids.
+// Fees are charged in the currency received, and the withdrawal fee in the
+// trading currency. The profit is linear between the cumulative amounts of
+// the levels of either board, so only those are evaluated. The boards are
+// left as the adapter returned them.
+func size(asks *models.Board, bids *models.Board, buyFee decimal.Decimal, sellFee decimal.Decimal, transferFee decimal.Decimal) (Opportunity, bool) {
+	if !buyFee.LessThan(one) {
+		return Opportunity{}, false
+	}
+	askBars := append([]models.BoardBar(nil), asks.Asks...)
+	bidBars := append([]models.BoardBar(nil), bids.Bids...)
+	sort.Slice(askBars, func(i, j int) bool { return askBars[i].Price.LessThan(askBars[j].Price) })
+	sort.Slice(bidBars, func(i, j int) bool { return bidBars[i].Price.GreaterThan(bidBars[j].Price) })
+
+	var amounts []decimal.Decimal
+	depth := decimal.Zero
+	for _, b := range askBars {
+		depth = depth.Add(b.Amount)
+		amounts = append(amounts, depth)
+	}
+	sold := decimal.Zero
+	for _, b := range bidBars {
+		sold = sold.Add(b.Amount)
+		if amount := sold.Add(transferFee).Div(one.Sub(buyFee)); amount.LessThan(depth) {
+			amounts = append(amounts, amount)
+		}
+	}
+	var best Opportunity
+	for _, amount := range amounts {
+		received := amount.Mul(one.Sub(buyFee)).Sub(transferFee)
+		if !received.IsPositive() {
+			continue
+		}
+		cost, ok := fill(askBars, amount)
+		if !ok {
+			continue
+		}
+		proceeds, ok := fill(bidBars, received)
+		if !ok {
+			continue
+		}
+		profit := proceeds.Mul(one.Sub(sellFee)).Sub(cost)
+		if profit.GreaterThan(best.Profit) {
+			best = Opportunity{
+				BuyPrice:   cost.Div(amount),
+				SellPrice:  proceeds.Div(received),
+				Amount:     amount,
+				Cost:       cost,
+				Profit:     profit,
+				ProfitRate: profit.Div(cost),
+			}
+		}
+	}
+	return best, best.Profit.IsPositive()
+}
+
+// fill returns the value of taking amount from bars, best first, and false
+// when they hold less.
+func fill(bars []models.BoardBar, amount decimal.Decimal) (decimal.Decimal, bool) {
+	value := decimal.Zero
+	for _, b := range bars {
+		take := decimal.Min(b.Amount, amount)
+		value = value.Add(take.Mul(b.Price))
+		amount = amount.Sub(take)
+		if !amount.IsPositive() {
+			return value, true
+		}
+	}
+	return value, false
+}
+
+func warn(ctx context.Context, msg string, exchange string, err error) {
+	if ctx.Err() == nil {
+		logger.Get().Warnw(msg, "exchange", exchange, "error", err)
+	}
+}
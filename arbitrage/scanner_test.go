@@ -0,0 +1,123 @@
+package arbitrage
+
+import (
+	"context"
+	"math"
+	"testing"
+	"time"
+
+	"github.com/fxpgr/go-exchange-client/api/private"
+	"github.com/fxpgr/go-exchange-client/api/public/mocks"
+	"github.com/fxpgr/go-exchange-client/decimal"
+	"github.com/fxpgr/go-exchange-client/models"
+	"github.com/stretchr/testify/mock"
+)
+
+var d = decimal.RequireFromString
+
+func near(a, b float64) bool { return math.Abs(a-b) < 1e-6 }
+
+func newTestPublicClient(board *models.Board) *mocks.PublicClient {
+	pub := new(mocks.PublicClient)
+	pub.On("WithContext", mock.Anything).Return(pub)
+	pub.On("OrderBookTickMap").Return(map[string]map[string]models.OrderBookTick{
+		"BTC": {"USD": {
+			BestAskPrice:  board.BestAskPrice(),
+			BestAskAmount: board.BestAskAmount(),
+			BestBidPrice:  board.BestBidPrice(),
+			BestBidAmount: board.BestBidAmount(),
+		}},
+	}, nil)
+	pub.On("Board", "BTC", "USD").Return(board, nil)
+	return pub
+}
+
+func newTestPrivateClient(taker float64, transferFee float64) *private.MockPrivateClient {
+	priv := new(private.MockPrivateClient)
+	priv.On("WithContext", mock.Anything).Return(priv)
+	priv.On("TradeFeeRate", mock.Anything, mock.Anything).Return(private.TradeFee{TakerFee: decimal.NewFromFloat(taker)}, nil)
+	priv.On("TransferFee").Return(map[string]float64{"BTC": transferFee}, nil)
+	return priv
+}
+
+func TestScannerScan(t *testing.T) {
+	scanner := NewScanner(Config{Venues: []Venue{
+		{
+			Exchange: "a",
+			Public: newTestPublicClient(&models.Board{
+				Asks: []models.BoardBar{{Price: d("100"), Amount: d("1")}, {Price: d("101"), Amount: d("1")}},
+				Bids: []models.BoardBar{{Price: d("99"), Amount: d("1")}},
+			}),
+			Private: newTestPrivateClient(0.001, 0.01),
+		},
+		{
+			Exchange: "b",
+			Public: newTestPublicClient(&models.Board{
+				Asks: []models.BoardBar{{Price: d("104"), Amount: d("1")}},
+				Bids: []models.BoardBar{{Price: d("103"), Amount: d("1.5")}, {Price: d("100.5"), Amount: d("5")}},
+			}),
+			Private: newTestPrivateClient(0.002, 0.01),
+		},
+	}})
+	opportunities := scanner.Scan(context.Background())
+	if len(opportunities) != 1 {
+		t.Fatalf("Scanner: Expected %v opportunity. Got %+v", 1, opportunities)
+	}
+	o := opportunities[0]
+	if o.Buy != "a" || o.Sell != "b" || o.Trading != "BTC" || o.Settlement != "USD" {
+		t.Errorf("Scanner: unexpected opportunity %+v", o)
+	}
+	// selling exactly the first bid level after the fee and the withdrawal
+	if !near(o.Amount.Float64(), 1.51/0.999) || !near(o.SellPrice.Float64(), 103) || !near(o.Profit.Float64(), 2.528337337) {
+		t.Errorf("Scanner: unexpected sizing %+v", o)
+	}
+
+	scanner.config.MinProfitRate = d("0.05")
+	if opportunities := scanner.Scan(context.Background()); len(opportunities) != 0 {
+		t.Errorf("Scanner: Expected no opportunity. Got %+v", opportunities)
+	}
+}
+
+func TestSizeUnsortedBoards(t *testing.T) {
+	asks := &models.Board{Asks: []models.BoardBar{{Price: d("101"), Amount: d("1")}, {Price: d("100"), Amount: d("1")}}}
+	bids := &models.Board{Bids: []models.BoardBar{{Price: d("100.5"), Amount: d("5")}, {Price: d("103"), Amount: d("1.5")}}}
+	o, ok := size(asks, bids, d("0.001"), d("0.002"), d("0.01"))
+	if !ok {
+		t.Fatal("size: Expected an opportunity")
+	}
+	if !near(o.Amount.Float64(), 1.51/0.999) || !near(o.SellPrice.Float64(), 103) || !near(o.Profit.Float64(), 2.528337337) {
+		t.Errorf("size: unexpected sizing %+v", o)
+	}
+	if asks.Asks[0].Price.String() != "101" || bids.Bids[0].Price.String() != "100.5" {
+		t.Errorf("size: Expected the boards to be left unsorted. Got %+v %+v", asks, bids)
+	}
+
+	// the whole depth must be taken although 0.1 + 0.2 is not exact in float64
+	asks = &models.Board{Asks: []models.BoardBar{{Price: d("100"), Amount: d("0.1")}, {Price: d("100"), Amount: d("0.2")}}}
+	bids = &models.Board{Bids: []models.BoardBar{{Price: d("110"), Amount: d("10")}}}
+	o, ok = size(asks, bids, decimal.Zero, decimal.Zero, decimal.Zero)
+	if !ok {
+		t.Fatal("size: Expected an opportunity")
+	}
+	if !o.Amount.Equal(d("0.3")) || !o.Profit.Equal(d("3")) {
+		t.Errorf("size: Expected amount %v and profit %v. Got %+v", 0.3, 3, o)
+	}
+}
+
+func TestScannerRun(t *testing.T) {
+	scanner := NewScanner(Config{Interval: time.Millisecond, Venues: []Venue{
+		{Exchange: "a", Public: newTestPublicClient(&models.Board{Asks: []models.BoardBar{{Price: d("100"), Amount: d("1")}}})},
+		{Exchange: "b", Public: newTestPublicClient(&models.Board{Bids: []models.BoardBar{{Price: d("101"), Amount: d("1")}}})},
+	}})
+	ctx, cancel := context.WithCancel(context.Background())
+	out := make(chan []Opportunity)
+	go scanner.Run(ctx, out)
+	for i := 0; i < 2; i++ {
+		if opportunities := <-out; len(opportunities) != 1 || !opportunities[0].Profit.Equal(d("1")) {
+			t.Errorf("Scanner: unexpected opportunities %+v", opportunities)
+		}
+	}
+	cancel()
+	for range out {
+	}
+}
@@ -0,0 +1,361 @@
+package arbitrage
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"github.com/fxpgr/go-exchange-client/decimal"
+	"github.com/fxpgr/go-exchange-client/models"
+	"github.com/pkg/errors"
+)
+
+// Leg is one trade of a cycle, buying (models.Ask) or selling (models.Bid)
+// Amount of Trading for Settlement at the average rate Price.
+type Leg struct {
+	Trading    string
+	Settlement string
+	Type       models.OrderType
+	Price      decimal.Decimal
+	Amount     decimal.Decimal
+}
+
+// Cycle is trading Amount of Currencies[0] for Currencies[1], then for
+// Currencies[2] and back to Currencies[0], receiving Return. Profit is Return
+// less Amount, net of taker fees. Amounts are rounded to the precisions of
+// the pairs, and the dust rounding leaves in the other currencies is not
+// counted.
+type Cycle struct {
+	Currencies [3]string
+	Legs       [3]Leg
+	Amount     decimal.Decimal
+	Return     decimal.Decimal
+	Profit     decimal.Decimal
+	ProfitRate decimal.Decimal
+	Time       time.Time
+}
+
+// TriangularFinder finds the profitable three-leg cycles of one exchange.
+// Cycles earning less than MinProfitRate of their amount are dropped.
+type TriangularFinder struct {
+	Venue         Venue
+	MinProfitRate decimal.Decimal
+}
+
+func NewTriangularFinder(venue Venue, minProfitRate decimal.Decimal) *TriangularFinder {
+	return &TriangularFinder{Venue: venue, MinProfitRate: minProfitRate}
+}
+
+// edge converts from into to through pair, buying when to is its trading
+// currency and selling otherwise.
+type edge struct {
+	pair models.CurrencyPair
+	typ  models.OrderType
+	from string
+	to   string
+}
+
+// Find builds the currency graph of the pairs with order book ticks and
+// returns the profitable cycles, the most profitable rate first. Each cycle
+// is reported once in each direction, starting from its first currency in
+// alphabetical order. Cycles whose boards, precisions or fees fail are logged
+// and skipped.
+func (f *TriangularFinder) Find(ctx context.Context) ([]Cycle, error) {
+	pub := f.Venue.Public.WithContext(ctx)
+	pairs, err := pub.CurrencyPairs()
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to get currency pairs of %s", f.Venue.Exchange)
+	}
+	ticks, err := pub.OrderBookTickMap()
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to get order book ticks of %s", f.Venue.Exchange)
+	}
+	graph := make(map[string]map[string]edge)
+	add := func(e edge) {
+		if graph[e.from] == nil {
+			graph[e.from] = make(map[string]edge)
+		}
+		graph[e.from][e.to] = e
+	}
+	for _, p := range pairs {
+		tick, ok := ticks[p.Trading][p.Settlement]
+		if !ok || !tick.BestAskPrice.IsPositive() || !tick.BestBidPrice.IsPositive() {
+			continue
+		}
+		add(edge{pair: p, typ: models.Ask, from: p.Settlement, to: p.Trading})
+		add(edge{pair: p, typ: models.Bid, from: p.Trading, to: p.Settlement})
+	}
+
+	e := &evaluation{
+		ctx:        ctx,
+		venue:      f.Venue,
+		fees:       make(map[models.CurrencyPair]decimal.Decimal),
+		boards:     make(map[models.CurrencyPair]*models.Board),
+		precisions: make(map[models.CurrencyPair]int),
+	}
+	var cycles []Cycle
+	for a, out := range graph {
+		for b, ab := range out {
+			for c, bc := range graph[b] {
+				ca, ok := graph[c][a]
+				if !ok || c == a || a > b || a > c {
+					continue
+				}
+				edges := [3]edge{ab, bc, ca}
+				rate, ok := e.tickRate(edges, ticks)
+				if !ok || rate.Sub(one).LessThan(f.MinProfitRate) {
+					continue
+				}
+				cycle, ok := e.size(edges)
+				if ok && !cycle.ProfitRate.LessThan(f.MinProfitRate) {
+					cycles = append(cycles, cycle)
+				}
+			}
+		}
+	}
+	sort.SliceStable(cycles, func(i, j int) bool {
+		return cycles[i].ProfitRate.GreaterThan(cycles[j].ProfitRate)
+	})
+	return cycles, nil
+}
+
+// evaluation caches what a Find reads per pair.
+type evaluation struct {
+	ctx        context.Context
+	venue      Venue
+	fees       map[models.CurrencyPair]decimal.Decimal
+	boards     map[models.CurrencyPair]*models.Board
+	precisions map[models.CurrencyPair]int
+}
+
+var one = decimal.NewFromInt(1)
+
+// fee returns the taker fee of p, failing for fees which leave nothing.
+func (e *evaluation) fee(p models.CurrencyPair) (decimal.Decimal, bool) {
+	if fee, ok := e.fees[p]; ok {
+		return fee, fee.LessThan(one)
+	}
+	fee, err := takerFee(e.ctx, e.venue, p)
+	if err != nil {
+		warn(e.ctx, "failed to get trade fee", e.venue.Exchange, err)
+		return decimal.Zero, false
+	}
+	e.fees[p] = fee
+	return fee, fee.LessThan(one)
+}
+
+// tickRate returns what a unit of the first currency returns through the
+// best prices of the cycle, net of fees.
+func (e *evaluation) tickRate(edges [3]edge, ticks map[string]map[string]models.OrderBookTick) (decimal.Decimal, bool) {
+	rate := one
+	for _, ed := range edges {
+		fee, ok := e.fee(ed.pair)
+		if !ok {
+			return decimal.Zero, false
+		}
+		tick := ticks[ed.pair.Trading][ed.pair.Settlement]
+		if ed.typ == models.Ask {
+			rate = rate.Mul(one.Sub(fee)).Div(tick.BestAskPrice)
+		} else {
+			rate = rate.Mul(tick.BestBidPrice).Mul(one.Sub(fee))
+		}
+	}
+	return rate, true
+}
+
+// leg returns the board, fee and amount precision of an edge.
+func (e *evaluation) leg(ed edge) (*leg, bool) {
+	fee, ok := e.fee(ed.pair)
+	if !ok {
+		return nil, false
+	}
+	board, ok := e.boards[ed.pair]
+	if !ok {
+		b, err := e.venue.Public.WithContext(e.ctx).Board(ed.pair.Trading, ed.pair.Settlement)
+		if err != nil {
+			warn(e.ctx, "failed to get board", e.venue.Exchange, err)
+		} else {
+			board = &models.Board{
+				Asks: append([]models.BoardBar(nil), b.Asks...),
+				Bids: append([]models.BoardBar(nil), b.Bids...),
+			}
+			sort.Slice(board.Asks, func(i, j int) bool { return board.Asks[i].Price.LessThan(board.Asks[j].Price) })
+			sort.Slice(board.Bids, func(i, j int) bool { return board.Bids[i].Price.GreaterThan(board.Bids[j].Price) })
+		}
+		e.boards[ed.pair] = board
+	}
+	precision, ok := e.precisions[ed.pair]
+	if !ok {
+		p, err := e.venue.Public.WithContext(e.ctx).Precise(ed.pair.Trading, ed.pair.Settlement)
+		if err != nil {
+			warn(e.ctx, "failed to get precisions", e.venue.Exchange, err)
+			precision = -1
+		} else {
+			precision = p.AmountPrecision
+		}
+		e.precisions[ed.pair] = precision
+	}
+	if board == nil || precision < 0 {
+		return nil, false
+	}
+	return &leg{edge: ed, board: board, fee: fee, precision: precision}, true
+}
+
+type leg struct {
+	edge
+	board     *models.Board
+	fee       decimal.Decimal
+	precision int
+}
+
+// levels returns the levels the leg takes, best first.
+func (l *leg) levels() []models.BoardBar {
+	if l.typ == models.Ask {
+		return l.board.Asks
+	}
+	return l.board.Bids
+}
+
+// breakpoints returns the cumulative amounts of from the levels of the
+// board take.
+func (l *leg) breakpoints() []decimal.Decimal {
+	var points []decimal.Decimal
+	sum := decimal.Zero
+	for _, bar := range l.levels() {
+		if l.typ == models.Ask {
+			sum = sum.Add(bar.Amount.Mul(bar.Price))
+		} else {
+			sum = sum.Add(bar.Amount)
+		}
+		points = append(points, sum)
+	}
+	return points
+}
+
+// trade converts up to amount of from into to across the board, returning
+// what it spends and receives, and the trading amount. It fails when the
+// board is too thin.
+func (l *leg) trade(amount decimal.Decimal, round bool) (spent decimal.Decimal, received decimal.Decimal, traded decimal.Decimal, ok bool) {
+	if l.typ == models.Bid {
+		traded = amount
+		if round {
+			traded = floor(amount, l.precision)
+		}
+		quote, ok := fill(l.board.Bids, traded)
+		return traded, quote.Mul(one.Sub(l.fee)), traded, ok
+	}
+	remaining := amount
+	for _, bar := range l.board.Asks {
+		if !remaining.IsPositive() {
+			break
+		}
+		if take := remaining.Div(bar.Price); take.LessThan(bar.Amount) {
+			traded = traded.Add(take)
+			remaining = decimal.Zero
+		} else {
+			traded = traded.Add(bar.Amount)
+			remaining = remaining.Sub(bar.Amount.Mul(bar.Price))
+		}
+	}
+	if remaining.IsPositive() {
+		return decimal.Zero, decimal.Zero, decimal.Zero, false
+	}
+	if round {
+		traded = floor(traded, l.precision)
+	}
+	spent, ok = fill(l.board.Asks, traded)
+	return spent, traded.Mul(one.Sub(l.fee)), traded, ok
+}
+
+// floor rounds v down to a multiple of the step of precision decimals.
+func floor(v decimal.Decimal, precision int) decimal.Decimal {
+	return v.FloorStep(decimal.New(1, -int32(precision)))
+}
+
+// run trades amount of the first currency through the legs.
+func run(legs [3]*leg, amount decimal.Decimal, round bool) (Cycle, bool) {
+	var c Cycle
+	in := amount
+	for i, l := range legs {
+		spent, received, traded, ok := l.trade(in, round)
+		if !ok || !traded.IsPositive() {
+			return Cycle{}, false
+		}
+		if i == 0 {
+			c.Amount = spent
+		}
+		price := spent.Div(traded)
+		if l.typ == models.Bid {
+			price = received.Div(one.Sub(l.fee)).Div(traded)
+		}
+		c.Legs[i] = Leg{Trading: l.pair.Trading, Settlement: l.pair.Settlement, Type: l.typ, Price: price, Amount: traded}
+		in = received
+	}
+	c.Return = in
+	c.Profit = c.Return.Sub(c.Amount)
+	c.ProfitRate = c.Profit.Div(c.Amount)
+	return c, true
+}
+
+// size returns the most profitable amount to run through the cycle. Its
+// profit is linear between the breakpoints of the boards, which are mapped
+// back to the first currency by bisection, so only those are evaluated.
+func (e *evaluation) size(edges [3]edge) (Cycle, bool) {
+	var legs [3]*leg
+	for i, ed := range edges {
+		l, ok := e.leg(ed)
+		if !ok {
+			return Cycle{}, false
+		}
+		legs[i] = l
+	}
+	depth := legs[0].breakpoints()
+	if len(depth) == 0 {
+		return Cycle{}, false
+	}
+	total := depth[len(depth)-1]
+	amounts := append([]decimal.Decimal(nil), depth...)
+	two := decimal.NewFromInt(2)
+	for i := 1; i < len(legs); i++ {
+		// reaches reports whether amount brings at least point into leg i
+		reaches := func(amount decimal.Decimal, point decimal.Decimal) bool {
+			in := amount
+			for _, l := range legs[:i] {
+				_, received, _, ok := l.trade(in, false)
+				if !ok {
+					return true
+				}
+				in = received
+			}
+			return !in.LessThan(point)
+		}
+		for _, point := range legs[i].breakpoints() {
+			if !reaches(total, point) {
+				break
+			}
+			lo, hi := decimal.Zero, total
+			for n := 0; n < 64; n++ {
+				mid := lo.Add(hi).Div(two)
+				if reaches(mid, point) {
+					hi = mid
+				} else {
+					lo = mid
+				}
+			}
+			amounts = append(amounts, lo)
+		}
+	}
+	var best Cycle
+	for _, amount := range amounts {
+		c, ok := run(legs, amount, true)
+		if ok && c.Profit.GreaterThan(best.Profit) {
+			best = c
+		}
+	}
+	if !best.Profit.IsPositive() {
+		return Cycle{}, false
+	}
+	best.Currencies = [3]string{edges[0].from, edges[1].from, edges[2].from}
+	best.Time = time.Now()
+	return best, true
+}
@@ -0,0 +1,87 @@
+package arbitrage
+
+import (
+	"context"
+	"testing"
+
+	"github.com/fxpgr/go-exchange-client/api/public/mocks"
+	"github.com/fxpgr/go-exchange-client/decimal"
+	"github.com/fxpgr/go-exchange-client/models"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestTriangularFinderFind(t *testing.T) {
+	boards := map[models.CurrencyPair]*models.Board{
+		{Trading: "ETH", Settlement: "BTC"}: {
+			Asks: []models.BoardBar{{Price: d("0.03"), Amount: d("10")}},
+			Bids: []models.BoardBar{{Price: d("0.0299"), Amount: d("10")}},
+		},
+		{Trading: "ETH", Settlement: "USDT"}: {
+			Asks: []models.BoardBar{{Price: d("311"), Amount: d("10")}},
+			Bids: []models.BoardBar{{Price: d("310"), Amount: d("5")}, {Price: d("290"), Amount: d("10")}},
+		},
+		{Trading: "BTC", Settlement: "USDT"}: {
+			Asks: []models.BoardBar{{Price: d("10000"), Amount: d("1")}},
+			Bids: []models.BoardBar{{Price: d("9990"), Amount: d("1")}},
+		},
+	}
+	pub := new(mocks.PublicClient)
+	pub.On("WithContext", mock.Anything).Return(pub)
+	var pairs []models.CurrencyPair
+	ticks := make(map[string]map[string]models.OrderBookTick)
+	for p, b := range boards {
+		pairs = append(pairs, p)
+		if ticks[p.Trading] == nil {
+			ticks[p.Trading] = make(map[string]models.OrderBookTick)
+		}
+		ticks[p.Trading][p.Settlement] = models.OrderBookTick{BestAskPrice: b.BestAskPrice(), BestBidPrice: b.BestBidPrice()}
+		pub.On("Board", p.Trading, p.Settlement).Return(b, nil)
+		pub.On("Precise", p.Trading, p.Settlement).Return(&models.Precisions{PricePrecision: 8, AmountPrecision: 3}, nil)
+	}
+	pub.On("CurrencyPairs").Return(pairs, nil)
+	pub.On("OrderBookTickMap").Return(ticks, nil)
+
+	finder := NewTriangularFinder(Venue{Exchange: "a", Public: pub, Private: newTestPrivateClient(0.001, 0)}, decimal.Zero)
+	cycles, err := finder.Find(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(cycles) != 1 {
+		t.Fatalf("TriangularFinder: Expected %v cycle. Got %+v", 1, cycles)
+	}
+	c := cycles[0]
+	if c.Currencies != [3]string{"BTC", "ETH", "USDT"} || c.Legs[0].Type != models.Ask || c.Legs[1].Type != models.Bid || c.Legs[2].Type != models.Ask {
+		t.Errorf("TriangularFinder: unexpected cycle %+v", c)
+	}
+	// the depth of ETH/USDT at 310 limits the cycle, and amounts are rounded
+	// to 3 decimals
+	if !c.Legs[0].Amount.Equal(d("5.005")) || !c.Legs[1].Amount.Equal(d("4.999")) || !c.Legs[2].Amount.Equal(d("0.154")) {
+		t.Errorf("TriangularFinder: unexpected amounts %+v", c.Legs)
+	}
+	if !c.Amount.Equal(d("0.15015")) || !c.Return.Equal(d("0.153846")) || c.ProfitRate.LessThan(d("0.02")) {
+		t.Errorf("TriangularFinder: unexpected cycle %+v", c)
+	}
+
+	finder.MinProfitRate = d("0.05")
+	if cycles, _ := finder.Find(context.Background()); len(cycles) != 0 {
+		t.Errorf("TriangularFinder: Expected no cycle. Got %+v", cycles)
+	}
+}
+
+func TestFloor(t *testing.T) {
+	for _, c := range []struct {
+		v         string
+		precision int
+		want      string
+	}{
+		{"0.9999999995", 0, "0"},
+		{"0.9999999995", 8, "0.99999999"},
+		{"5.005", 3, "5.005"},
+		{"0.154", 3, "0.154"},
+		{"1.23456", 2, "1.23"},
+	} {
+		if got := floor(d(c.v), c.precision); !got.Equal(d(c.want)) {
+			t.Errorf("floor(%v, %v): Expected %v. Got %v", c.v, c.precision, c.want, got)
+		}
+	}
+}
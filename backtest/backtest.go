@@ -0,0 +1,199 @@
+package backtest
+
+import (
+	"context"
+	"time"
+
+	"github.com/fxpgr/go-exchange-client/api/private"
+	"github.com/fxpgr/go-exchange-client/api/public"
+	"github.com/fxpgr/go-exchange-client/decimal"
+	"github.com/fxpgr/go-exchange-client/models"
+	"github.com/fxpgr/go-exchange-client/replay"
+	"github.com/pkg/errors"
+)
+
+// Strategy is called at every snapshot time of the recording with clients
+// serving the market as it was then. Returning an error stops the backtest.
+type Strategy func(now time.Time, pub public.PublicClient, priv private.PrivateClient) error
+
+// Config of a Backtest. Orders and cancels reach the simulated exchange
+// Latency after they are sent, and the report values everything in Quote
+// through the boards of Pairs.
+type Config struct {
+	Exchange string
+	Pairs    []models.CurrencyPair
+	Quote    string
+	Balances map[string]decimal.Decimal
+	Fee      private.TradeFee
+	Latency  time.Duration
+}
+
+// Backtest runs a strategy over a recording, or over synthetic boards added
+// to a replay.Recording in memory.
+type Backtest struct {
+	Config    Config
+	recording *replay.Recording
+}
+
+func NewBacktest(recording *replay.Recording, config Config) *Backtest {
+	return &Backtest{Config: config, recording: recording}
+}
+
+func (b *Backtest) Run(strategy Strategy) (*Report, error) {
+	clock := replay.NewClock(b.recording.Start())
+	pub := replay.NewReplayApi(b.recording, b.Config.Exchange, clock)
+	paper := private.NewPaperApi(pub, b.Config.Balances, b.Config.Fee)
+	paper.Now = clock.Now
+	client := &Client{
+		PaperApi: paper,
+		Latency:  b.Config.Latency,
+		clock:    clock,
+		pub:      pub,
+		expected: make(map[string]decimal.Decimal),
+		pairs:    make(map[models.CurrencyPair]bool),
+	}
+	report := &Report{Quote: b.Config.Quote, Fees: make(map[string]decimal.Decimal)}
+	for _, t := range b.recording.Times() {
+		clock.Set(t)
+		// fill the resting orders the market has crossed by now
+		if _, err := paper.ActiveOrders(); err != nil {
+			return nil, err
+		}
+		if err := strategy(t, pub, client); err != nil {
+			return nil, errors.Wrapf(err, "strategy failed at %s", t)
+		}
+		if equity, ok := b.equity(pub, paper); ok {
+			report.addEquity(t, equity)
+		}
+	}
+	for pair := range client.pairs {
+		trades, err := paper.MyTrades(pair.Trading, pair.Settlement, time.Time{}, 0)
+		if err != nil {
+			return nil, err
+		}
+		for _, t := range trades {
+			report.addFill(t, client.expected[t.OrderID])
+		}
+	}
+	report.finish()
+	return report, nil
+}
+
+// equity values the balances of paper in Quote at the mid prices of Pairs,
+// failing while a currency held has no board yet.
+func (b *Backtest) equity(pub public.PublicClient, paper *private.PaperApi) (decimal.Decimal, bool) {
+	balances, err := paper.CompleteBalances()
+	if err != nil {
+		return decimal.Zero, false
+	}
+	equity := decimal.Zero
+	for c, v := range balances {
+		amount := v.Available.Add(v.OnOrders)
+		if amount.IsZero() {
+			continue
+		}
+		rate, ok := b.rate(pub, c)
+		if !ok {
+			return decimal.Zero, false
+		}
+		equity = equity.Add(amount.Mul(rate))
+	}
+	return equity, true
+}
+
+func (b *Backtest) rate(pub public.PublicClient, currency string) (decimal.Decimal, bool) {
+	one := decimal.NewFromInt(1)
+	if currency == b.Config.Quote {
+		return one, true
+	}
+	for _, p := range b.Config.Pairs {
+		var invert bool
+		switch {
+		case p.Trading == currency && p.Settlement == b.Config.Quote:
+		case p.Trading == b.Config.Quote && p.Settlement == currency:
+			invert = true
+		default:
+			continue
+		}
+		board, err := pub.Board(p.Trading, p.Settlement)
+		if err != nil || len(board.Asks) == 0 || len(board.Bids) == 0 {
+			return decimal.Zero, false
+		}
+		mid := board.BestAskPrice().Add(board.BestBidPrice()).Div(decimal.NewFromInt(2))
+		if !mid.IsPositive() {
+			return decimal.Zero, false
+		}
+		if invert {
+			return one.Div(mid), true
+		}
+		return mid, true
+	}
+	return decimal.Zero, false
+}
+
+// Client is the PrivateClient of a backtest. Orders and cancels take effect
+// Latency after they are sent, against the market as it is then, while the
+// strategy keeps seeing the market at the current step.
+type Client struct {
+	*private.PaperApi
+	Latency time.Duration
+
+	clock *replay.Clock
+	pub   public.PublicClient
+	// expected holds the average rate of the board an order was decided on
+	expected map[string]decimal.Decimal
+	pairs    map[models.CurrencyPair]bool
+}
+
+func (c *Client) WithContext(ctx context.Context) private.PrivateClient {
+	return c
+}
+
+// delayed runs f at the time the exchange receives a request sent now.
+func (c *Client) delayed(f func() error) error {
+	now := c.clock.Now()
+	c.clock.Set(now.Add(c.Latency))
+	defer c.clock.Set(now)
+	return f()
+}
+
+func (c *Client) Order(trading string, settlement string,
+	ordertype models.OrderType, price float64, amount float64) (string, error) {
+	return c.PlaceOrder(models.OrderRequest{
+		Trading:    trading,
+		Settlement: settlement,
+		Type:       ordertype,
+		Price:      decimal.NewFromFloat(price),
+		Amount:     decimal.NewFromFloat(amount),
+	})
+}
+
+func (c *Client) PlaceOrder(req models.OrderRequest) (string, error) {
+	var expected decimal.Decimal
+	if board, err := c.pub.Board(req.Trading, req.Settlement); err == nil {
+		if req.Type == models.Ask {
+			expected, _ = board.AverageAskRate(req.Amount)
+		} else {
+			expected, _ = board.AverageBidRate(req.Amount)
+		}
+	}
+	var id string
+	err := c.delayed(func() error {
+		var err error
+		id, err = c.PaperApi.PlaceOrder(req)
+		return err
+	})
+	if err != nil {
+		return "", err
+	}
+	c.expected[id] = expected
+	c.pairs[models.CurrencyPair{Trading: req.Trading, Settlement: req.Settlement}] = true
+	return id, nil
+}
+
+func (c *Client) CancelOrder(trading string, settlement string,
+	ordertype models.OrderType, orderNumber string) error {
+	return c.delayed(func() error {
+		return c.PaperApi.CancelOrder(trading, settlement, ordertype, orderNumber)
+	})
+}
@@ -0,0 +1,58 @@
+package backtest
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/fxpgr/go-exchange-client/api/private"
+	"github.com/fxpgr/go-exchange-client/api/public"
+	"github.com/fxpgr/go-exchange-client/decimal"
+	"github.com/fxpgr/go-exchange-client/models"
+	"github.com/fxpgr/go-exchange-client/recorder"
+	"github.com/fxpgr/go-exchange-client/replay"
+)
+
+func TestBacktestRun(t *testing.T) {
+	start := time.Date(2019, 1, 1, 0, 0, 0, 0, time.UTC)
+	recording := new(replay.Recording)
+	for _, s := range []struct {
+		after time.Duration
+		ask   int64
+	}{{0, 100}, {3 * time.Second, 105}, {10 * time.Second, 90}} {
+		recording.Add(&recorder.Record{
+			Time:       start.Add(s.after),
+			Exchange:   "binance",
+			Kind:       recorder.KindBoard,
+			Trading:    "BTC",
+			Settlement: "USD",
+			Board: &models.Board{
+				Asks: []models.BoardBar{{Price: decimal.NewFromInt(s.ask), Amount: decimal.NewFromInt(1)}},
+				Bids: []models.BoardBar{{Price: decimal.NewFromInt(s.ask - 1), Amount: decimal.NewFromInt(1)}},
+			},
+		})
+	}
+	bt := NewBacktest(recording, Config{
+		Exchange: "binance",
+		Pairs:    []models.CurrencyPair{{Trading: "BTC", Settlement: "USD"}},
+		Quote:    "USD",
+		Balances: map[string]decimal.Decimal{"USD": decimal.NewFromInt(1000)},
+		Latency:  5 * time.Second,
+	})
+	report, err := bt.Run(func(now time.Time, pub public.PublicClient, priv private.PrivateClient) error {
+		if !now.Equal(start) {
+			return nil
+		}
+		_, err := priv.Order("BTC", "USD", models.Ask, 200, 1)
+		return err
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(report.Fills) != 1 || !report.Fills[0].Price.Equal(decimal.NewFromInt(105)) || !report.Slippage["USD"].Equal(decimal.NewFromInt(5)) {
+		t.Errorf("Backtest: unexpected fills %+v", report.Fills)
+	}
+	if len(report.Equity) != 3 || !report.PnL.Equal(decimal.NewFromInt(-10)) || math.Abs(report.MaxDrawdown.Float64()-15/999.5) > 1e-9 {
+		t.Errorf("Backtest: unexpected report %+v", report)
+	}
+}
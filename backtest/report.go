@@ -0,0 +1,86 @@
+package backtest
+
+import (
+	"sort"
+	"time"
+
+	"github.com/fxpgr/go-exchange-client/decimal"
+	"github.com/fxpgr/go-exchange-client/models"
+)
+
+// Fill is a fill of the backtest. Expected is the average rate of the board
+// the order was decided on for its whole amount, zero when the board was too
+// thin, and Slippage is what the fill cost beyond it in the settlement
+// currency.
+type Fill struct {
+	*models.Trade
+	Expected decimal.Decimal
+	Slippage decimal.Decimal
+}
+
+type EquityPoint struct {
+	Time   time.Time
+	Equity decimal.Decimal
+}
+
+// Report of a backtest. Equity is valued in Quote, Fees are per currency and
+// MaxDrawdown is the largest fall from a peak of Equity, as a fraction of
+// the peak.
+type Report struct {
+	Quote       string
+	Start       time.Time
+	End         time.Time
+	StartEquity decimal.Decimal
+	EndEquity   decimal.Decimal
+	PnL         decimal.Decimal
+	MaxDrawdown decimal.Decimal
+	Equity      []EquityPoint
+	Fills       []Fill
+	Fees        map[string]decimal.Decimal
+	Slippage    map[string]decimal.Decimal
+}
+
+func (r *Report) addEquity(t time.Time, equity decimal.Decimal) {
+	r.Equity = append(r.Equity, EquityPoint{Time: t, Equity: equity})
+}
+
+func (r *Report) addFill(t *models.Trade, expected decimal.Decimal) {
+	f := Fill{Trade: t, Expected: expected}
+	if expected.IsPositive() {
+		f.Slippage = t.Price.Sub(expected).Mul(t.Amount)
+		if t.Type == models.Bid {
+			f.Slippage = f.Slippage.Neg()
+		}
+	}
+	r.Fills = append(r.Fills, f)
+	r.Fees[t.FeeCurrency] = r.Fees[t.FeeCurrency].Add(t.Fee)
+}
+
+func (r *Report) finish() {
+	sort.SliceStable(r.Fills, func(i, j int) bool {
+		return r.Fills[i].Time.Before(r.Fills[j].Time)
+	})
+	r.Slippage = make(map[string]decimal.Decimal)
+	for _, f := range r.Fills {
+		r.Slippage[f.Settlement] = r.Slippage[f.Settlement].Add(f.Slippage)
+	}
+	if len(r.Equity) == 0 {
+		return
+	}
+	first, last := r.Equity[0], r.Equity[len(r.Equity)-1]
+	r.Start, r.End = first.Time, last.Time
+	r.StartEquity, r.EndEquity = first.Equity, last.Equity
+	r.PnL = last.Equity.Sub(first.Equity)
+	peak := first.Equity
+	for _, p := range r.Equity {
+		if p.Equity.GreaterThan(peak) {
+			peak = p.Equity
+		}
+		if !peak.IsPositive() {
+			continue
+		}
+		if drawdown := peak.Sub(p.Equity).Div(peak); drawdown.GreaterThan(r.MaxDrawdown) {
+			r.MaxDrawdown = drawdown
+		}
+	}
+}
@@ -0,0 +1,71 @@
+// Command recorder records the public market data of exchanges until it is
+// interrupted. Every argument is an exchange and the pairs whose boards are
+// recorded, such as binance:ETH/BTC,LTC/BTC.
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+
+	"github.com/fxpgr/go-exchange-client/logger"
+	"github.com/fxpgr/go-exchange-client/models"
+	"github.com/fxpgr/go-exchange-client/recorder"
+)
+
+func parseTarget(arg string) (recorder.Target, error) {
+	xs := strings.SplitN(arg, ":", 2)
+	t := recorder.Target{Exchange: xs[0]}
+	if len(xs) == 1 || xs[1] == "" {
+		return t, nil
+	}
+	for _, p := range strings.Split(xs[1], ",") {
+		ps := strings.Split(p, "/")
+		if len(ps) != 2 {
+			return t, fmt.Errorf("invalid pair %s", p)
+		}
+		t.Pairs = append(t.Pairs, models.CurrencyPair{Trading: ps[0], Settlement: ps[1]})
+	}
+	return t, nil
+}
+
+func main() {
+	config := recorder.DefaultConfig
+	flag.StringVar(&config.Dir, "dir", config.Dir, "directory of the recording")
+	flag.DurationVar(&config.Interval, "interval", config.Interval, "interval of board and tick snapshots")
+	flag.DurationVar(&config.MetadataInterval, "metadata-interval", config.MetadataInterval, "interval of pair and precision snapshots")
+	flag.Int64Var(&config.MaxFileSize, "max-size", config.MaxFileSize, "compressed size of a segment before rotating, 0 for no limit")
+	flag.DurationVar(&config.MaxFileAge, "max-age", config.MaxFileAge, "age of a segment before rotating, 0 for no limit")
+	flag.Parse()
+	for _, arg := range flag.Args() {
+		t, err := parseTarget(arg)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(2)
+		}
+		config.Targets = append(config.Targets, t)
+	}
+	if len(config.Targets) == 0 {
+		fmt.Fprintln(os.Stderr, "usage: recorder [flags] exchange[:TRADING/SETTLEMENT,...]...")
+		os.Exit(2)
+	}
+
+	r, err := recorder.NewRecorder(config)
+	if err != nil {
+		logger.Get().Fatal(err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	sig := make(chan os.Signal, 1)
+	signal.Notify(sig, syscall.SIGINT, syscall.SIGTERM)
+	go func() {
+		<-sig
+		cancel()
+	}()
+	if err := r.Run(ctx); err != nil {
+		logger.Get().Fatal(err)
+	}
+}
@@ -0,0 +1,339 @@
+// Package decimal implements exact decimal numbers for prices, amounts and
+// balances, which float64 cannot hold for the 8 decimal assets exchanges
+// trade.
+package decimal
+
+import (
+	"math"
+	"math/big"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// DivisionPrecision is the number of decimals Div rounds its quotient to.
+var DivisionPrecision int32 = 16
+
+// Zero is the zero value of Decimal, which is ready to use.
+var Zero = Decimal{}
+
+var ten = big.NewInt(10)
+
+// Decimal is the number value / 10^scale. Decimals are immutable, every
+// operation returns a new one.
+type Decimal struct {
+	value *big.Int
+	scale int32
+}
+
+// New returns value * 10^exp.
+func New(value int64, exp int32) Decimal {
+	if exp >= 0 {
+		return Decimal{value: new(big.Int).Mul(big.NewInt(value), pow10(exp))}
+	}
+	return Decimal{value: big.NewInt(value), scale: -exp}
+}
+
+func NewFromInt(i int64) Decimal {
+	return Decimal{value: big.NewInt(i)}
+}
+
+// NewFromFloat returns the decimal of the shortest representation of f, so
+// 0.1 is exactly 0.1. NaN and infinities are zero.
+func NewFromFloat(f float64) Decimal {
+	if math.IsNaN(f) || math.IsInf(f, 0) {
+		return Zero
+	}
+	d, _ := NewFromString(strconv.FormatFloat(f, 'f', -1, 64))
+	return d
+}
+
+// NewFromString parses a decimal such as "-12.345" or "5.0E-4".
+func NewFromString(s string) (Decimal, error) {
+	mantissa, exp := s, int64(0)
+	if i := strings.IndexAny(s, "eE"); i >= 0 {
+		e, err := strconv.ParseInt(s[i+1:], 10, 32)
+		if err != nil {
+			return Zero, errors.Errorf("invalid decimal %q", s)
+		}
+		mantissa, exp = s[:i], e
+	}
+	intPart, fracPart := mantissa, ""
+	if i := strings.IndexByte(mantissa, '.'); i >= 0 {
+		intPart, fracPart = mantissa[:i], mantissa[i+1:]
+	}
+	sign := ""
+	if len(intPart) > 0 && (intPart[0] == '-' || intPart[0] == '+') {
+		sign, intPart = intPart[:1], intPart[1:]
+	}
+	digits := intPart + fracPart
+	if digits == "" || strings.Trim(digits, "0123456789") != "" {
+		return Zero, errors.Errorf("invalid decimal %q", s)
+	}
+	value, _ := new(big.Int).SetString(sign+digits, 10)
+	exp -= int64(len(fracPart))
+	if exp < math.MinInt32 || exp > math.MaxInt32 {
+		return Zero, errors.Errorf("decimal %q out of range", s)
+	}
+	if exp > 0 {
+		value.Mul(value, pow10(int32(exp)))
+		exp = 0
+	}
+	return Decimal{value: value, scale: int32(-exp)}, nil
+}
+
+// RequireFromString is NewFromString for constants, panicking when s is
+// invalid.
+func RequireFromString(s string) Decimal {
+	d, err := NewFromString(s)
+	if err != nil {
+		panic(err)
+	}
+	return d
+}
+
+func pow10(n int32) *big.Int {
+	return new(big.Int).Exp(ten, big.NewInt(int64(n)), nil)
+}
+
+func (d Decimal) val() *big.Int {
+	if d.value == nil {
+		return new(big.Int)
+	}
+	return d.value
+}
+
+// rescale returns the value of d at scale, which is not below d.scale.
+func (d Decimal) rescale(scale int32) *big.Int {
+	if scale == d.scale {
+		return d.val()
+	}
+	return new(big.Int).Mul(d.val(), pow10(scale-d.scale))
+}
+
+func align(d, d2 Decimal) (*big.Int, *big.Int, int32) {
+	scale := d.scale
+	if d2.scale > scale {
+		scale = d2.scale
+	}
+	return d.rescale(scale), d2.rescale(scale), scale
+}
+
+func (d Decimal) Add(d2 Decimal) Decimal {
+	a, b, scale := align(d, d2)
+	return Decimal{value: new(big.Int).Add(a, b), scale: scale}
+}
+
+func (d Decimal) Sub(d2 Decimal) Decimal {
+	a, b, scale := align(d, d2)
+	return Decimal{value: new(big.Int).Sub(a, b), scale: scale}
+}
+
+func (d Decimal) Mul(d2 Decimal) Decimal {
+	return Decimal{value: new(big.Int).Mul(d.val(), d2.val()), scale: d.scale + d2.scale}
+}
+
+// Div returns d / d2 rounded half away from zero to DivisionPrecision
+// decimals. It panics when d2 is zero.
+func (d Decimal) Div(d2 Decimal) Decimal {
+	if d2.IsZero() {
+		panic("decimal division by zero")
+	}
+	num := new(big.Int).Mul(d.val(), pow10(DivisionPrecision+d2.scale))
+	den := new(big.Int).Mul(d2.val(), pow10(d.scale))
+	return Decimal{value: quoRound(num, den), scale: DivisionPrecision}
+}
+
+// quoRound returns num / den rounded half away from zero.
+func quoRound(num, den *big.Int) *big.Int {
+	q, r := new(big.Int).QuoRem(num, den, new(big.Int))
+	if new(big.Int).Abs(new(big.Int).Lsh(r, 1)).Cmp(new(big.Int).Abs(den)) >= 0 {
+		if num.Sign() == den.Sign() {
+			q.Add(q, big.NewInt(1))
+		} else {
+			q.Sub(q, big.NewInt(1))
+		}
+	}
+	return q
+}
+
+func (d Decimal) Neg() Decimal {
+	return Decimal{value: new(big.Int).Neg(d.val()), scale: d.scale}
+}
+
+func (d Decimal) Abs() Decimal {
+	return Decimal{value: new(big.Int).Abs(d.val()), scale: d.scale}
+}
+
+// Cmp returns -1, 0 or 1 as d is less than, equal to or greater than d2.
+func (d Decimal) Cmp(d2 Decimal) int {
+	a, b, _ := align(d, d2)
+	return a.Cmp(b)
+}
+
+func (d Decimal) Equal(d2 Decimal) bool {
+	return d.Cmp(d2) == 0
+}
+
+func (d Decimal) LessThan(d2 Decimal) bool {
+	return d.Cmp(d2) < 0
+}
+
+func (d Decimal) GreaterThan(d2 Decimal) bool {
+	return d.Cmp(d2) > 0
+}
+
+func (d Decimal) Sign() int {
+	return d.val().Sign()
+}
+
+func (d Decimal) IsZero() bool {
+	return d.Sign() == 0
+}
+
+func (d Decimal) IsPositive() bool {
+	return d.Sign() > 0
+}
+
+func (d Decimal) IsNegative() bool {
+	return d.Sign() < 0
+}
+
+// Truncate drops the decimals of d beyond places, rounding toward zero.
+func (d Decimal) Truncate(places int32) Decimal {
+	if places < 0 || d.scale <= places {
+		return d
+	}
+	return Decimal{value: new(big.Int).Quo(d.val(), pow10(d.scale-places)), scale: places}
+}
+
+// Round rounds d half away from zero to places decimals.
+func (d Decimal) Round(places int32) Decimal {
+	if places < 0 || d.scale <= places {
+		return d
+	}
+	return Decimal{value: quoRound(d.val(), pow10(d.scale-places)), scale: places}
+}
+
+// FloorStep returns the greatest multiple of step not above d, and CeilStep
+// the least not below it. Both return d when step is not positive.
+func (d Decimal) FloorStep(step Decimal) Decimal {
+	return d.roundStep(step, false)
+}
+
+func (d Decimal) CeilStep(step Decimal) Decimal {
+	return d.roundStep(step, true)
+}
+
+func (d Decimal) roundStep(step Decimal, up bool) Decimal {
+	if !step.IsPositive() {
+		return d
+	}
+	a, b, _ := align(d, step)
+	q, m := new(big.Int).DivMod(a, b, new(big.Int))
+	if up && m.Sign() != 0 {
+		q.Add(q, big.NewInt(1))
+	}
+	return Decimal{value: new(big.Int).Mul(q, step.val()), scale: step.scale}
+}
+
+// Places returns the number of decimals of d, ignoring trailing zeros.
+func (d Decimal) Places() int32 {
+	return d.normalize().scale
+}
+
+func (d Decimal) normalize() Decimal {
+	value, scale := d.val(), d.scale
+	if value.Sign() == 0 {
+		return Zero
+	}
+	q, r := new(big.Int), new(big.Int)
+	for scale > 0 {
+		q.QuoRem(value, ten, r)
+		if r.Sign() != 0 {
+			break
+		}
+		value, scale = new(big.Int).Set(q), scale-1
+	}
+	return Decimal{value: value, scale: scale}
+}
+
+// Float64 is the float compatibility accessor, returning the nearest float64
+// to d.
+func (d Decimal) Float64() float64 {
+	f, _ := strconv.ParseFloat(d.String(), 64)
+	return f
+}
+
+// String returns d exactly, without trailing zeros.
+func (d Decimal) String() string {
+	n := d.normalize()
+	return n.format(n.scale)
+}
+
+// StringFixed returns d rounded half away from zero to places decimals, with
+// trailing zeros kept.
+func (d Decimal) StringFixed(places int32) string {
+	if places < 0 {
+		places = 0
+	}
+	return d.Round(places).format(places)
+}
+
+// format writes d with places decimals, which is not below d.scale.
+func (d Decimal) format(places int32) string {
+	value := d.rescale(places)
+	digits := new(big.Int).Abs(value).String()
+	if places > 0 {
+		if pad := int(places) + 1 - len(digits); pad > 0 {
+			digits = strings.Repeat("0", pad) + digits
+		}
+		i := len(digits) - int(places)
+		digits = digits[:i] + "." + digits[i:]
+	}
+	if value.Sign() < 0 {
+		return "-" + digits
+	}
+	return digits
+}
+
+// MarshalJSON writes d as a JSON number, so documents written with float64
+// fields read back unchanged.
+func (d Decimal) MarshalJSON() ([]byte, error) {
+	return []byte(d.String()), nil
+}
+
+// UnmarshalJSON reads a JSON number or a quoted decimal. Null and the empty
+// string are zero.
+func (d *Decimal) UnmarshalJSON(b []byte) error {
+	s := strings.Trim(string(b), `"`)
+	if s == "" || s == "null" {
+		*d = Zero
+		return nil
+	}
+	v, err := NewFromString(s)
+	if err != nil {
+		return err
+	}
+	*d = v
+	return nil
+}
+
+func Min(first Decimal, rest ...Decimal) Decimal {
+	for _, d := range rest {
+		if d.LessThan(first) {
+			first = d
+		}
+	}
+	return first
+}
+
+func Max(first Decimal, rest ...Decimal) Decimal {
+	for _, d := range rest {
+		if d.GreaterThan(first) {
+			first = d
+		}
+	}
+	return first
+}
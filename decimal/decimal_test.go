@@ -0,0 +1,102 @@
+package decimal
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestNewFromString(t *testing.T) {
+	tests := []struct {
+		s        string
+		expected string
+	}{
+		{"0.29", "0.29"},
+		{"-12.3400", "-12.34"},
+		{"5.0E-4", "0.0005"},
+		{"1e3", "1000"},
+		{"+.5", "0.5"},
+		{"00012", "12"},
+	}
+	for _, test := range tests {
+		d, err := NewFromString(test.s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if s := d.String(); s != test.expected {
+			t.Errorf("%v: Expected %v. Got %v", test.s, test.expected, s)
+		}
+	}
+	for _, s := range []string{"", "-", "1.2.3", "abc", "1e"} {
+		if _, err := NewFromString(s); err == nil {
+			t.Errorf("%q: Expected an error", s)
+		}
+	}
+}
+
+func TestArithmetic(t *testing.T) {
+	a := RequireFromString("0.1")
+	b := RequireFromString("0.2")
+	if s := a.Add(b).String(); s != "0.3" {
+		t.Errorf("Expected %v. Got %v", "0.3", s)
+	}
+	if s := a.Sub(b).String(); s != "-0.1" {
+		t.Errorf("Expected %v. Got %v", "-0.1", s)
+	}
+	if s := RequireFromString("0.00000001").Mul(NewFromInt(3)).String(); s != "0.00000003" {
+		t.Errorf("Expected %v. Got %v", "0.00000003", s)
+	}
+	if s := NewFromInt(2).Div(NewFromInt(3)).String(); s != "0.6666666666666667" {
+		t.Errorf("Expected %v. Got %v", "0.6666666666666667", s)
+	}
+	if !Zero.Add(a).Equal(NewFromFloat(0.1)) || !a.LessThan(b) || Zero.Sign() != 0 {
+		t.Error("unexpected comparison")
+	}
+	if f := a.Add(b).Float64(); f != 0.3 {
+		t.Errorf("Expected %v. Got %v", 0.3, f)
+	}
+}
+
+func TestRounding(t *testing.T) {
+	d := RequireFromString("1.2375")
+	tests := []struct {
+		got      string
+		expected string
+	}{
+		{d.Truncate(2).String(), "1.23"},
+		{d.Neg().Truncate(2).String(), "-1.23"},
+		{d.Round(3).String(), "1.238"},
+		{d.StringFixed(6), "1.237500"},
+		{d.StringFixed(0), "1"},
+		{d.FloorStep(RequireFromString("0.05")).String(), "1.2"},
+		{d.CeilStep(RequireFromString("0.05")).String(), "1.25"},
+		{RequireFromString("1.25").CeilStep(RequireFromString("0.05")).String(), "1.25"},
+		{d.FloorStep(Zero).String(), "1.2375"},
+		{RequireFromString("0.0005").StringFixed(8), "0.00050000"},
+	}
+	for i, test := range tests {
+		if test.got != test.expected {
+			t.Errorf("%d: Expected %v. Got %v", i, test.expected, test.got)
+		}
+	}
+	if p := RequireFromString("0.0100").Places(); p != 2 {
+		t.Errorf("Expected %v. Got %v", 2, p)
+	}
+}
+
+func TestJSON(t *testing.T) {
+	var v struct {
+		Number Decimal `json:"number"`
+		Quoted Decimal `json:"quoted"`
+		Null   Decimal `json:"null"`
+	}
+	if err := json.Unmarshal([]byte(`{"number":0.12345678,"quoted":"1.5","null":null}`), &v); err != nil {
+		t.Fatal(err)
+	}
+	bs, err := json.Marshal(v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s := string(bs); s != `{"number":0.12345678,"quoted":1.5,"null":0}` {
+		t.Errorf("unexpected json %v", s)
+	}
+}
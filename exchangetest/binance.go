@@ -0,0 +1,371 @@
+package exchangetest
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/fxpgr/go-exchange-client/apierrors"
+	"github.com/fxpgr/go-exchange-client/decimal"
+	"github.com/fxpgr/go-exchange-client/models"
+	"github.com/pkg/errors"
+)
+
+// NewBinanceServer starts a server speaking the binance REST API: exchange
+// info, depth, and the signed account, order and trade endpoints.
+func NewBinanceServer(apiKey string, secret string) *Server {
+	return newServer(apiKey, secret, func(s *Server, mux *http.ServeMux) {
+		mux.HandleFunc("/api/v1/time", func(w http.ResponseWriter, r *http.Request) {
+			writeJSON(w, http.StatusOK, map[string]interface{}{"serverTime": millis(time.Now())})
+		})
+		mux.HandleFunc("/api/v1/exchangeInfo", s.binanceExchangeInfo)
+		mux.HandleFunc("/api/v1/depth", s.binanceDepth)
+		mux.HandleFunc("/api/v3/account", s.binanceSigned(s.binanceAccount))
+		mux.HandleFunc("/api/v3/order", s.binanceSigned(s.binanceOrder))
+		mux.HandleFunc("/api/v3/openOrders", s.binanceSigned(s.binanceOpenOrders))
+		mux.HandleFunc("/api/v3/allOrders", s.binanceSigned(s.binanceAllOrders))
+		mux.HandleFunc("/api/v3/myTrades", s.binanceSigned(s.binanceMyTrades))
+	})
+}
+
+func binanceSymbol(p models.CurrencyPair) string {
+	return p.Trading + p.Settlement
+}
+
+func binanceError(w http.ResponseWriter, status int, code int, msg string) {
+	writeJSON(w, status, map[string]interface{}{"code": code, "msg": msg})
+}
+
+// binanceSigned checks the api key header and the HMAC-SHA256 signature of
+// the query, which is computed over the other parameters.
+func (s *Server) binanceSigned(h func(w http.ResponseWriter, r *http.Request)) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-MBX-APIKEY") != s.APIKey {
+			binanceError(w, http.StatusUnauthorized, -2015, "Invalid API-key, IP, or permissions for action.")
+			return
+		}
+		query := r.URL.Query()
+		signature := query.Get("signature")
+		query.Del("signature")
+		mac := hmac.New(sha256.New, []byte(s.Secret))
+		mac.Write([]byte(query.Encode()))
+		if !hmac.Equal([]byte(signature), []byte(hex.EncodeToString(mac.Sum(nil)))) {
+			binanceError(w, http.StatusBadRequest, -1022, "Signature for this request is not valid.")
+			return
+		}
+		timestamp, err := strconv.ParseInt(query.Get("timestamp"), 10, 64)
+		if err != nil {
+			binanceError(w, http.StatusBadRequest, -1102, "Mandatory parameter 'timestamp' was not sent, was empty/null, or malformed.")
+			return
+		}
+		window := int64(5000)
+		if v, err := strconv.ParseInt(query.Get("recvWindow"), 10, 64); err == nil {
+			window = v
+		}
+		if d := millis(time.Now()) - timestamp; d > window || d < -1000 {
+			binanceError(w, http.StatusBadRequest, -1021, "Timestamp for this request is outside of the recvWindow.")
+			return
+		}
+		r.Form = query
+		h(w, r)
+	}
+}
+
+func (s *Server) binanceExchangeInfo(w http.ResponseWriter, r *http.Request) {
+	var symbols []map[string]interface{}
+	for _, p := range s.Pairs() {
+		precisions, _ := s.Precisions(p.Trading, p.Settlement)
+		symbols = append(symbols, map[string]interface{}{
+			"symbol":             binanceSymbol(p),
+			"status":             "TRADING",
+			"baseAsset":          p.Trading,
+			"baseAssetPrecision": 8,
+			"quoteAsset":         p.Settlement,
+			"quotePrecision":     8,
+			"orderTypes":         []string{"LIMIT", "LIMIT_MAKER", "MARKET"},
+			"filters": []map[string]interface{}{
+				{
+					"filterType": "PRICE_FILTER",
+					"minPrice":   formatDecimal(step(precisions.PricePrecision)),
+					"maxPrice":   formatDecimal(decimal.NewFromInt(100000)),
+					"tickSize":   formatDecimal(step(precisions.PricePrecision)),
+				},
+				{
+					"filterType": "LOT_SIZE",
+					"minQty":     formatDecimal(step(precisions.AmountPrecision)),
+					"maxQty":     formatDecimal(decimal.NewFromInt(9000000)),
+					"stepSize":   formatDecimal(step(precisions.AmountPrecision)),
+				},
+			},
+		})
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"timezone":   "UTC",
+		"serverTime": millis(time.Now()),
+		"symbols":    symbols,
+	})
+}
+
+// step returns the smallest increment of a value with precision decimals.
+func step(precision int) decimal.Decimal {
+	return decimal.New(1, -int32(precision))
+}
+
+func (s *Server) binanceDepth(w http.ResponseWriter, r *http.Request) {
+	p, ok := s.findPair(r.URL.Query().Get("symbol"), binanceSymbol)
+	if !ok {
+		binanceError(w, http.StatusBadRequest, -1121, "Invalid symbol.")
+		return
+	}
+	board := s.Board(p.Trading, p.Settlement)
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"lastUpdateId": millis(time.Now()),
+		"bids":         levels(board.Bids),
+		"asks":         levels(board.Asks),
+	})
+}
+
+func (s *Server) binanceAccount(w http.ResponseWriter, r *http.Request) {
+	var assets []string
+	balances := s.Balances()
+	for c := range balances {
+		assets = append(assets, c)
+	}
+	sort.Strings(assets)
+	var list []map[string]string
+	for _, c := range assets {
+		list = append(list, map[string]string{
+			"asset":  c,
+			"free":   formatDecimal(balances[c].Available),
+			"locked": formatDecimal(balances[c].OnOrders),
+		})
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"makerCommission":  int(s.Fee.Mul(decimal.NewFromInt(10000)).Float64()),
+		"takerCommission":  int(s.Fee.Mul(decimal.NewFromInt(10000)).Float64()),
+		"buyerCommission":  0,
+		"sellerCommission": 0,
+		"canTrade":         true,
+		"canWithdraw":      true,
+		"canDeposit":       true,
+		"updateTime":       millis(time.Now()),
+		"accountType":      "SPOT",
+		"balances":         list,
+		"permissions":      []string{"SPOT"},
+	})
+}
+
+var binanceStatus = map[models.OrderStatus]string{
+	models.OrderOpen:            "NEW",
+	models.OrderPartiallyFilled: "PARTIALLY_FILLED",
+	models.OrderFilled:          "FILLED",
+	models.OrderCanceled:        "CANCELED",
+	models.OrderRejected:        "REJECTED",
+	models.OrderExpired:         "EXPIRED",
+}
+
+func binanceOrderJSON(o Order) map[string]interface{} {
+	typ := "LIMIT"
+	switch {
+	case o.ExecutionType == models.Market:
+		typ = "MARKET"
+	case o.TimeInForce == models.PostOnly:
+		typ = "LIMIT_MAKER"
+	}
+	timeInForce := o.TimeInForce.String()
+	if o.TimeInForce == models.PostOnly {
+		timeInForce = "GTC"
+	}
+	orderID, _ := strconv.ParseInt(o.ID, 10, 64)
+	return map[string]interface{}{
+		"symbol":              o.Trading + o.Settlement,
+		"orderId":             orderID,
+		"orderListId":         -1,
+		"clientOrderId":       o.ClientID,
+		"price":               formatDecimal(o.Price),
+		"origQty":             formatDecimal(o.Amount),
+		"executedQty":         formatDecimal(o.Filled),
+		"cummulativeQuoteQty": formatDecimal(o.Quote),
+		"status":              binanceStatus[o.Status],
+		"timeInForce":         timeInForce,
+		"type":                typ,
+		"side":                strings.ToUpper(side(o.Type)),
+		"stopPrice":           formatDecimal(decimal.Zero),
+		"icebergQty":          formatDecimal(decimal.Zero),
+		"time":                millis(o.Created),
+		"updateTime":          millis(o.Updated),
+		"isWorking":           !o.Status.Closed(),
+	}
+}
+
+func (s *Server) binanceOrder(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodPost:
+		s.binancePlace(w, r)
+		return
+	}
+	id := r.Form.Get("origClientOrderId")
+	if id == "" {
+		id = r.Form.Get("orderId")
+	}
+	switch r.Method {
+	case http.MethodGet:
+		o, ok := s.Order(id)
+		if !ok {
+			binanceError(w, http.StatusBadRequest, -2013, "Order does not exist.")
+			return
+		}
+		writeJSON(w, http.StatusOK, binanceOrderJSON(o))
+	case http.MethodDelete:
+		o, err := s.Cancel(id)
+		if err != nil {
+			binanceError(w, http.StatusBadRequest, -2011, "Unknown order sent.")
+			return
+		}
+		v := binanceOrderJSON(o)
+		v["origClientOrderId"] = o.ClientID
+		v["clientOrderId"] = randomID(22)
+		writeJSON(w, http.StatusOK, v)
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
+
+func (s *Server) binancePlace(w http.ResponseWriter, r *http.Request) {
+	p, ok := s.findPair(r.Form.Get("symbol"), binanceSymbol)
+	if !ok {
+		binanceError(w, http.StatusBadRequest, -1121, "Invalid symbol.")
+		return
+	}
+	req := models.OrderRequest{Trading: p.Trading, Settlement: p.Settlement}
+	switch r.Form.Get("side") {
+	case "BUY":
+		req.Type = models.Ask
+	case "SELL":
+		req.Type = models.Bid
+	default:
+		binanceError(w, http.StatusBadRequest, -1117, "Invalid side.")
+		return
+	}
+	switch r.Form.Get("type") {
+	case "MARKET":
+		req.ExecutionType = models.Market
+	case "LIMIT_MAKER":
+		req.TimeInForce = models.PostOnly
+	case "LIMIT":
+		switch r.Form.Get("timeInForce") {
+		case "GTC":
+		case "IOC":
+			req.TimeInForce = models.IOC
+		case "FOK":
+			req.TimeInForce = models.FOK
+		default:
+			binanceError(w, http.StatusBadRequest, -1115, "Invalid timeInForce.")
+			return
+		}
+	default:
+		binanceError(w, http.StatusBadRequest, -1116, "Invalid orderType.")
+		return
+	}
+	req.Amount, _ = decimal.NewFromString(r.Form.Get("quantity"))
+	req.Price, _ = decimal.NewFromString(r.Form.Get("price"))
+	clientID := r.Form.Get("newClientOrderId")
+	if clientID == "" {
+		clientID = randomID(22)
+	}
+	o, err := s.Place(req, clientID)
+	switch {
+	case errors.Is(err, apierrors.ErrInsufficientFunds):
+		binanceError(w, http.StatusBadRequest, -2010, "Account has insufficient balance for requested action.")
+	case errors.Is(err, ErrWouldTake):
+		binanceError(w, http.StatusBadRequest, -2010, "Order would immediately match and take.")
+	case err != nil:
+		binanceError(w, http.StatusBadRequest, -1013, "Filter failure: LOT_SIZE")
+	default:
+		v := binanceOrderJSON(o)
+		v["transactTime"] = millis(o.Created)
+		writeJSON(w, http.StatusOK, v)
+	}
+}
+
+func (s *Server) binanceOpenOrders(w http.ResponseWriter, r *http.Request) {
+	list := []map[string]interface{}{}
+	symbol := r.Form.Get("symbol")
+	for _, o := range s.open() {
+		if symbol == "" || symbol == o.Trading+o.Settlement {
+			list = append(list, binanceOrderJSON(o))
+		}
+	}
+	writeJSON(w, http.StatusOK, list)
+}
+
+func (s *Server) binanceAllOrders(w http.ResponseWriter, r *http.Request) {
+	p, ok := s.findPair(r.Form.Get("symbol"), binanceSymbol)
+	if !ok {
+		binanceError(w, http.StatusBadRequest, -1121, "Invalid symbol.")
+		return
+	}
+	limit := 500
+	if v, err := strconv.Atoi(r.Form.Get("limit")); err == nil && v > 0 && v <= 1000 {
+		limit = v
+	}
+	fromID, _ := strconv.ParseInt(r.Form.Get("orderId"), 10, 64)
+	list := []map[string]interface{}{}
+	for _, o := range s.Orders() {
+		id, _ := strconv.ParseInt(o.ID, 10, 64)
+		if o.Trading != p.Trading || o.Settlement != p.Settlement || id < fromID {
+			continue
+		}
+		if len(list) == limit {
+			break
+		}
+		list = append(list, binanceOrderJSON(o))
+	}
+	writeJSON(w, http.StatusOK, list)
+}
+
+func (s *Server) binanceMyTrades(w http.ResponseWriter, r *http.Request) {
+	p, ok := s.findPair(r.Form.Get("symbol"), binanceSymbol)
+	if !ok {
+		binanceError(w, http.StatusBadRequest, -1121, "Invalid symbol.")
+		return
+	}
+	limit := 500
+	if v, err := strconv.Atoi(r.Form.Get("limit")); err == nil && v > 0 && v <= 1000 {
+		limit = v
+	}
+	fromID, _ := strconv.ParseInt(r.Form.Get("fromId"), 10, 64)
+	startTime, _ := strconv.ParseInt(r.Form.Get("startTime"), 10, 64)
+	orderID := r.Form.Get("orderId")
+	list := []map[string]interface{}{}
+	for _, t := range s.Trades(p.Trading, p.Settlement) {
+		if t.ID < fromID || millis(t.Time) < startTime || orderID != "" && orderID != t.OrderID {
+			continue
+		}
+		if len(list) == limit {
+			break
+		}
+		orderID, _ := strconv.ParseInt(t.OrderID, 10, 64)
+		list = append(list, map[string]interface{}{
+			"symbol":          binanceSymbol(p),
+			"id":              t.ID,
+			"orderId":         orderID,
+			"orderListId":     -1,
+			"price":           formatDecimal(t.Price),
+			"qty":             formatDecimal(t.Amount),
+			"quoteQty":        formatDecimal(t.Price.Mul(t.Amount)),
+			"commission":      formatDecimal(t.Fee),
+			"commissionAsset": t.FeeCurrency,
+			"time":            millis(t.Time),
+			"isBuyer":         t.Type == models.Ask,
+			"isMaker":         t.Maker,
+			"isBestMatch":     true,
+		})
+	}
+	writeJSON(w, http.StatusOK, list)
+}
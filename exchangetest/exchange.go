@@ -0,0 +1,464 @@
+package exchangetest
+
+import (
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/fxpgr/go-exchange-client/apierrors"
+	"github.com/fxpgr/go-exchange-client/decimal"
+	"github.com/fxpgr/go-exchange-client/models"
+	"github.com/pkg/errors"
+)
+
+var (
+	// ErrWouldTake is returned for a post-only order which would cross the
+	// board.
+	ErrWouldTake = errors.New("post-only order would take liquidity")
+	// ErrOrderClosed is returned for a cancel of an order which is no longer
+	// open.
+	ErrOrderClosed  = errors.New("order is closed")
+	ErrInvalidOrder = errors.New("invalid price or amount")
+)
+
+// Order is an order kept by an Exchange. Quote is the settlement exchanged by
+// its fills, and Fee is denominated in FeeCurrency.
+type Order struct {
+	models.OrderRequest
+	ID          string
+	ClientID    string
+	Status      models.OrderStatus
+	Filled      decimal.Decimal
+	Quote       decimal.Decimal
+	Fee         decimal.Decimal
+	FeeCurrency string
+	Created     time.Time
+	Updated     time.Time
+
+	// reserved is what the order still holds of the currency it spends
+	reserved decimal.Decimal
+}
+
+// Trade is a fill of an Order.
+type Trade struct {
+	ID            int64
+	OrderID       string
+	ClientOrderID string
+	Trading       string
+	Settlement    string
+	Type          models.OrderType
+	Price         decimal.Decimal
+	Amount        decimal.Decimal
+	Fee           decimal.Decimal
+	FeeCurrency   string
+	Maker         bool
+	Time          time.Time
+}
+
+// Exchange is the state behind the fake servers: balances, boards, orders and
+// their fills. Orders which cross the board take its liquidity, and resting
+// orders are filled at their own price by the first board set which crosses
+// them. Fee is a rate charged in the currency received, or in the settlement
+// currency on both sides when SettlementFees is set.
+type Exchange struct {
+	Fee            decimal.Decimal
+	SettlementFees bool
+	Now            func() time.Time
+
+	pairs      []models.CurrencyPair
+	precisions map[models.CurrencyPair]models.Precisions
+	boards     map[models.CurrencyPair]*models.Board
+	balances   map[string]*models.Balance
+	orders     []*Order
+	trades     []*Trade
+	orderSeq   int64
+	tradeSeq   int64
+	m          sync.Mutex
+}
+
+func NewExchange() *Exchange {
+	return &Exchange{
+		Fee:        decimal.New(1, -3),
+		Now:        time.Now,
+		precisions: make(map[models.CurrencyPair]models.Precisions),
+		boards:     make(map[models.CurrencyPair]*models.Board),
+		balances:   make(map[string]*models.Balance),
+		orderSeq:   100000,
+	}
+}
+
+// AddPair lists trading/settlement on the exchange.
+func (e *Exchange) AddPair(trading string, settlement string, precisions models.Precisions) {
+	e.m.Lock()
+	defer e.m.Unlock()
+	p := models.CurrencyPair{Trading: trading, Settlement: settlement}
+	if _, ok := e.precisions[p]; !ok {
+		e.pairs = append(e.pairs, p)
+	}
+	e.precisions[p] = precisions
+}
+
+func (e *Exchange) Pairs() []models.CurrencyPair {
+	e.m.Lock()
+	defer e.m.Unlock()
+	return append([]models.CurrencyPair(nil), e.pairs...)
+}
+
+func (e *Exchange) Precisions(trading string, settlement string) (models.Precisions, bool) {
+	e.m.Lock()
+	defer e.m.Unlock()
+	p, ok := e.precisions[models.CurrencyPair{Trading: trading, Settlement: settlement}]
+	return p, ok
+}
+
+// findPair returns the listed pair which symbol names in the format of an
+// exchange.
+func (e *Exchange) findPair(symbol string, format func(models.CurrencyPair) string) (models.CurrencyPair, bool) {
+	e.m.Lock()
+	defer e.m.Unlock()
+	for _, p := range e.pairs {
+		if format(p) == symbol {
+			return p, true
+		}
+	}
+	return models.CurrencyPair{}, false
+}
+
+func (e *Exchange) balance(currency string) *models.Balance {
+	b, ok := e.balances[currency]
+	if !ok {
+		b = &models.Balance{}
+		e.balances[currency] = b
+	}
+	return b
+}
+
+// SetBalance sets the available balance of currency, leaving what is on
+// orders untouched.
+func (e *Exchange) SetBalance(currency string, amount decimal.Decimal) {
+	e.m.Lock()
+	defer e.m.Unlock()
+	e.balance(currency).Available = amount
+}
+
+func (e *Exchange) Balances() map[string]models.Balance {
+	e.m.Lock()
+	defer e.m.Unlock()
+	m := make(map[string]models.Balance)
+	for c, b := range e.balances {
+		m[c] = *b
+	}
+	return m
+}
+
+// SetBoard replaces the board of trading/settlement and fills the resting
+// orders it crosses.
+func (e *Exchange) SetBoard(trading string, settlement string, board *models.Board) {
+	e.m.Lock()
+	defer e.m.Unlock()
+	p := models.CurrencyPair{Trading: trading, Settlement: settlement}
+	b := &models.Board{
+		Asks: append([]models.BoardBar(nil), board.Asks...),
+		Bids: append([]models.BoardBar(nil), board.Bids...),
+	}
+	sort.Slice(b.Asks, func(i, j int) bool { return b.Asks[i].Price.LessThan(b.Asks[j].Price) })
+	sort.Slice(b.Bids, func(i, j int) bool { return b.Bids[i].Price.GreaterThan(b.Bids[j].Price) })
+	e.boards[p] = b
+	for _, o := range e.orders {
+		if o.Trading != trading || o.Settlement != settlement || o.Status.Closed() {
+			continue
+		}
+		e.take(o, o.Price, true)
+	}
+}
+
+// Board returns a copy of the board of trading/settlement, less the liquidity
+// taken by orders.
+func (e *Exchange) Board(trading string, settlement string) models.Board {
+	e.m.Lock()
+	defer e.m.Unlock()
+	b, ok := e.boards[models.CurrencyPair{Trading: trading, Settlement: settlement}]
+	if !ok {
+		return models.Board{}
+	}
+	return models.Board{
+		Asks: append([]models.BoardBar(nil), b.Asks...),
+		Bids: append([]models.BoardBar(nil), b.Bids...),
+	}
+}
+
+// crossing returns the levels an order on the other side of the board would
+// take, best first.
+func (e *Exchange) crossing(o *Order) *[]models.BoardBar {
+	b, ok := e.boards[models.CurrencyPair{Trading: o.Trading, Settlement: o.Settlement}]
+	if !ok {
+		return &[]models.BoardBar{}
+	}
+	if o.Type == models.Ask {
+		return &b.Asks
+	}
+	return &b.Bids
+}
+
+func crosses(o *Order, price decimal.Decimal) bool {
+	if o.ExecutionType == models.Market {
+		return true
+	}
+	if o.Type == models.Ask {
+		return !price.GreaterThan(o.Price)
+	}
+	return !price.LessThan(o.Price)
+}
+
+// available returns the amount o could take from the board right now.
+func (e *Exchange) available(o *Order) decimal.Decimal {
+	amount := decimal.Zero
+	for _, bar := range *e.crossing(o) {
+		if !crosses(o, bar.Price) {
+			break
+		}
+		amount = amount.Add(bar.Amount)
+	}
+	return amount
+}
+
+// take fills o against the levels of the board it crosses, at the price of
+// each level for a taker and at its own price for a maker.
+func (e *Exchange) take(o *Order, price decimal.Decimal, maker bool) {
+	levels := e.crossing(o)
+	for len(*levels) > 0 && o.Amount.GreaterThan(o.Filled) {
+		bar := &(*levels)[0]
+		if !crosses(o, bar.Price) {
+			break
+		}
+		amount := decimal.Min(bar.Amount, o.Amount.Sub(o.Filled))
+		at := bar.Price
+		if maker {
+			at = price
+		}
+		e.fill(o, amount, at, maker)
+		bar.Amount = bar.Amount.Sub(amount)
+		if !bar.Amount.IsPositive() {
+			*levels = (*levels)[1:]
+		}
+	}
+}
+
+// fill books a fill of amount at price and settles the balances.
+func (e *Exchange) fill(o *Order, amount decimal.Decimal, price decimal.Decimal, maker bool) {
+	quote := amount.Mul(price)
+	trade := &Trade{
+		OrderID:       o.ID,
+		ClientOrderID: o.ClientID,
+		Trading:       o.Trading,
+		Settlement:    o.Settlement,
+		Type:          o.Type,
+		Price:         price,
+		Amount:        amount,
+		Maker:         maker,
+		Time:          e.Now(),
+	}
+	trading, settlement := e.balance(o.Trading), e.balance(o.Settlement)
+	if o.Type == models.Ask {
+		release := quote
+		if o.ExecutionType == models.Limit && o.Amount.GreaterThan(o.Filled) {
+			release = o.reserved.Mul(amount).Div(o.Amount.Sub(o.Filled))
+		}
+		o.reserved = o.reserved.Sub(release)
+		settlement.OnOrders = settlement.OnOrders.Sub(release)
+		settlement.Available = settlement.Available.Add(release.Sub(quote))
+		trading.Available = trading.Available.Add(amount)
+		trade.FeeCurrency = o.Trading
+		trade.Fee = amount.Mul(e.Fee)
+	} else {
+		o.reserved = o.reserved.Sub(amount)
+		trading.OnOrders = trading.OnOrders.Sub(amount)
+		settlement.Available = settlement.Available.Add(quote)
+		trade.FeeCurrency = o.Settlement
+		trade.Fee = quote.Mul(e.Fee)
+	}
+	if e.SettlementFees {
+		trade.FeeCurrency = o.Settlement
+		trade.Fee = quote.Mul(e.Fee)
+	}
+	fees := e.balance(trade.FeeCurrency)
+	fees.Available = fees.Available.Sub(trade.Fee)
+	e.tradeSeq++
+	trade.ID = e.tradeSeq
+	e.trades = append(e.trades, trade)
+
+	o.Filled = o.Filled.Add(amount)
+	o.Quote = o.Quote.Add(quote)
+	o.Fee = o.Fee.Add(trade.Fee)
+	o.FeeCurrency = trade.FeeCurrency
+	o.Updated = trade.Time
+	o.Status = models.OrderPartiallyFilled
+	if !o.Amount.GreaterThan(o.Filled) {
+		o.Status = models.OrderFilled
+		e.release(o)
+	}
+}
+
+// release returns what o still reserves to the available balance.
+func (e *Exchange) release(o *Order) {
+	currency := o.Trading
+	if o.Type == models.Ask {
+		currency = o.Settlement
+	}
+	b := e.balance(currency)
+	b.OnOrders = b.OnOrders.Sub(o.reserved)
+	b.Available = b.Available.Add(o.reserved)
+	o.reserved = decimal.Zero
+}
+
+// Place places req. A clientID already used returns the order placed with it,
+// so retried requests are idempotent.
+func (e *Exchange) Place(req models.OrderRequest, clientID string) (Order, error) {
+	e.m.Lock()
+	defer e.m.Unlock()
+	p := models.CurrencyPair{Trading: req.Trading, Settlement: req.Settlement}
+	if _, ok := e.precisions[p]; !ok {
+		return Order{}, errors.Wrapf(apierrors.ErrInvalidSymbol, "%s/%s", req.Trading, req.Settlement)
+	}
+	if clientID != "" {
+		if o := e.lookup(clientID); o != nil {
+			return *o, nil
+		}
+	}
+	if !req.Amount.IsPositive() || req.ExecutionType == models.Limit && !req.Price.IsPositive() {
+		return Order{}, ErrInvalidOrder
+	}
+	now := e.Now()
+	o := &Order{OrderRequest: req, ClientID: clientID, Status: models.OrderOpen, Created: now, Updated: now}
+	levels := *e.crossing(o)
+	if req.TimeInForce == models.PostOnly && req.ExecutionType == models.Limit && len(levels) > 0 && crosses(o, levels[0].Price) {
+		return Order{}, ErrWouldTake
+	}
+
+	// reserve what the order spends, a market buy at the board it takes
+	currency, cost := o.Trading, o.Amount
+	if o.Type == models.Ask {
+		currency, cost = o.Settlement, o.Amount.Mul(o.Price)
+		if o.ExecutionType == models.Market {
+			cost = e.marketCost(o)
+		}
+	}
+	if b := e.balance(currency); b.Available.LessThan(cost) {
+		return Order{}, errors.Wrapf(apierrors.ErrInsufficientFunds, "%s %v available, %v needed", currency, b.Available, cost)
+	}
+	e.orderSeq++
+	o.ID = strconv.FormatInt(e.orderSeq, 10)
+	b := e.balance(currency)
+	b.Available = b.Available.Sub(cost)
+	b.OnOrders = b.OnOrders.Add(cost)
+	o.reserved = cost
+	e.orders = append(e.orders, o)
+
+	if req.TimeInForce == models.FOK && req.ExecutionType == models.Limit && e.available(o).LessThan(o.Amount) {
+		e.close(o, models.OrderExpired)
+		return *o, nil
+	}
+	e.take(o, decimal.Zero, false)
+	if !o.Status.Closed() && (o.ExecutionType == models.Market || o.TimeInForce == models.IOC || o.TimeInForce == models.FOK) {
+		e.close(o, models.OrderExpired)
+	}
+	return *o, nil
+}
+
+// marketCost returns the settlement a market buy of o spends.
+func (e *Exchange) marketCost(o *Order) decimal.Decimal {
+	cost, remaining := decimal.Zero, o.Amount
+	for _, bar := range *e.crossing(o) {
+		amount := decimal.Min(bar.Amount, remaining)
+		cost = cost.Add(amount.Mul(bar.Price))
+		remaining = remaining.Sub(amount)
+		if !remaining.IsPositive() {
+			break
+		}
+	}
+	return cost
+}
+
+func (e *Exchange) close(o *Order, status models.OrderStatus) {
+	e.release(o)
+	o.Status = status
+	o.Updated = e.Now()
+}
+
+func (e *Exchange) lookup(id string) *Order {
+	for _, o := range e.orders {
+		if o.ID == id || o.ClientID != "" && o.ClientID == id {
+			return o
+		}
+	}
+	return nil
+}
+
+// Cancel cancels the open order with the exchange or client order id id.
+func (e *Exchange) Cancel(id string) (Order, error) {
+	e.m.Lock()
+	defer e.m.Unlock()
+	o := e.lookup(id)
+	if o == nil {
+		return Order{}, errors.Wrapf(apierrors.ErrOrderNotFound, "order %s", id)
+	}
+	if o.Status.Closed() {
+		return *o, errors.Wrapf(ErrOrderClosed, "order %s is %s", id, o.Status)
+	}
+	e.close(o, models.OrderCanceled)
+	return *o, nil
+}
+
+// Fill fills amount of the open order id at its own price, as if the market
+// had traded against it.
+func (e *Exchange) Fill(id string, amount decimal.Decimal) error {
+	e.m.Lock()
+	defer e.m.Unlock()
+	o := e.lookup(id)
+	if o == nil {
+		return errors.Wrapf(apierrors.ErrOrderNotFound, "order %s", id)
+	}
+	if o.Status.Closed() {
+		return errors.Wrapf(ErrOrderClosed, "order %s is %s", id, o.Status)
+	}
+	e.fill(o, decimal.Min(amount, o.Amount.Sub(o.Filled)), o.Price, true)
+	return nil
+}
+
+// Order returns the order with the exchange or client order id id.
+func (e *Exchange) Order(id string) (Order, bool) {
+	e.m.Lock()
+	defer e.m.Unlock()
+	o := e.lookup(id)
+	if o == nil {
+		return Order{}, false
+	}
+	return *o, true
+}
+
+// Orders returns the orders placed, oldest first.
+func (e *Exchange) Orders() []Order {
+	e.m.Lock()
+	defer e.m.Unlock()
+	orders := make([]Order, 0, len(e.orders))
+	for _, o := range e.orders {
+		orders = append(orders, *o)
+	}
+	return orders
+}
+
+// Trades returns the fills of trading/settlement, oldest first. Empty
+// currencies match every pair.
+func (e *Exchange) Trades(trading string, settlement string) []Trade {
+	e.m.Lock()
+	defer e.m.Unlock()
+	var trades []Trade
+	for _, t := range e.trades {
+		if trading != "" && (t.Trading != trading || t.Settlement != settlement) {
+			continue
+		}
+		trades = append(trades, *t)
+	}
+	return trades
+}
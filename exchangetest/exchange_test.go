@@ -0,0 +1,105 @@
+package exchangetest
+
+import (
+	"testing"
+
+	"github.com/fxpgr/go-exchange-client/apierrors"
+	"github.com/fxpgr/go-exchange-client/decimal"
+	"github.com/fxpgr/go-exchange-client/models"
+	"github.com/pkg/errors"
+)
+
+var d = decimal.RequireFromString
+
+func eq(a decimal.Decimal, b string) bool { return a.Equal(d(b)) }
+
+func newTestExchange() *Exchange {
+	e := NewExchange()
+	e.AddPair("ETH", "BTC", models.Precisions{PricePrecision: 6, AmountPrecision: 3})
+	e.SetBalance("BTC", d("1"))
+	e.SetBalance("ETH", d("10"))
+	e.SetBoard("ETH", "BTC", &models.Board{
+		Asks: []models.BoardBar{{Price: d("0.032"), Amount: d("1")}, {Price: d("0.031"), Amount: d("1")}},
+		Bids: []models.BoardBar{{Price: d("0.029"), Amount: d("1")}},
+	})
+	return e
+}
+
+func TestExchangePlace(t *testing.T) {
+	e := newTestExchange()
+	o, err := e.Place(models.OrderRequest{Trading: "ETH", Settlement: "BTC", Type: models.Ask, Price: d("0.0315"), Amount: d("1.5")}, "a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if o.Status != models.OrderPartiallyFilled || !eq(o.Filled, "1") || !eq(o.Quote, "0.031") || !eq(o.Fee, "0.001") {
+		t.Errorf("unexpected order %+v", o)
+	}
+	b := e.Balances()
+	if !eq(b["BTC"].Available, "0.95325") || !eq(b["BTC"].OnOrders, "0.01575") || !eq(b["ETH"].Available, "10.999") {
+		t.Errorf("unexpected balances %+v", b)
+	}
+	if again, _ := e.Place(models.OrderRequest{Trading: "ETH", Settlement: "BTC", Type: models.Ask, Price: d("0.0315"), Amount: d("1.5")}, "a"); again.ID != o.ID {
+		t.Errorf("Expected the order %v again. Got %v", o.ID, again.ID)
+	}
+
+	e.SetBoard("ETH", "BTC", &models.Board{Asks: []models.BoardBar{{Price: d("0.031"), Amount: d("2")}}})
+	o, _ = e.Order("a")
+	if o.Status != models.OrderFilled || !eq(o.Quote, "0.04675") {
+		t.Errorf("unexpected order %+v", o)
+	}
+	if b := e.Balances()["BTC"]; !b.OnOrders.IsZero() || !eq(b.Available, "0.95325") {
+		t.Errorf("unexpected balance %+v", b)
+	}
+	if trades := e.Trades("ETH", "BTC"); len(trades) != 2 || trades[0].Maker || !trades[1].Maker {
+		t.Errorf("unexpected trades %+v", trades)
+	}
+}
+
+func TestExchangeCancel(t *testing.T) {
+	e := newTestExchange()
+	o, err := e.Place(models.OrderRequest{Trading: "ETH", Settlement: "BTC", Type: models.Bid, Price: d("0.03"), Amount: d("4")}, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := e.Fill(o.ID, d("1")); err != nil {
+		t.Fatal(err)
+	}
+	if b := e.Balances(); !eq(b["ETH"].OnOrders, "3") || !eq(b["BTC"].Available, "1.02997") {
+		t.Errorf("unexpected balances %+v", b)
+	}
+	if o, err = e.Cancel(o.ID); err != nil || o.Status != models.OrderCanceled {
+		t.Errorf("unexpected cancel %+v %v", o, err)
+	}
+	if b := e.Balances()["ETH"]; !eq(b.Available, "9") || !b.OnOrders.IsZero() {
+		t.Errorf("unexpected balance %+v", b)
+	}
+	if _, err := e.Cancel(o.ID); !errors.Is(err, ErrOrderClosed) {
+		t.Errorf("Expected %v. Got %v", ErrOrderClosed, err)
+	}
+	if _, err := e.Cancel("1"); !errors.Is(err, apierrors.ErrOrderNotFound) {
+		t.Errorf("Expected %v. Got %v", apierrors.ErrOrderNotFound, err)
+	}
+}
+
+func TestExchangeRejects(t *testing.T) {
+	e := newTestExchange()
+	req := models.OrderRequest{Trading: "ETH", Settlement: "BTC", Type: models.Ask, Price: d("0.031"), Amount: d("1"), TimeInForce: models.PostOnly}
+	if _, err := e.Place(req, ""); !errors.Is(err, ErrWouldTake) {
+		t.Errorf("Expected %v. Got %v", ErrWouldTake, err)
+	}
+	req.TimeInForce, req.Amount = models.FOK, d("3")
+	if o, err := e.Place(req, ""); err != nil || o.Status != models.OrderExpired || !o.Filled.IsZero() {
+		t.Errorf("unexpected order %+v %v", o, err)
+	}
+	req.TimeInForce, req.Amount = models.GTC, d("100")
+	if _, err := e.Place(req, ""); !errors.Is(err, apierrors.ErrInsufficientFunds) {
+		t.Errorf("Expected %v. Got %v", apierrors.ErrInsufficientFunds, err)
+	}
+	req.Trading = "XRP"
+	if _, err := e.Place(req, ""); !errors.Is(err, apierrors.ErrInvalidSymbol) {
+		t.Errorf("Expected %v. Got %v", apierrors.ErrInvalidSymbol, err)
+	}
+	if b := e.Balances()["BTC"]; !eq(b.Available, "1") || !b.OnOrders.IsZero() {
+		t.Errorf("unexpected balance %+v", b)
+	}
+}
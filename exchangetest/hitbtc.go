@@ -0,0 +1,292 @@
+package exchangetest
+
+import (
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/fxpgr/go-exchange-client/apierrors"
+	"github.com/fxpgr/go-exchange-client/decimal"
+	"github.com/fxpgr/go-exchange-client/models"
+	"github.com/pkg/errors"
+)
+
+// NewHitbtcServer starts a server speaking the hitbtc REST API v2 under
+// /api/2: symbols, the order book, and the trading and history endpoints
+// authenticated by basic auth. Fees are charged in the settlement currency,
+// as hitbtc does.
+func NewHitbtcServer(apiKey string, secret string) *Server {
+	return newServer(apiKey, secret, func(s *Server, mux *http.ServeMux) {
+		s.SettlementFees = true
+		mux.HandleFunc("/api/2/public/symbol", s.hitbtcSymbols)
+		mux.HandleFunc("/api/2/public/orderbook/", s.hitbtcOrderBook)
+		mux.HandleFunc("/api/2/trading/balance", s.hitbtcAuth(s.hitbtcBalance))
+		mux.HandleFunc("/api/2/order", s.hitbtcAuth(s.hitbtcOrders))
+		mux.HandleFunc("/api/2/order/", s.hitbtcAuth(s.hitbtcCancel))
+		mux.HandleFunc("/api/2/history/order", s.hitbtcAuth(s.hitbtcHistoryOrder))
+		mux.HandleFunc("/api/2/history/order/", s.hitbtcAuth(s.hitbtcOrderTrades))
+		mux.HandleFunc("/api/2/history/trades", s.hitbtcAuth(s.hitbtcTrades))
+	})
+}
+
+func hitbtcSymbol(p models.CurrencyPair) string {
+	return p.Trading + p.Settlement
+}
+
+func hitbtcError(w http.ResponseWriter, status int, code int, message string) {
+	writeJSON(w, status, map[string]interface{}{
+		"error": map[string]interface{}{"code": code, "message": message, "description": ""},
+	})
+}
+
+func hitbtcTime(t time.Time) string {
+	return t.UTC().Format("2006-01-02T15:04:05.000Z")
+}
+
+func (s *Server) hitbtcAuth(h func(w http.ResponseWriter, r *http.Request)) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		user, password, ok := r.BasicAuth()
+		if !ok || user != s.APIKey || password != s.Secret {
+			hitbtcError(w, http.StatusUnauthorized, 1002, "Authorization failed")
+			return
+		}
+		r.ParseForm()
+		h(w, r)
+	}
+}
+
+func (s *Server) hitbtcSymbols(w http.ResponseWriter, r *http.Request) {
+	list := []map[string]string{}
+	for _, p := range s.Pairs() {
+		precisions, _ := s.Precisions(p.Trading, p.Settlement)
+		list = append(list, map[string]string{
+			"id":                   hitbtcSymbol(p),
+			"baseCurrency":         p.Trading,
+			"quoteCurrency":        p.Settlement,
+			"quantityIncrement":    step(precisions.AmountPrecision).String(),
+			"tickSize":             step(precisions.PricePrecision).String(),
+			"takeLiquidityRate":    s.Fee.String(),
+			"provideLiquidityRate": s.Fee.String(),
+			"feeCurrency":          p.Settlement,
+		})
+	}
+	writeJSON(w, http.StatusOK, list)
+}
+
+func hitbtcLevels(bars []models.BoardBar) []map[string]string {
+	l := make([]map[string]string, 0, len(bars))
+	for _, b := range bars {
+		l = append(l, map[string]string{"price": formatDecimal(b.Price), "size": formatDecimal(b.Amount)})
+	}
+	return l
+}
+
+func (s *Server) hitbtcOrderBook(w http.ResponseWriter, r *http.Request) {
+	p, ok := s.findPair(strings.TrimPrefix(r.URL.Path, "/api/2/public/orderbook/"), hitbtcSymbol)
+	if !ok {
+		hitbtcError(w, http.StatusBadRequest, 2001, "Symbol not found")
+		return
+	}
+	board := s.Board(p.Trading, p.Settlement)
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"ask":       hitbtcLevels(board.Asks),
+		"bid":       hitbtcLevels(board.Bids),
+		"timestamp": hitbtcTime(time.Now()),
+	})
+}
+
+func (s *Server) hitbtcBalance(w http.ResponseWriter, r *http.Request) {
+	balances := s.Balances()
+	var currencies []string
+	for c := range balances {
+		currencies = append(currencies, c)
+	}
+	sort.Strings(currencies)
+	list := []map[string]string{}
+	for _, c := range currencies {
+		b := balances[c]
+		list = append(list, map[string]string{
+			"currency":  c,
+			"available": formatDecimal(b.Available),
+			"reserved":  formatDecimal(b.OnOrders),
+		})
+	}
+	writeJSON(w, http.StatusOK, list)
+}
+
+var hitbtcStatus = map[models.OrderStatus]string{
+	models.OrderOpen:            "new",
+	models.OrderPartiallyFilled: "partiallyFilled",
+	models.OrderFilled:          "filled",
+	models.OrderCanceled:        "canceled",
+	models.OrderRejected:        "expired",
+	models.OrderExpired:         "expired",
+}
+
+func hitbtcOrderJSON(o Order) map[string]interface{} {
+	timeInForce := o.TimeInForce.String()
+	if o.TimeInForce == models.PostOnly {
+		timeInForce = "GTC"
+	}
+	id, _ := strconv.ParseInt(o.ID, 10, 64)
+	return map[string]interface{}{
+		"id":            id,
+		"clientOrderId": o.ClientID,
+		"symbol":        o.Trading + o.Settlement,
+		"side":          side(o.Type),
+		"status":        hitbtcStatus[o.Status],
+		"type":          o.ExecutionType.String(),
+		"timeInForce":   timeInForce,
+		"quantity":      formatDecimal(o.Amount),
+		"price":         formatDecimal(o.Price),
+		"cumQuantity":   formatDecimal(o.Filled),
+		"postOnly":      o.TimeInForce == models.PostOnly,
+		"createdAt":     hitbtcTime(o.Created),
+		"updatedAt":     hitbtcTime(o.Updated),
+	}
+}
+
+// hitbtcOrders lists the open orders on GET and places an order on POST.
+func (s *Server) hitbtcOrders(w http.ResponseWriter, r *http.Request) {
+	if r.Method == http.MethodGet {
+		list := []map[string]interface{}{}
+		for _, o := range s.open() {
+			if symbol := r.Form.Get("symbol"); symbol == "" || symbol == o.Trading+o.Settlement {
+				list = append(list, hitbtcOrderJSON(o))
+			}
+		}
+		writeJSON(w, http.StatusOK, list)
+		return
+	}
+	p, ok := s.findPair(r.Form.Get("symbol"), hitbtcSymbol)
+	if !ok {
+		hitbtcError(w, http.StatusBadRequest, 2001, "Symbol not found")
+		return
+	}
+	req := models.OrderRequest{Trading: p.Trading, Settlement: p.Settlement}
+	switch r.Form.Get("side") {
+	case "buy":
+	case "sell":
+		req.Type = models.Bid
+	default:
+		hitbtcError(w, http.StatusBadRequest, 10001, "Validation error")
+		return
+	}
+	if r.Form.Get("type") == "market" {
+		req.ExecutionType = models.Market
+	}
+	switch r.Form.Get("timeInForce") {
+	case "", "GTC":
+	case "IOC":
+		req.TimeInForce = models.IOC
+	case "FOK":
+		req.TimeInForce = models.FOK
+	default:
+		hitbtcError(w, http.StatusBadRequest, 10001, "Validation error")
+		return
+	}
+	if r.Form.Get("postOnly") == "true" {
+		req.TimeInForce = models.PostOnly
+	}
+	req.Amount, _ = decimal.NewFromString(r.Form.Get("quantity"))
+	req.Price, _ = decimal.NewFromString(r.Form.Get("price"))
+	clientID := r.Form.Get("clientOrderId")
+	if clientID == "" {
+		clientID = randomID(32)
+	}
+	o, err := s.Place(req, clientID)
+	switch {
+	case errors.Is(err, apierrors.ErrInsufficientFunds):
+		hitbtcError(w, http.StatusBadRequest, 20001, "Insufficient funds")
+	case errors.Is(err, ErrWouldTake):
+		hitbtcError(w, http.StatusBadRequest, 10001, "Post only order would take liquidity")
+	case err != nil:
+		hitbtcError(w, http.StatusBadRequest, 10001, "Validation error")
+	default:
+		writeJSON(w, http.StatusOK, hitbtcOrderJSON(o))
+	}
+}
+
+// hitbtcCancel serves DELETE /api/2/order/{clientOrderId}.
+func (s *Server) hitbtcCancel(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodDelete {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	o, err := s.Cancel(strings.TrimPrefix(r.URL.Path, "/api/2/order/"))
+	if err != nil {
+		hitbtcError(w, http.StatusBadRequest, 20002, "Order not found")
+		return
+	}
+	writeJSON(w, http.StatusOK, hitbtcOrderJSON(o))
+}
+
+func (s *Server) hitbtcHistoryOrder(w http.ResponseWriter, r *http.Request) {
+	list := []map[string]interface{}{}
+	id := r.Form.Get("clientOrderId")
+	for _, o := range s.Orders() {
+		if id == "" || o.ClientID == id {
+			list = append(list, hitbtcOrderJSON(o))
+		}
+	}
+	writeJSON(w, http.StatusOK, list)
+}
+
+func hitbtcTradeJSON(t Trade) map[string]interface{} {
+	orderID, _ := strconv.ParseInt(t.OrderID, 10, 64)
+	return map[string]interface{}{
+		"id":            t.ID,
+		"orderId":       orderID,
+		"clientOrderId": t.ClientOrderID,
+		"symbol":        t.Trading + t.Settlement,
+		"side":          side(t.Type),
+		"quantity":      formatDecimal(t.Amount),
+		"price":         formatDecimal(t.Price),
+		"fee":           formatDecimal(t.Fee),
+		"timestamp":     hitbtcTime(t.Time),
+	}
+}
+
+// hitbtcOrderTrades serves /api/2/history/order/{id}/trades.
+func (s *Server) hitbtcOrderTrades(w http.ResponseWriter, r *http.Request) {
+	id := strings.TrimSuffix(strings.TrimPrefix(r.URL.Path, "/api/2/history/order/"), "/trades")
+	list := []map[string]interface{}{}
+	for _, t := range s.Trades("", "") {
+		if t.OrderID == id {
+			list = append(list, hitbtcTradeJSON(t))
+		}
+	}
+	writeJSON(w, http.StatusOK, list)
+}
+
+func (s *Server) hitbtcTrades(w http.ResponseWriter, r *http.Request) {
+	p, ok := s.findPair(r.Form.Get("symbol"), hitbtcSymbol)
+	if !ok {
+		hitbtcError(w, http.StatusBadRequest, 2001, "Symbol not found")
+		return
+	}
+	limit := 100
+	if v, err := strconv.Atoi(r.Form.Get("limit")); err == nil && v > 0 && v <= 1000 {
+		limit = v
+	}
+	offset, _ := strconv.Atoi(r.Form.Get("offset"))
+	from, _ := time.Parse(time.RFC3339Nano, r.Form.Get("from"))
+	var trades []Trade
+	for _, t := range s.Trades(p.Trading, p.Settlement) {
+		if !t.Time.Before(from) {
+			trades = append(trades, t)
+		}
+	}
+	if r.Form.Get("sort") != "ASC" {
+		for i, j := 0, len(trades)-1; i < j; i, j = i+1, j-1 {
+			trades[i], trades[j] = trades[j], trades[i]
+		}
+	}
+	list := []map[string]interface{}{}
+	for i := offset; i < len(trades) && i < offset+limit; i++ {
+		list = append(list, hitbtcTradeJSON(trades[i]))
+	}
+	writeJSON(w, http.StatusOK, list)
+}
@@ -0,0 +1,345 @@
+package exchangetest
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/fxpgr/go-exchange-client/apierrors"
+	"github.com/fxpgr/go-exchange-client/decimal"
+	"github.com/fxpgr/go-exchange-client/models"
+	"github.com/pkg/errors"
+)
+
+const huobiAccountID = 100009
+
+// NewHuobiServer starts a server speaking the huobi REST API: depth and the
+// signed account, order and match result endpoints.
+func NewHuobiServer(apiKey string, secret string) *Server {
+	return newServer(apiKey, secret, func(s *Server, mux *http.ServeMux) {
+		mux.HandleFunc("/market/depth", s.huobiDepth)
+		s.huobiRoutes(mux)
+	})
+}
+
+func huobiSymbol(p models.CurrencyPair) string {
+	return strings.ToLower(p.Trading + p.Settlement)
+}
+
+func huobiError(w http.ResponseWriter, code string, msg string) {
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"status":   "error",
+		"err-code": code,
+		"err-msg":  msg,
+		"data":     nil,
+	})
+}
+
+func huobiOK(w http.ResponseWriter, data interface{}) {
+	writeJSON(w, http.StatusOK, map[string]interface{}{"status": "ok", "data": data})
+}
+
+// huobiRoutes registers the signed endpoints, which the okex v1 API mirrors.
+func (s *Server) huobiRoutes(mux *http.ServeMux) {
+	mux.HandleFunc("/v1/account/accounts", s.huobiSigned(func(w http.ResponseWriter, r *http.Request) {
+		huobiOK(w, []map[string]interface{}{
+			{"id": huobiAccountID, "type": "spot", "subtype": "", "state": "working"},
+		})
+	}))
+	mux.HandleFunc("/v1/account/accounts/", s.huobiSigned(s.huobiBalance))
+	mux.HandleFunc("/v1/order/orders/place", s.huobiSigned(s.huobiPlace))
+	mux.HandleFunc("/v1/order/orders/", s.huobiSigned(s.huobiOrder))
+	mux.HandleFunc("/v1/order/openOrders", s.huobiSigned(s.huobiOpenOrders))
+	mux.HandleFunc("/v1/order/matchresults", s.huobiSigned(s.huobiMatchResults))
+}
+
+// huobiSigned checks the signature version 2 of a request: the HMAC-SHA256 of
+// the method, host, path and sorted query, in base64.
+func (s *Server) huobiSigned(h func(w http.ResponseWriter, r *http.Request)) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		query := r.URL.Query()
+		if query.Get("AccessKeyId") != s.APIKey {
+			huobiError(w, "api-signature-not-valid", "Signature not valid: Incorrect Access key [Access key错误]")
+			return
+		}
+		signature := query.Get("Signature")
+		query.Del("Signature")
+		payload := fmt.Sprintf("%s\n%s\n%s\n%s", r.Method, r.Host, r.URL.Path, query.Encode())
+		mac := hmac.New(sha256.New, []byte(s.Secret))
+		mac.Write([]byte(payload))
+		if query.Get("SignatureMethod") != "HmacSHA256" || query.Get("SignatureVersion") != "2" ||
+			!hmac.Equal([]byte(signature), []byte(base64.StdEncoding.EncodeToString(mac.Sum(nil)))) {
+			huobiError(w, "api-signature-not-valid", "Signature not valid: Verification failure [校验失败]")
+			return
+		}
+		timestamp, err := time.Parse("2006-01-02T15:04:05", query.Get("Timestamp"))
+		if err != nil || time.Since(timestamp) > 5*time.Minute || time.Until(timestamp) > time.Minute {
+			huobiError(w, "login-required", "Signature not valid: Timestamp expired")
+			return
+		}
+		r.Form = query
+		h(w, r)
+	}
+}
+
+func (s *Server) huobiDepth(w http.ResponseWriter, r *http.Request) {
+	symbol := r.URL.Query().Get("symbol")
+	p, ok := s.findPair(symbol, huobiSymbol)
+	if !ok {
+		huobiError(w, "invalid-parameter", "invalid symbol")
+		return
+	}
+	board := s.Board(p.Trading, p.Settlement)
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"status": "ok",
+		"ch":     "market." + symbol + ".depth." + r.URL.Query().Get("type"),
+		"ts":     millis(time.Now()),
+		"tick": map[string]interface{}{
+			"bids":    numberLevels(board.Bids),
+			"asks":    numberLevels(board.Asks),
+			"ts":      millis(time.Now()),
+			"version": millis(time.Now()),
+		},
+	})
+}
+
+// numberLevels returns the bars of a board as [price, amount] pairs of
+// numbers.
+func numberLevels(bars []models.BoardBar) [][]decimal.Decimal {
+	l := make([][]decimal.Decimal, 0, len(bars))
+	for _, b := range bars {
+		l = append(l, []decimal.Decimal{b.Price, b.Amount})
+	}
+	return l
+}
+
+func (s *Server) huobiBalance(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path != fmt.Sprintf("/v1/account/accounts/%d/balance", huobiAccountID) {
+		huobiError(w, "account-get-balance-account-inexistent-error", "account for id `"+r.Form.Get("account-id")+"` and user id does not exist")
+		return
+	}
+	balances := s.Balances()
+	var currencies []string
+	for c := range balances {
+		currencies = append(currencies, c)
+	}
+	sort.Strings(currencies)
+	list := []map[string]string{}
+	for _, c := range currencies {
+		list = append(list,
+			map[string]string{"currency": strings.ToLower(c), "type": "trade", "balance": formatDecimal(balances[c].Available)},
+			map[string]string{"currency": strings.ToLower(c), "type": "frozen", "balance": formatDecimal(balances[c].OnOrders)})
+	}
+	huobiOK(w, map[string]interface{}{
+		"id":    huobiAccountID,
+		"type":  "spot",
+		"state": "working",
+		"list":  list,
+	})
+}
+
+func (s *Server) huobiPlace(w http.ResponseWriter, r *http.Request) {
+	p, ok := s.findPair(r.Form.Get("symbol"), huobiSymbol)
+	if !ok {
+		huobiError(w, "base-symbol-error", "The symbol is invalid")
+		return
+	}
+	if r.Form.Get("account-id") != strconv.Itoa(huobiAccountID) {
+		huobiError(w, "account-frozen-account-inexistent-error", "account for id `"+r.Form.Get("account-id")+"` does not exist")
+		return
+	}
+	req := models.OrderRequest{Trading: p.Trading, Settlement: p.Settlement}
+	typ := strings.SplitN(r.Form.Get("type"), "-", 2)
+	if len(typ) != 2 || typ[0] != "buy" && typ[0] != "sell" {
+		huobiError(w, "invalid-parameter", "invalid type")
+		return
+	}
+	if typ[0] == "sell" {
+		req.Type = models.Bid
+	}
+	switch typ[1] {
+	case "market":
+		req.ExecutionType = models.Market
+	case "limit":
+	case "ioc":
+		req.TimeInForce = models.IOC
+	case "limit-fok":
+		req.TimeInForce = models.FOK
+	case "limit-maker":
+		req.TimeInForce = models.PostOnly
+	default:
+		huobiError(w, "invalid-parameter", "invalid type")
+		return
+	}
+	req.Amount, _ = decimal.NewFromString(r.Form.Get("amount"))
+	req.Price, _ = decimal.NewFromString(r.Form.Get("price"))
+	if req.ExecutionType == models.Market && req.Type == models.Ask {
+		// market buys are sized in the settlement currency
+		board := s.Board(p.Trading, p.Settlement)
+		if len(board.Asks) == 0 {
+			huobiError(w, "order-market-order-no-liquidity", "no liquidity")
+			return
+		}
+		req.Amount = req.Amount.Div(board.Asks[0].Price)
+	}
+	o, err := s.Place(req, r.Form.Get("client-order-id"))
+	switch {
+	case errors.Is(err, apierrors.ErrInsufficientFunds):
+		huobiError(w, "account-frozen-balance-insufficient-error", "trade account balance is not enough")
+	case errors.Is(err, ErrWouldTake):
+		huobiError(w, "order-limitorder-price-error", "limit-maker order would match immediately")
+	case err != nil:
+		huobiError(w, "order-limitorder-amount-min-error", "limit order amount error")
+	default:
+		huobiOK(w, o.ID)
+	}
+}
+
+var huobiState = map[models.OrderStatus]string{
+	models.OrderOpen:            "submitted",
+	models.OrderPartiallyFilled: "partial-filled",
+	models.OrderFilled:          "filled",
+	models.OrderCanceled:        "canceled",
+	models.OrderRejected:        "canceled",
+	models.OrderExpired:         "canceled",
+}
+
+func huobiOrderJSON(o Order) map[string]interface{} {
+	typ := side(o.Type) + "-limit"
+	switch {
+	case o.ExecutionType == models.Market:
+		typ = side(o.Type) + "-market"
+	case o.TimeInForce == models.IOC:
+		typ = side(o.Type) + "-ioc"
+	case o.TimeInForce == models.FOK:
+		typ = side(o.Type) + "-limit-fok"
+	case o.TimeInForce == models.PostOnly:
+		typ = side(o.Type) + "-limit-maker"
+	}
+	amount := o.Amount
+	if o.ExecutionType == models.Market && o.Type == models.Ask {
+		amount = o.Quote
+	}
+	state := huobiState[o.Status]
+	if state == "canceled" && o.Filled.IsPositive() {
+		state = "partial-canceled"
+	}
+	var finished, canceled int64
+	if o.Status.Closed() {
+		finished = millis(o.Updated)
+		if o.Status != models.OrderFilled {
+			canceled = millis(o.Updated)
+		}
+	}
+	id, _ := strconv.ParseInt(o.ID, 10, 64)
+	return map[string]interface{}{
+		"id":                id,
+		"symbol":            strings.ToLower(o.Trading + o.Settlement),
+		"account-id":        huobiAccountID,
+		"client-order-id":   o.ClientID,
+		"amount":            formatDecimal(amount),
+		"price":             formatDecimal(o.Price),
+		"created-at":        millis(o.Created),
+		"type":              typ,
+		"field-amount":      formatDecimal(o.Filled),
+		"field-cash-amount": formatDecimal(o.Quote),
+		"field-fees":        formatDecimal(o.Fee),
+		"finished-at":       finished,
+		"canceled-at":       canceled,
+		"source":            "api",
+		"state":             state,
+	}
+}
+
+// huobiOrder serves /v1/order/orders/{id} and its submitcancel.
+func (s *Server) huobiOrder(w http.ResponseWriter, r *http.Request) {
+	path := strings.TrimPrefix(r.URL.Path, "/v1/order/orders/")
+	id := strings.TrimSuffix(path, "/submitcancel")
+	if strings.Contains(id, "/") {
+		http.NotFound(w, r)
+		return
+	}
+	if id != path {
+		_, err := s.Cancel(id)
+		switch {
+		case errors.Is(err, apierrors.ErrOrderNotFound):
+			huobiError(w, "base-record-invalid", "record invalid")
+		case err != nil:
+			huobiError(w, "order-orderstate-error", "the order state is error")
+		default:
+			huobiOK(w, id)
+		}
+		return
+	}
+	o, ok := s.Order(id)
+	if !ok {
+		huobiError(w, "base-record-invalid", "record invalid")
+		return
+	}
+	huobiOK(w, huobiOrderJSON(o))
+}
+
+func (s *Server) huobiOpenOrders(w http.ResponseWriter, r *http.Request) {
+	symbol := r.Form.Get("symbol")
+	list := []map[string]interface{}{}
+	for _, o := range s.open() {
+		if symbol == "" || symbol == strings.ToLower(o.Trading+o.Settlement) {
+			list = append(list, huobiOrderJSON(o))
+		}
+	}
+	huobiOK(w, list)
+}
+
+// huobiMatchResults pages through the fills by id: forward from the id from
+// with direct=next, and from the oldest otherwise.
+func (s *Server) huobiMatchResults(w http.ResponseWriter, r *http.Request) {
+	p, ok := s.findPair(r.Form.Get("symbol"), huobiSymbol)
+	if !ok {
+		huobiError(w, "base-symbol-error", "The symbol is invalid")
+		return
+	}
+	size := 100
+	if v, err := strconv.Atoi(r.Form.Get("size")); err == nil && v > 0 && v <= 100 {
+		size = v
+	}
+	from, _ := strconv.ParseInt(r.Form.Get("from"), 10, 64)
+	var start time.Time
+	if v := r.Form.Get("start-date"); v != "" {
+		start, _ = time.Parse("2006-01-02", v)
+	}
+	list := []map[string]interface{}{}
+	for _, t := range s.Trades(p.Trading, p.Settlement) {
+		if t.ID <= from || t.Time.Before(start) {
+			continue
+		}
+		if len(list) == size {
+			break
+		}
+		orderID, _ := strconv.ParseInt(t.OrderID, 10, 64)
+		role := "taker"
+		if t.Maker {
+			role = "maker"
+		}
+		o, _ := s.Order(t.OrderID)
+		list = append(list, map[string]interface{}{
+			"id":            t.ID,
+			"order-id":      orderID,
+			"match-id":      t.ID + 1000000,
+			"symbol":        huobiSymbol(p),
+			"type":          huobiOrderJSON(o)["type"],
+			"source":        "api",
+			"price":         formatDecimal(t.Price),
+			"filled-amount": formatDecimal(t.Amount),
+			"filled-fees":   formatDecimal(t.Fee),
+			"role":          role,
+			"created-at":    millis(t.Time),
+		})
+	}
+	huobiOK(w, list)
+}
@@ -0,0 +1,314 @@
+package exchangetest
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/fxpgr/go-exchange-client/apierrors"
+	"github.com/fxpgr/go-exchange-client/decimal"
+	"github.com/fxpgr/go-exchange-client/models"
+	"github.com/pkg/errors"
+)
+
+// NewKucoinServer starts a server speaking the kucoin REST API: currencies,
+// tickers, the level 2 order book, and the signed account endpoint and order
+// endpoints the adapter uses.
+func NewKucoinServer(passphrase string, apiKey string, secret string) *Server {
+	return newServer(apiKey, secret, func(s *Server, mux *http.ServeMux) {
+		s.Passphrase = passphrase
+		mux.HandleFunc("/api/v1/currencies", s.kucoinCurrencies)
+		mux.HandleFunc("/api/v1/market/allTickers", s.kucoinTickers)
+		mux.HandleFunc("/api/v2/market/orderbook/level2", s.kucoinOrderBook)
+		mux.HandleFunc("/api/v1/accounts", s.kucoinSigned(s.kucoinAccounts))
+		mux.HandleFunc("/v1/order", s.kucoinSigned(s.kucoinPlace))
+		mux.HandleFunc("/v1/cancel-order", s.kucoinSigned(s.kucoinCancel))
+		mux.HandleFunc("/v1/order/detail", s.kucoinSigned(s.kucoinDetail))
+		mux.HandleFunc("/v1/order/active", s.kucoinSigned(s.kucoinActive))
+		mux.HandleFunc("/v1/order/dealt", s.kucoinSigned(s.kucoinDealt))
+	})
+}
+
+func kucoinSymbol(p models.CurrencyPair) string {
+	return p.Trading + "-" + p.Settlement
+}
+
+func kucoinError(w http.ResponseWriter, status int, code string, msg string) {
+	writeJSON(w, status, map[string]interface{}{"code": code, "msg": msg})
+}
+
+func kucoinOK(w http.ResponseWriter, data interface{}) {
+	writeJSON(w, http.StatusOK, map[string]interface{}{"code": "200000", "success": true, "data": data})
+}
+
+// kucoinSigned checks the key, passphrase and timestamp headers and the
+// HMAC-SHA256 signature, in base64, of the timestamp, method, path and
+// parameters, which are in the query of a GET and in the body otherwise.
+func (s *Server) kucoinSigned(h func(w http.ResponseWriter, r *http.Request)) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		params := r.URL.Query()
+		if r.Method != http.MethodGet {
+			body, _ := ioutil.ReadAll(r.Body)
+			params, _ = url.ParseQuery(string(body))
+		}
+		if r.Header.Get("KC-API-KEY") != s.APIKey {
+			kucoinError(w, http.StatusUnauthorized, "400003", "KC-API-KEY not exists")
+			return
+		}
+		if r.Header.Get("KC-API-PASSPHRASE") != s.Passphrase {
+			kucoinError(w, http.StatusUnauthorized, "400004", "Invalid KC-API-PASSPHRASE")
+			return
+		}
+		timestamp := r.Header.Get("KC-API-TIMESTAMP")
+		t, err := strconv.ParseInt(timestamp, 10, 64)
+		if d := millis(time.Now()) - t; err != nil || d > 5000 || d < -5000 {
+			kucoinError(w, http.StatusUnauthorized, "400002", "KC-API-TIMESTAMP Invalid")
+			return
+		}
+		mac := hmac.New(sha256.New, []byte(s.Secret))
+		mac.Write([]byte(timestamp + r.Method + r.URL.Path + params.Encode()))
+		if !hmac.Equal([]byte(r.Header.Get("KC-API-SIGN")), []byte(base64.StdEncoding.EncodeToString(mac.Sum(nil)))) {
+			kucoinError(w, http.StatusUnauthorized, "400005", "Invalid KC-API-SIGN")
+			return
+		}
+		r.Form = params
+		h(w, r)
+	}
+}
+
+// kucoinCurrencies reports the amount precision of a pair as the precision
+// of its trading currency and the price precision as that of its settlement.
+func (s *Server) kucoinCurrencies(w http.ResponseWriter, r *http.Request) {
+	precision := make(map[string]int)
+	for _, p := range s.Pairs() {
+		precisions, _ := s.Precisions(p.Trading, p.Settlement)
+		precision[p.Trading] = precisions.AmountPrecision
+		precision[p.Settlement] = precisions.PricePrecision
+	}
+	var currencies []string
+	for c := range precision {
+		currencies = append(currencies, c)
+	}
+	sort.Strings(currencies)
+	list := []map[string]interface{}{}
+	for _, c := range currencies {
+		list = append(list, map[string]interface{}{
+			"currency":          c,
+			"name":              c,
+			"fullName":          c,
+			"precision":         precision[c],
+			"withdrawalMinSize": formatDecimal(decimal.New(1, -2)),
+			"withdrawalMinFee":  formatDecimal(decimal.New(1, -3)),
+			"isWithdrawEnabled": true,
+			"isDepositEnabled":  true,
+		})
+	}
+	kucoinOK(w, list)
+}
+
+func (s *Server) kucoinTickers(w http.ResponseWriter, r *http.Request) {
+	list := []map[string]interface{}{}
+	for _, p := range s.Pairs() {
+		board := s.Board(p.Trading, p.Settlement)
+		var buy, sell decimal.Decimal
+		if len(board.Bids) > 0 {
+			buy = board.Bids[0].Price
+		}
+		if len(board.Asks) > 0 {
+			sell = board.Asks[0].Price
+		}
+		list = append(list, map[string]interface{}{
+			"symbol":     kucoinSymbol(p),
+			"symbolName": kucoinSymbol(p),
+			"buy":        formatDecimal(buy),
+			"sell":       formatDecimal(sell),
+			"last":       formatDecimal(buy.Add(sell).Div(decimal.NewFromInt(2))),
+			"vol":        formatDecimal(decimal.Zero),
+			"volValue":   formatDecimal(decimal.Zero),
+		})
+	}
+	kucoinOK(w, map[string]interface{}{"time": millis(time.Now()), "ticker": list})
+}
+
+func (s *Server) kucoinOrderBook(w http.ResponseWriter, r *http.Request) {
+	p, ok := s.findPair(r.URL.Query().Get("symbol"), kucoinSymbol)
+	if !ok {
+		kucoinError(w, http.StatusBadRequest, "900001", "Symbol not exists")
+		return
+	}
+	board := s.Board(p.Trading, p.Settlement)
+	kucoinOK(w, map[string]interface{}{
+		"sequence": strconv.FormatInt(millis(time.Now()), 10),
+		"time":     millis(time.Now()),
+		"bids":     levels(board.Bids),
+		"asks":     levels(board.Asks),
+	})
+}
+
+func (s *Server) kucoinAccounts(w http.ResponseWriter, r *http.Request) {
+	balances := s.Balances()
+	var currencies []string
+	for c := range balances {
+		currencies = append(currencies, c)
+	}
+	sort.Strings(currencies)
+	list := []map[string]string{}
+	for i, c := range currencies {
+		b := balances[c]
+		list = append(list, map[string]string{
+			"id":        strconv.Itoa(5000 + i),
+			"currency":  c,
+			"type":      "trade",
+			"balance":   formatDecimal(b.Available.Add(b.OnOrders)),
+			"available": formatDecimal(b.Available),
+			"holds":     formatDecimal(b.OnOrders),
+		})
+	}
+	kucoinOK(w, list)
+}
+
+func kucoinSide(t models.OrderType) string {
+	if t == models.Ask {
+		return "BUY"
+	}
+	return "SELL"
+}
+
+func (s *Server) kucoinPlace(w http.ResponseWriter, r *http.Request) {
+	p, ok := s.findPair(r.Form.Get("symbol"), kucoinSymbol)
+	if !ok {
+		kucoinError(w, http.StatusBadRequest, "900001", "Symbol not exists")
+		return
+	}
+	req := models.OrderRequest{Trading: p.Trading, Settlement: p.Settlement}
+	switch r.Form.Get("type") {
+	case "BUY":
+	case "SELL":
+		req.Type = models.Bid
+	default:
+		kucoinError(w, http.StatusBadRequest, "400100", "type invalid")
+		return
+	}
+	req.Price, _ = decimal.NewFromString(r.Form.Get("price"))
+	req.Amount, _ = decimal.NewFromString(r.Form.Get("amount"))
+	o, err := s.Place(req, r.Form.Get("clientOid"))
+	switch {
+	case errors.Is(err, apierrors.ErrInsufficientFunds):
+		kucoinError(w, http.StatusBadRequest, "200004", "Balance insufficient")
+	case err != nil:
+		kucoinError(w, http.StatusBadRequest, "400100", err.Error())
+	default:
+		kucoinOK(w, map[string]string{"orderOid": o.ID})
+	}
+}
+
+func (s *Server) kucoinCancel(w http.ResponseWriter, r *http.Request) {
+	o, ok := s.Order(r.Form.Get("orderOid"))
+	if !ok || kucoinSymbol(models.CurrencyPair{Trading: o.Trading, Settlement: o.Settlement}) != r.Form.Get("symbol") {
+		kucoinError(w, http.StatusBadRequest, "400100", "order not exist")
+		return
+	}
+	if _, err := s.Cancel(o.ID); err != nil {
+		kucoinError(w, http.StatusBadRequest, "400100", "order not exist or already canceled")
+		return
+	}
+	kucoinOK(w, nil)
+}
+
+// kucoinDetail answers a null data for an order of the other side, which the
+// adapter relies on to find the side of an order.
+func (s *Server) kucoinDetail(w http.ResponseWriter, r *http.Request) {
+	o, ok := s.Order(r.Form.Get("orderOid"))
+	if !ok || kucoinSide(o.Type) != r.Form.Get("type") {
+		kucoinOK(w, nil)
+		return
+	}
+	var average decimal.Decimal
+	if o.Filled.IsPositive() {
+		average = o.Quote.Div(o.Filled)
+	}
+	pending := o.Amount.Sub(o.Filled)
+	if o.Status.Closed() {
+		pending = decimal.Zero
+	}
+	kucoinOK(w, map[string]interface{}{
+		"orderOid":         o.ID,
+		"coinType":         o.Trading,
+		"coinTypePair":     o.Settlement,
+		"type":             kucoinSide(o.Type),
+		"orderPrice":       o.Price,
+		"pendingAmount":    pending,
+		"dealAmount":       o.Filled,
+		"dealValueTotal":   o.Quote,
+		"dealPriceAverage": average,
+		"feeTotal":         o.Fee,
+		"createdAt":        millis(o.Created),
+		"isActive":         !o.Status.Closed(),
+	})
+}
+
+// kucoinActive lists the open orders of a symbol by side, each as
+// [time, side, price, amount, dealt amount, order id].
+func (s *Server) kucoinActive(w http.ResponseWriter, r *http.Request) {
+	data := map[string][][]interface{}{"BUY": {}, "SELL": {}}
+	for _, o := range s.open() {
+		if kucoinSymbol(models.CurrencyPair{Trading: o.Trading, Settlement: o.Settlement}) != r.Form.Get("symbol") {
+			continue
+		}
+		data[kucoinSide(o.Type)] = append(data[kucoinSide(o.Type)],
+			[]interface{}{millis(o.Created), kucoinSide(o.Type), o.Price, o.Amount, o.Filled, o.ID})
+	}
+	kucoinOK(w, data)
+}
+
+func (s *Server) kucoinDealt(w http.ResponseWriter, r *http.Request) {
+	p, ok := s.findPair(r.Form.Get("symbol"), kucoinSymbol)
+	if !ok {
+		kucoinError(w, http.StatusBadRequest, "900001", "Symbol not exists")
+		return
+	}
+	limit := 20
+	if v, err := strconv.Atoi(r.Form.Get("limit")); err == nil && v > 0 && v <= 20 {
+		limit = v
+	}
+	page := 1
+	if v, err := strconv.Atoi(r.Form.Get("page")); err == nil && v > 0 {
+		page = v
+	}
+	since, _ := strconv.ParseInt(r.Form.Get("since"), 10, 64)
+	var trades []Trade
+	for _, t := range s.Trades(p.Trading, p.Settlement) {
+		if millis(t.Time) >= since {
+			trades = append(trades, t)
+		}
+	}
+	datas := []map[string]interface{}{}
+	for i := (page - 1) * limit; i < len(trades) && i < page*limit; i++ {
+		t := trades[i]
+		datas = append(datas, map[string]interface{}{
+			"oid":          strconv.FormatInt(t.ID, 10),
+			"orderOid":     t.OrderID,
+			"coinType":     t.Trading,
+			"coinTypePair": t.Settlement,
+			"direction":    kucoinSide(t.Type),
+			"dealPrice":    t.Price,
+			"amount":       t.Amount,
+			"dealValue":    t.Price.Mul(t.Amount),
+			"fee":          t.Fee,
+			"feeRate":      s.Fee,
+			"createdAt":    millis(t.Time),
+		})
+	}
+	kucoinOK(w, map[string]interface{}{
+		"total": len(trades),
+		"datas": datas,
+		"limit": limit,
+		"page":  page,
+	})
+}
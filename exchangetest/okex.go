@@ -0,0 +1,50 @@
+package exchangetest
+
+import (
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/fxpgr/go-exchange-client/models"
+)
+
+// NewOkexServer starts a server speaking the okex REST API: the v2 depth and
+// the signed v1 endpoints, which mirror those of huobi.
+func NewOkexServer(apiKey string, secret string) *Server {
+	return newServer(apiKey, secret, func(s *Server, mux *http.ServeMux) {
+		mux.HandleFunc("/v2/markets/", s.okexDepth)
+		s.huobiRoutes(mux)
+	})
+}
+
+func okexSymbol(p models.CurrencyPair) string {
+	return strings.ToLower(p.Trading + "_" + p.Settlement)
+}
+
+// okexDepth serves /v2/markets/{symbol}/depth.
+func (s *Server) okexDepth(w http.ResponseWriter, r *http.Request) {
+	symbol := strings.TrimSuffix(strings.TrimPrefix(r.URL.Path, "/v2/markets/"), "/depth")
+	p, ok := s.findPair(symbol, okexSymbol)
+	if !ok {
+		writeJSON(w, http.StatusOK, map[string]interface{}{"code": 30032, "msg": "pair suspended", "data": nil})
+		return
+	}
+	board := s.Board(p.Trading, p.Settlement)
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"code": 0,
+		"msg":  "",
+		"data": map[string]interface{}{
+			"asks":      okexLevels(board.Asks),
+			"bids":      okexLevels(board.Bids),
+			"timestamp": millis(time.Now()),
+		},
+	})
+}
+
+func okexLevels(bars []models.BoardBar) []map[string]string {
+	l := make([]map[string]string, 0, len(bars))
+	for _, b := range bars {
+		l = append(l, map[string]string{"price": formatDecimal(b.Price), "totalSize": formatDecimal(b.Amount)})
+	}
+	return l
+}
@@ -0,0 +1,336 @@
+package exchangetest
+
+import (
+	"crypto/hmac"
+	"crypto/sha512"
+	"encoding/hex"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/fxpgr/go-exchange-client/apierrors"
+	"github.com/fxpgr/go-exchange-client/decimal"
+	"github.com/fxpgr/go-exchange-client/models"
+	"github.com/pkg/errors"
+)
+
+// NewPoloniexServer starts a server speaking the poloniex REST API: the
+// public order book at /public and the trading API, whose commands are
+// posted to / or /tradingApi with a Key and a HMAC-SHA512 Sign of the body.
+func NewPoloniexServer(apiKey string, secret string) *Server {
+	return newServer(apiKey, secret, func(s *Server, mux *http.ServeMux) {
+		p := &poloniex{Server: s}
+		mux.HandleFunc("/public", p.public)
+		mux.HandleFunc("/tradingApi", p.trading)
+		mux.HandleFunc("/", p.trading)
+	})
+}
+
+// poloniex holds the last nonce, which every trading request must exceed.
+type poloniex struct {
+	*Server
+	nonce int64
+	m     sync.Mutex
+}
+
+func poloniexSymbol(p models.CurrencyPair) string {
+	return p.Settlement + "_" + p.Trading
+}
+
+func poloniexError(w http.ResponseWriter, message string) {
+	writeJSON(w, http.StatusOK, map[string]string{"error": message})
+}
+
+func poloniexTime(t time.Time) string {
+	return t.UTC().Format("2006-01-02 15:04:05")
+}
+
+func (p *poloniex) public(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	if query.Get("command") != "returnOrderBook" {
+		poloniexError(w, "Invalid command.")
+		return
+	}
+	pair, ok := p.findPair(query.Get("currencyPair"), poloniexSymbol)
+	if !ok {
+		poloniexError(w, "Invalid currency pair.")
+		return
+	}
+	board := p.Board(pair.Trading, pair.Settlement)
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"asks":     poloniexLevels(board.Asks),
+		"bids":     poloniexLevels(board.Bids),
+		"isFrozen": "0",
+		"seq":      millis(time.Now()),
+	})
+}
+
+// poloniexLevels returns the bars of a board as [price, amount] pairs of a
+// string and a number.
+func poloniexLevels(bars []models.BoardBar) [][]interface{} {
+	l := make([][]interface{}, 0, len(bars))
+	for _, b := range bars {
+		l = append(l, []interface{}{formatDecimal(b.Price), b.Amount})
+	}
+	return l
+}
+
+func (p *poloniex) trading(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.NotFound(w, r)
+		return
+	}
+	body, _ := ioutil.ReadAll(r.Body)
+	mac := hmac.New(sha512.New, []byte(p.Secret))
+	mac.Write(body)
+	if r.Header.Get("Key") != p.APIKey || !hmac.Equal([]byte(r.Header.Get("Sign")), []byte(hex.EncodeToString(mac.Sum(nil)))) {
+		writeJSON(w, http.StatusForbidden, map[string]string{"error": "Invalid API key/secret pair."})
+		return
+	}
+	form, _ := url.ParseQuery(string(body))
+	nonce, _ := strconv.ParseInt(form.Get("nonce"), 10, 64)
+	p.m.Lock()
+	last := p.nonce
+	if nonce > last {
+		p.nonce = nonce
+	}
+	p.m.Unlock()
+	if nonce <= last {
+		poloniexError(w, "Nonce must be greater than "+strconv.FormatInt(last, 10)+". You provided "+form.Get("nonce")+".")
+		return
+	}
+	switch form.Get("command") {
+	case "returnBalances":
+		m := make(map[string]string)
+		for c, b := range p.Balances() {
+			m[c] = formatDecimal(b.Available)
+		}
+		writeJSON(w, http.StatusOK, m)
+	case "returnCompleteBalances":
+		m := make(map[string]map[string]string)
+		for c, b := range p.Balances() {
+			m[c] = map[string]string{
+				"available": formatDecimal(b.Available),
+				"onOrders":  formatDecimal(b.OnOrders),
+				"btcValue":  formatDecimal(decimal.Zero),
+			}
+		}
+		writeJSON(w, http.StatusOK, m)
+	case "buy", "sell":
+		p.place(w, form)
+	case "cancelOrder":
+		p.cancel(w, form)
+	case "returnOpenOrders":
+		p.openOrders(w, form)
+	case "returnOrderStatus":
+		p.orderStatus(w, form)
+	case "returnOrderTrades":
+		p.orderTrades(w, form)
+	case "returnTradeHistory":
+		p.tradeHistory(w, form)
+	default:
+		poloniexError(w, "Invalid command.")
+	}
+}
+
+func (p *poloniex) place(w http.ResponseWriter, form url.Values) {
+	pair, ok := p.findPair(form.Get("currencyPair"), poloniexSymbol)
+	if !ok {
+		poloniexError(w, "Invalid currency pair.")
+		return
+	}
+	req := models.OrderRequest{Trading: pair.Trading, Settlement: pair.Settlement}
+	if form.Get("command") == "sell" {
+		req.Type = models.Bid
+	}
+	switch {
+	case form.Get("immediateOrCancel") == "1":
+		req.TimeInForce = models.IOC
+	case form.Get("fillOrKill") == "1":
+		req.TimeInForce = models.FOK
+	case form.Get("postOnly") == "1":
+		req.TimeInForce = models.PostOnly
+	}
+	req.Price, _ = decimal.NewFromString(form.Get("rate"))
+	req.Amount, _ = decimal.NewFromString(form.Get("amount"))
+	o, err := p.Place(req, "")
+	switch {
+	case errors.Is(err, apierrors.ErrInsufficientFunds):
+		currency := pair.Trading
+		if req.Type == models.Ask {
+			currency = pair.Settlement
+		}
+		poloniexError(w, "Not enough "+currency+".")
+		return
+	case errors.Is(err, ErrWouldTake):
+		poloniexError(w, "Unable to place post-only order at this price.")
+		return
+	case err != nil:
+		poloniexError(w, "Total must be at least 0.0001.")
+		return
+	}
+	if o.TimeInForce == models.FOK && o.Filled.IsZero() {
+		poloniexError(w, "Unable to fill order completely.")
+		return
+	}
+	resulting := []map[string]string{}
+	for _, t := range p.Trades(o.Trading, o.Settlement) {
+		if t.OrderID == o.ID {
+			resulting = append(resulting, map[string]string{
+				"amount":  formatDecimal(t.Amount),
+				"date":    poloniexTime(t.Time),
+				"rate":    formatDecimal(t.Price),
+				"total":   formatDecimal(t.Amount.Mul(t.Price)),
+				"tradeID": strconv.FormatInt(t.ID, 10),
+				"type":    side(t.Type),
+			})
+		}
+	}
+	orderNumber, _ := strconv.ParseInt(o.ID, 10, 64)
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"orderNumber":     orderNumber,
+		"resultingTrades": resulting,
+		"fee":             formatDecimal(p.Fee),
+		"currencyPair":    form.Get("currencyPair"),
+	})
+}
+
+func (p *poloniex) cancel(w http.ResponseWriter, form url.Values) {
+	o, err := p.Cancel(form.Get("orderNumber"))
+	if err != nil {
+		poloniexError(w, "Invalid order number, or you are not the person who placed the order.")
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"success": 1,
+		"amount":  formatDecimal(o.Amount.Sub(o.Filled)),
+		"message": "Order #" + o.ID + " canceled.",
+	})
+}
+
+func poloniexOpenOrderJSON(o Order) map[string]string {
+	return map[string]string{
+		"orderNumber":    o.ID,
+		"type":           side(o.Type),
+		"rate":           formatDecimal(o.Price),
+		"startingAmount": formatDecimal(o.Amount),
+		"amount":         formatDecimal(o.Amount.Sub(o.Filled)),
+		"total":          formatDecimal(o.Amount.Sub(o.Filled).Mul(o.Price)),
+		"date":           poloniexTime(o.Created),
+		"margin":         "0",
+	}
+}
+
+// openOrders answers a single pair with a list, and "all" with the lists of
+// every pair.
+func (p *poloniex) openOrders(w http.ResponseWriter, form url.Values) {
+	all := make(map[string][]map[string]string)
+	for _, pair := range p.Pairs() {
+		all[poloniexSymbol(pair)] = []map[string]string{}
+	}
+	for _, o := range p.open() {
+		symbol := poloniexSymbol(models.CurrencyPair{Trading: o.Trading, Settlement: o.Settlement})
+		all[symbol] = append(all[symbol], poloniexOpenOrderJSON(o))
+	}
+	if form.Get("currencyPair") == "all" {
+		writeJSON(w, http.StatusOK, all)
+		return
+	}
+	list, ok := all[form.Get("currencyPair")]
+	if !ok {
+		poloniexError(w, "Invalid currency pair.")
+		return
+	}
+	writeJSON(w, http.StatusOK, list)
+}
+
+// orderStatus only knows open orders, as poloniex does.
+func (p *poloniex) orderStatus(w http.ResponseWriter, form url.Values) {
+	id := form.Get("orderNumber")
+	o, ok := p.Order(id)
+	if !ok || o.Status.Closed() {
+		writeJSON(w, http.StatusOK, map[string]interface{}{
+			"result":  map[string]string{"error": "Order not found, or you are not the person who placed it."},
+			"success": 0,
+		})
+		return
+	}
+	v := poloniexOpenOrderJSON(o)
+	v["currencyPair"] = poloniexSymbol(models.CurrencyPair{Trading: o.Trading, Settlement: o.Settlement})
+	v["status"] = "Open"
+	if o.Filled.IsPositive() {
+		v["status"] = "Partially filled"
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"result":  map[string]interface{}{id: v},
+		"success": 1,
+	})
+}
+
+// tradeJSON reports the fee as the rate charged, as poloniex does.
+func (p *poloniex) tradeJSON(t Trade) map[string]interface{} {
+	orderNumber, _ := strconv.ParseInt(t.OrderID, 10, 64)
+	return map[string]interface{}{
+		"globalTradeID": t.ID + 100000000,
+		"tradeID":       strconv.FormatInt(t.ID, 10),
+		"currencyPair":  poloniexSymbol(models.CurrencyPair{Trading: t.Trading, Settlement: t.Settlement}),
+		"type":          side(t.Type),
+		"rate":          formatDecimal(t.Price),
+		"amount":        formatDecimal(t.Amount),
+		"total":         formatDecimal(t.Amount.Mul(t.Price)),
+		"fee":           formatDecimal(p.Fee),
+		"date":          poloniexTime(t.Time),
+		"orderNumber":   strconv.FormatInt(orderNumber, 10),
+		"category":      "exchange",
+	}
+}
+
+func (p *poloniex) orderTrades(w http.ResponseWriter, form url.Values) {
+	var list []map[string]interface{}
+	for _, t := range p.Trades("", "") {
+		if t.OrderID == form.Get("orderNumber") {
+			list = append(list, p.tradeJSON(t))
+		}
+	}
+	if len(list) == 0 {
+		poloniexError(w, "Order not found, or you are not the person who placed it.")
+		return
+	}
+	writeJSON(w, http.StatusOK, list)
+}
+
+// tradeHistory returns the newest trades between start and end, newest
+// first.
+func (p *poloniex) tradeHistory(w http.ResponseWriter, form url.Values) {
+	pair, ok := p.findPair(form.Get("currencyPair"), poloniexSymbol)
+	if !ok {
+		poloniexError(w, "Invalid currency pair.")
+		return
+	}
+	start, _ := strconv.ParseInt(form.Get("start"), 10, 64)
+	end := time.Now().Unix()
+	if v, err := strconv.ParseInt(form.Get("end"), 10, 64); err == nil {
+		end = v
+	}
+	limit := 500
+	if v, err := strconv.Atoi(form.Get("limit")); err == nil && v > 0 {
+		limit = v
+	}
+	trades := p.Trades(pair.Trading, pair.Settlement)
+	sort.SliceStable(trades, func(i, j int) bool { return trades[i].ID > trades[j].ID })
+	list := []map[string]interface{}{}
+	for _, t := range trades {
+		if t.Time.Unix() < start || t.Time.Unix() > end {
+			continue
+		}
+		if len(list) == limit {
+			break
+		}
+		list = append(list, p.tradeJSON(t))
+	}
+	writeJSON(w, http.StatusOK, list)
+}
@@ -0,0 +1,85 @@
+package exchangetest
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"time"
+
+	"github.com/fxpgr/go-exchange-client/decimal"
+	"github.com/fxpgr/go-exchange-client/models"
+)
+
+// Server is a TLS httptest server speaking the REST protocol of one exchange
+// over an Exchange. Private requests which are not signed with APIKey and
+// Secret, and Passphrase where the exchange has one, are rejected the way the
+// exchange rejects them. Adapters reach it by BaseURL, with the HttpClient of
+// Client() which trusts its certificate.
+type Server struct {
+	*httptest.Server
+	*Exchange
+	APIKey     string
+	Secret     string
+	Passphrase string
+}
+
+func newServer(apiKey string, secret string, routes func(s *Server, mux *http.ServeMux)) *Server {
+	s := &Server{Exchange: NewExchange(), APIKey: apiKey, Secret: secret}
+	mux := http.NewServeMux()
+	routes(s, mux)
+	s.Server = httptest.NewTLSServer(mux)
+	return s
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+func formatDecimal(v decimal.Decimal) string {
+	return v.StringFixed(8)
+}
+
+func millis(t time.Time) int64 {
+	if t.IsZero() {
+		return 0
+	}
+	return t.UnixNano() / int64(time.Millisecond)
+}
+
+// randomID returns n random hex digits, for the ids exchanges generate.
+func randomID(n int) string {
+	b := make([]byte, (n+1)/2)
+	rand.Read(b)
+	return hex.EncodeToString(b)[:n]
+}
+
+// levels returns the bars of a board as [price, amount] pairs of strings.
+func levels(bars []models.BoardBar) [][]string {
+	l := make([][]string, 0, len(bars))
+	for _, b := range bars {
+		l = append(l, []string{formatDecimal(b.Price), formatDecimal(b.Amount)})
+	}
+	return l
+}
+
+func side(t models.OrderType) string {
+	if t == models.Ask {
+		return "buy"
+	}
+	return "sell"
+}
+
+// open returns the orders which are not closed.
+func (s *Server) open() []Order {
+	var orders []Order
+	for _, o := range s.Orders() {
+		if !o.Status.Closed() {
+			orders = append(orders, o)
+		}
+	}
+	return orders
+}
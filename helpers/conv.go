@@ -1,8 +1,12 @@
 package helpers
 
 import (
+	"encoding/json"
 	"fmt"
 	"strconv"
+
+	"github.com/fxpgr/go-exchange-client/decimal"
+	"github.com/tidwall/gjson"
 )
 
 func ToFloat64(v interface{}) float64 {
@@ -22,6 +26,29 @@ func ToFloat64(v interface{}) float64 {
 	}
 }
 
+// ToDecimal is ToFloat64 for exact decimals. It also takes a gjson.Result,
+// whose numbers are read from their raw text rather than through a float.
+func ToDecimal(v interface{}) decimal.Decimal {
+	switch v := v.(type) {
+	case nil:
+		return decimal.Zero
+	case float64:
+		return decimal.NewFromFloat(v)
+	case string:
+		d, _ := decimal.NewFromString(v)
+		return d
+	case json.Number:
+		return ToDecimal(string(v))
+	case gjson.Result:
+		if v.Type == gjson.Number {
+			return ToDecimal(v.Raw)
+		}
+		return ToDecimal(v.String())
+	default:
+		panic("to decimal error.")
+	}
+}
+
 func ToInt(v interface{}) int {
 	if v == nil {
 		return 0
@@ -1,18 +1,26 @@
 package helpers
 
 import (
+	"context"
 	"encoding/json"
-	"errors"
-	"fmt"
 	"io/ioutil"
 	"log"
 	"net/http"
 	"net/url"
 	"strings"
+
+	"github.com/fxpgr/go-exchange-client/apierrors"
 )
 
 func NewHttpRequest(client *http.Client, reqType string, reqUrl string, postData string, requstHeaders map[string]string) ([]byte, error) {
-	req, _ := http.NewRequest(reqType, reqUrl, strings.NewReader(postData))
+	return NewHttpRequestWithContext(context.Background(), client, reqType, reqUrl, postData, requstHeaders)
+}
+
+func NewHttpRequestWithContext(ctx context.Context, client *http.Client, reqType string, reqUrl string, postData string, requstHeaders map[string]string) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, reqType, reqUrl, strings.NewReader(postData))
+	if err != nil {
+		return nil, err
+	}
 	if req.Header.Get("User-Agent") == "" {
 		req.Header.Set("User-Agent", "Mozilla/5.0 (Windows NT 5.1) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/31.0.1650.63 Safari/537.36")
 	}
@@ -35,7 +43,7 @@ func NewHttpRequest(client *http.Client, reqType string, reqUrl string, postData
 	}
 
 	if resp.StatusCode != 200 {
-		return nil, errors.New(fmt.Sprintf("HttpStatusCode:%d ,Desc:%s", resp.StatusCode, string(bodyData)))
+		return nil, apierrors.New(resp.StatusCode, "", string(bodyData), apierrors.FromStatus(resp.StatusCode))
 	}
 
 	return bodyData, nil
@@ -57,11 +65,15 @@ func HttpGet(client *http.Client, reqUrl string) (map[string]interface{}, error)
 }
 
 func HttpGet2(client *http.Client, reqUrl string, headers map[string]string) (map[string]interface{}, error) {
+	return HttpGet2WithContext(context.Background(), client, reqUrl, headers)
+}
+
+func HttpGet2WithContext(ctx context.Context, client *http.Client, reqUrl string, headers map[string]string) (map[string]interface{}, error) {
 	if headers == nil {
 		headers = map[string]string{}
 	}
 	headers["Content-Type"] = "application/x-www-form-urlencoded"
-	respData, err := NewHttpRequest(client, "GET", reqUrl, "", headers)
+	respData, err := NewHttpRequestWithContext(ctx, client, "GET", reqUrl, "", headers)
 	if err != nil {
 		return nil, err
 	}
@@ -1,11 +1,13 @@
 package models
 
+import "github.com/fxpgr/go-exchange-client/decimal"
+
 type Balance struct {
-	Available float64
-	OnOrders  float64
+	Available decimal.Decimal
+	OnOrders  decimal.Decimal
 }
 
-func NewBalance(available float64, onOrders float64) *Balance {
+func NewBalance(available decimal.Decimal, onOrders decimal.Decimal) *Balance {
 	return &Balance{
 		Available: available,
 		OnOrders:  onOrders,
@@ -1,21 +1,22 @@
 package models
 
 import (
+	"github.com/fxpgr/go-exchange-client/decimal"
 	"github.com/pkg/errors"
 	"sort"
 )
 
 type OrderBookTick struct {
-	BestAskPrice  float64
-	BestAskAmount float64
-	BestBidPrice  float64
-	BestBidAmount float64
+	BestAskPrice  decimal.Decimal
+	BestAskAmount decimal.Decimal
+	BestBidPrice  decimal.Decimal
+	BestBidAmount decimal.Decimal
 }
 
 type BoardBar struct {
-	Type   OrderType `json:"-"`
-	Price  float64   `json:"price"`
-	Amount float64   `json:"amount"`
+	Type   OrderType       `json:"-"`
+	Price  decimal.Decimal `json:"price"`
+	Amount decimal.Decimal `json:"amount"`
 }
 
 type Board struct {
@@ -23,84 +24,90 @@ type Board struct {
 	Bids []BoardBar `json:"bids"`
 }
 
-func (b *Board) BestBidAmount() float64 {
+func (b *Board) BestBidAmount() decimal.Decimal {
 	if len(b.Bids) == 0 {
-		return 0
+		return decimal.Zero
 	}
 	sort.Slice(b.Bids, func(i, j int) bool {
-		return b.Bids[i].Price > b.Bids[j].Price
+		return b.Bids[i].Price.GreaterThan(b.Bids[j].Price)
 	})
 	return b.Bids[0].Amount
 }
 
-func (b *Board) BestAskAmount() float64 {
+func (b *Board) BestAskAmount() decimal.Decimal {
 	if len(b.Asks) == 0 {
-		return 0
+		return decimal.Zero
 	}
 	sort.Slice(b.Asks, func(i, j int) bool {
-		return b.Asks[i].Price < b.Asks[j].Price
+		return b.Asks[i].Price.LessThan(b.Asks[j].Price)
 	})
 	return b.Asks[0].Amount
 }
 
-func (b *Board) BestBidPrice() float64 {
+func (b *Board) BestBidPrice() decimal.Decimal {
 	if len(b.Bids) == 0 {
-		return 0
+		return decimal.Zero
 	}
 	sort.Slice(b.Bids, func(i, j int) bool {
-		return b.Bids[i].Price > b.Bids[j].Price
+		return b.Bids[i].Price.GreaterThan(b.Bids[j].Price)
 	})
 	return b.Bids[0].Price
 }
 
-func (b *Board) BestAskPrice() float64 {
+func (b *Board) BestAskPrice() decimal.Decimal {
 	if len(b.Asks) == 0 {
-		return 0
+		return decimal.Zero
 	}
 	sort.Slice(b.Asks, func(i, j int) bool {
-		return b.Asks[i].Price < b.Asks[j].Price
+		return b.Asks[i].Price.LessThan(b.Asks[j].Price)
 	})
 	return b.Asks[0].Price
 }
 
-func (b *Board) AverageBidRate(amount float64) (float64, error) {
+func (b *Board) AverageBidRate(amount decimal.Decimal) (decimal.Decimal, error) {
 	if len(b.Bids) == 0 {
-		return 0, errors.New("there is no bids")
+		return decimal.Zero, errors.New("there is no bids")
+	}
+	if !amount.IsPositive() {
+		return decimal.Zero, errors.Errorf("invalid amount %v", amount)
 	}
 	sort.Slice(b.Bids, func(i, j int) bool {
-		return b.Bids[i].Price < b.Bids[j].Price
+		return b.Bids[i].Price.GreaterThan(b.Bids[j].Price)
 	})
-	var sum float64
+	sum := decimal.Zero
 	remainingAmount := amount
 	for _, v := range b.Bids {
-		if v.Amount > remainingAmount {
-			sum += remainingAmount * v.Price
-			return sum / amount, nil
+		if v.Amount.Cmp(remainingAmount) >= 0 {
+			sum = sum.Add(remainingAmount.Mul(v.Price))
+			return sum.Div(amount), nil
 		} else {
-			sum += v.Amount * v.Price
-			remainingAmount = remainingAmount - v.Amount
+			sum = sum.Add(v.Amount.Mul(v.Price))
+			remainingAmount = remainingAmount.Sub(v.Amount)
 		}
 	}
-	return 0, errors.New("there is not enough board orders")
+	return decimal.Zero, errors.New("there is not enough board orders")
 }
 
-func (b *Board) AverageAskRate(amount float64) (float64, error) {
+func (b *Board) AverageAskRate(amount decimal.Decimal) (decimal.Decimal, error) {
 	if len(b.Asks) == 0 {
-		return 0, errors.New("there is no asks")
+		return decimal.Zero, errors.New("there is no asks")
+	}
+	if !amount.IsPositive() {
+		return decimal.Zero, errors.Errorf("invalid amount %v", amount)
 	}
 	sort.Slice(b.Asks, func(i, j int) bool {
-		return b.Asks[i].Price < b.Asks[j].Price
+		return b.Asks[i].Price.LessThan(b.Asks[j].Price)
 	})
-	var sum float64
+	sum := decimal.Zero
 	remainingAmount := amount
 	for _, v := range b.Asks {
-		if v.Amount > remainingAmount {
-			sum += remainingAmount * v.Price
-			return sum / amount, nil
+		if v.Amount.Cmp(remainingAmount) >= 0 {
+			sum = sum.Add(remainingAmount.Mul(v.Price))
+			return sum.Div(amount), nil
 		} else {
-			sum += v.Amount * v.Price
-			remainingAmount = remainingAmount - v.Amount
+			sum = sum.Add(v.Amount.Mul(v.Price))
+			remainingAmount = remainingAmount.Sub(v.Amount)
 		}
 	}
-	return 0, errors.New("there is not enough board orders")
+	return decimal.Zero, errors.New("there is not enough board orders")
 }
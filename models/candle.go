@@ -0,0 +1,65 @@
+package models
+
+import (
+	"time"
+
+	"github.com/fxpgr/go-exchange-client/decimal"
+)
+
+type Interval int
+
+const (
+	Interval1m Interval = iota + 1
+	Interval3m
+	Interval5m
+	Interval15m
+	Interval30m
+	Interval1h
+	Interval2h
+	Interval4h
+	Interval6h
+	Interval8h
+	Interval12h
+	Interval1d
+	Interval1w
+)
+
+var intervals = map[Interval]struct {
+	name     string
+	duration time.Duration
+}{
+	Interval1m:  {"1m", time.Minute},
+	Interval3m:  {"3m", 3 * time.Minute},
+	Interval5m:  {"5m", 5 * time.Minute},
+	Interval15m: {"15m", 15 * time.Minute},
+	Interval30m: {"30m", 30 * time.Minute},
+	Interval1h:  {"1h", time.Hour},
+	Interval2h:  {"2h", 2 * time.Hour},
+	Interval4h:  {"4h", 4 * time.Hour},
+	Interval6h:  {"6h", 6 * time.Hour},
+	Interval8h:  {"8h", 8 * time.Hour},
+	Interval12h: {"12h", 12 * time.Hour},
+	Interval1d:  {"1d", 24 * time.Hour},
+	Interval1w:  {"1w", 7 * 24 * time.Hour},
+}
+
+func (i Interval) Duration() time.Duration {
+	return intervals[i].duration
+}
+
+func (i Interval) String() string {
+	if v, ok := intervals[i]; ok {
+		return v.name
+	}
+	return "unknown"
+}
+
+// Candle is an OHLCV bar opening at Time. Volume is in the trading currency.
+type Candle struct {
+	Time   time.Time
+	Open   decimal.Decimal
+	High   decimal.Decimal
+	Low    decimal.Decimal
+	Close  decimal.Decimal
+	Volume decimal.Decimal
+}
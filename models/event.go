@@ -0,0 +1,36 @@
+package models
+
+import "time"
+
+// UserEventType is what changed in an account.
+type UserEventType int
+
+const (
+	OrderUpdate UserEventType = iota
+	Fill
+	BalanceChange
+)
+
+func (t UserEventType) String() string {
+	switch t {
+	case OrderUpdate:
+		return "order update"
+	case Fill:
+		return "fill"
+	case BalanceChange:
+		return "balance change"
+	}
+	return "unknown"
+}
+
+// UserEvent is a change of an account pushed by a user data stream. Order is
+// set for an OrderUpdate, Trade for a Fill, and Currency and Balance for a
+// BalanceChange. Time is when the exchange made the change.
+type UserEvent struct {
+	Type     UserEventType
+	Order    *Order
+	Trade    *Trade
+	Currency string
+	Balance  *Balance
+	Time     time.Time
+}
@@ -1,7 +1,64 @@
 package models
 
-import "testing"
+import (
+	"testing"
+
+	"github.com/fxpgr/go-exchange-client/decimal"
+)
+
+func d(s string) decimal.Decimal {
+	return decimal.RequireFromString(s)
+}
 
 func TestNewBalance(t *testing.T) {
-	_ = NewBalance(0.1, 0.05)
+	_ = NewBalance(d("0.1"), d("0.05"))
+}
+
+func TestBoardAverageRate(t *testing.T) {
+	b := &Board{
+		Asks: []BoardBar{{Price: d("102"), Amount: d("1")}, {Price: d("101"), Amount: d("1")}},
+		Bids: []BoardBar{{Price: d("98"), Amount: d("1")}, {Price: d("99"), Amount: d("1")}},
+	}
+	if rate, err := b.AverageAskRate(d("2")); err != nil || !rate.Equal(d("101.5")) {
+		t.Errorf("Board: Expected %v. Got %v, %v", 101.5, rate, err)
+	}
+	if rate, err := b.AverageBidRate(d("1")); err != nil || !rate.Equal(d("99")) {
+		t.Errorf("Board: Expected %v. Got %v, %v", 99, rate, err)
+	}
+	if _, err := b.AverageBidRate(d("3")); err == nil {
+		t.Error("Board: Expected not enough board orders")
+	}
+}
+
+func TestMarketRulesPrecisions(t *testing.T) {
+	r := NewMarketRules(Precisions{PricePrecision: 6, AmountPrecision: 0})
+	if !r.TickSize.Equal(d("0.000001")) || !r.StepSize.Equal(d("1")) {
+		t.Errorf("unexpected rules %+v", r)
+	}
+	if p := r.Precisions(); p.PricePrecision != 6 || p.AmountPrecision != 0 {
+		t.Errorf("unexpected precisions %+v", p)
+	}
+	if p := (MarketRules{TickSize: d("0.05"), StepSize: d("10")}).Precisions(); p.PricePrecision != 2 || p.AmountPrecision != 0 {
+		t.Errorf("unexpected precisions %+v", p)
+	}
+}
+
+func TestMarketRulesRound(t *testing.T) {
+	r := MarketRules{TickSize: d("0.05"), StepSize: d("0.01")}
+	tests := []struct {
+		got      decimal.Decimal
+		expected string
+	}{
+		{r.RoundPrice(d("1.23"), false), "1.2"},
+		{r.RoundPrice(d("1.23"), true), "1.25"},
+		{r.RoundPrice(d("1.25"), true), "1.25"},
+		{r.FloorAmount(d("0.29")), "0.29"},
+		{r.FloorAmount(d("0.299999")), "0.29"},
+		{(MarketRules{}).FloorAmount(d("0.123")), "0.123"},
+	}
+	for i, test := range tests {
+		if test.got.String() != test.expected {
+			t.Errorf("%d: Expected %v. Got %v", i, test.expected, test.got)
+		}
+	}
 }
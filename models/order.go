@@ -1,5 +1,11 @@
 package models
 
+import (
+	"time"
+
+	"github.com/fxpgr/go-exchange-client/decimal"
+)
+
 type OrderType int
 
 const (
@@ -7,16 +13,120 @@ const (
 	Bid
 )
 
+type ExecutionType int
+
+const (
+	Limit ExecutionType = iota
+	Market
+)
+
+func (e ExecutionType) String() string {
+	switch e {
+	case Limit:
+		return "limit"
+	case Market:
+		return "market"
+	}
+	return "unknown"
+}
+
+type TimeInForce int
+
+const (
+	GTC TimeInForce = iota
+	IOC
+	FOK
+	PostOnly
+)
+
+func (t TimeInForce) String() string {
+	switch t {
+	case GTC:
+		return "GTC"
+	case IOC:
+		return "IOC"
+	case FOK:
+		return "FOK"
+	case PostOnly:
+		return "post-only"
+	}
+	return "unknown"
+}
+
+// OrderRequest is an order to be placed. The zero ExecutionType and
+// TimeInForce make a GTC limit order. TimeInForce applies to limit orders
+// only, and Price is ignored for market orders except where an exchange
+// needs it to size a market buy in the settlement currency.
+type OrderRequest struct {
+	Trading       string
+	Settlement    string
+	Type          OrderType
+	ExecutionType ExecutionType
+	TimeInForce   TimeInForce
+	Price         decimal.Decimal
+	Amount        decimal.Decimal
+}
+
+type OrderStatus int
+
+const (
+	OrderUnknown OrderStatus = iota
+	OrderOpen
+	OrderPartiallyFilled
+	OrderFilled
+	OrderCanceled
+	OrderRejected
+	OrderExpired
+)
+
+func (s OrderStatus) String() string {
+	switch s {
+	case OrderOpen:
+		return "open"
+	case OrderPartiallyFilled:
+		return "partially filled"
+	case OrderFilled:
+		return "filled"
+	case OrderCanceled:
+		return "canceled"
+	case OrderRejected:
+		return "rejected"
+	case OrderExpired:
+		return "expired"
+	}
+	return "unknown"
+}
+
+// Closed reports whether the order can no longer be filled.
+func (s OrderStatus) Closed() bool {
+	return s >= OrderFilled
+}
+
+// Order is an order as reported by the exchange. The fields after Amount are
+// filled in by OrderStatus and left zero where an exchange does not report
+// them. Fee is denominated in FeeCurrency.
 type Order struct {
 	ExchangeOrderID string
 	Type            OrderType
 	Trading         string
 	Settlement      string
-	Price           float64
-	Amount          float64
+	Price           decimal.Decimal
+	Amount          decimal.Decimal
+
+	Status       OrderStatus
+	FilledAmount decimal.Decimal
+	AveragePrice decimal.Decimal
+	Fee          decimal.Decimal
+	FeeCurrency  string
+	CreatedAt    time.Time
+	UpdatedAt    time.Time
+}
+
+func (o *Order) RemainingAmount() decimal.Decimal {
+	return o.Amount.Sub(o.FilledAmount)
 }
 
 type FilledOrderInfo struct {
-	Price  float64
-	Amount float64
+	Price  decimal.Decimal
+	Amount decimal.Decimal
 }
@@ -0,0 +1,49 @@
+package models
+
+import "github.com/fxpgr/go-exchange-client/decimal"
+
+// MarketRules are the trading rules of a pair. Prices are multiples of
+// TickSize between MinPrice and MaxPrice, amounts are multiples of StepSize
+// between MinAmount and MaxAmount, and the price times the amount of an order
+// is at least MinNotional of the settlement currency. A zero value is a rule
+// the exchange does not set or publish.
+type MarketRules struct {
+	TickSize    decimal.Decimal
+	StepSize    decimal.Decimal
+	MinAmount   decimal.Decimal
+	MaxAmount   decimal.Decimal
+	MinNotional decimal.Decimal
+	MinPrice    decimal.Decimal
+	MaxPrice    decimal.Decimal
+}
+
+// NewMarketRules returns the rules of a pair whose exchange only publishes
+// its precisions.
+func NewMarketRules(p Precisions) MarketRules {
+	return MarketRules{
+		TickSize: decimal.New(1, -int32(p.PricePrecision)),
+		StepSize: decimal.New(1, -int32(p.AmountPrecision)),
+	}
+}
+
+// Precisions returns the decimals of TickSize and StepSize.
+func (r MarketRules) Precisions() Precisions {
+	return Precisions{
+		PricePrecision:  int(r.TickSize.Places()),
+		AmountPrecision: int(r.StepSize.Places()),
+	}
+}
+
+// RoundPrice returns price on the tick, rounded up when up is true and down
+// otherwise.
+func (r MarketRules) RoundPrice(price decimal.Decimal, up bool) decimal.Decimal {
+	if up {
+		return price.CeilStep(r.TickSize)
+	}
+	return price.FloorStep(r.TickSize)
+}
+
+// FloorAmount returns amount rounded down to the step.
+func (r MarketRules) FloorAmount(amount decimal.Decimal) decimal.Decimal {
+	return amount.FloorStep(r.StepSize)
+}
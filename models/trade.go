@@ -0,0 +1,32 @@
+package models
+
+import (
+	"time"
+
+	"github.com/fxpgr/go-exchange-client/decimal"
+)
+
+// Trade is a fill of one of our own orders. Fee is denominated in
+// FeeCurrency.
+type Trade struct {
+	ID          string
+	OrderID     string
+	Trading     string
+	Settlement  string
+	Type        OrderType
+	Price       decimal.Decimal
+	Amount      decimal.Decimal
+	Fee         decimal.Decimal
+	FeeCurrency string
+	Time        time.Time
+}
+
+// PublicTrade is a trade of the public tape. Type is the side of the taker,
+// so Ask is a market buy and Bid a market sell.
+type PublicTrade struct {
+	ID     string
+	Type   OrderType
+	Price  decimal.Decimal
+	Amount decimal.Decimal
+	Time   time.Time
+}
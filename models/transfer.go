@@ -0,0 +1,48 @@
+package models
+
+import (
+	"time"
+
+	"github.com/fxpgr/go-exchange-client/decimal"
+)
+
+type TransferStatus int
+
+const (
+	TransferUnknown TransferStatus = iota
+	TransferPending
+	TransferCompleted
+	TransferFailed
+	TransferCanceled
+)
+
+func (s TransferStatus) String() string {
+	switch s {
+	case TransferPending:
+		return "pending"
+	case TransferCompleted:
+		return "completed"
+	case TransferFailed:
+		return "failed"
+	case TransferCanceled:
+		return "canceled"
+	}
+	return "unknown"
+}
+
+// Transfer is a deposit to or a withdrawal from an exchange. Tag holds the
+// memo, payment id or destination tag of currencies which need one, and
+// Network the chain it was sent over when the exchange reports it.
+type Transfer struct {
+	ID        string
+	Currency  string
+	Amount    decimal.Decimal
+	Fee       decimal.Decimal
+	Address   string
+	Tag       string
+	TxID      string
+	Network   string
+	Status    TransferStatus
+	CreatedAt time.Time
+	UpdatedAt time.Time
+}
@@ -0,0 +1,66 @@
+package ratelimit
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type profile struct {
+	limit           int
+	interval        time.Duration
+	weigh           func(*http.Request) int
+	usedHeader      string
+	remainingHeader string
+}
+
+var profiles = map[string]profile{
+	"binance": {
+		limit:      1200,
+		interval:   time.Minute,
+		weigh:      binanceWeight,
+		usedHeader: "X-Mbx-Used-Weight",
+	},
+	"bitflyer":  {limit: 500, interval: 5 * time.Minute},
+	"cobinhood": {limit: 10, interval: time.Second},
+	"hitbtc":    {limit: 100, interval: time.Second},
+	"huobi": {
+		limit:           100,
+		interval:        10 * time.Second,
+		remainingHeader: "X-Hb-Ratelimit-Requests-Remain",
+	},
+	"kucoin": {
+		limit:           1800,
+		interval:        time.Minute,
+		remainingHeader: "Gw-Ratelimit-Remaining",
+	},
+	"lbank":    {limit: 10, interval: time.Second},
+	"okex":     {limit: 20, interval: 2 * time.Second},
+	"p2pb2b":   {limit: 10, interval: time.Second},
+	"poloniex": {limit: 6, interval: time.Second},
+}
+
+func binanceWeight(req *http.Request) int {
+	path := req.URL.Path
+	query := req.URL.Query()
+	switch {
+	case strings.HasSuffix(path, "/ticker/24hr"), strings.HasSuffix(path, "/openOrders"):
+		if query.Get("symbol") == "" {
+			return 40
+		}
+	case strings.HasSuffix(path, "/depth"):
+		limit, _ := strconv.Atoi(query.Get("limit"))
+		switch {
+		case limit > 1000:
+			return 50
+		case limit > 500:
+			return 10
+		case limit > 100:
+			return 5
+		}
+	case strings.HasSuffix(path, "/account"), strings.HasSuffix(path, "/allOrders"), strings.HasSuffix(path, "/myTrades"):
+		return 5
+	}
+	return 1
+}
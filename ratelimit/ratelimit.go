@@ -0,0 +1,200 @@
+package ratelimit
+
+import (
+	"context"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Limiter is a token bucket measured in request weight. It holds at most
+// limit weight and refills at limit per interval.
+type Limiter struct {
+	limit    int
+	interval time.Duration
+	tokens   float64
+	updated  time.Time
+
+	weigh           func(*http.Request) int
+	usedHeader      string
+	remainingHeader string
+
+	m *sync.Mutex
+}
+
+// NewLimiter returns a limiter allowing limit weight per interval. A limit of
+// zero or less disables limiting.
+func NewLimiter(limit int, interval time.Duration) *Limiter {
+	return &Limiter{
+		limit:    limit,
+		interval: interval,
+		tokens:   float64(limit),
+		updated:  time.Now(),
+		m:        new(sync.Mutex),
+	}
+}
+
+func (l *Limiter) SetLimit(limit int, interval time.Duration) {
+	l.m.Lock()
+	defer l.m.Unlock()
+	l.refill(time.Now())
+	l.limit = limit
+	l.interval = interval
+	l.tokens = math.Min(l.tokens, float64(limit))
+}
+
+func (l *Limiter) Limit() (int, time.Duration) {
+	l.m.Lock()
+	defer l.m.Unlock()
+	return l.limit, l.interval
+}
+
+// Remaining returns the weight which can be spent right now without waiting.
+func (l *Limiter) Remaining() int {
+	l.m.Lock()
+	defer l.m.Unlock()
+	l.refill(time.Now())
+	return int(l.tokens)
+}
+
+// SetRemaining overwrites the local estimate with the budget reported by the
+// exchange.
+func (l *Limiter) SetRemaining(weight int) {
+	l.m.Lock()
+	defer l.m.Unlock()
+	l.updated = time.Now()
+	l.tokens = math.Max(0, math.Min(float64(weight), float64(l.limit)))
+}
+
+// SetUsed is SetRemaining for exchanges reporting the weight already spent.
+func (l *Limiter) SetUsed(weight int) {
+	limit, _ := l.Limit()
+	l.SetRemaining(limit - weight)
+}
+
+// Weight returns the documented weight of req, 1 when it is not known.
+func (l *Limiter) Weight(req *http.Request) int {
+	if l.weigh == nil {
+		return 1
+	}
+	return l.weigh(req)
+}
+
+// Wait blocks until weight is available or ctx is done.
+func (l *Limiter) Wait(ctx context.Context, weight int) error {
+	for {
+		l.m.Lock()
+		if l.limit <= 0 || l.interval <= 0 {
+			l.m.Unlock()
+			return nil
+		}
+		if weight > l.limit {
+			weight = l.limit
+		}
+		now := time.Now()
+		l.refill(now)
+		if l.tokens >= float64(weight) {
+			l.tokens -= float64(weight)
+			l.m.Unlock()
+			return nil
+		}
+		delay := time.Duration((float64(weight) - l.tokens) / float64(l.limit) * float64(l.interval))
+		l.m.Unlock()
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		case <-timer.C:
+		}
+	}
+}
+
+func (l *Limiter) refill(now time.Time) {
+	if l.limit <= 0 || l.interval <= 0 {
+		return
+	}
+	elapsed := now.Sub(l.updated)
+	l.updated = now
+	if elapsed <= 0 {
+		return
+	}
+	l.tokens = math.Min(float64(l.limit), l.tokens+float64(l.limit)*float64(elapsed)/float64(l.interval))
+}
+
+func (l *Limiter) observe(resp *http.Response) {
+	if resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode == http.StatusTeapot {
+		l.SetRemaining(0)
+		return
+	}
+	if l.usedHeader != "" {
+		if used, err := strconv.Atoi(resp.Header.Get(l.usedHeader)); err == nil {
+			l.SetUsed(used)
+			return
+		}
+	}
+	if l.remainingHeader != "" {
+		if remaining, err := strconv.Atoi(resp.Header.Get(l.remainingHeader)); err == nil {
+			l.SetRemaining(remaining)
+		}
+	}
+}
+
+// Transport waits on Limiter before every request and feeds the rate limit
+// headers of every response back into it.
+type Transport struct {
+	Limiter *Limiter
+	Base    http.RoundTripper
+}
+
+func (t *Transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if err := t.Limiter.Wait(req.Context(), t.Limiter.Weight(req)); err != nil {
+		return nil, err
+	}
+	base := t.Base
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	resp, err := base.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+	t.Limiter.observe(resp)
+	return resp, nil
+}
+
+// NewTransport wraps base with the limiter shared by every client of exchange.
+func NewTransport(exchange string, base http.RoundTripper) http.RoundTripper {
+	if t, ok := base.(*Transport); ok {
+		base = t.Base
+	}
+	return &Transport{Limiter: For(exchange), Base: base}
+}
+
+var (
+	limiters = make(map[string]*Limiter)
+	mtx      sync.Mutex
+)
+
+// For returns the limiter of exchange, shared by its public and private
+// clients. Exchanges without a documented budget are not limited.
+func For(exchange string) *Limiter {
+	mtx.Lock()
+	defer mtx.Unlock()
+	name := strings.ToLower(exchange)
+	l, ok := limiters[name]
+	if ok {
+		return l
+	}
+	p := profiles[name]
+	l = NewLimiter(p.limit, p.interval)
+	l.weigh = p.weigh
+	l.usedHeader = p.usedHeader
+	l.remainingHeader = p.remainingHeader
+	limiters[name] = l
+	return l
+}
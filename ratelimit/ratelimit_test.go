@@ -0,0 +1,58 @@
+package ratelimit
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+type FakeRoundTripper struct {
+	header http.Header
+}
+
+func (rt *FakeRoundTripper) RoundTrip(r *http.Request) (*http.Response, error) {
+	return &http.Response{
+		StatusCode: http.StatusOK,
+		Body:       ioutil.NopCloser(strings.NewReader("{}")),
+		Header:     rt.header,
+	}, nil
+}
+
+func TestLimiterWait(t *testing.T) {
+	l := NewLimiter(10, time.Hour)
+	if err := l.Wait(context.Background(), 4); err != nil {
+		t.Fatal(err)
+	}
+	if l.Remaining() != 6 {
+		t.Errorf("Limiter: Expected %v. Got %v", 6, l.Remaining())
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if err := l.Wait(ctx, 8); err != context.DeadlineExceeded {
+		t.Errorf("Limiter: Expected %v. Got %v", context.DeadlineExceeded, err)
+	}
+	l.SetLimit(0, 0)
+	if err := l.Wait(context.Background(), 100); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestTransportUsedWeight(t *testing.T) {
+	header := make(http.Header)
+	header.Set("X-MBX-USED-WEIGHT", "1150")
+	cli := &http.Client{Transport: NewTransport("binance", &FakeRoundTripper{header: header})}
+	res, err := cli.Get("http://localhost/api/v1/ticker/24hr")
+	if err != nil {
+		t.Fatal(err)
+	}
+	res.Body.Close()
+	if r := For("binance").Remaining(); r != 50 {
+		t.Errorf("Transport: Expected %v. Got %v", 50, r)
+	}
+	if For("BINANCE") != For("binance") {
+		t.Error("Transport: limiter is not shared")
+	}
+}
@@ -0,0 +1,302 @@
+package recorder
+
+import (
+	"bufio"
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/fxpgr/go-exchange-client/models"
+	"github.com/pkg/errors"
+)
+
+// A recording is a directory of segments named <seq>-<start>.jsonl.gz. Each
+// segment is a gzip compressed stream of JSON lines, a Header followed by
+// Records. Segments are only ever appended to, and a recording which is
+// resumed continues in a new segment.
+const (
+	Format  = "go-exchange-client/recorder"
+	Version = 1
+
+	segmentSuffix = ".jsonl.gz"
+	segmentTime   = "20060102T150405Z"
+)
+
+type Header struct {
+	Format  string    `json:"format"`
+	Version int       `json:"version"`
+	Created time.Time `json:"created"`
+}
+
+type Kind string
+
+const (
+	KindBoard   Kind = "board"
+	KindTicks   Kind = "ticks"
+	KindPairs   Kind = "pairs"
+	KindPrecise Kind = "precise"
+)
+
+// Record is a snapshot of one public endpoint. Trading and Settlement are set
+// for boards and precisions, and only the field of Kind is.
+type Record struct {
+	Time       time.Time                                  `json:"time"`
+	Exchange   string                                     `json:"exchange"`
+	Kind       Kind                                       `json:"kind"`
+	Trading    string                                     `json:"trading,omitempty"`
+	Settlement string                                     `json:"settlement,omitempty"`
+	Board      *models.Board                              `json:"board,omitempty"`
+	Ticks      map[string]map[string]models.OrderBookTick `json:"ticks,omitempty"`
+	Pairs      []models.CurrencyPair                      `json:"pairs,omitempty"`
+	Precisions *models.Precisions                         `json:"precisions,omitempty"`
+}
+
+type countingWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (c *countingWriter) Write(p []byte) (int, error) {
+	n, err := c.w.Write(p)
+	c.n += int64(n)
+	return n, err
+}
+
+// Writer appends records to a recording, starting a new segment once the
+// current one has grown past MaxSize compressed bytes or is older than
+// MaxAge. Zero disables either limit. Every record is flushed to the file as
+// it is written, so a crash loses at most the record being written.
+type Writer struct {
+	Dir     string
+	MaxSize int64
+	MaxAge  time.Duration
+
+	seq     int
+	file    *os.File
+	count   *countingWriter
+	gz      *gzip.Writer
+	enc     *json.Encoder
+	started time.Time
+	m       sync.Mutex
+}
+
+// NewWriter opens the recording in dir, creating it if needed, and resumes it
+// after its last segment.
+func NewWriter(dir string, maxSize int64, maxAge time.Duration) (*Writer, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, errors.Wrapf(err, "failed to create %s", dir)
+	}
+	segments, err := Segments(dir)
+	if err != nil {
+		return nil, err
+	}
+	w := &Writer{Dir: dir, MaxSize: maxSize, MaxAge: maxAge}
+	if len(segments) > 0 {
+		w.seq, _ = segmentSeq(segments[len(segments)-1])
+	}
+	return w, nil
+}
+
+func (w *Writer) Write(r *Record) error {
+	w.m.Lock()
+	defer w.m.Unlock()
+	if w.gz != nil && (w.MaxSize > 0 && w.count.n >= w.MaxSize || w.MaxAge > 0 && time.Since(w.started) >= w.MaxAge) {
+		if err := w.close(); err != nil {
+			return err
+		}
+	}
+	if w.gz == nil {
+		if err := w.open(); err != nil {
+			return err
+		}
+	}
+	if err := w.enc.Encode(r); err != nil {
+		return errors.Wrapf(err, "failed to write %s", w.file.Name())
+	}
+	if err := w.gz.Flush(); err != nil {
+		return errors.Wrapf(err, "failed to write %s", w.file.Name())
+	}
+	return nil
+}
+
+func (w *Writer) open() error {
+	w.seq++
+	w.started = time.Now().UTC()
+	name := filepath.Join(w.Dir, fmt.Sprintf("%08d-%s%s", w.seq, w.started.Format(segmentTime), segmentSuffix))
+	f, err := os.OpenFile(name, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+	if err != nil {
+		return errors.Wrapf(err, "failed to create %s", name)
+	}
+	w.file = f
+	w.count = &countingWriter{w: f}
+	w.gz = gzip.NewWriter(w.count)
+	w.enc = json.NewEncoder(w.gz)
+	return w.enc.Encode(&Header{Format: Format, Version: Version, Created: w.started})
+}
+
+func (w *Writer) close() error {
+	if w.gz == nil {
+		return nil
+	}
+	err := w.gz.Close()
+	if cerr := w.file.Close(); err == nil {
+		err = cerr
+	}
+	w.gz = nil
+	if err != nil {
+		return errors.Wrapf(err, "failed to close %s", w.file.Name())
+	}
+	return nil
+}
+
+func (w *Writer) Close() error {
+	w.m.Lock()
+	defer w.m.Unlock()
+	return w.close()
+}
+
+// Segments returns the segments of the recording in dir in the order they
+// were written.
+func Segments(dir string) ([]string, error) {
+	names, err := filepath.Glob(filepath.Join(dir, "*"+segmentSuffix))
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to list %s", dir)
+	}
+	var segments []string
+	for _, name := range names {
+		if _, ok := segmentSeq(name); ok {
+			segments = append(segments, name)
+		}
+	}
+	sort.Slice(segments, func(i, j int) bool {
+		a, _ := segmentSeq(segments[i])
+		b, _ := segmentSeq(segments[j])
+		return a < b
+	})
+	return segments, nil
+}
+
+func segmentSeq(name string) (int, bool) {
+	base := filepath.Base(name)
+	i := strings.IndexByte(base, '-')
+	if i < 0 {
+		return 0, false
+	}
+	seq, err := strconv.Atoi(base[:i])
+	return seq, err == nil
+}
+
+// Reader reads the records of a recording in the order they were written. A
+// segment cut short by a crash is read up to its last complete record, and
+// skipped when it is too short to hold a header.
+type Reader struct {
+	segments []string
+	file     *os.File
+	gz       *gzip.Reader
+	dec      *json.Decoder
+}
+
+func NewReader(dir string) (*Reader, error) {
+	segments, err := Segments(dir)
+	if err != nil {
+		return nil, err
+	}
+	return &Reader{segments: segments}, nil
+}
+
+// Next returns the next record, or io.EOF once every segment is read.
+func (r *Reader) Next() (*Record, error) {
+	for {
+		if r.dec == nil {
+			if len(r.segments) == 0 {
+				return nil, io.EOF
+			}
+			name := r.segments[0]
+			r.segments = r.segments[1:]
+			if err := r.open(name); err != nil {
+				return nil, err
+			}
+			if r.dec == nil {
+				continue
+			}
+		}
+		var rec Record
+		err := r.dec.Decode(&rec)
+		if err == nil {
+			fixBoard(rec.Board)
+			return &rec, nil
+		}
+		name := r.file.Name()
+		r.close()
+		if err != io.EOF && err != io.ErrUnexpectedEOF {
+			if _, ok := err.(*json.SyntaxError); !ok {
+				return nil, errors.Wrapf(err, "failed to read %s", name)
+			}
+		}
+	}
+}
+
+func (r *Reader) open(name string) error {
+	f, err := os.Open(name)
+	if err != nil {
+		return errors.Wrapf(err, "failed to open %s", name)
+	}
+	gz, err := gzip.NewReader(bufio.NewReader(f))
+	if err == io.EOF || err == io.ErrUnexpectedEOF {
+		f.Close()
+		return nil
+	}
+	if err != nil {
+		f.Close()
+		return errors.Wrapf(err, "failed to open %s", name)
+	}
+	r.file, r.gz, r.dec = f, gz, json.NewDecoder(gz)
+	var h Header
+	if err := r.dec.Decode(&h); err != nil {
+		r.close()
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			return nil
+		}
+		return errors.Wrapf(err, "failed to read header of %s", name)
+	}
+	if h.Format != Format || h.Version > Version {
+		r.close()
+		return errors.Errorf("%s is %s version %d, want %s up to version %d", name, h.Format, h.Version, Format, Version)
+	}
+	return nil
+}
+
+func (r *Reader) close() {
+	if r.file != nil {
+		r.gz.Close()
+		r.file.Close()
+	}
+	r.file, r.gz, r.dec = nil, nil, nil
+}
+
+func (r *Reader) Close() error {
+	r.close()
+	return nil
+}
+
+// fixBoard restores the side of the bars of a decoded board, which is not
+// serialised.
+func fixBoard(b *models.Board) {
+	if b == nil {
+		return
+	}
+	for i := range b.Asks {
+		b.Asks[i].Type = models.Ask
+	}
+	for i := range b.Bids {
+		b.Bids[i].Type = models.Bid
+	}
+}
@@ -0,0 +1,168 @@
+package recorder
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/fxpgr/go-exchange-client/api/public"
+	"github.com/fxpgr/go-exchange-client/logger"
+	"github.com/fxpgr/go-exchange-client/models"
+	"github.com/pkg/errors"
+)
+
+// Target is an exchange to record. Client defaults to public.NewClient of
+// Exchange.
+type Target struct {
+	Exchange string
+	Pairs    []models.CurrencyPair
+	Client   public.PublicClient
+}
+
+// Config of a Recorder. Boards and order book ticks are polled every
+// Interval, currency pairs and precisions every MetadataInterval.
+type Config struct {
+	Dir              string
+	Targets          []Target
+	Interval         time.Duration
+	MetadataInterval time.Duration
+	MaxFileSize      int64
+	MaxFileAge       time.Duration
+}
+
+var DefaultConfig = Config{
+	Dir:              "recordings",
+	Interval:         10 * time.Second,
+	MetadataInterval: time.Hour,
+	MaxFileSize:      64 << 20,
+	MaxFileAge:       24 * time.Hour,
+}
+
+// Recorder polls the public endpoints of its targets and appends every
+// snapshot to a recording.
+type Recorder struct {
+	config Config
+	writer *Writer
+}
+
+func NewRecorder(config Config) (*Recorder, error) {
+	if config.Interval <= 0 {
+		config.Interval = DefaultConfig.Interval
+	}
+	if config.MetadataInterval <= 0 {
+		config.MetadataInterval = DefaultConfig.MetadataInterval
+	}
+	if config.Dir == "" {
+		config.Dir = DefaultConfig.Dir
+	}
+	targets := make([]Target, 0, len(config.Targets))
+	for _, t := range config.Targets {
+		if t.Client == nil {
+			client, err := public.NewClient(t.Exchange)
+			if err != nil {
+				return nil, errors.Wrapf(err, "failed to initialize %s client", t.Exchange)
+			}
+			t.Client = client
+		}
+		targets = append(targets, t)
+	}
+	config.Targets = targets
+	w, err := NewWriter(config.Dir, config.MaxFileSize, config.MaxFileAge)
+	if err != nil {
+		return nil, err
+	}
+	return &Recorder{config: config, writer: w}, nil
+}
+
+// Run records until ctx is done, then closes the recording. Failed polls are
+// logged and retried at the next interval, while a failed write stops the
+// recorder.
+func (r *Recorder) Run(ctx context.Context) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	errs := make(chan error, len(r.config.Targets))
+	var wg sync.WaitGroup
+	for _, t := range r.config.Targets {
+		wg.Add(1)
+		go func(t Target) {
+			defer wg.Done()
+			if err := r.record(ctx, t); err != nil {
+				errs <- err
+				cancel()
+			}
+		}(t)
+	}
+	wg.Wait()
+	close(errs)
+	err := <-errs
+	if cerr := r.writer.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
+
+func (r *Recorder) record(ctx context.Context, t Target) error {
+	client := t.Client.WithContext(ctx)
+	ticker := time.NewTicker(r.config.Interval)
+	defer ticker.Stop()
+	var metadataAt time.Time
+	for {
+		if time.Since(metadataAt) >= r.config.MetadataInterval {
+			if err := r.pollMetadata(ctx, client, t); err != nil {
+				return err
+			}
+			metadataAt = time.Now()
+		}
+		if err := r.poll(ctx, client, t); err != nil {
+			return err
+		}
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+func (r *Recorder) poll(ctx context.Context, client public.PublicClient, t Target) error {
+	ticks, err := client.OrderBookTickMap()
+	if err := r.write(ctx, t, err, &Record{Kind: KindTicks, Ticks: ticks}); err != nil {
+		return err
+	}
+	for _, p := range t.Pairs {
+		board, err := client.Board(p.Trading, p.Settlement)
+		if err := r.write(ctx, t, err, &Record{Kind: KindBoard, Trading: p.Trading, Settlement: p.Settlement, Board: board}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (r *Recorder) pollMetadata(ctx context.Context, client public.PublicClient, t Target) error {
+	pairs, err := client.CurrencyPairs()
+	if err := r.write(ctx, t, err, &Record{Kind: KindPairs, Pairs: pairs}); err != nil {
+		return err
+	}
+	for _, p := range t.Pairs {
+		precisions, err := client.Precise(p.Trading, p.Settlement)
+		if err := r.write(ctx, t, err, &Record{Kind: KindPrecise, Trading: p.Trading, Settlement: p.Settlement, Precisions: precisions}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// write records rec unless the poll which produced it failed with pollErr,
+// which is only logged.
+func (r *Recorder) write(ctx context.Context, t Target, pollErr error, rec *Record) error {
+	if pollErr != nil {
+		if ctx.Err() == nil {
+			logger.Get().Warnw("failed to poll", "exchange", t.Exchange, "kind", rec.Kind,
+				"trading", rec.Trading, "settlement", rec.Settlement, "error", pollErr)
+		}
+		return nil
+	}
+	rec.Time = time.Now().UTC()
+	rec.Exchange = t.Exchange
+	return r.writer.Write(rec)
+}
@@ -0,0 +1,115 @@
+package recorder
+
+import (
+	"context"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/fxpgr/go-exchange-client/api/public/mocks"
+	"github.com/fxpgr/go-exchange-client/decimal"
+	"github.com/fxpgr/go-exchange-client/models"
+	"github.com/stretchr/testify/mock"
+)
+
+var d = decimal.RequireFromString
+
+func readAll(t *testing.T, dir string) []*Record {
+	r, err := NewReader(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+	var records []*Record
+	for {
+		rec, err := r.Next()
+		if err == io.EOF {
+			return records
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		records = append(records, rec)
+	}
+}
+
+func TestWriterRotateResume(t *testing.T) {
+	dir, err := ioutil.TempDir("", "recorder")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	w, err := NewWriter(dir, 1, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 3; i++ {
+		if err := w.Write(&Record{Exchange: "binance", Kind: KindPairs}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	w.Close()
+	// a crashed writer leaves its segment without a gzip trailer
+	w, _ = NewWriter(dir, 0, 0)
+	w.Write(&Record{Exchange: "huobi", Kind: KindPairs})
+	w.file.Close()
+	ioutil.WriteFile(filepath.Join(dir, "00000005-20190101T000000Z.jsonl.gz"), nil, 0644)
+
+	segments, _ := Segments(dir)
+	if len(segments) != 5 {
+		t.Errorf("Writer: Expected %v segments. Got %v", 5, len(segments))
+	}
+	records := readAll(t, dir)
+	if len(records) != 4 || records[3].Exchange != "huobi" {
+		t.Errorf("Reader: unexpected records %+v", records)
+	}
+}
+
+func TestRecorderRun(t *testing.T) {
+	dir, err := ioutil.TempDir("", "recorder")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	pair := models.CurrencyPair{Trading: "ETH", Settlement: "BTC"}
+	board := &models.Board{
+		Asks: []models.BoardBar{{Type: models.Ask, Price: d("0.031"), Amount: d("1")}},
+		Bids: []models.BoardBar{{Type: models.Bid, Price: d("0.03"), Amount: d("2")}},
+	}
+	pub := new(mocks.PublicClient)
+	pub.On("WithContext", mock.Anything).Return(pub)
+	pub.On("CurrencyPairs").Return([]models.CurrencyPair{pair}, nil)
+	pub.On("Precise", "ETH", "BTC").Return(&models.Precisions{PricePrecision: 6, AmountPrecision: 3}, nil)
+	pub.On("OrderBookTickMap").Return(map[string]map[string]models.OrderBookTick{"ETH": {"BTC": {BestAskPrice: d("0.031")}}}, nil)
+	pub.On("Board", "ETH", "BTC").Return(board, nil)
+
+	r, err := NewRecorder(Config{
+		Dir:      dir,
+		Targets:  []Target{{Exchange: "binance", Pairs: []models.CurrencyPair{pair}, Client: pub}},
+		Interval: 10 * time.Millisecond,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 35*time.Millisecond)
+	defer cancel()
+	if err := r.Run(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	kinds := make(map[Kind]int)
+	for _, rec := range readAll(t, dir) {
+		kinds[rec.Kind]++
+		if rec.Kind == KindBoard && rec.Board.Bids[0].Type != models.Bid {
+			t.Errorf("Reader: bids are not restored")
+		}
+	}
+	if kinds[KindPairs] != 1 || kinds[KindPrecise] != 1 || kinds[KindBoard] < 2 || kinds[KindTicks] != kinds[KindBoard] {
+		t.Errorf("Recorder: unexpected records %v", kinds)
+	}
+}
@@ -0,0 +1,247 @@
+package replay
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/fxpgr/go-exchange-client/api/public"
+	"github.com/fxpgr/go-exchange-client/apierrors"
+	"github.com/fxpgr/go-exchange-client/models"
+	"github.com/fxpgr/go-exchange-client/recorder"
+	"github.com/pkg/errors"
+)
+
+// ErrNoData is returned for what was not recorded by the simulated time.
+var ErrNoData = errors.New("no data recorded")
+
+// Clock is a virtual clock which only moves when it is told to.
+type Clock struct {
+	now time.Time
+	m   sync.Mutex
+}
+
+func NewClock(t time.Time) *Clock {
+	return &Clock{now: t}
+}
+
+func (c *Clock) Now() time.Time {
+	c.m.Lock()
+	defer c.m.Unlock()
+	return c.now
+}
+
+func (c *Clock) Set(t time.Time) {
+	c.m.Lock()
+	defer c.m.Unlock()
+	c.now = t
+}
+
+func (c *Clock) Advance(d time.Duration) {
+	c.m.Lock()
+	defer c.m.Unlock()
+	c.now = c.now.Add(d)
+}
+
+type seriesKey struct {
+	exchange   string
+	kind       recorder.Kind
+	trading    string
+	settlement string
+}
+
+// series holds the records of one endpoint, oldest first.
+type series []*recorder.Record
+
+// at returns the latest record at or before t.
+func (s series) at(t time.Time) *recorder.Record {
+	i := sort.Search(len(s), func(i int) bool {
+		return s[i].Time.After(t)
+	})
+	if i == 0 {
+		return nil
+	}
+	return s[i-1]
+}
+
+// Recording is a recording loaded into memory and indexed by endpoint.
+type Recording struct {
+	series map[seriesKey]series
+	times  []time.Time
+}
+
+// Load reads the recording in dir.
+func Load(dir string) (*Recording, error) {
+	r, err := recorder.NewReader(dir)
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+	rec := &Recording{series: make(map[seriesKey]series)}
+	for {
+		v, err := r.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		rec.Add(v)
+	}
+	return rec, nil
+}
+
+// Add indexes v, for recordings assembled in memory. It must not be called
+// while the recording is replayed.
+func (r *Recording) Add(v *recorder.Record) {
+	if r.series == nil {
+		r.series = make(map[seriesKey]series)
+	}
+	k := seriesKey{exchange: strings.ToLower(v.Exchange), kind: v.Kind}
+	if v.Kind == recorder.KindBoard || v.Kind == recorder.KindPrecise {
+		k.trading, k.settlement = v.Trading, v.Settlement
+	}
+	// records written concurrently for several exchanges may be slightly
+	// out of order, so they are moved back into place
+	s := append(r.series[k], v)
+	for i := len(s) - 1; i > 0 && s[i].Time.Before(s[i-1].Time); i-- {
+		s[i], s[i-1] = s[i-1], s[i]
+	}
+	r.series[k] = s
+
+	i := sort.Search(len(r.times), func(i int) bool {
+		return !r.times[i].Before(v.Time)
+	})
+	if i < len(r.times) && r.times[i].Equal(v.Time) {
+		return
+	}
+	r.times = append(r.times, time.Time{})
+	copy(r.times[i+1:], r.times[i:])
+	r.times[i] = v.Time
+}
+
+// Times returns the times of the snapshots in the recording, oldest first.
+func (r *Recording) Times() []time.Time {
+	return r.times
+}
+
+func (r *Recording) Start() time.Time {
+	if len(r.times) == 0 {
+		return time.Time{}
+	}
+	return r.times[0]
+}
+
+func (r *Recording) End() time.Time {
+	if len(r.times) == 0 {
+		return time.Time{}
+	}
+	return r.times[len(r.times)-1]
+}
+
+// ReplayApi is a PublicClient serving the snapshots of Exchange in a
+// recording as they were at the time of Clock. Snapshots older than
+// MaxStaleness are treated as missing unless it is zero.
+type ReplayApi struct {
+	Exchange     string
+	Clock        *Clock
+	MaxStaleness time.Duration
+
+	recording *Recording
+}
+
+func NewReplayApi(recording *Recording, exchange string, clock *Clock) *ReplayApi {
+	return &ReplayApi{Exchange: exchange, Clock: clock, recording: recording}
+}
+
+func (h *ReplayApi) record(kind recorder.Kind, trading string, settlement string) (*recorder.Record, error) {
+	k := seriesKey{exchange: strings.ToLower(h.Exchange), kind: kind, trading: trading, settlement: settlement}
+	now := h.Clock.Now()
+	v := h.recording.series[k].at(now)
+	if v == nil || h.MaxStaleness > 0 && now.Sub(v.Time) > h.MaxStaleness {
+		if trading != "" {
+			return nil, errors.Wrapf(ErrNoData, "%s %s/%s on %s at %s", kind, trading, settlement, h.Exchange, now)
+		}
+		return nil, errors.Wrapf(ErrNoData, "%s on %s at %s", kind, h.Exchange, now)
+	}
+	return v, nil
+}
+
+// Board returns a copy of the recorded board, which callers may sort.
+func (h *ReplayApi) Board(trading string, settlement string) (*models.Board, error) {
+	v, err := h.record(recorder.KindBoard, trading, settlement)
+	if err != nil {
+		return nil, err
+	}
+	return &models.Board{
+		Asks: append([]models.BoardBar(nil), v.Board.Asks...),
+		Bids: append([]models.BoardBar(nil), v.Board.Bids...),
+	}, nil
+}
+
+func (h *ReplayApi) OrderBookTickMap() (map[string]map[string]models.OrderBookTick, error) {
+	v, err := h.record(recorder.KindTicks, "", "")
+	if err != nil {
+		return nil, err
+	}
+	m := make(map[string]map[string]models.OrderBookTick)
+	for trading, ticks := range v.Ticks {
+		m[trading] = make(map[string]models.OrderBookTick)
+		for settlement, tick := range ticks {
+			m[trading][settlement] = tick
+		}
+	}
+	return m, nil
+}
+
+func (h *ReplayApi) CurrencyPairs() ([]models.CurrencyPair, error) {
+	v, err := h.record(recorder.KindPairs, "", "")
+	if err != nil {
+		return nil, err
+	}
+	return append([]models.CurrencyPair(nil), v.Pairs...), nil
+}
+
+func (h *ReplayApi) Precise(trading string, settlement string) (*models.Precisions, error) {
+	v, err := h.record(recorder.KindPrecise, trading, settlement)
+	if err != nil {
+		return nil, err
+	}
+	p := *v.Precisions
+	return &p, nil
+}
+
+// Rules are not recorded, so they are derived from the recorded precisions.
+func (h *ReplayApi) Rules(trading string, settlement string) (*models.MarketRules, error) {
+	p, err := h.Precise(trading, settlement)
+	if err != nil {
+		return nil, err
+	}
+	rules := models.NewMarketRules(*p)
+	return &rules, nil
+}
+
+// FrozenCurrency is not recorded, so no currency is ever frozen.
+func (h *ReplayApi) FrozenCurrency() ([]string, error) {
+	return []string{}, nil
+}
+
+func (h *ReplayApi) Candles(trading string, settlement string, interval models.Interval, since time.Time, limit int) ([]models.Candle, error) {
+	return nil, errors.Wrap(apierrors.ErrUnsupported, "candles are not recorded")
+}
+
+func (h *ReplayApi) RecentTrades(trading string, settlement string, limit int) ([]models.PublicTrade, error) {
+	return nil, errors.Wrap(apierrors.ErrUnsupported, "trades are not recorded")
+}
+
+func (h *ReplayApi) SetTransport(transport http.RoundTripper) error {
+	return nil
+}
+
+func (h *ReplayApi) WithContext(ctx context.Context) public.PublicClient {
+	return h
+}
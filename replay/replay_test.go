@@ -0,0 +1,72 @@
+package replay
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/fxpgr/go-exchange-client/api/public"
+	"github.com/fxpgr/go-exchange-client/decimal"
+	"github.com/fxpgr/go-exchange-client/models"
+	"github.com/fxpgr/go-exchange-client/recorder"
+	"github.com/pkg/errors"
+)
+
+var _ public.PublicClient = (*ReplayApi)(nil)
+
+func TestReplayBoard(t *testing.T) {
+	dir, err := ioutil.TempDir("", "replay")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	start := time.Date(2019, 1, 1, 0, 0, 0, 0, time.UTC)
+	w, err := recorder.NewWriter(dir, 0, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, price := range []string{"0.031", "0.032"} {
+		w.Write(&recorder.Record{
+			Time:       start.Add(time.Duration(i) * 10 * time.Second),
+			Exchange:   "binance",
+			Kind:       recorder.KindBoard,
+			Trading:    "ETH",
+			Settlement: "BTC",
+			Board:      &models.Board{Asks: []models.BoardBar{{Price: decimal.RequireFromString(price), Amount: decimal.NewFromInt(1)}}},
+		})
+	}
+	w.Close()
+
+	recording, err := Load(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !recording.Start().Equal(start) || len(recording.Times()) != 2 {
+		t.Errorf("Recording: unexpected times %v", recording.Times())
+	}
+	clock := NewClock(start.Add(-time.Second))
+	client := NewReplayApi(recording, "Binance", clock)
+	if _, err := client.Board("ETH", "BTC"); errors.Cause(err) != ErrNoData {
+		t.Errorf("ReplayApi: Expected %v. Got %v", ErrNoData, err)
+	}
+	clock.Advance(5 * time.Second)
+	board, err := client.Board("ETH", "BTC")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if board.BestAskPrice().String() != "0.031" || board.Asks[0].Type != models.Ask {
+		t.Errorf("ReplayApi: Expected %v. Got %+v", 0.031, board)
+	}
+	clock.Set(recording.End())
+	board, _ = client.Board("ETH", "BTC")
+	if board.BestAskPrice().String() != "0.032" {
+		t.Errorf("ReplayApi: Expected %v. Got %+v", 0.032, board)
+	}
+	client.MaxStaleness = time.Second
+	clock.Advance(time.Minute)
+	if _, err := client.Board("ETH", "BTC"); errors.Cause(err) != ErrNoData {
+		t.Errorf("ReplayApi: Expected %v. Got %v", ErrNoData, err)
+	}
+}
@@ -0,0 +1,190 @@
+package retry
+
+import (
+	"context"
+	"errors"
+	"io"
+	"io/ioutil"
+	"math"
+	"math/rand"
+	"net"
+	"net/http"
+	"strconv"
+	"syscall"
+	"time"
+)
+
+// Policy describes how failed idempotent requests are retried. Delays grow
+// exponentially from BaseDelay up to MaxDelay, and Jitter is the fraction of
+// each delay which is randomised away.
+type Policy struct {
+	MaxAttempts int
+	BaseDelay   time.Duration
+	MaxDelay    time.Duration
+	Jitter      float64
+}
+
+var DefaultPolicy = Policy{
+	MaxAttempts: 3,
+	BaseDelay:   200 * time.Millisecond,
+	MaxDelay:    5 * time.Second,
+	Jitter:      0.2,
+}
+
+func (p Policy) backoff(attempt int) time.Duration {
+	d := math.Min(float64(p.BaseDelay)*math.Pow(2, float64(attempt)), float64(p.MaxDelay))
+	d -= d * p.Jitter * rand.Float64()
+	return time.Duration(d)
+}
+
+type contextKey int
+
+const (
+	policyKey contextKey = iota
+	idempotentKey
+	clientOrderIDKey
+)
+
+// WithPolicy overrides the policy for the requests bound to the returned context.
+func WithPolicy(ctx context.Context, p Policy) context.Context {
+	return context.WithValue(ctx, policyKey, p)
+}
+
+// Idempotent marks the requests bound to the returned context as safe to
+// retry even though their method is not GET or HEAD.
+func Idempotent(ctx context.Context) context.Context {
+	return context.WithValue(ctx, idempotentKey, true)
+}
+
+// WithClientOrderID opts an order in to retries. Adapters which can send a
+// client order ID attach it to the order and mark the request Idempotent, so
+// the exchange deduplicates a retried order instead of placing it twice.
+func WithClientOrderID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, clientOrderIDKey, id)
+}
+
+func ClientOrderID(ctx context.Context) string {
+	id, _ := ctx.Value(clientOrderIDKey).(string)
+	return id
+}
+
+func isIdempotent(req *http.Request) bool {
+	if req.Method == "GET" || req.Method == "HEAD" {
+		return true
+	}
+	ok, _ := req.Context().Value(idempotentKey).(bool)
+	return ok
+}
+
+// Transport retries idempotent requests which failed with a transient network
+// error, a 5xx or a 429 response.
+type Transport struct {
+	Policy *Policy
+	Base   http.RoundTripper
+}
+
+func NewTransport(base http.RoundTripper) http.RoundTripper {
+	if t, ok := base.(*Transport); ok {
+		return t
+	}
+	return &Transport{Base: base}
+}
+
+func (t *Transport) policy(ctx context.Context) Policy {
+	if p, ok := ctx.Value(policyKey).(Policy); ok {
+		return p
+	}
+	if t.Policy != nil {
+		return *t.Policy
+	}
+	return DefaultPolicy
+}
+
+// Do retries like Transport, but calls send to build and send a new request
+// for every attempt. It is for requests signed over a nonce, which cannot be
+// sent twice. The policy is the one of ctx, or DefaultPolicy.
+func Do(ctx context.Context, send func() (*http.Response, error)) (*http.Response, error) {
+	p, ok := ctx.Value(policyKey).(Policy)
+	if !ok {
+		p = DefaultPolicy
+	}
+	return do(ctx, p, func(int) (*http.Response, error) { return send() })
+}
+
+func (t *Transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	base := t.Base
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	if !isIdempotent(req) || (req.Body != nil && req.GetBody == nil) {
+		return base.RoundTrip(req)
+	}
+	ctx := req.Context()
+	return do(ctx, t.policy(ctx), func(attempt int) (*http.Response, error) {
+		r := req
+		if attempt > 0 && req.GetBody != nil {
+			body, err := req.GetBody()
+			if err != nil {
+				return nil, err
+			}
+			r = req.Clone(ctx)
+			r.Body = body
+		}
+		return base.RoundTrip(r)
+	})
+}
+
+func do(ctx context.Context, p Policy, send func(attempt int) (*http.Response, error)) (*http.Response, error) {
+	for attempt := 0; ; attempt++ {
+		resp, err := send(attempt)
+		if attempt+1 >= p.MaxAttempts || !retryable(resp, err) {
+			return resp, err
+		}
+		delay := p.backoff(attempt)
+		if resp != nil {
+			if after, ok := retryAfter(resp); ok {
+				if after > p.MaxDelay {
+					return resp, err
+				}
+				delay = after
+			}
+			io.Copy(ioutil.Discard, resp.Body)
+			resp.Body.Close()
+		}
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return nil, ctx.Err()
+		case <-timer.C:
+		}
+	}
+}
+
+func retryable(resp *http.Response, err error) bool {
+	if err != nil {
+		if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+			return false
+		}
+		var netErr net.Error
+		if errors.As(err, &netErr) && netErr.Timeout() {
+			return true
+		}
+		return errors.Is(err, syscall.ECONNRESET) || errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF)
+	}
+	return resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500
+}
+
+func retryAfter(resp *http.Response) (time.Duration, bool) {
+	v := resp.Header.Get("Retry-After")
+	if v == "" {
+		return 0, false
+	}
+	if seconds, err := strconv.Atoi(v); err == nil {
+		return time.Duration(seconds) * time.Second, true
+	}
+	if at, err := http.ParseTime(v); err == nil {
+		return time.Until(at), true
+	}
+	return 0, false
+}
@@ -0,0 +1,95 @@
+package retry
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+type FakeRoundTripper struct {
+	statuses []int
+	header   map[string]string
+	requests []*http.Request
+}
+
+func (rt *FakeRoundTripper) RoundTrip(r *http.Request) (*http.Response, error) {
+	status := http.StatusOK
+	if len(rt.requests) < len(rt.statuses) {
+		status = rt.statuses[len(rt.requests)]
+	}
+	rt.requests = append(rt.requests, r)
+	res := &http.Response{
+		StatusCode: status,
+		Body:       ioutil.NopCloser(strings.NewReader("{}")),
+		Header:     make(http.Header),
+	}
+	for k, v := range rt.header {
+		res.Header.Set(k, v)
+	}
+	return res, nil
+}
+
+var testPolicy = &Policy{MaxAttempts: 3, BaseDelay: time.Millisecond, MaxDelay: 100 * time.Millisecond}
+
+func TestTransportRetriesIdempotent(t *testing.T) {
+	rt := &FakeRoundTripper{statuses: []int{http.StatusBadGateway, http.StatusServiceUnavailable}}
+	cli := &http.Client{Transport: &Transport{Policy: testPolicy, Base: rt}}
+	res, err := cli.Get("http://localhost/board")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.StatusCode != http.StatusOK || len(rt.requests) != 3 {
+		t.Errorf("Transport: Expected %v attempts. Got %v", 3, len(rt.requests))
+	}
+
+	rt = &FakeRoundTripper{statuses: []int{http.StatusBadGateway}}
+	cli = &http.Client{Transport: &Transport{Policy: testPolicy, Base: rt}}
+	res, err = cli.Post("http://localhost/order", "application/json", strings.NewReader("{}"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.StatusCode != http.StatusBadGateway || len(rt.requests) != 1 {
+		t.Errorf("Transport: POST must not be retried. Got %v attempts", len(rt.requests))
+	}
+
+	rt = &FakeRoundTripper{statuses: []int{http.StatusBadGateway}}
+	cli = &http.Client{Transport: &Transport{Policy: testPolicy, Base: rt}}
+	req, _ := http.NewRequest("POST", "http://localhost/order", strings.NewReader("{}"))
+	res, err = cli.Do(req.WithContext(Idempotent(context.Background())))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.StatusCode != http.StatusOK || len(rt.requests) != 2 {
+		t.Errorf("Transport: Expected %v attempts. Got %v", 2, len(rt.requests))
+	}
+}
+
+func TestTransportRetryAfter(t *testing.T) {
+	rt := &FakeRoundTripper{
+		statuses: []int{http.StatusTooManyRequests},
+		header:   map[string]string{"Retry-After": "1"},
+	}
+	cli := &http.Client{Transport: &Transport{Policy: testPolicy, Base: rt}}
+	res, err := cli.Get("http://localhost/board")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.StatusCode != http.StatusTooManyRequests || len(rt.requests) != 1 {
+		t.Errorf("Transport: Retry-After beyond MaxDelay must not be retried. Got %v attempts", len(rt.requests))
+	}
+
+	rt.requests = nil
+	p := Policy{MaxAttempts: 2, BaseDelay: time.Millisecond, MaxDelay: 2 * time.Second}
+	start := time.Now()
+	req, _ := http.NewRequest("GET", "http://localhost/board", nil)
+	res, err = cli.Do(req.WithContext(WithPolicy(context.Background(), p)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.StatusCode != http.StatusOK || time.Since(start) < time.Second {
+		t.Errorf("Transport: Retry-After is not honoured")
+	}
+}
@@ -0,0 +1,85 @@
+package stream
+
+import (
+	"sort"
+
+	"github.com/fxpgr/go-exchange-client/decimal"
+	"github.com/fxpgr/go-exchange-client/models"
+)
+
+// Book is an order book maintained from the levels an exchange pushes.
+type Book struct {
+	asks map[string]models.BoardBar
+	bids map[string]models.BoardBar
+}
+
+func NewBook() *Book {
+	return &Book{
+		asks: make(map[string]models.BoardBar),
+		bids: make(map[string]models.BoardBar),
+	}
+}
+
+// Reset empties the book.
+func (b *Book) Reset() {
+	b.asks = make(map[string]models.BoardBar)
+	b.bids = make(map[string]models.BoardBar)
+}
+
+// Load replaces the book with board.
+func (b *Book) Load(board *models.Board) {
+	b.Reset()
+	for _, bar := range board.Asks {
+		b.Set(models.Ask, bar.Price, bar.Amount)
+	}
+	for _, bar := range board.Bids {
+		b.Set(models.Bid, bar.Price, bar.Amount)
+	}
+}
+
+// Set sets the amount of a level, removing it when amount is zero.
+func (b *Book) Set(side models.OrderType, price decimal.Decimal, amount decimal.Decimal) {
+	levels := b.asks
+	if side == models.Bid {
+		levels = b.bids
+	}
+	key := price.String()
+	if !amount.IsPositive() {
+		delete(levels, key)
+		return
+	}
+	levels[key] = models.BoardBar{Type: side, Price: price, Amount: amount}
+}
+
+// Board returns a copy of the book, asks ascending and bids descending.
+func (b *Book) Board() *models.Board {
+	board := &models.Board{
+		Asks: make([]models.BoardBar, 0, len(b.asks)),
+		Bids: make([]models.BoardBar, 0, len(b.bids)),
+	}
+	for _, bar := range b.asks {
+		board.Asks = append(board.Asks, bar)
+	}
+	for _, bar := range b.bids {
+		board.Bids = append(board.Bids, bar)
+	}
+	sort.Slice(board.Asks, func(i, j int) bool { return board.Asks[i].Price.LessThan(board.Asks[j].Price) })
+	sort.Slice(board.Bids, func(i, j int) bool { return board.Bids[i].Price.GreaterThan(board.Bids[j].Price) })
+	return board
+}
+
+// Tick returns the best levels of the book.
+func (b *Book) Tick() models.OrderBookTick {
+	var tick models.OrderBookTick
+	for _, bar := range b.asks {
+		if tick.BestAskPrice.IsZero() || bar.Price.LessThan(tick.BestAskPrice) {
+			tick.BestAskPrice, tick.BestAskAmount = bar.Price, bar.Amount
+		}
+	}
+	for _, bar := range b.bids {
+		if bar.Price.GreaterThan(tick.BestBidPrice) {
+			tick.BestBidPrice, tick.BestBidAmount = bar.Price, bar.Amount
+		}
+	}
+	return tick
+}
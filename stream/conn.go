@@ -0,0 +1,228 @@
+// Package stream keeps WebSocket connections to exchanges open, redialing
+// them when they drop, and maintains the order books pushed over them.
+package stream
+
+import (
+	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"context"
+	"io/ioutil"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/fxpgr/go-exchange-client/logger"
+	"github.com/gorilla/websocket"
+	"github.com/pkg/errors"
+)
+
+// ErrNotConnected is returned by WriteText and WriteJSON while the connection
+// is down. What OnConnect sends is sent again once it is up.
+var ErrNotConnected = errors.New("stream not connected")
+
+// Decoder turns a frame received into the message passed to Handler.
+type Decoder func(messageType int, data []byte) ([]byte, error)
+
+// Gunzip decodes the gzip compressed binary frames of Huobi.
+func Gunzip(messageType int, data []byte) ([]byte, error) {
+	if messageType != websocket.BinaryMessage {
+		return data, nil
+	}
+	r, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to gunzip message")
+	}
+	defer r.Close()
+	return ioutil.ReadAll(r)
+}
+
+// Inflate decodes the raw deflate compressed binary frames of OKEx.
+func Inflate(messageType int, data []byte) ([]byte, error) {
+	if messageType != websocket.BinaryMessage {
+		return data, nil
+	}
+	r := flate.NewReader(bytes.NewReader(data))
+	defer r.Close()
+	msg, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to inflate message")
+	}
+	return msg, nil
+}
+
+// Conn is a WebSocket connection which is redialed with an exponential
+// backoff whenever it drops, fails to answer for ReadTimeout or its Handler
+// fails. OnConnect runs after every dial, before any message is handled, to
+// subscribe and authenticate again. Prepare runs before every dial, to fetch
+// the URL of streams authenticated by a token in it.
+type Conn struct {
+	URL       string
+	Header    http.Header
+	Dialer    *websocket.Dialer
+	Prepare   func(c *Conn) error
+	OnConnect func(c *Conn) error
+	Handler   func(msg []byte) error
+	Decode    Decoder
+
+	// Ping is sent every PingInterval, a ping control frame when nil.
+	Ping         func(c *Conn) error
+	PingInterval time.Duration
+	ReadTimeout  time.Duration
+	MinBackoff   time.Duration
+	MaxBackoff   time.Duration
+
+	conn *websocket.Conn
+	m    sync.Mutex
+}
+
+func NewConn(url string, handler func(msg []byte) error) *Conn {
+	return &Conn{
+		URL:          url,
+		Dialer:       websocket.DefaultDialer,
+		Handler:      handler,
+		PingInterval: 20 * time.Second,
+		ReadTimeout:  time.Minute,
+		MinBackoff:   time.Second,
+		MaxBackoff:   time.Minute,
+	}
+}
+
+// Run keeps the connection open until ctx is done.
+func (c *Conn) Run(ctx context.Context) error {
+	backoff := c.MinBackoff
+	for {
+		started := time.Now()
+		err := c.session(ctx)
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		if time.Since(started) > c.MaxBackoff {
+			backoff = c.MinBackoff
+		}
+		logger.Get().Warnw("stream disconnected", "url", c.URL, "error", err, "retry", backoff)
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(backoff):
+		}
+		if backoff *= 2; backoff > c.MaxBackoff {
+			backoff = c.MaxBackoff
+		}
+	}
+}
+
+func (c *Conn) session(ctx context.Context) error {
+	if c.Prepare != nil {
+		if err := c.Prepare(c); err != nil {
+			return errors.Wrap(err, "failed to prepare stream")
+		}
+	}
+	conn, _, err := c.Dialer.DialContext(ctx, c.URL, c.Header)
+	if err != nil {
+		return errors.Wrapf(err, "failed to dial %s", c.URL)
+	}
+	defer conn.Close()
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		select {
+		case <-ctx.Done():
+			conn.Close()
+		case <-done:
+		}
+	}()
+	c.m.Lock()
+	c.conn = conn
+	c.m.Unlock()
+	defer func() {
+		c.m.Lock()
+		c.conn = nil
+		c.m.Unlock()
+	}()
+
+	conn.SetReadDeadline(time.Now().Add(c.ReadTimeout))
+	conn.SetPongHandler(func(string) error {
+		return conn.SetReadDeadline(time.Now().Add(c.ReadTimeout))
+	})
+	if c.OnConnect != nil {
+		if err := c.OnConnect(c); err != nil {
+			return errors.Wrap(err, "failed to set up stream")
+		}
+	}
+	go c.keepalive(done, c.URL)
+	for {
+		messageType, data, err := conn.ReadMessage()
+		if err != nil {
+			return errors.Wrap(err, "failed to read message")
+		}
+		conn.SetReadDeadline(time.Now().Add(c.ReadTimeout))
+		if c.Decode != nil {
+			if data, err = c.Decode(messageType, data); err != nil {
+				return err
+			}
+		}
+		if err := c.Handler(data); err != nil {
+			return err
+		}
+	}
+}
+
+func (c *Conn) keepalive(done chan struct{}, url string) {
+	if c.PingInterval <= 0 {
+		return
+	}
+	ticker := time.NewTicker(c.PingInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-done:
+			return
+		case <-ticker.C:
+		}
+		var err error
+		if c.Ping != nil {
+			err = c.Ping(c)
+		} else {
+			err = c.write(websocket.PingMessage, nil)
+		}
+		if err != nil && err != ErrNotConnected {
+			logger.Get().Warnw("failed to ping stream", "url", url, "error", err)
+		}
+	}
+}
+
+func (c *Conn) write(messageType int, data []byte) error {
+	c.m.Lock()
+	defer c.m.Unlock()
+	if c.conn == nil {
+		return ErrNotConnected
+	}
+	c.conn.SetWriteDeadline(time.Now().Add(10 * time.Second))
+	return c.conn.WriteMessage(messageType, data)
+}
+
+// WriteText sends msg as a text frame.
+func (c *Conn) WriteText(msg []byte) error {
+	return c.write(websocket.TextMessage, msg)
+}
+
+// WriteJSON sends v encoded as JSON in a text frame.
+func (c *Conn) WriteJSON(v interface{}) error {
+	c.m.Lock()
+	defer c.m.Unlock()
+	if c.conn == nil {
+		return ErrNotConnected
+	}
+	c.conn.SetWriteDeadline(time.Now().Add(10 * time.Second))
+	return c.conn.WriteJSON(v)
+}
+
+// Reconnect drops the connection, so it is redialed and set up again.
+func (c *Conn) Reconnect() {
+	c.m.Lock()
+	defer c.m.Unlock()
+	if c.conn != nil {
+		c.conn.Close()
+	}
+}
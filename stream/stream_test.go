@@ -0,0 +1,77 @@
+package stream
+
+import (
+	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"testing"
+
+	"github.com/fxpgr/go-exchange-client/decimal"
+	"github.com/fxpgr/go-exchange-client/models"
+	"github.com/gorilla/websocket"
+)
+
+func TestBook(t *testing.T) {
+	d := decimal.RequireFromString
+	b := NewBook()
+	b.Load(&models.Board{
+		Asks: []models.BoardBar{{Price: d("101"), Amount: d("1")}, {Price: d("100.5"), Amount: d("2")}},
+		Bids: []models.BoardBar{{Price: d("99"), Amount: d("1")}},
+	})
+	b.Set(models.Ask, d("100.50"), d("0"))
+	b.Set(models.Bid, d("99.5"), d("3"))
+	board := b.Board()
+	if len(board.Asks) != 1 || board.Asks[0].Price.String() != "101" || len(board.Bids) != 2 || board.Bids[0].Price.String() != "99.5" {
+		t.Errorf("unexpected board %+v", board)
+	}
+	if tick := b.Tick(); tick.BestAskPrice.String() != "101" || tick.BestBidAmount.String() != "3" {
+		t.Errorf("unexpected tick %+v", tick)
+	}
+}
+
+func TestDecoders(t *testing.T) {
+	var gz, fl bytes.Buffer
+	w := gzip.NewWriter(&gz)
+	w.Write([]byte(`{"ping":1}`))
+	w.Close()
+	fw, _ := flate.NewWriter(&fl, flate.BestSpeed)
+	fw.Write([]byte("pong"))
+	fw.Close()
+
+	if msg, err := Gunzip(websocket.BinaryMessage, gz.Bytes()); err != nil || string(msg) != `{"ping":1}` {
+		t.Errorf("Expected %v. Got %s %v", `{"ping":1}`, msg, err)
+	}
+	if msg, err := Inflate(websocket.BinaryMessage, fl.Bytes()); err != nil || string(msg) != "pong" {
+		t.Errorf("Expected %v. Got %s %v", "pong", msg, err)
+	}
+	if msg, _ := Inflate(websocket.TextMessage, []byte("pong")); string(msg) != "pong" {
+		t.Errorf("Expected %v. Got %s", "pong", msg)
+	}
+}
+
+func TestSubscribers(t *testing.T) {
+	d := decimal.RequireFromString
+	var s Subscribers
+	ticks := s.AddTicks()
+	trades := s.AddTrades()
+	s.PublishTick(models.OrderBookTick{BestBidPrice: d("1")})
+	s.PublishTick(models.OrderBookTick{BestBidPrice: d("2")})
+	s.PublishTrade(models.PublicTrade{ID: "1"})
+	s.PublishTrade(models.PublicTrade{ID: "2"})
+	if tick := <-ticks; tick.BestBidPrice.String() != "2" || len(ticks) != 0 {
+		t.Errorf("expected only the latest tick, got %+v", tick)
+	}
+	if len(trades) != 2 {
+		t.Errorf("expected every trade, got %d", len(trades))
+	}
+	if !s.Remove(ticks) || s.Remove(ticks) || s.Len() != 1 {
+		t.Error("failed to remove subscriber")
+	}
+	if _, open := <-ticks; open {
+		t.Error("expected removed channel to be closed")
+	}
+	s.Close()
+	if s.Len() != 0 {
+		t.Error("expected no subscribers after close")
+	}
+}
@@ -0,0 +1,114 @@
+package stream
+
+import (
+	"github.com/fxpgr/go-exchange-client/logger"
+	"github.com/fxpgr/go-exchange-client/models"
+)
+
+// TradesBuffer is the number of trades a trades subscriber may fall behind
+// before trades are dropped.
+var TradesBuffer = 1024
+
+// Subscribers are the channels subscribed to one channel of an exchange. They
+// are not safe for concurrent use, the stream owning them guards them with
+// its own lock. Board and tick subscribers only get the latest value when
+// they fall behind, trade subscribers get every trade up to TradesBuffer.
+type Subscribers struct {
+	boards []chan *models.Board
+	ticks  []chan models.OrderBookTick
+	trades []chan models.PublicTrade
+}
+
+func (s *Subscribers) AddBoard() chan *models.Board {
+	ch := make(chan *models.Board, 1)
+	s.boards = append(s.boards, ch)
+	return ch
+}
+
+func (s *Subscribers) AddTicks() chan models.OrderBookTick {
+	ch := make(chan models.OrderBookTick, 1)
+	s.ticks = append(s.ticks, ch)
+	return ch
+}
+
+func (s *Subscribers) AddTrades() chan models.PublicTrade {
+	ch := make(chan models.PublicTrade, TradesBuffer)
+	s.trades = append(s.trades, ch)
+	return ch
+}
+
+// Remove closes and removes the channel ch, returning whether it was
+// subscribed.
+func (s *Subscribers) Remove(ch interface{}) bool {
+	for i, c := range s.boards {
+		if c == ch {
+			s.boards = append(s.boards[:i], s.boards[i+1:]...)
+			close(c)
+			return true
+		}
+	}
+	for i, c := range s.ticks {
+		if c == ch {
+			s.ticks = append(s.ticks[:i], s.ticks[i+1:]...)
+			close(c)
+			return true
+		}
+	}
+	for i, c := range s.trades {
+		if c == ch {
+			s.trades = append(s.trades[:i], s.trades[i+1:]...)
+			close(c)
+			return true
+		}
+	}
+	return false
+}
+
+func (s *Subscribers) Len() int {
+	return len(s.boards) + len(s.ticks) + len(s.trades)
+}
+
+// Close closes and removes every channel.
+func (s *Subscribers) Close() {
+	for _, c := range s.boards {
+		close(c)
+	}
+	for _, c := range s.ticks {
+		close(c)
+	}
+	for _, c := range s.trades {
+		close(c)
+	}
+	*s = Subscribers{}
+}
+
+// PublishBoard sends every board subscriber its own copy of book.
+func (s *Subscribers) PublishBoard(book *Book) {
+	for _, ch := range s.boards {
+		select {
+		case <-ch:
+		default:
+		}
+		ch <- book.Board()
+	}
+}
+
+func (s *Subscribers) PublishTick(tick models.OrderBookTick) {
+	for _, ch := range s.ticks {
+		select {
+		case <-ch:
+		default:
+		}
+		ch <- tick
+	}
+}
+
+func (s *Subscribers) PublishTrade(trade models.PublicTrade) {
+	for _, ch := range s.trades {
+		select {
+		case ch <- trade:
+		default:
+			logger.Get().Warnw("trade subscriber fell behind, dropping trade", "id", trade.ID)
+		}
+	}
+}
@@ -0,0 +1,174 @@
+// Package symbols maps the currency codes and pair symbols of each exchange
+// to the canonical, upper case currency codes of the models, and back.
+package symbols
+
+import (
+	"strings"
+	"sync"
+
+	"github.com/fxpgr/go-exchange-client/models"
+)
+
+// Format is how an exchange spells a pair symbol: the native codes of the
+// currencies, settlement first or last, joined by Separator.
+type Format struct {
+	Separator       string
+	SettlementFirst bool
+	Lower           bool
+}
+
+// Aliases are the codes every exchange may use for a canonical currency.
+var Aliases = map[string]string{
+	"XBT":   "BTC",
+	"MIOTA": "IOTA",
+}
+
+var formats = map[string]Format{
+	"binance":   {},
+	"bitflyer":  {Separator: "_"},
+	"cobinhood": {Separator: "-"},
+	"hitbtc":    {},
+	"huobi":     {Lower: true},
+	"kucoin":    {Separator: "-"},
+	"lbank":     {Separator: "_", Lower: true},
+	"okex":      {Separator: "_", Lower: true},
+	"p2pb2b":    {Separator: "_"},
+	"poloniex":  {Separator: "_", SettlementFirst: true},
+}
+
+// overrides are the native codes of an exchange for a canonical currency.
+var overrides = map[string]map[string]string{
+	"binance": {"BCC": "BCH"},
+}
+
+var (
+	registries = make(map[string]*Registry)
+	mtx        sync.Mutex
+)
+
+// Registry maps the native currency codes and pair symbols of one exchange
+// to canonical ones. Overrides apply both ways, while Aliases only apply to
+// native codes.
+type Registry struct {
+	Exchange string
+	Format   Format
+
+	m         sync.RWMutex
+	canonical map[string]string
+	native    map[string]string
+	pairs     map[string][2]string
+}
+
+// For returns the registry of exchange shared by its public and private
+// clients.
+func For(exchange string) *Registry {
+	exchange = strings.ToLower(exchange)
+	mtx.Lock()
+	defer mtx.Unlock()
+	r, ok := registries[exchange]
+	if !ok {
+		r = NewRegistry(exchange, formats[exchange])
+		for native, canonical := range overrides[exchange] {
+			r.Override(native, canonical)
+		}
+		registries[exchange] = r
+	}
+	return r
+}
+
+// NewRegistry returns a registry of exchange without overrides, which is not
+// the one For returns.
+func NewRegistry(exchange string, format Format) *Registry {
+	return &Registry{
+		Exchange:  exchange,
+		Format:    format,
+		canonical: make(map[string]string),
+		native:    make(map[string]string),
+		pairs:     make(map[string][2]string),
+	}
+}
+
+// Override makes native the code of the exchange for canonical.
+func (r *Registry) Override(native string, canonical string) {
+	native, canonical = strings.ToUpper(native), strings.ToUpper(canonical)
+	r.m.Lock()
+	defer r.m.Unlock()
+	r.canonical[native] = canonical
+	r.native[canonical] = native
+}
+
+// Currency returns the canonical code of the native code of a currency.
+func (r *Registry) Currency(native string) string {
+	native = strings.ToUpper(native)
+	r.m.RLock()
+	defer r.m.RUnlock()
+	if c, ok := r.canonical[native]; ok {
+		return c
+	}
+	if c, ok := Aliases[native]; ok {
+		return c
+	}
+	return native
+}
+
+// Native returns the code of the exchange for a canonical currency.
+func (r *Registry) Native(currency string) string {
+	currency = strings.ToUpper(currency)
+	r.m.RLock()
+	if n, ok := r.native[currency]; ok {
+		currency = n
+	}
+	r.m.RUnlock()
+	if r.Format.Lower {
+		return strings.ToLower(currency)
+	}
+	return currency
+}
+
+// join returns the symbol of a pair of native codes.
+func (r *Registry) join(trading string, settlement string) string {
+	if r.Format.SettlementFirst {
+		trading, settlement = settlement, trading
+	}
+	if r.Format.Lower {
+		return strings.ToLower(trading + r.Format.Separator + settlement)
+	}
+	return strings.ToUpper(trading + r.Format.Separator + settlement)
+}
+
+// Symbol returns the symbol of the exchange for a canonical pair.
+func (r *Registry) Symbol(trading string, settlement string) string {
+	return r.join(r.Native(trading), r.Native(settlement))
+}
+
+// AddPair registers a pair of native codes, so that Pair can split its
+// symbol when the exchange joins codes without a separator, and returns its
+// canonical pair.
+func (r *Registry) AddPair(trading string, settlement string) models.CurrencyPair {
+	r.m.Lock()
+	r.pairs[strings.ToUpper(r.join(trading, settlement))] = [2]string{trading, settlement}
+	r.m.Unlock()
+	return models.CurrencyPair{Trading: r.Currency(trading), Settlement: r.Currency(settlement)}
+}
+
+// Pair returns the canonical pair of a symbol of the exchange. Symbols
+// without a separator are only known once added by AddPair.
+func (r *Registry) Pair(symbol string) (models.CurrencyPair, bool) {
+	r.m.RLock()
+	natives, ok := r.pairs[strings.ToUpper(symbol)]
+	r.m.RUnlock()
+	if !ok {
+		if r.Format.Separator == "" {
+			return models.CurrencyPair{}, false
+		}
+		xs := strings.Split(symbol, r.Format.Separator)
+		if len(xs) != 2 || xs[0] == "" || xs[1] == "" {
+			return models.CurrencyPair{}, false
+		}
+		if r.Format.SettlementFirst {
+			xs[0], xs[1] = xs[1], xs[0]
+		}
+		natives = [2]string{xs[0], xs[1]}
+	}
+	return models.CurrencyPair{Trading: r.Currency(natives[0]), Settlement: r.Currency(natives[1])}, true
+}
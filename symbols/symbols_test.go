@@ -0,0 +1,72 @@
+package symbols
+
+import (
+	"testing"
+
+	"github.com/fxpgr/go-exchange-client/models"
+)
+
+func TestRegistryOverride(t *testing.T) {
+	r := NewRegistry("test", Format{})
+	r.Override("BCC", "BCH")
+	if c := r.Currency("bcc"); c != "BCH" {
+		t.Errorf("Expected %v. Got %v", "BCH", c)
+	}
+	if n := r.Native("BCH"); n != "BCC" {
+		t.Errorf("Expected %v. Got %v", "BCC", n)
+	}
+	if s := r.Symbol("BCH", "BTC"); s != "BCCBTC" {
+		t.Errorf("Expected %v. Got %v", "BCCBTC", s)
+	}
+	if c := r.Currency("XBT"); c != "BTC" {
+		t.Errorf("Expected %v. Got %v", "BTC", c)
+	}
+	if n := r.Native("BTC"); n != "BTC" {
+		t.Errorf("Expected %v. Got %v", "BTC", n)
+	}
+}
+
+func TestRegistryPair(t *testing.T) {
+	tests := []struct {
+		format Format
+		symbol string
+		pair   models.CurrencyPair
+		ok     bool
+	}{
+		{Format{Separator: "-"}, "ETH-BTC", models.CurrencyPair{Trading: "ETH", Settlement: "BTC"}, true},
+		{Format{Separator: "_", Lower: true}, "miota_btc", models.CurrencyPair{Trading: "IOTA", Settlement: "BTC"}, true},
+		{Format{Separator: "_", SettlementFirst: true}, "BTC_ETH", models.CurrencyPair{Trading: "ETH", Settlement: "BTC"}, true},
+		{Format{Separator: "_"}, "ETHBTC", models.CurrencyPair{}, false},
+		{Format{}, "ETHBTC", models.CurrencyPair{}, false},
+	}
+	for _, test := range tests {
+		pair, ok := NewRegistry("test", test.format).Pair(test.symbol)
+		if pair != test.pair || ok != test.ok {
+			t.Errorf("%+v: Expected %v %v. Got %v %v", test.format, test.pair, test.ok, pair, ok)
+		}
+	}
+
+	r := NewRegistry("test", Format{Lower: true})
+	if pair := r.AddPair("bcc", "usdt"); pair.Trading != "BCC" || pair.Settlement != "USDT" {
+		t.Errorf("unexpected pair %+v", pair)
+	}
+	r.Override("BCC", "BCH")
+	if pair, ok := r.Pair("bccusdt"); !ok || pair.Trading != "BCH" || pair.Settlement != "USDT" {
+		t.Errorf("unexpected pair %+v %v", pair, ok)
+	}
+	if s := r.Symbol("BCH", "USDT"); s != "bccusdt" {
+		t.Errorf("Expected %v. Got %v", "bccusdt", s)
+	}
+}
+
+func TestFor(t *testing.T) {
+	if For("Binance") != For("binance") {
+		t.Error("Expected the same registry")
+	}
+	if c := For("binance").Currency("BCC"); c != "BCH" {
+		t.Errorf("Expected %v. Got %v", "BCH", c)
+	}
+	if s := For("poloniex").Symbol("ETH", "BTC"); s != "BTC_ETH" {
+		t.Errorf("Expected %v. Got %v", "BTC_ETH", s)
+	}
+}